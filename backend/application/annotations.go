@@ -0,0 +1,110 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+)
+
+// CommitAnnotationRepo returns the delivery metadata CI attached to the commits of a repository
+type CommitAnnotationRepo interface {
+	GetCommitAnnotation(ctx context.Context, repo, commit string) (*domain.CommitAnnotation, error)
+}
+
+// commitAnnotation returns the annotation of the commit of the repository of the source, nil if there is none
+func (r *ReconciliationManager) commitAnnotation(ctx context.Context, src *domain.Source, commit string) *domain.CommitAnnotation {
+	if r.cfg.Annotations == nil || commit == "" {
+		return nil
+	}
+	a, err := r.cfg.Annotations.GetCommitAnnotation(ctx, RepoName(src.URL), commit)
+	if err != nil {
+		if err != errors.ErrNotFound {
+			r.logger.LogError(ctx, "Could not GetCommitAnnotation of %s:%v", commit, err)
+		}
+		return nil
+	}
+	return a
+}
+
+// annotationInfos returns the infos about the build of an annotated commit for notifications
+func annotationInfos(a *domain.CommitAnnotation) []NotifyAdditionalInfos {
+	if a == nil {
+		return nil
+	}
+	infos := []NotifyAdditionalInfos{}
+	if a.BuildNumber != "" || a.BuildURL != "" {
+		build := strings.TrimSpace(fmt.Sprintf("%s %s", a.BuildNumber, a.BuildURL))
+		infos = append(infos, NotifyAdditionalInfos{
+			Header: "Build",
+			Text:   build,
+		})
+	}
+	if len(a.Tickets) > 0 {
+		infos = append(infos, NotifyAdditionalInfos{
+			Header: "Tickets",
+			Text:   strings.Join(a.Tickets, ", "),
+		})
+	}
+	if len(a.Artifacts) > 0 {
+		artifacts := []string{}
+		for _, art := range a.Artifacts {
+			artifacts = append(artifacts, art.Name+"@"+art.Digest)
+		}
+		infos = append(infos, NotifyAdditionalInfos{
+			Header: "Artifacts",
+			Text:   strings.Join(artifacts, "\n"),
+			Large:  true,
+		})
+	}
+	return infos
+}
+
+// AppliedCommit is a commit the jobs of a source are currently applied at, with its annotation if CI added one
+type AppliedCommit struct {
+	Commit     string                   `json:"commit"`
+	Jobs       []string                 `json:"jobs"`
+	Annotation *domain.CommitAnnotation `json:"annotation,omitempty"`
+}
+
+// AppliedCommits returns the commits the jobs of the source are applied at with their annotations,
+// ordered by the first job applied at a commit
+func AppliedCommits(ctx context.Context, repo CommitAnnotationRepo, src *domain.Source) ([]*AppliedCommit, error) {
+	res := []*AppliedCommit{}
+	if src.Status == nil {
+		return res, nil
+	}
+	names := make([]string, 0, len(src.Status.Jobs))
+	for name := range src.Status.Jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	byCommit := map[string]*AppliedCommit{}
+	for _, name := range names {
+		commit := src.Status.Jobs[name].LastAppliedCommit
+		if commit == "" {
+			continue
+		}
+		applied, ok := byCommit[commit]
+		if !ok {
+			applied = &AppliedCommit{Commit: commit}
+			byCommit[commit] = applied
+			res = append(res, applied)
+		}
+		applied.Jobs = append(applied.Jobs, name)
+	}
+	for _, applied := range res {
+		a, err := repo.GetCommitAnnotation(ctx, RepoName(src.URL), applied.Commit)
+		if err == errors.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		applied.Annotation = a
+	}
+	return res, nil
+}
@@ -0,0 +1,52 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+)
+
+type fakeAnnotationRepo map[string]*domain.CommitAnnotation
+
+func (r fakeAnnotationRepo) GetCommitAnnotation(ctx context.Context, repo, commit string) (*domain.CommitAnnotation, error) {
+	a, ok := r[repo+"@"+commit]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	return a, nil
+}
+
+func TestAppliedCommits(t *testing.T) {
+	repo := fakeAnnotationRepo{
+		"ops/jobs@c2": {Repo: "ops/jobs", Commit: "c2", BuildNumber: "7", Tickets: []string{"OPS-1"}},
+	}
+	src := &domain.Source{
+		URL: "git@github.com:ops/jobs.git",
+		Status: &domain.SourceStatus{Jobs: map[string]domain.JobStatus{
+			"web":    {LastAppliedCommit: "c2"},
+			"api":    {LastAppliedCommit: "c1"},
+			"worker": {LastAppliedCommit: "c2"},
+			"failed": {},
+		}},
+	}
+	applied, err := AppliedCommits(context.Background(), repo, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0].Commit != "c1" || applied[0].Annotation != nil ||
+		applied[1].Commit != "c2" || len(applied[1].Jobs) != 2 || applied[1].Annotation.BuildNumber != "7" {
+		t.Errorf("Unexpected applied commits %+v", applied)
+	}
+
+	infos := annotationInfos(applied[1].Annotation)
+	if len(infos) != 2 || infos[0].Header != "Build" || infos[0].Text != "7" || infos[1].Text != "OPS-1" {
+		t.Errorf("Unexpected infos %+v", infos)
+	}
+
+	// CI may send the name or a clone url of the repository
+	if RepoName("ops/jobs") != "ops/jobs" || RepoName("https://github.com/ops/jobs.git") != "ops/jobs" {
+		t.Errorf("Expected repository names to be normalized")
+	}
+}
@@ -0,0 +1,60 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// Action is an operation triggered by a user, e.g. a manual sync.
+// All Nomad mutations performed as a consequence are linked to it in the audit log.
+type Action struct {
+	ID   string
+	Type domain.AuditActionType
+	// UserID is empty if the action was triggered by an admin
+	UserID string
+	// Actor describes who triggered the action, e.g. the email of the user
+	Actor string
+	Time  time.Time
+}
+
+type actionKey struct{}
+
+// WithAction attaches the action to the context
+func WithAction(ctx context.Context, a *Action) context.Context {
+	if a == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, actionKey{}, a)
+}
+
+// ActionFromContext returns the action attached to the context or nil for automatic syncs
+func ActionFromContext(ctx context.Context) *Action {
+	a, _ := ctx.Value(actionKey{}).(*Action)
+	return a
+}
+
+type AuditRepo interface {
+	SaveAuditEntry(ctx context.Context, e *domain.AuditEntry) error
+}
+
+// NewAuditEntry creates an entry for a Nomad mutation, linked to the action in the context if there is one
+func NewAuditEntry(ctx context.Context, src *domain.Source, op domain.AuditOperation) *domain.AuditEntry {
+	e := &domain.AuditEntry{
+		ActionType: domain.AuditActionTypeAutomatic,
+		Actor:      "nomad-ops",
+		Operation:  op,
+		Timestamp:  time.Now(),
+	}
+	if src != nil {
+		e.SourceID = src.ID
+	}
+	if a := ActionFromContext(ctx); a != nil {
+		e.ActionID = a.ID
+		e.ActionType = a.Type
+		e.UserID = a.UserID
+		e.Actor = a.Actor
+	}
+	return e
+}
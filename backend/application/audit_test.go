@@ -0,0 +1,28 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestNewAuditEntry(t *testing.T) {
+	src := &domain.Source{ID: "src"}
+
+	e := NewAuditEntry(context.Background(), src, domain.AuditOperationRegisterJob)
+	if e.ActionType != domain.AuditActionTypeAutomatic || e.ActionID != "" || e.SourceID != "src" {
+		t.Errorf("Expected an automatic entry, got %+v", e)
+	}
+
+	ctx := WithAction(context.Background(), &Action{
+		ID:     "a1",
+		Type:   domain.AuditActionTypeRestart,
+		UserID: "u1",
+		Actor:  "jdoe@example.org",
+	})
+	e = NewAuditEntry(ctx, src, domain.AuditOperationDeregisterJob)
+	if e.ActionID != "a1" || e.ActionType != domain.AuditActionTypeRestart || e.UserID != "u1" || e.Actor != "jdoe@example.org" {
+		t.Errorf("Expected the entry to be linked to the action, got %+v", e)
+	}
+}
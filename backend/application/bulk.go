@@ -0,0 +1,279 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type BulkOperation string
+
+const (
+	BulkOperationPause  BulkOperation = "pause"
+	BulkOperationResume BulkOperation = "resume"
+	BulkOperationSync   BulkOperation = "sync"
+	// BulkOperationPrune deletes the jobs of the sources and the sources
+	BulkOperationPrune BulkOperation = "prune"
+)
+
+const (
+	BulkResultOK      = "ok"
+	BulkResultSkipped = "skipped"
+	BulkResultFailed  = "failed"
+)
+
+type BulkSourceRepo interface {
+	ListSources(ctx context.Context, opts ListSourcesOptions) ([]*domain.Source, error)
+	SetPaused(ctx context.Context, id string, paused bool) error
+}
+
+type SourceSyncer interface {
+	SyncSourceByID(ctx context.Context, id string, opts SyncSourceOptions) error
+}
+
+// SourceFilter selects sources, a source matches if it matches all set fields
+type SourceFilter struct {
+	IDs []string `json:"ids,omitempty"`
+	// Teams matches sources owned by any of the teams
+	Teams []string `json:"teams,omitempty"`
+	// Name is a glob, e.g. preview-*
+	Name       string `json:"name,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+	Region     string `json:"region,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	DataCenter string `json:"dataCenter,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Paused     *bool  `json:"paused,omitempty"`
+	// Labels is a label selector, e.g. env=prod,tier!=frontend
+	Labels string `json:"labels,omitempty"`
+}
+
+// IsEmpty returns true if the filter matches all sources
+func (f SourceFilter) IsEmpty() bool {
+	return len(f.IDs) == 0 && len(f.Teams) == 0 && f.Name == "" && f.URL == "" && f.Branch == "" &&
+		f.Region == "" && f.Namespace == "" && f.DataCenter == "" && f.Status == "" && f.Paused == nil &&
+		f.Labels == ""
+}
+
+// Matches returns true if the source matches all set fields, sources never match an invalid label selector
+func (f SourceFilter) Matches(src *domain.Source) bool {
+	if f.Labels != "" {
+		sel, err := domain.ParseLabelSelector(f.Labels)
+		if err != nil || !sel.Matches(src.Labels) {
+			return false
+		}
+	}
+	if len(f.IDs) > 0 && !contains(f.IDs, src.ID) {
+		return false
+	}
+	if len(f.Teams) > 0 {
+		found := false
+		for _, team := range src.TeamIDs {
+			found = found || contains(f.Teams, team)
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Name != "" {
+		if ok, _ := path.Match(f.Name, src.Name); !ok {
+			return false
+		}
+	}
+	status := ""
+	if src.Status != nil {
+		status = src.Status.Status
+	}
+	return (f.URL == "" || f.URL == src.URL) &&
+		(f.Branch == "" || f.Branch == src.Branch) &&
+		(f.Region == "" || f.Region == src.Region) &&
+		(f.Namespace == "" || f.Namespace == src.Namespace) &&
+		(f.DataCenter == "" || f.DataCenter == src.DataCenter) &&
+		(f.Status == "" || f.Status == status) &&
+		(f.Paused == nil || *f.Paused == src.Paused)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+type BulkRequest struct {
+	Operation BulkOperation `json:"operation"`
+	Filter    SourceFilter  `json:"filter"`
+	// All must be set to select all sources with an empty filter
+	All bool `json:"all,omitempty"`
+	// DryRun only reports the matching sources
+	DryRun bool `json:"dryRun,omitempty"`
+	// Restart restarts the jobs when syncing
+	Restart bool `json:"restart,omitempty"`
+	// Confirm must be the number of matching sources to prune them
+	Confirm string `json:"confirm,omitempty"`
+}
+
+type BulkResult struct {
+	SourceID   string `json:"sourceId"`
+	SourceName string `json:"sourceName"`
+	Result     string `json:"result"`
+	Message    string `json:"message,omitempty"`
+}
+
+// BulkReport is the consolidated result of a bulk operation, with one result per matching source
+type BulkReport struct {
+	Operation BulkOperation `json:"operation"`
+	DryRun    bool          `json:"dryRun,omitempty"`
+	ActionID  string        `json:"actionId,omitempty"`
+	Matched   int           `json:"matched"`
+	Succeeded int           `json:"succeeded"`
+	Skipped   int           `json:"skipped"`
+	Failed    int           `json:"failed"`
+	Results   []BulkResult  `json:"results"`
+}
+
+func (r *BulkReport) add(src *domain.Source, result, msg string) {
+	switch result {
+	case BulkResultOK:
+		r.Succeeded++
+	case BulkResultSkipped:
+		r.Skipped++
+	case BulkResultFailed:
+		r.Failed++
+	}
+	r.Results = append(r.Results, BulkResult{
+		SourceID:   src.ID,
+		SourceName: src.Name,
+		Result:     result,
+		Message:    msg,
+	})
+}
+
+// BulkOperator pauses, resumes, syncs or prunes all sources matching a filter,
+// e.g. to pause everything deploying to a region during an incident
+type BulkOperator struct {
+	ctx     context.Context
+	logger  log.Logger
+	repo    BulkSourceRepo
+	updater SourceUpdater
+	syncer  SourceSyncer
+	deleter SourceDeleter
+	// shard limits pruning to the sources of this instance, nil prunes all sources
+	shard Shard
+}
+
+func CreateBulkOperator(ctx context.Context,
+	logger log.Logger,
+	repo BulkSourceRepo,
+	updater SourceUpdater,
+	syncer SourceSyncer,
+	deleter SourceDeleter,
+	shard Shard) (*BulkOperator, error) {
+	t := &BulkOperator{
+		ctx:     ctx,
+		logger:  logger,
+		repo:    repo,
+		updater: updater,
+		syncer:  syncer,
+		deleter: deleter,
+		shard:   shard,
+	}
+
+	return t, nil
+}
+
+// Run applies the operation to the matching sources visible to the user, the action of the context is used
+// for all of them. Failures of single sources do not stop the operation, they are part of the report.
+func (b *BulkOperator) Run(ctx context.Context, req BulkRequest, visible func(srcID string) bool) (*BulkReport, error) {
+	switch req.Operation {
+	case BulkOperationPause, BulkOperationResume, BulkOperationSync, BulkOperationPrune:
+	default:
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("unknown operation %q", req.Operation))
+	}
+	if req.Filter.IsEmpty() && !req.All {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("the filter matches all sources, set all to confirm"))
+	}
+	if _, err := domain.ParseLabelSelector(req.Filter.Labels); err != nil {
+		return nil, err
+	}
+	srcs, err := b.repo.ListSources(ctx, ListSourcesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	matching := []*domain.Source{}
+	for _, src := range srcs {
+		if req.Filter.Matches(src) && visible(src.ID) {
+			matching = append(matching, src)
+		}
+	}
+
+	report := &BulkReport{
+		Operation: req.Operation,
+		DryRun:    req.DryRun,
+		Matched:   len(matching),
+		Results:   []BulkResult{},
+	}
+	if action := ActionFromContext(ctx); action != nil {
+		report.ActionID = action.ID
+	}
+	if req.DryRun {
+		for _, src := range matching {
+			report.add(src, BulkResultSkipped, "Dry run")
+		}
+		return report, nil
+	}
+	if req.Operation == BulkOperationPrune && req.Confirm != strconv.Itoa(len(matching)) {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest,
+			fmt.Errorf("confirm the pruning of the %d matching sources with confirm %q", len(matching), strconv.Itoa(len(matching))))
+	}
+
+	b.logger.LogInfo(ctx, "Running bulk %s on %d sources...", req.Operation, len(matching))
+	for _, src := range matching {
+		result, msg := b.apply(ctx, req, src)
+		report.add(src, result, msg)
+	}
+	return report, nil
+}
+
+func (b *BulkOperator) apply(ctx context.Context, req BulkRequest, src *domain.Source) (string, string) {
+	var err error
+	switch req.Operation {
+	case BulkOperationPause, BulkOperationResume:
+		paused := req.Operation == BulkOperationPause
+		if src.Paused == paused {
+			return BulkResultSkipped, fmt.Sprintf("Source is already %sd", req.Operation)
+		}
+		err = b.repo.SetPaused(ctx, src.ID, paused)
+		if err == nil {
+			src.Paused = paused
+			err = b.updater.OnUpdatedSource(ctx, src)
+		}
+	case BulkOperationSync:
+		err = b.syncer.SyncSourceByID(ctx, src.ID, SyncSourceOptions{
+			ForceRestart: req.Restart,
+			Action:       ActionFromContext(ctx),
+		})
+		if err == errors.ErrNotFound {
+			return BulkResultSkipped, "Source is reconciled by the primary"
+		}
+	case BulkOperationPrune:
+		if b.shard != nil && !b.shard.Owns(src.ID) {
+			// the primary would recreate the pruned jobs
+			return BulkResultSkipped, "Source is reconciled by the primary"
+		}
+		err = b.deleter.DeleteSource(ctx, src)
+	}
+	if err != nil {
+		b.logger.LogError(ctx, "Could not %s source %s:%v", req.Operation, src.ID, err)
+		return BulkResultFailed, err.Error()
+	}
+	return BulkResultOK, ""
+}
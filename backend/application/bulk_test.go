@@ -0,0 +1,131 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeBulkRepo struct {
+	fakePromotionRepo
+}
+
+func (r *fakeBulkRepo) SetPaused(ctx context.Context, id string, paused bool) error {
+	r.find(id).Paused = paused
+	return nil
+}
+
+type fakeSyncer struct {
+	synced []string
+}
+
+func (s *fakeSyncer) SyncSourceByID(ctx context.Context, id string, opts SyncSourceOptions) error {
+	if id == "other" {
+		return errors.ErrNotFound
+	}
+	s.synced = append(s.synced, id)
+	return nil
+}
+
+type fakeOwnedShard struct{}
+
+func (fakeOwnedShard) Owns(srcID string) bool {
+	return srcID != "other"
+}
+
+func TestBulkOperator(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeBulkRepo{fakePromotionRepo{srcs: []*domain.Source{
+		{ID: "web", Name: "web", Region: "eu", TeamIDs: []string{"shop"}},
+		{ID: "api", Name: "api", Region: "eu", Paused: true},
+		{ID: "other", Name: "preview-1", Region: "eu"},
+		{ID: "db", Name: "db", Region: "us", Labels: map[string]string{"env": "prod", "tier": "data"}},
+	}}}
+	syncer := &fakeSyncer{}
+	deleter := &recordingDeleter{}
+	b, err := CreateBulkOperator(ctx, log.NewSimpleLogger(false, "Test"), repo,
+		&recordingUpdater{updated: make(chan *domain.Source, 10)},
+		syncer,
+		deleter,
+		fakeOwnedShard{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := func(string) bool { return true }
+	format := func(r *BulkReport) string {
+		res := []string{}
+		for _, result := range r.Results {
+			res = append(res, fmt.Sprintf("%s:%s", result.SourceID, result.Result))
+		}
+		return fmt.Sprintf("%d/%d/%d %s", r.Succeeded, r.Skipped, r.Failed, strings.Join(res, " "))
+	}
+
+	// everything in a region, without the sources the user can not see
+	report, err := b.Run(ctx, BulkRequest{Operation: BulkOperationPause, Filter: SourceFilter{Region: "eu"}}, func(id string) bool {
+		return id != "other"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format(report) != "1/1/0 web:ok api:skipped" || !repo.find("web").Paused {
+		t.Errorf("Unexpected report %s", format(report))
+	}
+
+	report, err = b.Run(ctx, BulkRequest{Operation: BulkOperationSync, Filter: SourceFilter{Region: "eu"}}, all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format(report) != "2/1/0 web:ok api:ok other:skipped" {
+		t.Errorf("Unexpected report %s", format(report))
+	}
+
+	report, err = b.Run(ctx, BulkRequest{Operation: BulkOperationResume, Filter: SourceFilter{Teams: []string{"shop"}}}, all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format(report) != "1/0/0 web:ok" || repo.find("web").Paused {
+		t.Errorf("Unexpected report %s", format(report))
+	}
+
+	report, err = b.Run(ctx, BulkRequest{Operation: BulkOperationSync, Filter: SourceFilter{Labels: "env=prod,tier!=web"}}, all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format(report) != "1/0/0 db:ok" {
+		t.Errorf("Unexpected report %s", format(report))
+	}
+	_, err = b.Run(ctx, BulkRequest{Operation: BulkOperationSync, Filter: SourceFilter{Labels: "=prod"}}, all)
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeInvalidRequest {
+		t.Errorf("Expected an invalid selector to be rejected, got %v", err)
+	}
+
+	// an empty filter must be confirmed
+	_, err = b.Run(ctx, BulkRequest{Operation: BulkOperationPause}, all)
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeInvalidRequest {
+		t.Errorf("Expected an empty filter to be rejected, got %v", err)
+	}
+
+	// pruning must be confirmed with the number of matching sources
+	prune := BulkRequest{Operation: BulkOperationPrune, Filter: SourceFilter{Name: "preview-*"}, Confirm: "2"}
+	_, err = b.Run(ctx, prune, all)
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeInvalidRequest || len(deleter.deleted) != 0 {
+		t.Errorf("Expected the wrong confirmation to be rejected, got %v", err)
+	}
+	prune.DryRun = true
+	report, err = b.Run(ctx, prune, all)
+	if err != nil || format(report) != "0/1/0 other:skipped" || len(deleter.deleted) != 0 {
+		t.Errorf("Unexpected dry run %s %v", format(report), err)
+	}
+	prune.DryRun = false
+	prune.Filter = SourceFilter{Region: "eu"}
+	prune.Confirm = "3"
+	report, err = b.Run(ctx, prune, all)
+	if err != nil || format(report) != "2/1/0 web:ok api:ok other:skipped" || len(deleter.deleted) != 2 {
+		t.Errorf("Unexpected report %s %v", format(report), err)
+	}
+}
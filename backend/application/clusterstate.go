@@ -0,0 +1,71 @@
+package application
+
+import (
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// NewClusterState groups the jobs of a source by namespace. CurrentJobs is keyed by job name,
+// unless a name exists in several namespaces, then by <namespace>/<name>.
+func NewClusterState(jobs []*api.Job) *ClusterState {
+	s := &ClusterState{
+		CurrentJobs: map[string]*JobInfo{},
+		Namespaces:  map[string]map[string]*JobInfo{},
+	}
+	count := map[string]int{}
+	for _, j := range jobs {
+		ns := jobNamespace(nil, j)
+		if s.Namespaces[ns] == nil {
+			s.Namespaces[ns] = map[string]*JobInfo{}
+		}
+		s.Namespaces[ns][strPtrToStr(j.Name)] = &JobInfo{Job: j}
+		count[strPtrToStr(j.Name)]++
+	}
+	for ns, byName := range s.Namespaces {
+		for name, j := range byName {
+			if count[name] > 1 {
+				s.CurrentJobs[ns+"/"+name] = j
+				continue
+			}
+			s.CurrentJobs[name] = j
+		}
+	}
+	return s
+}
+
+// resolveNamespaces keys the job in the namespace a name is desired in like the desired job, by its name or, if
+// the name is desired in several namespaces, by <namespace>/<name>, so it is updated. The jobs with that name in
+// other namespaces, e.g. after the namespace of a job changed, are keyed by <namespace>/<name> to be deleted.
+func (s *ClusterState) resolveNamespaces(src *domain.Source, desiredState *DesiredState) {
+	if s.Namespaces == nil {
+		return
+	}
+	for key, desired := range desiredState.Jobs {
+		ns := jobNamespace(src, desired.Job)
+		name := strPtrToStr(desired.Name)
+		if cur, ok := s.CurrentJobs[key]; ok && jobNamespace(nil, cur.Job) != ns {
+			delete(s.CurrentJobs, key)
+			s.CurrentJobs[jobNamespace(nil, cur.Job)+"/"+name] = cur
+		}
+		if j, ok := s.Namespaces[ns][name]; ok {
+			for _, k := range []string{name, ns + "/" + name} {
+				if s.CurrentJobs[k] == j {
+					delete(s.CurrentJobs, k)
+				}
+			}
+			s.CurrentJobs[key] = j
+		}
+	}
+}
+
+// jobNamespace returns the namespace of the job, falling back to the one of the source and the default namespace
+func jobNamespace(src *domain.Source, job *api.Job) string {
+	if job != nil && job.Namespace != nil && *job.Namespace != "" {
+		return *job.Namespace
+	}
+	if src != nil && src.Namespace != "" {
+		return src.Namespace
+	}
+	return "default"
+}
@@ -0,0 +1,51 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestClusterStateNamespaces(t *testing.T) {
+	job := func(ns, name string) *api.Job {
+		return &api.Job{ID: log.ToStrPtr(name), Name: log.ToStrPtr(name), Namespace: log.ToStrPtr(ns)}
+	}
+	s := NewClusterState([]*api.Job{job("a", "web"), job("b", "web"), job("a", "api"), job("b", "worker"), job("a", "old")})
+
+	keys := func() string {
+		res := []string{}
+		for k, j := range s.CurrentJobs {
+			res = append(res, k+"@"+*j.Namespace)
+		}
+		sort.Strings(res)
+		return fmt.Sprint(res)
+	}
+	if got := keys(); got != "[a/web@a api@a b/web@b old@a worker@b]" {
+		t.Errorf("Unexpected keys %s", got)
+	}
+
+	// web is desired in b, worker moved from b to the namespace of the source
+	s.resolveNamespaces(&domain.Source{Namespace: "a"}, &DesiredState{Jobs: map[string]*JobInfo{
+		"web":    {Job: job("b", "web")},
+		"api":    {Job: &api.Job{Name: log.ToStrPtr("api")}},
+		"worker": {Job: &api.Job{Name: log.ToStrPtr("worker")}},
+	}})
+	if got := keys(); got != "[a/web@a api@a b/worker@b old@a web@b]" {
+		t.Errorf("Unexpected keys after resolving %s", got)
+	}
+
+	// api is desired in a and b, the desired jobs are keyed by namespace
+	s = NewClusterState([]*api.Job{job("a", "api")})
+	s.resolveNamespaces(&domain.Source{}, &DesiredState{Jobs: map[string]*JobInfo{
+		"a/api": {Job: job("a", "api")},
+		"b/api": {Job: job("b", "api")},
+	}})
+	if got := keys(); got != "[a/api@a]" {
+		t.Errorf("Unexpected keys of a name desired in several namespaces %s", got)
+	}
+}
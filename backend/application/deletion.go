@@ -0,0 +1,90 @@
+package application
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// MetaKeyWaitFor lists the jobs a job depends on, comma separated ids of the same namespace or <namespace>/<id>
+const MetaKeyWaitFor = "nomadopswaitfor"
+
+// WaitFor returns the jobs the job depends on as <namespace>/<id>
+func WaitFor(job *api.Job) []string {
+	res := []string{}
+	if job == nil {
+		return res
+	}
+	seen := map[string]bool{}
+	for _, dep := range strings.Split(job.Meta[MetaKeyWaitFor], ",") {
+		dep = strings.TrimSpace(dep)
+		if dep == "" {
+			continue
+		}
+		if !strings.Contains(dep, "/") {
+			dep = jobNamespace(nil, job) + "/" + dep
+		}
+		if !seen[dep] {
+			seen[dep] = true
+			res = append(res, dep)
+		}
+	}
+	return res
+}
+
+// DeletionWaves groups the jobs, keyed by any name, into waves that are deleted one after the other. A job is
+// deleted in an earlier wave than the jobs it waits for, so apps are removed before the databases and gateways
+// they depend on. Jobs depending on each other in a cycle are deleted in the last wave. Each wave is sorted.
+func DeletionWaves(jobs map[string]*api.Job) [][]string {
+	byID := map[string]string{}
+	for k, j := range jobs {
+		if j != nil && j.ID != nil {
+			byID[jobNamespace(nil, j)+"/"+*j.ID] = k
+		}
+	}
+	// number of jobs to delete that still wait for a job
+	waiting := map[string]int{}
+	// jobs a job waits for
+	deps := map[string][]string{}
+	for k, j := range jobs {
+		waiting[k] += 0
+		for _, dep := range WaitFor(j) {
+			d, ok := byID[dep]
+			if !ok || d == k {
+				continue
+			}
+			deps[k] = append(deps[k], d)
+			waiting[d]++
+		}
+	}
+
+	waves := [][]string{}
+	for len(waiting) > 0 {
+		wave := []string{}
+		for k, n := range waiting {
+			if n == 0 {
+				wave = append(wave, k)
+			}
+		}
+		if len(wave) == 0 {
+			// cycle, the remaining jobs are deleted at once
+			for k := range waiting {
+				wave = append(wave, k)
+			}
+		}
+		for _, k := range wave {
+			delete(waiting, k)
+		}
+		for _, k := range wave {
+			for _, d := range deps[k] {
+				if _, ok := waiting[d]; ok {
+					waiting[d]--
+				}
+			}
+		}
+		sort.Strings(wave)
+		waves = append(waves, wave)
+	}
+	return waves
+}
@@ -0,0 +1,43 @@
+package application
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+func TestDeletionWaves(t *testing.T) {
+	job := func(ns, id, waitFor string) *api.Job {
+		return &api.Job{ID: &id, Namespace: &ns, Meta: map[string]string{MetaKeyWaitFor: waitFor}}
+	}
+	jobs := map[string]*api.Job{
+		"web":      job("default", "web", "api"),
+		"api":      job("default", "api", "db, infra/vault, gone"),
+		"db":       job("default", "db", ""),
+		"vault":    job("infra", "vault", ""),
+		"worker":   job("default", "worker", "db"),
+		"cron":     job("default", "cron", ""),
+		"a":        job("default", "a", "b"),
+		"b":        job("default", "b", "a"),
+		"selfish":  job("default", "selfish", "selfish"),
+		"sidecar":  job("default", "sidecar", "a"),
+		"other-db": job("other", "db", ""),
+	}
+
+	waves := DeletionWaves(jobs)
+	expected := [][]string{
+		{"cron", "other-db", "selfish", "sidecar", "web", "worker"},
+		{"api"},
+		{"db", "vault"},
+		// the cycle is deleted last
+		{"a", "b"},
+	}
+	if !reflect.DeepEqual(waves, expected) {
+		t.Errorf("Expected waves %v, got %v", expected, waves)
+	}
+
+	if waves := DeletionWaves(map[string]*api.Job{}); len(waves) != 0 {
+		t.Errorf("Expected no waves, got %v", waves)
+	}
+}
@@ -0,0 +1,135 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type SourceExpiryRepo interface {
+	ListSources(ctx context.Context, opts ListSourcesOptions) ([]*domain.Source, error)
+	SetExpiryWarned(ctx context.Context, id string, t time.Time) error
+}
+
+// SourceDeleter prunes the jobs of a source and deletes it
+type SourceDeleter interface {
+	DeleteSource(ctx context.Context, src *domain.Source) error
+}
+
+type SourceExpirerConfig struct {
+	// Interval is the time between two checks for expired sources
+	Interval time.Duration
+	// Warning is the time before the expiry a notification is sent, 0 disables it
+	Warning time.Duration
+	// Shard limits the sources to the ones of this instance, nil handles all sources
+	Shard Shard
+}
+
+// SourceExpirer deletes sources after their expiry, e.g. preview environments
+type SourceExpirer struct {
+	ctx      context.Context
+	logger   log.Logger
+	cfg      SourceExpirerConfig
+	repo     SourceExpiryRepo
+	deleter  SourceDeleter
+	notifier Notifier
+}
+
+func CreateSourceExpirer(ctx context.Context,
+	logger log.Logger,
+	cfg SourceExpirerConfig,
+	repo SourceExpiryRepo,
+	deleter SourceDeleter,
+	notifier Notifier) (*SourceExpirer, error) {
+	t := &SourceExpirer{
+		ctx:      ctx,
+		logger:   logger,
+		cfg:      cfg,
+		repo:     repo,
+		deleter:  deleter,
+		notifier: notifier,
+	}
+
+	return t, nil
+}
+
+// Run checks the sources every interval until the context is done
+func (e *SourceExpirer) Run(ctx context.Context) {
+	t := time.NewTicker(e.cfg.Interval)
+	defer t.Stop()
+	for {
+		err := e.Check(ctx, time.Now())
+		if err != nil {
+			e.logger.LogError(ctx, "Could not check the expiry of sources:%v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Check deletes expired sources and warns about sources that expire within the warning period once
+func (e *SourceExpirer) Check(ctx context.Context, now time.Time) error {
+	srcs, err := e.repo.ListSources(ctx, ListSourcesOptions{})
+	if err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		if src.ExpiresAt == nil || (e.cfg.Shard != nil && !e.cfg.Shard.Owns(src.ID)) {
+			continue
+		}
+		if !now.Before(*src.ExpiresAt) {
+			e.expire(ctx, src)
+			continue
+		}
+		warnAt := src.ExpiresAt.Add(-e.cfg.Warning)
+		// the expiry may have been extended after the last warning
+		warned := src.ExpiryWarnedAt != nil && !src.ExpiryWarnedAt.Before(warnAt)
+		if e.cfg.Warning > 0 && !now.Before(warnAt) && !warned {
+			e.notify(ctx, src, NotificationInfo,
+				fmt.Sprintf("Source expires at %s, its jobs will be deleted", src.ExpiresAt.UTC().Format(time.RFC3339)))
+			err := e.repo.SetExpiryWarned(ctx, src.ID, now)
+			if err != nil {
+				e.logger.LogError(ctx, "Could not SetExpiryWarned of %s:%v", src.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *SourceExpirer) expire(ctx context.Context, src *domain.Source) {
+	e.logger.LogInfo(ctx, "Source %s (%s) expired at %v, deleting it...", src.Name, src.ID, src.ExpiresAt)
+	err := e.deleter.DeleteSource(ctx, src)
+	if err != nil {
+		e.logger.LogError(ctx, "Could not delete expired source %s:%v", src.ID, err)
+		e.notify(ctx, src, NotificationError, fmt.Sprintf("Could not delete the expired source: %v", err))
+		return
+	}
+	e.notify(ctx, src, NotificationSuccess, "Source expired, its jobs and the source were deleted")
+}
+
+func (e *SourceExpirer) notify(ctx context.Context, src *domain.Source, t NotificationType, msg string) {
+	err := e.notifier.Notify(ctx, NotifyOptions{
+		Source:  src,
+		Type:    t,
+		Message: msg,
+		Infos: []NotifyAdditionalInfos{
+			{
+				Header: "Git-Url",
+				Text:   src.URL,
+			},
+			{
+				Header: "Git-Rev",
+				Text:   src.Branch,
+			},
+		},
+	})
+	if err != nil {
+		e.logger.LogError(ctx, "Could not notify:%v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeExpiryRepo struct {
+	srcs []*domain.Source
+}
+
+func (r *fakeExpiryRepo) ListSources(ctx context.Context, opts ListSourcesOptions) ([]*domain.Source, error) {
+	return r.srcs, nil
+}
+
+func (r *fakeExpiryRepo) SetExpiryWarned(ctx context.Context, id string, t time.Time) error {
+	for _, src := range r.srcs {
+		if src.ID == id {
+			src.ExpiryWarnedAt = &t
+		}
+	}
+	return nil
+}
+
+type recordingDeleter struct {
+	deleted []string
+}
+
+func (d *recordingDeleter) DeleteSource(ctx context.Context, src *domain.Source) error {
+	d.deleted = append(d.deleted, src.ID)
+	return nil
+}
+
+func TestSourceExpirerCheck(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Minute)
+	soon := now.Add(time.Hour)
+	later := now.Add(48 * time.Hour)
+	repo := &fakeExpiryRepo{srcs: []*domain.Source{
+		{ID: "expired", ExpiresAt: &expired},
+		{ID: "soon", ExpiresAt: &soon},
+		{ID: "later", ExpiresAt: &later},
+		{ID: "forever"},
+	}}
+	d := &recordingDeleter{}
+	n := &recordingNotifier{}
+	e, err := CreateSourceExpirer(context.Background(), log.NewSimpleLogger(false, "Test"),
+		SourceExpirerConfig{Warning: 24 * time.Hour}, repo, d, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Check(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.deleted) != 1 || d.deleted[0] != "expired" {
+		t.Errorf("Expected only the expired source to be deleted, got %v", d.deleted)
+	}
+	if len(n.sent) != 2 || n.sent[1].Source.ID != "soon" || n.sent[1].Type != NotificationInfo {
+		t.Fatalf("Expected a deletion notification and a warning for soon, got %+v", n.sent)
+	}
+
+	// the warning is only sent once
+	repo.srcs = repo.srcs[1:]
+	if err := e.Check(context.Background(), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.sent) != 2 {
+		t.Errorf("Expected no further notifications, got %+v", n.sent[2:])
+	}
+}
@@ -0,0 +1,140 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// FreezeFeed is an external calendar of change freezes
+type FreezeFeed interface {
+	FetchFreezeWindows(ctx context.Context) ([]domain.FreezeWindow, error)
+}
+
+// FreezeChecker returns the freeze window syncs of a source with the labels are blocked by at the given time,
+// nil if there is none
+type FreezeChecker interface {
+	ActiveFreeze(now time.Time, labels map[string]string) *domain.FreezeWindow
+}
+
+type FreezeCalendarConfig struct {
+	// Interval between the refreshes of the feed
+	Interval time.Duration
+}
+
+// FreezeOverride lifts all freezes until the given time
+type FreezeOverride struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// FreezeCalendar keeps the freeze windows of a feed. If the feed can not be fetched,
+// the last known windows stay in effect.
+type FreezeCalendar struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    FreezeCalendarConfig
+	feed   FreezeFeed
+
+	lock     sync.Mutex
+	windows  []domain.FreezeWindow
+	override *FreezeOverride
+}
+
+func CreateFreezeCalendar(ctx context.Context,
+	logger log.Logger,
+	cfg FreezeCalendarConfig,
+	feed FreezeFeed) (*FreezeCalendar, error) {
+	c := &FreezeCalendar{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+		feed:   feed,
+	}
+
+	err := c.Refresh(ctx)
+	if err != nil {
+		// syncs must not be blocked from starting the server
+		logger.LogError(ctx, "Could not fetch the freeze windows:%v", err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(c.cfg.Interval):
+			}
+			err := c.Refresh(c.ctx)
+			if err != nil {
+				c.logger.LogError(c.ctx, "Could not refresh the freeze windows, keeping the last known ones:%v", err)
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// Refresh fetches the freeze windows of the feed
+func (c *FreezeCalendar) Refresh(ctx context.Context) error {
+	windows, err := c.feed.FetchFreezeWindows(ctx)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.windows = windows
+	return nil
+}
+
+// ActiveFreeze returns the freeze window of a source with the labels containing the given time,
+// the one ending last if several overlap. It returns nil while an admin override is in effect.
+func (c *FreezeCalendar) ActiveFreeze(now time.Time, labels map[string]string) *domain.FreezeWindow {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.override != nil && now.Before(c.override.Until) {
+		return nil
+	}
+	var active *domain.FreezeWindow
+	for i := range c.windows {
+		w := c.windows[i]
+		if w.Contains(now) && w.Freezes(labels) && (active == nil || w.End.After(active.End)) {
+			active = &w
+		}
+	}
+	return active
+}
+
+// Windows returns the known freeze windows that did not end yet
+func (c *FreezeCalendar) Windows(now time.Time) []domain.FreezeWindow {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	res := []domain.FreezeWindow{}
+	for _, w := range c.windows {
+		if now.Before(w.End) {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
+// Override lifts all freezes until the given time, nil ends the override
+func (c *FreezeCalendar) Override(o *FreezeOverride) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.override = o
+}
+
+// ActiveOverride returns the override in effect at the given time or nil
+func (c *FreezeCalendar) ActiveOverride(now time.Time) *FreezeOverride {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.override == nil || !now.Before(c.override.Until) {
+		return nil
+	}
+	o := *c.override
+	return &o
+}
@@ -0,0 +1,69 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeFreezeFeed struct {
+	windows []domain.FreezeWindow
+	err     error
+}
+
+func (f *fakeFreezeFeed) FetchFreezeWindows(ctx context.Context) ([]domain.FreezeWindow, error) {
+	return f.windows, f.err
+}
+
+func TestFreezeCalendar(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := time.Date(2023, 12, 20, 12, 0, 0, 0, time.UTC)
+	feed := &fakeFreezeFeed{windows: []domain.FreezeWindow{
+		{Start: now.Add(-time.Hour), End: now.Add(time.Hour), Reason: "Release"},
+		{Start: now.Add(-2 * time.Hour), End: now.Add(48 * time.Hour), Reason: "Holidays"},
+		{Start: now.Add(72 * time.Hour), End: now.Add(96 * time.Hour), Reason: "Later"},
+		{Start: now.Add(-time.Hour), End: now.Add(72 * time.Hour), Reason: "Prod only", Selector: "env=prod"},
+	}}
+	c, err := CreateFreezeCalendar(ctx, log.NewSimpleLogger(false, "Test"), FreezeCalendarConfig{
+		Interval: time.Hour,
+	}, feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the window ending last wins
+	if f := c.ActiveFreeze(now, nil); f == nil || f.Reason != "Holidays" {
+		t.Errorf("Expected the holiday freeze, got %+v", f)
+	}
+	if f := c.ActiveFreeze(now, map[string]string{"env": "prod"}); f == nil || f.Reason != "Prod only" {
+		t.Errorf("Expected the freeze of prod, got %+v", f)
+	}
+	if f := c.ActiveFreeze(now.Add(50*time.Hour), nil); f != nil {
+		t.Errorf("Expected no freeze between windows, got %+v", f)
+	}
+	if f := c.ActiveFreeze(now.Add(96*time.Hour), nil); f != nil {
+		t.Errorf("Expected the end of a window to be excluded, got %+v", f)
+	}
+
+	// the last known windows are kept if the feed is unreachable
+	feed.err = fmt.Errorf("unreachable")
+	if err := c.Refresh(ctx); err == nil {
+		t.Errorf("Expected the error of the feed")
+	}
+	if f := c.ActiveFreeze(now, nil); f == nil {
+		t.Errorf("Expected the freeze to be kept")
+	}
+
+	c.Override(&FreezeOverride{Until: now.Add(time.Hour)})
+	if f := c.ActiveFreeze(now, nil); f != nil {
+		t.Errorf("Expected no freeze during an override, got %+v", f)
+	}
+	if f := c.ActiveFreeze(now.Add(time.Hour), nil); f == nil {
+		t.Errorf("Expected the freeze after the override ended")
+	}
+}
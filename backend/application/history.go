@@ -0,0 +1,319 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type JobHistoryRepo interface {
+	SaveJobRevision(ctx context.Context, rev *domain.JobRevision) error
+	// ListJobRevisions returns the revisions of the source until the time, oldest first, all for a zero time
+	ListJobRevisions(ctx context.Context, srcID string, until time.Time) ([]*domain.JobRevision, error)
+}
+
+// JobHistory records the normalized jobs registered for a source after every sync that changed them,
+// so the jobs a source deployed at any point in time can be looked up and compared
+type JobHistory struct {
+	ctx     context.Context
+	logger  log.Logger
+	cluster ClusterAPI
+	repo    JobHistoryRepo
+
+	lock sync.Mutex
+	// latest are the hashes of the last recorded revisions of the registered jobs by source
+	latest map[string]map[string]string
+}
+
+func CreateJobHistory(ctx context.Context,
+	logger log.Logger,
+	cluster ClusterAPI,
+	repo JobHistoryRepo) (*JobHistory, error) {
+	t := &JobHistory{
+		ctx:     ctx,
+		logger:  logger,
+		cluster: cluster,
+		repo:    repo,
+		latest:  map[string]map[string]string{},
+	}
+
+	return t, nil
+}
+
+// OnSynced records the jobs of the source that changed since the last sync
+func (h *JobHistory) OnSynced(ctx context.Context, src *domain.Source) {
+	err := h.Record(ctx, src, time.Now())
+	if err != nil {
+		h.logger.LogError(ctx, "Could not record the job history of %s:%v", src.ID, err)
+	}
+}
+
+// Record stores a revision of every registered job of the source that changed and marks the jobs
+// that are no longer registered as deleted
+func (h *JobHistory) Record(ctx context.Context, src *domain.Source, now time.Time) error {
+	state, err := h.cluster.GetCurrentClusterState(ctx, GetCurrentClusterStateOptions{
+		Source: src,
+	})
+	if err != nil {
+		return err
+	}
+	commit := ""
+	if src.Status != nil {
+		commit = src.Status.AppliedCommit()
+	}
+	revs := map[string]*domain.JobRevision{}
+	for _, job := range state.CurrentJobs {
+		cpy := normalizedJob(job.Job)
+		data, err := json.Marshal(cpy)
+		if err != nil {
+			return err
+		}
+		rev := &domain.JobRevision{
+			SourceID:  src.ID,
+			Namespace: jobNamespace(nil, cpy),
+			Job:       *cpy.ID,
+			Commit:    commit,
+			Spec:      data,
+			Hash:      fmt.Sprintf("%x", sha256.Sum256(data)),
+			Timestamp: now,
+		}
+		revs[rev.Key()] = rev
+	}
+
+	latest, err := h.latestHashes(ctx, src.ID)
+	if err != nil {
+		return err
+	}
+	for key, hash := range latest {
+		if _, ok := revs[key]; !ok {
+			rev := &domain.JobRevision{
+				SourceID:  src.ID,
+				Commit:    commit,
+				Deleted:   true,
+				Timestamp: now,
+			}
+			rev.Namespace, rev.Job, _ = strings.Cut(key, "/")
+			revs[key] = rev
+			continue
+		}
+		if revs[key].Hash == hash {
+			delete(revs, key)
+		}
+	}
+
+	keys := make([]string, 0, len(revs))
+	for key := range revs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		rev := revs[key]
+		err := h.repo.SaveJobRevision(ctx, rev)
+		if err != nil {
+			return err
+		}
+		h.lock.Lock()
+		if rev.Deleted {
+			delete(h.latest[src.ID], key)
+		} else {
+			h.latest[src.ID][key] = rev.Hash
+		}
+		h.lock.Unlock()
+	}
+	return nil
+}
+
+// latestHashes returns a copy of the hashes of the registered jobs of the source as last recorded
+func (h *JobHistory) latestHashes(ctx context.Context, srcID string) (map[string]string, error) {
+	h.lock.Lock()
+	latest, ok := h.latest[srcID]
+	h.lock.Unlock()
+	if !ok {
+		state, err := h.StateAt(ctx, srcID, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		latest = map[string]string{}
+		for key, rev := range state.Jobs {
+			latest[key] = rev.Hash
+		}
+		h.lock.Lock()
+		h.latest[srcID] = latest
+		h.lock.Unlock()
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	res := make(map[string]string, len(latest))
+	for k, v := range latest {
+		res[k] = v
+	}
+	return res, nil
+}
+
+// StateAt returns the jobs the source had registered at the time, the latest state for a zero time
+func (h *JobHistory) StateAt(ctx context.Context, srcID string, at time.Time) (*domain.SourceStateAt, error) {
+	revs, err := h.repo.ListJobRevisions(ctx, srcID, at)
+	if err != nil {
+		return nil, err
+	}
+	state := &domain.SourceStateAt{
+		Time: at,
+		Jobs: map[string]*domain.JobRevision{},
+	}
+	for _, rev := range revs {
+		state.Commit = rev.Commit
+		if rev.Deleted {
+			delete(state.Jobs, rev.Key())
+			continue
+		}
+		state.Jobs[rev.Key()] = rev
+	}
+	return state, nil
+}
+
+// Diff returns the jobs of the source that differ between the two points in time
+func (h *JobHistory) Diff(ctx context.Context, srcID string, from, to time.Time) (*domain.SourceStateDiff, error) {
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("%s is before %s", to.Format(time.RFC3339), from.Format(time.RFC3339)))
+	}
+	fromState, err := h.StateAt(ctx, srcID, from)
+	if err != nil {
+		return nil, err
+	}
+	toState, err := h.StateAt(ctx, srcID, to)
+	if err != nil {
+		return nil, err
+	}
+	return DiffSourceStates(fromState, toState)
+}
+
+// DiffSourceStates compares the jobs of two states field by field
+func DiffSourceStates(from, to *domain.SourceStateAt) (*domain.SourceStateDiff, error) {
+	res := &domain.SourceStateDiff{
+		From: from,
+		To:   to,
+		Jobs: map[string]*domain.JobChange{},
+	}
+	for key, rev := range from.Jobs {
+		if _, ok := to.Jobs[key]; !ok {
+			res.Jobs[key] = &domain.JobChange{Type: domain.JobChangeTypeRemoved}
+			continue
+		}
+		if rev.Hash == to.Jobs[key].Hash {
+			continue
+		}
+		var a, b interface{}
+		if err := json.Unmarshal(rev.Spec, &a); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(to.Jobs[key].Spec, &b); err != nil {
+			return nil, err
+		}
+		fields := []domain.FieldChange{}
+		if err := diffJSON("", a, b, true, true, &fields); err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].Path < fields[j].Path
+		})
+		res.Jobs[key] = &domain.JobChange{
+			Type:   domain.JobChangeTypeChanged,
+			Fields: fields,
+		}
+	}
+	for key := range to.Jobs {
+		if _, ok := from.Jobs[key]; !ok {
+			res.Jobs[key] = &domain.JobChange{Type: domain.JobChangeTypeAdded}
+		}
+	}
+	return res, nil
+}
+
+// diffJSON appends the leaves that differ between the decoded JSON values, hasA and hasB tell whether
+// the values exist at all
+func diffJSON(path string, a, b interface{}, hasA, hasB bool, res *[]domain.FieldChange) error {
+	if hasA && hasB {
+		switch av := a.(type) {
+		case map[string]interface{}:
+			if bv, ok := b.(map[string]interface{}); ok {
+				keys := map[string]bool{}
+				for k := range av {
+					keys[k] = true
+				}
+				for k := range bv {
+					keys[k] = true
+				}
+				for k := range keys {
+					p := k
+					if path != "" {
+						p = path + "." + k
+					}
+					va, okA := av[k]
+					vb, okB := bv[k]
+					if err := diffJSON(p, va, vb, okA, okB, res); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		case []interface{}:
+			if bv, ok := b.([]interface{}); ok {
+				for i := 0; i < len(av) || i < len(bv); i++ {
+					var va, vb interface{}
+					if i < len(av) {
+						va = av[i]
+					}
+					if i < len(bv) {
+						vb = bv[i]
+					}
+					if err := diffJSON(fmt.Sprintf("%s[%d]", path, i), va, vb, i < len(av), i < len(bv), res); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+	}
+	change := domain.FieldChange{Path: path}
+	var err error
+	if hasA {
+		change.From, err = marshalCompact(a)
+		if err != nil {
+			return err
+		}
+	}
+	if hasB {
+		change.To, err = marshalCompact(b)
+		if err != nil {
+			return err
+		}
+	}
+	*res = append(*res, change)
+	return nil
+}
+
+func marshalCompact(v interface{}) (json.RawMessage, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
@@ -0,0 +1,123 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type historyCluster struct {
+	ClusterAPI
+	jobs map[string]*api.Job
+}
+
+func (c *historyCluster) GetCurrentClusterState(ctx context.Context, opts GetCurrentClusterStateOptions) (*ClusterState, error) {
+	state := &ClusterState{CurrentJobs: map[string]*JobInfo{}}
+	for name, job := range c.jobs {
+		state.CurrentJobs[name] = &JobInfo{Job: job}
+	}
+	return state, nil
+}
+
+type memJobHistory struct {
+	revs []*domain.JobRevision
+}
+
+func (r *memJobHistory) SaveJobRevision(ctx context.Context, rev *domain.JobRevision) error {
+	r.revs = append(r.revs, rev)
+	return nil
+}
+
+func (r *memJobHistory) ListJobRevisions(ctx context.Context, srcID string, until time.Time) ([]*domain.JobRevision, error) {
+	res := []*domain.JobRevision{}
+	for _, rev := range r.revs {
+		if rev.SourceID == srcID && (until.IsZero() || !rev.Timestamp.After(until)) {
+			res = append(res, rev)
+		}
+	}
+	return res, nil
+}
+
+func TestJobHistory(t *testing.T) {
+	ctx := context.Background()
+	job := func(id string, count int, version uint64) *api.Job {
+		return &api.Job{
+			ID:         &id,
+			Version:    &version,
+			TaskGroups: []*api.TaskGroup{{Name: &id, Count: &count}},
+		}
+	}
+	cluster := &historyCluster{jobs: map[string]*api.Job{
+		"web": job("web", 1, 1),
+		"db":  job("db", 1, 1),
+	}}
+	repo := &memJobHistory{}
+	h, err := CreateJobHistory(ctx, log.NewSimpleLogger(false, "Test"), cluster, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &domain.Source{ID: "src"}
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+
+	if err := h.Record(ctx, src, t1); err != nil {
+		t.Fatal(err)
+	}
+	// only the version changed, which is not recorded
+	cluster.jobs["web"] = job("web", 1, 2)
+	if err := h.Record(ctx, src, t1.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.revs) != 2 {
+		t.Fatalf("Expected unchanged jobs not to be recorded, got %d revisions", len(repo.revs))
+	}
+
+	cluster.jobs["web"] = job("web", 3, 3)
+	delete(cluster.jobs, "db")
+	cluster.jobs["api"] = job("api", 1, 1)
+	if err := h.Record(ctx, src, t2); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := h.StateAt(ctx, "src", t1.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Jobs) != 2 || state.Jobs["default/db"] == nil || state.Jobs["default/web"] == nil {
+		t.Errorf("Unexpected jobs at t1 %v", state.Jobs)
+	}
+
+	diff, err := h.Diff(ctx, "src", t1, t3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Jobs) != 3 ||
+		diff.Jobs["default/api"].Type != domain.JobChangeTypeAdded ||
+		diff.Jobs["default/db"].Type != domain.JobChangeTypeRemoved ||
+		diff.Jobs["default/web"].Type != domain.JobChangeTypeChanged {
+		t.Fatalf("Unexpected diff %v", diff.Jobs)
+	}
+	fields := diff.Jobs["default/web"].Fields
+	if len(fields) != 1 || fields[0].Path != "TaskGroups[0].Count" || string(fields[0].From) != "1" || string(fields[0].To) != "3" {
+		t.Errorf("Unexpected changed fields %+v", fields)
+	}
+
+	if _, err := h.Diff(ctx, "src", t3, t1); domain.ErrorCodeOf(err, "") != domain.ErrorCodeInvalidRequest {
+		t.Errorf("Expected reversed times to be rejected, got %v", err)
+	}
+
+	// a restarted history continues with the stored revisions
+	h, _ = CreateJobHistory(ctx, log.NewSimpleLogger(false, "Test"), cluster, repo)
+	if err := h.Record(ctx, src, t3); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.revs) != 5 {
+		t.Errorf("Expected no revisions after a restart, got %d", len(repo.revs)-5)
+	}
+}
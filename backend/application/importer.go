@@ -0,0 +1,268 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+const (
+	ImportGroupByNamespace = "namespace"
+	// ImportGroupByMetaPrefix groups jobs by the value of a meta key, e.g. meta:team
+	ImportGroupByMetaPrefix = "meta:"
+
+	ImportFormatJSON = "json"
+	// ImportFormatHCL exports the job files submitted to Nomad (1.6 or newer), jobs without a stored
+	// submission are exported as JSON
+	ImportFormatHCL = "hcl"
+)
+
+// ImportedJob is a job registered in Nomad without being managed by a source
+type ImportedJob struct {
+	Job *api.Job
+	// Submission is the HCL job file the job was submitted with, empty if Nomad did not store it
+	// or the job file needs variables
+	Submission string
+}
+
+type ImportCluster interface {
+	ListUnmanagedJobs(ctx context.Context, withSubmissions bool) ([]*ImportedJob, error)
+}
+
+type ImportSourceRepo interface {
+	CreateSource(ctx context.Context, settings map[string]any) (*domain.Source, error)
+}
+
+type SourceAdder interface {
+	OnAddedSource(ctx context.Context, src *domain.Source) error
+}
+
+type ImporterConfig struct {
+	AuthorName  string
+	AuthorEmail string
+}
+
+type ImportRequest struct {
+	// GroupBy is namespace or meta:<key>, every group becomes a source
+	GroupBy string `json:"groupBy"`
+	// Format of the exported job files, json or hcl
+	Format string `json:"format,omitempty"`
+	// URL and Branch of the repository the jobs are exported to
+	URL    string `json:"url"`
+	Branch string `json:"branch,omitempty"`
+	// Path is the directory of the exported jobs in the repository, each group gets a subdirectory
+	Path        string `json:"path,omitempty"`
+	DeployKeyID string `json:"deployKey,omitempty"`
+	// DryRun only returns the suggested sources
+	DryRun bool `json:"dryRun,omitempty"`
+	// Adopt creates the suggested sources after exporting the jobs
+	Adopt bool `json:"adopt,omitempty"`
+	// Paused creates the sources paused, to review their plans before they adopt the jobs
+	Paused bool `json:"paused,omitempty"`
+}
+
+type ImportedSource struct {
+	Name string `json:"name"`
+	// Source are the suggested settings of the source
+	Source map[string]any `json:"source"`
+	Jobs   []string       `json:"jobs"`
+	Files  []string       `json:"files"`
+	// ID of the created source, if the sources were adopted
+	ID string `json:"id,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun  bool              `json:"dryRun,omitempty"`
+	Sources []*ImportedSource `json:"sources"`
+	// Ungrouped are the jobs without the meta key, they are not exported
+	Ungrouped []string `json:"ungrouped,omitempty"`
+}
+
+// Importer migrates jobs registered without nomad-ops: it groups them into suggested sources, exports
+// their live specs to a repository and creates the sources, which adopt the jobs on their first sync
+type Importer struct {
+	ctx     context.Context
+	logger  log.Logger
+	cfg     ImporterConfig
+	cluster ImportCluster
+	writer  SnapshotWriter
+	repo    ImportSourceRepo
+	adder   SourceAdder
+}
+
+func CreateImporter(ctx context.Context,
+	logger log.Logger,
+	cfg ImporterConfig,
+	cluster ImportCluster,
+	writer SnapshotWriter,
+	repo ImportSourceRepo,
+	adder SourceAdder) (*Importer, error) {
+	t := &Importer{
+		ctx:     ctx,
+		logger:  logger,
+		cfg:     cfg,
+		cluster: cluster,
+		writer:  writer,
+		repo:    repo,
+		adder:   adder,
+	}
+
+	return t, nil
+}
+
+var importNameRegex = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (i *Importer) Run(ctx context.Context, req ImportRequest) (*ImportReport, error) {
+	if req.GroupBy != ImportGroupByNamespace && (!strings.HasPrefix(req.GroupBy, ImportGroupByMetaPrefix) ||
+		req.GroupBy == ImportGroupByMetaPrefix) {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("groupBy must be namespace or meta:<key>"))
+	}
+	if req.Format == "" {
+		req.Format = ImportFormatJSON
+	}
+	if req.Format != ImportFormatJSON && req.Format != ImportFormatHCL {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("format must be json or hcl"))
+	}
+	if req.URL == "" {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("the url of the repository is required"))
+	}
+	if req.Branch == "" {
+		req.Branch = "main"
+	}
+	req.Path = strings.Trim(path.Clean("/"+req.Path), "/")
+	if req.Path == "" {
+		// the export replaces the directory, it must not be the root of the repository
+		req.Path = "nomad"
+	}
+
+	jobs, err := i.cluster.ListUnmanagedJobs(ctx, req.Format == ImportFormatHCL)
+	if err != nil {
+		return nil, err
+	}
+	report, files, err := planImport(req, jobs)
+	if err != nil {
+		return nil, err
+	}
+	if req.DryRun || len(report.Sources) == 0 {
+		return report, nil
+	}
+
+	i.logger.LogInfo(ctx, "Exporting %d jobs of %d sources to %s...", len(files), len(report.Sources), req.URL)
+	err = i.writer.WriteSnapshot(ctx, &domain.Source{
+		URL:         req.URL,
+		DeployKeyID: req.DeployKeyID,
+	}, Snapshot{
+		Branch:      req.Branch,
+		Dir:         req.Path,
+		Files:       files,
+		Message:     fmt.Sprintf("Import of %d jobs from Nomad", len(files)),
+		AuthorName:  i.cfg.AuthorName,
+		AuthorEmail: i.cfg.AuthorEmail,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !req.Adopt {
+		return report, nil
+	}
+	for _, s := range report.Sources {
+		src, err := i.repo.CreateSource(ctx, s.Source)
+		if err != nil {
+			return report, fmt.Errorf("could not create source %s: %w", s.Name, err)
+		}
+		s.ID = src.ID
+		err = i.adder.OnAddedSource(ctx, src)
+		if err != nil {
+			return report, fmt.Errorf("could not watch source %s: %w", s.Name, err)
+		}
+	}
+	return report, nil
+}
+
+// planImport groups the jobs and returns the files of the export, by path relative to the path of the request
+func planImport(req ImportRequest, jobs []*ImportedJob) (*ImportReport, map[string][]byte, error) {
+	report := &ImportReport{
+		DryRun:  req.DryRun,
+		Sources: []*ImportedSource{},
+	}
+	files := map[string][]byte{}
+	groups := map[string][]*ImportedJob{}
+	for _, j := range jobs {
+		group := jobNamespace(nil, j.Job)
+		if key := strings.TrimPrefix(req.GroupBy, ImportGroupByMetaPrefix); key != req.GroupBy {
+			group = j.Job.Meta[key]
+		}
+		group = strings.Trim(importNameRegex.ReplaceAllString(group, "-"), "-.")
+		if group == "" {
+			report.Ungrouped = append(report.Ungrouped, fmt.Sprintf("%s/%s", jobNamespace(nil, j.Job), *j.Job.ID))
+			continue
+		}
+		groups[group] = append(groups[group], j)
+	}
+	sort.Strings(report.Ungrouped)
+
+	names := []string{}
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := &ImportedSource{
+			Name: name,
+			Source: map[string]any{
+				"name":      name,
+				"url":       req.URL,
+				"branch":    req.Branch,
+				"path":      path.Join(req.Path, name),
+				"deployKey": req.DeployKeyID,
+				"paused":    req.Paused,
+			},
+		}
+		namespaces := map[string]bool{}
+		sort.Slice(groups[name], func(a, b int) bool { return *groups[name][a].Job.ID < *groups[name][b].Job.ID })
+		for _, j := range groups[name] {
+			namespaces[jobNamespace(nil, j.Job)] = true
+			file, data, err := exportJob(req.Format, j)
+			if err != nil {
+				return nil, nil, err
+			}
+			file = path.Join(name, file)
+			if _, ok := files[file]; ok {
+				// the same job id in different namespaces
+				file = path.Join(name, jobNamespace(nil, j.Job)+"-"+path.Base(file))
+			}
+			files[file] = data
+			s.Jobs = append(s.Jobs, fmt.Sprintf("%s/%s", jobNamespace(nil, j.Job), *j.Job.ID))
+			s.Files = append(s.Files, path.Join(req.Path, file))
+		}
+		if len(namespaces) == 1 {
+			// the namespace is not part of submitted job files run with -namespace
+			for ns := range namespaces {
+				s.Source["namespace"] = ns
+			}
+		}
+		report.Sources = append(report.Sources, s)
+	}
+	return report, files, nil
+}
+
+// exportJob returns the file name and content of the job, JSON jobs are named <job>.nomad.json
+func exportJob(format string, j *ImportedJob) (string, []byte, error) {
+	if format == ImportFormatHCL && j.Submission != "" {
+		return *j.Job.ID + ".nomad", []byte(j.Submission), nil
+	}
+	data, err := json.MarshalIndent(normalizedJob(j.Job), "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+	return *j.Job.ID + ".nomad.json", append(data, '\n'), nil
+}
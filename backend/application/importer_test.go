@@ -0,0 +1,152 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeImportCluster struct {
+	jobs []*ImportedJob
+}
+
+func (c *fakeImportCluster) ListUnmanagedJobs(ctx context.Context, withSubmissions bool) ([]*ImportedJob, error) {
+	res := []*ImportedJob{}
+	for _, j := range c.jobs {
+		cpy := *j
+		if !withSubmissions {
+			cpy.Submission = ""
+		}
+		res = append(res, &cpy)
+	}
+	return res, nil
+}
+
+type recordingSnapshotWriter struct {
+	src       *domain.Source
+	snapshots []Snapshot
+}
+
+func (w *recordingSnapshotWriter) WriteSnapshot(ctx context.Context, src *domain.Source, snapshot Snapshot) error {
+	w.src = src
+	w.snapshots = append(w.snapshots, snapshot)
+	return nil
+}
+
+type fakeImportRepo struct {
+	created []map[string]any
+}
+
+func (r *fakeImportRepo) CreateSource(ctx context.Context, settings map[string]any) (*domain.Source, error) {
+	r.created = append(r.created, settings)
+	return &domain.Source{ID: "id-" + settings["name"].(string), Name: settings["name"].(string)}, nil
+}
+
+type recordingAdder struct {
+	added []string
+}
+
+func (a *recordingAdder) OnAddedSource(ctx context.Context, src *domain.Source) error {
+	a.added = append(a.added, src.ID)
+	return nil
+}
+
+func importJob(namespace, id string, meta map[string]string) *api.Job {
+	return &api.Job{
+		ID:          &id,
+		Name:        &id,
+		Namespace:   &namespace,
+		Meta:        meta,
+		Status:      log.ToStrPtr("running"),
+		ModifyIndex: func() *uint64 { i := uint64(42); return &i }(),
+	}
+}
+
+func TestImporter(t *testing.T) {
+	ctx := context.Background()
+	cluster := &fakeImportCluster{jobs: []*ImportedJob{
+		{Job: importJob("default", "web", map[string]string{"team": "shop"}), Submission: `job "web" {}`},
+		{Job: importJob("default", "api", map[string]string{"team": "shop"})},
+		{Job: importJob("infra", "traefik", map[string]string{"team": "platform"})},
+		{Job: importJob("infra", "legacy", nil)},
+	}}
+	writer := &recordingSnapshotWriter{}
+	repo := &fakeImportRepo{}
+	adder := &recordingAdder{}
+	i, err := CreateImporter(ctx, log.NewSimpleLogger(false, "Test"), ImporterConfig{AuthorName: "nomad-ops"},
+		cluster, writer, repo, adder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range []ImportRequest{
+		{GroupBy: "node", URL: "git@example.com:ops/jobs.git"},
+		{GroupBy: "meta:", URL: "git@example.com:ops/jobs.git"},
+		{GroupBy: "namespace"},
+		{GroupBy: "namespace", URL: "git@example.com:ops/jobs.git", Format: "yaml"},
+	} {
+		if _, err := i.Run(ctx, req); domain.ErrorCodeOf(err, "") != domain.ErrorCodeInvalidRequest {
+			t.Errorf("Expected %v to be invalid, got %v", req, err)
+		}
+	}
+
+	// dry runs only suggest the sources
+	report, err := i.Run(ctx, ImportRequest{GroupBy: "namespace", URL: "git@example.com:ops/jobs.git", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(writer.snapshots) != 0 || len(report.Sources) != 2 ||
+		strings.Join(report.Sources[0].Jobs, ",") != "default/api,default/web" ||
+		strings.Join(report.Sources[1].Files, ",") != "nomad/infra/legacy.nomad.json,nomad/infra/traefik.nomad.json" ||
+		report.Sources[1].Source["namespace"] != "infra" || report.Sources[1].Source["path"] != "nomad/infra" {
+		t.Errorf("Unexpected report %s", log.ToJSONString(report))
+	}
+
+	// the export uses submitted job files if available and the sources are created
+	report, err = i.Run(ctx, ImportRequest{
+		GroupBy: "meta:team",
+		Format:  "hcl",
+		URL:     "git@example.com:ops/jobs.git",
+		Branch:  "gitops",
+		Path:    "/clusters/prod/",
+		Adopt:   true,
+		Paused:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Ungrouped, ",") != "infra/legacy" || len(writer.snapshots) != 1 {
+		t.Fatalf("Unexpected report %s", log.ToJSONString(report))
+	}
+	snapshot := writer.snapshots[0]
+	files := []string{}
+	for name := range snapshot.Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	if snapshot.Branch != "gitops" || snapshot.Dir != "clusters/prod" ||
+		strings.Join(files, ",") != "platform/traefik.nomad.json,shop/api.nomad.json,shop/web.nomad" ||
+		writer.src.URL != "git@example.com:ops/jobs.git" {
+		t.Errorf("Unexpected snapshot %s %v", snapshot.Dir, files)
+	}
+	exported := &api.Job{}
+	if err := json.Unmarshal(snapshot.Files["shop/api.nomad.json"], exported); err != nil ||
+		*exported.ID != "api" || exported.Status != nil || exported.ModifyIndex != nil {
+		t.Errorf("Expected the normalized job, got %s %v", snapshot.Files["shop/api.nomad.json"], err)
+	}
+	if string(snapshot.Files["shop/web.nomad"]) != `job "web" {}` {
+		t.Errorf("Expected the submitted job file, got %s", snapshot.Files["shop/web.nomad"])
+	}
+	if len(repo.created) != 2 || repo.created[1]["path"] != "clusters/prod/shop" || repo.created[1]["paused"] != true ||
+		repo.created[1]["branch"] != "gitops" || repo.created[1]["namespace"] != "default" ||
+		strings.Join(adder.added, ",") != "id-platform,id-shop" || report.Sources[1].ID != "id-shop" {
+		t.Errorf("Unexpected sources %v %v", repo.created, adder.added)
+	}
+}
@@ -0,0 +1,310 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// Incident is opened for a condition of a source and resolved once the source recovers
+type Incident struct {
+	SourceID   string
+	SourceName string
+	Condition  domain.IncidentCondition
+	// Summary describes the condition, e.g. the error of the last sync
+	Summary string
+	// Details are additional information like the status message of the source
+	Details map[string]string
+}
+
+// Key identifies the incident of the condition of the source, e.g. as dedup key
+func (i *Incident) Key() string {
+	return fmt.Sprintf("nomad-ops/%s/%s", i.SourceID, i.Condition)
+}
+
+// Alerter opens and resolves incidents, both are idempotent
+type Alerter interface {
+	Trigger(ctx context.Context, inc *Incident) error
+	Resolve(ctx context.Context, inc *Incident) error
+}
+
+type IncidentTrackerConfig struct {
+	// Conditions incidents are opened for, all if empty
+	Conditions []domain.IncidentCondition
+	// SyncFailingFor is the time the syncs of a source fail before an incident is opened
+	SyncFailingFor time.Duration
+	AppName        string
+}
+
+// IncidentTracker opens and resolves incidents for the conditions of the sources, based on their status.
+// It is used as SourceStatusPatcher of the watcher, storing the open incidents in the status of the sources,
+// so they are resolved after a restart as well.
+type IncidentTracker struct {
+	ctx      context.Context
+	logger   log.Logger
+	cfg      IncidentTrackerConfig
+	next     SourceStatusPatcher
+	repo     SourceRepo
+	alerters map[string]Alerter
+
+	lock sync.Mutex
+	// open incidents by source
+	open map[string]map[domain.IncidentCondition]bool
+	// time the syncs of a source started failing
+	failingSince map[string]time.Time
+}
+
+func CreateIncidentTracker(ctx context.Context,
+	logger log.Logger,
+	cfg IncidentTrackerConfig,
+	next SourceStatusPatcher,
+	repo SourceRepo,
+	alerters map[string]Alerter) (*IncidentTracker, error) {
+	if len(cfg.Conditions) == 0 {
+		cfg.Conditions = []domain.IncidentCondition{
+			domain.IncidentConditionSyncFailing,
+			domain.IncidentConditionDeploymentFailed,
+			domain.IncidentConditionDrift,
+		}
+	}
+	for _, c := range cfg.Conditions {
+		switch c {
+		case domain.IncidentConditionSyncFailing, domain.IncidentConditionDeploymentFailed, domain.IncidentConditionDrift:
+		default:
+			return nil, fmt.Errorf("unknown incident condition %s", c)
+		}
+	}
+	t := &IncidentTracker{
+		ctx:          ctx,
+		logger:       logger,
+		cfg:          cfg,
+		next:         next,
+		repo:         repo,
+		alerters:     alerters,
+		open:         map[string]map[domain.IncidentCondition]bool{},
+		failingSince: map[string]time.Time{},
+	}
+
+	srcs, err := repo.ListSources(ctx, ListSourcesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, src := range srcs {
+		if src.Status == nil {
+			continue
+		}
+		for _, c := range src.Status.Incidents {
+			t.setOpen(src.ID, c, true)
+		}
+	}
+
+	metrics.GetOrCreateGauge(fmt.Sprintf(`nomad_ops_open_incidents{app="%s"}`, cfg.AppName), func() float64 {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		n := 0
+		for _, conds := range t.open {
+			n += len(conds)
+		}
+		return float64(n)
+	})
+
+	return t, nil
+}
+
+// SetSourceStatus stores the status, after opening or resolving the incidents of the source
+func (t *IncidentTracker) SetSourceStatus(srcID string, s *domain.SourceStatus) error {
+	t.observe(t.ctx, srcID, s, time.Now())
+	return t.next.SetSourceStatus(srcID, s)
+}
+
+// ResolveSource resolves the open incidents of a deleted source
+func (t *IncidentTracker) ResolveSource(ctx context.Context, srcID string) {
+	t.lock.Lock()
+	conds := t.conditions(srcID)
+	delete(t.failingSince, srcID)
+	t.lock.Unlock()
+	for _, c := range conds {
+		inc := &Incident{SourceID: srcID, Condition: c}
+		if t.alert(ctx, inc, false) {
+			t.setOpen(srcID, c, false)
+		}
+	}
+}
+
+// observe opens incidents for the conditions the status shows and resolves the ones it does not show anymore.
+// The open incidents are set in the status.
+func (t *IncidentTracker) observe(ctx context.Context, srcID string, s *domain.SourceStatus, now time.Time) {
+	if s == nil {
+		return
+	}
+	t.lock.Lock()
+	open := t.open[srcID]
+	active := map[domain.IncidentCondition]bool{}
+	switch s.Status {
+	case domain.SourceStatusStatusInit, domain.SourceStatusStatusSyncing, domain.SourceStatusStatusUnknown:
+		// the sync did not finish yet, the incidents stay as they are
+		for c := range open {
+			active[c] = true
+		}
+	case domain.SourceStatusStatusError:
+		since, ok := t.failingSince[srcID]
+		if !ok {
+			since = now
+			t.failingSince[srcID] = now
+		}
+		if now.Sub(since) >= t.cfg.SyncFailingFor {
+			active[domain.IncidentConditionSyncFailing] = true
+		}
+		// a failed sync tells nothing about the deployments and the drift
+		active[domain.IncidentConditionDeploymentFailed] = open[domain.IncidentConditionDeploymentFailed]
+		active[domain.IncidentConditionDrift] = open[domain.IncidentConditionDrift]
+	default:
+		delete(t.failingSince, srcID)
+		for _, js := range s.Jobs {
+			if js.DeploymentStatus == "failed" {
+				active[domain.IncidentConditionDeploymentFailed] = true
+			}
+		}
+		if s.Status == domain.SourceStatusStatusOutOfSync {
+			active[domain.IncidentConditionDrift] = true
+		}
+	}
+
+	var trigger, resolve []domain.IncidentCondition
+	enabled := map[domain.IncidentCondition]bool{}
+	for _, c := range t.cfg.Conditions {
+		enabled[c] = true
+		if active[c] && !open[c] {
+			trigger = append(trigger, c)
+		}
+	}
+	// incidents of conditions that got disabled are resolved as well
+	for _, c := range t.conditions(srcID) {
+		if !active[c] || !enabled[c] {
+			resolve = append(resolve, c)
+		}
+	}
+	t.lock.Unlock()
+
+	name := ""
+	if len(trigger) > 0 {
+		name = t.sourceName(ctx, srcID)
+	}
+	for _, c := range trigger {
+		inc := &Incident{
+			SourceID:   srcID,
+			SourceName: name,
+			Condition:  c,
+			Summary:    incidentSummary(name, c, s),
+			Details: map[string]string{
+				"source":    srcID,
+				"status":    s.Status,
+				"message":   s.Message,
+				"errorCode": string(s.ErrorCode),
+			},
+		}
+		if t.alert(ctx, inc, true) {
+			t.setOpen(srcID, c, true)
+		}
+	}
+	for _, c := range resolve {
+		if t.alert(ctx, &Incident{SourceID: srcID, Condition: c}, false) {
+			t.setOpen(srcID, c, false)
+		}
+	}
+
+	t.lock.Lock()
+	s.Incidents = t.conditions(srcID)
+	t.lock.Unlock()
+}
+
+// alert triggers or resolves the incident with all alerters, returns false if any of them failed,
+// it is sent again with the next status then
+func (t *IncidentTracker) alert(ctx context.Context, inc *Incident, trigger bool) bool {
+	names := make([]string, 0, len(t.alerters))
+	for name := range t.alerters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ok := true
+	for _, name := range names {
+		var err error
+		if trigger {
+			err = t.alerters[name].Trigger(ctx, inc)
+		} else {
+			err = t.alerters[name].Resolve(ctx, inc)
+		}
+		if err != nil {
+			t.logger.LogError(ctx, "Could not send incident %s to %s:%v", inc.Key(), name, err)
+			ok = false
+			continue
+		}
+		if trigger {
+			t.logger.LogInfo(ctx, "Triggered incident %s in %s", inc.Key(), name)
+		} else {
+			t.logger.LogInfo(ctx, "Resolved incident %s in %s", inc.Key(), name)
+		}
+	}
+	return ok
+}
+
+func (t *IncidentTracker) setOpen(srcID string, c domain.IncidentCondition, open bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !open {
+		delete(t.open[srcID], c)
+		if len(t.open[srcID]) == 0 {
+			delete(t.open, srcID)
+		}
+		return
+	}
+	if t.open[srcID] == nil {
+		t.open[srcID] = map[domain.IncidentCondition]bool{}
+	}
+	t.open[srcID][c] = true
+}
+
+// conditions returns the sorted conditions with an open incident of the source, the lock must be held
+func (t *IncidentTracker) conditions(srcID string) []domain.IncidentCondition {
+	var res []domain.IncidentCondition
+	for c := range t.open[srcID] {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(a, b int) bool {
+		return res[a] < res[b]
+	})
+	return res
+}
+
+// sourceName returns the name of the source, the id if it cannot be found
+func (t *IncidentTracker) sourceName(ctx context.Context, srcID string) string {
+	srcs, err := t.repo.ListSources(ctx, ListSourcesOptions{})
+	if err != nil {
+		t.logger.LogError(ctx, "Could not get the name of source %s:%v", srcID, err)
+		return srcID
+	}
+	for _, src := range srcs {
+		if src.ID == srcID {
+			return src.Name
+		}
+	}
+	return srcID
+}
+
+func incidentSummary(name string, c domain.IncidentCondition, s *domain.SourceStatus) string {
+	switch c {
+	case domain.IncidentConditionSyncFailing:
+		return fmt.Sprintf("Sync of source %s is failing: %s", name, s.Message)
+	case domain.IncidentConditionDeploymentFailed:
+		return fmt.Sprintf("Deployment of source %s failed: %s", name, s.Message)
+	default:
+		return fmt.Sprintf("Source %s drifted from the repository: %s", name, s.Message)
+	}
+}
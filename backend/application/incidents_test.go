@@ -0,0 +1,149 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeAlerter struct {
+	fail bool
+	sent []string
+}
+
+func (a *fakeAlerter) Trigger(ctx context.Context, inc *Incident) error {
+	if a.fail {
+		return errors.New("unavailable")
+	}
+	a.sent = append(a.sent, "trigger "+inc.Key())
+	return nil
+}
+
+func (a *fakeAlerter) Resolve(ctx context.Context, inc *Incident) error {
+	if a.fail {
+		return errors.New("unavailable")
+	}
+	a.sent = append(a.sent, "resolve "+inc.Key())
+	return nil
+}
+
+func (a *fakeAlerter) take() []string {
+	sent := a.sent
+	a.sent = nil
+	return sent
+}
+
+type statusStore struct {
+	sources []*domain.Source
+}
+
+func (s *statusStore) ListSources(ctx context.Context, opts ListSourcesOptions) ([]*domain.Source, error) {
+	return s.sources, nil
+}
+
+func (s *statusStore) SetSourceStatus(srcID string, status *domain.SourceStatus) error {
+	for _, src := range s.sources {
+		if src.ID == srcID {
+			src.Status = status
+		}
+	}
+	return nil
+}
+
+func TestIncidentTracker(t *testing.T) {
+	ctx := context.Background()
+	store := &statusStore{sources: []*domain.Source{{ID: "src", Name: "web"}}}
+	alerter := &fakeAlerter{}
+	tracker, err := CreateIncidentTracker(ctx, log.NewSimpleLogger(false, "Test"), IncidentTrackerConfig{
+		SyncFailingFor: 10 * time.Minute,
+	}, store, store, map[string]Alerter{"pagerduty": alerter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	observe := func(status string, after time.Duration) *domain.SourceStatus {
+		now = now.Add(after)
+		s := &domain.SourceStatus{Status: status, Message: "no such branch", Jobs: map[string]domain.JobStatus{}}
+		tracker.observe(ctx, "src", s, now)
+		return s
+	}
+
+	// failing syncs open an incident after 10 minutes, retries in between do not reset the time
+	observe(domain.SourceStatusStatusError, 0)
+	observe(domain.SourceStatusStatusSyncing, 5*time.Minute)
+	if sent := alerter.take(); len(sent) != 0 {
+		t.Errorf("Expected no incident within 10 minutes, got %v", sent)
+	}
+	s := observe(domain.SourceStatusStatusError, 5*time.Minute)
+	if sent := alerter.take(); !reflect.DeepEqual(sent, []string{"trigger nomad-ops/src/syncFailing"}) {
+		t.Errorf("Expected an incident for the failing sync, got %v", sent)
+	}
+	if !reflect.DeepEqual(s.Incidents, []domain.IncidentCondition{domain.IncidentConditionSyncFailing}) {
+		t.Errorf("Expected the open incident in the status, got %v", s.Incidents)
+	}
+	s = observe(domain.SourceStatusStatusSyncing, time.Minute)
+	if sent := alerter.take(); len(sent) != 0 || len(s.Incidents) != 1 {
+		t.Errorf("Expected the incident to stay open while syncing, got %v - %v", sent, s.Incidents)
+	}
+
+	// the sync recovers, but a deployment failed
+	s = &domain.SourceStatus{
+		Status: domain.SourceStatusStatusSyncedWithError,
+		Jobs:   map[string]domain.JobStatus{"web": {DeploymentStatus: "failed"}},
+	}
+	tracker.observe(ctx, "src", s, now)
+	expected := []string{"trigger nomad-ops/src/deploymentFailed", "resolve nomad-ops/src/syncFailing"}
+	if sent := alerter.take(); !reflect.DeepEqual(sent, expected) {
+		t.Errorf("Expected %v, got %v", expected, sent)
+	}
+
+	// resolving fails, it is retried with the next status
+	alerter.fail = true
+	observe(domain.SourceStatusStatusOutOfSync, time.Minute)
+	alerter.fail = false
+	s = observe(domain.SourceStatusStatusOutOfSync, time.Minute)
+	expected = []string{"trigger nomad-ops/src/drift", "resolve nomad-ops/src/deploymentFailed"}
+	if sent := alerter.take(); !reflect.DeepEqual(sent, expected) {
+		t.Errorf("Expected %v, got %v", expected, sent)
+	}
+	if fmt.Sprint(s.Incidents) != "[drift]" {
+		t.Errorf("Expected the drift incident in the status, got %v", s.Incidents)
+	}
+
+	// a restarted tracker resolves the incidents stored in the status
+	store.sources[0].Status = s
+	restarted, err := CreateIncidentTracker(ctx, log.NewSimpleLogger(false, "Test"), IncidentTrackerConfig{},
+		store, store, map[string]Alerter{"pagerduty": alerter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = restarted.SetSourceStatus("src", &domain.SourceStatus{Status: domain.SourceStatusStatusSynced})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := alerter.take(); !reflect.DeepEqual(sent, []string{"resolve nomad-ops/src/drift"}) {
+		t.Errorf("Expected the drift to be resolved, got %v", sent)
+	}
+	if len(store.sources[0].Status.Incidents) != 0 {
+		t.Errorf("Expected no open incident, got %v", store.sources[0].Status.Incidents)
+	}
+
+	// incidents of deleted sources are resolved
+	observe(domain.SourceStatusStatusOutOfSync, time.Minute)
+	tracker.ResolveSource(ctx, "src")
+	if sent := alerter.take(); !reflect.DeepEqual(sent, []string{"resolve nomad-ops/src/drift"}) {
+		t.Errorf("Expected the incident of the deleted source to be resolved, got %v", sent)
+	}
+
+	if _, err := CreateIncidentTracker(ctx, log.NewSimpleLogger(false, "Test"), IncidentTrackerConfig{
+		Conditions: []domain.IncidentCondition{"unknown"},
+	}, store, store, nil); err == nil {
+		t.Errorf("Expected an error for an unknown condition")
+	}
+}
@@ -0,0 +1,59 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// JobLimits protect nomad-ops and the Nomad servers from generators producing huge or countless jobs,
+// a zero value disables the limit
+type JobLimits struct {
+	// MaxJobsPerSource is the maximum number of jobs of a source
+	MaxJobsPerSource int
+	// MaxJobSize is the maximum size in bytes of a parsed or rendered job in the JSON format of the Nomad API
+	MaxJobSize int
+	// MaxTaskGroups is the maximum number of task groups of a job
+	MaxTaskGroups int
+}
+
+// Check returns an error with the code LIMIT_EXCEEDED naming every exceeded limit of the desired state
+func (l JobLimits) Check(desiredState *DesiredState) error {
+	if l.MaxJobsPerSource > 0 && len(desiredState.Jobs) > l.MaxJobsPerSource {
+		return domain.WithErrorCode(domain.ErrorCodeLimitExceeded,
+			fmt.Errorf("source has %d jobs, the maximum is %d", len(desiredState.Jobs), l.MaxJobsPerSource))
+	}
+	if l.MaxJobSize <= 0 && l.MaxTaskGroups <= 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(desiredState.Jobs))
+	for name := range desiredState.Jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		job := desiredState.Jobs[name]
+		if l.MaxTaskGroups > 0 && len(job.TaskGroups) > l.MaxTaskGroups {
+			errs = append(errs, fmt.Errorf("job %s has %d task groups, the maximum is %d",
+				name, len(job.TaskGroups), l.MaxTaskGroups))
+		}
+		if l.MaxJobSize > 0 {
+			data, err := json.Marshal(job.Job)
+			if err != nil {
+				return err
+			}
+			if len(data) > l.MaxJobSize {
+				errs = append(errs, fmt.Errorf("job %s has %d bytes, the maximum is %d", name, len(data), l.MaxJobSize))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return domain.WithErrorCode(domain.ErrorCodeLimitExceeded, errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package application
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestJobLimits(t *testing.T) {
+	desiredState := &DesiredState{Jobs: map[string]*JobInfo{}}
+	for _, name := range []string{"web", "api"} {
+		job := api.NewServiceJob(name, name, "global", 50)
+		job.AddTaskGroup(api.NewTaskGroup("a", 1))
+		desiredState.Jobs[name] = &JobInfo{Job: job}
+	}
+	desiredState.Jobs["web"].AddTaskGroup(api.NewTaskGroup("b", 1))
+
+	if err := (JobLimits{}).Check(desiredState); err != nil {
+		t.Errorf("Expected no limits, got %v", err)
+	}
+	if err := (JobLimits{MaxJobsPerSource: 2, MaxTaskGroups: 2, MaxJobSize: 1 << 20}).Check(desiredState); err != nil {
+		t.Errorf("Expected the jobs to be within the limits, got %v", err)
+	}
+
+	err := (JobLimits{MaxJobsPerSource: 1}).Check(desiredState)
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeLimitExceeded || err.Error() != "source has 2 jobs, the maximum is 1" {
+		t.Errorf("Expected too many jobs, got %v", err)
+	}
+	err = (JobLimits{MaxTaskGroups: 1, MaxJobSize: 10}).Check(desiredState)
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeLimitExceeded ||
+		!strings.HasPrefix(err.Error(), "job api has ") ||
+		!strings.Contains(err.Error(), "job web has 2 task groups, the maximum is 1") {
+		t.Errorf("Expected every exceeded limit, got %v", err)
+	}
+
+	// nothing is planned if the limits are exceeded
+	cluster := &flakyCluster{registered: map[string]bool{}}
+	r := &ReconciliationManager{
+		logger:        log.NewSimpleLogger(false, "Test"),
+		cfg:           ReconciliationManagerConfig{Limits: JobLimits{MaxTaskGroups: 1}},
+		clusterAccess: cluster,
+		evRepo:        discardEvents{},
+		notifier:      &recordingNotifier{},
+	}
+	if _, err := r.OnReconcile(context.Background(), &domain.Source{ID: "src"}, desiredState, false); err == nil {
+		t.Fatalf("Expected the limits to be exceeded")
+	}
+	if u := cluster.takeUpdates(); len(u) != 0 {
+		t.Errorf("Expected no job to be planned, got %v", u)
+	}
+}
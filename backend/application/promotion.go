@@ -0,0 +1,231 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PromotionRepo interface {
+	ListSources(ctx context.Context, opts ListSourcesOptions) ([]*domain.Source, error)
+	// SetRevision pins the source to the revision and clears its pending promotion
+	SetRevision(ctx context.Context, id, revision string) error
+	SetPendingPromotion(ctx context.Context, id, commit string) error
+}
+
+// SourceUpdater restarts the watch of a source with its new settings
+type SourceUpdater interface {
+	OnUpdatedSource(ctx context.Context, src *domain.Source) error
+}
+
+// PipelineStage is a source of a promotion pipeline
+type PipelineStage struct {
+	SourceID          string `json:"sourceId"`
+	Name              string `json:"name"`
+	Branch            string `json:"branch"`
+	Revision          string `json:"revision,omitempty"`
+	AppliedCommit     string `json:"appliedCommit,omitempty"`
+	Status            string `json:"status,omitempty"`
+	PromotionApproval bool   `json:"promotionApproval,omitempty"`
+	PendingPromotion  string `json:"pendingPromotion,omitempty"`
+}
+
+// Promoter promotes the commit of a healthy sync of a source to the next source of its pipeline,
+// e.g. dev -> staging -> prod. Stages requiring an approval keep the commit as pending promotion.
+type Promoter struct {
+	ctx      context.Context
+	logger   log.Logger
+	repo     PromotionRepo
+	updater  SourceUpdater
+	notifier Notifier
+}
+
+func CreatePromoter(ctx context.Context,
+	logger log.Logger,
+	repo PromotionRepo,
+	updater SourceUpdater,
+	notifier Notifier) (*Promoter, error) {
+	t := &Promoter{
+		ctx:      ctx,
+		logger:   logger,
+		repo:     repo,
+		updater:  updater,
+		notifier: notifier,
+	}
+
+	return t, nil
+}
+
+// OnSynced promotes the applied commit of the source in the background, if the source is in sync
+// and has no running deployments
+func (p *Promoter) OnSynced(ctx context.Context, src *domain.Source) {
+	if src.PromoteTo == "" || src.Paused || src.Status == nil || src.Status.Status != domain.SourceStatusStatusSynced {
+		return
+	}
+	for _, job := range src.Status.Jobs {
+		if job.DeploymentStatus == "running" || job.DeploymentStatus == "failed" {
+			return
+		}
+	}
+	commit := src.Status.AppliedCommit()
+	if commit == "" {
+		return
+	}
+	// updating the next source waits for its watch, which may be syncing as well.
+	// The promotion outlives the watch of this source, which is restarted by updates.
+	go p.promoteCommit(p.ctx, src.Name, src.PromoteTo, commit)
+}
+
+func (p *Promoter) promoteCommit(ctx context.Context, from, to, commit string) {
+	next, err := p.getSource(ctx, to)
+	if err != nil {
+		p.logger.LogError(ctx, "Could not get source %s to promote %s to:%v", to, commit, err)
+		return
+	}
+	if next.Revision == commit || next.PendingPromotion == commit {
+		return
+	}
+	if next.PromotionApproval {
+		p.logger.LogInfo(ctx, "Promotion of %s from %s to %s waits for an approval", commit, from, next.Name)
+		err := p.repo.SetPendingPromotion(ctx, next.ID, commit)
+		if err != nil {
+			p.logger.LogError(ctx, "Could not SetPendingPromotion of %s:%v", next.ID, err)
+			return
+		}
+		p.notify(ctx, next, NotificationInfo, fmt.Sprintf("Commit %s of %s waits for an approval of its promotion", commit, from))
+		return
+	}
+	err = p.promote(ctx, next, commit)
+	if err != nil {
+		p.logger.LogError(ctx, "Could not promote %s to %s:%v", commit, next.ID, err)
+		p.notify(ctx, next, NotificationError, fmt.Sprintf("Could not promote commit %s of %s: %v", commit, from, err))
+		return
+	}
+	p.notify(ctx, next, NotificationSuccess, fmt.Sprintf("Promoted commit %s of %s", commit, from))
+}
+
+// Approve promotes the pending promotion of the source
+func (p *Promoter) Approve(ctx context.Context, id string) (*domain.Source, error) {
+	src, err := p.getSource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if src.PendingPromotion == "" {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("source %s has no pending promotion", id))
+	}
+	commit := src.PendingPromotion
+	err = p.promote(ctx, src, commit)
+	if err != nil {
+		return nil, err
+	}
+	p.notify(ctx, src, NotificationSuccess, fmt.Sprintf("Approved the promotion of commit %s", commit))
+	return src, nil
+}
+
+// Reject discards the pending promotion of the source
+func (p *Promoter) Reject(ctx context.Context, id string) error {
+	src, err := p.getSource(ctx, id)
+	if err != nil {
+		return err
+	}
+	if src.PendingPromotion == "" {
+		return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("source %s has no pending promotion", id))
+	}
+	return p.repo.SetPendingPromotion(ctx, id, "")
+}
+
+// Pipeline returns the stages of the promotion pipeline of the source, starting with the first one
+func (p *Promoter) Pipeline(ctx context.Context, id string) ([]PipelineStage, error) {
+	srcs, err := p.repo.ListSources(ctx, ListSourcesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]*domain.Source{}
+	prev := map[string]*domain.Source{}
+	for _, src := range srcs {
+		byID[src.ID] = src
+	}
+	for _, src := range srcs {
+		if src.PromoteTo != "" && byID[src.PromoteTo] != nil {
+			prev[src.PromoteTo] = src
+		}
+	}
+	first, ok := byID[id]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	// stages may form a cycle
+	visited := map[string]bool{first.ID: true}
+	for prev[first.ID] != nil && !visited[prev[first.ID].ID] {
+		first = prev[first.ID]
+		visited[first.ID] = true
+	}
+
+	res := []PipelineStage{}
+	visited = map[string]bool{}
+	for src := first; src != nil && !visited[src.ID]; src = byID[src.PromoteTo] {
+		visited[src.ID] = true
+		stage := PipelineStage{
+			SourceID:          src.ID,
+			Name:              src.Name,
+			Branch:            src.Branch,
+			Revision:          src.Revision,
+			PromotionApproval: src.PromotionApproval,
+			PendingPromotion:  src.PendingPromotion,
+		}
+		if src.Status != nil {
+			stage.AppliedCommit = src.Status.AppliedCommit()
+			stage.Status = src.Status.Status
+		}
+		res = append(res, stage)
+	}
+	return res, nil
+}
+
+func (p *Promoter) promote(ctx context.Context, src *domain.Source, commit string) error {
+	p.logger.LogInfo(ctx, "Promoting %s to %s (%s)", commit, src.Name, src.ID)
+	err := p.repo.SetRevision(ctx, src.ID, commit)
+	if err != nil {
+		return err
+	}
+	src.Revision = commit
+	src.PendingPromotion = ""
+	return p.updater.OnUpdatedSource(ctx, src)
+}
+
+func (p *Promoter) getSource(ctx context.Context, id string) (*domain.Source, error) {
+	srcs, err := p.repo.ListSources(ctx, ListSourcesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, src := range srcs {
+		if src.ID == id {
+			return src, nil
+		}
+	}
+	return nil, errors.ErrNotFound
+}
+
+func (p *Promoter) notify(ctx context.Context, src *domain.Source, t NotificationType, msg string) {
+	err := p.notifier.Notify(ctx, NotifyOptions{
+		Source:  src,
+		Type:    t,
+		Message: msg,
+		Infos: []NotifyAdditionalInfos{
+			{
+				Header: "Git-Url",
+				Text:   src.URL,
+			},
+			{
+				Header: "Git-Rev",
+				Text:   src.Revision,
+			},
+		},
+	})
+	if err != nil {
+		p.logger.LogError(ctx, "Could not notify:%v", err)
+	}
+}
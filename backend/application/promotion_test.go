@@ -0,0 +1,128 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakePromotionRepo struct {
+	srcs []*domain.Source
+}
+
+func (r *fakePromotionRepo) ListSources(ctx context.Context, opts ListSourcesOptions) ([]*domain.Source, error) {
+	res := []*domain.Source{}
+	for _, src := range r.srcs {
+		cpy := *src
+		res = append(res, &cpy)
+	}
+	return res, nil
+}
+
+func (r *fakePromotionRepo) find(id string) *domain.Source {
+	for _, src := range r.srcs {
+		if src.ID == id {
+			return src
+		}
+	}
+	return nil
+}
+
+func (r *fakePromotionRepo) SetRevision(ctx context.Context, id, revision string) error {
+	r.find(id).Revision = revision
+	r.find(id).PendingPromotion = ""
+	return nil
+}
+
+func (r *fakePromotionRepo) SetPendingPromotion(ctx context.Context, id, commit string) error {
+	r.find(id).PendingPromotion = commit
+	return nil
+}
+
+type recordingUpdater struct {
+	updated chan *domain.Source
+}
+
+func (u *recordingUpdater) OnUpdatedSource(ctx context.Context, src *domain.Source) error {
+	u.updated <- src
+	return nil
+}
+
+func syncedAt(commit, deployment string) *domain.SourceStatus {
+	return &domain.SourceStatus{
+		Status: domain.SourceStatusStatusSynced,
+		Jobs: map[string]domain.JobStatus{
+			"web": {LastAppliedCommit: commit, DeploymentStatus: deployment},
+		},
+	}
+}
+
+func TestPromoter(t *testing.T) {
+	repo := &fakePromotionRepo{srcs: []*domain.Source{
+		{ID: "prod", Name: "prod", PromotionApproval: true},
+		{ID: "dev", Name: "dev", PromoteTo: "staging", Status: syncedAt("c1", "")},
+		{ID: "staging", Name: "staging", PromoteTo: "prod"},
+	}}
+	u := &recordingUpdater{updated: make(chan *domain.Source, 1)}
+	p, err := CreatePromoter(context.Background(), log.NewSimpleLogger(false, "Test"), repo, u, &recordingNotifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.OnSynced(context.Background(), repo.find("dev"))
+	select {
+	case src := <-u.updated:
+		if src.ID != "staging" || src.Revision != "c1" || repo.find("staging").Revision != "c1" {
+			t.Errorf("Expected staging to be pinned to c1, got %v", src)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the commit to be promoted to staging")
+	}
+
+	// the deployment of staging is still running
+	repo.find("staging").Status = syncedAt("c1", "running")
+	p.OnSynced(context.Background(), repo.find("staging"))
+	select {
+	case src := <-u.updated:
+		t.Fatalf("Expected no promotion of a running deployment, got %v", src)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// prod requires an approval
+	repo.find("staging").Status = syncedAt("c1", "successful")
+	p.promoteCommit(context.Background(), "staging", "prod", "c1")
+	if repo.find("prod").PendingPromotion != "c1" || repo.find("prod").Revision != "" {
+		t.Errorf("Expected a pending promotion of c1 to prod, got %v", repo.find("prod"))
+	}
+	if _, err := p.Approve(context.Background(), "prod"); err != nil {
+		t.Fatal(err)
+	}
+	if src := <-u.updated; src.ID != "prod" || repo.find("prod").Revision != "c1" || repo.find("prod").PendingPromotion != "" {
+		t.Errorf("Expected prod to be pinned to c1 after the approval, got %v", repo.find("prod"))
+	}
+	if _, err := p.Approve(context.Background(), "prod"); domain.ErrorCodeOf(err, "") != domain.ErrorCodeInvalidRequest {
+		t.Errorf("Expected no pending promotion, got %v", err)
+	}
+
+	stages, err := p.Pipeline(context.Background(), "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(stages) != "[{dev dev   c1 synced false } {staging staging  c1 c1 synced false } {prod prod  c1   true }]" {
+		t.Errorf("Unexpected stages %v", stages)
+	}
+
+	// cycles end the pipeline
+	repo.find("prod").PromoteTo = "dev"
+	stages, err = p.Pipeline(context.Background(), "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stages) != 3 || stages[0].SourceID != "prod" {
+		t.Errorf("Unexpected stages of a cycle %v", stages)
+	}
+}
@@ -2,6 +2,9 @@ package application
 
 import (
 	"context"
+	"reflect"
+	"sync"
+	"time"
 
 	"github.com/nomad-ops/nomad-ops/backend/domain"
 	"github.com/nomad-ops/nomad-ops/backend/utils/log"
@@ -18,6 +21,8 @@ type NotificationType string
 var (
 	NotificationSuccess NotificationType = "success"
 	NotificationError   NotificationType = "error"
+	// NotificationInfo is used for expected state changes that require no action, e.g. a paused source falling behind git
+	NotificationInfo NotificationType = "info"
 )
 
 type NotifyOptions struct {
@@ -50,12 +55,24 @@ type VaultTokenRepo interface {
 	GetVaultToken(ctx context.Context, id string) (*domain.VaultToken, error)
 }
 
+type NotificationTargetRepo interface {
+	GetNotificationTarget(ctx context.Context, name string) (*domain.NotificationTarget, error)
+	SetNotificationDelivery(ctx context.Context, name string, d domain.NotificationDelivery) error
+}
+
 type EventRepo interface {
 	SaveEvent(ctx context.Context, ev *domain.Event) error
 }
 
+// Shard decides which sources are reconciled by this instance
+type Shard interface {
+	Owns(srcID string) bool
+}
+
 type SourceWatcher interface {
 	WatchSource(ctx context.Context, src *domain.Source, cb ReconcilerFunc) error
+	UpdateSource(ctx context.Context, src *domain.Source) error
+	SyncSourceByID(ctx context.Context, id string, opts SyncSourceOptions) error
 	StopSourceWatch(ctx context.Context, id string) error
 }
 
@@ -68,9 +85,32 @@ type ReconciliationManager struct {
 	clusterAccess ClusterAPI
 	evRepo        EventRepo
 	notifier      Notifier
+
+	lock sync.Mutex
+	// settings of the watched sources
+	watched map[string]*domain.Source
+
+	startup *startupReport
 }
 
 type ReconciliationManagerConfig struct {
+	// JobParallelism limits the number of jobs of a source that are registered or deleted at once
+	JobParallelism int
+	// JobRetryBackoff is the time a job that failed to apply is not retried, unless the commit changes.
+	// It doubles with every consecutive failure up to JobRetryMaxBackoff.
+	JobRetryBackoff    time.Duration
+	JobRetryMaxBackoff time.Duration
+	// Shard limits the sources to the ones of this instance, e.g. none on a standby, nil reconciles all sources
+	Shard Shard
+	// StartupReportTimeout is the time the resync of all sources on startup is awaited for the startup report,
+	// sources that did not sync by then are reported as pending. 0 disables the resync and the report.
+	StartupReportTimeout time.Duration
+	// StartupReportNotify sends the startup report as a notification
+	StartupReportNotify bool
+	// Limits are checked before the jobs of a source are planned
+	Limits JobLimits
+	// Annotations adds the build of the applied commit to notifications, optional
+	Annotations CommitAnnotationRepo
 }
 
 func CreateReconciliationManager(ctx context.Context,
@@ -90,30 +130,105 @@ func CreateReconciliationManager(ctx context.Context,
 		clusterAccess: clusterAccess,
 		evRepo:        evRepo,
 		notifier:      notifier,
+		watched:       map[string]*domain.Source{},
 	}
 
 	// Get all sources from repo on startup
-	srcs, err := repo.ListSources(ctx, ListSourcesOptions{})
+	err := t.Rebalance(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	for _, src := range srcs {
-		cpy := src
-		err = watcher.WatchSource(ctx, cpy, t.OnReconcile)
-		if err != nil {
-			return nil, err
-		}
+	if cfg.StartupReportTimeout > 0 {
+		t.startStartupReport(ctx)
 	}
 
 	return t, nil
 }
 
+func (m *ReconciliationManager) owns(srcID string) bool {
+	return m.cfg.Shard == nil || m.cfg.Shard.Owns(srcID)
+}
+
 func (m *ReconciliationManager) OnAddedSource(ctx context.Context, src *domain.Source) error {
-	err := m.watcher.WatchSource(ctx, src, m.OnReconcile)
+	if !m.owns(src.ID) {
+		return nil
+	}
+	err := m.watcher.WatchSource(ctx, src, m.reconcile)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	m.watched[src.ID] = sourceSettings(src)
+	m.lock.Unlock()
+	return nil
+}
+
+// OnUpdatedSource passes the new settings to the watch, sources this instance does not own are ignored
+func (m *ReconciliationManager) OnUpdatedSource(ctx context.Context, src *domain.Source) error {
+	if !m.owns(src.ID) {
+		return nil
+	}
+	err := m.watcher.UpdateSource(ctx, src)
 	if err != nil {
 		return err
 	}
+	m.lock.Lock()
+	m.watched[src.ID] = sourceSettings(src)
+	m.lock.Unlock()
+	return nil
+}
+
+// sourceSettings returns a copy of the source without the fields that change on every sync
+func sourceSettings(src *domain.Source) *domain.Source {
+	cpy := *src
+	cpy.Status = nil
+	cpy.ExpiryWarnedAt = nil
+	return &cpy
+}
+
+// Rebalance watches the sources owned by this instance and stops watching the others, e.g. after a standby got promoted
+func (m *ReconciliationManager) Rebalance(ctx context.Context) error {
+	srcs, err := m.repo.ListSources(ctx, ListSourcesOptions{})
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	found := map[string]bool{}
+	for _, src := range srcs {
+		found[src.ID] = true
+		if !m.owns(src.ID) {
+			if m.watched[src.ID] != nil {
+				m.logger.LogInfo(ctx, "Source %s is no longer owned by this instance", src.ID)
+			}
+			continue
+		}
+		settings := sourceSettings(src)
+		prev := m.watched[src.ID]
+		switch {
+		case prev == nil:
+			cpy := src
+			err = m.watcher.WatchSource(ctx, cpy, m.reconcile)
+		case !reflect.DeepEqual(prev, settings):
+			// updated in the store, e.g. by the replication
+			err = m.watcher.UpdateSource(ctx, src)
+		}
+		if err != nil {
+			return err
+		}
+		m.watched[src.ID] = settings
+	}
+	for id := range m.watched {
+		if found[id] && m.owns(id) {
+			continue
+		}
+		err = m.watcher.StopSourceWatch(ctx, id)
+		if err != nil {
+			return err
+		}
+		delete(m.watched, id)
+	}
 	return nil
 }
 
@@ -126,5 +241,8 @@ func (m *ReconciliationManager) OnDeletedSource(ctx context.Context, id string)
 	if err != nil {
 		return err
 	}
+	m.lock.Lock()
+	delete(m.watched, id)
+	m.lock.Unlock()
 	return nil
 }
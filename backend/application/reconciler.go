@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,10 +23,14 @@ var (
 
 type ClusterState struct {
 	CurrentJobs map[string]*JobInfo
+	// Namespaces are the jobs by namespace and name, see NewClusterState
+	Namespaces map[string]map[string]*JobInfo
 }
 type DesiredState struct {
 	GitInfo GitInfo
 	Jobs    map[string]*JobInfo
+	// Bundles are the resolved bundles whose jobs are part of Jobs
+	Bundles []domain.LockedBundle
 }
 
 type GitInfo struct {
@@ -32,11 +39,20 @@ type GitInfo struct {
 
 type JobInfo struct {
 	GitInfo GitInfo
+	// Warnings of parsing the job file
+	Warnings []string
 	*api.Job
 }
 
+type ParseJobOptions struct {
+	// Strict rejects job files that produce parser warnings
+	Strict bool
+	// HCL1Fallback parses job files that are not valid HCL2 with the deprecated HCL1 parser
+	HCL1Fallback bool
+}
+
 type JobParser interface {
-	ParseJob(ctx context.Context, j string) (*JobInfo, error)
+	ParseJob(ctx context.Context, j string, opts ParseJobOptions) (*JobInfo, error)
 }
 
 type GetCurrentClusterStateOptions struct {
@@ -44,20 +60,35 @@ type GetCurrentClusterStateOptions struct {
 }
 
 type UpdateJobInfo struct {
-	Updated          bool
-	Created          bool
-	Diff             json.RawMessage
+	Updated bool
+	Created bool
+	Diff    json.RawMessage
+	// DiffSummary is a human readable version of Diff
+	DiffSummary      string
 	DeploymentStatus DeploymentStatus
+	// Warnings of parsing and planning the job
+	Warnings []string
+	// PolicyViolations are the Sentinel policies the plan warned about
+	PolicyViolations []*domain.PolicyViolation
+	// Links are the pages of the job in the Nomad UI
+	Links *domain.NomadLinks
 }
 
 type DeploymentStatus struct {
 	Status string
+	// ID of the latest deployment, empty if the job has none
+	ID string
+	// HealthyAllocs is the number of healthy allocations of the latest deployment over all task groups
+	HealthyAllocs int
 }
 
 type ClusterAPI interface {
 	GetCurrentClusterState(ctx context.Context, opts GetCurrentClusterStateOptions) (*ClusterState, error)
 	UpdateJob(ctx context.Context, src *domain.Source, job *JobInfo, restart bool) (*UpdateJobInfo, error)
 	DeleteJob(ctx context.Context, src *domain.Source, job *JobInfo) error
+	RevertJob(ctx context.Context, src *domain.Source, job *JobInfo, version uint64) error
+	// WaitForJobsStopped waits until the allocations of the deleted jobs stopped, bounded by the cluster access
+	WaitForJobsStopped(ctx context.Context, src *domain.Source, jobs []*JobInfo)
 }
 
 type ChangeInfo struct {
@@ -65,6 +96,35 @@ type ChangeInfo struct {
 	Create map[string]*JobInfo
 	Delete map[string]*JobInfo
 	Update map[string]*JobInfo
+	// Adopted are the updated jobs that existed in the cluster without being managed by the source
+	Adopted map[string]*JobInfo
+	// DiffSummaries holds a human readable summary per changed job
+	DiffSummaries map[string]string
+}
+
+// JobCreatedSummary is the diff summary of a job that is new to the cluster
+func JobCreatedSummary(name string) string {
+	return fmt.Sprintf("Job %s: created", name)
+}
+
+// JobDeletedSummary is the diff summary of a job that is removed from the cluster
+func JobDeletedSummary(name string) string {
+	return fmt.Sprintf("Job %s: deleted", name)
+}
+
+// Summary renders all changes into a single text, one job per paragraph
+func (c *ChangeInfo) Summary() string {
+	var keys []string
+	for k := range c.DiffSummaries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s:\n%s", k, c.DiffSummaries[k]))
+	}
+	return strings.Join(parts, "\n\n")
 }
 
 type ReconcilerFunc func(ctx context.Context,
@@ -77,6 +137,12 @@ func (r *ReconciliationManager) OnReconcile(ctx context.Context,
 	desiredState *DesiredState,
 	restart bool) (*ChangeInfo, error) {
 
+	err := r.cfg.Limits.Check(desiredState)
+	if err != nil {
+		r.logger.LogError(ctx, "Jobs of %s exceed the limits: %v", src.Name, err)
+		return nil, err
+	}
+
 	currentState, err := r.clusterAccess.GetCurrentClusterState(ctx, GetCurrentClusterStateOptions{
 		Source: src,
 	})
@@ -84,87 +150,236 @@ func (r *ReconciliationManager) OnReconcile(ctx context.Context,
 		r.logger.LogError(ctx, "Failed to get current cluster state: %v - %v - %v", err, src.URL, src.Path)
 		return nil, err
 	}
+	currentState.resolveNamespaces(src, desiredState)
 
 	changed := &ChangeInfo{
 		DryRun: src.Paused,
 		Create: map[string]*JobInfo{},
 		Delete: map[string]*JobInfo{},
 		Update: map[string]*JobInfo{},
+
+		Adopted:       map[string]*JobInfo{},
+		DiffSummaries: map[string]string{},
 	}
 
 	if src.Status == nil {
 		src.Status = &domain.SourceStatus{}
 	}
 
+	// a previous sync failed for some jobs, jobs already applied at this commit are not planned again
+	previousJobs := src.Status.Jobs
+	resuming := false
+	for _, js := range previousJobs {
+		if js.Failures > 0 {
+			resuming = true
+		}
+	}
+	commit := desiredState.GitInfo.GitCommit
+	// loaded once the first job is updated
+	var annotation *domain.CommitAnnotation
+	var annotationOnce sync.Once
+
 	src.Status.Jobs = map[string]domain.JobStatus{}
 	src.Status.Status = domain.SourceStatusStatusSynced
 	src.Status.LastCheckTime = toTimePtr(time.Now())
 	src.Status.Message = ""
+	src.Status.ErrorCode = ""
+	src.Status.TimedOutPhase = ""
 
+	// guards changed and src.Status while jobs are processed concurrently
+	var mu sync.Mutex
+	// events and notifications of the changed jobs, they are sent once no job is processed anymore,
+	// as they read the status of the source
+	var events []*domain.Event
+	var notifications []NotifyOptions
+
+	var toDelete []string
 	for k, job := range currentState.CurrentJobs {
-		if _, ok := desiredState.Jobs[k]; !ok {
-			r.logger.LogTrace(ctx, "Checking if job is still required: %v...%+v", strPtrToStr(job.Name), log.ToJSONString(job))
-			cpy := job
+		if _, ok := desiredState.Jobs[k]; ok {
+			continue
+		}
+		r.logger.LogTrace(ctx, "Checking if job is still required: %v...%+v", strPtrToStr(job.Name), log.ToJSONString(job))
 
-			if cpy.Periodic != nil && cpy.Periodic.Enabled != nil && *cpy.Periodic.Enabled {
-				// ignore periodic jobs
-				continue
-			}
-			if cpy.Type != nil && (*cpy.Type == "batch" || *cpy.Type == "sysbatch") {
-				continue
-			}
-			if cpy.ParentID != nil && *cpy.ParentID != "" {
-				// has a parent job, periodic probably
-				continue
-			}
+		if job.Periodic != nil && job.Periodic.Enabled != nil && *job.Periodic.Enabled {
+			// ignore periodic jobs
+			continue
+		}
+		if job.Type != nil && (*job.Type == "batch" || *job.Type == "sysbatch") {
+			continue
+		}
+		if job.ParentID != nil && *job.ParentID != "" {
+			// has a parent job, periodic probably
+			continue
+		}
 
-			changed.Delete[k] = cpy
+		changed.Delete[k] = job
+		changed.DiffSummaries[k] = JobDeletedSummary(k)
 
-			if src.Paused {
-				r.logger.LogInfo(ctx, "Found job %s that is no longer desired. Would be deleted...", k)
-				continue
-			}
+		if src.Paused {
+			r.logger.LogInfo(ctx, "Found job %s that is no longer desired. Would be deleted...", k)
+			continue
+		}
+		toDelete = append(toDelete, k)
+	}
 
-			r.logger.LogInfo(ctx, "Found job %s that is no longer desired. Deleting...", k)
-			err := r.clusterAccess.DeleteJob(ctx, src, job)
+	deleteJob := func(k string) error {
+		job := currentState.CurrentJobs[k]
+		reportJobProgress(ctx, k)
+
+		r.logger.LogInfo(ctx, "Found job %s that is no longer desired. Deleting...", k)
+		err := r.clusterAccess.DeleteJob(ctx, src, job)
+		if err != nil {
+			r.logger.LogError(ctx, "Failed to DeleteJob: %v - %v - %v - %v", err, src.URL, src.Path, *job.Name)
+			return fmt.Errorf("could not delete job %s: %w", k, err)
+		}
+
+		// we have a change
+		mu.Lock()
+		src.Status.LastUpdateTime = toTimePtr(time.Now())
+		mu.Unlock()
+
+		ev := &domain.Event{
+			ID:        uuid.New().String(),
+			Timestamp: time.Now(),
+			Message:   fmt.Sprintf("Deleted Job:%v", strPtrToStr(job.Job.Name)),
+			Type:      domain.EventTypeDeleted,
+			Source:    src,
+		}
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+		r.logger.LogInfo(ctx, "Found job %s that is no longer desired. Deleting...Done", k)
+		return nil
+	}
+
+	// jobs are deleted before the jobs they wait for, a wave is deleted once the allocations of the previous one stopped
+	deleteJobs := func() error {
+		jobs := map[string]*api.Job{}
+		for _, k := range toDelete {
+			jobs[k] = currentState.CurrentJobs[k].Job
+		}
+		var deleted []*JobInfo
+		for _, wave := range DeletionWaves(jobs) {
+			r.clusterAccess.WaitForJobsStopped(ctx, src, deleted)
+			// the jobs the wave waits for are kept if it could not be deleted
+			err := r.forEachJob(ctx, wave, deleteJob)
+			r.sendJobChanges(ctx, events, nil)
+			events = nil
 			if err != nil {
-				r.logger.LogError(ctx, "Failed to DeleteJob: %v - %v - %v - %v", err, src.URL, src.Path, *job.Name)
-				return nil, err
+				return err
+			}
+			deleted = nil
+			for _, k := range wave {
+				deleted = append(deleted, currentState.CurrentJobs[k])
 			}
+		}
+		return nil
+	}
+
+	// an atomic sync deletes jobs only after all jobs are registered, as deletions can not be reverted
+	atomic := src.Atomic && !src.Paused
+	if !atomic {
+		err = deleteJobs()
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			// we have a change
-			src.Status.LastUpdateTime = toTimePtr(time.Now())
+	var toUpdate []string
+	for k := range desiredState.Jobs {
+		toUpdate = append(toUpdate, k)
+	}
 
-			ev := &domain.Event{
-				ID:        uuid.New().String(),
-				Timestamp: time.Now(),
-				Message:   fmt.Sprintf("Deleted Job:%v", strPtrToStr(job.Job.Name)),
-				Type:      domain.EventTypeDeleted,
-				Source:    src,
-			}
-			err = r.evRepo.SaveEvent(ctx, ev)
+	if atomic {
+		// nothing is registered unless all jobs can be planned
+		planSrc := *src
+		planSrc.Paused = true
+		err = r.forEachJob(ctx, toUpdate, func(k string) error {
+			_, err := r.clusterAccess.UpdateJob(ctx, &planSrc, desiredState.Jobs[k], restart)
 			if err != nil {
-				r.logger.LogError(ctx, "Could not store event:%v", log.ToJSONString(ev))
+				return fmt.Errorf("could not plan job %s: %w", k, err)
 			}
-			r.logger.LogInfo(ctx, "Found job %s that is no longer desired. Deleting...Done", k)
+			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
+	// jobs registered by this sync, reverted if an atomic sync fails
+	var registered []string
+
+	err = r.forEachJob(ctx, toUpdate, func(k string) error {
+		job := desiredState.Jobs[k]
+		reportJobProgress(ctx, k)
+
+		previous, hasPrevious := previousJobs[k]
+		if hasPrevious && !restart && !src.Paused && !src.Atomic && commit != "" {
+			current, exists := currentState.CurrentJobs[k]
+			if resuming && exists && previous.Failures == 0 && previous.LastAppliedCommit == commit {
+				r.logger.LogTrace(ctx, "Job %v is already applied at %v", k, commit)
+				previous.Status = strPtrToStr(current.Status)
+				previous.StatusDescription = strPtrToStr(current.StatusDescription)
+				mu.Lock()
+				src.Status.Jobs[k] = previous
+				mu.Unlock()
+				return nil
+			}
+			if previous.Failures > 0 && previous.FailedCommit == commit &&
+				previous.RetryAfter != nil && time.Now().Before(*previous.RetryAfter) {
+				mu.Lock()
+				src.Status.Jobs[k] = previous
+				mu.Unlock()
+				return fmt.Errorf("job %s failed %d times, retrying after %s: %s",
+					k, previous.Failures, previous.RetryAfter.Format(time.RFC3339), previous.LastError)
+			}
+		}
 
-	for k, job := range desiredState.Jobs {
 		r.logger.LogTrace(ctx, "Updating job %v...%+v", strPtrToStr(job.Name), log.ToJSONString(job))
 		info, err := r.clusterAccess.UpdateJob(ctx, src, job, restart)
 		if err != nil {
 			r.logger.LogError(ctx, "Could not UpdateJob %v", log.ToJSONString(job))
-			return nil, err
+			if !src.Paused {
+				failed := previous
+				if failed.FailedCommit != commit {
+					failed.Failures = 0
+				}
+				failed.Failures++
+				failed.FailedCommit = commit
+				failed.LastError = err.Error()
+				failed.PolicyViolations = domain.PolicyViolationsOf(err)
+				failed.RetryAfter = nil
+				if !src.Atomic {
+					// an atomic source is retried as a whole
+					failed.RetryAfter = toTimePtr(time.Now().Add(r.retryBackoff(failed.Failures)))
+				}
+				mu.Lock()
+				src.Status.Jobs[k] = failed
+				mu.Unlock()
+			}
+			return fmt.Errorf("could not update job %s: %w", k, err)
 		}
 
 		jobStatus := domain.JobStatus{
-			Type:             strPtrToStr(job.Type),
-			Status:           "unknown",
-			DeploymentStatus: info.DeploymentStatus.Status,
-			Groups:           map[string]domain.GroupStatus{},
-			Namespace:        *job.Namespace,
-			Diff:             info.Diff,
+			Type:                    strPtrToStr(job.Type),
+			Status:                  "unknown",
+			DeploymentStatus:        info.DeploymentStatus.Status,
+			DeploymentID:            info.DeploymentStatus.ID,
+			DeploymentHealthyAllocs: info.DeploymentStatus.HealthyAllocs,
+			Groups:                  map[string]domain.GroupStatus{},
+			Namespace:               strPtrToStr(job.Namespace),
+			Diff:                    info.Diff,
+			DiffSummary:             info.DiffSummary,
+			Warnings:                info.Warnings,
+			PolicyViolations:        info.PolicyViolations,
+			Links:                   info.Links,
+		}
+		trackDeploymentProgress(previous, &jobStatus, time.Now())
+		jobStatus.LastAppliedCommit = previous.LastAppliedCommit
+		if !src.Paused {
+			jobStatus.LastAppliedCommit = commit
+		}
+		if len(info.Warnings) > 0 {
+			r.logger.LogInfo(ctx, "Job %v has warnings:%v", strPtrToStr(job.Name), info.Warnings)
 		}
 		if j, ok := currentState.CurrentJobs[k]; ok {
 			jobStatus.Status = strPtrToStr(j.Status)
@@ -191,25 +406,43 @@ func (r *ReconciliationManager) OnReconcile(ctx context.Context,
 			jobStatus.Groups[strPtrToStr(tg.Name)] = groupStatus
 		}
 
-		src.Status.Jobs[strPtrToStr(job.Name)] = jobStatus
+		mu.Lock()
+		src.Status.Jobs[k] = jobStatus
+		mu.Unlock()
 
 		r.logger.LogTrace(ctx, "Updating job %v...Done", strPtrToStr(job.Name))
 
 		if !info.Created && !info.Updated {
 			r.logger.LogTrace(ctx, "Nothing to do for job %v", strPtrToStr(job.Name))
-			continue
+			return nil
 		}
 
 		// we have a change
+		mu.Lock()
 		src.Status.LastUpdateTime = toTimePtr(time.Now())
-
+		if info.DiffSummary != "" {
+			// only nomad-ops metadata changed (new commit / forced restart) => nothing to summarize
+			changed.DiffSummaries[k] = info.DiffSummary
+		}
 		if info.Created {
-			cpy := job
-			changed.Create[k] = cpy
+			changed.Create[k] = job
+		}
+		if info.Updated && !(info.Created && src.Paused) {
+			changed.Update[k] = job
+		}
+		if _, managed := currentState.CurrentJobs[k]; !managed && info.Updated &&
+			info.DiffSummary != JobCreatedSummary(strPtrToStr(job.ID)) {
+			changed.Adopted[k] = job
+		}
+		if !src.Paused {
+			registered = append(registered, k)
+		}
+		mu.Unlock()
 
+		if info.Created {
 			if src.Paused {
 				r.logger.LogInfo(ctx, "Would create job %v", strPtrToStr(job.Name))
-				continue
+				return nil
 			}
 			ev := &domain.Event{
 				ID:        uuid.New().String(),
@@ -218,19 +451,15 @@ func (r *ReconciliationManager) OnReconcile(ctx context.Context,
 				Type:      domain.EventTypeCreated,
 				Source:    src,
 			}
-			err = r.evRepo.SaveEvent(ctx, ev)
-			if err != nil {
-				r.logger.LogError(ctx, "Could not store event:%v", log.ToJSONString(ev))
-			}
+			mu.Lock()
+			events = append(events, ev)
+			mu.Unlock()
 			r.logger.LogInfo(ctx, "Created job %v", strPtrToStr(job.Name))
 		}
 		if info.Updated {
-			cpy := job
-			changed.Update[k] = cpy
-
 			if src.Paused {
 				r.logger.LogInfo(ctx, "Would update job %v", strPtrToStr(job.Name))
-				continue
+				return nil
 			}
 
 			ev := &domain.Event{
@@ -240,54 +469,202 @@ func (r *ReconciliationManager) OnReconcile(ctx context.Context,
 				Type:      domain.EventTypeUpdated,
 				Source:    src,
 			}
-			err = r.evRepo.SaveEvent(ctx, ev)
-			if err != nil {
-				r.logger.LogError(ctx, "Could not store event:%v - %v", err, log.ToJSONString(ev))
-			}
+			mu.Lock()
+			events = append(events, ev)
+			mu.Unlock()
 			r.logger.LogInfo(ctx, "Updated job %v", strPtrToStr(job.Name))
-			err = r.notifier.Notify(ctx, NotifyOptions{
+			infos := []NotifyAdditionalInfos{
+				{
+					Header: "Git-Commit",
+					Text:   desiredState.GitInfo.GitCommit,
+				},
+				{
+					Header: "Git-Url",
+					Text:   src.URL,
+				},
+				{
+					Header: "Git-Ref",
+					Text:   src.Branch,
+				},
+				{
+					Header: "Git-Repo-Path",
+					Text:   src.Path,
+				},
+				{
+					Header: "Nomad-Namespace",
+					Text:   src.Namespace,
+				},
+				{
+					Header: "Nomad-Region",
+					Text:   src.Region,
+				},
+				{
+					Header: "Force Restart",
+					Text:   fmt.Sprintf("%v", restart),
+				},
+			}
+			if info.Links != nil {
+				infos = append(infos, NotifyAdditionalInfos{
+					Header: "Nomad-UI",
+					Text:   info.Links.Job,
+				})
+			}
+			annotationOnce.Do(func() {
+				annotation = r.commitAnnotation(ctx, src, commit)
+			})
+			infos = append(infos, annotationInfos(annotation)...)
+			if info.DiffSummary != "" {
+				infos = append(infos, NotifyAdditionalInfos{
+					Header: "Changes",
+					Text:   info.DiffSummary,
+					Large:  true,
+				})
+			}
+			mu.Lock()
+			notifications = append(notifications, NotifyOptions{
 				Source:  src,
 				GitInfo: desiredState.GitInfo,
 				Type:    NotificationSuccess,
 				Message: fmt.Sprintf("Updated Job:%v", strPtrToStr(job.Job.Name)),
-				Infos: []NotifyAdditionalInfos{
-					{
-						Header: "Git-Commit",
-						Text:   desiredState.GitInfo.GitCommit,
-					},
-					{
-						Header: "Git-Url",
-						Text:   src.URL,
-					},
-					{
-						Header: "Git-Ref",
-						Text:   src.Branch,
-					},
-					{
-						Header: "Git-Repo-Path",
-						Text:   src.Path,
-					},
-					{
-						Header: "Nomad-Namespace",
-						Text:   src.Namespace,
-					},
-					{
-						Header: "Nomad-Region",
-						Text:   src.Region,
-					},
-					{
-						Header: "Force Restart",
-						Text:   fmt.Sprintf("%v", restart),
-					},
-				},
+				Infos:   infos,
 			})
+			mu.Unlock()
+		}
+		return nil
+	})
+	r.sendJobChanges(ctx, events, notifications)
+	events = nil
+	if err != nil {
+		if atomic && len(registered) > 0 {
+			r.revertJobs(ctx, src, currentState, desiredState, previousJobs, registered)
+			return nil, fmt.Errorf("reverted %s: %w", strings.Join(registered, ", "), err)
+		}
+		return nil, err
+	}
+
+	if atomic {
+		err = deleteJobs()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return changed, nil
+}
+
+// sendJobChanges stores the events and sends the notifications of the changed jobs
+func (r *ReconciliationManager) sendJobChanges(ctx context.Context, events []*domain.Event, notifications []NotifyOptions) {
+	for _, ev := range events {
+		err := r.evRepo.SaveEvent(ctx, ev)
+		if err != nil {
+			r.logger.LogError(ctx, "Could not store event:%v - %v", err, log.ToJSONString(ev))
+		}
+	}
+	for _, n := range notifications {
+		err := r.notifier.Notify(ctx, n)
+		if err != nil {
+			r.logger.LogError(ctx, "Could not notify:%v", err)
+		}
+	}
+}
+
+// revertJobs restores the jobs registered by a failed atomic sync: updated jobs are reverted to the version
+// before the sync, created jobs are deleted. Failures are logged, the jobs are fixed by the next sync.
+func (r *ReconciliationManager) revertJobs(ctx context.Context,
+	src *domain.Source,
+	currentState *ClusterState,
+	desiredState *DesiredState,
+	previousJobs map[string]domain.JobStatus,
+	names []string) {
+
+	// the revert must not be aborted by the cancellation of the sync, the action is kept for the audit log
+	ctx = WithAction(r.ctx, ActionFromContext(ctx))
+	sort.Strings(names)
+	for _, k := range names {
+		job := desiredState.Jobs[k]
+		ev := &domain.Event{
+			ID:        uuid.New().String(),
+			Timestamp: time.Now(),
+			Source:    src,
+		}
+		if current, ok := currentState.CurrentJobs[k]; ok && current.Version != nil {
+			r.logger.LogInfo(ctx, "Reverting job %s to version %d...", k, *current.Version)
+			err := r.clusterAccess.RevertJob(ctx, src, job, *current.Version)
 			if err != nil {
-				r.logger.LogError(ctx, "Could not notify:%v", err)
+				r.logger.LogError(ctx, "Could not revert job %s:%v", k, err)
+				continue
 			}
+			ev.Message = fmt.Sprintf("Reverted Job:%s to version %d", k, *current.Version)
+			ev.Type = domain.EventTypeUpdated
+		} else {
+			r.logger.LogInfo(ctx, "Deleting job %s created by the failed sync...", k)
+			err := r.clusterAccess.DeleteJob(ctx, src, job)
+			if err != nil {
+				r.logger.LogError(ctx, "Could not delete job %s:%v", k, err)
+				continue
+			}
+			ev.Message = fmt.Sprintf("Deleted Job:%s created by the failed sync", k)
+			ev.Type = domain.EventTypeDeleted
 		}
+		err := r.evRepo.SaveEvent(ctx, ev)
+		if err != nil {
+			r.logger.LogError(ctx, "Could not store event:%v", log.ToJSONString(ev))
+		}
+
+		// the job is no longer applied at this commit
+		js := src.Status.Jobs[k]
+		js.LastAppliedCommit = previousJobs[k].LastAppliedCommit
+		src.Status.Jobs[k] = js
 	}
+}
 
-	return changed, nil
+// retryBackoff is the time a job is not retried after its nth consecutive failure, doubling up to cfg.JobRetryMaxBackoff
+func (r *ReconciliationManager) retryBackoff(failures int) time.Duration {
+	backoff := r.cfg.JobRetryBackoff
+	for i := 1; i < failures && backoff < r.cfg.JobRetryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.cfg.JobRetryMaxBackoff && r.cfg.JobRetryMaxBackoff > 0 {
+		return r.cfg.JobRetryMaxBackoff
+	}
+	return backoff
+}
+
+// forEachJob calls fn for all jobs, at most cfg.JobParallelism at once. All jobs are processed even
+// if some of them fail, the errors are joined into one.
+func (r *ReconciliationManager) forEachJob(ctx context.Context, names []string, fn func(name string) error) error {
+	parallelism := r.cfg.JobParallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	// deterministic order, in case the parallelism is lower than the number of jobs
+	sort.Strings(names)
+
+	var lock sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+	for _, name := range names {
+		if ctx.Err() != nil {
+			lock.Lock()
+			errs = append(errs, fmt.Errorf("job %s was skipped: %w", name, ctx.Err()))
+			lock.Unlock()
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(name); err != nil {
+				lock.Lock()
+				errs = append(errs, err)
+				lock.Unlock()
+			}
+		}(name)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
 func toTimePtr(t time.Time) *time.Time {
@@ -0,0 +1,310 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestForEachJob(t *testing.T) {
+	r := &ReconciliationManager{
+		cfg: ReconciliationManagerConfig{JobParallelism: 2},
+	}
+
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	processed := map[string]bool{}
+	errFailed := domain.WithErrorCode(domain.ErrorCodePlanFailed, errors.New("failed"))
+
+	err := r.forEachJob(context.Background(), []string{"a", "b", "c", "d", "e"}, func(name string) error {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		processed[name] = true
+		lock.Unlock()
+
+		time.Sleep(time.Millisecond * 10)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+		if name == "b" {
+			return errFailed
+		}
+		return nil
+	})
+
+	if maxRunning != 2 {
+		t.Errorf("Expected 2 jobs to run at once, got %d", maxRunning)
+	}
+	if len(processed) != 5 {
+		t.Errorf("Expected all jobs to be processed despite the error, got %v", processed)
+	}
+	if !errors.Is(err, errFailed) || domain.ErrorCodeOf(err, "") != domain.ErrorCodePlanFailed {
+		t.Errorf("Expected the error of the failed job, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = r.forEachJob(ctx, []string{"a"}, func(name string) error {
+		t.Errorf("Expected no job to run after cancellation")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancellation error, got %v", err)
+	}
+}
+
+type flakyCluster struct {
+	lock       sync.Mutex
+	fail       map[string]bool
+	failPlan   map[string]bool
+	registered map[string]bool
+	updates    []string
+	reverted   []string
+	deleted    []string
+}
+
+func (c *flakyCluster) GetCurrentClusterState(ctx context.Context, opts GetCurrentClusterStateOptions) (*ClusterState, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	state := &ClusterState{CurrentJobs: map[string]*JobInfo{}}
+	for name := range c.registered {
+		name := name
+		version := uint64(1)
+		state.CurrentJobs[name] = &JobInfo{Job: &api.Job{Name: &name, Version: &version}}
+	}
+	return state, nil
+}
+
+func (c *flakyCluster) UpdateJob(ctx context.Context, src *domain.Source, job *JobInfo, restart bool) (*UpdateJobInfo, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if src.Paused {
+		if c.failPlan[*job.Name] {
+			return nil, errors.New("invalid")
+		}
+		return &UpdateJobInfo{Updated: true}, nil
+	}
+	if c.fail[*job.Name] {
+		return nil, errors.New("unavailable")
+	}
+	c.registered[*job.Name] = true
+	c.updates = append(c.updates, *job.Name)
+	return &UpdateJobInfo{Updated: true}, nil
+}
+
+func (c *flakyCluster) DeleteJob(ctx context.Context, src *domain.Source, job *JobInfo) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.registered, *job.Name)
+	c.deleted = append(c.deleted, *job.Name)
+	return nil
+}
+
+func (c *flakyCluster) RevertJob(ctx context.Context, src *domain.Source, job *JobInfo, version uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.reverted = append(c.reverted, *job.Name)
+	return nil
+}
+
+func (c *flakyCluster) WaitForJobsStopped(ctx context.Context, src *domain.Source, jobs []*JobInfo) {
+}
+
+func (c *flakyCluster) takeUpdates() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	updates := c.updates
+	c.updates = nil
+	sort.Strings(updates)
+	return updates
+}
+
+func TestReconcileResumesFailedJobs(t *testing.T) {
+	ctx := context.Background()
+	cluster := &flakyCluster{fail: map[string]bool{"b": true}, registered: map[string]bool{}}
+	r := &ReconciliationManager{
+		logger:        log.NewSimpleLogger(false, "Test"),
+		cfg:           ReconciliationManagerConfig{JobRetryBackoff: time.Hour, JobRetryMaxBackoff: 4 * time.Hour},
+		clusterAccess: cluster,
+		evRepo:        discardEvents{},
+		notifier:      &recordingNotifier{},
+	}
+	src := &domain.Source{ID: "src"}
+	desiredState := func(commit string) *DesiredState {
+		jobs := map[string]*JobInfo{}
+		for _, name := range []string{"a", "b", "c"} {
+			name := name
+			jobs[name] = &JobInfo{Job: &api.Job{Name: &name}}
+		}
+		return &DesiredState{GitInfo: GitInfo{GitCommit: commit}, Jobs: jobs}
+	}
+
+	if _, err := r.OnReconcile(ctx, src, desiredState("c1"), false); err == nil {
+		t.Fatalf("Expected job b to fail")
+	}
+	if u := cluster.takeUpdates(); len(u) != 2 {
+		t.Errorf("Expected a and c to be applied, got %v", u)
+	}
+	b := src.Status.Jobs["b"]
+	if b.Failures != 1 || b.FailedCommit != "c1" || b.RetryAfter == nil || b.LastError == "" {
+		t.Errorf("Expected the failure of b to be tracked, got %+v", b)
+	}
+	if src.Status.Jobs["a"].LastAppliedCommit != "c1" {
+		t.Errorf("Expected a to be applied at c1, got %+v", src.Status.Jobs["a"])
+	}
+
+	// b is in backoff and a and c are already applied
+	cluster.fail["b"] = false
+	if _, err := r.OnReconcile(ctx, src, desiredState("c1"), false); err == nil {
+		t.Errorf("Expected b to wait for its backoff")
+	}
+	if u := cluster.takeUpdates(); len(u) != 0 {
+		t.Errorf("Expected no job to be applied, got %v", u)
+	}
+
+	// the backoff is over, only b is retried
+	past := time.Now().Add(-time.Second)
+	b = src.Status.Jobs["b"]
+	b.RetryAfter = &past
+	src.Status.Jobs["b"] = b
+	if _, err := r.OnReconcile(ctx, src, desiredState("c1"), false); err != nil {
+		t.Fatalf("Could not resume:%v", err)
+	}
+	if u := cluster.takeUpdates(); len(u) != 1 || u[0] != "b" {
+		t.Errorf("Expected only b to be retried, got %v", u)
+	}
+	if b := src.Status.Jobs["b"]; b.Failures != 0 || b.LastAppliedCommit != "c1" {
+		t.Errorf("Expected b to be applied, got %+v", b)
+	}
+
+	// nothing failed, all jobs are planned again
+	if _, err := r.OnReconcile(ctx, src, desiredState("c1"), false); err != nil {
+		t.Fatalf("Could not reconcile:%v", err)
+	}
+	if u := cluster.takeUpdates(); len(u) != 3 {
+		t.Errorf("Expected all jobs to be planned, got %v", u)
+	}
+}
+
+// statusReadingNotifier reads the job status of the source like notifiers serializing the source
+type statusReadingNotifier struct {
+	recordingNotifier
+}
+
+func (n *statusReadingNotifier) Notify(ctx context.Context, opts NotifyOptions) error {
+	for name, js := range opts.Source.Status.Jobs {
+		_ = name + js.LastAppliedCommit
+	}
+	return n.recordingNotifier.Notify(ctx, opts)
+}
+
+func TestReconcileNotifiesAfterParallelJobs(t *testing.T) {
+	cluster := &flakyCluster{registered: map[string]bool{}}
+	notifier := &statusReadingNotifier{}
+	r := &ReconciliationManager{
+		logger:        log.NewSimpleLogger(false, "Test"),
+		cfg:           ReconciliationManagerConfig{JobParallelism: 4},
+		clusterAccess: cluster,
+		evRepo:        discardEvents{},
+		notifier:      notifier,
+	}
+	jobs := map[string]*JobInfo{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		name := name
+		jobs[name] = &JobInfo{Job: &api.Job{Name: &name}}
+	}
+
+	src := &domain.Source{ID: "src"}
+	if _, err := r.OnReconcile(context.Background(), src, &DesiredState{GitInfo: GitInfo{GitCommit: "c1"}, Jobs: jobs}, false); err != nil {
+		t.Fatalf("Could not reconcile:%v", err)
+	}
+	if len(notifier.sent) != 6 {
+		t.Errorf("Expected a notification of every updated job, got %d", len(notifier.sent))
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	r := &ReconciliationManager{
+		cfg: ReconciliationManagerConfig{JobRetryBackoff: time.Minute, JobRetryMaxBackoff: 5 * time.Minute},
+	}
+	for failures, expected := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 4 * time.Minute, 4: 5 * time.Minute, 10: 5 * time.Minute} {
+		if b := r.retryBackoff(failures); b != expected {
+			t.Errorf("Expected a backoff of %v after %d failures, got %v", expected, failures, b)
+		}
+	}
+}
+
+type discardEvents struct{}
+
+func (discardEvents) SaveEvent(ctx context.Context, ev *domain.Event) error {
+	return nil
+}
+
+func TestAtomicReconcile(t *testing.T) {
+	ctx := context.Background()
+	cluster := &flakyCluster{
+		failPlan:   map[string]bool{"b": true},
+		fail:       map[string]bool{},
+		registered: map[string]bool{"a": true, "old": true},
+	}
+	r := &ReconciliationManager{
+		ctx:           ctx,
+		logger:        log.NewSimpleLogger(false, "Test"),
+		clusterAccess: cluster,
+		evRepo:        discardEvents{},
+		notifier:      &recordingNotifier{},
+	}
+	src := &domain.Source{ID: "src", Atomic: true}
+	desiredState := &DesiredState{GitInfo: GitInfo{GitCommit: "c1"}, Jobs: map[string]*JobInfo{}}
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		desiredState.Jobs[name] = &JobInfo{Job: &api.Job{Name: &name}}
+	}
+
+	if _, err := r.OnReconcile(ctx, src, desiredState, false); err == nil {
+		t.Fatalf("Expected the plan of b to fail")
+	}
+	if u := cluster.takeUpdates(); len(u) != 0 || len(cluster.deleted) != 0 {
+		t.Errorf("Expected nothing to be changed if a plan fails, got %v %v", u, cluster.deleted)
+	}
+
+	cluster.failPlan["b"] = false
+	cluster.fail["b"] = true
+	if _, err := r.OnReconcile(ctx, src, desiredState, false); err == nil {
+		t.Fatalf("Expected the registration of b to fail")
+	}
+	if len(cluster.reverted) != 1 || cluster.reverted[0] != "a" {
+		t.Errorf("Expected a to be reverted, got %v", cluster.reverted)
+	}
+	if len(cluster.deleted) != 1 || cluster.deleted[0] != "c" {
+		t.Errorf("Expected only the new job c to be deleted, got %v", cluster.deleted)
+	}
+	if js := src.Status.Jobs["a"]; js.LastAppliedCommit != "" {
+		t.Errorf("Expected the reverted job a not to be applied, got %+v", js)
+	}
+	cluster.takeUpdates()
+
+	cluster.fail["b"] = false
+	cluster.deleted = nil
+	if _, err := r.OnReconcile(ctx, src, desiredState, false); err != nil {
+		t.Fatalf("Could not reconcile:%v", err)
+	}
+	if u := cluster.takeUpdates(); len(u) != 3 {
+		t.Errorf("Expected all jobs to be registered, got %v", u)
+	}
+	if len(cluster.deleted) != 1 || cluster.deleted[0] != "old" {
+		t.Errorf("Expected the removed job to be deleted after the registrations, got %v", cluster.deleted)
+	}
+}
@@ -0,0 +1,34 @@
+package application
+
+import (
+	"context"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// RenderRequest is the input of a Renderer
+type RenderRequest struct {
+	// Dir is the checkout of the repository at Commit
+	Dir string `json:"dir"`
+	// Path is the path of the source in the repository, relative to Dir
+	Path   string         `json:"path"`
+	Commit string         `json:"commit"`
+	Source *domain.Source `json:"source"`
+}
+
+// RenderedJob is a jobspec, either an HCL job file or a job in the JSON format of the Nomad API
+type RenderedJob struct {
+	// File the job was rendered from, used in errors
+	File string `json:"file"`
+	Spec string `json:"spec"`
+}
+
+// Renderer generates the jobspecs of a source, e.g. from CUE or Jsonnet files
+type Renderer interface {
+	Render(ctx context.Context, req RenderRequest) ([]RenderedJob, error)
+}
+
+// JobValidator checks a job of the desired state against rules of the organization, e.g. CUE schemas
+type JobValidator interface {
+	ValidateJob(ctx context.Context, src *domain.Source, job *JobInfo) error
+}
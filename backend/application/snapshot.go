@@ -0,0 +1,166 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// Snapshot are the files of the jobs of a source committed to a branch
+type Snapshot struct {
+	// URL is the repository, empty for the one of the source
+	URL    string
+	Branch string
+	// Dir is the directory of the source on the branch, its content is replaced by the files
+	Dir         string
+	Files       map[string][]byte
+	Message     string
+	AuthorName  string
+	AuthorEmail string
+}
+
+type SnapshotWriter interface {
+	WriteSnapshot(ctx context.Context, src *domain.Source, snapshot Snapshot) error
+}
+
+type SnapshotterConfig struct {
+	// URL is the repository the snapshots are written to, empty for the repository of each source
+	URL string
+	// Branch is the branch the snapshots are written to, e.g. deployed
+	Branch      string
+	AuthorName  string
+	AuthorEmail string
+}
+
+// Snapshotter writes the jobs registered for a source after a successful sync to a branch as normalized JSON,
+// a record of the live state that can be audited and diffed over time
+type Snapshotter struct {
+	ctx     context.Context
+	logger  log.Logger
+	cfg     SnapshotterConfig
+	cluster ClusterAPI
+	writer  SnapshotWriter
+
+	lock sync.Mutex
+	// written are the hashes of the last written snapshots by source
+	written map[string]string
+}
+
+func CreateSnapshotter(ctx context.Context,
+	logger log.Logger,
+	cfg SnapshotterConfig,
+	cluster ClusterAPI,
+	writer SnapshotWriter) (*Snapshotter, error) {
+	t := &Snapshotter{
+		ctx:     ctx,
+		logger:  logger,
+		cfg:     cfg,
+		cluster: cluster,
+		writer:  writer,
+		written: map[string]string{},
+	}
+
+	return t, nil
+}
+
+// OnSynced writes the snapshot of the source if it is enabled and the registered jobs changed since the last one
+func (s *Snapshotter) OnSynced(ctx context.Context, src *domain.Source) {
+	if !src.Snapshot {
+		return
+	}
+	err := s.Write(ctx, src)
+	if err != nil {
+		s.logger.LogError(ctx, "Could not write the snapshot of %s:%v", src.ID, err)
+	}
+}
+
+func (s *Snapshotter) Write(ctx context.Context, src *domain.Source) error {
+	state, err := s.cluster.GetCurrentClusterState(ctx, GetCurrentClusterStateOptions{
+		Source: src,
+	})
+	if err != nil {
+		return err
+	}
+	files, err := SnapshotFiles(state)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\n%s\n", name, files[name])
+	}
+	hash := fmt.Sprintf("%x", h.Sum(nil))
+	s.lock.Lock()
+	unchanged := s.written[src.ID] == hash
+	s.lock.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	commit := ""
+	if src.Status != nil {
+		commit = src.Status.AppliedCommit()
+	}
+	err = s.writer.WriteSnapshot(ctx, src, Snapshot{
+		URL:         s.cfg.URL,
+		Branch:      s.cfg.Branch,
+		Dir:         src.Name,
+		Files:       files,
+		Message:     fmt.Sprintf("Snapshot of %s at %s", src.Name, commit),
+		AuthorName:  s.cfg.AuthorName,
+		AuthorEmail: s.cfg.AuthorEmail,
+	})
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.written[src.ID] = hash
+	s.lock.Unlock()
+	return nil
+}
+
+// SnapshotFiles returns the registered jobs as <namespace>/<job>.json, without the fields Nomad changes on every
+// registration or evaluation
+func SnapshotFiles(state *ClusterState) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, job := range state.CurrentJobs {
+		cpy := normalizedJob(job.Job)
+		data, err := json.MarshalIndent(cpy, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[fmt.Sprintf("%s/%s.json", jobNamespace(nil, cpy), *cpy.ID)] = append(data, '\n')
+	}
+	return files, nil
+}
+
+// normalizedJob returns a copy of the job without the fields Nomad changes on every registration or evaluation
+// and without tokens
+func normalizedJob(job *api.Job) *api.Job {
+	cpy := *job
+	cpy.Status = nil
+	cpy.StatusDescription = nil
+	cpy.Stable = nil
+	cpy.Version = nil
+	cpy.SubmitTime = nil
+	cpy.CreateIndex = nil
+	cpy.ModifyIndex = nil
+	cpy.JobModifyIndex = nil
+	cpy.VaultToken = nil
+	cpy.ConsulToken = nil
+	return &cpy
+}
@@ -0,0 +1,179 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// ReplicationSource returns the changes of the store of the primary page by page
+type ReplicationSource interface {
+	FetchChanges(ctx context.Context, since time.Time, page *domain.ReplicationPage) (*domain.ReplicationBatch, error)
+}
+
+// ReplicationTarget applies the changes of the primary to the store of the standby
+type ReplicationTarget interface {
+	ApplyChanges(ctx context.Context, batch *domain.ReplicationBatch) error
+}
+
+type StandbyConfig struct {
+	// Primary is the address of the primary, shown in the status
+	Primary string
+	// Interval is the time between two replications
+	Interval time.Duration
+	AppName  string
+}
+
+// Standby replicates the store of the primary and reconciles no source until it got promoted.
+// It is used as Shard of the reconciliation manager.
+type Standby struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    StandbyConfig
+	source ReplicationSource
+	target ReplicationTarget
+
+	// replicating is held while changes are applied, so a promotion waits for them
+	replicating sync.Mutex
+
+	lock   sync.RWMutex
+	status domain.StandbyStatus
+}
+
+func CreateStandby(ctx context.Context,
+	logger log.Logger,
+	cfg StandbyConfig,
+	source ReplicationSource,
+	target ReplicationTarget) (*Standby, error) {
+	t := &Standby{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+		source: source,
+		target: target,
+		status: domain.StandbyStatus{
+			Primary: cfg.Primary,
+		},
+	}
+
+	metrics.GetOrCreateGauge(fmt.Sprintf(`nomad_ops_standby_replication_lag_seconds{app="%s"}`, cfg.AppName), func() float64 {
+		s := t.Status()
+		if s.Promoted || s.Cursor == nil {
+			return 0
+		}
+		return time.Since(*s.Cursor).Seconds()
+	})
+
+	return t, nil
+}
+
+// Owns returns true once the standby got promoted, until then the primary reconciles all sources
+func (s *Standby) Owns(srcID string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.status.Promoted
+}
+
+// Status returns the state of the replication
+func (s *Standby) Status() domain.StandbyStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.status
+}
+
+// Run replicates every interval until the context is done or the standby got promoted
+func (s *Standby) Run(ctx context.Context) {
+	t := time.NewTicker(s.cfg.Interval)
+	defer t.Stop()
+	for {
+		if s.Status().Promoted {
+			return
+		}
+		err := s.replicate(ctx)
+		if err != nil {
+			s.logger.LogError(ctx, "Could not replicate from %s:%v", s.cfg.Primary, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// replicate applies all pages of the changes of the primary since the last replication. The cursor of the first
+// page is kept, so rows changed while the pages are fetched are replicated again with the next replication.
+func (s *Standby) replicate(ctx context.Context) error {
+	s.replicating.Lock()
+	defer s.replicating.Unlock()
+
+	status := s.Status()
+	if status.Promoted {
+		return nil
+	}
+	var since time.Time
+	if status.Cursor != nil {
+		since = *status.Cursor
+	}
+	var cursor *time.Time
+	var page *domain.ReplicationPage
+	var err error
+	for {
+		var batch *domain.ReplicationBatch
+		batch, err = s.source.FetchChanges(ctx, since, page)
+		if err == nil {
+			err = s.target.ApplyChanges(ctx, batch)
+		}
+		if err != nil {
+			break
+		}
+		if cursor == nil {
+			cursor = &batch.Cursor
+		}
+		page = batch.Next
+		if page == nil {
+			break
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err != nil {
+		s.status.Error = err.Error()
+		return err
+	}
+	now := time.Now()
+	s.status.Error = ""
+	s.status.LastReplicatedAt = &now
+	s.status.Cursor = cursor
+	return nil
+}
+
+// Promote stops the replication and lets the standby reconcile all sources. The changes since the last
+// replication are fetched once more if the primary is still reachable, takeOver starts watching the sources.
+func (s *Standby) Promote(ctx context.Context, takeOver func(ctx context.Context) error) error {
+	if s.Status().Promoted {
+		return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("the standby is already promoted"))
+	}
+	finalCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	err := s.replicate(finalCtx)
+	cancel()
+	if err != nil {
+		s.logger.LogInfo(ctx, "Could not replicate the last changes of %s, promoting anyway:%v", s.cfg.Primary, err)
+	}
+
+	s.replicating.Lock()
+	s.lock.Lock()
+	s.status.Promoted = true
+	s.lock.Unlock()
+	s.replicating.Unlock()
+
+	s.logger.LogInfo(ctx, "Promoted the standby of %s, taking over all sources", s.cfg.Primary)
+	return takeOver(ctx)
+}
@@ -0,0 +1,89 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeReplication struct {
+	err     error
+	since   []time.Time
+	applied int
+	// pages is the number of pages of the next replication
+	pages int
+}
+
+func (r *fakeReplication) FetchChanges(ctx context.Context, since time.Time, page *domain.ReplicationPage) (*domain.ReplicationBatch, error) {
+	if page == nil {
+		r.since = append(r.since, since)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	batch := &domain.ReplicationBatch{Cursor: time.Date(2023, 1, len(r.since), 0, 0, 0, 0, time.UTC)}
+	if r.pages > 1 {
+		r.pages--
+		// later pages have a later cursor, the one of the first page is kept
+		batch.Cursor = batch.Cursor.Add(time.Hour)
+		batch.Next = &domain.ReplicationPage{Table: "sources", After: "id"}
+	}
+	return batch, nil
+}
+
+func (r *fakeReplication) ApplyChanges(ctx context.Context, batch *domain.ReplicationBatch) error {
+	r.applied++
+	return nil
+}
+
+func TestStandby(t *testing.T) {
+	ctx := context.Background()
+	repl := &fakeReplication{}
+	s, err := CreateStandby(ctx, log.NewSimpleLogger(false, "Test"), StandbyConfig{Primary: "primary"}, repl, repl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repl.pages = 3
+	if err := s.replicate(ctx); err != nil {
+		t.Fatalf("Could not replicate:%v", err)
+	}
+	if repl.applied != 3 || repl.pages != 1 {
+		t.Errorf("Expected all pages to be applied, got %d", repl.applied)
+	}
+	if err := s.replicate(ctx); err != nil {
+		t.Fatalf("Could not replicate:%v", err)
+	}
+	if !repl.since[0].IsZero() || !repl.since[1].Equal(time.Date(2023, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the cursor of the first page of the last batch to be passed, got %v", repl.since)
+	}
+	if s.Owns("src") {
+		t.Errorf("Expected the standby to reconcile no source")
+	}
+
+	// the primary is gone, the standby is promoted with the changes replicated so far
+	repl.err = fmt.Errorf("connection refused")
+	tookOver := false
+	err = s.Promote(ctx, func(ctx context.Context) error {
+		tookOver = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Could not Promote:%v", err)
+	}
+	status := s.Status()
+	if !tookOver || !s.Owns("src") || !status.Promoted || status.Error == "" || repl.applied != 4 {
+		t.Errorf("Unexpected status after the promotion %+v", status)
+	}
+
+	if err := s.Promote(ctx, func(ctx context.Context) error { return nil }); domain.ErrorCodeOf(err, "") != domain.ErrorCodeInvalidRequest {
+		t.Errorf("Expected a second promotion to fail, got %v", err)
+	}
+	if err := s.replicate(ctx); err != nil || len(repl.since) != 3 {
+		t.Errorf("Expected no replication after the promotion")
+	}
+}
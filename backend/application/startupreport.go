@@ -0,0 +1,346 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+const (
+	StartupReportSourceSynced  = "synced"
+	StartupReportSourceFailed  = "failed"
+	StartupReportSourcePending = "pending"
+)
+
+// StartupReport summarizes the first sync of every source after the start
+type StartupReport struct {
+	StartedAt time.Time `json:"startedAt"`
+	// nil until all sources synced or the timeout passed
+	CompletedAt *time.Time             `json:"completedAt,omitempty"`
+	Sources     []*StartupReportSource `json:"sources"`
+	Totals      StartupReportTotals    `json:"totals"`
+}
+
+type StartupReportSource struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// synced, failed or pending
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	// jobs that did not exist in the cluster
+	Created []string `json:"created"`
+	// jobs that existed in the cluster without being managed by the source
+	Adopted []string `json:"adopted"`
+	// jobs changed in the cluster since they were applied at the same commit
+	Drifted []string `json:"drifted"`
+	// jobs changed by new commits
+	Updated []string `json:"updated"`
+	// jobs no longer in git, deleted or, if the source is paused, to be deleted
+	Orphaned []string `json:"orphaned"`
+	Failed   []string `json:"failed"`
+	InSync   int      `json:"inSync"`
+}
+
+type StartupReportTotals struct {
+	Sources  int `json:"sources"`
+	Synced   int `json:"synced"`
+	Failed   int `json:"failed"`
+	Pending  int `json:"pending"`
+	Created  int `json:"createdJobs"`
+	Adopted  int `json:"adoptedJobs"`
+	Drifted  int `json:"driftedJobs"`
+	Updated  int `json:"updatedJobs"`
+	Orphaned int `json:"orphanedJobs"`
+	// jobs that failed to apply
+	FailedJobs int `json:"failedJobs"`
+	InSync     int `json:"inSyncJobs"`
+}
+
+// startupReport collects the first sync of the expected sources
+type startupReport struct {
+	lock     sync.Mutex
+	report   *StartupReport
+	expected map[string]*domain.Source
+	done     chan struct{}
+}
+
+func newStartupReport(now time.Time, srcs map[string]*domain.Source) *startupReport {
+	r := &startupReport{
+		report: &StartupReport{
+			StartedAt: now,
+			Sources:   []*StartupReportSource{},
+		},
+		expected: srcs,
+		done:     make(chan struct{}),
+	}
+	if len(srcs) == 0 {
+		r.complete(now, nil)
+	}
+	return r
+}
+
+// record adds the outcome of the first sync of a source, later syncs are ignored
+func (r *startupReport) record(src *domain.Source, desiredState *DesiredState,
+	previous map[string]domain.JobStatus, changed *ChangeInfo, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.report.CompletedAt != nil {
+		return
+	}
+	if _, ok := r.expected[src.ID]; !ok {
+		return
+	}
+	delete(r.expected, src.ID)
+
+	s := newStartupReportSource(src)
+	commit := desiredState.GitInfo.GitCommit
+	s.Commit = commit
+	s.Status = StartupReportSourceSynced
+	if err != nil {
+		s.Status = StartupReportSourceFailed
+		s.Error = err.Error()
+	}
+	if src.Status != nil {
+		for k, js := range src.Status.Jobs {
+			if js.Failures > 0 && js.FailedCommit == commit {
+				s.Failed = append(s.Failed, k)
+			}
+		}
+	}
+	if changed != nil {
+		for k := range changed.Delete {
+			s.Orphaned = append(s.Orphaned, k)
+		}
+		for k, job := range changed.Update {
+			switch {
+			case changed.DiffSummaries[k] == JobCreatedSummary(strPtrToStr(job.ID)):
+				s.Created = append(s.Created, k)
+			case changed.Adopted[k] != nil:
+				s.Adopted = append(s.Adopted, k)
+			case previous[k].LastAppliedCommit == commit:
+				s.Drifted = append(s.Drifted, k)
+			default:
+				s.Updated = append(s.Updated, k)
+			}
+		}
+		s.InSync = len(desiredState.Jobs) - len(changed.Update) - len(s.Failed)
+		if s.InSync < 0 {
+			s.InSync = 0
+		}
+	}
+	for _, l := range [][]string{s.Created, s.Adopted, s.Drifted, s.Updated, s.Orphaned, s.Failed} {
+		sort.Strings(l)
+	}
+	r.report.Sources = append(r.report.Sources, s)
+
+	if len(r.expected) == 0 {
+		r.complete(time.Now(), nil)
+	}
+}
+
+func newStartupReportSource(src *domain.Source) *StartupReportSource {
+	return &StartupReportSource{
+		ID:       src.ID,
+		Name:     src.Name,
+		Created:  []string{},
+		Adopted:  []string{},
+		Drifted:  []string{},
+		Updated:  []string{},
+		Orphaned: []string{},
+		Failed:   []string{},
+	}
+}
+
+// complete adds the sources without a sync, failed if their status is an error, e.g. the repository could not be fetched
+func (r *startupReport) complete(now time.Time, statuses map[string]*domain.Source) {
+	if r.report.CompletedAt != nil {
+		return
+	}
+	for id, src := range r.expected {
+		s := newStartupReportSource(src)
+		s.Status = StartupReportSourcePending
+		if cur, ok := statuses[id]; ok && cur.Status != nil && cur.Status.Status == domain.SourceStatusStatusError {
+			s.Status = StartupReportSourceFailed
+			s.Error = cur.Status.Message
+		}
+		r.report.Sources = append(r.report.Sources, s)
+	}
+	r.expected = map[string]*domain.Source{}
+	sort.Slice(r.report.Sources, func(a, b int) bool {
+		return r.report.Sources[a].Name < r.report.Sources[b].Name
+	})
+	r.report.Totals = computeStartupReportTotals(r.report.Sources)
+	r.report.CompletedAt = &now
+	close(r.done)
+}
+
+func computeStartupReportTotals(srcs []*StartupReportSource) StartupReportTotals {
+	t := StartupReportTotals{}
+	for _, s := range srcs {
+		t.Sources++
+		switch s.Status {
+		case StartupReportSourceSynced:
+			t.Synced++
+		case StartupReportSourceFailed:
+			t.Failed++
+		default:
+			t.Pending++
+		}
+		t.Created += len(s.Created)
+		t.Adopted += len(s.Adopted)
+		t.Drifted += len(s.Drifted)
+		t.Updated += len(s.Updated)
+		t.Orphaned += len(s.Orphaned)
+		t.FailedJobs += len(s.Failed)
+		t.InSync += s.InSync
+	}
+	return t
+}
+
+// Filter returns a copy of the report with the visible sources only
+func (r *StartupReport) Filter(visible func(srcID string) bool) *StartupReport {
+	res := *r
+	res.Sources = []*StartupReportSource{}
+	for _, s := range r.Sources {
+		if visible(s.ID) {
+			res.Sources = append(res.Sources, s)
+		}
+	}
+	res.Totals = computeStartupReportTotals(res.Sources)
+	return &res
+}
+
+// notifyOptions renders the report as a single notification
+func (r *StartupReport) notifyOptions() NotifyOptions {
+	t := r.Totals
+	opts := NotifyOptions{
+		Type: NotificationSuccess,
+		Message: fmt.Sprintf("Startup resync of %d sources: %d synced, %d failed, %d pending. Jobs: %d created, %d adopted, %d drifted, %d updated, %d orphaned, %d failed, %d in sync",
+			t.Sources, t.Synced, t.Failed, t.Pending, t.Created, t.Adopted, t.Drifted, t.Updated, t.Orphaned, t.FailedJobs, t.InSync),
+	}
+	if t.Failed > 0 || t.FailedJobs > 0 || t.Pending > 0 {
+		opts.Type = NotificationError
+	}
+	for _, c := range []struct {
+		header string
+		jobs   func(s *StartupReportSource) []string
+	}{
+		{"Failed", func(s *StartupReportSource) []string { return s.Failed }},
+		{"Drifted", func(s *StartupReportSource) []string { return s.Drifted }},
+		{"Adopted", func(s *StartupReportSource) []string { return s.Adopted }},
+		{"Orphaned", func(s *StartupReportSource) []string { return s.Orphaned }},
+	} {
+		lines := []string{}
+		for _, s := range r.Sources {
+			if jobs := c.jobs(s); len(jobs) > 0 {
+				lines = append(lines, fmt.Sprintf("%s: %s", s.Name, strings.Join(jobs, ", ")))
+			}
+		}
+		if len(lines) > 0 {
+			opts.Infos = append(opts.Infos, NotifyAdditionalInfos{Header: c.header, Text: strings.Join(lines, "\n")})
+		}
+	}
+	lines := []string{}
+	for _, s := range r.Sources {
+		if s.Status != StartupReportSourceSynced {
+			line := fmt.Sprintf("%s: %s", s.Name, s.Status)
+			if s.Error != "" {
+				line += " - " + s.Error
+			}
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > 0 {
+		opts.Infos = append(opts.Infos, NotifyAdditionalInfos{Header: "Sources", Text: strings.Join(lines, "\n")})
+	}
+	return opts
+}
+
+// StartupReport returns the report of the first sync after the start, nil if disabled.
+// The report is incomplete until CompletedAt is set.
+func (m *ReconciliationManager) StartupReport() *StartupReport {
+	if m.startup == nil {
+		return nil
+	}
+	m.startup.lock.Lock()
+	defer m.startup.lock.Unlock()
+	res := *m.startup.report
+	res.Sources = append([]*StartupReportSource{}, m.startup.report.Sources...)
+	if res.CompletedAt == nil {
+		res.Totals = computeStartupReportTotals(res.Sources)
+	}
+	return &res
+}
+
+// reconcile records the first sync of every source in the startup report
+func (m *ReconciliationManager) reconcile(ctx context.Context,
+	src *domain.Source,
+	desiredState *DesiredState,
+	restart bool) (*ChangeInfo, error) {
+	if m.startup == nil {
+		return m.OnReconcile(ctx, src, desiredState, restart)
+	}
+	previous := map[string]domain.JobStatus{}
+	if src.Status != nil {
+		for k, js := range src.Status.Jobs {
+			previous[k] = js
+		}
+	}
+	changed, err := m.OnReconcile(ctx, src, desiredState, restart)
+	m.startup.record(src, desiredState, previous, changed, err)
+	return changed, err
+}
+
+// startStartupReport resyncs the watched sources and completes the report after the timeout
+func (m *ReconciliationManager) startStartupReport(ctx context.Context) {
+	m.lock.Lock()
+	expected := map[string]*domain.Source{}
+	for id, src := range m.watched {
+		expected[id] = src
+	}
+	m.lock.Unlock()
+	m.startup = newStartupReport(time.Now(), expected)
+
+	for id := range expected {
+		err := m.watcher.SyncSourceByID(ctx, id, SyncSourceOptions{})
+		if err != nil {
+			m.logger.LogError(ctx, "Could not resync source %s:%v", id, err)
+		}
+	}
+
+	go func() {
+		select {
+		case <-m.startup.done:
+		case <-time.After(m.cfg.StartupReportTimeout):
+			statuses := map[string]*domain.Source{}
+			srcs, err := m.repo.ListSources(ctx, ListSourcesOptions{})
+			if err != nil {
+				m.logger.LogError(ctx, "Could not list sources for the startup report:%v", err)
+			}
+			for _, src := range srcs {
+				statuses[src.ID] = src
+			}
+			m.startup.lock.Lock()
+			m.startup.complete(time.Now(), statuses)
+			m.startup.lock.Unlock()
+		case <-ctx.Done():
+			return
+		}
+
+		report := m.StartupReport()
+		m.logger.LogInfo(ctx, "Startup resync completed: %+v", report.Totals)
+		if !m.cfg.StartupReportNotify {
+			return
+		}
+		err := m.notifier.Notify(ctx, report.notifyOptions())
+		if err != nil {
+			m.logger.LogError(ctx, "Could not notify:%v", err)
+		}
+	}()
+}
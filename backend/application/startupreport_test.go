@@ -0,0 +1,68 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestStartupReport(t *testing.T) {
+	web := &domain.Source{ID: "web", Name: "web"}
+	api1 := &domain.Source{ID: "api", Name: "api"}
+	docs := &domain.Source{ID: "docs", Name: "docs"}
+	r := newStartupReport(time.Now(), map[string]*domain.Source{"web": web, "api": api1, "docs": docs})
+
+	job := func(id string) *JobInfo { return &JobInfo{Job: &api.Job{ID: log.ToStrPtr(id)}} }
+	desired := &DesiredState{
+		GitInfo: GitInfo{GitCommit: "c2"},
+		Jobs:    map[string]*JobInfo{"new": job("new"), "legacy": job("legacy"), "edited": job("edited"), "bumped": job("bumped"), "same": job("same")},
+	}
+	previous := map[string]domain.JobStatus{
+		"edited": {LastAppliedCommit: "c2"},
+		"bumped": {LastAppliedCommit: "c1"},
+	}
+	r.record(web, desired, previous, &ChangeInfo{
+		Update:        map[string]*JobInfo{"new": desired.Jobs["new"], "legacy": desired.Jobs["legacy"], "edited": desired.Jobs["edited"], "bumped": desired.Jobs["bumped"]},
+		Adopted:       map[string]*JobInfo{"legacy": desired.Jobs["legacy"]},
+		Delete:        map[string]*JobInfo{"old": job("old")},
+		DiffSummaries: map[string]string{"new": JobCreatedSummary("new")},
+	}, nil)
+
+	api1.Status = &domain.SourceStatus{Jobs: map[string]domain.JobStatus{"api": {Failures: 1, FailedCommit: "c2"}}}
+	r.record(api1, &DesiredState{GitInfo: GitInfo{GitCommit: "c2"}}, nil, nil, errors.New("could not update job api"))
+	// only the first sync counts
+	r.record(api1, &DesiredState{}, nil, &ChangeInfo{}, nil)
+
+	select {
+	case <-r.done:
+		t.Fatal("Expected the report to wait for docs")
+	default:
+	}
+	r.complete(time.Now(), map[string]*domain.Source{
+		"docs": {ID: "docs", Status: &domain.SourceStatus{Status: domain.SourceStatusStatusError, Message: "authentication required"}},
+	})
+
+	rep := r.report
+	s := rep.Sources[2]
+	if s.Name != "web" || fmt.Sprint(s.Created, s.Adopted, s.Drifted, s.Updated, s.Orphaned, s.InSync) != "[new] [legacy] [edited] [bumped] [old] 1" {
+		t.Errorf("Unexpected classification %+v", s)
+	}
+	if rep.Sources[0].Status != StartupReportSourceFailed || fmt.Sprint(rep.Sources[0].Failed) != "[api]" {
+		t.Errorf("Expected api to fail, got %+v", rep.Sources[0])
+	}
+	if rep.Sources[1].Status != StartupReportSourceFailed || rep.Sources[1].Error != "authentication required" {
+		t.Errorf("Expected docs to fail with its status, got %+v", rep.Sources[1])
+	}
+	if rep.Totals.Sources != 3 || rep.Totals.Synced != 1 || rep.Totals.Failed != 2 || rep.Totals.FailedJobs != 1 {
+		t.Errorf("Unexpected totals %+v", rep.Totals)
+	}
+	if n := rep.notifyOptions(); n.Type != NotificationError {
+		t.Errorf("Expected an error notification, got %+v", n)
+	}
+}
@@ -0,0 +1,277 @@
+package application
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// health of a source as published by the StatusPublisher
+const (
+	SourceHealthHealthy     = "healthy"
+	SourceHealthProgressing = "progressing"
+	SourceHealthOutOfSync   = "outofsync"
+	SourceHealthDegraded    = "degraded"
+	SourceHealthFailing     = "failing"
+)
+
+// StatusVariables stores variables in Nomad
+type StatusVariables interface {
+	PutVariable(ctx context.Context, namespace, path string, items map[string]string) error
+	DeleteVariable(ctx context.Context, namespace, path string) error
+	// ListVariables returns the items of the variables below the prefix by path
+	ListVariables(ctx context.Context, namespace, prefix string) (map[string]map[string]string, error)
+}
+
+type StatusPublisherConfig struct {
+	// Namespace of the variables
+	Namespace string
+	// Prefix of the paths of the variables, the variable of a source is <Prefix>/<source name>,
+	// or <Prefix>/<source name>-<source id> if the names of several sources map to the same path
+	Prefix string
+	// RefreshInterval is the time after which an unchanged status is published again, to update the last sync time.
+	// 0 publishes only changes.
+	RefreshInterval time.Duration
+}
+
+// StatusPublisher publishes the status of every source to a Nomad variable, so jobs in the cluster can consume
+// the deployment state without the API of nomad-ops. It is used as SourceStatusPatcher of the watcher and only
+// writes the variable if the status changed, the status of running syncs is not published.
+type StatusPublisher struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    StatusPublisherConfig
+	next   SourceStatusPatcher
+	repo   SourceRepo
+	vars   StatusVariables
+
+	lock      sync.Mutex
+	published map[string]*publishedStatus
+}
+
+type publishedStatus struct {
+	name  string
+	path  string
+	items map[string]string
+	at    time.Time
+}
+
+func CreateStatusPublisher(ctx context.Context,
+	logger log.Logger,
+	cfg StatusPublisherConfig,
+	next SourceStatusPatcher,
+	repo SourceRepo,
+	vars StatusVariables) (*StatusPublisher, error) {
+	if cfg.Namespace == "" {
+		cfg.Namespace = "default"
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "nomad-ops/status"
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	return &StatusPublisher{
+		ctx:       ctx,
+		logger:    logger,
+		cfg:       cfg,
+		next:      next,
+		repo:      repo,
+		vars:      vars,
+		published: map[string]*publishedStatus{},
+	}, nil
+}
+
+// SetSourceStatus stores the status and publishes it, failures to publish are only logged
+func (p *StatusPublisher) SetSourceStatus(srcID string, s *domain.SourceStatus) error {
+	err := p.next.SetSourceStatus(srcID, s)
+	p.publish(p.ctx, srcID, s, time.Now())
+	return err
+}
+
+// RemoveSource deletes the variable of a deleted source
+func (p *StatusPublisher) RemoveSource(ctx context.Context, srcID string) {
+	p.lock.Lock()
+	prev := p.published[srcID]
+	delete(p.published, srcID)
+	p.lock.Unlock()
+	if prev == nil {
+		// not published since the start, the variable may still exist
+		err := p.RemoveOrphans(ctx)
+		if err != nil {
+			p.logger.LogError(ctx, "Could not remove the status variable of %s:%v", srcID, err)
+		}
+		return
+	}
+	err := p.vars.DeleteVariable(ctx, p.cfg.Namespace, prev.path)
+	if err != nil {
+		p.logger.LogError(ctx, "Could not delete the status variable %s:%v", prev.path, err)
+	}
+}
+
+func (p *StatusPublisher) publish(ctx context.Context, srcID string, s *domain.SourceStatus, now time.Time) {
+	if s == nil {
+		return
+	}
+	switch s.Status {
+	case "", domain.SourceStatusStatusInit, domain.SourceStatusStatusSyncing:
+		// the sync did not finish yet
+		return
+	}
+
+	p.lock.Lock()
+	prev := p.published[srcID]
+	p.lock.Unlock()
+
+	refresh := prev == nil || (p.cfg.RefreshInterval > 0 && now.Sub(prev.at) >= p.cfg.RefreshInterval)
+	name, path := "", ""
+	if prev != nil {
+		name, path = prev.name, prev.path
+	}
+	if refresh {
+		// renames are picked up with the refresh
+		name, path = p.sourcePath(ctx, srcID)
+	}
+	items := statusItems(srcID, name, s)
+	if !refresh && reflect.DeepEqual(withoutTimes(items), withoutTimes(prev.items)) {
+		return
+	}
+
+	err := p.vars.PutVariable(ctx, p.cfg.Namespace, path, items)
+	if err != nil {
+		p.logger.LogError(ctx, "Could not publish the status of %s to %s:%v", name, path, err)
+		return
+	}
+	if prev != nil && prev.path != path {
+		err := p.vars.DeleteVariable(ctx, p.cfg.Namespace, prev.path)
+		if err != nil {
+			p.logger.LogError(ctx, "Could not delete the status variable %s:%v", prev.path, err)
+		}
+	}
+
+	p.lock.Lock()
+	p.published[srcID] = &publishedStatus{
+		name:  name,
+		path:  path,
+		items: items,
+		at:    now,
+	}
+	p.lock.Unlock()
+}
+
+// sourcePath returns the name of the source and the path of its variable. The id of the source is appended
+// if the name of another source maps to the same path, e.g. "a.b" and "a_b".
+func (p *StatusPublisher) sourcePath(ctx context.Context, srcID string) (string, string) {
+	srcs, err := p.repo.ListSources(ctx, ListSourcesOptions{})
+	if err != nil {
+		p.logger.LogError(ctx, "Could not get the name of source %s:%v", srcID, err)
+		return srcID, p.cfg.Prefix + "/" + variablePathSegment(srcID)
+	}
+	name := srcID
+	for _, src := range srcs {
+		if src.ID == srcID {
+			name = src.Name
+		}
+	}
+	segment := variablePathSegment(name)
+	for _, src := range srcs {
+		if src.ID != srcID && variablePathSegment(src.Name) == segment {
+			segment += "-" + variablePathSegment(srcID)
+			break
+		}
+	}
+	return name, p.cfg.Prefix + "/" + segment
+}
+
+// RemoveOrphans deletes the variables of sources that no longer exist, e.g. deleted while nomad-ops was down
+func (p *StatusPublisher) RemoveOrphans(ctx context.Context) error {
+	// the variables are listed first, so variables of sources created in the meantime are kept
+	vars, err := p.vars.ListVariables(ctx, p.cfg.Namespace, p.cfg.Prefix)
+	if err != nil {
+		return err
+	}
+	srcs, err := p.repo.ListSources(ctx, ListSourcesOptions{})
+	if err != nil {
+		return err
+	}
+	exists := map[string]bool{}
+	for _, src := range srcs {
+		exists[src.ID] = true
+	}
+	for path, items := range vars {
+		srcID := items["sourceId"]
+		if srcID == "" || exists[srcID] {
+			continue
+		}
+		p.logger.LogInfo(ctx, "Deleting the status variable %s of the deleted source %s", path, srcID)
+		err := p.vars.DeleteVariable(ctx, p.cfg.Namespace, path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceHealth summarizes the status of a source that finished a sync
+func sourceHealth(s *domain.SourceStatus) string {
+	switch s.Status {
+	case domain.SourceStatusStatusError:
+		return SourceHealthFailing
+	case domain.SourceStatusStatusSyncedWithError:
+		return SourceHealthDegraded
+	case domain.SourceStatusStatusOutOfSync:
+		return SourceHealthOutOfSync
+	}
+	for _, job := range s.Jobs {
+		if job.DeploymentStatus == "running" {
+			return SourceHealthProgressing
+		}
+	}
+	return SourceHealthHealthy
+}
+
+// statusItems are the items of the variable of a source
+func statusItems(srcID, name string, s *domain.SourceStatus) map[string]string {
+	items := map[string]string{
+		"sourceId": srcID,
+		"source":   name,
+		"status":   s.Status,
+		"health":   sourceHealth(s),
+		"commit":   s.AppliedCommit(),
+	}
+	if s.Message != "" {
+		items["message"] = s.Message
+	}
+	if s.ErrorCode != "" {
+		items["errorCode"] = string(s.ErrorCode)
+	}
+	if s.LastCheckTime != nil {
+		items["lastSyncTime"] = s.LastCheckTime.UTC().Format(time.RFC3339)
+	}
+	if s.LastUpdateTime != nil {
+		items["lastUpdateTime"] = s.LastUpdateTime.UTC().Format(time.RFC3339)
+	}
+	return items
+}
+
+// withoutTimes leaves out the time of the last sync, which changes with every sync
+func withoutTimes(items map[string]string) map[string]string {
+	res := map[string]string{}
+	for k, v := range items {
+		if k != "lastSyncTime" {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+var invalidVariablePathChars = regexp.MustCompile(`[^a-zA-Z0-9\-_~]`)
+
+// variablePathSegment replaces the characters Nomad does not allow in variable paths
+func variablePathSegment(name string) string {
+	return invalidVariablePathChars.ReplaceAllString(name, "_")
+}
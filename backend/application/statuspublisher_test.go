@@ -0,0 +1,147 @@
+package application
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeVariables struct {
+	vars   map[string]map[string]string
+	writes int
+}
+
+func (f *fakeVariables) PutVariable(ctx context.Context, namespace, path string, items map[string]string) error {
+	f.vars[namespace+"/"+path] = items
+	f.writes++
+	return nil
+}
+
+func (f *fakeVariables) DeleteVariable(ctx context.Context, namespace, path string) error {
+	delete(f.vars, namespace+"/"+path)
+	return nil
+}
+
+func (f *fakeVariables) ListVariables(ctx context.Context, namespace, prefix string) (map[string]map[string]string, error) {
+	res := map[string]map[string]string{}
+	for k, items := range f.vars {
+		if strings.HasPrefix(k, namespace+"/"+prefix+"/") {
+			res[strings.TrimPrefix(k, namespace+"/")] = items
+		}
+	}
+	return res, nil
+}
+
+func TestStatusPublisher(t *testing.T) {
+	ctx := context.Background()
+	store := &statusStore{sources: []*domain.Source{{ID: "src", Name: "my web"}}}
+	vars := &fakeVariables{vars: map[string]map[string]string{}}
+	publisher, err := CreateStatusPublisher(ctx, log.NewSimpleLogger(false, "Test"), StatusPublisherConfig{
+		RefreshInterval: 10 * time.Minute,
+	}, store, store, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	status := func(s string, commit string) *domain.SourceStatus {
+		return &domain.SourceStatus{
+			Status:        s,
+			LastCheckTime: &now,
+			Jobs: map[string]domain.JobStatus{
+				"web": {LastAppliedCommit: commit},
+			},
+		}
+	}
+
+	publisher.publish(ctx, "src", status(domain.SourceStatusStatusSyncing, "abc"), now)
+	if vars.writes != 0 {
+		t.Fatalf("Expected running syncs not to be published, got %v", vars.vars)
+	}
+
+	publisher.publish(ctx, "src", status(domain.SourceStatusStatusSynced, "abc"), now)
+	items := vars.vars["default/nomad-ops/status/my_web"]
+	if items["health"] != SourceHealthHealthy || items["commit"] != "abc" || items["source"] != "my web" ||
+		items["lastSyncTime"] != "2026-01-01T12:00:00Z" {
+		t.Fatalf("Unexpected items %v", vars.vars)
+	}
+
+	// unchanged status is only published again after the refresh interval
+	publisher.publish(ctx, "src", status(domain.SourceStatusStatusSynced, "abc"), now.Add(time.Minute))
+	if vars.writes != 1 {
+		t.Fatalf("Expected an unchanged status not to be published, got %d writes", vars.writes)
+	}
+	publisher.publish(ctx, "src", status(domain.SourceStatusStatusError, "abc"), now.Add(2*time.Minute))
+	if vars.writes != 2 || vars.vars["default/nomad-ops/status/my_web"]["health"] != SourceHealthFailing {
+		t.Fatalf("Expected the failure to be published, got %v", vars.vars)
+	}
+
+	// renames move the variable with the next refresh
+	store.sources[0].Name = "web"
+	publisher.publish(ctx, "src", status(domain.SourceStatusStatusSynced, "def"), now.Add(15*time.Minute))
+	if _, ok := vars.vars["default/nomad-ops/status/my_web"]; ok || vars.vars["default/nomad-ops/status/web"]["commit"] != "def" {
+		t.Fatalf("Expected the variable to be moved, got %v", vars.vars)
+	}
+
+	publisher.RemoveSource(ctx, "src")
+	if len(vars.vars) != 0 {
+		t.Fatalf("Expected the variable to be deleted, got %v", vars.vars)
+	}
+}
+
+func TestStatusPublisherPaths(t *testing.T) {
+	ctx := context.Background()
+	store := &statusStore{sources: []*domain.Source{{ID: "a", Name: "a.b"}, {ID: "b", Name: "a_b"}, {ID: "c", Name: "web"}}}
+	vars := &fakeVariables{vars: map[string]map[string]string{
+		// published before a restart, the source was deleted in the meantime
+		"default/nomad-ops/status/old": {"sourceId": "deleted"},
+		"default/nomad-ops/other":      {"sourceId": "deleted"},
+	}}
+	publisher, err := CreateStatusPublisher(ctx, log.NewSimpleLogger(false, "Test"), StatusPublisherConfig{}, store, store, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, id := range []string{"a", "b", "c"} {
+		publisher.publish(ctx, id, &domain.SourceStatus{Status: domain.SourceStatusStatusSynced}, now)
+	}
+	for path, id := range map[string]string{
+		"default/nomad-ops/status/a_b-a": "a",
+		"default/nomad-ops/status/a_b-b": "b",
+		"default/nomad-ops/status/web":   "c",
+	} {
+		if vars.vars[path]["sourceId"] != id {
+			t.Errorf("Expected the variable of %s at %s, got %v", id, path, vars.vars)
+		}
+	}
+
+	if err := publisher.RemoveOrphans(ctx); err != nil {
+		t.Fatalf("Could not RemoveOrphans:%v", err)
+	}
+	if _, ok := vars.vars["default/nomad-ops/status/old"]; ok || len(vars.vars) != 4 {
+		t.Errorf("Expected only the variable of the deleted source to be removed, got %v", vars.vars)
+	}
+}
+
+func TestSourceHealth(t *testing.T) {
+	for _, tc := range []struct {
+		status *domain.SourceStatus
+		health string
+	}{
+		{&domain.SourceStatus{Status: domain.SourceStatusStatusSynced}, SourceHealthHealthy},
+		{&domain.SourceStatus{Status: domain.SourceStatusStatusSynced, Jobs: map[string]domain.JobStatus{
+			"web": {DeploymentStatus: "running"},
+		}}, SourceHealthProgressing},
+		{&domain.SourceStatus{Status: domain.SourceStatusStatusOutOfSync}, SourceHealthOutOfSync},
+		{&domain.SourceStatus{Status: domain.SourceStatusStatusSyncedWithError}, SourceHealthDegraded},
+		{&domain.SourceStatus{Status: domain.SourceStatusStatusError}, SourceHealthFailing},
+	} {
+		if h := sourceHealth(tc.status); h != tc.health {
+			t.Errorf("Expected %s for %s, got %s", tc.health, tc.status.Status, h)
+		}
+	}
+}
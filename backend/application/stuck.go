@@ -0,0 +1,141 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// StuckDeployments detects running deployments that make no progress, instead of waiting on them forever
+type StuckDeployments struct {
+	// Timeout is the time a running deployment may go without gaining a healthy allocation, zero disables the detection
+	Timeout time.Duration
+	// Fail fails stuck deployments in Nomad, which then reverts or stops them according to the update block of the job
+	Fail bool
+}
+
+// DeploymentFailer fails a deployment of a job of the source in Nomad
+type DeploymentFailer interface {
+	FailDeployment(ctx context.Context, src *domain.Source, deploymentID string) error
+}
+
+// trackDeploymentProgress sets the time the running deployment of the job last made progress,
+// which is the time it was first seen or its number of healthy allocations last increased
+func trackDeploymentProgress(previous domain.JobStatus, status *domain.JobStatus, now time.Time) {
+	if status.DeploymentStatus != "running" || status.DeploymentID == "" {
+		status.DeploymentProgressTime = nil
+		return
+	}
+	if previous.DeploymentID == status.DeploymentID && previous.DeploymentProgressTime != nil &&
+		status.DeploymentHealthyAllocs <= previous.DeploymentHealthyAllocs {
+		status.DeploymentProgressTime = previous.DeploymentProgressTime
+		return
+	}
+	status.DeploymentProgressTime = toTimePtr(now)
+}
+
+// stuckJobs returns the names of the jobs whose running deployment made no progress within the timeout, sorted
+func stuckJobs(status *domain.SourceStatus, timeout time.Duration, now time.Time) []string {
+	names := []string{}
+	if timeout <= 0 {
+		return names
+	}
+	for name, job := range status.Jobs {
+		if job.DeploymentStatus != "running" || job.DeploymentProgressTime == nil {
+			continue
+		}
+		if now.Sub(*job.DeploymentProgressTime) > timeout {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkStuckDeployments fails the status if a deployment of the source made no progress within the timeout.
+// Stuck deployments are failed in Nomad if configured and notified once, notified holds the IDs of the
+// deployments notified before and the IDs of the currently stuck ones are returned.
+func (w *RepoWatcher) checkStuckDeployments(ctx context.Context, wi *WatchInfo, gitInfo GitInfo,
+	notified map[string]bool, now time.Time) map[string]bool {
+
+	cfg := w.cfg.StuckDeployments
+	status := wi.Source.Status
+	stuck := map[string]bool{}
+	var msgs []string
+	for _, name := range stuckJobs(status, cfg.Timeout, now) {
+		job := status.Jobs[name]
+		stuck[job.DeploymentID] = true
+		msg := fmt.Sprintf("Deployment of job %s made no progress for %v", name, cfg.Timeout)
+		if notified[job.DeploymentID] {
+			msgs = append(msgs, msg)
+			continue
+		}
+		if cfg.Fail && w.cfg.DeploymentFailer != nil {
+			err := w.cfg.DeploymentFailer.FailDeployment(ctx, wi.Source, job.DeploymentID)
+			if err != nil {
+				w.logger.LogError(ctx, "Could not fail stuck deployment %s of %s:%v", job.DeploymentID, name, err)
+				msg = fmt.Sprintf("%s, could not fail it: %v", msg, err)
+			} else {
+				w.logger.LogInfo(ctx, "Failed stuck deployment %s of %s", job.DeploymentID, name)
+				msg += ", failed it"
+				job.DeploymentStatus = "failed"
+				status.Jobs[name] = job
+			}
+		}
+		msgs = append(msgs, msg)
+		w.notifyStuckDeployment(ctx, wi, gitInfo, name, job, msg)
+	}
+	if len(msgs) > 0 {
+		status.Status = domain.SourceStatusStatusError
+		status.Message = strings.Join(msgs, "; ")
+		status.ErrorCode = domain.ErrorCodeDeploymentStuck
+	}
+	return stuck
+}
+
+func (w *RepoWatcher) notifyStuckDeployment(ctx context.Context, wi *WatchInfo, gitInfo GitInfo,
+	name string, job domain.JobStatus, msg string) {
+
+	infos := []NotifyAdditionalInfos{
+		{
+			Header: "Git-Url",
+			Text:   wi.Source.URL,
+		},
+		{
+			Header: "Git-Rev",
+			Text:   wi.Source.Branch,
+		},
+		{
+			Header: "Git-Repo-Path",
+			Text:   wi.Source.Path,
+		},
+		{
+			Header: "Nomad-Namespace",
+			Text:   job.Namespace,
+		},
+		{
+			Header: "Nomad-Deployment",
+			Text:   job.DeploymentID,
+		},
+	}
+	if job.Links != nil && job.Links.Deployment != "" {
+		infos = append(infos, NotifyAdditionalInfos{
+			Header: "Nomad-UI " + name,
+			Text:   job.Links.Deployment,
+		})
+	}
+	err := w.notifier.Notify(ctx, NotifyOptions{
+		Source:  wi.Source,
+		GitInfo: gitInfo,
+		Type:    NotificationError,
+		Message: msg,
+		Infos:   infos,
+	})
+	if err != nil {
+		w.logger.LogError(ctx, "Could not notify:%v", err)
+	}
+}
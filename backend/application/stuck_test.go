@@ -0,0 +1,108 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeDeploymentFailer struct {
+	failed []string
+}
+
+func (f *fakeDeploymentFailer) FailDeployment(ctx context.Context, src *domain.Source, deploymentID string) error {
+	f.failed = append(f.failed, deploymentID)
+	return nil
+}
+
+func TestTrackDeploymentProgress(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	now := time.Now()
+	previous := domain.JobStatus{
+		DeploymentStatus:        "running",
+		DeploymentID:            "d1",
+		DeploymentHealthyAllocs: 1,
+		DeploymentProgressTime:  &start,
+	}
+
+	status := domain.JobStatus{DeploymentStatus: "running", DeploymentID: "d1", DeploymentHealthyAllocs: 1}
+	trackDeploymentProgress(previous, &status, now)
+	if status.DeploymentProgressTime == nil || !status.DeploymentProgressTime.Equal(start) {
+		t.Errorf("Expected no progress without new healthy allocations, got %v", status.DeploymentProgressTime)
+	}
+
+	status = domain.JobStatus{DeploymentStatus: "running", DeploymentID: "d1", DeploymentHealthyAllocs: 2}
+	trackDeploymentProgress(previous, &status, now)
+	if status.DeploymentProgressTime == nil || !status.DeploymentProgressTime.Equal(now) {
+		t.Errorf("Expected progress with a new healthy allocation, got %v", status.DeploymentProgressTime)
+	}
+
+	status = domain.JobStatus{DeploymentStatus: "running", DeploymentID: "d2"}
+	trackDeploymentProgress(previous, &status, now)
+	if status.DeploymentProgressTime == nil || !status.DeploymentProgressTime.Equal(now) {
+		t.Errorf("Expected a new deployment to start over, got %v", status.DeploymentProgressTime)
+	}
+
+	status = domain.JobStatus{DeploymentStatus: "successful", DeploymentID: "d1", DeploymentHealthyAllocs: 1}
+	trackDeploymentProgress(previous, &status, now)
+	if status.DeploymentProgressTime != nil {
+		t.Errorf("Expected no progress time for a finished deployment, got %v", status.DeploymentProgressTime)
+	}
+}
+
+func TestCheckStuckDeployments(t *testing.T) {
+	ctx := context.Background()
+	n := &recordingNotifier{}
+	failer := &fakeDeploymentFailer{}
+	w, err := CreateRepoWatcher(ctx, log.NewSimpleLogger(false, "Test"), RepoWatcherConfig{
+		StuckDeployments: StuckDeployments{Timeout: 10 * time.Minute},
+		DeploymentFailer: failer,
+	}, nil, nil, n, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateRepoWatcher:%v", err)
+	}
+	now := time.Now()
+	stale := now.Add(-time.Hour)
+	recent := now.Add(-time.Minute)
+	wi := &WatchInfo{
+		ctx: ctx,
+		Source: &domain.Source{
+			ID: "src",
+			Status: &domain.SourceStatus{
+				Status: domain.SourceStatusStatusSynced,
+				Jobs: map[string]domain.JobStatus{
+					"web": {DeploymentStatus: "running", DeploymentID: "d1", DeploymentProgressTime: &stale},
+					"api": {DeploymentStatus: "running", DeploymentID: "d2", DeploymentProgressTime: &recent},
+				},
+			},
+		},
+	}
+
+	notified := w.checkStuckDeployments(ctx, wi, GitInfo{}, nil, now)
+	status := wi.Source.Status
+	if status.Status != domain.SourceStatusStatusError || status.ErrorCode != domain.ErrorCodeDeploymentStuck ||
+		status.Message != "Deployment of job web made no progress for 10m0s" {
+		t.Fatalf("Expected the sync to fail for the stuck deployment, got %+v", status)
+	}
+	if len(n.sent) != 1 || n.sent[0].Type != NotificationError || len(failer.failed) != 0 {
+		t.Fatalf("Expected a notification without failing the deployment, got %v %v", n.sent, failer.failed)
+	}
+
+	// notified once
+	notified = w.checkStuckDeployments(ctx, wi, GitInfo{}, notified, now)
+	if len(n.sent) != 1 || !notified["d1"] {
+		t.Fatalf("Expected the stuck deployment to be notified once, got %d", len(n.sent))
+	}
+
+	w.cfg.StuckDeployments.Fail = true
+	w.checkStuckDeployments(ctx, wi, GitInfo{}, nil, now)
+	if len(failer.failed) != 1 || failer.failed[0] != "d1" || status.Jobs["web"].DeploymentStatus != "failed" {
+		t.Errorf("Expected the stuck deployment to be failed, got %v", failer.failed)
+	}
+	if status.Message != "Deployment of job web made no progress for 10m0s, failed it" {
+		t.Errorf("Unexpected message %s", status.Message)
+	}
+}
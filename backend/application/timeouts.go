@@ -0,0 +1,71 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// PhaseTimeouts bound the phases of a sync, a zero duration does not bound the phase
+type PhaseTimeouts struct {
+	// GitFetch bounds a single clone, pull or fetch of the repository of a source or of its bundles
+	GitFetch time.Duration
+	// Render bounds rendering the jobs of a source with a renderer
+	Render time.Duration
+	// Parse bounds parsing a single job file
+	Parse time.Duration
+	// Plan bounds planning a single job
+	Plan time.Duration
+	// Register bounds registering a single job
+	Register time.Duration
+	// DeploymentWait bounds the time the deployments of a source may run after a sync before the sync fails
+	DeploymentWait time.Duration
+}
+
+func (t PhaseTimeouts) of(phase domain.ReconcilePhase) time.Duration {
+	switch phase {
+	case domain.ReconcilePhaseFetch:
+		return t.GitFetch
+	case domain.ReconcilePhaseRender:
+		return t.Render
+	case domain.ReconcilePhaseParse:
+		return t.Parse
+	case domain.ReconcilePhasePlan:
+		return t.Plan
+	case domain.ReconcilePhaseRegister:
+		return t.Register
+	case domain.ReconcilePhaseDeploymentWait:
+		return t.DeploymentWait
+	}
+	return 0
+}
+
+type phaseTimeoutsKey struct{}
+
+// WithPhaseTimeouts attaches the timeouts to the context of a sync
+func WithPhaseTimeouts(ctx context.Context, t PhaseTimeouts) context.Context {
+	return context.WithValue(ctx, phaseTimeoutsKey{}, t)
+}
+
+// RunPhase runs fn bounded by the timeout of the phase attached to ctx, unbounded without timeouts.
+// If the phase did not finish in time the error is a domain.PhaseTimeoutError with the code TIMEOUT.
+func RunPhase(ctx context.Context, phase domain.ReconcilePhase, fn func(ctx context.Context) error) error {
+	t, _ := ctx.Value(phaseTimeoutsKey{}).(PhaseTimeouts)
+	timeout := t.of(phase)
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	phaseCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := fn(phaseCtx)
+	// cancelled syncs are no timeouts
+	if err != nil && phaseCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return domain.WithErrorCode(domain.ErrorCodeTimeout, &domain.PhaseTimeoutError{
+			Phase:   phase,
+			Timeout: timeout,
+			Err:     err,
+		})
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestRunPhase(t *testing.T) {
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ctx := WithPhaseTimeouts(context.Background(), PhaseTimeouts{GitFetch: 10 * time.Millisecond})
+
+	err := RunPhase(ctx, domain.ReconcilePhaseFetch, hang)
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeTimeout || domain.TimedOutPhaseOf(err) != domain.ReconcilePhaseFetch {
+		t.Fatalf("Expected the fetch to time out, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the cause to be kept, got %v", err)
+	}
+
+	// phases without timeout are not bounded
+	expected := errors.New("failed")
+	err = RunPhase(ctx, domain.ReconcilePhasePlan, func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); ok {
+			t.Errorf("Expected no deadline")
+		}
+		return expected
+	})
+	if err != expected {
+		t.Errorf("Expected the error of the phase, got %v", err)
+	}
+
+	// cancelled syncs did not time out
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = RunPhase(cancelled, domain.ReconcilePhaseFetch, hang)
+	if domain.TimedOutPhaseOf(err) != "" {
+		t.Errorf("Expected no timeout for a cancelled sync, got %v", err)
+	}
+}
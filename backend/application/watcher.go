@@ -2,13 +2,17 @@ package application
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"path"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
+	"github.com/hashicorp/nomad/api"
 	giturls "github.com/whilp/git-urls"
 
 	"github.com/nomad-ops/nomad-ops/backend/domain"
@@ -26,9 +30,144 @@ type WatchInfo struct {
 	Source     *domain.Source
 	Reconciler ReconcilerFunc
 	syncFunc   func(context.Context, SyncSourceOptions) error
-	syncCh     chan SyncSourceOptions
+	// syncCh signals that pendingSync got set
+	syncCh     chan struct{}
 	updateFunc func(context.Context, *domain.Source) error
-	updateCh   chan *domain.Source
+	updateCh   chan sourceUpdate
+
+	pendingLock  sync.Mutex
+	pendingSync  *SyncSourceOptions
+	pendingSince time.Time
+
+	runningLock   sync.Mutex
+	running       *domain.Reconcile
+	cancelRunning context.CancelFunc
+}
+
+// requestSync queues a sync of the source. Syncs requested while another one is pending are merged,
+// so a running sync is followed by exactly one more sync no matter how often it was triggered.
+func (wi *WatchInfo) requestSync(opts SyncSourceOptions) {
+	wi.pendingLock.Lock()
+	defer wi.pendingLock.Unlock()
+	if wi.pendingSync == nil {
+		wi.pendingSync = &SyncSourceOptions{}
+		wi.pendingSince = time.Now()
+		// buffered, there is at most one signal per pending sync
+		wi.syncCh <- struct{}{}
+	}
+	wi.pendingSync.ForceRestart = wi.pendingSync.ForceRestart || opts.ForceRestart
+	wi.pendingSync.IgnoreFreeze = wi.pendingSync.IgnoreFreeze || opts.IgnoreFreeze
+	if opts.Action != nil {
+		wi.pendingSync.Action = opts.Action
+	}
+}
+
+// pending returns a copy of the pending sync and the time it was first requested, false if none is pending
+func (wi *WatchInfo) pending() (SyncSourceOptions, time.Time, bool) {
+	wi.pendingLock.Lock()
+	defer wi.pendingLock.Unlock()
+	if wi.pendingSync == nil {
+		return SyncSourceOptions{}, time.Time{}, false
+	}
+	return *wi.pendingSync, wi.pendingSince, true
+}
+
+func (wi *WatchInfo) takePendingSync() SyncSourceOptions {
+	wi.pendingLock.Lock()
+	defer wi.pendingLock.Unlock()
+	opts := wi.pendingSync
+	wi.pendingSync = nil
+	if opts == nil {
+		return SyncSourceOptions{}
+	}
+	return *opts
+}
+
+// startReconcile marks a sync as running and returns its context, which is cancelled by cancelReconcile
+func (wi *WatchInfo) startReconcile(action *Action) context.Context {
+	ctx, cancel := context.WithCancel(wi.ctx)
+	wi.runningLock.Lock()
+	defer wi.runningLock.Unlock()
+	wi.running = &domain.Reconcile{
+		SourceID:   wi.Source.ID,
+		SourceName: wi.Source.Name,
+		Phase:      domain.ReconcilePhaseFetch,
+		StartedAt:  time.Now(),
+	}
+	if action != nil {
+		wi.running.ActionID = action.ID
+	}
+	wi.cancelRunning = cancel
+	return withJobProgress(ctx, wi.setCurrentJob)
+}
+
+func (wi *WatchInfo) setPhase(phase domain.ReconcilePhase) {
+	wi.runningLock.Lock()
+	defer wi.runningLock.Unlock()
+	if wi.running != nil {
+		wi.running.Phase = phase
+		wi.running.CurrentJob = ""
+	}
+}
+
+func (wi *WatchInfo) setCurrentJob(job string) {
+	wi.runningLock.Lock()
+	defer wi.runningLock.Unlock()
+	if wi.running != nil {
+		wi.running.CurrentJob = job
+	}
+}
+
+func (wi *WatchInfo) finishReconcile() {
+	wi.runningLock.Lock()
+	defer wi.runningLock.Unlock()
+	if wi.cancelRunning != nil {
+		wi.cancelRunning()
+	}
+	wi.running = nil
+	wi.cancelRunning = nil
+}
+
+// cancelReconcile cancels the running sync, returns false if there is none
+func (wi *WatchInfo) cancelReconcile() bool {
+	wi.runningLock.Lock()
+	defer wi.runningLock.Unlock()
+	if wi.running == nil {
+		return false
+	}
+	wi.cancelRunning()
+	return true
+}
+
+// runningReconcile returns a copy of the running sync or nil
+func (wi *WatchInfo) runningReconcile(now time.Time) *domain.Reconcile {
+	wi.runningLock.Lock()
+	defer wi.runningLock.Unlock()
+	if wi.running == nil {
+		return nil
+	}
+	r := *wi.running
+	r.ElapsedSeconds = now.Sub(r.StartedAt).Seconds()
+	return &r
+}
+
+type jobProgressKey struct{}
+
+// withJobProgress attaches a callback to the context that is informed about the job currently processed
+func withJobProgress(ctx context.Context, fn func(job string)) context.Context {
+	return context.WithValue(ctx, jobProgressKey{}, fn)
+}
+
+// reportJobProgress informs the callback of the context, if any, that the job is processed now
+func reportJobProgress(ctx context.Context, job string) {
+	if fn, ok := ctx.Value(jobProgressKey{}).(func(job string)); ok {
+		fn(job)
+	}
+}
+
+type sourceUpdate struct {
+	source *domain.Source
+	action *Action
 }
 
 type RepoWatcher struct {
@@ -41,12 +180,31 @@ type RepoWatcher struct {
 	watchList           map[string]*WatchInfo
 	notifier            Notifier
 	vaultRepo           VaultTokenRepo
+	syncedHooks         []func(context.Context, *domain.Source)
 }
 
 type RepoWatcherConfig struct {
 	Interval        time.Duration
 	ErrorRetryCount int
 	AppName         string
+	// Freeze blocks syncs during change freezes, optional
+	Freeze FreezeChecker
+	// PendingSyncs persists requested syncs until they ran, so they are replayed after a restart, optional
+	PendingSyncs PendingSyncRepo
+	// PhaseTimeouts bound the phases of every sync
+	PhaseTimeouts PhaseTimeouts
+	// StuckDeployments detects deployments that make no progress
+	StuckDeployments StuckDeployments
+	// DeploymentFailer fails stuck deployments in Nomad, optional
+	DeploymentFailer DeploymentFailer
+}
+
+// PendingSyncRepo stores the pending sync of every source
+type PendingSyncRepo interface {
+	SavePendingSync(ctx context.Context, p *domain.PendingSync) error
+	// DeletePendingSync deletes the pending sync of the source if it was last requested before the time
+	DeletePendingSync(ctx context.Context, srcID string, before time.Time) error
+	ListPendingSyncs(ctx context.Context) ([]*domain.PendingSync, error)
 }
 
 type SourceStatusPatcher interface {
@@ -71,11 +229,91 @@ func CreateRepoWatcher(ctx context.Context,
 		vaultRepo:           vaultRepo,
 	}
 
+	metrics.GetOrCreateGauge(fmt.Sprintf(`nomad_ops_pending_syncs{app="%s"}`, cfg.AppName), func() float64 {
+		n, _ := t.pendingSyncs(time.Now())
+		return float64(n)
+	})
+	metrics.GetOrCreateGauge(fmt.Sprintf(`nomad_ops_oldest_pending_sync_seconds{app="%s"}`, cfg.AppName), func() float64 {
+		_, age := t.pendingSyncs(time.Now())
+		return age.Seconds()
+	})
+
 	return t, nil
 }
 
+// OnSynced registers a callback that is invoked after every sync that left a source in sync
+// without running deployments, e.g. to promote its commit
+func (w *RepoWatcher) OnSynced(fn func(ctx context.Context, src *domain.Source)) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.syncedHooks = append(w.syncedHooks, fn)
+}
+
+func (w *RepoWatcher) synced(ctx context.Context, src *domain.Source) {
+	w.lock.Lock()
+	hooks := w.syncedHooks
+	w.lock.Unlock()
+	for _, fn := range hooks {
+		fn(ctx, src)
+	}
+}
+
+// pendingSyncs returns the number of sources waiting for a sync and the age of the oldest request
+func (w *RepoWatcher) pendingSyncs(now time.Time) (int, time.Duration) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	n := 0
+	var oldest time.Duration
+	for _, wi := range w.watchList {
+		wi.pendingLock.Lock()
+		if wi.pendingSync != nil {
+			n++
+			if age := now.Sub(wi.pendingSince); age > oldest {
+				oldest = age
+			}
+		}
+		wi.pendingLock.Unlock()
+	}
+	return n, oldest
+}
+
+// ListReconciles returns the currently running syncs, the longest running first
+func (w *RepoWatcher) ListReconciles(ctx context.Context) []*domain.Reconcile {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	now := time.Now()
+	res := []*domain.Reconcile{}
+	for _, wi := range w.watchList {
+		if r := wi.runningReconcile(now); r != nil {
+			res = append(res, r)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].StartedAt.Before(res[j].StartedAt)
+	})
+	return res
+}
+
+// CancelReconcile cancels the running sync of the source. The source keeps being watched.
+func (w *RepoWatcher) CancelReconcile(ctx context.Context, id string) error {
+	w.lock.Lock()
+	wi, ok := w.watchList[id]
+	w.lock.Unlock()
+	if !ok || !wi.cancelReconcile() {
+		return errors.ErrNotFound
+	}
+	w.logger.LogInfo(ctx, "Cancelled running sync of %s %s - %s", wi.Source.Name, wi.Source.URL, wi.Source.Path)
+	return nil
+}
+
 type SyncSourceOptions struct {
 	ForceRestart bool
+	// IgnoreFreeze applies the changes during a change freeze
+	IgnoreFreeze bool
+	// Action links the resulting Nomad mutations to a user action, optional
+	Action *Action
+	// Trigger requested the sync, sources that disabled the trigger are not synced
+	Trigger domain.SyncTrigger
 }
 
 func (w *RepoWatcher) SyncSourceByID(ctx context.Context, id string, opts SyncSourceOptions) error {
@@ -85,6 +323,10 @@ func (w *RepoWatcher) SyncSourceByID(ctx context.Context, id string, opts SyncSo
 	if !ok {
 		return errors.ErrNotFound
 	}
+	if !wi.Source.TriggerEnabled(opts.Trigger) {
+		w.logger.LogInfo(ctx, "Not syncing %s, trigger %s is disabled", wi.Source.Name, opts.Trigger)
+		return nil
+	}
 	w.logger.LogInfo(ctx, "Syncing repo %s on branch %s", wi.Source.URL, wi.Source.Branch)
 	err := wi.syncFunc(ctx, opts)
 	if err != nil {
@@ -102,15 +344,9 @@ func (w *RepoWatcher) SyncSource(ctx context.Context, repo, branch string, opts
 			continue
 		}
 
-		w.logger.LogTrace(ctx, "Parsing URL %s", iwi.Source.URL)
-		u, err := giturls.Parse(iwi.Source.URL)
-		if err != nil {
-			w.logger.LogError(ctx, "Could not parse url:%v - %v", iwi.Source.URL, err)
-			continue
-		}
-		w.logger.LogTrace(ctx, "Raw Path: %s", u.RawPath)
-		w.logger.LogTrace(ctx, "Path: %s", u.Path)
-		if u.Path != fmt.Sprintf("%s.git", repo) {
+		name := RepoName(iwi.Source.URL)
+		w.logger.LogTrace(ctx, "Repo of %s: %s", iwi.Source.URL, name)
+		if name == "" || !strings.EqualFold(name, strings.TrimSuffix(repo, ".git")) {
 			continue
 		}
 		cpy := iwi
@@ -121,6 +357,10 @@ func (w *RepoWatcher) SyncSource(ctx context.Context, repo, branch string, opts
 		return errors.ErrNotFound
 	}
 	for _, wi := range wis {
+		if !wi.Source.TriggerEnabled(opts.Trigger) {
+			w.logger.LogInfo(ctx, "Not syncing %s, trigger %s is disabled", wi.Source.Name, opts.Trigger)
+			continue
+		}
 		err := wi.syncFunc(ctx, opts)
 		if err != nil {
 			return err
@@ -129,6 +369,101 @@ func (w *RepoWatcher) SyncSource(ctx context.Context, repo, branch string, opts
 	return nil
 }
 
+// PreviewSource plans the desired state against the cluster without applying any change,
+// e.g. to show the effect of a pull request
+func (w *RepoWatcher) PreviewSource(ctx context.Context, src *domain.Source, desiredState *DesiredState) (*ChangeInfo, error) {
+	w.lock.Lock()
+	wi, ok := w.watchList[src.ID]
+	w.lock.Unlock()
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+
+	cpy := *src
+	cpy.Paused = true
+	cpy.CreateNamespace = false
+	cpy.Status = &domain.SourceStatus{}
+
+	if cpy.VaultTokenID != "" {
+		t, err := w.vaultRepo.GetVaultToken(ctx, cpy.VaultTokenID)
+		if err != nil {
+			return nil, err
+		}
+		for k := range desiredState.Jobs {
+			desiredState.Jobs[k].VaultToken = &t.Value
+		}
+	}
+	err := w.applyOverrides(ctx, &cpy, desiredState)
+	if err != nil {
+		return nil, err
+	}
+	return wi.Reconciler(ctx, &cpy, desiredState, false)
+}
+
+// RenderDesiredState returns the jobs of the desired state as they are submitted for the source, keyed by job name,
+// in a deterministic format for golden-file tests. Defaults are filled in, secrets like the vault token are left out.
+func (w *RepoWatcher) RenderDesiredState(ctx context.Context, src *domain.Source, desiredState *DesiredState) ([]byte, error) {
+	err := w.applyOverrides(ctx, src, desiredState)
+	if err != nil {
+		return nil, err
+	}
+	jobs := map[string]*api.Job{}
+	for name, j := range desiredState.Jobs {
+		job := *j.Job
+		job.Canonicalize()
+		job.VaultToken = nil
+		jobs[name] = &job
+	}
+	// maps are marshalled with sorted keys
+	b, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// RepoName normalizes a clone url to the name of the repository, so sources can be matched with webhooks:
+// owner/repo for GitHub and Bitbucket, PROJECT/repo for Bitbucket Data Center and org/project/repo for Azure DevOps.
+// It returns an empty string for invalid urls.
+func RepoName(rawURL string) string {
+	u, err := giturls.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	p := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "ssh.dev.azure.com" || strings.HasPrefix(host, "vs-ssh.visualstudio.com"):
+		// v3/org/project/repo
+		p = strings.TrimPrefix(p, "v3/")
+	case host == "dev.azure.com":
+		// org/project/_git/repo
+		p = strings.Replace(p, "/_git/", "/", 1)
+	case strings.HasSuffix(host, ".visualstudio.com"):
+		// [DefaultCollection/]project/_git/repo on the org subdomain
+		p = strings.TrimPrefix(p, "DefaultCollection/")
+		p = strings.TrimSuffix(host, ".visualstudio.com") + "/" + strings.Replace(p, "/_git/", "/", 1)
+	default:
+		// http urls of Bitbucket Data Center
+		p = strings.TrimPrefix(p, "scm/")
+	}
+	return p
+}
+
+// TouchesPath checks if any of the changed files of a pull request is the path of the source or within it
+func TouchesPath(files []string, srcPath string) bool {
+	p := path.Clean(strings.TrimPrefix(srcPath, "/"))
+	if p == "." || p == "" {
+		return len(files) > 0
+	}
+	for _, f := range files {
+		if f == p || strings.HasPrefix(f, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 func (w *RepoWatcher) UpdateSource(ctx context.Context, src *domain.Source) error {
 	w.logger.LogInfo(ctx, "Updating source %s", src.Name)
 	w.lock.Lock()
@@ -151,6 +486,12 @@ func (w *RepoWatcher) applyOverrides(ctx context.Context, src *domain.Source, de
 			dcs := strings.Split(src.DataCenter, ",")
 			v.Datacenters = dcs
 		}
+		if len(v.Datacenters) == 0 && src.DefaultDataCenters != "" {
+			v.Datacenters = strings.Split(src.DefaultDataCenters, ",")
+		}
+		if src.DefaultNodePool != "" && (v.NodePool == nil || *v.NodePool == "") {
+			v.NodePool = &src.DefaultNodePool
+		}
 		if src.Namespace != "" {
 			v.Namespace = &src.Namespace
 		}
@@ -173,21 +514,19 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 		cancel:     cancel,
 		Reconciler: cb,
 		Source:     origSrc,
-		syncCh:     make(chan SyncSourceOptions),
+		syncCh:     make(chan struct{}, 1),
 		syncFunc: func(ctx context.Context, opts SyncSourceOptions) error {
-			select {
-			case wi.syncCh <- opts:
-				return nil
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-workerCtx.Done():
+			if workerCtx.Err() != nil {
 				return workerCtx.Err()
 			}
+			wi.requestSync(opts)
+			w.savePendingSync(ctx, wi)
+			return nil
 		},
-		updateCh: make(chan *domain.Source),
+		updateCh: make(chan sourceUpdate),
 		updateFunc: func(ctx context.Context, src *domain.Source) error {
 			select {
-			case wi.updateCh <- src:
+			case wi.updateCh <- sourceUpdate{source: src, action: ActionFromContext(ctx)}:
 				return nil
 			case <-ctx.Done():
 				return ctx.Err()
@@ -245,7 +584,31 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 
 		waitTime := w.cfg.Interval
 
+		activeSyncs := metrics.GetOrCreateCounter(fmt.Sprintf(`nomad_ops_active_syncs_gauge{app="%s"}`, w.cfg.AppName))
+		active := false
+		defer func() {
+			if active {
+				activeSyncs.Dec()
+			}
+			wi.finishReconcile()
+		}()
+
+		// time the last sync took the pending sync, the persisted one is deleted once the sync finished
+		var takenAt time.Time
+		// time the deployments of the source are pending since
+		var deployingSince time.Time
+		// the stuck deployments of the source that were notified already
+		var stuckNotified map[string]bool
 		for {
+			if active {
+				activeSyncs.Dec()
+				active = false
+			}
+			wi.finishReconcile()
+			if !takenAt.IsZero() {
+				w.deletePendingSync(wi.ctx, wi.Source.ID, takenAt)
+				takenAt = time.Time{}
+			}
 			select {
 			case <-wi.ctx.Done():
 				return
@@ -260,16 +623,43 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 			}
 			firstRun = false
 			restart := false
+			ignoreFreeze := false
+			var action *Action
+			// sources with polling disabled wait for a requested sync
+			var poll <-chan time.Time
+			if wi.Source.TriggerEnabled(domain.SyncTriggerPoll) {
+				poll = time.After(waitTime)
+			}
 			select {
-			case <-time.After(waitTime):
-			case opts := <-wi.syncCh:
+			case <-poll:
+			case <-wi.syncCh:
+				opts := wi.takePendingSync()
+				takenAt = time.Now()
 				restart = opts.ForceRestart
-			case src := <-wi.updateCh:
+				ignoreFreeze = opts.IgnoreFreeze
+				action = opts.Action
+			case u := <-wi.updateCh:
 				w.logger.LogInfo(wi.ctx, "Updating watch on %s %s - %s", wi.Source.Name, wi.Source.URL, wi.Source.Path)
-				wi.Source = src
+				wi.Source = u.source
+				action = u.action
 			case <-wi.ctx.Done():
 				return
 			}
+			activeSyncs.Inc()
+			active = true
+			// syncs requested until now are covered by this sync
+			select {
+			case <-wi.syncCh:
+				opts := wi.takePendingSync()
+				takenAt = time.Now()
+				restart = restart || opts.ForceRestart
+				ignoreFreeze = ignoreFreeze || opts.IgnoreFreeze
+				if opts.Action != nil {
+					action = opts.Action
+				}
+			default:
+			}
+			syncCtx := WithPhaseTimeouts(wi.startReconcile(action), w.cfg.PhaseTimeouts)
 			wi.Source.Status.Status = domain.SourceStatusStatusSyncing
 			wi.Source.Status.Message = "Syncing"
 
@@ -278,12 +668,14 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 				w.logger.LogError(ctx, "Could not SetSourceStatus on %s:%v", wi.Source.ID, err)
 			}
 
-			desiredState, err := w.dsw.FetchDesiredState(wi.ctx, wi.Source)
+			desiredState, err := w.dsw.FetchDesiredState(syncCtx, wi.Source)
 			if err != nil {
 				w.logger.LogError(wi.ctx, "Could not FetchDesiredState: %v - %v - %v", err, wi.Source.URL, wi.Source.Path)
 				err = w.sourceStatusPatcher.SetSourceStatus(wi.Source.ID, &domain.SourceStatus{
 					Status:        domain.SourceStatusStatusError,
 					Message:       err.Error(),
+					ErrorCode:     domain.ErrorCodeOf(err, ""),
+					TimedOutPhase: domain.TimedOutPhaseOf(err),
 					LastCheckTime: toTimePtr(time.Now()),
 				})
 				if err != nil {
@@ -338,13 +730,15 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 				continue
 			}
 
+			wi.setPhase(domain.ReconcilePhasePrepare)
 			if wi.Source.VaultTokenID != "" {
-				t, err := w.vaultRepo.GetVaultToken(ctx, wi.Source.VaultTokenID)
+				t, err := w.vaultRepo.GetVaultToken(syncCtx, wi.Source.VaultTokenID)
 				if err != nil {
 					w.logger.LogError(wi.ctx, "Could not GetVaultToken: %v - %v - %v", err, wi.Source.URL, wi.Source.Path)
 					err = w.sourceStatusPatcher.SetSourceStatus(wi.Source.ID, &domain.SourceStatus{
 						Status:        domain.SourceStatusStatusError,
 						Message:       err.Error(),
+						ErrorCode:     domain.ErrorCodeOf(err, ""),
 						LastCheckTime: toTimePtr(time.Now()),
 					})
 					if err != nil {
@@ -401,12 +795,13 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 				}
 			}
 
-			err = w.applyOverrides(wi.ctx, wi.Source, desiredState)
+			err = w.applyOverrides(syncCtx, wi.Source, desiredState)
 			if err != nil {
 				w.logger.LogError(wi.ctx, "Could not apply overrides: %v - %v - %v", err, wi.Source.URL, wi.Source.Path)
 				err = w.sourceStatusPatcher.SetSourceStatus(wi.Source.ID, &domain.SourceStatus{
 					Status:        domain.SourceStatusStatusError,
 					Message:       err.Error(),
+					ErrorCode:     domain.ErrorCodeOf(err, ""),
 					LastCheckTime: toTimePtr(time.Now()),
 				})
 				if err != nil {
@@ -458,14 +853,27 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 				continue
 			}
 
-			changeInfo, err := wi.Reconciler(wi.ctx, wi.Source, desiredState, restart)
+			// during a change freeze the changes are only planned, like for a paused source
+			src := wi.Source
+			wi.Source.Status.Freeze = nil
+			if freeze := w.activeFreeze(time.Now(), wi.Source, ignoreFreeze); freeze != nil && !wi.Source.Paused {
+				cpy := *wi.Source
+				cpy.Paused = true
+				src = &cpy
+				wi.Source.Status.Freeze = freeze
+			}
+
+			wi.setPhase(domain.ReconcilePhaseApply)
+			changeInfo, err := wi.Reconciler(WithAction(syncCtx, action), src, desiredState, restart)
 			if err != nil {
 				w.logger.LogError(wi.ctx, "Could not Reconcile: %v - %v - %v", err, wi.Source.URL, wi.Source.Path)
-				err = w.sourceStatusPatcher.SetSourceStatus(wi.Source.ID, &domain.SourceStatus{
-					Status:        domain.SourceStatusStatusError,
-					Message:       err.Error(),
-					LastCheckTime: toTimePtr(time.Now()),
-				})
+				// the status of the jobs is kept, so the next sync can resume where this one failed
+				wi.Source.Status.Status = domain.SourceStatusStatusError
+				wi.Source.Status.Message = err.Error()
+				wi.Source.Status.ErrorCode = domain.ErrorCodeOf(err, "")
+				wi.Source.Status.TimedOutPhase = domain.TimedOutPhaseOf(err)
+				wi.Source.Status.LastCheckTime = toTimePtr(time.Now())
+				err = w.sourceStatusPatcher.SetSourceStatus(wi.Source.ID, wi.Source.Status)
 				if err != nil {
 					w.logger.LogError(ctx, "Could not SetSourceStatus on %s:%v", wi.Source.ID, err)
 				}
@@ -475,7 +883,7 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 						GitInfo: desiredState.GitInfo,
 						Type:    NotificationError,
 						Message: "Could not Reconcile",
-						Infos: []NotifyAdditionalInfos{
+						Infos: append([]NotifyAdditionalInfos{
 							{
 								Header: "Git-Url",
 								Text:   wi.Source.URL,
@@ -509,7 +917,7 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 								Text:   fmt.Sprintf("Could not Reconcile:%v", err),
 								Large:  true,
 							},
-						},
+						}, failedJobLinks(wi.Source.Status)...),
 					})
 					if err != nil {
 						w.logger.LogError(ctx, "Could not notify:%v", err)
@@ -565,7 +973,7 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 				}
 			}
 
-			if wi.Source.Paused {
+			if src.Paused {
 				wi.Source.Status.Status = domain.SourceStatusStatusSynced
 				msg := "Still in sync"
 				if len(changeInfo.Create) > 0 || len(changeInfo.Update) > 0 || len(changeInfo.Delete) > 0 {
@@ -573,21 +981,220 @@ func (w *RepoWatcher) WatchSource(ctx context.Context, origSrc *domain.Source, c
 						len(changeInfo.Create), len(changeInfo.Update), len(changeInfo.Delete))
 					wi.Source.Status.Status = domain.SourceStatusStatusOutOfSync
 				}
+				if freeze := wi.Source.Status.Freeze; freeze != nil {
+					msg = fmt.Sprintf("%s, frozen until %s", msg, freeze.End.Format(time.RFC3339))
+					if freeze.Reason != "" {
+						msg = fmt.Sprintf("%s: %s", msg, freeze.Reason)
+					}
+				}
 				wi.Source.Status.Message = msg
+				w.notifyOutOfSync(wi, desiredState, changeInfo)
+			} else {
+				// changes got applied, start over once the source is paused again
+				wi.Source.Status.OutOfSyncSummary = ""
 			}
 
-			wi.Source.Status.DetermineSyncStatus()
+			pending := wi.Source.Status.DetermineSyncStatus()
+			if !pending || src.Paused {
+				deployingSince = time.Time{}
+				stuckNotified = nil
+			} else {
+				if deployingSince.IsZero() || len(changeInfo.Create) > 0 || len(changeInfo.Update) > 0 {
+					// the sync started new deployments
+					deployingSince = time.Now()
+				}
+				w.checkDeploymentWait(wi.Source.Status, deployingSince, time.Now())
+				stuckNotified = w.checkStuckDeployments(wi.ctx, wi, desiredState.GitInfo, stuckNotified, time.Now())
+			}
 
 			err = w.sourceStatusPatcher.SetSourceStatus(wi.Source.ID, wi.Source.Status)
 			if err != nil {
 				w.logger.LogError(ctx, "Could not SetSourceStatus on %s:%v", wi.Source.ID, err)
 			}
+			if !pending && wi.Source.Status.Status == domain.SourceStatusStatusSynced {
+				w.synced(wi.ctx, wi.Source)
+			}
 		}
 	}(wi)
 
 	return nil
 }
 
+// checkDeploymentWait fails the status if the deployments of the source are pending for longer than
+// the deployment wait timeout
+func (w *RepoWatcher) checkDeploymentWait(status *domain.SourceStatus, since, now time.Time) {
+	timeout := w.cfg.PhaseTimeouts.DeploymentWait
+	if timeout <= 0 || now.Sub(since) <= timeout {
+		return
+	}
+	err := &domain.PhaseTimeoutError{
+		Phase:   domain.ReconcilePhaseDeploymentWait,
+		Timeout: timeout,
+		Err:     fmt.Errorf("%s", status.Message),
+	}
+	status.Status = domain.SourceStatusStatusError
+	status.Message = err.Error()
+	status.ErrorCode = domain.ErrorCodeTimeout
+	status.TimedOutPhase = err.Phase
+}
+
+// activeFreeze returns the change freeze blocking syncs of the source at the given time or nil
+func (w *RepoWatcher) activeFreeze(now time.Time, src *domain.Source, ignore bool) *domain.FreezeWindow {
+	if w.cfg.Freeze == nil || ignore {
+		return nil
+	}
+	return w.cfg.Freeze.ActiveFreeze(now, src.Labels)
+}
+
+// failedJobLinks returns the links to the Nomad UI of the jobs that failed to apply, in the order of their names
+func failedJobLinks(status *domain.SourceStatus) []NotifyAdditionalInfos {
+	names := []string{}
+	for name, job := range status.Jobs {
+		if job.LastError != "" && job.Links != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	infos := []NotifyAdditionalInfos{}
+	for _, name := range names {
+		infos = append(infos, NotifyAdditionalInfos{
+			Header: "Nomad-UI " + name,
+			Text:   status.Jobs[name].Links.Job,
+		})
+	}
+	return infos
+}
+
+// notifyOutOfSync sends a notification including a summary of the pending changes
+// whenever the set of changes for a paused source differs from the last notified one.
+// The last notified summary is kept in the source status, so it survives restarts.
+func (w *RepoWatcher) notifyOutOfSync(wi *WatchInfo, desiredState *DesiredState, changeInfo *ChangeInfo) {
+	summary := changeInfo.Summary()
+	if summary == wi.Source.Status.OutOfSyncSummary {
+		return
+	}
+	wi.Source.Status.OutOfSyncSummary = summary
+	if summary == "" {
+		// back in sync
+		return
+	}
+	err := w.notifier.Notify(wi.ctx, NotifyOptions{
+		Source:  wi.Source,
+		GitInfo: desiredState.GitInfo,
+		Type:    NotificationInfo,
+		Message: wi.Source.Status.Message,
+		Infos: []NotifyAdditionalInfos{
+			{
+				Header: "Git-Url",
+				Text:   wi.Source.URL,
+			},
+			{
+				Header: "Git-Rev",
+				Text:   wi.Source.Branch,
+			},
+			{
+				Header: "Git-Commit",
+				Text:   desiredState.GitInfo.GitCommit,
+			},
+			{
+				Header: "Git-Repo-Path",
+				Text:   wi.Source.Path,
+			},
+			{
+				Header: "Changes",
+				Text:   summary,
+				Large:  true,
+			},
+		},
+	})
+	if err != nil {
+		w.logger.LogError(wi.ctx, "Could not notify:%v", err)
+	}
+}
+
+// savePendingSync persists the pending sync of the source, if any
+func (w *RepoWatcher) savePendingSync(ctx context.Context, wi *WatchInfo) {
+	if w.cfg.PendingSyncs == nil {
+		return
+	}
+	opts, since, ok := wi.pending()
+	if !ok {
+		// the worker took it already
+		return
+	}
+	p := &domain.PendingSync{
+		SourceID:        wi.Source.ID,
+		ForceRestart:    opts.ForceRestart,
+		IgnoreFreeze:    opts.IgnoreFreeze,
+		RequestedAt:     since,
+		LastRequestedAt: time.Now(),
+	}
+	if opts.Action != nil {
+		p.ActionID = opts.Action.ID
+		p.ActionType = opts.Action.Type
+		p.UserID = opts.Action.UserID
+		p.Actor = opts.Action.Actor
+		p.ActionTime = opts.Action.Time
+	}
+	err := w.cfg.PendingSyncs.SavePendingSync(ctx, p)
+	if err != nil {
+		w.logger.LogError(ctx, "Could not SavePendingSync of %s:%v", wi.Source.ID, err)
+	}
+}
+
+func (w *RepoWatcher) deletePendingSync(ctx context.Context, srcID string, before time.Time) {
+	if w.cfg.PendingSyncs == nil {
+		return
+	}
+	err := w.cfg.PendingSyncs.DeletePendingSync(ctx, srcID, before)
+	if err != nil {
+		w.logger.LogError(ctx, "Could not DeletePendingSync of %s:%v", srcID, err)
+	}
+}
+
+// ReplayPendingSyncs requests the persisted syncs of the watched sources again, e.g. the ones requested
+// right before a restart, in the order they were first requested. Requests for the same source are merged,
+// the ones of sources that are not watched, i.e. on a standby that was not promoted yet, are skipped.
+func (w *RepoWatcher) ReplayPendingSyncs(ctx context.Context) error {
+	if w.cfg.PendingSyncs == nil {
+		return nil
+	}
+	pending, err := w.cfg.PendingSyncs.ListPendingSyncs(ctx)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].RequestedAt.Before(pending[j].RequestedAt)
+	})
+	replayed := 0
+	for _, p := range pending {
+		w.lock.Lock()
+		wi, ok := w.watchList[p.SourceID]
+		w.lock.Unlock()
+		if !ok {
+			continue
+		}
+		opts := SyncSourceOptions{
+			ForceRestart: p.ForceRestart,
+			IgnoreFreeze: p.IgnoreFreeze,
+		}
+		if p.ActionID != "" {
+			opts.Action = &Action{
+				ID:     p.ActionID,
+				Type:   p.ActionType,
+				UserID: p.UserID,
+				Actor:  p.Actor,
+				Time:   p.ActionTime,
+			}
+		}
+		// the persisted request is kept until the sync ran
+		wi.requestSync(opts)
+		replayed++
+	}
+	w.logger.LogInfo(ctx, "Replayed %d of %d pending syncs", replayed, len(pending))
+	return nil
+}
+
 func (w *RepoWatcher) StopSourceWatch(ctx context.Context, id string) error {
 	w.lock.Lock()
 	defer w.lock.Unlock()
@@ -0,0 +1,410 @@
+package application
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type recordingNotifier struct {
+	lock sync.Mutex
+	sent []NotifyOptions
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, opts NotifyOptions) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.sent = append(n.sent, opts)
+	return nil
+}
+
+func TestChangeInfoSummary(t *testing.T) {
+	c := &ChangeInfo{
+		DiffSummaries: map[string]string{
+			"web": "Group web: count 2 -> 3",
+			"api": JobDeletedSummary("api"),
+		},
+	}
+	expected := "api:\nJob api: deleted\n\nweb:\nGroup web: count 2 -> 3"
+	if s := c.Summary(); s != expected {
+		t.Errorf("Unexpected summary:\n%s\nexpected:\n%s", s, expected)
+	}
+
+	if s := (&ChangeInfo{}).Summary(); s != "" {
+		t.Errorf("Expected empty summary, got:%s", s)
+	}
+}
+
+func TestNotifyOutOfSync(t *testing.T) {
+	ctx := context.Background()
+	n := &recordingNotifier{}
+	w, err := CreateRepoWatcher(ctx, log.NewSimpleLogger(false, "Test"), RepoWatcherConfig{}, nil, nil, n, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateRepoWatcher:%v", err)
+	}
+	wi := &WatchInfo{
+		ctx: ctx,
+		Source: &domain.Source{
+			ID:     "src",
+			Status: &domain.SourceStatus{},
+		},
+	}
+	desired := &DesiredState{}
+	changes := &ChangeInfo{
+		DiffSummaries: map[string]string{
+			"web": "Group web: count 2 -> 3",
+		},
+	}
+
+	w.notifyOutOfSync(wi, desired, changes)
+	w.notifyOutOfSync(wi, desired, changes)
+	if len(n.sent) != 1 {
+		t.Fatalf("Expected exactly 1 notification for unchanged changes, got %d", len(n.sent))
+	}
+	if n.sent[0].Type != NotificationInfo {
+		t.Errorf("Expected an info notification, got %s", n.sent[0].Type)
+	}
+
+	// back in sync resets the state without notifying
+	w.notifyOutOfSync(wi, desired, &ChangeInfo{})
+	if len(n.sent) != 1 {
+		t.Fatalf("Expected no notification when back in sync, got %d", len(n.sent))
+	}
+	if wi.Source.Status.OutOfSyncSummary != "" {
+		t.Errorf("Expected the notified summary to be reset")
+	}
+
+	// drifting again notifies again
+	w.notifyOutOfSync(wi, desired, changes)
+	if len(n.sent) != 2 {
+		t.Fatalf("Expected a new notification after drifting again, got %d", len(n.sent))
+	}
+}
+
+func TestRequestSyncCoalesces(t *testing.T) {
+	wi := &WatchInfo{
+		syncCh: make(chan struct{}, 1),
+	}
+	first := &Action{ID: "first"}
+	second := &Action{ID: "second"}
+
+	// triggered by a webhook, a user and the event stream while a sync is running
+	wi.requestSync(SyncSourceOptions{Action: first})
+	wi.requestSync(SyncSourceOptions{ForceRestart: true, Action: second})
+	wi.requestSync(SyncSourceOptions{})
+
+	if len(wi.syncCh) != 1 {
+		t.Fatalf("Expected exactly one queued sync, got %d", len(wi.syncCh))
+	}
+	<-wi.syncCh
+	opts := wi.takePendingSync()
+	if !opts.ForceRestart || opts.Action != second {
+		t.Errorf("Unexpected merged options %+v", opts)
+	}
+
+	wi.requestSync(SyncSourceOptions{})
+	if len(wi.syncCh) != 1 {
+		t.Errorf("Expected a new sync to be queued after the pending one was taken")
+	}
+}
+
+func TestCancelReconcile(t *testing.T) {
+	ctx := context.Background()
+	wi := &WatchInfo{
+		ctx:    ctx,
+		Source: &domain.Source{ID: "src", Name: "source"},
+	}
+	if wi.cancelReconcile() {
+		t.Fatalf("Expected nothing to cancel without running sync")
+	}
+
+	syncCtx := wi.startReconcile(&Action{ID: "action"})
+	wi.setPhase(domain.ReconcilePhaseApply)
+	reportJobProgress(syncCtx, "web")
+
+	r := wi.runningReconcile(time.Now())
+	if r == nil || r.Phase != domain.ReconcilePhaseApply || r.CurrentJob != "web" || r.ActionID != "action" {
+		t.Fatalf("Unexpected running sync %+v", r)
+	}
+
+	if !wi.cancelReconcile() {
+		t.Fatalf("Expected the running sync to be cancelled")
+	}
+	if syncCtx.Err() == nil {
+		t.Errorf("Expected the context of the sync to be cancelled")
+	}
+	if wi.ctx.Err() != nil {
+		t.Errorf("Expected the watch to keep running")
+	}
+
+	wi.finishReconcile()
+	if r := wi.runningReconcile(time.Now()); r != nil {
+		t.Errorf("Expected no running sync after finishing, got %+v", r)
+	}
+}
+
+func TestRepoName(t *testing.T) {
+	for u, expected := range map[string]string{
+		"git@github.com:org/repo.git":                                      "org/repo",
+		"https://github.com/org/repo.git":                                  "org/repo",
+		"https://github.com/org/repo":                                      "org/repo",
+		"https://bitbucket.example.com/scm/proj/repo.git":                  "proj/repo",
+		"https://org@dev.azure.com/org/project/_git/repo":                  "org/project/repo",
+		"git@ssh.dev.azure.com:v3/org/project/repo":                        "org/project/repo",
+		"https://org.visualstudio.com/DefaultCollection/project/_git/repo": "org/project/repo",
+		"https://org.visualstudio.com/project/_git/repo":                   "org/project/repo",
+	} {
+		if n := RepoName(u); n != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, u, n)
+		}
+	}
+}
+
+func TestRenderDesiredState(t *testing.T) {
+	ctx := context.Background()
+	w, err := CreateRepoWatcher(ctx, log.NewSimpleLogger(false, "Test"), RepoWatcherConfig{}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateRepoWatcher:%v", err)
+	}
+	render := func() string {
+		vaultToken := "secret"
+		desiredState := &DesiredState{
+			GitInfo: GitInfo{GitCommit: "abc"},
+			Jobs: map[string]*JobInfo{
+				"web": {Job: &api.Job{ID: log.ToStrPtr("web"), VaultToken: &vaultToken}},
+				"api": {Job: &api.Job{ID: log.ToStrPtr("api")}},
+			},
+		}
+		b, err := w.RenderDesiredState(ctx, &domain.Source{Namespace: "team", DataCenter: "dc1,dc2"}, desiredState)
+		if err != nil {
+			t.Fatalf("Could not RenderDesiredState:%v", err)
+		}
+		return string(b)
+	}
+
+	rendered := render()
+	if rendered != render() {
+		t.Errorf("Expected the rendered jobs to be deterministic")
+	}
+	if strings.Index(rendered, `"api"`) > strings.Index(rendered, `"web"`) {
+		t.Errorf("Expected the jobs to be sorted by name:\n%s", rendered)
+	}
+	for _, expected := range []string{`"Namespace": "team"`, `"dc2"`, `"Priority": 50`} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected %s in:\n%s", expected, rendered)
+		}
+	}
+	if strings.Contains(rendered, "secret") {
+		t.Errorf("Expected the vault token to be left out:\n%s", rendered)
+	}
+}
+
+func TestApplyOverridesDefaults(t *testing.T) {
+	ctx := context.Background()
+	w := &RepoWatcher{}
+	src := &domain.Source{DefaultDataCenters: "eu-1,eu-2", DefaultNodePool: "shared"}
+	desiredState := &DesiredState{
+		Jobs: map[string]*JobInfo{
+			"plain": {Job: &api.Job{}},
+			"explicit": {Job: &api.Job{
+				Datacenters: []string{"dc1"},
+				NodePool:    log.ToStrPtr("gpu"),
+			}},
+		},
+	}
+	if err := w.applyOverrides(ctx, src, desiredState); err != nil {
+		t.Fatalf("Could not applyOverrides:%v", err)
+	}
+
+	plain := desiredState.Jobs["plain"]
+	if strings.Join(plain.Datacenters, ",") != "eu-1,eu-2" {
+		t.Errorf("Expected the default datacenters, got %v", plain.Datacenters)
+	}
+	if plain.NodePool == nil || *plain.NodePool != "shared" {
+		t.Errorf("Expected the default node pool, got %v", plain.NodePool)
+	}
+	explicit := desiredState.Jobs["explicit"]
+	if strings.Join(explicit.Datacenters, ",") != "dc1" || *explicit.NodePool != "gpu" {
+		t.Errorf("Expected the job settings to be kept, got %v %v", explicit.Datacenters, *explicit.NodePool)
+	}
+
+	// the override of the datacenters wins over the defaults
+	src.DataCenter = "us-1"
+	if err := w.applyOverrides(ctx, src, desiredState); err != nil {
+		t.Fatalf("Could not applyOverrides:%v", err)
+	}
+	if strings.Join(desiredState.Jobs["plain"].Datacenters, ",") != "us-1" || *desiredState.Jobs["plain"].NodePool != "shared" {
+		t.Errorf("Unexpected job after applying the overrides again %+v", desiredState.Jobs["plain"].Job)
+	}
+}
+
+type fakePendingSyncRepo struct {
+	lock    sync.Mutex
+	pending map[string]*domain.PendingSync
+}
+
+func (r *fakePendingSyncRepo) SavePendingSync(ctx context.Context, p *domain.PendingSync) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pending[p.SourceID] = p
+	return nil
+}
+
+func (r *fakePendingSyncRepo) DeletePendingSync(ctx context.Context, srcID string, before time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if p, ok := r.pending[srcID]; ok && p.LastRequestedAt.Before(before) {
+		delete(r.pending, srcID)
+	}
+	return nil
+}
+
+func (r *fakePendingSyncRepo) ListPendingSyncs(ctx context.Context) ([]*domain.PendingSync, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	res := []*domain.PendingSync{}
+	for _, p := range r.pending {
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func TestPendingSyncs(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakePendingSyncRepo{pending: map[string]*domain.PendingSync{}}
+	w, err := CreateRepoWatcher(ctx, log.NewSimpleLogger(false, "Test"), RepoWatcherConfig{PendingSyncs: repo}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateRepoWatcher:%v", err)
+	}
+	wi := &WatchInfo{
+		ctx:    ctx,
+		Source: &domain.Source{ID: "src"},
+		syncCh: make(chan struct{}, 1),
+	}
+	w.watchList["src"] = wi
+
+	action := &Action{ID: "action", Type: domain.AuditActionTypeSync, UserID: "user", Actor: "jane"}
+	wi.requestSync(SyncSourceOptions{ForceRestart: true, Action: action})
+	w.savePendingSync(ctx, wi)
+	p := repo.pending["src"]
+	if p == nil || !p.ForceRestart || p.ActionID != "action" || p.Actor != "jane" || p.RequestedAt.IsZero() {
+		t.Fatalf("Unexpected pending sync %+v", p)
+	}
+
+	// requests after the sync was taken are kept
+	w.deletePendingSync(ctx, "src", p.LastRequestedAt)
+	if repo.pending["src"] == nil {
+		t.Fatalf("Expected a later request to be kept")
+	}
+
+	// the restarted instance only replays the syncs of the watched sources
+	<-wi.syncCh
+	wi.takePendingSync()
+	repo.pending["other"] = &domain.PendingSync{SourceID: "other"}
+	if err := w.ReplayPendingSyncs(ctx); err != nil {
+		t.Fatalf("Could not ReplayPendingSyncs:%v", err)
+	}
+	if len(wi.syncCh) != 1 {
+		t.Fatalf("Expected the pending sync to be replayed")
+	}
+	opts := wi.takePendingSync()
+	if !opts.ForceRestart || opts.Action == nil || opts.Action.ID != "action" || opts.Action.UserID != "user" {
+		t.Errorf("Unexpected replayed options %+v", opts)
+	}
+
+	w.deletePendingSync(ctx, "src", time.Now().Add(time.Second))
+	if repo.pending["src"] != nil || repo.pending["other"] == nil {
+		t.Errorf("Expected only the synced source to be deleted")
+	}
+}
+
+func TestCheckDeploymentWait(t *testing.T) {
+	w := &RepoWatcher{cfg: RepoWatcherConfig{PhaseTimeouts: PhaseTimeouts{DeploymentWait: time.Minute}}}
+	now := time.Now()
+
+	status := &domain.SourceStatus{Status: domain.SourceStatusStatusSynced, Message: "Deployment pending for job: web"}
+	w.checkDeploymentWait(status, now.Add(-30*time.Second), now)
+	if status.Status != domain.SourceStatusStatusSynced || status.TimedOutPhase != "" {
+		t.Fatalf("Expected the deployment to be still awaited, got %+v", status)
+	}
+
+	w.checkDeploymentWait(status, now.Add(-2*time.Minute), now)
+	if status.Status != domain.SourceStatusStatusError || status.ErrorCode != domain.ErrorCodeTimeout ||
+		status.TimedOutPhase != domain.ReconcilePhaseDeploymentWait ||
+		status.Message != "deploymentWait did not finish within 1m0s: Deployment pending for job: web" {
+		t.Errorf("Expected the deployment wait to time out, got %+v", status)
+	}
+}
+
+func TestSyncTriggers(t *testing.T) {
+	ctx := context.Background()
+	w, err := CreateRepoWatcher(ctx, log.NewSimpleLogger(false, "Test"), RepoWatcherConfig{}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateRepoWatcher:%v", err)
+	}
+	wi := &WatchInfo{
+		ctx: ctx,
+		Source: &domain.Source{
+			ID:              "src",
+			URL:             "https://github.com/nomad-ops/nomad-ops.git",
+			Branch:          "main",
+			DisableWebhooks: true,
+		},
+		syncCh: make(chan struct{}, 1),
+	}
+	wi.syncFunc = func(ctx context.Context, opts SyncSourceOptions) error {
+		wi.requestSync(opts)
+		return nil
+	}
+	w.watchList["src"] = wi
+
+	if err := w.SyncSource(ctx, "nomad-ops/nomad-ops", "main", SyncSourceOptions{Trigger: domain.SyncTriggerWebhook}); err != nil {
+		t.Fatalf("Could not SyncSource:%v", err)
+	}
+	if err := w.SyncSourceByID(ctx, "src", SyncSourceOptions{Trigger: domain.SyncTriggerWebhook}); err != nil {
+		t.Fatalf("Could not SyncSourceByID:%v", err)
+	}
+	if len(wi.syncCh) != 0 {
+		t.Fatalf("Expected webhooks not to sync the source")
+	}
+
+	if err := w.SyncSourceByID(ctx, "src", SyncSourceOptions{Trigger: domain.SyncTriggerNomadEvent}); err != nil {
+		t.Fatalf("Could not SyncSourceByID:%v", err)
+	}
+	if len(wi.syncCh) != 1 {
+		t.Fatalf("Expected Nomad events to sync the source")
+	}
+	wi.takePendingSync()
+	<-wi.syncCh
+
+	// manual syncs are always enabled
+	wi.Source.DisablePolling = true
+	wi.Source.DisableNomadEvents = true
+	if err := w.SyncSourceByID(ctx, "src", SyncSourceOptions{}); err != nil {
+		t.Fatalf("Could not SyncSourceByID:%v", err)
+	}
+	if len(wi.syncCh) != 1 {
+		t.Errorf("Expected a manual sync of the source")
+	}
+}
+
+func TestTouchesPath(t *testing.T) {
+	files := []string{"README.md", "jobs/web.nomad"}
+	for p, expected := range map[string]bool{
+		"jobs":   true,
+		"/jobs/": true,
+		"job":    false,
+		"":       true,
+	} {
+		if TouchesPath(files, p) != expected {
+			t.Errorf("Expected %v for path %s", expected, p)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/annotationstore"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerAnnotationRoutes adds the routes CI annotates commits with and users read the annotations of a source with
+func registerAnnotationRoutes(e *core.ServeEvent,
+	app core.App,
+	logger log.Logger,
+	annotationStore *annotationstore.PocketBaseStore) {
+	// add new "POST /api/actions/commits/annotations" route, CI attaches the build of a commit
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/actions/commits/annotations",
+		Handler: func(c echo.Context) error {
+			a := &domain.CommitAnnotation{}
+			if err := c.Bind(a); err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected the repo and the commit"),
+				})
+			}
+			// clone urls are accepted as well
+			a.Repo = application.RepoName(a.Repo)
+			if err := a.Validate(); err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			}
+
+			if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+				found, err := isRepoTeamMember(app, authRecord, a.Repo)
+				if err != nil {
+					return err
+				}
+				if !found {
+					return apis.NewForbiddenError("Only team members of a source of the repository can annotate its commits", nil)
+				}
+			}
+
+			saved, err := annotationStore.SaveCommitAnnotation(c.Request().Context(), a)
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not SaveCommitAnnotation:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, saved)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimit(64 * 1024),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// commits the jobs of a source are applied at, with the builds CI annotated them with
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/sources/:id/annotations",
+		Handler: func(c echo.Context) error {
+			rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+			if err != nil {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+
+			if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+				found, err := isSourceTeamMember(app, authRecord, rec)
+				if err != nil {
+					return err
+				}
+				if !found {
+					// do not reveal that the source exists
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Source was not found"),
+					})
+				}
+			}
+
+			res, err := application.AppliedCommits(c.Request().Context(), annotationStore, domain.SourceFromRecord(rec, true))
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not list the annotations of source %s:%v", rec.Id, err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, res)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
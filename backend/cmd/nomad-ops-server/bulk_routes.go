@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerBulkRoutes adds the routes operating on many sources at once and importing the jobs registered without nomad-ops
+func registerBulkRoutes(e *core.ServeEvent,
+	app core.App,
+	logger log.Logger,
+	bulkOperator *application.BulkOperator,
+	importer *application.Importer) {
+	// pauses, resumes, syncs or prunes all sources of the user matching a filter
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/nomad/sources/bulk",
+		Handler: func(c echo.Context) error {
+			req := application.BulkRequest{}
+			if err := c.Bind(&req); err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected an operation and a filter"),
+				})
+			}
+			actionType := domain.AuditActionTypeUpdate
+			switch {
+			case req.Operation == application.BulkOperationSync && req.Restart:
+				actionType = domain.AuditActionTypeRestart
+			case req.Operation == application.BulkOperationSync:
+				actionType = domain.AuditActionTypeSync
+			case req.Operation == application.BulkOperationPrune:
+				actionType = domain.AuditActionTypeDelete
+			}
+			action := newAction(c, actionType)
+
+			logger.LogInfo(c.Request().Context(), "Bulk %s of sources (action %s by %s, dry run %v)...", req.Operation, action.ID, action.Actor, req.DryRun)
+			report, err := bulkOperator.Run(application.WithAction(c.Request().Context(), action), req, visibleSources(app, logger, c))
+			if err != nil {
+				if domain.ErrorCodeOf(err, "") == domain.ErrorCodeInvalidRequest {
+					return c.JSON(http.StatusBadRequest, domain.Error{
+						Code:    domain.ErrorCodeInvalidRequest,
+						Message: log.ToStrPtr(err.Error()),
+					})
+				}
+				logger.LogError(c.Request().Context(), "Could not run bulk operation:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, report)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimit(64 * 1024),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// imports the jobs registered without nomad-ops: suggests sources, exports the jobs to git and creates the sources
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/admin/import",
+		Handler: func(c echo.Context) error {
+			req := application.ImportRequest{}
+			if err := c.Bind(&req); err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected groupBy and the url of the repository"),
+				})
+			}
+			logger.LogInfo(c.Request().Context(), "Importing jobs grouped by %s to %s (dry run %v, adopt %v)...", req.GroupBy, req.URL, req.DryRun, req.Adopt)
+			report, err := importer.Run(c.Request().Context(), req)
+			if err != nil {
+				if domain.ErrorCodeOf(err, "") == domain.ErrorCodeInvalidRequest {
+					return c.JSON(http.StatusBadRequest, domain.Error{
+						Code:    domain.ErrorCodeInvalidRequest,
+						Message: log.ToStrPtr(err.Error()),
+					})
+				}
+				logger.LogError(c.Request().Context(), "Could not import jobs:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, report)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimit(64 * 1024),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/nomadcluster"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerClusterRoutes adds the routes reporting the state of nomad-ops and the Nomad cluster
+func registerClusterRoutes(e *core.ServeEvent,
+	app core.App,
+	logger log.Logger,
+	nomadAPI *nomadcluster.Client,
+	manager *application.ReconciliationManager) {
+	// outcome of the resync of all sources after the start
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/startup-report",
+		Handler: func(c echo.Context) error {
+			report := manager.StartupReport()
+			if report == nil {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("The startup report is disabled"),
+				})
+			}
+			return c.JSON(http.StatusOK, report.Filter(visibleSources(app, logger, c)))
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// versions of the Nomad agents and whether they are outside the tested range
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/version",
+		Handler: func(c echo.Context) error {
+			return c.JSON(http.StatusOK, nomadAPI.ClusterVersion())
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// readiness, fails while the Nomad token lacks capabilities nomad-ops requires
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/ready",
+		Handler: func(c echo.Context) error {
+			report := nomadAPI.ACLReport()
+			if !report.Ready {
+				return c.JSON(http.StatusServiceUnavailable, report)
+			}
+			return c.JSON(http.StatusOK, report)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			middleware.Recover(),
+		},
+	})
+}
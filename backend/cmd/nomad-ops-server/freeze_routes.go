@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerFreezeRoutes adds the routes admins watch and lift freezes with and sync single sources despite them
+func registerFreezeRoutes(e *core.ServeEvent,
+	logger log.Logger,
+	freezeCalendar *application.FreezeCalendar,
+	watcher *application.RepoWatcher) {
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/admin/freeze",
+		Handler: func(c echo.Context) error {
+			now := time.Now()
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"override": freezeCalendar.ActiveOverride(now),
+				"windows":  freezeCalendar.Windows(now),
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// lifts all freezes for a while, e.g. for an emergency fix
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPut,
+		Path:   "/api/admin/freeze/override",
+		Handler: func(c echo.Context) error {
+			req := struct {
+				// e.g. 2h
+				Duration string `json:"duration"`
+				Reason   string `json:"reason"`
+			}{}
+			if err := c.Bind(&req); err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected the duration of the override"),
+				})
+			}
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil || d <= 0 {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Invalid duration " + req.Duration),
+				})
+			}
+			o := &application.FreezeOverride{
+				Until:  time.Now().Add(d),
+				Reason: req.Reason,
+			}
+			logger.LogInfo(c.Request().Context(), "Freezes are lifted until %s: %s", o.Until.Format(time.RFC3339), o.Reason)
+			freezeCalendar.Override(o)
+			return c.JSON(http.StatusOK, o)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimit(64 * 1024),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodDelete,
+		Path:   "/api/admin/freeze/override",
+		Handler: func(c echo.Context) error {
+			freezeCalendar.Override(nil)
+			return c.NoContent(http.StatusNoContent)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// applies the changes of a single source despite an active freeze
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/admin/sources/:id/sync",
+		Handler: func(c echo.Context) error {
+			id := c.PathParam("id")
+			restart := c.QueryParam("restart") == "true"
+			actionType := domain.AuditActionTypeSync
+			if restart {
+				actionType = domain.AuditActionTypeRestart
+			}
+			action := newAction(c, actionType)
+
+			logger.LogInfo(c.Request().Context(), "Syncing source %s ignoring freezes (action %s by %s)...", id, action.ID, action.Actor)
+			err := watcher.SyncSourceByID(c.Request().Context(), id, application.SyncSourceOptions{
+				ForceRestart: restart,
+				IgnoreFreeze: true,
+				Action:       action,
+			})
+			if err == errors.ErrNotFound {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not SyncSourceByID:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, map[string]string{
+				"actionId": action.ID,
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
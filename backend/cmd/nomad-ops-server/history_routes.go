@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerHistoryRoutes adds the routes of the job history of sources
+func registerHistoryRoutes(e *core.ServeEvent,
+	app core.App,
+	logger log.Logger,
+	jobHistory *application.JobHistory) {
+	// jobs a source had registered at a point in time and the changes between two points in time,
+	// e.g. for incident retrospectives
+	historySource := func(c echo.Context) (*models.Record, error) {
+		rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+		if err != nil {
+			return nil, c.JSON(http.StatusNotFound, domain.Error{
+				Code:    domain.ErrorCodeNotFound,
+				Message: log.ToStrPtr("Source was not found"),
+			})
+		}
+		if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+			found, err := isSourceTeamMember(app, authRecord, rec)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				// do not reveal that the source exists
+				return nil, c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+		}
+		return rec, nil
+	}
+	historyTime := func(c echo.Context, param string) (time.Time, error) {
+		value := c.QueryParam(param)
+		if value == "" {
+			return time.Now(), nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, c.JSON(http.StatusBadRequest, domain.Error{
+				Code:    domain.ErrorCodeInvalidRequest,
+				Message: log.ToStrPtr(fmt.Sprintf("Expected %s as RFC 3339 time, e.g. 2023-01-02T15:04:05Z", param)),
+			})
+		}
+		return t, nil
+	}
+	historyError := func(c echo.Context, rec *models.Record, err error) error {
+		code := domain.ErrorCodeOf(err, domain.ErrorCodeInternal)
+		if code == domain.ErrorCodeInvalidRequest {
+			return c.JSON(http.StatusBadRequest, domain.Error{
+				Code:    code,
+				Message: log.ToStrPtr(err.Error()),
+			})
+		}
+		logger.LogError(c.Request().Context(), "Could not read the job history of %s:%v", rec.Id, err)
+		return c.JSON(http.StatusInternalServerError, domain.Error{
+			Code:    code,
+			Message: log.ToStrPtr("Unexpected error"),
+		})
+	}
+
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/sources/:id/history",
+		Handler: func(c echo.Context) error {
+			rec, err := historySource(c)
+			if rec == nil {
+				return err
+			}
+			at, err := historyTime(c, "at")
+			if err != nil {
+				return err
+			}
+			state, err := jobHistory.StateAt(c.Request().Context(), rec.Id, at)
+			if err != nil {
+				return historyError(c, rec, err)
+			}
+			return c.JSON(http.StatusOK, state)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/sources/:id/history/diff",
+		Handler: func(c echo.Context) error {
+			rec, err := historySource(c)
+			if rec == nil {
+				return err
+			}
+			if c.QueryParam("from") == "" {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected from as RFC 3339 time, e.g. 2023-01-02T15:04:05Z"),
+				})
+			}
+			from, err := historyTime(c, "from")
+			if err != nil {
+				return err
+			}
+			to, err := historyTime(c, "to")
+			if err != nil {
+				return err
+			}
+			diff, err := jobHistory.Diff(c.Request().Context(), rec.Id, from, to)
+			if err != nil {
+				return historyError(c, rec, err)
+			}
+			return c.JSON(http.StatusOK, diff)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/nomadcluster"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerJobRoutes adds the routes searching the managed jobs, showing their topology and operating their deployments
+func registerJobRoutes(e *core.ServeEvent,
+	app core.App,
+	logger log.Logger,
+	nomadAPI *nomadcluster.Client) {
+	// managed jobs of all sources, users only find the jobs of sources of their teams
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/jobs/search",
+		Handler: func(c echo.Context) error {
+			q := nomadcluster.JobSearchQuery{
+				Text:      c.QueryParam("q"),
+				Image:     c.QueryParam("image"),
+				Namespace: c.QueryParam("namespace"),
+				SourceID:  c.QueryParam("source"),
+			}
+			for _, m := range c.QueryParams()["meta"] {
+				k, v, ok := strings.Cut(m, "=")
+				if !ok {
+					return c.JSON(http.StatusBadRequest, domain.Error{
+						Code:    domain.ErrorCodeInvalidRequest,
+						Message: log.ToStrPtr("Expected meta as key=value"),
+					})
+				}
+				if q.Meta == nil {
+					q.Meta = map[string]string{}
+				}
+				q.Meta[k] = v
+			}
+
+			sel, err := domain.ParseLabelSelector(c.QueryParam("selector"))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			}
+			// labels of the sources by id, only looked up if a selector is given
+			selected := map[string]bool{}
+			matches := func(srcID string) bool {
+				if sel.IsEmpty() {
+					return true
+				}
+				m, ok := selected[srcID]
+				if !ok {
+					rec, err := app.Dao().FindRecordById("sources", srcID)
+					m = err == nil && sel.Matches(domain.SourceFromRecord(rec, false).Labels)
+					selected[srcID] = m
+				}
+				return m
+			}
+
+			visible := visibleSources(app, logger, c)
+			res := []*nomadcluster.IndexedJob{}
+			for _, j := range nomadAPI.SearchJobs(c.Request().Context(), q) {
+				if visible(j.SourceID) && matches(j.SourceID) {
+					res = append(res, j)
+				}
+			}
+			return c.JSON(http.StatusOK, res)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// topology of the managed jobs of the sources of the user
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/graph",
+		Handler: func(c echo.Context) error {
+			g := nomadAPI.JobGraph(c.Request().Context(), visibleSources(app, logger, c))
+			for _, n := range g.Nodes {
+				if n.Type != nomadcluster.GraphNodeTypeSource {
+					continue
+				}
+				if rec, err := app.Dao().FindRecordById("sources", n.SourceID); err == nil {
+					n.Label = rec.GetString("name")
+				}
+			}
+			return c.JSON(http.StatusOK, g)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// pause, resume or fail a deployment of a job of the source, only team members of the source can operate it
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/nomad/sources/:id/deployments/:deploymentId/:operation",
+		Handler: func(c echo.Context) error {
+			op := nomadcluster.DeploymentOperation(c.PathParam("operation"))
+			if op != nomadcluster.DeploymentOperationPause &&
+				op != nomadcluster.DeploymentOperationResume &&
+				op != nomadcluster.DeploymentOperationFail {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected the operation pause, resume or fail"),
+				})
+			}
+
+			rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+			if err != nil {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+
+			if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+				found, err := isSourceTeamMember(app, authRecord, rec)
+				if err != nil {
+					return err
+				}
+				if !found {
+					// do not reveal that the source exists
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Source was not found"),
+					})
+				}
+			}
+
+			action := newAction(c, domain.AuditActionTypeDeployment)
+			ctx := application.WithAction(c.Request().Context(), action)
+			deploymentID := c.PathParam("deploymentId")
+			logger.LogInfo(ctx, "Deployment %s of source %s: %s (action %s by %s)...", deploymentID, rec.Id, op, action.ID, action.Actor)
+			resp, err := nomadAPI.UpdateSourceDeployment(ctx, domain.SourceFromRecord(rec, false), deploymentID, op)
+			if err == errors.ErrNotFound {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Deployment was not found"),
+				})
+			}
+			if domain.ErrorCodeOf(err, "") == domain.ErrorCodeInvalidRequest {
+				return c.JSON(http.StatusConflict, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			}
+			if err != nil {
+				logger.LogError(ctx, "Could not %s deployment %s:%v", op, deploymentID, err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+
+			return c.JSON(http.StatusOK, map[string]string{
+				"actionId": action.ID,
+				"evalId":   resp.EvalID,
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/ldap"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/teamstore"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/userstore"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerLDAPRoutes adds the route users log in with their LDAP credentials
+func registerLDAPRoutes(e *core.ServeEvent,
+	logger log.Logger,
+	ldapAuth *ldap.Authenticator,
+	userStore *userstore.PocketBaseStore,
+	teamStore *teamstore.PocketBaseStore) {
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/auth/ldap",
+		Handler: func(c echo.Context) error {
+			req := struct {
+				Username string `json:"username"`
+				Password string `json:"password"`
+			}{}
+			if err := c.Bind(&req); err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected a username and password"),
+				})
+			}
+
+			u, err := ldapAuth.Authenticate(c.Request().Context(), req.Username, req.Password)
+			if err == ldap.ErrInvalidCredentials {
+				return c.JSON(http.StatusUnauthorized, domain.Error{
+					Code:    domain.ErrorCodeUnauthorized,
+					Message: log.ToStrPtr("Invalid credentials"),
+				})
+			}
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not Authenticate with LDAP:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+
+			record, err := userStore.UpsertLDAPUser(c.Request().Context(), userstore.ExternalUser{
+				DN:       u.DN,
+				Username: u.Username,
+				Email:    u.Email,
+				Name:     u.Name,
+			})
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not UpsertLDAPUser:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+
+			members := map[string][]string{}
+			for _, team := range u.Teams {
+				members[team] = []string{record.Id}
+			}
+			err = teamStore.SyncLDAPMembers(c.Request().Context(), []string{record.Id}, members, ldapAuth.MappedTeams())
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not SyncLDAPMembers:%v", err)
+			}
+
+			return apis.RecordAuthResponse(e.App, c, record, map[string]any{
+				"groups": u.Groups,
+				"teams":  u.Teams,
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"io/fs"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -18,21 +20,40 @@ import (
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/models"
 	"github.com/pocketbase/pocketbase/models/settings"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/security"
 
 	"github.com/nomad-ops/nomad-ops/backend/application"
 	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/annotationstore"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/auditstore"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/azuredevops"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/bitbucket"
 	"github.com/nomad-ops/nomad-ops/backend/interfaces/eventstore"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/freezefeed"
 	"github.com/nomad-ops/nomad-ops/backend/interfaces/github"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/jobhistorystore"
 	"github.com/nomad-ops/nomad-ops/backend/interfaces/keystore"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/ldap"
 	"github.com/nomad-ops/nomad-ops/backend/interfaces/nomadcluster"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/notificationtargetstore"
 	"github.com/nomad-ops/nomad-ops/backend/interfaces/notifier"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/pendingsyncstore"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/registry"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/renderer"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/replication"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/retention"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/sessionstore"
 	"github.com/nomad-ops/nomad-ops/backend/interfaces/sourcestore"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/statustokenstore"
 	"github.com/nomad-ops/nomad-ops/backend/interfaces/teamstore"
 	"github.com/nomad-ops/nomad-ops/backend/interfaces/teamsync"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/userstore"
 	"github.com/nomad-ops/nomad-ops/backend/interfaces/vaulttokenstore"
 	"github.com/nomad-ops/nomad-ops/backend/utils/env"
 	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
 	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+	"github.com/nomad-ops/nomad-ops/backend/utils/proxy"
 	mon "github.com/nomad-ops/nomad-ops/backend/utils/vmmonitor"
 )
 
@@ -52,6 +73,11 @@ func main() {
 
 	app.OnRecordBeforeCreateRequest().Add(func(e *core.RecordCreateEvent) error {
 
+		if e.Collection.Name == "sources" || e.Collection.Name == "source_templates" {
+			if err := validateLabels(e.Record); err != nil {
+				return err
+			}
+		}
 		if e.Collection.Name == "sources" {
 			e.Record.Set("status", &domain.SourceStatus{
 				Status:  domain.SourceStatusStatusInit,
@@ -61,6 +87,22 @@ func main() {
 		return nil
 	})
 
+	app.OnRecordBeforeUpdateRequest().Add(func(e *core.RecordUpdateEvent) error {
+		if e.Collection.Name == "users" {
+			// the link to the LDAP user is managed by nomad-ops only
+			e.Record.Set("ldapDN", e.Record.OriginalCopy().GetString("ldapDN"))
+		}
+		if e.Collection.Name == "sources" || e.Collection.Name == "source_templates" {
+			return validateLabels(e.Record)
+		}
+		if e.Collection.Name == "notification_targets" {
+			if _, err := domain.ParseLabelSelector(e.Record.GetString("sourceSelector")); err != nil {
+				return apis.NewBadRequestError(err.Error(), nil)
+			}
+		}
+		return nil
+	})
+
 	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
 
 		set := settings.New()
@@ -189,6 +231,275 @@ func main() {
 			return err
 		}
 
+		notificationTargetStore, err := notificationtargetstore.CreatePocketBaseStore(ctx,
+			log.NewSimpleLogger(trace, "NotificationTargetStore-PocketBase"),
+			notificationtargetstore.PocketBaseStoreConfig{
+				App: e.App,
+			})
+
+		if err != nil {
+			logger.LogError(ctx, "Could not CreatePocketBaseStore for notificationTargets:%v", err)
+			return err
+		}
+
+		userStore, err := userstore.CreatePocketBaseStore(ctx,
+			log.NewSimpleLogger(trace, "UserStore-PocketBase"),
+			userstore.PocketBaseStoreConfig{
+				App: e.App,
+			})
+
+		if err != nil {
+			logger.LogError(ctx, "Could not CreatePocketBaseStore for users:%v", err)
+			return err
+		}
+
+		sessionStore, err := sessionstore.CreatePocketBaseStore(ctx,
+			log.NewSimpleLogger(trace, "SessionStore-PocketBase"),
+			sessionstore.PocketBaseStoreConfig{
+				App: e.App,
+			})
+
+		if err != nil {
+			logger.LogError(ctx, "Could not CreatePocketBaseStore for sessions:%v", err)
+			return err
+		}
+
+		statusTokenStore, err := statustokenstore.CreatePocketBaseStore(ctx,
+			log.NewSimpleLogger(trace, "StatusTokenStore-PocketBase"),
+			statustokenstore.PocketBaseStoreConfig{
+				App: e.App,
+			})
+
+		if err != nil {
+			logger.LogError(ctx, "Could not CreatePocketBaseStore for status tokens:%v", err)
+			return err
+		}
+
+		pendingSyncStore, err := pendingsyncstore.CreatePocketBaseStore(ctx,
+			log.NewSimpleLogger(trace, "PendingSyncStore-PocketBase"),
+			pendingsyncstore.PocketBaseStoreConfig{
+				App: e.App,
+			})
+
+		if err != nil {
+			logger.LogError(ctx, "Could not CreatePocketBaseStore for pending syncs:%v", err)
+			return err
+		}
+
+		annotationStore, err := annotationstore.CreatePocketBaseStore(ctx,
+			log.NewSimpleLogger(trace, "AnnotationStore-PocketBase"),
+			annotationstore.PocketBaseStoreConfig{
+				App: e.App,
+			})
+
+		if err != nil {
+			logger.LogError(ctx, "Could not CreatePocketBaseStore for commit annotations:%v", err)
+			return err
+		}
+
+		// standbys replicate the store with this token, deleted records are remembered for them
+		replicationToken := strings.TrimSpace(ReadFromFile(ctx, logger, "REPLICATION_TOKEN_FILE", ""))
+		replicationStore, err := replication.CreatePocketBaseStore(ctx,
+			log.NewSimpleLogger(trace, "ReplicationStore-PocketBase"),
+			replication.PocketBaseStoreConfig{
+				App:          e.App,
+				TombstoneTTL: env.GetDurationEnv(ctx, logger, "REPLICATION_TOMBSTONE_TTL", 24*time.Hour),
+				PageSize:     env.GetIntEnv(ctx, logger, "REPLICATION_PAGE_SIZE", 1000),
+			})
+
+		if err != nil {
+			logger.LogError(ctx, "Could not CreatePocketBaseStore for replication:%v", err)
+			return err
+		}
+		if replicationToken != "" {
+			app.OnModelAfterDelete().Add(func(e *core.ModelEvent) error {
+				err := replicationStore.RecordDeletion(ctx, e.Model)
+				if err != nil {
+					logger.LogError(ctx, "Could not RecordDeletion:%v", err)
+				}
+				return nil
+			})
+		}
+
+		sessionPolicy := domain.SessionPolicy{
+			TTL:               env.GetDurationEnv(ctx, logger, "SESSION_TTL", 0),
+			InactivityTimeout: env.GetDurationEnv(ctx, logger, "SESSION_INACTIVITY_TIMEOUT", 0),
+		}
+
+		e.App.OnRecordAuthRequest().Add(func(ev *core.RecordAuthEvent) error {
+			if ev.Record.Collection().Name != "users" {
+				return nil
+			}
+			_, err := sessionStore.CreateSession(ev.HttpContext.Request().Context(),
+				ev.Record.Id,
+				ev.Token,
+				ev.HttpContext.Request().UserAgent(),
+				ev.HttpContext.RealIP())
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateSession:%v", err)
+				return err
+			}
+			return nil
+		})
+
+		// rejects tokens of revoked or expired sessions
+		e.Router.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+				if authRecord == nil {
+					return next(c)
+				}
+				token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+
+				session, err := sessionStore.GetSessionByToken(c.Request().Context(), token)
+				if err == errors.ErrNotFound {
+					// issued before sessions were tracked => track from now on
+					_, err = sessionStore.CreateSession(c.Request().Context(),
+						authRecord.Id, token, c.Request().UserAgent(), c.RealIP())
+					if err != nil {
+						logger.LogError(c.Request().Context(), "Could not CreateSession:%v", err)
+						return err
+					}
+					return next(c)
+				}
+				if err != nil {
+					logger.LogError(c.Request().Context(), "Could not GetSessionByToken:%v", err)
+					return err
+				}
+
+				now := time.Now()
+				if !session.IsActive(now, sessionPolicy) {
+					return apis.NewUnauthorizedError("The session was revoked or has expired", nil)
+				}
+				if now.Sub(session.LastSeen) > time.Minute {
+					err = sessionStore.TouchSession(c.Request().Context(), session.ID, now)
+					if err != nil {
+						logger.LogError(c.Request().Context(), "Could not TouchSession:%v", err)
+					}
+				}
+				return next(c)
+			}
+		})
+
+		go func() {
+			t := time.NewTicker(time.Hour)
+			defer t.Stop()
+			for {
+				// older sessions belong to tokens that expired anyway
+				maxAge := time.Duration(e.App.Settings().RecordAuthToken.Duration) * time.Second
+				n, err := sessionStore.DeleteSessionsBefore(ctx, time.Now().Add(-maxAge))
+				if err != nil {
+					logger.LogError(ctx, "Could not DeleteSessionsBefore:%v", err)
+				}
+				if n > 0 {
+					logger.LogInfo(ctx, "Deleted %d old sessions", n)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+				}
+			}
+		}()
+
+		var archive *filesystem.System
+		if bucket := env.GetStringEnv(ctx, logger, "ARCHIVE_S3_BUCKET", ""); bucket != "" {
+			archive, err = filesystem.NewS3(bucket,
+				env.GetStringEnv(ctx, logger, "ARCHIVE_S3_REGION", ""),
+				env.GetStringEnv(ctx, logger, "ARCHIVE_S3_ENDPOINT", ""),
+				strings.TrimSpace(ReadFromFile(ctx, logger, "ARCHIVE_S3_ACCESS_KEY_FILE", "")),
+				strings.TrimSpace(ReadFromFile(ctx, logger, "ARCHIVE_S3_SECRET_FILE", "")),
+				env.GetStringEnv(ctx, logger, "ARCHIVE_S3_FORCE_PATH_STYLE", "FALSE") == "TRUE")
+			if err != nil {
+				return err
+			}
+		} else if dir := env.GetStringEnv(ctx, logger, "ARCHIVE_DIR", ""); dir != "" {
+			archive, err = filesystem.NewLocal(dir)
+			if err != nil {
+				return err
+			}
+		}
+		pruner, err := retention.CreatePruner(ctx, logger, retention.PrunerConfig{
+			App:      e.App,
+			Interval: env.GetDurationEnv(ctx, logger, "RETENTION_INTERVAL", time.Hour),
+			Policies: []retention.Policy{
+				{
+					Collection: "events",
+					MaxAge:     env.GetDurationEnv(ctx, logger, "RETENTION_EVENTS_MAX_AGE", 0),
+					MaxCount:   env.GetIntEnv(ctx, logger, "RETENTION_EVENTS_MAX_COUNT", 0),
+				},
+				{
+					Collection: "audit_log",
+					MaxAge:     env.GetDurationEnv(ctx, logger, "RETENTION_AUDIT_LOG_MAX_AGE", 0),
+					MaxCount:   env.GetIntEnv(ctx, logger, "RETENTION_AUDIT_LOG_MAX_COUNT", 0),
+				},
+			},
+			Archive: archive,
+		})
+		if err != nil {
+			return err
+		}
+		go pruner.Run(ctx)
+
+		var ldapAuth *ldap.Authenticator
+		if ldapURL := env.GetStringEnv(ctx, logger, "LDAP_URL", ""); ldapURL != "" {
+			groupTeamMapping := map[string]string{}
+			if m := env.GetStringEnv(ctx, logger, "LDAP_GROUP_TEAM_MAPPING", ""); m != "" {
+				if err := json.Unmarshal([]byte(m), &groupTeamMapping); err != nil {
+					logger.LogError(ctx, "Could not parse LDAP_GROUP_TEAM_MAPPING:%v", err)
+					os.Exit(-2)
+				}
+			}
+			ldapAuth, err = ldap.CreateAuthenticator(ctx,
+				log.NewSimpleLogger(trace, "LDAP-Auth"),
+				ldap.AuthenticatorConfig{
+					URL:                ldapURL,
+					StartTLS:           env.GetStringEnv(ctx, logger, "LDAP_START_TLS", "FALSE") == "TRUE",
+					CACertFile:         env.GetStringEnv(ctx, logger, "LDAP_CA_CERT_FILE", ""),
+					InsecureSkipVerify: env.GetStringEnv(ctx, logger, "LDAP_INSECURE", "FALSE") == "TRUE",
+					Timeout:            env.GetDurationEnv(ctx, logger, "LDAP_TIMEOUT", 10*time.Second),
+					BindDN:             env.GetStringEnv(ctx, logger, "LDAP_BIND_DN", ""),
+					BindPassword:       strings.TrimSpace(ReadFromFile(ctx, logger, "LDAP_BIND_PASSWORD_FILE", "")),
+					UserBaseDN:         env.GetStringEnv(ctx, logger, "LDAP_USER_BASE_DN", ""),
+					UserFilter:         env.GetStringEnv(ctx, logger, "LDAP_USER_FILTER", "(uid=%s)"),
+					EmailAttribute:     env.GetStringEnv(ctx, logger, "LDAP_EMAIL_ATTRIBUTE", "mail"),
+					NameAttribute:      env.GetStringEnv(ctx, logger, "LDAP_NAME_ATTRIBUTE", "cn"),
+					MemberOfAttribute:  env.GetStringEnv(ctx, logger, "LDAP_MEMBER_OF_ATTRIBUTE", "memberOf"),
+					GroupBaseDN:        env.GetStringEnv(ctx, logger, "LDAP_GROUP_BASE_DN", ""),
+					GroupFilter:        env.GetStringEnv(ctx, logger, "LDAP_GROUP_FILTER", ""),
+					GroupNameAttribute: env.GetStringEnv(ctx, logger, "LDAP_GROUP_NAME_ATTRIBUTE", "cn"),
+					GroupTeamMapping:   groupTeamMapping,
+				})
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateAuthenticator for LDAP:%v", err)
+				os.Exit(-2)
+			}
+
+			_, err = teamsync.CreateLDAPTeamSync(ctx,
+				log.NewSimpleLogger(trace, "LDAP-TeamSync"),
+				teamsync.LDAPTeamSyncConfig{
+					Interval: env.GetDurationEnv(ctx, logger, "LDAP_GROUP_SYNC_INTERVAL", 15*time.Minute),
+				},
+				ldapAuth,
+				userStore,
+				teamStore)
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateLDAPTeamSync:%v", err)
+				os.Exit(-2)
+			}
+		}
+
+		auditStore, err := auditstore.CreatePocketBaseStore(ctx,
+			log.NewSimpleLogger(trace, "AuditStore-PocketBase"),
+			auditstore.PocketBaseStoreConfig{
+				App: e.App,
+			})
+
+		if err != nil {
+			logger.LogError(ctx, "Could not CreatePocketBaseStore for audit log:%v", err)
+			return err
+		}
+
 		nomadToken := ""
 		if tokenPath := env.GetStringEnv(ctx, logger, "NOMAD_TOKEN_FILE", ""); tokenPath != "" {
 			logger.LogInfo(ctx, "Using NOMAD_TOKEN_FILE...")
@@ -200,20 +511,142 @@ func main() {
 			nomadToken = string(b)
 		}
 
+		var fakeNomad *nomadcluster.FakeConfig
+		if env.GetStringEnv(ctx, logger, "NOMAD_FAKE", "FALSE") == "TRUE" {
+			fakeNomad = &nomadcluster.FakeConfig{
+				DeploymentDuration: env.GetDurationEnv(ctx, logger, "NOMAD_FAKE_DEPLOYMENT_DURATION", 10*time.Second),
+				FailurePercent:     env.GetIntEnv(ctx, logger, "NOMAD_FAKE_FAILURE_PERCENT", 0),
+				FailJobs:           strings.Split(env.GetStringEnv(ctx, logger, "NOMAD_FAKE_FAIL_JOBS", ""), ","),
+				FailDeployments:    strings.Split(env.GetStringEnv(ctx, logger, "NOMAD_FAKE_FAIL_DEPLOYMENTS", ""), ","),
+				Latency:            env.GetDurationEnv(ctx, logger, "NOMAD_FAKE_LATENCY", 0),
+				DenyCapabilities:   strings.Split(env.GetStringEnv(ctx, logger, "NOMAD_FAKE_DENY_CAPABILITIES", ""), ","),
+			}
+		}
+
+		// e.g. eu=https://nomad-eu.example.com,us=https://nomad-us.example.com
+		uiRegionURLs := map[string]string{}
+		for _, pair := range strings.Split(env.GetStringEnv(ctx, logger, "NOMAD_UI_REGION_URLS", ""), ",") {
+			if region, u, ok := strings.Cut(pair, "="); ok {
+				uiRegionURLs[strings.TrimSpace(region)] = strings.TrimSpace(u)
+			}
+		}
+
 		nomadAPI, err := nomadcluster.CreateClient(ctx,
 			log.NewSimpleLogger(trace, "NomadClient"),
 			nomadcluster.ClientConfig{
 				NomadToken: nomadToken,
-			})
+				Fake:       fakeNomad,
+				Address:    env.GetStringEnv(ctx, logger, "NOMAD_ADDR", ""),
+				UIURL:      env.GetStringEnv(ctx, logger, "NOMAD_UI_URL", ""),
+
+				UIRegionURLs: uiRegionURLs,
+				Transport: nomadcluster.TransportConfig{
+					DialTimeout:           env.GetDurationEnv(ctx, logger, "NOMAD_DIAL_TIMEOUT", 0),
+					KeepAlive:             env.GetDurationEnv(ctx, logger, "NOMAD_KEEP_ALIVE", 0),
+					TLSHandshakeTimeout:   env.GetDurationEnv(ctx, logger, "NOMAD_TLS_HANDSHAKE_TIMEOUT", 0),
+					ResponseHeaderTimeout: env.GetDurationEnv(ctx, logger, "NOMAD_RESPONSE_HEADER_TIMEOUT", 0),
+					IdleConnTimeout:       env.GetDurationEnv(ctx, logger, "NOMAD_IDLE_CONN_TIMEOUT", 0),
+					MaxIdleConns:          env.GetIntEnv(ctx, logger, "NOMAD_MAX_IDLE_CONNS", 0),
+					MaxIdleConnsPerHost:   env.GetIntEnv(ctx, logger, "NOMAD_MAX_IDLE_CONNS_PER_HOST", 0),
+				},
+				AppName:        env.GetStringEnv(ctx, logger, "APP_NAME", "nomad-ops"),
+				ParseTimeout:   env.GetDurationEnv(ctx, logger, "JOB_PARSE_TIMEOUT", time.Second*30),
+				MaxJobFileSize: env.GetIntEnv(ctx, logger, "JOB_MAX_FILE_SIZE", 1024*1024),
+				ParseCacheSize: env.GetIntEnv(ctx, logger, "JOB_PARSE_CACHE_SIZE", 1000),
+				DiffIgnore:     strings.Split(env.GetStringEnv(ctx, logger, "NOMAD_DIFF_IGNORE", ""), ","),
+				StateCacheTTL:  env.GetDurationEnv(ctx, logger, "NOMAD_STATE_CACHE_TTL", time.Minute*5),
+				// lowest and highest tested version, e.g. 1.3,1.6
+				TestedVersions:       strings.Split(env.GetStringEnv(ctx, logger, "NOMAD_TESTED_VERSIONS", ""), ","),
+				VersionCheckInterval: env.GetDurationEnv(ctx, logger, "NOMAD_VERSION_CHECK_INTERVAL", time.Hour),
+				JobStopTimeout:       env.GetDurationEnv(ctx, logger, "JOB_STOP_TIMEOUT", time.Minute*2),
+			},
+			auditStore)
 		if err != nil {
 			logger.LogError(ctx, "Could not CreateNomadClient:%v", err)
 			os.Exit(-2)
 		}
 
+		// the capabilities of the token are checked for the region and namespace of every source
+		go nomadAPI.RunACLCheck(ctx, env.GetDurationEnv(ctx, logger, "NOMAD_ACL_CHECK_INTERVAL", 10*time.Minute),
+			func(ctx context.Context) []nomadcluster.Destination {
+				srcs, err := srcStore.ListSources(ctx, application.ListSourcesOptions{})
+				if err != nil {
+					logger.LogError(ctx, "Could not ListSources for the ACL check:%v", err)
+					return nil
+				}
+				destinations := []nomadcluster.Destination{}
+				for _, src := range srcs {
+					destinations = append(destinations, nomadcluster.Destination{
+						Region:    src.Region,
+						Namespace: src.Namespace,
+					})
+				}
+				return destinations
+			})
+
+		// the nomad client uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY of the environment
+		noProxy := env.GetStringEnv(ctx, logger, "NO_PROXY", "")
+		// CA certificates trusted for all outbound HTTPS in addition to the system trust store
+		caFiles := env.GetListEnv(ctx, logger, "TLS_CA_FILES")
+		gitTransport, err := proxy.Transport(proxy.Config{
+			URL:     env.GetStringEnv(ctx, logger, "GIT_PROXY", ""),
+			NoProxy: noProxy,
+			CAFiles: append(append([]string{}, caFiles...), env.GetListEnv(ctx, logger, "GIT_TLS_CA_FILES")...),
+			Pins:    env.GetListEnv(ctx, logger, "GIT_TLS_PINS"),
+		})
+		if err != nil {
+			logger.LogError(ctx, "Could not create git transport:%v", err)
+			os.Exit(-2)
+		}
+		notificationTransport, err := proxy.Transport(proxy.Config{
+			URL:     env.GetStringEnv(ctx, logger, "NOTIFICATION_PROXY", ""),
+			NoProxy: noProxy,
+			CAFiles: append(append([]string{}, caFiles...), env.GetListEnv(ctx, logger, "NOTIFICATION_TLS_CA_FILES")...),
+			Pins:    env.GetListEnv(ctx, logger, "NOTIFICATION_TLS_PINS"),
+		})
+		if err != nil {
+			logger.LogError(ctx, "Could not create notification transport:%v", err)
+			os.Exit(-2)
+		}
+
+		cue := renderer.CreateCue(renderer.CueConfig{
+			Binary:     env.GetStringEnv(ctx, logger, "CUE_BINARY", "cue"),
+			Timeout:    env.GetDurationEnv(ctx, logger, "RENDERER_TIMEOUT", time.Minute),
+			Schemas:    env.GetStringEnv(ctx, logger, "CUE_SCHEMAS", ""),
+			Definition: env.GetStringEnv(ctx, logger, "CUE_SCHEMA_DEFINITION", "#Job"),
+		})
+		// external renderers as <name>=<command> [args...], comma separated, they may replace the built-in ones
+		renderers := map[string]application.Renderer{
+			"cue": cue,
+			"jsonnet": renderer.CreateJsonnet(renderer.JsonnetConfig{
+				Binary:  env.GetStringEnv(ctx, logger, "JSONNET_BINARY", "jsonnet"),
+				Timeout: env.GetDurationEnv(ctx, logger, "RENDERER_TIMEOUT", time.Minute),
+			}),
+		}
+		for _, plugin := range strings.Split(env.GetStringEnv(ctx, logger, "RENDERER_PLUGINS", ""), ",") {
+			name, command, ok := strings.Cut(strings.TrimSpace(plugin), "=")
+			if !ok {
+				continue
+			}
+			r, err := renderer.CreatePlugin(renderer.PluginConfig{
+				Command: strings.Fields(command),
+				Timeout: env.GetDurationEnv(ctx, logger, "RENDERER_TIMEOUT", time.Minute),
+			})
+			if err != nil {
+				logger.LogError(ctx, "Could not create renderer %s:%v", name, err)
+				os.Exit(-2)
+			}
+			renderers[strings.TrimSpace(name)] = r
+		}
+
 		dsw, err := github.CreateGitProvider(ctx,
 			log.NewSimpleLogger(trace, "GitProvider"),
 			github.GitProviderConfig{
-				ReposDir: env.GetStringEnv(ctx, logger, "NOMAD_OPS_LOCAL_REPO_DIR", "repos"),
+				ReposDir:     env.GetStringEnv(ctx, logger, "NOMAD_OPS_LOCAL_REPO_DIR", "repos"),
+				GitHubAPIURL: env.GetStringEnv(ctx, logger, "GITHUB_API_URL", "https://api.github.com"),
+				Transport:    gitTransport,
+				Renderers:    renderers,
+				Validator:    cue,
 			},
 			nomadAPI,
 			keyStore)
@@ -222,6 +655,42 @@ func main() {
 			os.Exit(-2)
 		}
 
+		// the variables of a source are validated against the schema of its repository when it is saved,
+		// sources whose schema cannot be fetched are saved and fail with their next sync instead
+		varsSchemaTimeout := env.GetDurationEnv(ctx, logger, "VARS_SCHEMA_TIMEOUT", time.Second*30)
+		validateVariables := func(rec *models.Record) error {
+			if varsSchemaTimeout <= 0 {
+				return nil
+			}
+			src := domain.SourceFromRecord(rec, false)
+			fetchCtx, cancel := context.WithTimeout(ctx, varsSchemaTimeout)
+			defer cancel()
+			schema, err := dsw.FetchVarsSchema(fetchCtx, src)
+			if err != nil {
+				logger.LogError(ctx, "Could not fetch the variable schema of %s:%v", src.URL, err)
+				return nil
+			}
+			if schema == nil {
+				return nil
+			}
+			if err := schema.Validate(src.Variables); err != nil {
+				return apis.NewBadRequestError(err.Error(), nil)
+			}
+			return nil
+		}
+		app.OnRecordBeforeCreateRequest("sources").Add(func(e *core.RecordCreateEvent) error {
+			return validateVariables(e.Record)
+		})
+		app.OnRecordBeforeUpdateRequest("sources").Add(func(e *core.RecordUpdateEvent) error {
+			orig := e.Record.OriginalCopy()
+			for _, field := range []string{"url", "branch", "revision", "path", "variables", "deployKey"} {
+				if orig.GetString(field) != e.Record.GetString(field) {
+					return validateVariables(e.Record)
+				}
+			}
+			return nil
+		})
+
 		getNotifiers := func() map[string]application.Notifier {
 			res := map[string]application.Notifier{}
 
@@ -234,6 +703,7 @@ func main() {
 						IconSuccess: env.GetStringEnv(ctx, logger, "SLACK_ICON_SUCCESS", ":check:"),
 						IconError:   env.GetStringEnv(ctx, logger, "SLACK_ICON_ERROR", ":check-no:"),
 						EnvInfoText: env.GetStringEnv(ctx, logger, "SLACK_ENV_INFO_TEXT", "Sent by nomad-ops (dev)"),
+						Transport:   notificationTransport,
 					})
 				if err != nil {
 					logger.LogError(ctx, "Could not CreateSlack:%v", err)
@@ -256,6 +726,7 @@ func main() {
 						AuthHeaderValue:     ReadFromFile(ctx, logger, "WEBHOOK_AUTH_HEADER_VALUE_FILE", ""),
 						BodyTemplate:        ReadFromFile(ctx, logger, "WEBHOOK_BODY_TEMPLATE_FILE", ""),
 						QueryParamsTemplate: ReadFromFile(ctx, logger, "WEBHOOK_QUERY_TEMPLATE_FILE", ""),
+						Transport:           notificationTransport,
 					})
 				if err != nil {
 					logger.LogError(ctx, "Could not CreateWebhook:%v", err)
@@ -270,21 +741,160 @@ func main() {
 		notificationComposer, err := notifier.CreateComposer(ctx,
 			log.NewSimpleLogger(trace, "Notification-Composer"),
 			notifier.ComposerConfig{
-				Notifiers: getNotifiers(),
+				Notifiers:   getNotifiers(),
+				TargetRepo:  notificationTargetStore,
+				DedupWindow: env.GetDurationEnv(ctx, logger, "NOTIFICATION_DEDUP_WINDOW", time.Hour),
+
+				RetryBaseDelay: env.GetDurationEnv(ctx, logger, "NOTIFICATION_RETRY_BASE_DELAY", 10*time.Second),
+				RetryMaxDelay:  env.GetDurationEnv(ctx, logger, "NOTIFICATION_RETRY_MAX_DELAY", 10*time.Minute),
+				MaxAttempts:    env.GetIntEnv(ctx, logger, "NOTIFICATION_MAX_ATTEMPTS", 10),
 			})
 		if err != nil {
 			logger.LogError(ctx, "Could not CreateComposer:%v", err)
 			os.Exit(-2)
 		}
 
+		err = notificationTargetStore.EnsureNotificationTargets(ctx, notificationComposer.TargetNames())
+		if err != nil {
+			logger.LogError(ctx, "Could not EnsureNotificationTargets:%v", err)
+			return err
+		}
+
+		alerters := map[string]application.Alerter{}
+		if routingKey := ReadFromFile(ctx, logger, "PAGERDUTY_ROUTING_KEY_FILE", ""); routingKey != "" {
+			pagerDuty, err := notifier.CreatePagerDuty(ctx,
+				log.NewSimpleLogger(trace, "PagerDuty"),
+				notifier.PagerDutyConfig{
+					RoutingKey: routingKey,
+					URL:        env.GetStringEnv(ctx, logger, "PAGERDUTY_URL", ""),
+					Severity:   env.GetStringEnv(ctx, logger, "PAGERDUTY_SEVERITY", "error"),
+					Timeout:    env.GetDurationEnv(ctx, logger, "INCIDENT_TIMEOUT", 10*time.Second),
+					Transport:  notificationTransport,
+				})
+			if err != nil {
+				logger.LogError(ctx, "Could not CreatePagerDuty:%v", err)
+				os.Exit(-2)
+			}
+			alerters["pagerduty"] = pagerDuty
+		}
+		if apiKey := ReadFromFile(ctx, logger, "OPSGENIE_API_KEY_FILE", ""); apiKey != "" {
+			opsgenie, err := notifier.CreateOpsgenie(ctx,
+				log.NewSimpleLogger(trace, "Opsgenie"),
+				notifier.OpsgenieConfig{
+					APIKey:    apiKey,
+					URL:       env.GetStringEnv(ctx, logger, "OPSGENIE_URL", ""),
+					Priority:  env.GetStringEnv(ctx, logger, "OPSGENIE_PRIORITY", "P3"),
+					Timeout:   env.GetDurationEnv(ctx, logger, "INCIDENT_TIMEOUT", 10*time.Second),
+					Transport: notificationTransport,
+				})
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateOpsgenie:%v", err)
+				os.Exit(-2)
+			}
+			alerters["opsgenie"] = opsgenie
+		}
+
+		// the incidents are opened and resolved when the watcher stores the status of a source
+		var statusPatcher application.SourceStatusPatcher = srcStore
+		var incidents *application.IncidentTracker
+		if len(alerters) > 0 {
+			conditions := []domain.IncidentCondition{}
+			for _, c := range strings.Split(env.GetStringEnv(ctx, logger, "INCIDENT_CONDITIONS", ""), ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					conditions = append(conditions, domain.IncidentCondition(c))
+				}
+			}
+			incidents, err = application.CreateIncidentTracker(ctx,
+				log.NewSimpleLogger(trace, "IncidentTracker"),
+				application.IncidentTrackerConfig{
+					Conditions:     conditions,
+					SyncFailingFor: env.GetDurationEnv(ctx, logger, "INCIDENT_SYNC_FAILING_FOR", 15*time.Minute),
+					AppName:        env.GetStringEnv(ctx, logger, "APP_NAME", "nomad-ops"),
+				},
+				srcStore,
+				srcStore,
+				alerters)
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateIncidentTracker:%v", err)
+				os.Exit(-2)
+			}
+			statusPatcher = incidents
+		}
+
+		// the status of the sources is published to Nomad variables for other jobs in the cluster
+		var statusPublisher *application.StatusPublisher
+		if env.GetStringEnv(ctx, logger, "STATUS_VARIABLES", "FALSE") == "TRUE" {
+			statusPublisher, err = application.CreateStatusPublisher(ctx,
+				log.NewSimpleLogger(trace, "StatusPublisher"),
+				application.StatusPublisherConfig{
+					Namespace:       env.GetStringEnv(ctx, logger, "STATUS_VARIABLES_NAMESPACE", "default"),
+					Prefix:          env.GetStringEnv(ctx, logger, "STATUS_VARIABLES_PREFIX", "nomad-ops/status"),
+					RefreshInterval: env.GetDurationEnv(ctx, logger, "STATUS_VARIABLES_REFRESH_INTERVAL", 10*time.Minute),
+				},
+				statusPatcher,
+				srcStore,
+				nomadAPI)
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateStatusPublisher:%v", err)
+				os.Exit(-2)
+			}
+			// sources may have been deleted while nomad-ops was down
+			err = statusPublisher.RemoveOrphans(ctx)
+			if err != nil {
+				logger.LogError(ctx, "Could not RemoveOrphans:%v", err)
+			}
+			statusPatcher = statusPublisher
+		}
+
+		var freezeCalendar *application.FreezeCalendar
+		var freezeChecker application.FreezeChecker
+		if feedURL := env.GetStringEnv(ctx, logger, "FREEZE_FEED_URL", ""); feedURL != "" {
+			feed, err := freezefeed.CreateHTTPFeed(ctx,
+				log.NewSimpleLogger(trace, "FreezeFeed"),
+				freezefeed.HTTPFeedConfig{
+					URL:           feedURL,
+					Authorization: ReadFromFile(ctx, logger, "FREEZE_FEED_AUTHORIZATION_FILE", ""),
+				})
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateHTTPFeed:%v", err)
+				os.Exit(-2)
+			}
+			freezeCalendar, err = application.CreateFreezeCalendar(ctx,
+				log.NewSimpleLogger(trace, "FreezeCalendar"),
+				application.FreezeCalendarConfig{
+					Interval: env.GetDurationEnv(ctx, logger, "FREEZE_FEED_INTERVAL", 5*time.Minute),
+				},
+				feed)
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateFreezeCalendar:%v", err)
+				os.Exit(-2)
+			}
+			freezeChecker = freezeCalendar
+		}
+
 		watcher, err := application.CreateRepoWatcher(ctx,
 			log.NewSimpleLogger(trace, "RepoWatcher"),
 			application.RepoWatcherConfig{
 				Interval:        env.GetDurationEnv(ctx, logger, "NOMAD_OPS_POLLING_INTERVAL", 60*time.Second),
 				ErrorRetryCount: env.GetIntEnv(ctx, logger, "NOMAD_OPS_ERROR_RETRY_COUNT", 2),
 				AppName:         env.GetStringEnv(ctx, logger, "APP_NAME", "nomad-ops"),
+				Freeze:          freezeChecker,
+				PendingSyncs:    pendingSyncStore,
+				PhaseTimeouts: application.PhaseTimeouts{
+					GitFetch:       env.GetDurationEnv(ctx, logger, "SYNC_GIT_FETCH_TIMEOUT", 5*time.Minute),
+					Render:         env.GetDurationEnv(ctx, logger, "SYNC_RENDER_TIMEOUT", 0),
+					Parse:          env.GetDurationEnv(ctx, logger, "SYNC_PARSE_TIMEOUT", 0),
+					Plan:           env.GetDurationEnv(ctx, logger, "SYNC_PLAN_TIMEOUT", 2*time.Minute),
+					Register:       env.GetDurationEnv(ctx, logger, "SYNC_REGISTER_TIMEOUT", 2*time.Minute),
+					DeploymentWait: env.GetDurationEnv(ctx, logger, "SYNC_DEPLOYMENT_WAIT_TIMEOUT", 0),
+				},
+				StuckDeployments: application.StuckDeployments{
+					Timeout: env.GetDurationEnv(ctx, logger, "DEPLOYMENT_STUCK_TIMEOUT", 0),
+					Fail:    env.GetStringEnv(ctx, logger, "DEPLOYMENT_STUCK_FAIL", "FALSE") == "TRUE",
+				},
+				DeploymentFailer: nomadAPI,
 			},
-			srcStore,
+			statusPatcher,
 			dsw,
 			notificationComposer,
 			vaultTokenStore)
@@ -293,8 +903,87 @@ func main() {
 			os.Exit(-2)
 		}
 
+		var gitHubApp *github.GitHubApp
+		if secret := strings.TrimSpace(ReadFromFile(ctx, logger, "GITHUB_WEBHOOK_SECRET_FILE", "")); secret != "" {
+			gitHubApp, err = github.CreateGitHubApp(ctx,
+				log.NewSimpleLogger(trace, "GitHubApp"),
+				github.GitHubAppConfig{
+					WebhookSecret: secret,
+					CheckRunName:  env.GetStringEnv(ctx, logger, "GITHUB_CHECK_RUN_NAME", "nomad-ops"),
+					Transport:     gitTransport,
+				},
+				dsw,
+				srcStore,
+				keyStore,
+				watcher)
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateGitHubApp:%v", err)
+				os.Exit(-2)
+			}
+		}
+
+		var bitbucketApp *bitbucket.Bitbucket
+		if secret := strings.TrimSpace(ReadFromFile(ctx, logger, "BITBUCKET_WEBHOOK_SECRET_FILE", "")); secret != "" {
+			bitbucketApp, err = bitbucket.CreateBitbucket(ctx,
+				log.NewSimpleLogger(trace, "Bitbucket"),
+				bitbucket.Config{
+					WebhookSecret: secret,
+					CloudAPIURL:   env.GetStringEnv(ctx, logger, "BITBUCKET_API_URL", "https://api.bitbucket.org/2.0"),
+					ServerURL:     env.GetStringEnv(ctx, logger, "BITBUCKET_SERVER_URL", ""),
+					StatusKey:     env.GetStringEnv(ctx, logger, "BITBUCKET_STATUS_KEY", "nomad-ops"),
+					StatusBaseURL: env.GetStringEnv(ctx, logger, "BITBUCKET_STATUS_BASE_URL", "http://localhost:3000/ui/sources/"),
+					Transport:     gitTransport,
+				},
+				dsw,
+				srcStore,
+				keyStore,
+				watcher)
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateBitbucket:%v", err)
+				os.Exit(-2)
+			}
+		}
+
+		var azureDevOps *azuredevops.AzureDevOps
+		if secret := strings.TrimSpace(ReadFromFile(ctx, logger, "AZURE_DEVOPS_WEBHOOK_SECRET_FILE", "")); secret != "" {
+			azureDevOps, err = azuredevops.CreateAzureDevOps(ctx,
+				log.NewSimpleLogger(trace, "AzureDevOps"),
+				azuredevops.Config{
+					WebhookSecret: secret,
+					StatusGenre:   env.GetStringEnv(ctx, logger, "AZURE_DEVOPS_STATUS_GENRE", "nomad-ops"),
+					StatusBaseURL: env.GetStringEnv(ctx, logger, "AZURE_DEVOPS_STATUS_BASE_URL", "http://localhost:3000/ui/sources/"),
+					Transport:     gitTransport,
+				},
+				dsw,
+				srcStore,
+				keyStore,
+				watcher)
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateAzureDevOps:%v", err)
+				os.Exit(-2)
+			}
+		}
+
+		var registryWebhook *registry.Registry
+		if secret := strings.TrimSpace(ReadFromFile(ctx, logger, "REGISTRY_WEBHOOK_SECRET_FILE", "")); secret != "" {
+			registryWebhook, err = registry.CreateRegistry(ctx,
+				log.NewSimpleLogger(trace, "Registry"),
+				registry.Config{
+					Secret:  secret,
+					Restart: env.GetStringEnv(ctx, logger, "REGISTRY_WEBHOOK_RESTART", "FALSE") == "TRUE",
+				},
+				nomadAPI,
+				watcher)
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateRegistry:%v", err)
+				os.Exit(-2)
+			}
+		}
+
 		err = nomadAPI.SubscribeJobChanges(ctx, func(jobName string) {
-			err := watcher.SyncSourceByID(ctx, jobName, application.SyncSourceOptions{})
+			err := watcher.SyncSourceByID(ctx, jobName, application.SyncSourceOptions{
+				Trigger: domain.SyncTriggerNomadEvent,
+			})
 			if err == errors.ErrNotFound {
 				// not handled by us --- ignore
 				return
@@ -308,9 +997,54 @@ func main() {
 			os.Exit(-2)
 		}
 
+		// a standby replicates the store of the primary and reconciles no source until it got promoted
+		var shard application.Shard
+		var standby *application.Standby
+		if primary := env.GetStringEnv(ctx, logger, "STANDBY_PRIMARY_URL", ""); primary != "" {
+			replicationClient, err := replication.CreateClient(ctx,
+				log.NewSimpleLogger(trace, "Replication-Client"),
+				replication.ClientConfig{
+					PrimaryURL: primary,
+					Token:      replicationToken,
+					Timeout:    env.GetDurationEnv(ctx, logger, "STANDBY_REPLICATION_TIMEOUT", time.Minute),
+				})
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateClient for replication:%v", err)
+				os.Exit(-2)
+			}
+			standby, err = application.CreateStandby(ctx,
+				log.NewSimpleLogger(trace, "Standby"),
+				application.StandbyConfig{
+					Primary:  primary,
+					Interval: env.GetDurationEnv(ctx, logger, "STANDBY_REPLICATION_INTERVAL", 10*time.Second),
+					AppName:  env.GetStringEnv(ctx, logger, "APP_NAME", "nomad-ops"),
+				},
+				replicationClient,
+				replicationStore)
+			if err != nil {
+				logger.LogError(ctx, "Could not CreateStandby:%v", err)
+				os.Exit(-2)
+			}
+			shard = standby
+		}
+
 		manager, err := application.CreateReconciliationManager(ctx,
 			log.NewSimpleLogger(trace, "ReconciliationManager"),
-			application.ReconciliationManagerConfig{},
+			application.ReconciliationManagerConfig{
+				JobParallelism:     env.GetIntEnv(ctx, logger, "RECONCILE_JOB_PARALLELISM", 1),
+				JobRetryBackoff:    env.GetDurationEnv(ctx, logger, "RECONCILE_JOB_RETRY_BACKOFF", 30*time.Second),
+				JobRetryMaxBackoff: env.GetDurationEnv(ctx, logger, "RECONCILE_JOB_RETRY_MAX_BACKOFF", 10*time.Minute),
+				Shard:              shard,
+
+				StartupReportTimeout: env.GetDurationEnv(ctx, logger, "STARTUP_REPORT_TIMEOUT", 15*time.Minute),
+				StartupReportNotify:  env.GetStringEnv(ctx, logger, "STARTUP_REPORT_NOTIFY", "FALSE") == "TRUE",
+				Limits: application.JobLimits{
+					MaxJobsPerSource: env.GetIntEnv(ctx, logger, "MAX_JOBS_PER_SOURCE", 0),
+					MaxJobSize:       env.GetIntEnv(ctx, logger, "MAX_JOB_SIZE", 0),
+					MaxTaskGroups:    env.GetIntEnv(ctx, logger, "MAX_TASK_GROUPS", 0),
+				},
+				Annotations: annotationStore,
+			},
 			srcStore,
 			watcher,
 			nomadAPI,
@@ -320,6 +1054,137 @@ func main() {
 			logger.LogError(ctx, "Could not CreateReconciliationManager:%v", err)
 			os.Exit(-2)
 		}
+		// syncs requested right before the last shutdown or crash
+		err = watcher.ReplayPendingSyncs(ctx)
+		if err != nil {
+			logger.LogError(ctx, "Could not ReplayPendingSyncs:%v", err)
+		}
+		if standby != nil {
+			go standby.Run(ctx)
+		}
+
+		// deleteSource stops watching the source, prunes or retains its resources and deletes it.
+		// The source is watched again if that fails.
+		deleteSource := func(reqCtx context.Context, rec *models.Record, retain bool) (*nomadcluster.SourceDeletionPlan, []string, error) {
+			src := domain.SourceFromRecord(rec, false)
+			// stop syncing first, a running sync would recreate the jobs
+			err := manager.OnDeletedSource(reqCtx, rec.Id)
+			var plan *nomadcluster.SourceDeletionPlan
+			var keptNamespaces []string
+			if err == nil {
+				plan, err = nomadAPI.PlanSourceDeletion(reqCtx, src)
+			}
+			if err == nil {
+				keptNamespaces, err = nomadAPI.PruneSource(reqCtx, src, plan, retain)
+			}
+			if err == nil {
+				err = app.Dao().DeleteRecord(rec)
+			}
+			if err != nil {
+				if err := manager.OnAddedSource(ctx, src); err != nil {
+					logger.LogError(reqCtx, "Could not resume watching source %s:%v", rec.Id, err)
+				}
+				return nil, nil, err
+			}
+			if incidents != nil {
+				incidents.ResolveSource(reqCtx, rec.Id)
+			}
+			if statusPublisher != nil {
+				statusPublisher.RemoveSource(reqCtx, rec.Id)
+			}
+			return plan, keptNamespaces, nil
+		}
+
+		sourceDeleter := sourceDeleterFunc(func(reqCtx context.Context, src *domain.Source) error {
+			rec, err := app.Dao().FindRecordById("sources", src.ID)
+			if err != nil {
+				return err
+			}
+			_, _, err = deleteSource(reqCtx, rec, false)
+			return err
+		})
+
+		expirer, err := application.CreateSourceExpirer(ctx,
+			log.NewSimpleLogger(trace, "SourceExpirer"),
+			application.SourceExpirerConfig{
+				Interval: env.GetDurationEnv(ctx, logger, "SOURCE_EXPIRY_INTERVAL", time.Minute),
+				Warning:  env.GetDurationEnv(ctx, logger, "SOURCE_EXPIRY_WARNING", 24*time.Hour),
+				Shard:    shard,
+			},
+			srcStore,
+			sourceDeleter,
+			notificationComposer)
+		if err != nil {
+			return err
+		}
+		go expirer.Run(ctx)
+
+		bulkOperator, err := application.CreateBulkOperator(ctx,
+			log.NewSimpleLogger(trace, "BulkOperator"),
+			srcStore,
+			manager,
+			watcher,
+			sourceDeleter,
+			shard)
+		if err != nil {
+			return err
+		}
+
+		promoter, err := application.CreatePromoter(ctx,
+			log.NewSimpleLogger(trace, "Promoter"),
+			srcStore,
+			manager,
+			notificationComposer)
+		if err != nil {
+			return err
+		}
+		watcher.OnSynced(promoter.OnSynced)
+
+		snapshotter, err := application.CreateSnapshotter(ctx,
+			log.NewSimpleLogger(trace, "Snapshotter"),
+			application.SnapshotterConfig{
+				URL:         env.GetStringEnv(ctx, logger, "SNAPSHOT_REPO_URL", ""),
+				Branch:      env.GetStringEnv(ctx, logger, "SNAPSHOT_BRANCH", "deployed"),
+				AuthorName:  env.GetStringEnv(ctx, logger, "SNAPSHOT_AUTHOR_NAME", "nomad-ops"),
+				AuthorEmail: env.GetStringEnv(ctx, logger, "SNAPSHOT_AUTHOR_EMAIL", "nomad-ops@localhost"),
+			},
+			nomadAPI,
+			dsw)
+		if err != nil {
+			return err
+		}
+		watcher.OnSynced(snapshotter.OnSynced)
+
+		jobHistoryStore, err := jobhistorystore.CreatePocketBaseStore(ctx,
+			log.NewSimpleLogger(trace, "JobHistoryStore-PocketBase"),
+			jobhistorystore.PocketBaseStoreConfig{
+				App: e.App,
+			})
+		if err != nil {
+			return err
+		}
+		jobHistory, err := application.CreateJobHistory(ctx,
+			log.NewSimpleLogger(trace, "JobHistory"),
+			nomadAPI,
+			jobHistoryStore)
+		if err != nil {
+			return err
+		}
+		watcher.OnSynced(jobHistory.OnSynced)
+
+		importer, err := application.CreateImporter(ctx,
+			log.NewSimpleLogger(trace, "Importer"),
+			application.ImporterConfig{
+				AuthorName:  env.GetStringEnv(ctx, logger, "SNAPSHOT_AUTHOR_NAME", "nomad-ops"),
+				AuthorEmail: env.GetStringEnv(ctx, logger, "SNAPSHOT_AUTHOR_EMAIL", "nomad-ops@localhost"),
+			},
+			nomadAPI,
+			dsw,
+			srcStore,
+			manager)
+		if err != nil {
+			return err
+		}
 
 		app.OnRecordAfterCreateRequest().Add(func(e *core.RecordCreateEvent) error {
 			if e.Collection.Name == "sources" {
@@ -344,7 +1209,9 @@ func main() {
 		app.OnRecordAfterUpdateRequest().Add(func(e *core.RecordUpdateEvent) error {
 			if e.Collection.Name == "sources" {
 				// Update watch
-				err := watcher.UpdateSource(e.HttpContext.Request().Context(), domain.SourceFromRecord(e.Record, true))
+				actionCtx := application.WithAction(e.HttpContext.Request().Context(),
+					newAction(e.HttpContext, domain.AuditActionTypeUpdate))
+				err := manager.OnUpdatedSource(actionCtx, domain.SourceFromRecord(e.Record, true))
 				if err != nil {
 					logger.LogError(ctx, "Could not UpdateSource:%v", err)
 					return err
@@ -355,6 +1222,25 @@ func main() {
 			return nil
 		})
 
+		app.OnRecordBeforeDeleteRequest().Add(func(e *core.RecordDeleteEvent) error {
+			if e.Collection.Name != "sources" {
+				return nil
+			}
+			// the cluster is asked, the search index is empty until the first sync after a restart
+			state, err := nomadAPI.GetCurrentClusterState(e.HttpContext.Request().Context(), application.GetCurrentClusterStateOptions{
+				Source: domain.SourceFromRecord(e.Record, false),
+			})
+			if err != nil {
+				logger.LogError(ctx, "Could not GetCurrentClusterState of source %s:%v", e.Record.Id, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Could not check the jobs of the source", nil)
+			}
+			if len(state.CurrentJobs) > 0 {
+				return apis.NewBadRequestError(
+					fmt.Sprintf("The source still manages jobs, delete it with POST /api/nomad/sources/%s/delete", e.Record.Id), nil)
+			}
+			return nil
+		})
+
 		app.OnRecordAfterDeleteRequest().Add(func(e *core.RecordDeleteEvent) error {
 			if e.Collection.Name == "sources" {
 				logger.LogInfo(ctx, "Removing source from watch...")
@@ -363,6 +1249,12 @@ func main() {
 					logger.LogError(ctx, "Could not handle deleted source:%v", err)
 					return err
 				}
+				if incidents != nil {
+					incidents.ResolveSource(e.HttpContext.Request().Context(), e.Record.Id)
+				}
+				if statusPublisher != nil {
+					statusPublisher.RemoveSource(e.HttpContext.Request().Context(), e.Record.Id)
+				}
 			}
 
 			return nil
@@ -418,6 +1310,10 @@ func main() {
 			return nil
 		})
 
+		if ldapAuth != nil {
+			registerLDAPRoutes(e, logger, ldapAuth, userStore, teamStore)
+		}
+
 		wwwroot, err := fs.Sub(public, "wwwroot")
 		if err != nil {
 			return err
@@ -425,6 +1321,14 @@ func main() {
 
 		e.Router.Add("GET", "/*", apis.StaticDirectoryHandler(wwwroot, true))
 
+		registerGitWebhookRoutes(e, logger, gitHubApp, bitbucketApp, azureDevOps)
+
+		if registryWebhook != nil {
+			registerRegistryWebhookRoutes(e, logger, registryWebhook)
+		}
+
+		registerBulkRoutes(e, app, logger, bulkOperator, importer)
+
 		// add new "POST /api/actions/sources/sync" route
 		e.Router.AddRoute(echo.Route{
 			Method: http.MethodPost,
@@ -433,17 +1337,22 @@ func main() {
 				id := c.QueryParam("id")
 				if id == "" {
 					return c.JSON(http.StatusBadRequest, domain.Error{
+						Code:    domain.ErrorCodeInvalidRequest,
 						Message: log.ToStrPtr("Expected a valid 'id' parameter"),
 					})
 				}
 
-				logger.LogInfo(c.Request().Context(), "Syncing source %s...", id)
+				action := newAction(c, domain.AuditActionTypeSync)
+
+				logger.LogInfo(c.Request().Context(), "Syncing source %s (action %s by %s)...", id, action.ID, action.Actor)
 				err := watcher.SyncSourceByID(c.Request().Context(), id, application.SyncSourceOptions{
 					ForceRestart: false,
+					Action:       action,
 				})
 
 				if err == errors.ErrNotFound {
 					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
 						Message: log.ToStrPtr("Source was not found"),
 					})
 				}
@@ -451,11 +1360,14 @@ func main() {
 				if err != nil {
 					logger.LogError(c.Request().Context(), "Could not UpdateSourceByID:%v", err)
 					return c.JSON(http.StatusInternalServerError, domain.Error{
+						Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
 						Message: log.ToStrPtr("Unexpected error"),
 					})
 				}
 
-				return c.JSON(http.StatusOK, map[string]string{}) // empty 200 OK response
+				return c.JSON(http.StatusOK, map[string]string{
+					"actionId": action.ID,
+				})
 			},
 			Middlewares: []echo.MiddlewareFunc{
 				func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -467,6 +1379,7 @@ func main() {
 						id := c.QueryParam("id")
 						if id == "" {
 							return c.JSON(http.StatusBadRequest, domain.Error{
+								Code:    domain.ErrorCodeInvalidRequest,
 								Message: log.ToStrPtr("Expected a valid 'id' parameter"),
 							})
 						}
@@ -476,31 +1389,9 @@ func main() {
 							return err
 						}
 
-						teamIDs := rec.GetStringSlice("teams")
-
-						if len(teamIDs) == 0 {
-							// No team "owns" this source
-							// anybody may sync
-							return next(c)
-						}
-
-						found := false
-						for _, teamID := range teamIDs {
-							teamRec, err := app.Dao().FindRecordById("teams", teamID)
-							if err != nil {
-								return err
-							}
-
-							members := teamRec.GetStringSlice("members")
-							for _, member := range members {
-								if member == authRecord.Id {
-									found = true
-									break
-								}
-							}
-							if found {
-								break
-							}
+						found, err := isSourceTeamMember(app, authRecord, rec)
+						if err != nil {
+							return err
 						}
 
 						if !found {
@@ -520,6 +1411,29 @@ func main() {
 			},
 		})
 
+		registerAnnotationRoutes(e, app, logger, annotationStore)
+
+		registerSourceRoutes(ctx, e, app, logger, srcStore, nomadAPI, manager, deleteSource)
+
+		registerJobRoutes(e, app, logger, nomadAPI)
+
+		registerClusterRoutes(e, app, logger, nomadAPI, manager)
+
+		registerStatusRoutes(e, app, logger, srcStore, statusTokenStore)
+
+		registerPromotionRoutes(e, app, logger, promoter)
+
+		registerRenderRoutes(e, app, logger, dsw, watcher)
+
+		registerHistoryRoutes(e, app, logger, jobHistory)
+
+		// the proxy gives admins access to everything the Nomad token of nomad-ops can read,
+		// users only read the jobs of the sources of their teams
+		proxyAuth := apis.RequireAdminOrRecordAuth("users")
+		if env.GetStringEnv(ctx, logger, "NOMAD_PROXY_ADMIN_ONLY", "FALSE") == "TRUE" {
+			proxyAuth = apis.RequireAdminAuth()
+		}
+
 		e.Router.AddRoute(echo.Route{
 			Method: http.MethodGet, // Read only, but still a user might see too much
 			Path:   "/api/nomad/proxy/*",
@@ -536,9 +1450,23 @@ func main() {
 					}
 					params[k] = v[0]
 				}
+				path := strings.TrimPrefix(c.Request().URL.EscapedPath(), "/api/nomad/proxy")
+
+				if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+					allowed, err := isProxiedJobOfTeam(app, authRecord, path, params["namespace"])
+					if err != nil {
+						return err
+					}
+					if !allowed {
+						return c.JSON(http.StatusForbidden, domain.Error{
+							Code:    domain.ErrorCodeUnauthorized,
+							Message: log.ToStrPtr("Users can only read the jobs of the sources of their teams"),
+						})
+					}
+				}
 
 				resp, err := nomadAPI.ProxyHandler(c.Request().Context(),
-					strings.TrimPrefix(c.Request().URL.EscapedPath(), "/api/nomad/proxy"),
+					path,
 					api.QueryOptions{
 						Params: params,
 					})
@@ -546,6 +1474,7 @@ func main() {
 				if err != nil {
 					logger.LogError(c.Request().Context(), "Could not handle Nomad Proxy Request:%v", err)
 					return c.JSON(http.StatusInternalServerError, domain.Error{
+						Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
 						Message: log.ToStrPtr("Unexpected error"),
 					})
 				}
@@ -554,7 +1483,7 @@ func main() {
 				return c.Stream(http.StatusOK, "application/json", resp)
 			},
 			Middlewares: []echo.MiddlewareFunc{
-				apis.RequireAdminOrRecordAuth("users"),
+				proxyAuth,
 				middleware.CORSWithConfig(middleware.CORSConfig{}),
 				middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
 				middleware.Recover(),
@@ -570,12 +1499,14 @@ func main() {
 				u, err := nomadAPI.GetURL(c.Request().Context())
 				if err != nil {
 					return c.JSONPretty(http.StatusInternalServerError, domain.Error{
+						Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
 						Message: log.ToStrPtr("Unexpected error"),
 					}, "    ")
 				}
 
-				return c.JSONPretty(http.StatusOK, map[string]string{
-					"ui": u,
+				return c.JSONPretty(http.StatusOK, map[string]interface{}{
+					"ui":      u,
+					"regions": uiRegionURLs,
 				}, "    ")
 			},
 			Middlewares: []echo.MiddlewareFunc{
@@ -587,6 +1518,16 @@ func main() {
 			},
 		})
 
+		if freezeCalendar != nil {
+			registerFreezeRoutes(e, logger, freezeCalendar, watcher)
+		}
+
+		registerStandbyRoutes(ctx, e, logger, replicationToken, replicationStore, standby, manager, watcher)
+
+		registerReconcileRoutes(e, logger, watcher)
+
+		registerSessionRoutes(e, logger, sessionStore, sessionPolicy)
+
 		logger.LogInfo(ctx, "Initialization done")
 
 		_, err = mon.StartMon(ctx, log.NewSimpleLogger(logger.IsTraceEnabled(ctx), "Monitor"), mon.Config{
@@ -603,6 +1544,162 @@ func main() {
 	}
 }
 
+// isSourceTeamMember returns true if the source is not owned by any team
+// or the user is a member of at least one of the owning teams
+func isSourceTeamMember(app core.App, authRecord *models.Record, src *models.Record) (bool, error) {
+	teamIDs := src.GetStringSlice("teams")
+
+	if len(teamIDs) == 0 {
+		// No team "owns" this source
+		return true, nil
+	}
+
+	for _, teamID := range teamIDs {
+		teamRec, err := app.Dao().FindRecordById("teams", teamID)
+		if err != nil {
+			return false, err
+		}
+
+		for _, member := range teamRec.GetStringSlice("members") {
+			if member == authRecord.Id {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// isProxiedJobOfTeam returns whether the proxied path reads a job, e.g. /v1/job/<id>/allocations,
+// of a source the user is a team member of
+func isProxiedJobOfTeam(app core.App, authRecord *models.Record, path, namespace string) (bool, error) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) < 3 || parts[0] != "v1" || parts[1] != "job" {
+		return false, nil
+	}
+	jobID, err := url.PathUnescape(parts[2])
+	if err != nil {
+		return false, nil
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	records, err := app.Dao().FindRecordsByExpr("sources")
+	if err != nil {
+		return false, err
+	}
+	for _, rec := range records {
+		src := domain.SourceFromRecord(rec, false)
+		if src.Status == nil {
+			continue
+		}
+		// jobs of the same name in several namespaces are keyed by namespace and name
+		job, ok := src.Status.Jobs[namespace+"/"+jobID]
+		if !ok {
+			job, ok = src.Status.Jobs[jobID]
+		}
+		if !ok || (job.Namespace != namespace && !(job.Namespace == "" && namespace == "default")) {
+			continue
+		}
+		found, err := isSourceTeamMember(app, authRecord, rec)
+		if err != nil {
+			return false, err
+		}
+		if found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isRepoTeamMember returns whether the user is a team member of at least one source of the repository
+func isRepoTeamMember(app core.App, authRecord *models.Record, repo string) (bool, error) {
+	records, err := app.Dao().FindRecordsByExpr("sources")
+	if err != nil {
+		return false, err
+	}
+	for _, rec := range records {
+		if application.RepoName(rec.GetString("url")) != repo {
+			continue
+		}
+		found, err := isSourceTeamMember(app, authRecord, rec)
+		if err != nil {
+			return false, err
+		}
+		if found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// visibleSources returns whether the user of the request is a team member of a source, admins see all sources
+func visibleSources(app core.App, logger log.Logger, c echo.Context) func(srcID string) bool {
+	authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	members := map[string]bool{}
+	return func(srcID string) bool {
+		if authRecord == nil {
+			return true
+		}
+		member, ok := members[srcID]
+		if !ok {
+			rec, err := app.Dao().FindRecordById("sources", srcID)
+			if err == nil {
+				member, err = isSourceTeamMember(app, authRecord, rec)
+			}
+			if err != nil {
+				// e.g. a deleted source whose jobs are still running
+				logger.LogTrace(c.Request().Context(), "Could not check the team of source %s:%v", srcID, err)
+			}
+			members[srcID] = member
+		}
+		return member
+	}
+}
+
+// validateLabels rejects labels that are no object of strings or invalid
+func validateLabels(record *models.Record) error {
+	if record.GetString("labels") == "" {
+		return nil
+	}
+	labels := map[string]string{}
+	err := record.UnmarshalJSONField("labels", &labels)
+	if err != nil {
+		return apis.NewBadRequestError("The labels must be an object of strings", nil)
+	}
+	err = domain.ValidateLabels(labels)
+	if err != nil {
+		return apis.NewBadRequestError(err.Error(), nil)
+	}
+	return nil
+}
+
+// sourceDeleterFunc adapts a function to application.SourceDeleter
+type sourceDeleterFunc func(ctx context.Context, src *domain.Source) error
+
+func (f sourceDeleterFunc) DeleteSource(ctx context.Context, src *domain.Source) error {
+	return f(ctx, src)
+}
+
+// newAction creates an audit action for the user or admin of the request
+func newAction(c echo.Context, t domain.AuditActionType) *application.Action {
+	a := &application.Action{
+		ID:   security.RandomString(15),
+		Type: t,
+		Time: time.Now(),
+	}
+	if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+		a.UserID = authRecord.Id
+		a.Actor = authRecord.Email()
+		if a.Actor == "" {
+			a.Actor = authRecord.Username()
+		}
+	}
+	if admin, _ := c.Get(apis.ContextAdminKey).(*models.Admin); admin != nil {
+		a.Actor = "admin:" + admin.Email
+	}
+	return a
+}
+
 func ReadFromFile(ctx context.Context, logger log.Logger, key string, def string) string {
 	fp := env.GetStringEnv(ctx, logger, key, "")
 	if fp == "" {
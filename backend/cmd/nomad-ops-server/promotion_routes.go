@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerPromotionRoutes adds the routes showing and approving the promotions of sources
+func registerPromotionRoutes(e *core.ServeEvent,
+	app core.App,
+	logger log.Logger,
+	promoter *application.Promoter) {
+	// stages of the promotion pipeline of a source
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/sources/:id/pipeline",
+		Handler: func(c echo.Context) error {
+			if !visibleSources(app, logger, c)(c.PathParam("id")) {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+			stages, err := promoter.Pipeline(c.Request().Context(), c.PathParam("id"))
+			if err != nil {
+				if err == errors.ErrNotFound {
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Source was not found"),
+					})
+				}
+				logger.LogError(c.Request().Context(), "Could not get the pipeline:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, stages)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// approves (POST) or rejects (DELETE) the pending promotion of a source
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		method := method
+		e.Router.AddRoute(echo.Route{
+			Method: method,
+			Path:   "/api/nomad/sources/:id/promotion",
+			Handler: func(c echo.Context) error {
+				if !visibleSources(app, logger, c)(c.PathParam("id")) {
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Source was not found"),
+					})
+				}
+				reqCtx := application.WithAction(c.Request().Context(), newAction(c, domain.AuditActionTypeUpdate))
+				var err error
+				if method == http.MethodPost {
+					_, err = promoter.Approve(reqCtx, c.PathParam("id"))
+				} else {
+					err = promoter.Reject(reqCtx, c.PathParam("id"))
+				}
+				if err != nil {
+					switch {
+					case err == errors.ErrNotFound:
+						return c.JSON(http.StatusNotFound, domain.Error{
+							Code:    domain.ErrorCodeNotFound,
+							Message: log.ToStrPtr("Source was not found"),
+						})
+					case domain.ErrorCodeOf(err, "") == domain.ErrorCodeInvalidRequest:
+						return c.JSON(http.StatusBadRequest, domain.Error{
+							Code:    domain.ErrorCodeInvalidRequest,
+							Message: log.ToStrPtr(err.Error()),
+						})
+					}
+					logger.LogError(c.Request().Context(), "Could not handle the promotion:%v", err)
+					return c.JSON(http.StatusInternalServerError, domain.Error{
+						Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+						Message: log.ToStrPtr("Unexpected error"),
+					})
+				}
+				return c.NoContent(http.StatusNoContent)
+			},
+			Middlewares: []echo.MiddlewareFunc{
+				apis.RequireAdminOrRecordAuth("users"),
+				apis.ActivityLogger(e.App),
+				middleware.CORSWithConfig(middleware.CORSConfig{}),
+				middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+				middleware.Recover(),
+				middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+			},
+		})
+	}
+}
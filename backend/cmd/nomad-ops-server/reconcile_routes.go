@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerReconcileRoutes adds the routes admins watch and cancel running syncs with
+func registerReconcileRoutes(e *core.ServeEvent,
+	logger log.Logger,
+	watcher *application.RepoWatcher) {
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/admin/reconciles",
+		Handler: func(c echo.Context) error {
+			return c.JSON(http.StatusOK, watcher.ListReconciles(c.Request().Context()))
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodDelete,
+		Path:   "/api/admin/reconciles/:id",
+		Handler: func(c echo.Context) error {
+			logger.LogInfo(c.Request().Context(), "Cancelling running sync of source %s...", c.PathParam("id"))
+			err := watcher.CancelReconcile(c.Request().Context(), c.PathParam("id"))
+			if err == errors.ErrNotFound {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("No running sync of the source was found"),
+				})
+			}
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not CancelReconcile:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.NoContent(http.StatusNoContent)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
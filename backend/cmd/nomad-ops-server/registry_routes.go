@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/registry"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerRegistryWebhookRoutes adds the route container registries notify about pushed images
+func registerRegistryWebhookRoutes(e *core.ServeEvent,
+	logger log.Logger,
+	registryWebhook *registry.Registry) {
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/registry/webhook/:provider",
+		Handler: func(c echo.Context) error {
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, 25*1024*1024))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Could not read body"),
+				})
+			}
+			// Docker Hub can only pass the token in the url, Harbor sends it as auth header
+			token := c.QueryParam("token")
+			if h := c.Request().Header.Get("Authorization"); h != "" {
+				token = strings.TrimPrefix(h, "Bearer ")
+			}
+			synced, err := registryWebhook.HandleWebhook(c.Request().Context(),
+				c.PathParam("provider"),
+				token,
+				c.Request().Header.Get("X-Hub-Signature-256"),
+				body)
+			if err == registry.ErrUnauthorized {
+				return c.JSON(http.StatusUnauthorized, domain.Error{
+					Code:    domain.ErrorCodeUnauthorized,
+					Message: log.ToStrPtr("Invalid token or signature"),
+				})
+			}
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not HandleWebhook:%v", err)
+				code := domain.ErrorCodeOf(err, domain.ErrorCodeInternal)
+				status := http.StatusInternalServerError
+				if code == domain.ErrorCodeInvalidRequest {
+					status = http.StatusBadRequest
+				}
+				return c.JSON(status, domain.Error{
+					Code:    code,
+					Message: log.ToStrPtr("Could not handle webhook"),
+				})
+			}
+			return c.JSON(http.StatusAccepted, map[string][]string{
+				"sources": synced,
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
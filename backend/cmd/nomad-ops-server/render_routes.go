@@ -0,0 +1,279 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/github"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerRenderRoutes adds the routes rendering the jobs, bundle lockfiles and variable schemas of sources
+func registerRenderRoutes(e *core.ServeEvent,
+	app core.App,
+	logger log.Logger,
+	dsw *github.GitProvider,
+	watcher *application.RepoWatcher) {
+	// rendered jobs of a source at a commit, e.g. for golden-file tests
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/sources/:id/rendered",
+		Handler: func(c echo.Context) error {
+			rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+			if err != nil {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+
+			if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+				found, err := isSourceTeamMember(app, authRecord, rec)
+				if err != nil {
+					return err
+				}
+				if !found {
+					// do not reveal that the source exists
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Source was not found"),
+					})
+				}
+			}
+
+			src := domain.SourceFromRecord(rec, false)
+			desiredState, err := dsw.FetchDesiredStateAtCommit(c.Request().Context(), src, c.QueryParam("commit"))
+			if err == nil {
+				var b []byte
+				b, err = watcher.RenderDesiredState(c.Request().Context(), src, desiredState)
+				if err == nil {
+					c.Response().Header().Set("X-Git-Commit", desiredState.GitInfo.GitCommit)
+					return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, b)
+				}
+			}
+
+			code := domain.ErrorCodeOf(err, domain.ErrorCodeInternal)
+			switch code {
+			case domain.ErrorCodeInvalidRequest, domain.ErrorCodeParseError, domain.ErrorCodeJobConflict:
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    code,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			case domain.ErrorCodeNotFound:
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    code,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			}
+			logger.LogError(c.Request().Context(), "Could not render source %s:%v", rec.Id, err)
+			return c.JSON(http.StatusInternalServerError, domain.Error{
+				Code:    code,
+				Message: log.ToStrPtr("Unexpected error"),
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// lockfile of the bundles of a source at a commit, it is committed as nomad-ops.bundles.lock.json
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/sources/:id/bundles/lock",
+		Handler: func(c echo.Context) error {
+			rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+			if err != nil {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+
+			if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+				found, err := isSourceTeamMember(app, authRecord, rec)
+				if err != nil {
+					return err
+				}
+				if !found {
+					// do not reveal that the source exists
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Source was not found"),
+					})
+				}
+			}
+
+			src := domain.SourceFromRecord(rec, false)
+			desiredState, err := dsw.FetchDesiredStateAtCommit(c.Request().Context(), src, c.QueryParam("commit"))
+			if err == nil {
+				lock := domain.BundleLock{
+					Bundles: desiredState.Bundles,
+				}
+				if lock.Bundles == nil {
+					lock.Bundles = []domain.LockedBundle{}
+				}
+				c.Response().Header().Set("X-Git-Commit", desiredState.GitInfo.GitCommit)
+				return c.JSON(http.StatusOK, lock)
+			}
+
+			code := domain.ErrorCodeOf(err, domain.ErrorCodeInternal)
+			switch code {
+			case domain.ErrorCodeInvalidRequest, domain.ErrorCodeParseError, domain.ErrorCodeJobConflict:
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    code,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			case domain.ErrorCodeNotFound:
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    code,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			}
+			logger.LogError(c.Request().Context(), "Could not resolve the bundles of source %s:%v", rec.Id, err)
+			return c.JSON(http.StatusInternalServerError, domain.Error{
+				Code:    code,
+				Message: log.ToStrPtr("Unexpected error"),
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// schema of the variables of a source, nomad-ops.vars.schema.json next to its job files, null if there is none
+	varsSchema := func(c echo.Context, src *domain.Source) error {
+		schema, err := dsw.FetchVarsSchema(c.Request().Context(), src)
+		if err == nil {
+			return c.JSON(http.StatusOK, struct {
+				Schema *domain.VarsSchema `json:"schema"`
+			}{
+				Schema: schema,
+			})
+		}
+		code := domain.ErrorCodeOf(err, domain.ErrorCodeInternal)
+		switch code {
+		case domain.ErrorCodeInvalidRequest, domain.ErrorCodeGitAuthFailed:
+			return c.JSON(http.StatusBadRequest, domain.Error{
+				Code:    code,
+				Message: log.ToStrPtr(err.Error()),
+			})
+		case domain.ErrorCodeNotFound:
+			return c.JSON(http.StatusNotFound, domain.Error{
+				Code:    code,
+				Message: log.ToStrPtr(err.Error()),
+			})
+		}
+		logger.LogError(c.Request().Context(), "Could not fetch the variable schema of %s:%v", src.URL, err)
+		return c.JSON(http.StatusInternalServerError, domain.Error{
+			Code:    code,
+			Message: log.ToStrPtr("Unexpected error"),
+		})
+	}
+
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/sources/:id/vars-schema",
+		Handler: func(c echo.Context) error {
+			rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+			if err != nil {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+
+			if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+				found, err := isSourceTeamMember(app, authRecord, rec)
+				if err != nil {
+					return err
+				}
+				if !found {
+					// do not reveal that the source exists
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Source was not found"),
+					})
+				}
+			}
+
+			return varsSchema(c, domain.SourceFromRecord(rec, false))
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// schema of the variables of a source that is about to be created, e.g. to render the form of its variables
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/nomad/vars-schema",
+		Handler: func(c echo.Context) error {
+			req := struct {
+				URL       string `json:"url"`
+				Branch    string `json:"branch"`
+				Revision  string `json:"revision"`
+				Path      string `json:"path"`
+				DeployKey string `json:"deployKey"`
+			}{}
+			if err := c.Bind(&req); err != nil || req.URL == "" || req.Branch == "" || req.Path == "" {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected the url, branch and path of the source"),
+				})
+			}
+			if req.DeployKey != "" {
+				key, err := app.Dao().FindRecordById("keys", req.DeployKey)
+				visible := err == nil
+				if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); visible && authRecord != nil && key.GetString("team") != "" {
+					// keys of a team are only used by its members
+					visible = false
+					team, err := app.Dao().FindRecordById("teams", key.GetString("team"))
+					if err == nil {
+						for _, member := range team.GetStringSlice("members") {
+							visible = visible || member == authRecord.Id
+						}
+					}
+				}
+				if !visible {
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Deploy key was not found"),
+					})
+				}
+			}
+			return varsSchema(c, &domain.Source{
+				URL:         req.URL,
+				Branch:      req.Branch,
+				Revision:    req.Revision,
+				Path:        req.Path,
+				DeployKeyID: req.DeployKey,
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimit(64 * 1024),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
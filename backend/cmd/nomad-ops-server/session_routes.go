@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/sessionstore"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerSessionRoutes adds the routes admins list and revoke the sessions of users with
+func registerSessionRoutes(e *core.ServeEvent,
+	logger log.Logger,
+	sessionStore *sessionstore.PocketBaseStore,
+	sessionPolicy domain.SessionPolicy) {
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/admin/sessions",
+		Handler: func(c echo.Context) error {
+			sessions, err := sessionStore.ListSessions(c.Request().Context(), c.QueryParam("user"))
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not ListSessions:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+
+			all := c.QueryParam("all") == "true"
+			now := time.Now()
+			res := []*domain.Session{}
+			for _, s := range sessions {
+				if all || s.IsActive(now, sessionPolicy) {
+					res = append(res, s)
+				}
+			}
+			return c.JSON(http.StatusOK, res)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodDelete,
+		Path:   "/api/admin/sessions/:id",
+		Handler: func(c echo.Context) error {
+			logger.LogInfo(c.Request().Context(), "Revoking session %s...", c.PathParam("id"))
+			err := sessionStore.RevokeSession(c.Request().Context(), c.PathParam("id"))
+			if err == errors.ErrNotFound {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Session was not found"),
+				})
+			}
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not RevokeSession:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.NoContent(http.StatusNoContent)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodDelete,
+		Path:   "/api/admin/users/:id/sessions",
+		Handler: func(c echo.Context) error {
+			logger.LogInfo(c.Request().Context(), "Revoking all sessions of user %s...", c.PathParam("id"))
+			err := sessionStore.RevokeUserSessions(c.Request().Context(), c.PathParam("id"))
+			if err == errors.ErrNotFound {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("User was not found"),
+				})
+			}
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not RevokeUserSessions:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.NoContent(http.StatusNoContent)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
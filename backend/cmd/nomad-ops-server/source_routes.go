@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/nomadcluster"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/sourcestore"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerSourceRoutes adds the read APIs of a single source and the routes creating and deleting sources
+func registerSourceRoutes(ctx context.Context,
+	e *core.ServeEvent,
+	app core.App,
+	logger log.Logger,
+	srcStore *sourcestore.PocketBaseStore,
+	nomadAPI *nomadcluster.Client,
+	manager *application.ReconciliationManager,
+	deleteSource func(ctx context.Context, rec *models.Record, retain bool) (*nomadcluster.SourceDeletionPlan, []string, error)) {
+	// read APIs scoped to a single source, only team members of the source can access them
+	for path, list := range map[string]func(ctx context.Context, src *domain.Source) (any, error){
+		"jobs": func(ctx context.Context, src *domain.Source) (any, error) {
+			return nomadAPI.ListSourceJobs(ctx, src)
+		},
+		"allocations": func(ctx context.Context, src *domain.Source) (any, error) {
+			return nomadAPI.ListSourceAllocations(ctx, src)
+		},
+		"deployments": func(ctx context.Context, src *domain.Source) (any, error) {
+			return nomadAPI.ListSourceDeployments(ctx, src)
+		},
+	} {
+		path, list := path, list
+		e.Router.AddRoute(echo.Route{
+			Method: http.MethodGet,
+			Path:   "/api/nomad/sources/:id/" + path,
+			Handler: func(c echo.Context) error {
+				rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+				if err != nil {
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Source was not found"),
+					})
+				}
+
+				if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+					found, err := isSourceTeamMember(app, authRecord, rec)
+					if err != nil {
+						return err
+					}
+					if !found {
+						// do not reveal that the source exists
+						return c.JSON(http.StatusNotFound, domain.Error{
+							Code:    domain.ErrorCodeNotFound,
+							Message: log.ToStrPtr("Source was not found"),
+						})
+					}
+				}
+
+				res, err := list(c.Request().Context(), domain.SourceFromRecord(rec, false))
+				if err != nil {
+					logger.LogError(c.Request().Context(), "Could not list %s of source %s:%v", path, rec.Id, err)
+					return c.JSON(http.StatusInternalServerError, domain.Error{
+						Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+						Message: log.ToStrPtr("Unexpected error"),
+					})
+				}
+				return c.JSON(http.StatusOK, res)
+			},
+			Middlewares: []echo.MiddlewareFunc{
+				apis.RequireAdminOrRecordAuth("users"),
+				middleware.CORSWithConfig(middleware.CORSConfig{}),
+				middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+				middleware.Recover(),
+				middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+			},
+		})
+	}
+
+	// jobs of other sources that wait for the jobs of the source, e.g. before pausing it
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/sources/:id/impact",
+		Handler: func(c echo.Context) error {
+			rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+			visible := visibleSources(app, logger, c)
+			if err != nil || !visible(rec.Id) {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+
+			// dependents of all sources count, but only the visible ones are listed
+			g := nomadAPI.JobGraph(c.Request().Context(), func(string) bool { return true })
+			res := struct {
+				Jobs   []*nomadcluster.GraphNode `json:"jobs"`
+				Hidden int                       `json:"hidden"`
+			}{
+				Jobs: []*nomadcluster.GraphNode{},
+			}
+			for _, n := range g.Impact(rec.Id) {
+				if !visible(n.SourceID) {
+					res.Hidden++
+					continue
+				}
+				res.Jobs = append(res.Jobs, n)
+			}
+			return c.JSON(http.StatusOK, res)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// resources that are pruned when the source is deleted
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/nomad/sources/:id/deletion",
+		Handler: func(c echo.Context) error {
+			rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+			if err != nil || !visibleSources(app, logger, c)(rec.Id) {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+
+			plan, err := nomadAPI.PlanSourceDeletion(c.Request().Context(), domain.SourceFromRecord(rec, false))
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not PlanSourceDeletion of %s:%v", rec.Id, err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, plan)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// deletes the source and retains its resources unless pruning is requested, the name of the source confirms the deletion
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/nomad/sources/:id/delete",
+		Handler: func(c echo.Context) error {
+			req := struct {
+				Confirm string `json:"confirm"`
+				// Prune deletes the resources of the source, they are retained by default
+				Prune bool `json:"prune"`
+			}{}
+			if err := c.Bind(&req); err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected confirm and prune"),
+				})
+			}
+
+			rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+			if err != nil || !visibleSources(app, logger, c)(rec.Id) {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+			if req.Confirm != rec.GetString("name") {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Confirm the deletion with the name of the source"),
+				})
+			}
+
+			action := newAction(c, domain.AuditActionTypeDelete)
+			logger.LogInfo(c.Request().Context(), "Deleting source %s (action %s by %s, prune %v)...", rec.Id, action.ID, action.Actor, req.Prune)
+
+			plan, keptNamespaces, err := deleteSource(application.WithAction(c.Request().Context(), action), rec, !req.Prune)
+			if err == nil {
+				return c.JSON(http.StatusOK, map[string]any{
+					"actionId":       action.ID,
+					"retained":       !req.Prune,
+					"plan":           plan,
+					"keptNamespaces": keptNamespaces,
+				})
+			}
+
+			logger.LogError(c.Request().Context(), "Could not delete source %s:%v", rec.Id, err)
+			return c.JSON(http.StatusInternalServerError, domain.Error{
+				Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+				Message: log.ToStrPtr("Could not delete the source, it is watched again"),
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminOrRecordAuth("users"),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimit(64 * 1024),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// creates a source from a template or as clone of another source, the user must be a team member of either
+	for path, collection := range map[string]string{
+		"/api/nomad/source-templates/:id/create": "source_templates",
+		"/api/nomad/sources/:id/clone":           "sources",
+	} {
+		path, collection := path, collection
+		e.Router.AddRoute(echo.Route{
+			Method: http.MethodPost,
+			Path:   path,
+			Handler: func(c echo.Context) error {
+				req := struct {
+					Name   string `json:"name"`
+					Branch string `json:"branch"`
+					Path   string `json:"path"`
+					// the new source expires after this duration, e.g. 72h
+					TTL string `json:"ttl"`
+				}{}
+				if err := c.Bind(&req); err != nil || req.Name == "" {
+					return c.JSON(http.StatusBadRequest, domain.Error{
+						Code:    domain.ErrorCodeInvalidRequest,
+						Message: log.ToStrPtr("Expected the name of the new source"),
+					})
+				}
+
+				rec, err := app.Dao().FindRecordById(collection, c.PathParam("id"))
+				if err != nil {
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Not found"),
+					})
+				}
+				if authRecord, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record); authRecord != nil {
+					found, err := isSourceTeamMember(app, authRecord, rec)
+					if err != nil {
+						return err
+					}
+					if !found {
+						return c.JSON(http.StatusNotFound, domain.Error{
+							Code:    domain.ErrorCodeNotFound,
+							Message: log.ToStrPtr("Not found"),
+						})
+					}
+				}
+
+				ttl := req.TTL
+				if ttl == "" && collection == "source_templates" {
+					ttl = rec.GetString("ttl")
+				}
+				overrides := map[string]string{}
+				if ttl != "" {
+					d, err := time.ParseDuration(ttl)
+					if err != nil || d <= 0 {
+						return c.JSON(http.StatusBadRequest, domain.Error{
+							Code:    domain.ErrorCodeInvalidRequest,
+							Message: log.ToStrPtr("Invalid ttl " + ttl),
+						})
+					}
+					overrides["expiresAt"] = time.Now().Add(d).UTC().Format(types.DefaultDateLayout)
+				}
+				if req.Branch != "" {
+					overrides["branch"] = req.Branch
+				}
+				if req.Path != "" {
+					overrides["path"] = req.Path
+				}
+				var src *domain.Source
+				if collection == "source_templates" {
+					src, err = srcStore.CreateSourceFromTemplate(c.Request().Context(), rec.Id, req.Name, overrides)
+				} else {
+					src, err = srcStore.CloneSource(c.Request().Context(), rec.Id, req.Name, overrides)
+				}
+				if err != nil {
+					if domain.ErrorCodeOf(err, "") == domain.ErrorCodeInvalidRequest {
+						return c.JSON(http.StatusBadRequest, domain.Error{
+							Code:    domain.ErrorCodeInvalidRequest,
+							Message: log.ToStrPtr(err.Error()),
+						})
+					}
+					logger.LogError(c.Request().Context(), "Could not create source from %s %s:%v", collection, rec.Id, err)
+					return c.JSON(http.StatusInternalServerError, domain.Error{
+						Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+						Message: log.ToStrPtr("Unexpected error"),
+					})
+				}
+
+				logger.LogInfo(ctx, "Adding new source to watch...")
+				err = manager.OnAddedSource(c.Request().Context(), src)
+				if err != nil {
+					logger.LogError(ctx, "Could not handle added source:%v", err)
+					return err
+				}
+				return c.JSON(http.StatusCreated, src)
+			},
+			Middlewares: []echo.MiddlewareFunc{
+				apis.RequireAdminOrRecordAuth("users"),
+				apis.ActivityLogger(e.App),
+				middleware.CORSWithConfig(middleware.CORSConfig{}),
+				middleware.BodyLimit(64 * 1024),
+				middleware.Recover(),
+				middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+			},
+		})
+	}
+}
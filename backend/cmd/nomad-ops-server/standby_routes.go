@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/replication"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerStandbyRoutes adds the route a standby replicates the store from and the routes admins watch and promote
+// the standby with
+func registerStandbyRoutes(ctx context.Context,
+	e *core.ServeEvent,
+	logger log.Logger,
+	replicationToken string,
+	replicationStore *replication.PocketBaseStore,
+	standby *application.Standby,
+	manager *application.ReconciliationManager,
+	watcher *application.RepoWatcher) {
+	// a page of the changes of the store since the cursor of a standby, authenticated by the replication token
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/replication/changes",
+		Handler: func(c echo.Context) error {
+			token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			if replicationToken == "" || !security.Equal(security.SHA256(token), security.SHA256(replicationToken)) {
+				return c.JSON(http.StatusUnauthorized, domain.Error{
+					Code:    domain.ErrorCodeUnauthorized,
+					Message: log.ToStrPtr("The replication token is invalid"),
+				})
+			}
+			var since time.Time
+			if v := c.QueryParam("since"); v != "" {
+				var err error
+				since, err = time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return c.JSON(http.StatusBadRequest, domain.Error{
+						Code:    domain.ErrorCodeInvalidRequest,
+						Message: log.ToStrPtr("since must be a RFC3339 time"),
+					})
+				}
+			}
+			var page *domain.ReplicationPage
+			if table := c.QueryParam("table"); table != "" {
+				page = &domain.ReplicationPage{
+					Table: table,
+					After: c.QueryParam("after"),
+				}
+			}
+			batch, err := replicationStore.Changes(c.Request().Context(), since, page)
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not read the changes for a standby:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, batch)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/admin/standby",
+		Handler: func(c echo.Context) error {
+			if standby == nil {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("This instance is no standby"),
+				})
+			}
+			return c.JSON(http.StatusOK, standby.Status())
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// promotes the standby after the primary failed, the primary must not reconcile anymore
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/admin/standby/promote",
+		Handler: func(c echo.Context) error {
+			if standby == nil {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("This instance is no standby"),
+				})
+			}
+			logger.LogInfo(c.Request().Context(), "Promoting the standby...")
+			err := standby.Promote(c.Request().Context(), func(context.Context) error {
+				// the sources are watched for the lifetime of the server, not of the request
+				err := manager.Rebalance(ctx)
+				if err != nil {
+					return err
+				}
+				return watcher.ReplayPendingSyncs(ctx)
+			})
+			if domain.ErrorCodeOf(err, "") == domain.ErrorCodeInvalidRequest {
+				return c.JSON(http.StatusConflict, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			}
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not Promote:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, standby.Status())
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+}
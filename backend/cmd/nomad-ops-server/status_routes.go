@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/badge"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/sourcestore"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/statustokenstore"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerStatusRoutes adds the status badges of sources and the status API authenticated by status tokens
+func registerStatusRoutes(e *core.ServeEvent,
+	app core.App,
+	logger log.Logger,
+	srcStore *sourcestore.PocketBaseStore,
+	statusTokenStore *statustokenstore.PocketBaseStore) {
+	// issues a new token for the status badge of the source, deleting it disables the badge
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		method := method
+		e.Router.AddRoute(echo.Route{
+			Method: method,
+			Path:   "/api/nomad/sources/:id/badge",
+			Handler: func(c echo.Context) error {
+				rec, err := app.Dao().FindRecordById("sources", c.PathParam("id"))
+				if err != nil || !visibleSources(app, logger, c)(rec.Id) {
+					return c.JSON(http.StatusNotFound, domain.Error{
+						Code:    domain.ErrorCodeNotFound,
+						Message: log.ToStrPtr("Source was not found"),
+					})
+				}
+
+				token := ""
+				if method == http.MethodPost {
+					token = security.RandomString(32)
+				}
+				err = srcStore.SetBadgeToken(c.Request().Context(), rec.Id, token)
+				if err != nil {
+					logger.LogError(c.Request().Context(), "Could not SetBadgeToken:%v", err)
+					return c.JSON(http.StatusInternalServerError, domain.Error{
+						Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+						Message: log.ToStrPtr("Unexpected error"),
+					})
+				}
+				if token == "" {
+					return c.NoContent(http.StatusNoContent)
+				}
+
+				u := fmt.Sprintf("%s/api/badges/sources/%s?token=%s",
+					strings.TrimSuffix(e.App.Settings().Meta.AppUrl, "/"), rec.Id, token)
+				return c.JSON(http.StatusOK, map[string]string{
+					"token": token,
+					"svg":   u,
+					"json":  u + "&format=json",
+				})
+			},
+			Middlewares: []echo.MiddlewareFunc{
+				apis.RequireAdminOrRecordAuth("users"),
+				apis.ActivityLogger(e.App),
+				middleware.CORSWithConfig(middleware.CORSConfig{}),
+				middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+				middleware.Recover(),
+				middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+			},
+		})
+	}
+
+	// public status badge of a source, e.g. for READMEs, the token of the badge replaces authentication
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/badges/sources/:id",
+		Handler: func(c echo.Context) error {
+			src, err := srcStore.GetSourceByBadgeToken(c.Request().Context(), c.PathParam("id"), c.QueryParam("token"))
+			if err != nil {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Badge was not found"),
+				})
+			}
+
+			b := badge.FromSource(src)
+			// badges are cached by proxies like GitHub camo otherwise
+			c.Response().Header().Set("Cache-Control", "no-cache, max-age=0")
+			if c.QueryParam("format") == "json" {
+				return c.JSON(http.StatusOK, b)
+			}
+			label := c.QueryParam("label")
+			if label == "" {
+				label = src.Name
+			}
+			return c.Blob(http.StatusOK, "image/svg+xml", b.SVG(label))
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// issues a token of the status API, admins revoke it by deleting it from the status_tokens collection
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/admin/status-tokens",
+		Handler: func(c echo.Context) error {
+			req := struct {
+				Name  string   `json:"name"`
+				Teams []string `json:"teams"`
+				// label selector of the sources, e.g. env=prod
+				Selector string `json:"selector"`
+				// the token expires after this duration, e.g. 8760h
+				TTL string `json:"ttl"`
+			}{}
+			if err := c.Bind(&req); err != nil || req.Name == "" {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Expected the name of the token"),
+				})
+			}
+			if _, err := domain.ParseLabelSelector(req.Selector); err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			}
+			tok := &domain.StatusToken{
+				Name:     req.Name,
+				TeamIDs:  req.Teams,
+				Selector: req.Selector,
+			}
+			if req.TTL != "" {
+				d, err := time.ParseDuration(req.TTL)
+				if err != nil || d <= 0 {
+					return c.JSON(http.StatusBadRequest, domain.Error{
+						Code:    domain.ErrorCodeInvalidRequest,
+						Message: log.ToStrPtr("Invalid ttl " + req.TTL),
+					})
+				}
+				tok.ExpiresAt = types.Pointer(time.Now().Add(d))
+			}
+			token := security.RandomString(32)
+			tok, err := statusTokenStore.CreateStatusToken(c.Request().Context(), tok, token)
+			if err != nil {
+				if domain.ErrorCodeOf(err, "") == domain.ErrorCodeInvalidRequest {
+					return c.JSON(http.StatusBadRequest, domain.Error{
+						Code:    domain.ErrorCodeInvalidRequest,
+						Message: log.ToStrPtr(err.Error()),
+					})
+				}
+				logger.LogError(c.Request().Context(), "Could not CreateStatusToken:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusCreated, map[string]interface{}{
+				"token":       token,
+				"statusToken": tok,
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.RequireAdminAuth(),
+			apis.ActivityLogger(e.App),
+			middleware.CORSWithConfig(middleware.CORSConfig{}),
+			middleware.BodyLimit(64 * 1024),
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	})
+
+	// requireStatusToken authenticates requests of the status API by a status token instead of a user
+	requireStatusToken := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := c.QueryParam("token")
+			if h := c.Request().Header.Get("Authorization"); h != "" {
+				token = strings.TrimPrefix(h, "Bearer ")
+			}
+			tok, err := statusTokenStore.GetStatusTokenByToken(c.Request().Context(), token)
+			if err != nil && err != errors.ErrNotFound {
+				logger.LogError(c.Request().Context(), "Could not GetStatusTokenByToken:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			now := time.Now()
+			if tok == nil || !tok.IsActive(now) {
+				return c.JSON(http.StatusUnauthorized, domain.Error{
+					Code:    domain.ErrorCodeUnauthorized,
+					Message: log.ToStrPtr("The status token is invalid or has expired"),
+				})
+			}
+			if tok.LastUsedAt == nil || now.Sub(*tok.LastUsedAt) > time.Minute {
+				err = statusTokenStore.TouchStatusToken(c.Request().Context(), tok.ID, now)
+				if err != nil {
+					logger.LogError(c.Request().Context(), "Could not TouchStatusToken:%v", err)
+				}
+			}
+			c.Set("statusToken", tok)
+			return next(c)
+		}
+	}
+	// statusOfSources returns the status of the sources the token of the request grants access to
+	statusOfSources := func(c echo.Context, id string, sel domain.LabelSelector) ([]badge.SourceStatus, error) {
+		tok := c.Get("statusToken").(*domain.StatusToken)
+		records, err := app.Dao().FindRecordsByExpr("sources")
+		if err != nil {
+			return nil, err
+		}
+		res := []badge.SourceStatus{}
+		for _, rec := range records {
+			if id != "" && rec.Id != id {
+				continue
+			}
+			src := domain.SourceFromRecord(rec, true)
+			if !tok.Grants(src) || !sel.Matches(src.Labels) {
+				continue
+			}
+			res = append(res, badge.StatusFromSource(src))
+		}
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].Name < res[j].Name
+		})
+		return res, nil
+	}
+	statusMiddlewares := []echo.MiddlewareFunc{
+		requireStatusToken,
+		middleware.CORSWithConfig(middleware.CORSConfig{}),
+		middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{}),
+		middleware.Recover(),
+		middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+	}
+
+	// read-only status of all sources the status token grants access to, e.g. for status pages
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/status/sources",
+		Handler: func(c echo.Context) error {
+			sel, err := domain.ParseLabelSelector(c.QueryParam("selector"))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr(err.Error()),
+				})
+			}
+			res, err := statusOfSources(c, "", sel)
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not list the status of sources:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			return c.JSON(http.StatusOK, res)
+		},
+		Middlewares: statusMiddlewares,
+	})
+
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/status/sources/:id",
+		Handler: func(c echo.Context) error {
+			res, err := statusOfSources(c, c.PathParam("id"), domain.LabelSelector{})
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not get the status of a source:%v", err)
+				return c.JSON(http.StatusInternalServerError, domain.Error{
+					Code:    domain.ErrorCodeOf(err, domain.ErrorCodeInternal),
+					Message: log.ToStrPtr("Unexpected error"),
+				})
+			}
+			if len(res) == 0 {
+				return c.JSON(http.StatusNotFound, domain.Error{
+					Code:    domain.ErrorCodeNotFound,
+					Message: log.ToStrPtr("Source was not found"),
+				})
+			}
+			return c.JSON(http.StatusOK, res[0])
+		},
+		Middlewares: statusMiddlewares,
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/azuredevops"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/bitbucket"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/github"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// registerGitWebhookRoutes adds the webhook routes of the configured git hosts
+func registerGitWebhookRoutes(e *core.ServeEvent,
+	logger log.Logger,
+	gitHubApp *github.GitHubApp,
+	bitbucketApp *bitbucket.Bitbucket,
+	azureDevOps *azuredevops.AzureDevOps) {
+	if gitHubApp != nil {
+		e.Router.AddRoute(gitWebhookRoute(logger, "/api/github/webhook", github.ErrInvalidSignature,
+			func(c echo.Context, body []byte) error {
+				return gitHubApp.HandleWebhook(c.Request().Context(),
+					c.Request().Header.Get("X-GitHub-Event"),
+					c.Request().Header.Get("X-Hub-Signature-256"),
+					body)
+			}))
+	}
+
+	if bitbucketApp != nil {
+		e.Router.AddRoute(gitWebhookRoute(logger, "/api/bitbucket/webhook", bitbucket.ErrInvalidSignature,
+			func(c echo.Context, body []byte) error {
+				return bitbucketApp.HandleWebhook(c.Request().Context(),
+					c.Request().Header.Get("X-Event-Key"),
+					c.Request().Header.Get("X-Hub-Signature"),
+					body)
+			}))
+	}
+
+	if azureDevOps != nil {
+		e.Router.AddRoute(gitWebhookRoute(logger, "/api/azure-devops/webhook", azuredevops.ErrInvalidCredentials,
+			func(c echo.Context, body []byte) error {
+				// service hooks authenticate with basic auth, the user name is not checked
+				_, password, _ := c.Request().BasicAuth()
+				return azureDevOps.HandleWebhook(c.Request().Context(), password, body)
+			}))
+	}
+}
+
+// gitWebhookRoute receives the webhooks of a git host and passes the body to handle.
+// Requests failing with the unauthorized error of the host are answered with 401.
+func gitWebhookRoute(logger log.Logger, path string, unauthorized error,
+	handle func(c echo.Context, body []byte) error) echo.Route {
+	return echo.Route{
+		Method: http.MethodPost,
+		Path:   path,
+		Handler: func(c echo.Context) error {
+			// GitHub caps payloads at 25MB
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, 25*1024*1024))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, domain.Error{
+					Code:    domain.ErrorCodeInvalidRequest,
+					Message: log.ToStrPtr("Could not read body"),
+				})
+			}
+			err = handle(c, body)
+			if err == unauthorized {
+				return c.JSON(http.StatusUnauthorized, domain.Error{
+					Code:    domain.ErrorCodeUnauthorized,
+					Message: log.ToStrPtr("Invalid signature or credentials"),
+				})
+			}
+			if err != nil {
+				logger.LogError(c.Request().Context(), "Could not HandleWebhook:%v", err)
+				code := domain.ErrorCodeOf(err, domain.ErrorCodeInternal)
+				status := http.StatusInternalServerError
+				if code == domain.ErrorCodeInvalidRequest {
+					status = http.StatusBadRequest
+				}
+				return c.JSON(status, domain.Error{
+					Code:    code,
+					Message: log.ToStrPtr("Could not handle webhook"),
+				})
+			}
+			return c.NoContent(http.StatusAccepted)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			middleware.Recover(),
+			middleware.LoggerWithConfig(middleware.LoggerConfig{}),
+		},
+	}
+}
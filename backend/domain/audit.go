@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+type AuditActionType string
+
+const (
+	// AuditActionTypeAutomatic is used for mutations of the regular polling or Nomad events
+	AuditActionTypeAutomatic AuditActionType = "automatic"
+	AuditActionTypeSync      AuditActionType = "sync"
+	AuditActionTypeRestart   AuditActionType = "restart"
+	AuditActionTypeUpdate    AuditActionType = "update"
+	// AuditActionTypeDeployment is used for operations on deployments, e.g. pausing a rollout
+	AuditActionTypeDeployment AuditActionType = "deployment"
+	// AuditActionTypeDelete is used for pruning or retaining the resources of a deleted source
+	AuditActionTypeDelete AuditActionType = "delete"
+)
+
+type AuditOperation string
+
+const (
+	AuditOperationRegisterNamespace AuditOperation = "register_namespace"
+	AuditOperationRegisterJob       AuditOperation = "register_job"
+	AuditOperationDeregisterJob     AuditOperation = "deregister_job"
+	AuditOperationRevertJob         AuditOperation = "revert_job"
+	AuditOperationPauseDeployment   AuditOperation = "pause_deployment"
+	AuditOperationResumeDeployment  AuditOperation = "resume_deployment"
+	AuditOperationFailDeployment    AuditOperation = "fail_deployment"
+	AuditOperationDeleteNamespace   AuditOperation = "delete_namespace"
+	// AuditOperationDeleteVariable records the path of the variable as job
+	AuditOperationDeleteVariable AuditOperation = "delete_variable"
+	// AuditOperationOrphanJob marks a job that was retained when its source was deleted
+	AuditOperationOrphanJob AuditOperation = "orphan_job"
+)
+
+// AuditEntry is a single mutation performed on the Nomad API
+type AuditEntry struct {
+
+	// id
+	// Read Only: true
+	ID string `json:"id,omitempty"`
+
+	// id of the user action that caused the mutation, empty for automatic syncs
+	ActionID string `json:"actionId,omitempty"`
+
+	ActionType AuditActionType `json:"actionType"`
+
+	UserID string `json:"user,omitempty"`
+
+	// who triggered the action
+	Actor string `json:"actor"`
+
+	SourceID string `json:"source,omitempty"`
+
+	Operation AuditOperation `json:"operation"`
+
+	Job string `json:"job,omitempty"`
+
+	Namespace string `json:"namespace,omitempty"`
+
+	Region string `json:"region,omitempty"`
+
+	// evaluation created by Nomad
+	EvalID string `json:"evalId,omitempty"`
+
+	// set if the mutation failed
+	Error string `json:"error,omitempty"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func initAuditCollection(app core.App,
+	usersCollection *models.Collection,
+	srcCollection *models.Collection) (*models.Collection, error) {
+
+	collection, err := app.Dao().FindCollectionByNameOrId("audit_log")
+
+	if err == sql.ErrNoRows {
+		collection = &models.Collection{}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(app, collection)
+	form.Name = "audit_log"
+	form.Type = models.CollectionTypeBase
+	// users only see the entries of the sources of their teams, entries without a source only admins
+	form.ListRule = types.Pointer("@request.auth.id != '' && source != '' && (source.teams:length = 0 || source.teams.members.id ?= @request.auth.id)")
+	form.ViewRule = types.Pointer("@request.auth.id != '' && source != '' && (source.teams:length = 0 || source.teams.members.id ?= @request.auth.id)")
+	// the audit log is written by nomad-ops only
+	form.CreateRule = nil
+	form.UpdateRule = nil
+	form.DeleteRule = nil
+
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "actionId",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options:  &schema.TextOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "actionType",
+		Type:     schema.FieldTypeSelect,
+		Required: true,
+		Options: &schema.SelectOptions{
+			MaxSelect: 1,
+			Values: []string{
+				string(AuditActionTypeAutomatic),
+				string(AuditActionTypeSync),
+				string(AuditActionTypeRestart),
+				string(AuditActionTypeUpdate),
+				string(AuditActionTypeDeployment),
+				string(AuditActionTypeDelete),
+			},
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "user",
+		Type:     schema.FieldTypeRelation,
+		Required: false,
+		Options: &schema.RelationOptions{
+			MaxSelect:    types.Pointer(1),
+			CollectionId: usersCollection.Id,
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "actor",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options:  &schema.TextOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "source",
+		Type:     schema.FieldTypeRelation,
+		Required: false,
+		Options: &schema.RelationOptions{
+			MaxSelect:    types.Pointer(1),
+			CollectionId: srcCollection.Id,
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "operation",
+		Type:     schema.FieldTypeSelect,
+		Required: true,
+		Options: &schema.SelectOptions{
+			MaxSelect: 1,
+			Values: []string{
+				string(AuditOperationRegisterNamespace),
+				string(AuditOperationRegisterJob),
+				string(AuditOperationDeregisterJob),
+				string(AuditOperationRevertJob),
+				string(AuditOperationPauseDeployment),
+				string(AuditOperationResumeDeployment),
+				string(AuditOperationFailDeployment),
+				string(AuditOperationDeleteNamespace),
+				string(AuditOperationDeleteVariable),
+				string(AuditOperationOrphanJob),
+			},
+		},
+	})
+	for _, name := range []string{"job", "namespace", "region", "evalId"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeText,
+			Required: false,
+			Options:  &schema.TextOptions{},
+		})
+	}
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "error",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(2000),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "timestamp",
+		Type:     schema.FieldTypeDate,
+		Required: true,
+		Options:  &schema.DateOptions{},
+	})
+
+	// validate and submit (internally it calls app.Dao().SaveCollection(collection) in a transaction)
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
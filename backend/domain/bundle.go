@@ -0,0 +1,251 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// BundleManifestFile declares the bundles of a source, it is read from the path of the source
+	// or the directory of the job file
+	BundleManifestFile = "nomad-ops.bundles.json"
+	// BundleLockFile pins the resolved bundles next to the manifest
+	BundleLockFile = "nomad-ops.bundles.lock.json"
+)
+
+// BundleManifest lists the shared bundles deployed together with the jobs of a source
+type BundleManifest struct {
+	Bundles []BundleDependency `json:"bundles"`
+}
+
+// BundleDependency is a bundle published as semver tags of a git repository, e.g. v1.2.0
+type BundleDependency struct {
+	// Name must be unique within the manifest
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Path of the job files in the bundle repository
+	Path string `json:"path,omitempty"`
+	// Version is a constraint, e.g. ^1.2.0, ~1.2, >=1.0.0 <2.0.0 or 1.2.3
+	Version string `json:"version"`
+	// Renderer renders the bundle instead of reading job files, see the renderer of a source
+	Renderer string `json:"renderer,omitempty"`
+}
+
+// BundleLock pins the bundles of a source to versions and commits
+type BundleLock struct {
+	Bundles []LockedBundle `json:"bundles"`
+}
+
+type LockedBundle struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Version string `json:"version"`
+	// Tag the version was resolved from
+	Tag    string `json:"tag"`
+	Commit string `json:"commit"`
+}
+
+// Find returns the locked bundle of the name, nil if it is not locked
+func (l *BundleLock) Find(name string) *LockedBundle {
+	if l == nil {
+		return nil
+	}
+	for i := range l.Bundles {
+		if l.Bundles[i].Name == name {
+			return &l.Bundles[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks the names, urls and version constraints of the bundles
+func (m *BundleManifest) Validate() error {
+	names := map[string]bool{}
+	for _, b := range m.Bundles {
+		if b.Name == "" || b.URL == "" {
+			return WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("bundles need a name and an url"))
+		}
+		if names[b.Name] {
+			return WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("bundle %s is declared more than once", b.Name))
+		}
+		names[b.Name] = true
+		if strings.HasPrefix(b.URL, "oci://") {
+			return WithErrorCode(ErrorCodeInvalidRequest,
+				fmt.Errorf("bundle %s: OCI artifacts are not supported, publish the bundle as tags of a git repository", b.Name))
+		}
+		if _, err := ParseVersionConstraint(b.Version); err != nil {
+			return WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("bundle %s: %w", b.Name, err))
+		}
+	}
+	return nil
+}
+
+// Version is a semantic version, build metadata is ignored
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          string
+}
+
+// ParseVersion parses versions like 1.2.3, v1.2.3 and 1.2.3-rc.1
+func ParseVersion(s string) (Version, error) {
+	v, parts, err := parseVersionParts(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if parts != 3 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// parseVersionParts parses partial versions like 1 or 1.2 as well and returns the number of parts
+func parseVersionParts(s string) (Version, int, error) {
+	v := Version{}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, v.Prerelease, _ = strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || s == "" {
+		return Version{}, 0, fmt.Errorf("invalid version %q", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, 0, fmt.Errorf("invalid version %q", s)
+		}
+		switch i {
+		case 0:
+			v.Major = n
+		case 1:
+			v.Minor = n
+		case 2:
+			v.Patch = n
+		}
+	}
+	return v, len(parts), nil
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or greater than o. Prereleases are compared as strings.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	case v.Prerelease < o.Prerelease:
+		return -1
+	}
+	return 1
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+type versionComparison struct {
+	operator string
+	version  Version
+}
+
+// VersionConstraint is a list of comparisons a version must all satisfy
+type VersionConstraint struct {
+	comparisons []versionComparison
+}
+
+// ParseVersionConstraint parses space or comma separated comparisons with the operators
+// =, !=, >, >=, <, <=, ^ (compatible major version) and ~ (compatible minor version).
+// A version without operator must match exactly, * or the empty constraint match all versions.
+func ParseVersionConstraint(s string) (VersionConstraint, error) {
+	c := VersionConstraint{}
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		if part == "*" {
+			continue
+		}
+		op := ""
+		for _, o := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(part, o) {
+				op = o
+				break
+			}
+		}
+		v, n, err := parseVersionParts(strings.TrimPrefix(part, op))
+		if err != nil {
+			return VersionConstraint{}, fmt.Errorf("invalid version constraint %q", s)
+		}
+		switch op {
+		case "^":
+			upper := Version{Major: v.Major + 1}
+			if v.Major == 0 && n > 1 {
+				upper = Version{Minor: v.Minor + 1}
+			}
+			c.comparisons = append(c.comparisons, versionComparison{">=", v}, versionComparison{"<", upper})
+		case "~":
+			upper := Version{Major: v.Major, Minor: v.Minor + 1}
+			if n == 1 {
+				upper = Version{Major: v.Major + 1}
+			}
+			c.comparisons = append(c.comparisons, versionComparison{">=", v}, versionComparison{"<", upper})
+		case "", "=":
+			if n < 3 {
+				// 1.2 matches all versions of 1.2.x
+				return ParseVersionConstraint("~" + strings.TrimPrefix(part, op))
+			}
+			c.comparisons = append(c.comparisons, versionComparison{"=", v})
+		default:
+			c.comparisons = append(c.comparisons, versionComparison{op, v})
+		}
+	}
+	return c, nil
+}
+
+// Check returns true if the version satisfies all comparisons. Prereleases only satisfy constraints
+// naming a prerelease of the same version.
+func (c VersionConstraint) Check(v Version) bool {
+	if v.Prerelease != "" {
+		allowed := false
+		for _, cmp := range c.comparisons {
+			allowed = allowed || (cmp.version.Prerelease != "" && cmp.version.Major == v.Major &&
+				cmp.version.Minor == v.Minor && cmp.version.Patch == v.Patch)
+		}
+		if !allowed {
+			return false
+		}
+	}
+	for _, cmp := range c.comparisons {
+		r := v.Compare(cmp.version)
+		ok := false
+		switch cmp.operator {
+		case "=":
+			ok = r == 0
+		case "!=":
+			ok = r != 0
+		case ">":
+			ok = r > 0
+		case ">=":
+			ok = r >= 0
+		case "<":
+			ok = r < 0
+		case "<=":
+			ok = r <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package domain
+
+import "testing"
+
+func TestVersionConstraint(t *testing.T) {
+	for constraint, versions := range map[string]map[string]bool{
+		"^1.2.0":          {"1.2.0": true, "1.9.3": true, "v1.2.5": true, "1.1.9": false, "2.0.0": false, "1.3.0-rc.1": false},
+		"^0.2.1":          {"0.2.1": true, "0.2.9": true, "0.3.0": false},
+		"~1.2":            {"1.2.0": true, "1.2.7": true, "1.3.0": false},
+		"1.2":             {"1.2.3": true, "1.3.0": false},
+		"1.2.3":           {"1.2.3": true, "1.2.4": false},
+		">=1.0.0 <2.0.0":  {"1.0.0": true, "1.99.0": true, "0.9.0": false, "2.0.0": false},
+		">1.0.0, !=1.2.0": {"1.0.0": false, "1.1.0": true, "1.2.0": false},
+		"*":               {"0.0.1": true, "3.0.0": true, "3.0.0-beta": false},
+		"=2.0.0-rc.2":     {"2.0.0-rc.2": true, "2.0.0-rc.1": false},
+		">=2.0.0-rc.1":    {"2.0.0-rc.2": true, "2.0.0": true, "2.1.0-rc.1": false},
+	} {
+		c, err := ParseVersionConstraint(constraint)
+		if err != nil {
+			t.Errorf("Could not parse %q:%v", constraint, err)
+			continue
+		}
+		for version, expected := range versions {
+			v, err := ParseVersion(version)
+			if err != nil {
+				t.Errorf("Could not parse %q:%v", version, err)
+				continue
+			}
+			if c.Check(v) != expected {
+				t.Errorf("Expected %q to satisfy %q: %v", version, constraint, expected)
+			}
+		}
+	}
+
+	for _, constraint := range []string{"^a", ">=1.0.0.0", "latest"} {
+		if _, err := ParseVersionConstraint(constraint); err == nil {
+			t.Errorf("Expected %q to be invalid", constraint)
+		}
+	}
+	if _, err := ParseVersion("1.2"); err == nil {
+		t.Errorf("Expected a version to need three parts")
+	}
+}
+
+func TestBundleManifestValidate(t *testing.T) {
+	valid := BundleManifest{Bundles: []BundleDependency{
+		{Name: "monitoring", URL: "https://git.example.com/bundles/monitoring.git", Version: "^1.0.0"},
+	}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected a valid manifest, got %v", err)
+	}
+	for _, m := range []BundleManifest{
+		{Bundles: []BundleDependency{{Name: "monitoring", Version: "^1.0.0"}}},
+		{Bundles: []BundleDependency{{Name: "monitoring", URL: "oci://registry.example.com/monitoring", Version: "^1.0.0"}}},
+		{Bundles: []BundleDependency{{Name: "monitoring", URL: "https://git.example.com/m.git", Version: "latest"}}},
+		{Bundles: []BundleDependency{valid.Bundles[0], valid.Bundles[0]}},
+	} {
+		if err := m.Validate(); ErrorCodeOf(err, "") != ErrorCodeInvalidRequest {
+			t.Errorf("Expected %v to be invalid, got %v", m, err)
+		}
+	}
+}
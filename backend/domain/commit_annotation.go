@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// CommitAnnotation is delivery metadata CI attached to a commit of a repository, e.g. the build that produced
+// the images deployed by the commit, shown next to the syncs that deployed it
+type CommitAnnotation struct {
+	// name of the repository, e.g. owner/repo, see application.RepoName
+	Repo string `json:"repo"`
+
+	Commit string `json:"commit"`
+
+	BuildNumber string `json:"buildNumber,omitempty"`
+
+	BuildURL string `json:"buildUrl,omitempty"`
+
+	Artifacts []ArtifactDigest `json:"artifacts,omitempty"`
+
+	// ids of the tickets the commit belongs to, e.g. OPS-123
+	Tickets []string `json:"tickets,omitempty"`
+
+	Meta map[string]string `json:"meta,omitempty"`
+
+	// Read Only: true
+	Updated time.Time `json:"updated,omitempty"`
+}
+
+// ArtifactDigest is an artifact built from a commit, e.g. an image
+type ArtifactDigest struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest"`
+}
+
+// Validate checks the annotation sent by CI
+func (a *CommitAnnotation) Validate() error {
+	if a.Repo == "" || a.Commit == "" {
+		return fmt.Errorf("repo and commit are required")
+	}
+	for _, art := range a.Artifacts {
+		if art.Name == "" || art.Digest == "" {
+			return fmt.Errorf("artifacts require a name and a digest")
+		}
+	}
+	return nil
+}
+
+// Merge adds the annotation sent later, e.g. by another stage of the pipeline. Set fields replace the stored ones,
+// artifacts are replaced by name and tickets are added.
+func (a *CommitAnnotation) Merge(other *CommitAnnotation) {
+	if other.BuildNumber != "" {
+		a.BuildNumber = other.BuildNumber
+	}
+	if other.BuildURL != "" {
+		a.BuildURL = other.BuildURL
+	}
+	for _, art := range other.Artifacts {
+		replaced := false
+		for i := range a.Artifacts {
+			if a.Artifacts[i].Name == art.Name {
+				a.Artifacts[i] = art
+				replaced = true
+			}
+		}
+		if !replaced {
+			a.Artifacts = append(a.Artifacts, art)
+		}
+	}
+	tickets := map[string]bool{}
+	for _, t := range a.Tickets {
+		tickets[t] = true
+	}
+	for _, t := range other.Tickets {
+		if !tickets[t] {
+			tickets[t] = true
+			a.Tickets = append(a.Tickets, t)
+		}
+	}
+	sort.Strings(a.Tickets)
+	for k, v := range other.Meta {
+		if a.Meta == nil {
+			a.Meta = map[string]string{}
+		}
+		a.Meta[k] = v
+	}
+}
+
+func initCommitAnnotationCollection(app core.App) (*models.Collection, error) {
+
+	collection, err := app.Dao().FindCollectionByNameOrId("commit_annotations")
+
+	if err == sql.ErrNoRows {
+		collection = &models.Collection{}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(app, collection)
+	form.Name = "commit_annotations"
+	form.Type = models.CollectionTypeBase
+	// written by CI and read through the API only, which checks the teams of the sources
+	form.ListRule = nil
+	form.ViewRule = nil
+	form.CreateRule = nil
+	form.UpdateRule = nil
+	form.DeleteRule = nil
+	form.Indexes = types.JsonArray[string]{
+		"create unique index commit_annotation_unique on commit_annotations (repo, commit)",
+	}
+
+	for _, name := range []string{"repo", "commit"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeText,
+			Required: true,
+			Options: &schema.TextOptions{
+				Max: types.Pointer(500),
+			},
+		})
+	}
+	for _, name := range []string{"buildNumber", "buildUrl"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeText,
+			Required: false,
+			Options: &schema.TextOptions{
+				Max: types.Pointer(2000),
+			},
+		})
+	}
+	for _, name := range []string{"artifacts", "tickets", "meta"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeJson,
+			Required: false,
+			Options:  &schema.JsonOptions{},
+		})
+	}
+
+	// validate and submit (internally it calls app.Dao().SaveCollection(collection) in a transaction)
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+func CommitAnnotationFromRecord(record *models.Record) *CommitAnnotation {
+	a := &CommitAnnotation{
+		Repo:        record.GetString("repo"),
+		Commit:      record.GetString("commit"),
+		BuildNumber: record.GetString("buildNumber"),
+		BuildURL:    record.GetString("buildUrl"),
+		Updated:     record.GetDateTime("updated").Time(),
+	}
+	for name, v := range map[string]any{"artifacts": &a.Artifacts, "tickets": &a.Tickets, "meta": &a.Meta} {
+		if record.GetString(name) == "" {
+			continue
+		}
+		err := record.UnmarshalJSONField(name, v)
+		if err != nil {
+			fmt.Printf("Could not unmarshal %s field:%v", name, err)
+		}
+	}
+	return a
+}
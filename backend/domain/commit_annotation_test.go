@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommitAnnotationMerge(t *testing.T) {
+	a := &CommitAnnotation{
+		Repo:        "ops/jobs",
+		Commit:      "abc",
+		BuildNumber: "41",
+		Artifacts:   []ArtifactDigest{{Name: "web", Digest: "sha256:1"}},
+		Tickets:     []string{"OPS-2"},
+	}
+	if err := a.Validate(); err != nil {
+		t.Fatalf("Expected a valid annotation, got %v", err)
+	}
+	if err := (&CommitAnnotation{Repo: "ops/jobs", Commit: "abc", Artifacts: []ArtifactDigest{{Name: "web"}}}).Validate(); err == nil {
+		t.Errorf("Expected artifacts without digest to be invalid")
+	}
+
+	// a later stage of the pipeline
+	a.Merge(&CommitAnnotation{
+		BuildURL:  "https://ci.example.com/41",
+		Artifacts: []ArtifactDigest{{Name: "web", Digest: "sha256:2"}, {Name: "api", Digest: "sha256:3"}},
+		Tickets:   []string{"OPS-1", "OPS-2"},
+		Meta:      map[string]string{"pipeline": "release"},
+	})
+	if a.BuildNumber != "41" || a.BuildURL != "https://ci.example.com/41" ||
+		len(a.Artifacts) != 2 || a.Artifacts[0].Digest != "sha256:2" ||
+		strings.Join(a.Tickets, ",") != "OPS-1,OPS-2" || a.Meta["pipeline"] != "release" {
+		t.Errorf("Unexpected merged annotation %+v", a)
+	}
+}
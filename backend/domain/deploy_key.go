@@ -11,8 +11,27 @@ import (
 	"github.com/pocketbase/pocketbase/tools/types"
 )
 
+type DeployKeyType string
+
+const (
+	// DeployKeyTypeSSH is a private ssh key, the default
+	DeployKeyTypeSSH DeployKeyType = "ssh"
+	// DeployKeyTypeToken is a static token used for http basic auth, e.g. a personal access token
+	DeployKeyTypeToken DeployKeyType = "token"
+	// DeployKeyTypeGitHubApp holds the private key of a GitHub App, installation tokens are issued as needed
+	DeployKeyTypeGitHubApp DeployKeyType = "github-app"
+	// DeployKeyTypeAzureIdentity uses the managed identity of the host to access Azure DevOps
+	DeployKeyTypeAzureIdentity DeployKeyType = "azure-identity"
+	// DeployKeyTypeGCPIdentity uses the service account of the host to access Google Cloud Source Repositories
+	DeployKeyTypeGCPIdentity DeployKeyType = "gcp-identity"
+)
+
 type DeployKey struct {
 
+	// id
+	// Read Only: true
+	ID string `json:"id,omitempty"`
+
 	// name
 	// Required: true
 	Name string `json:"name"`
@@ -21,10 +40,22 @@ type DeployKey struct {
 	// Read Only: true
 	Created time.Time `json:"timestamp,omitempty"`
 
-	// value
-	// Required: true
+	// how the key is used to authenticate, defaults to ssh
+	Type DeployKeyType `json:"type,omitempty"`
+
+	// value, depending on the type the ssh key, the token, the private key of the GitHub App
+	// or the client id / service account of the identity
 	Value string `json:"value"`
 
+	// user of token keys
+	Username string `json:"username,omitempty"`
+
+	// id of the GitHub App
+	AppID string `json:"appId,omitempty"`
+
+	// id of the installation of the GitHub App
+	InstallationID string `json:"installationId,omitempty"`
+
 	// teamID of owner
 	TeamID string `json:"teamID,omitempty"`
 }
@@ -61,12 +92,51 @@ func initKeyCollection(app core.App,
 			Max: types.Pointer(100),
 		},
 	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "type",
+		Type:     schema.FieldTypeSelect,
+		Required: false,
+		Options: &schema.SelectOptions{
+			MaxSelect: 1,
+			Values: []string{
+				string(DeployKeyTypeSSH),
+				string(DeployKeyTypeToken),
+				string(DeployKeyTypeGitHubApp),
+				string(DeployKeyTypeAzureIdentity),
+				string(DeployKeyTypeGCPIdentity),
+			},
+		},
+	})
 	addOrUpdateField(form, &schema.SchemaField{
 		Name:     "value",
 		Type:     schema.FieldTypeText,
-		Required: true,
+		Required: false, // identities may use the default identity of the host
+		Options: &schema.TextOptions{
+			Max: types.Pointer(10000),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "username",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(100),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "appId",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(100),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "installationId",
+		Type:     schema.FieldTypeText,
+		Required: false,
 		Options: &schema.TextOptions{
-			Max: types.Pointer(1000),
+			Max: types.Pointer(100),
 		},
 	})
 	max := 1
@@ -89,8 +159,13 @@ func initKeyCollection(app core.App,
 
 func DeployKeyFromRecord(record *models.Record) *DeployKey {
 	return &DeployKey{
-		Name:    record.GetString("name"),
-		Created: record.Created.Time(),
-		Value:   record.GetString("value"),
+		ID:             record.Id,
+		Name:           record.GetString("name"),
+		Created:        record.Created.Time(),
+		Type:           DeployKeyType(record.GetString("type")),
+		Value:          record.GetString("value"),
+		Username:       record.GetString("username"),
+		AppID:          record.GetString("appId"),
+		InstallationID: record.GetString("installationId"),
 	}
 }
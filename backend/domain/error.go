@@ -1,8 +1,123 @@
 package domain
 
+import (
+	"errors"
+	"strings"
+)
+
+// ErrorCode allows the UI and automations to branch on the kind of an error
+type ErrorCode string
+
+const (
+	ErrorCodeGitAuthFailed    ErrorCode = "GIT_AUTH_FAILED"
+	ErrorCodeParseError       ErrorCode = "PARSE_ERROR"
+	ErrorCodePlanFailed       ErrorCode = "PLAN_FAILED"
+	ErrorCodeNamespaceMissing ErrorCode = "NAMESPACE_MISSING"
+	ErrorCodeACLDenied        ErrorCode = "ACL_DENIED"
+	ErrorCodeJobConflict      ErrorCode = "JOB_CONFLICT"
+	ErrorCodePolicyViolation  ErrorCode = "POLICY_VIOLATION"
+	ErrorCodeRenderFailed     ErrorCode = "RENDER_FAILED"
+	ErrorCodeSchemaViolation  ErrorCode = "SCHEMA_VIOLATION"
+	// a phase of the sync did not finish in time
+	ErrorCodeTimeout ErrorCode = "TIMEOUT"
+	// the jobs of a source exceed the configured limits
+	ErrorCodeLimitExceeded ErrorCode = "LIMIT_EXCEEDED"
+	// a deployment of a job of the source made no progress for too long
+	ErrorCodeDeploymentStuck ErrorCode = "DEPLOYMENT_STUCK"
+
+	ErrorCodeInvalidRequest ErrorCode = "INVALID_REQUEST"
+	ErrorCodeUnauthorized   ErrorCode = "UNAUTHORIZED"
+	ErrorCodeNotFound       ErrorCode = "NOT_FOUND"
+	ErrorCodeInternal       ErrorCode = "INTERNAL"
+)
+
 type Error struct {
 
+	// The error code
+	Code ErrorCode `json:"code,omitempty"`
+
 	// The error message
 	// Required: true
 	Message *string `json:"message"`
 }
+
+// CodedError attaches an ErrorCode to an internal error
+type CodedError struct {
+	Code ErrorCode
+	Err  error
+}
+
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// WithErrorCode wraps err with the code, nil stays nil
+func WithErrorCode(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{
+		Code: code,
+		Err:  err,
+	}
+}
+
+// ErrorCodeOf returns the code of the outermost CodedError in the chain of err or the fallback
+func ErrorCodeOf(err error, fallback ErrorCode) ErrorCode {
+	var ce *CodedError
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return fallback
+}
+
+// PolicyViolation is a Sentinel policy (Nomad Enterprise) a job failed
+type PolicyViolation struct {
+	Policy string `json:"policy"`
+	// advisory, soft-mandatory or hard-mandatory
+	EnforcementLevel string `json:"enforcementLevel,omitempty"`
+	// the rules that evaluated to false
+	Rules []string `json:"rules,omitempty"`
+}
+
+func (v *PolicyViolation) String() string {
+	s := v.Policy
+	if v.EnforcementLevel != "" {
+		s += " (" + v.EnforcementLevel + ")"
+	}
+	if len(v.Rules) > 0 {
+		s += " rules " + strings.Join(v.Rules, ", ")
+	}
+	return s
+}
+
+// PolicyViolationError is returned if Nomad rejected a job because of Sentinel policies
+type PolicyViolationError struct {
+	Violations []*PolicyViolation
+	Err        error
+}
+
+func (e *PolicyViolationError) Error() string {
+	policies := []string{}
+	for _, v := range e.Violations {
+		policies = append(policies, v.String())
+	}
+	return "rejected by Sentinel policies " + strings.Join(policies, "; ")
+}
+
+func (e *PolicyViolationError) Unwrap() error {
+	return e.Err
+}
+
+// PolicyViolationsOf returns the violations of the first PolicyViolationError in the chain of err
+func PolicyViolationsOf(err error) []*PolicyViolation {
+	var pe *PolicyViolationError
+	if errors.As(err, &pe) {
+		return pe.Violations
+	}
+	return nil
+}
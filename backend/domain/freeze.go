@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// FreezeWindow is a declared change freeze, syncs only plan changes during the window
+type FreezeWindow struct {
+	Start time.Time `json:"start"`
+
+	End time.Time `json:"end"`
+
+	// reason of the freeze, e.g. the summary of the calendar event
+	Reason string `json:"reason,omitempty"`
+
+	// label selector of the frozen sources, all sources if empty
+	Selector string `json:"selector,omitempty"`
+}
+
+// Contains returns true if the time is within the window
+func (w *FreezeWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// Freezes returns true if the window applies to a source with the labels, invalid selectors freeze all sources
+func (w *FreezeWindow) Freezes(labels map[string]string) bool {
+	sel, err := ParseLabelSelector(w.Selector)
+	return err != nil || sel.Matches(labels)
+}
@@ -22,6 +22,13 @@ func InitModels(ctx context.Context, logger log.Logger, app core.App) error {
 	// allow everyone authenticated to list users
 	form := forms.NewCollectionUpsert(app, usersCollection)
 	form.ListRule = types.Pointer("@request.auth.id != ''")
+	// set for users that log in using LDAP
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "ldapDN",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options:  &schema.TextOptions{},
+	})
 	if err := form.Submit(); err != nil {
 		return err
 	}
@@ -49,11 +56,65 @@ func InitModels(ctx context.Context, logger log.Logger, app core.App) error {
 		return err
 	}
 
+	_, err = initSourceTemplateCollection(app, keyCollection, teamCollection, vaultTokenCollection)
+	if err != nil {
+		logger.LogError(ctx, "Could not initSourceTemplateCollection:%v", err)
+		return err
+	}
+
 	_, err = initEventCollection(app, srcCollection)
 	if err != nil {
 		logger.LogError(ctx, "Could not initEventCollection:%v", err)
 		return err
 	}
+
+	_, err = initAuditCollection(app, usersCollection, srcCollection)
+	if err != nil {
+		logger.LogError(ctx, "Could not initAuditCollection:%v", err)
+		return err
+	}
+
+	_, err = initNotificationTargetCollection(app)
+	if err != nil {
+		logger.LogError(ctx, "Could not initNotificationTargetCollection:%v", err)
+		return err
+	}
+
+	_, err = initSessionCollection(app, usersCollection)
+	if err != nil {
+		logger.LogError(ctx, "Could not initSessionCollection:%v", err)
+		return err
+	}
+
+	_, err = initStatusTokenCollection(app, teamCollection)
+	if err != nil {
+		logger.LogError(ctx, "Could not initStatusTokenCollection:%v", err)
+		return err
+	}
+
+	_, err = initPendingSyncCollection(app, srcCollection)
+	if err != nil {
+		logger.LogError(ctx, "Could not initPendingSyncCollection:%v", err)
+		return err
+	}
+
+	_, err = initCommitAnnotationCollection(app)
+	if err != nil {
+		logger.LogError(ctx, "Could not initCommitAnnotationCollection:%v", err)
+		return err
+	}
+
+	_, err = initJobHistoryCollection(app, srcCollection)
+	if err != nil {
+		logger.LogError(ctx, "Could not initJobHistoryCollection:%v", err)
+		return err
+	}
+
+	_, err = initReplicationTombstoneCollection(app)
+	if err != nil {
+		logger.LogError(ctx, "Could not initReplicationTombstoneCollection:%v", err)
+		return err
+	}
 	return nil
 }
 
@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// JobRevision is a spec of a job as registered for a source, recorded after a sync whenever it changed
+type JobRevision struct {
+	SourceID  string `json:"source"`
+	Namespace string `json:"namespace"`
+	Job       string `json:"job"`
+	// Commit the source was synced to when the revision was recorded
+	Commit string `json:"commit,omitempty"`
+	// Spec is the normalized job in the JSON format of the Nomad API, empty for deleted jobs
+	Spec json.RawMessage `json:"spec,omitempty"`
+	// Hash of the spec, used to record changes only
+	Hash string `json:"hash,omitempty"`
+	// Deleted marks that the job was no longer registered for the source
+	Deleted   bool      `json:"deleted,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Key identifies the job of the revision within its source
+func (r *JobRevision) Key() string {
+	return r.Namespace + "/" + r.Job
+}
+
+// SourceStateAt are the jobs a source had registered at a point in time
+type SourceStateAt struct {
+	Time time.Time `json:"time"`
+	// Commit of the latest revision before the time
+	Commit string `json:"commit,omitempty"`
+	// Jobs by <namespace>/<job>
+	Jobs map[string]*JobRevision `json:"jobs"`
+}
+
+type JobChangeType string
+
+const (
+	JobChangeTypeAdded   JobChangeType = "added"
+	JobChangeTypeRemoved JobChangeType = "removed"
+	JobChangeTypeChanged JobChangeType = "changed"
+)
+
+// FieldChange is a changed field of a job, From or To are empty if the field was added or removed
+type FieldChange struct {
+	// Path of the field, e.g. TaskGroups[0].Count
+	Path string          `json:"path"`
+	From json.RawMessage `json:"from,omitempty"`
+	To   json.RawMessage `json:"to,omitempty"`
+}
+
+// JobChange describes how a job differs between two points in time
+type JobChange struct {
+	Type JobChangeType `json:"type"`
+	// Fields are the changed fields of changed jobs, sorted by path
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// SourceStateDiff are the changes of the jobs of a source between two points in time
+type SourceStateDiff struct {
+	From *SourceStateAt `json:"from"`
+	To   *SourceStateAt `json:"to"`
+	// Jobs are the jobs that differ by <namespace>/<job>
+	Jobs map[string]*JobChange `json:"jobs"`
+}
+
+func initJobHistoryCollection(app core.App, srcCollection *models.Collection) (*models.Collection, error) {
+
+	collection, err := app.Dao().FindCollectionByNameOrId("job_history")
+
+	if err == sql.ErrNoRows {
+		collection = &models.Collection{}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(app, collection)
+	form.Name = "job_history"
+	form.Type = models.CollectionTypeBase
+	// written by nomad-ops only, read through the API which checks the teams of the source
+	form.ListRule = nil
+	form.ViewRule = nil
+	form.CreateRule = nil
+	form.UpdateRule = nil
+	form.DeleteRule = nil
+	form.Indexes = types.JsonArray[string]{
+		"create index job_history_source_timestamp on job_history (source, timestamp)",
+	}
+
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "source",
+		Type:     schema.FieldTypeRelation,
+		Required: true,
+		Options: &schema.RelationOptions{
+			MaxSelect:     types.Pointer(1),
+			CollectionId:  srcCollection.Id,
+			CascadeDelete: true,
+		},
+	})
+	for _, name := range []string{"namespace", "job", "commit", "hash"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeText,
+			Required: false,
+			Options: &schema.TextOptions{
+				Max: types.Pointer(500),
+			},
+		})
+	}
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "spec",
+		Type:     schema.FieldTypeJson,
+		Required: false,
+		Options:  &schema.JsonOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "deleted",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+		Options:  &schema.BoolOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "timestamp",
+		Type:     schema.FieldTypeDate,
+		Required: true,
+		Options:  &schema.DateOptions{},
+	})
+
+	// validate and submit (internally it calls app.Dao().SaveCollection(collection) in a transaction)
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+func JobRevisionFromRecord(record *models.Record) *JobRevision {
+	rev := &JobRevision{
+		SourceID:  record.GetString("source"),
+		Namespace: record.GetString("namespace"),
+		Job:       record.GetString("job"),
+		Commit:    record.GetString("commit"),
+		Hash:      record.GetString("hash"),
+		Deleted:   record.GetBool("deleted"),
+		Timestamp: record.GetDateTime("timestamp").Time(),
+	}
+	if spec := record.GetString("spec"); spec != "" && spec != "null" {
+		rev.Spec = json.RawMessage(spec)
+	}
+	return rev
+}
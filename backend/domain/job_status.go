@@ -1,6 +1,9 @@
 package domain
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type JobStatus struct {
 
@@ -14,6 +17,15 @@ type JobStatus struct {
 	// pending | ok | failed
 	DeploymentStatus string `json:"deploymentStatus,omitempty"`
 
+	// ID of the latest deployment of the job
+	DeploymentID string `json:"deploymentId,omitempty"`
+
+	// number of healthy allocations of the latest deployment
+	DeploymentHealthyAllocs int `json:"deploymentHealthyAllocs,omitempty"`
+
+	// time the running deployment was first seen or last gained a healthy allocation
+	DeploymentProgressTime *time.Time `json:"deploymentProgressTime,omitempty"`
+
 	// status description
 	StatusDescription string `json:"statusDescription,omitempty"`
 
@@ -25,4 +37,42 @@ type JobStatus struct {
 
 	// diff
 	Diff json.RawMessage `json:"diff,omitempty"`
+
+	// human readable summary of the diff
+	DiffSummary string `json:"diffSummary,omitempty"`
+
+	// warnings of parsing and planning the job, e.g. deprecated fields
+	Warnings []string `json:"warnings,omitempty"`
+
+	// commit the job was last applied at successfully
+	LastAppliedCommit string `json:"lastAppliedCommit,omitempty"`
+
+	// number of consecutive failures of applying the job at FailedCommit
+	Failures int `json:"failures,omitempty"`
+
+	// commit the job failed to apply at
+	FailedCommit string `json:"failedCommit,omitempty"`
+
+	// error of the last failure
+	LastError string `json:"lastError,omitempty"`
+
+	// Sentinel policies that rejected the job or, if not mandatory, warned about it
+	PolicyViolations []*PolicyViolation `json:"policyViolations,omitempty"`
+
+	// the failed job is not retried before this time, unless the commit changes
+	RetryAfter *time.Time `json:"retryAfter,omitempty"`
+
+	// pages of the job in the Nomad UI
+	Links *NomadLinks `json:"links,omitempty"`
+}
+
+// NomadLinks are deep links to the Nomad UI
+type NomadLinks struct {
+	Job string `json:"job,omitempty"`
+
+	// deployments of the job, set if the job has a deployment
+	Deployment string `json:"deployment,omitempty"`
+
+	// allocation that failed the latest deployment
+	Allocation string `json:"allocation,omitempty"`
 }
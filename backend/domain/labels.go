@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxLabels is the maximum number of labels of a source
+const MaxLabels = 64
+
+var (
+	// keys may have a prefix, e.g. example.com/team
+	labelKeyRegex   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._/-]{0,126}[a-zA-Z0-9])?$`)
+	labelValueRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9._-]{0,61}[a-zA-Z0-9])?)?$`)
+)
+
+// ValidateLabels returns an error naming the first invalid label
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("at most %d labels are allowed", MaxLabels))
+	}
+	keys := []string{}
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !labelKeyRegex.MatchString(k) {
+			return WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid label key %q", k))
+		}
+		if !labelValueRegex.MatchString(labels[k]) {
+			return WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid value %q of label %s", labels[k], k))
+		}
+	}
+	return nil
+}
+
+type labelOperator string
+
+const (
+	labelOperatorEquals    labelOperator = "="
+	labelOperatorNotEquals labelOperator = "!="
+	labelOperatorExists    labelOperator = "exists"
+	labelOperatorNotExists labelOperator = "!exists"
+)
+
+type labelRequirement struct {
+	key      string
+	operator labelOperator
+	value    string
+}
+
+// LabelSelector selects sources by their labels, e.g. "env=prod,team!=payments,critical,!deprecated".
+// A source matches if it matches all requirements, the empty selector matches all sources.
+type LabelSelector struct {
+	requirements []labelRequirement
+}
+
+// ParseLabelSelector parses comma separated requirements: key=value, key==value, key!=value,
+// key (the label exists) and !key (the label does not exist)
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	sel := LabelSelector{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		r := labelRequirement{}
+		switch {
+		case strings.Contains(part, "!="):
+			r.key, r.value, _ = strings.Cut(part, "!=")
+			r.operator = labelOperatorNotEquals
+		case strings.Contains(part, "=="):
+			r.key, r.value, _ = strings.Cut(part, "==")
+			r.operator = labelOperatorEquals
+		case strings.Contains(part, "="):
+			r.key, r.value, _ = strings.Cut(part, "=")
+			r.operator = labelOperatorEquals
+		case strings.HasPrefix(part, "!"):
+			r.key = strings.TrimPrefix(part, "!")
+			r.operator = labelOperatorNotExists
+		default:
+			r.key = part
+			r.operator = labelOperatorExists
+		}
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if !labelKeyRegex.MatchString(r.key) || !labelValueRegex.MatchString(r.value) {
+			return LabelSelector{}, WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid label selector %q", part))
+		}
+		sel.requirements = append(sel.requirements, r)
+	}
+	return sel, nil
+}
+
+// IsEmpty returns true if the selector matches all sources
+func (s LabelSelector) IsEmpty() bool {
+	return len(s.requirements) == 0
+}
+
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s.requirements {
+		v, ok := labels[r.key]
+		switch r.operator {
+		case labelOperatorEquals:
+			if !ok || v != r.value {
+				return false
+			}
+		case labelOperatorNotEquals:
+			if ok && v == r.value {
+				return false
+			}
+		case labelOperatorExists:
+			if !ok {
+				return false
+			}
+		case labelOperatorNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s LabelSelector) String() string {
+	parts := []string{}
+	for _, r := range s.requirements {
+		switch r.operator {
+		case labelOperatorExists:
+			parts = append(parts, r.key)
+		case labelOperatorNotExists:
+			parts = append(parts, "!"+r.key)
+		default:
+			parts = append(parts, r.key+string(r.operator)+r.value)
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "testing"
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"env": "prod", "team": "payments", "example.com/tier": "web"}
+	for selector, expected := range map[string]bool{
+		"":                          true,
+		"env=prod":                  true,
+		"env==prod, team=payments":  true,
+		"env=dev":                   false,
+		"team!=payments":            false,
+		"team!=search":              true,
+		"example.com/tier":          true,
+		"critical":                  false,
+		"!critical":                 true,
+		"!env":                      false,
+		"env=prod,example.com/tier": true,
+		"env=prod,critical":         false,
+	} {
+		sel, err := ParseLabelSelector(selector)
+		if err != nil {
+			t.Errorf("Could not parse %q:%v", selector, err)
+			continue
+		}
+		if sel.Matches(labels) != expected {
+			t.Errorf("Expected %q to match %v", selector, expected)
+		}
+	}
+
+	for _, selector := range []string{"=prod", "env=a b", "env in (a,b)", "!"} {
+		if _, err := ParseLabelSelector(selector); err == nil {
+			t.Errorf("Expected %q to be invalid", selector)
+		}
+	}
+
+	if err := ValidateLabels(map[string]string{"env": "prod", "owner": ""}); err != nil {
+		t.Errorf("Expected valid labels, got %v", err)
+	}
+	if err := ValidateLabels(map[string]string{"env": "-prod"}); err == nil {
+		t.Errorf("Expected an invalid value to be rejected")
+	}
+}
@@ -0,0 +1,236 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// NotificationTarget holds the runtime settings of a configured notifier (slack, webhook, ...)
+type NotificationTarget struct {
+
+	// id
+	// Read Only: true
+	ID string `json:"id,omitempty"`
+
+	// name of the notifier
+	// Required: true
+	Name string `json:"name"`
+
+	// if true no notifications are sent to this target
+	Muted bool `json:"muted,omitempty"`
+
+	// if set no notifications are sent to this target until the given time
+	SnoozedUntil *time.Time `json:"snoozedUntil,omitempty"`
+
+	// if set notifications are collected and sent as one summary per window, e.g. 15m
+	DigestWindow string `json:"digestWindow,omitempty"`
+
+	// label selector of the sources notified to this target, e.g. env=prod, all sources if empty
+	SourceSelector string `json:"sourceSelector,omitempty"`
+
+	// status of the last delivery attempt
+	// Read Only: true
+	// Enum: [delivered retrying failed]
+	LastDeliveryStatus string `json:"lastDeliveryStatus,omitempty"`
+
+	// error of the last failed delivery attempt
+	// Read Only: true
+	LastError string `json:"lastError,omitempty"`
+
+	// time of the last delivery attempt
+	// Read Only: true
+	LastAttemptTime *time.Time `json:"lastAttemptTime,omitempty"`
+
+	// time of the last successful delivery
+	// Read Only: true
+	LastSuccessTime *time.Time `json:"lastSuccessTime,omitempty"`
+
+	// number of notifications waiting for a retry
+	// Read Only: true
+	Pending int `json:"pending,omitempty"`
+}
+
+const (
+	NotificationDeliveryStatusDelivered string = "delivered"
+
+	NotificationDeliveryStatusRetrying string = "retrying"
+
+	NotificationDeliveryStatusFailed string = "failed"
+)
+
+// NotificationDelivery is the outcome of a single delivery attempt to a target
+type NotificationDelivery struct {
+	Status  string
+	Error   string
+	Time    time.Time
+	Pending int
+}
+
+// IsSilenced returns true if the target is muted or snoozed at the given time
+func (t *NotificationTarget) IsSilenced(now time.Time) bool {
+	if t.Muted {
+		return true
+	}
+	return t.SnoozedUntil != nil && now.Before(*t.SnoozedUntil)
+}
+
+// Selects returns true if notifications about the source are sent to the target. Notifications without a source
+// are sent to all targets, an invalid selector selects all sources.
+func (t *NotificationTarget) Selects(src *Source) bool {
+	if src == nil {
+		return true
+	}
+	sel, err := ParseLabelSelector(t.SourceSelector)
+	return err != nil || sel.Matches(src.Labels)
+}
+
+// Digest returns the digest window of the target, 0 if notifications are sent immediately
+func (t *NotificationTarget) Digest() time.Duration {
+	if t.DigestWindow == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(t.DigestWindow)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+func initNotificationTargetCollection(app core.App) (*models.Collection, error) {
+
+	collection, err := app.Dao().FindCollectionByNameOrId("notification_targets")
+
+	if err == sql.ErrNoRows {
+		collection = &models.Collection{}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(app, collection)
+	form.Name = "notification_targets"
+	form.Type = models.CollectionTypeBase
+	form.ListRule = types.Pointer("@request.auth.id != ''")
+	form.ViewRule = types.Pointer("@request.auth.id != ''")
+	// targets are created by nomad-ops on startup based on the configured notifiers
+	form.CreateRule = nil
+	// muting and snoozing affects the alerting of all teams
+	form.UpdateRule = nil
+	form.DeleteRule = nil
+	form.Indexes = types.JsonArray[string]{
+		"create unique index notification_target_unique on notification_targets (name)",
+	}
+
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "name",
+		Type:     schema.FieldTypeText,
+		Required: true,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(100),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "muted",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "snoozedUntil",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "digestWindow",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max:     types.Pointer(20),
+			Pattern: `^(\d+(ns|us|ms|s|m|h))*$`,
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "sourceSelector",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(500),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "lastDeliveryStatus",
+		Type:     schema.FieldTypeSelect,
+		Required: false,
+		Options: &schema.SelectOptions{
+			MaxSelect: 1,
+			Values: []string{
+				NotificationDeliveryStatusDelivered,
+				NotificationDeliveryStatusRetrying,
+				NotificationDeliveryStatusFailed,
+			},
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "lastError",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(1000),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "lastAttemptTime",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "lastSuccessTime",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "pending",
+		Type:     schema.FieldTypeNumber,
+		Required: false,
+		Options:  &schema.NumberOptions{},
+	})
+
+	// validate and submit (internally it calls app.Dao().SaveCollection(collection) in a transaction)
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+func NotificationTargetFromRecord(record *models.Record) *NotificationTarget {
+	return &NotificationTarget{
+		ID:           record.Id,
+		Name:         record.GetString("name"),
+		Muted:        record.GetBool("muted"),
+		SnoozedUntil: timeToPtr(record.GetDateTime("snoozedUntil").Time()),
+		DigestWindow: record.GetString("digestWindow"),
+
+		SourceSelector: record.GetString("sourceSelector"),
+
+		LastDeliveryStatus: record.GetString("lastDeliveryStatus"),
+		LastError:          record.GetString("lastError"),
+		LastAttemptTime:    timeToPtr(record.GetDateTime("lastAttemptTime").Time()),
+		LastSuccessTime:    timeToPtr(record.GetDateTime("lastSuccessTime").Time()),
+		Pending:            record.GetInt("pending"),
+	}
+}
+
+func timeToPtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
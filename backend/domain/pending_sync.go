@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// PendingSync is a requested sync of a source that did not run yet, stored so it survives restarts.
+// Requests for the same source are merged like the syncs queued in memory.
+type PendingSync struct {
+	SourceID     string `json:"source"`
+	ForceRestart bool   `json:"forceRestart,omitempty"`
+	IgnoreFreeze bool   `json:"ignoreFreeze,omitempty"`
+
+	// the user action the sync was requested by, empty for webhooks
+	ActionID   string          `json:"actionId,omitempty"`
+	ActionType AuditActionType `json:"actionType,omitempty"`
+	UserID     string          `json:"userId,omitempty"`
+	Actor      string          `json:"actor,omitempty"`
+	ActionTime time.Time       `json:"actionTime,omitempty"`
+
+	// time of the first request merged into the pending sync
+	RequestedAt time.Time `json:"requestedAt"`
+	// time of the last request merged into the pending sync
+	LastRequestedAt time.Time `json:"lastRequestedAt"`
+}
+
+func initPendingSyncCollection(app core.App, srcCollection *models.Collection) (*models.Collection, error) {
+
+	collection, err := app.Dao().FindCollectionByNameOrId("pending_syncs")
+
+	if err == sql.ErrNoRows {
+		collection = &models.Collection{}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(app, collection)
+	form.Name = "pending_syncs"
+	form.Type = models.CollectionTypeBase
+	// written and replayed by nomad-ops only
+	form.ListRule = nil
+	form.ViewRule = nil
+	form.CreateRule = nil
+	form.UpdateRule = nil
+	form.DeleteRule = nil
+	form.Indexes = types.JsonArray[string]{
+		"create unique index pending_sync_source_unique on pending_syncs (source)",
+	}
+
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "source",
+		Type:     schema.FieldTypeRelation,
+		Required: true,
+		Options: &schema.RelationOptions{
+			MaxSelect:     types.Pointer(1),
+			CollectionId:  srcCollection.Id,
+			CascadeDelete: true,
+		},
+	})
+	for _, name := range []string{"forceRestart", "ignoreFreeze"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeBool,
+			Required: false,
+			Options:  &schema.BoolOptions{},
+		})
+	}
+	for _, name := range []string{"actionId", "actionType", "userId", "actor"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeText,
+			Required: false,
+			Options: &schema.TextOptions{
+				Max: types.Pointer(500),
+			},
+		})
+	}
+	for _, name := range []string{"actionTime", "requestedAt", "lastRequestedAt"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeDate,
+			Required: false,
+			Options:  &schema.DateOptions{},
+		})
+	}
+
+	// validate and submit (internally it calls app.Dao().SaveCollection(collection) in a transaction)
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+func PendingSyncFromRecord(record *models.Record) *PendingSync {
+	return &PendingSync{
+		SourceID:        record.GetString("source"),
+		ForceRestart:    record.GetBool("forceRestart"),
+		IgnoreFreeze:    record.GetBool("ignoreFreeze"),
+		ActionID:        record.GetString("actionId"),
+		ActionType:      AuditActionType(record.GetString("actionType")),
+		UserID:          record.GetString("userId"),
+		Actor:           record.GetString("actor"),
+		ActionTime:      record.GetDateTime("actionTime").Time(),
+		RequestedAt:     record.GetDateTime("requestedAt").Time(),
+		LastRequestedAt: record.GetDateTime("lastRequestedAt").Time(),
+	}
+}
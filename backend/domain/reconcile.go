@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type ReconcilePhase string
+
+const (
+	// ReconcilePhaseFetch covers fetching the git repository and parsing the job files
+	ReconcilePhaseFetch ReconcilePhase = "fetch"
+	// ReconcilePhasePrepare covers resolving vault tokens and applying the source overrides
+	ReconcilePhasePrepare ReconcilePhase = "prepare"
+	// ReconcilePhaseApply covers comparing the jobs with the cluster and applying the changes
+	ReconcilePhaseApply ReconcilePhase = "apply"
+
+	// the steps of the phases above, which are bounded by their own timeouts
+
+	// ReconcilePhaseRender covers rendering the jobs of a source with a renderer
+	ReconcilePhaseRender ReconcilePhase = "render"
+	// ReconcilePhaseParse covers parsing a job file
+	ReconcilePhaseParse ReconcilePhase = "parse"
+	// ReconcilePhasePlan covers planning a job
+	ReconcilePhasePlan ReconcilePhase = "plan"
+	// ReconcilePhaseRegister covers registering a job
+	ReconcilePhaseRegister ReconcilePhase = "register"
+	// ReconcilePhaseDeploymentWait covers waiting for the deployments started by a sync
+	ReconcilePhaseDeploymentWait ReconcilePhase = "deploymentWait"
+)
+
+// PhaseTimeoutError is returned if a phase of a sync did not finish in time
+type PhaseTimeoutError struct {
+	Phase   ReconcilePhase
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *PhaseTimeoutError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s did not finish within %v", e.Phase, e.Timeout)
+	}
+	return fmt.Sprintf("%s did not finish within %v: %v", e.Phase, e.Timeout, e.Err)
+}
+
+func (e *PhaseTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// TimedOutPhaseOf returns the phase of the first PhaseTimeoutError in the chain of err, empty if there is none
+func TimedOutPhaseOf(err error) ReconcilePhase {
+	var te *PhaseTimeoutError
+	if errors.As(err, &te) {
+		return te.Phase
+	}
+	return ""
+}
+
+// Reconcile is a currently running sync of a source, it is not persisted
+type Reconcile struct {
+	SourceID string `json:"sourceId"`
+
+	SourceName string `json:"sourceName"`
+
+	Phase ReconcilePhase `json:"phase"`
+
+	// the job currently compared or applied, only set in the apply phase
+	CurrentJob string `json:"currentJob,omitempty"`
+
+	// the user action that triggered the sync, empty for automatic syncs
+	ActionID string `json:"actionId,omitempty"`
+
+	StartedAt time.Time `json:"startedAt"`
+
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// ReplicationTombstonesCollection records the deleted records, so a standby deletes them as well
+const ReplicationTombstonesCollection = "replication_tombstones"
+
+// ReplicationBatch is a page of the changes of the store of the primary since the cursor of the standby
+type ReplicationBatch struct {
+	// Cursor is passed with the next request for changes once all pages got applied
+	Cursor time.Time `json:"cursor"`
+	// Full is set if the batch contains all rows, rows missing in it are deleted by the standby
+	Full bool `json:"full,omitempty"`
+	// Tables by name
+	Tables map[string]*ReplicatedTable `json:"tables"`
+	// Next is the page requested next, nil for the last page
+	Next *ReplicationPage `json:"next,omitempty"`
+}
+
+// ReplicationPage points to the rows of a table following a row id, pages are ordered by table and id
+type ReplicationPage struct {
+	Table string `json:"table"`
+	After string `json:"after,omitempty"`
+}
+
+// ReplicatedTable are the created or updated rows and the ids of the deleted rows of a table
+type ReplicatedTable struct {
+	// Rows are the columns of each row as stored ordered by id, NULL columns are nil
+	Rows    []map[string]*string `json:"rows,omitempty"`
+	Deleted []string             `json:"deleted,omitempty"`
+	// After and Until limit the ids of the rows in this page, Until is empty if the table ends in this page
+	After string `json:"after,omitempty"`
+	Until string `json:"until,omitempty"`
+}
+
+// StandbyStatus is the state of the replication of a standby
+type StandbyStatus struct {
+	Primary string `json:"primary"`
+	// Promoted is set once the standby took over, it does not replicate anymore
+	Promoted         bool       `json:"promoted"`
+	LastReplicatedAt *time.Time `json:"lastReplicatedAt,omitempty"`
+	// Cursor is the time of the primary up to which the changes got replicated
+	Cursor *time.Time `json:"cursor,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+func initReplicationTombstoneCollection(app core.App) (*models.Collection, error) {
+
+	collection, err := app.Dao().FindCollectionByNameOrId(ReplicationTombstonesCollection)
+
+	if err == sql.ErrNoRows {
+		collection = &models.Collection{}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(app, collection)
+	form.Name = ReplicationTombstonesCollection
+	form.Type = models.CollectionTypeBase
+	// only read by standbys through the replication API
+	form.ListRule = nil
+	form.ViewRule = nil
+	form.CreateRule = nil
+	form.UpdateRule = nil
+	form.DeleteRule = nil
+	form.Indexes = types.JsonArray[string]{
+		"create index replication_tombstone_created on replication_tombstones (created)",
+	}
+
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "tableName",
+		Type:     schema.FieldTypeText,
+		Required: true,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(200),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "recordId",
+		Type:     schema.FieldTypeText,
+		Required: true,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(100),
+		},
+	})
+
+	// validate and submit (internally it calls app.Dao().SaveCollection(collection) in a transaction)
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Session is an issued auth token of a user
+type Session struct {
+
+	// id
+	// Read Only: true
+	ID string `json:"id,omitempty"`
+
+	// the user the token was issued for
+	// Required: true
+	UserID string `json:"user"`
+
+	UserAgent string `json:"userAgent,omitempty"`
+
+	IP string `json:"ip,omitempty"`
+
+	// time the token was issued or first seen
+	Created time.Time `json:"created"`
+
+	// time of the last request using the token
+	LastSeen time.Time `json:"lastSeen"`
+
+	// if true the token is rejected
+	Revoked bool `json:"revoked,omitempty"`
+
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// SessionPolicy limits the lifetime of sessions, a zero value disables the limit
+type SessionPolicy struct {
+	// TTL is the maximum age of a session
+	TTL time.Duration
+	// InactivityTimeout ends a session if it was not used for this duration
+	InactivityTimeout time.Duration
+}
+
+// IsActive returns true if the session was not revoked and is within the limits of the policy
+func (s *Session) IsActive(now time.Time, p SessionPolicy) bool {
+	if s.Revoked {
+		return false
+	}
+	if p.TTL > 0 && now.Sub(s.Created) > p.TTL {
+		return false
+	}
+	if p.InactivityTimeout > 0 && now.Sub(s.LastSeen) > p.InactivityTimeout {
+		return false
+	}
+	return true
+}
+
+func initSessionCollection(app core.App, usersCollection *models.Collection) (*models.Collection, error) {
+
+	collection, err := app.Dao().FindCollectionByNameOrId("sessions")
+
+	if err == sql.ErrNoRows {
+		collection = &models.Collection{}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(app, collection)
+	form.Name = "sessions"
+	form.Type = models.CollectionTypeBase
+	// sessions are managed by nomad-ops and the admin API only
+	form.ListRule = nil
+	form.ViewRule = nil
+	form.CreateRule = nil
+	form.UpdateRule = nil
+	form.DeleteRule = nil
+	form.Indexes = types.JsonArray[string]{
+		"create unique index session_token_unique on sessions (tokenHash)",
+	}
+
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "user",
+		Type:     schema.FieldTypeRelation,
+		Required: true,
+		Options: &schema.RelationOptions{
+			MaxSelect:     types.Pointer(1),
+			CollectionId:  usersCollection.Id,
+			CascadeDelete: true,
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "tokenHash",
+		Type:     schema.FieldTypeText,
+		Required: true,
+		Options:  &schema.TextOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "userAgent",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(500),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "ip",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options:  &schema.TextOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "lastSeen",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "revoked",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "revokedAt",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
+
+	// validate and submit (internally it calls app.Dao().SaveCollection(collection) in a transaction)
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+func SessionFromRecord(record *models.Record) *Session {
+	return &Session{
+		ID:        record.Id,
+		UserID:    record.GetString("user"),
+		UserAgent: record.GetString("userAgent"),
+		IP:        record.GetString("ip"),
+		Created:   record.GetDateTime("created").Time(),
+		LastSeen:  record.GetDateTime("lastSeen").Time(),
+		Revoked:   record.GetBool("revoked"),
+		RevokedAt: timeToPtr(record.GetDateTime("revokedAt").Time()),
+	}
+}
@@ -3,6 +3,7 @@ package domain
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/forms"
@@ -28,12 +29,31 @@ type Source struct {
 	// Required: true
 	Branch string `json:"branch"`
 
+	// commit or tag the source is pinned to instead of the head of its branch, e.g. set by a promotion
+	Revision string `json:"revision,omitempty"`
+
+	// id of the next source of the promotion pipeline, the commit of a healthy sync is promoted to it
+	PromoteTo string `json:"promoteTo,omitempty"`
+
+	// if true commits promoted to this source wait for an approval
+	PromotionApproval bool `json:"promotionApproval,omitempty"`
+
+	// commit promoted to this source that waits for an approval
+	// Read Only: true
+	PendingPromotion string `json:"pendingPromotion,omitempty"`
+
 	// if true the namespace will be created if it does not exist
 	CreateNamespace bool `json:"createNamespace,omitempty"`
 
 	// if set, will override whatever is written in the job file. Use comma to provide multiple.
 	DataCenter string `json:"dataCenter,omitempty"`
 
+	// datacenters of jobs that do not declare any. Use comma to provide multiple.
+	DefaultDataCenters string `json:"defaultDataCenters,omitempty"`
+
+	// node pool of jobs that do not set one
+	DefaultNodePool string `json:"defaultNodePool,omitempty"`
+
 	// deployKeyID to use
 	DeployKeyID string `json:"deployKeyID,omitempty"`
 
@@ -43,19 +63,69 @@ type Source struct {
 	// if true every commit forces an job update
 	Force bool `json:"force,omitempty"`
 
+	// if true job files that produce parser warnings are rejected
+	StrictParsing bool `json:"strictParsing,omitempty"`
+
+	// if true job files that are not valid HCL2 are parsed with the deprecated HCL1 parser
+	HCL1Fallback bool `json:"hcl1Fallback,omitempty"`
+
+	// if true jobs are only registered if all of them can be planned, and reverted if one of them fails to register
+	Atomic bool `json:"atomic,omitempty"`
+
+	// if true jobs are planned without a diff, only checking policies and feasibility, e.g. for jobs with many groups.
+	// Changes are detected by comparing a hash of the job spec with the one of the registered job.
+	SkipPlanDiff bool `json:"skipPlanDiff,omitempty"`
+
+	// if true the registered jobs are committed to the snapshot branch after every successful sync
+	Snapshot bool `json:"snapshot,omitempty"`
+
 	// if true no syncing is paused
 	Paused bool `json:"paused,omitempty"`
 
+	// if true the source is not synced on the regular interval
+	DisablePolling bool `json:"disablePolling,omitempty"`
+
+	// if true pushes to the repository and image pushes do not sync the source
+	DisableWebhooks bool `json:"disableWebhooks,omitempty"`
+
+	// if true changes of the jobs in Nomad do not sync the source
+	DisableNomadEvents bool `json:"disableNomadEvents,omitempty"`
+
+	// if true no notifications are sent for this source
+	NotificationsMuted bool `json:"notificationsMuted,omitempty"`
+
+	// if set no notifications are sent for this source until the given time
+	NotificationsSnoozedUntil *time.Time `json:"notificationsSnoozedUntil,omitempty"`
+
 	// if set, will override whatever is written in the job file
 	Namespace string `json:"namespace,omitempty"`
 
+	// if set the jobs of the source are pruned and the source is deleted at this time, e.g. for preview environments
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// time the warning about the expiry was sent
+	// Read Only: true
+	ExpiryWarnedAt *time.Time `json:"expiryWarnedAt,omitempty"`
+
 	// path in the repo
 	// Required: true
 	Path string `json:"path"`
 
-	// region
+	// name of the renderer generating the jobs from the path, empty to read the job files
+	Renderer string `json:"renderer,omitempty"`
+
+	// variables passed to the renderer, e.g. as ext vars of Jsonnet
+	Variables map[string]string `json:"variables,omitempty"`
+
+	// region of jobs that do not declare a region, also used to list the jobs of the source
 	Region string `json:"region,omitempty"`
 
+	// free-form labels, e.g. env=prod, used to filter and select sources
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// teams owning the source, every user can access it if empty
+	TeamIDs []string `json:"teams,omitempty"`
+
 	// status
 	// Read Only: true
 	Status *SourceStatus `json:"status,omitempty"`
@@ -65,6 +135,39 @@ type Source struct {
 	URL string `json:"url"`
 }
 
+// SyncTrigger is what requested a sync of a source
+type SyncTrigger string
+
+const (
+	// SyncTriggerManual is a sync requested by a user or by nomad-ops itself, it is always enabled
+	SyncTriggerManual     SyncTrigger = ""
+	SyncTriggerPoll       SyncTrigger = "poll"
+	SyncTriggerWebhook    SyncTrigger = "webhook"
+	SyncTriggerNomadEvent SyncTrigger = "nomadEvent"
+)
+
+// TriggerEnabled returns true if the trigger may sync the source, sources with all triggers
+// disabled are only synced manually
+func (s *Source) TriggerEnabled(t SyncTrigger) bool {
+	switch t {
+	case SyncTriggerPoll:
+		return !s.DisablePolling
+	case SyncTriggerWebhook:
+		return !s.DisableWebhooks
+	case SyncTriggerNomadEvent:
+		return !s.DisableNomadEvents
+	}
+	return true
+}
+
+// NotificationsSilenced returns true if notifications for this source are muted or snoozed at the given time
+func (s *Source) NotificationsSilenced(now time.Time) bool {
+	if s.NotificationsMuted {
+		return true
+	}
+	return s.NotificationsSnoozedUntil != nil && now.Before(*s.NotificationsSnoozedUntil)
+}
+
 func initSourceCollection(app core.App,
 	keysCollection *models.Collection,
 	teamsCollection *models.Collection,
@@ -112,6 +215,36 @@ func initSourceCollection(app core.App,
 			Max: types.Pointer(100),
 		},
 	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "revision",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(100),
+		},
+	})
+	// id of a source, a relation would require the id of this collection before it is created
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "promoteTo",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(15),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "promotionApproval",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "pendingPromotion",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(100),
+		},
+	})
 	addOrUpdateField(form, &schema.SchemaField{
 		Name:     "path",
 		Type:     schema.FieldTypeText,
@@ -128,6 +261,30 @@ func initSourceCollection(app core.App,
 			Max: types.Pointer(100),
 		},
 	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "defaultDataCenters",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(100),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "defaultNodePool",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(100),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "renderer",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(100),
+		},
+	})
 	addOrUpdateField(form, &schema.SchemaField{
 		Name:     "region",
 		Type:     schema.FieldTypeText,
@@ -164,12 +321,67 @@ func initSourceCollection(app core.App,
 		Type:     schema.FieldTypeBool,
 		Required: false,
 	})
+	for _, name := range []string{"disablePolling", "disableWebhooks", "disableNomadEvents"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeBool,
+			Required: false,
+		})
+	}
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "strictParsing",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "hcl1Fallback",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "atomic",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "skipPlanDiff",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "snapshot",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "notificationsMuted",
+		Type:     schema.FieldTypeBool,
+		Required: false,
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "notificationsSnoozedUntil",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
 	addOrUpdateField(form, &schema.SchemaField{
 		Name:     "status",
 		Type:     schema.FieldTypeJson,
 		Required: false,
 		Options:  &schema.JsonOptions{},
 	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "variables",
+		Type:     schema.FieldTypeJson,
+		Required: false,
+		Options:  &schema.JsonOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "labels",
+		Type:     schema.FieldTypeJson,
+		Required: false,
+		Options:  &schema.JsonOptions{},
+	})
 	addOrUpdateField(form, &schema.SchemaField{
 		Name:     "teams",
 		Type:     schema.FieldTypeRelation,
@@ -178,6 +390,25 @@ func initSourceCollection(app core.App,
 			CollectionId: teamsCollection.Id,
 		},
 	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "expiresAt",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "expiryWarnedAt",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
+	// sha256 of the token of the public status badge, empty if the badge is disabled
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "badgeTokenHash",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options:  &schema.TextOptions{},
+	})
 	addOrUpdateField(form, &schema.SchemaField{
 		Name:     "vaultToken",
 		Type:     schema.FieldTypeRelation,
@@ -207,21 +438,62 @@ func SourceFromRecord(record *models.Record, withStatus bool) *Source {
 	} else {
 		status = nil
 	}
+	var variables map[string]string
+	if record.GetString("variables") != "" {
+		err := record.UnmarshalJSONField("variables", &variables)
+		if err != nil {
+			fmt.Printf("Could not unmarshal variables field:%v", err)
+		}
+	}
+	var labels map[string]string
+	if record.GetString("labels") != "" {
+		err := record.UnmarshalJSONField("labels", &labels)
+		if err != nil {
+			fmt.Printf("Could not unmarshal labels field:%v", err)
+		}
+	}
 	src := &Source{
 		ID:              record.Id,
 		Name:            record.GetString("name"),
 		URL:             record.GetString("url"),
 		Branch:          record.GetString("branch"),
+		Revision:        record.GetString("revision"),
 		Path:            record.GetString("path"),
 		DataCenter:      record.GetString("dataCenter"),
 		Region:          record.GetString("region"),
 		Namespace:       record.GetString("namespace"),
+		TeamIDs:         record.GetStringSlice("teams"),
 		DeployKeyID:     record.GetString("deployKey"),
 		VaultTokenID:    record.GetString("vaultToken"),
 		CreateNamespace: record.GetBool("createNamespace"),
 		Force:           record.GetBool("force"),
 		Paused:          record.GetBool("paused"),
+		StrictParsing:   record.GetBool("strictParsing"),
+		HCL1Fallback:    record.GetBool("hcl1Fallback"),
+		Atomic:          record.GetBool("atomic"),
+		SkipPlanDiff:    record.GetBool("skipPlanDiff"),
+		Snapshot:        record.GetBool("snapshot"),
 		Status:          status,
+
+		DefaultDataCenters: record.GetString("defaultDataCenters"),
+		DefaultNodePool:    record.GetString("defaultNodePool"),
+		Renderer:           record.GetString("renderer"),
+		Variables:          variables,
+		Labels:             labels,
+
+		DisablePolling:     record.GetBool("disablePolling"),
+		DisableWebhooks:    record.GetBool("disableWebhooks"),
+		DisableNomadEvents: record.GetBool("disableNomadEvents"),
+
+		PromoteTo:         record.GetString("promoteTo"),
+		PromotionApproval: record.GetBool("promotionApproval"),
+		PendingPromotion:  record.GetString("pendingPromotion"),
+
+		NotificationsMuted:        record.GetBool("notificationsMuted"),
+		NotificationsSnoozedUntil: timeToPtr(record.GetDateTime("notificationsSnoozedUntil").Time()),
+
+		ExpiresAt:      timeToPtr(record.GetDateTime("expiresAt").Time()),
+		ExpiryWarnedAt: timeToPtr(record.GetDateTime("expiryWarnedAt").Time()),
 	}
 
 	return src
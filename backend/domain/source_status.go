@@ -22,6 +22,26 @@ type SourceStatus struct {
 	// Read Only: true
 	Message string `json:"message,omitempty"`
 
+	// code of the error if the status is error
+	// Read Only: true
+	ErrorCode ErrorCode `json:"errorCode,omitempty"`
+
+	// phase of the last sync that did not finish in time
+	// Read Only: true
+	TimedOutPhase ReconcilePhase `json:"timedOutPhase,omitempty"`
+
+	// summary of the pending changes of a paused source which was last notified
+	// Read Only: true
+	OutOfSyncSummary string `json:"outOfSyncSummary,omitempty"`
+
+	// conditions of the source an incident is open for, e.g. in PagerDuty
+	// Read Only: true
+	Incidents []IncidentCondition `json:"incidents,omitempty"`
+
+	// change freeze the last sync was blocked by, changes were only planned
+	// Read Only: true
+	Freeze *FreezeWindow `json:"freeze,omitempty"`
+
 	// status
 	// Read Only: true
 	// Enum: [synced error unknown syncing init]
@@ -51,6 +71,18 @@ func (s *SourceStatus) DetermineSyncStatus() bool {
 	return pending
 }
 
+// AppliedCommit returns the commit all jobs were last applied at, empty if they differ
+func (s *SourceStatus) AppliedCommit() string {
+	commit, first := "", true
+	for _, job := range s.Jobs {
+		if !first && job.LastAppliedCommit != commit {
+			return ""
+		}
+		commit, first = job.LastAppliedCommit, false
+	}
+	return commit
+}
+
 const (
 	SourceStatusStatusSynced string = "synced"
 
@@ -66,3 +98,15 @@ const (
 
 	SourceStatusStatusInit string = "init"
 )
+
+// IncidentCondition is a condition of a source an incident is opened for, until the source recovers
+type IncidentCondition string
+
+const (
+	// IncidentConditionSyncFailing is set if the syncs of the source failed for a while
+	IncidentConditionSyncFailing IncidentCondition = "syncFailing"
+	// IncidentConditionDeploymentFailed is set if a deployment of a job of the source failed
+	IncidentConditionDeploymentFailed IncidentCondition = "deploymentFailed"
+	// IncidentConditionDrift is set if the jobs of a paused source differ from the repository
+	IncidentConditionDrift IncidentCondition = "drift"
+)
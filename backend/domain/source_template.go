@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"database/sql"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// SourceTemplateNamePlaceholder is replaced by the name of the new source in the text fields of a template
+const SourceTemplateNamePlaceholder = "{name}"
+
+// SourceSettingFields are the fields of a source that are copied from a template or when cloning a source
+var SourceSettingFields = []string{
+	"url",
+	"branch",
+	"path",
+	"namespace",
+	"region",
+	"dataCenter",
+	"defaultDataCenters",
+	"defaultNodePool",
+	"renderer",
+	"deployKey",
+	"vaultToken",
+	"teams",
+	"labels",
+	"force",
+	"strictParsing",
+	"hcl1Fallback",
+	"atomic",
+	"skipPlanDiff",
+	"snapshot",
+	"notificationsMuted",
+	"disablePolling",
+	"disableWebhooks",
+	"disableNomadEvents",
+}
+
+func initSourceTemplateCollection(app core.App,
+	keysCollection *models.Collection,
+	teamsCollection *models.Collection,
+	vaultTokenCollection *models.Collection) (*models.Collection, error) {
+
+	collection, err := app.Dao().FindCollectionByNameOrId("source_templates")
+
+	if err == sql.ErrNoRows {
+		collection = &models.Collection{}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(app, collection)
+	form.Name = "source_templates"
+	form.Type = models.CollectionTypeBase
+	// templates are maintained by admins
+	form.ListRule = types.Pointer("@request.auth.id != ''")
+	form.ViewRule = types.Pointer("@request.auth.id != ''")
+	form.CreateRule = nil
+	form.UpdateRule = nil
+	form.DeleteRule = nil
+
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "name",
+		Type:     schema.FieldTypeText,
+		Required: true,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(200),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "description",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(1000),
+		},
+	})
+	// sources created from the template expire after this duration, e.g. 72h for preview environments
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "ttl",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(20),
+		},
+	})
+	for _, f := range []struct {
+		name string
+		max  int
+	}{
+		{"url", 200},
+		{"branch", 100},
+		{"path", 200},
+		{"namespace", 100},
+		{"region", 100},
+		{"dataCenter", 100},
+		{"defaultDataCenters", 100},
+		{"defaultNodePool", 100},
+		{"renderer", 100},
+	} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     f.name,
+			Type:     schema.FieldTypeText,
+			Required: false,
+			Options: &schema.TextOptions{
+				Max: types.Pointer(f.max),
+			},
+		})
+	}
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "deployKey",
+		Type:     schema.FieldTypeRelation,
+		Required: false,
+		Options: &schema.RelationOptions{
+			CollectionId: keysCollection.Id,
+			MaxSelect:    types.Pointer(1),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "vaultToken",
+		Type:     schema.FieldTypeRelation,
+		Required: false,
+		Options: &schema.RelationOptions{
+			CollectionId: vaultTokenCollection.Id,
+			MaxSelect:    types.Pointer(1),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "teams",
+		Type:     schema.FieldTypeRelation,
+		Required: false,
+		Options: &schema.RelationOptions{
+			CollectionId: teamsCollection.Id,
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "labels",
+		Type:     schema.FieldTypeJson,
+		Required: false,
+		Options:  &schema.JsonOptions{},
+	})
+	for _, name := range []string{"force", "strictParsing", "hcl1Fallback", "atomic", "skipPlanDiff", "snapshot", "notificationsMuted",
+		"disablePolling", "disableWebhooks", "disableNomadEvents"} {
+		addOrUpdateField(form, &schema.SchemaField{
+			Name:     name,
+			Type:     schema.FieldTypeBool,
+			Required: false,
+		})
+	}
+
+	// validate and submit (internally it calls app.Dao().SaveCollection(collection) in a transaction)
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
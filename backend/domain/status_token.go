@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// StatusToken grants read-only access to the status of sources, e.g. for status pages and dashboards
+type StatusToken struct {
+
+	// id
+	// Read Only: true
+	ID string `json:"id,omitempty"`
+
+	// name
+	// Required: true
+	Name string `json:"name"`
+
+	// the token only grants access to the sources of these teams, all sources if empty
+	TeamIDs []string `json:"teams,omitempty"`
+
+	// the token only grants access to the sources matching this label selector, all sources if empty
+	Selector string `json:"selector,omitempty"`
+
+	// the token is rejected after this time
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// time of the last request using the token
+	// Read Only: true
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// IsActive returns true if the token did not expire at the given time
+func (t *StatusToken) IsActive(now time.Time) bool {
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// Grants returns true if the token grants access to the source, sources never match an invalid selector
+func (t *StatusToken) Grants(src *Source) bool {
+	if t.Selector != "" {
+		sel, err := ParseLabelSelector(t.Selector)
+		if err != nil || !sel.Matches(src.Labels) {
+			return false
+		}
+	}
+	if len(t.TeamIDs) == 0 {
+		return true
+	}
+	for _, id := range t.TeamIDs {
+		for _, srcTeam := range src.TeamIDs {
+			if id == srcTeam {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func initStatusTokenCollection(app core.App, teamsCollection *models.Collection) (*models.Collection, error) {
+
+	collection, err := app.Dao().FindCollectionByNameOrId("status_tokens")
+
+	if err == sql.ErrNoRows {
+		collection = &models.Collection{}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(app, collection)
+	form.Name = "status_tokens"
+	form.Type = models.CollectionTypeBase
+	// tokens are issued by nomad-ops, admins revoke them by deleting the record
+	form.ListRule = nil
+	form.ViewRule = nil
+	form.CreateRule = nil
+	form.UpdateRule = nil
+	form.DeleteRule = nil
+	form.Indexes = types.JsonArray[string]{
+		"create unique index status_token_unique on status_tokens (tokenHash)",
+	}
+
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "name",
+		Type:     schema.FieldTypeText,
+		Required: true,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(200),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "tokenHash",
+		Type:     schema.FieldTypeText,
+		Required: true,
+		Options:  &schema.TextOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "teams",
+		Type:     schema.FieldTypeRelation,
+		Required: false,
+		Options: &schema.RelationOptions{
+			CollectionId:  teamsCollection.Id,
+			CascadeDelete: false,
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "selector",
+		Type:     schema.FieldTypeText,
+		Required: false,
+		Options: &schema.TextOptions{
+			Max: types.Pointer(500),
+		},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "expiresAt",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:     "lastUsedAt",
+		Type:     schema.FieldTypeDate,
+		Required: false,
+		Options:  &schema.DateOptions{},
+	})
+
+	// validate and submit (internally it calls app.Dao().SaveCollection(collection) in a transaction)
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+func StatusTokenFromRecord(record *models.Record) *StatusToken {
+	return &StatusToken{
+		ID:         record.Id,
+		Name:       record.GetString("name"),
+		TeamIDs:    record.GetStringSlice("teams"),
+		Selector:   record.GetString("selector"),
+		ExpiresAt:  timeToPtr(record.GetDateTime("expiresAt").Time()),
+		LastUsedAt: timeToPtr(record.GetDateTime("lastUsedAt").Time()),
+	}
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/pocketbase/pocketbase/tools/types"
 )
 
+// TeamOriginLDAP marks teams created by the LDAP group sync
+const TeamOriginLDAP = "ldap"
+
 type Team struct {
 
 	// id
@@ -23,6 +26,9 @@ type Team struct {
 
 	External bool `json:"external"`
 
+	// Origin is the directory that created an external team, its members are synced from it
+	Origin string `json:"origin,omitempty"`
+
 	MemberIDs []string `json:"members"`
 }
 
@@ -88,6 +94,11 @@ func initTeamCollection(app core.App, usersCollection *models.Collection) (*mode
 		Type:    schema.FieldTypeBool,
 		Options: &schema.BoolOptions{},
 	})
+	addOrUpdateField(form, &schema.SchemaField{
+		Name:    "origin",
+		Type:    schema.FieldTypeText,
+		Options: &schema.TextOptions{},
+	})
 	addOrUpdateField(form, &schema.SchemaField{
 		Name:     "members",
 		Type:     schema.FieldTypeRelation,
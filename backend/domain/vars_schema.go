@@ -0,0 +1,179 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VarsSchemaFile describes the variables of the sources of a repository, it is read from the path of the source
+// or the directory of the job file
+const VarsSchemaFile = "nomad-ops.vars.schema.json"
+
+// VarsSchema is a subset of JSON Schema describing the variables of a source. Variables are strings,
+// their type declares the values they must parse as.
+type VarsSchema struct {
+	Title       string                `json:"title,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Properties  map[string]*VarSchema `json:"properties"`
+	Required    []string              `json:"required,omitempty"`
+	// AdditionalProperties allows variables that are not declared, defaults to true
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+}
+
+// VarSchema describes a variable
+type VarSchema struct {
+	// Type is string, integer, number or boolean, defaults to string
+	Type        string `json:"type,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Default is used if the variable is not set, a string or the JSON value of its type, e.g. 2
+	Default json.RawMessage `json:"default,omitempty"`
+	Enum    []string        `json:"enum,omitempty"`
+	// Pattern is a regular expression the value must match
+	Pattern string   `json:"pattern,omitempty"`
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+	// Secret values are not shown by forms, e.g. passwords
+	Secret bool `json:"secret,omitempty"`
+
+	pattern *regexp.Regexp
+	// def is the default as value of a variable
+	def *string
+}
+
+// ParseVarsSchema parses and checks the schema
+func ParseVarsSchema(data []byte) (*VarsSchema, error) {
+	s := &VarsSchema{}
+	err := json.Unmarshal(data, s)
+	if err != nil {
+		return nil, WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid %s: %w", VarsSchemaFile, err))
+	}
+	for _, name := range s.Required {
+		if s.Properties[name] == nil {
+			return nil, WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid %s: required variable %s is not declared", VarsSchemaFile, name))
+		}
+	}
+	for name, v := range s.Properties {
+		if v == nil {
+			return nil, WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid %s: variable %s is empty", VarsSchemaFile, name))
+		}
+		switch v.Type {
+		case "":
+			v.Type = "string"
+		case "string", "integer", "number", "boolean":
+		default:
+			return nil, WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid %s: unknown type %s of variable %s", VarsSchemaFile, v.Type, name))
+		}
+		if v.Pattern != "" {
+			v.pattern, err = regexp.Compile(v.Pattern)
+			if err != nil {
+				return nil, WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid %s: pattern of variable %s: %w", VarsSchemaFile, name, err))
+			}
+		}
+		if len(v.Default) > 0 {
+			// string defaults are unquoted, other values are used as written, e.g. 2 or true
+			def := string(v.Default)
+			if v.Default[0] == '"' {
+				if err := json.Unmarshal(v.Default, &def); err != nil {
+					return nil, WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid %s: default of variable %s: %w", VarsSchemaFile, name, err))
+				}
+			}
+			v.def = &def
+			if err := v.validate(def); err != nil {
+				return nil, WithErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid %s: default of variable %s %w", VarsSchemaFile, name, err))
+			}
+		}
+	}
+	return s, nil
+}
+
+// Validate returns all variables that are missing, undeclared or do not match their schema, defaults count as set
+func (s *VarsSchema) Validate(vars map[string]string) error {
+	var errs []string
+	for _, name := range s.Required {
+		if _, ok := vars[name]; !ok && s.Properties[name].def == nil {
+			errs = append(errs, fmt.Sprintf("variable %s is required", name))
+		}
+	}
+	for name, value := range vars {
+		v, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				errs = append(errs, fmt.Sprintf("variable %s is not declared", name))
+			}
+			continue
+		}
+		if err := v.validate(value); err != nil {
+			errs = append(errs, fmt.Sprintf("variable %s %v", name, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Strings(errs)
+	return WithErrorCode(ErrorCodeSchemaViolation, fmt.Errorf("variables do not match %s: %s", VarsSchemaFile, strings.Join(errs, ", ")))
+}
+
+// WithDefaults returns the variables with the defaults of the unset ones
+func (s *VarsSchema) WithDefaults(vars map[string]string) map[string]string {
+	res := map[string]string{}
+	for name, v := range s.Properties {
+		if v.def != nil {
+			res[name] = *v.def
+		}
+	}
+	for k, v := range vars {
+		res[k] = v
+	}
+	return res
+}
+
+func (v *VarSchema) validate(value string) error {
+	var num float64
+	switch v.Type {
+	case "integer":
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		num = float64(i)
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		num = f
+	case "boolean":
+		if value != "true" && value != "false" {
+			return errors.New("must be true or false")
+		}
+	}
+	if v.Type == "integer" || v.Type == "number" {
+		if v.Minimum != nil && num < *v.Minimum {
+			return fmt.Errorf("must be at least %v", *v.Minimum)
+		}
+		if v.Maximum != nil && num > *v.Maximum {
+			return fmt.Errorf("must be at most %v", *v.Maximum)
+		}
+	}
+	if len(v.Enum) > 0 {
+		found := false
+		for _, e := range v.Enum {
+			if e == value {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("must be one of %s", strings.Join(v.Enum, ", "))
+		}
+	}
+	if v.pattern != nil && !v.pattern.MatchString(value) {
+		return fmt.Errorf("must match %s", v.Pattern)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVarsSchema(t *testing.T) {
+	s, err := ParseVarsSchema([]byte(`{
+	"properties": {
+		"env": {"enum": ["stage", "prod"]},
+		"replicas": {"type": "integer", "default": 2, "minimum": 1, "maximum": 10},
+		"ratio": {"type": "number"},
+		"canary": {"type": "boolean", "default": "false"},
+		"image": {"pattern": "^registry.example.com/"}
+	},
+	"required": ["env", "replicas"],
+	"additionalProperties": false
+}`))
+	if err != nil {
+		t.Fatalf("Could not ParseVarsSchema:%v", err)
+	}
+
+	if err := s.Validate(map[string]string{"env": "prod", "ratio": "0.5", "image": "registry.example.com/web:1"}); err != nil {
+		t.Errorf("Expected the variables to be valid:%v", err)
+	}
+	vars := s.WithDefaults(map[string]string{"env": "prod", "canary": "true"})
+	if vars["replicas"] != "2" || vars["canary"] != "true" || vars["env"] != "prod" || len(vars) != 3 {
+		t.Errorf("Unexpected variables with defaults %v", vars)
+	}
+
+	err = s.Validate(map[string]string{"replicas": "20", "ratio": "half", "canary": "yes", "image": "web", "debug": "true"})
+	if ErrorCodeOf(err, "") != ErrorCodeSchemaViolation {
+		t.Fatalf("Expected a schema violation, got %v", err)
+	}
+	for _, msg := range []string{
+		"variable env is required",
+		"variable replicas must be at most 10",
+		"variable ratio must be a number",
+		"variable canary must be true or false",
+		"variable image must match ^registry.example.com/",
+		"variable debug is not declared",
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected %q in %v", msg, err)
+		}
+	}
+	if err := s.Validate(map[string]string{"env": "dev", "replicas": "1"}); err == nil || !strings.Contains(err.Error(), "must be one of stage, prod") {
+		t.Errorf("Expected the enum to be checked, got %v", err)
+	}
+
+	for _, invalid := range []string{
+		`{"properties": {"a": {"type": "object"}}}`,
+		`{"properties": {"a": {"pattern": "("}}}`,
+		`{"properties": {"a": {"type": "integer", "default": "two"}}}`,
+		`{"properties": {}, "required": ["a"]}`,
+		`[]`,
+	} {
+		if _, err := ParseVarsSchema([]byte(invalid)); ErrorCodeOf(err, "") != ErrorCodeInvalidRequest {
+			t.Errorf("Expected %s to be invalid, got %v", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package annotationstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PocketBaseStore struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PocketBaseStoreConfig
+}
+
+type PocketBaseStoreConfig struct {
+	App core.App
+}
+
+func CreatePocketBaseStore(ctx context.Context,
+	logger log.Logger,
+	cfg PocketBaseStoreConfig) (*PocketBaseStore, error) {
+	t := &PocketBaseStore{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+func (s *PocketBaseStore) findRecord(repo, commit string) (*models.Record, error) {
+	return s.cfg.App.Dao().FindFirstRecordByFilter("commit_annotations", "repo = {:repo} && commit = {:commit}",
+		dbx.Params{"repo": repo, "commit": commit})
+}
+
+// SaveCommitAnnotation merges the annotation into the stored annotation of the commit and returns the result
+func (s *PocketBaseStore) SaveCommitAnnotation(ctx context.Context, a *domain.CommitAnnotation) (*domain.CommitAnnotation, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="commit_annotations",op="SaveCommitAnnotation"}`).UpdateDuration(time.Now())
+	merged := &domain.CommitAnnotation{
+		Repo:   a.Repo,
+		Commit: a.Commit,
+	}
+	record, err := s.findRecord(a.Repo, a.Commit)
+	if err == sql.ErrNoRows {
+		coll, err := s.cfg.App.Dao().FindCollectionByNameOrId("commit_annotations")
+		if err != nil {
+			return nil, err
+		}
+		record = models.NewRecord(coll)
+		record.Set("repo", a.Repo)
+		record.Set("commit", a.Commit)
+	} else if err != nil {
+		return nil, err
+	} else {
+		merged = domain.CommitAnnotationFromRecord(record)
+	}
+	merged.Merge(a)
+	record.Set("buildNumber", merged.BuildNumber)
+	record.Set("buildUrl", merged.BuildURL)
+	record.Set("artifacts", merged.Artifacts)
+	record.Set("tickets", merged.Tickets)
+	record.Set("meta", merged.Meta)
+	if err := s.cfg.App.Dao().SaveRecord(record); err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+	}
+	return domain.CommitAnnotationFromRecord(record), nil
+}
+
+func (s *PocketBaseStore) GetCommitAnnotation(ctx context.Context, repo, commit string) (*domain.CommitAnnotation, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="commit_annotations",op="GetCommitAnnotation"}`).UpdateDuration(time.Now())
+	record, err := s.findRecord(repo, commit)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return domain.CommitAnnotationFromRecord(record), nil
+}
@@ -0,0 +1,68 @@
+package auditstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PocketBaseStore struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PocketBaseStoreConfig
+}
+
+type PocketBaseStoreConfig struct {
+	App core.App
+}
+
+func CreatePocketBaseStore(ctx context.Context,
+	logger log.Logger,
+	cfg PocketBaseStoreConfig) (*PocketBaseStore, error) {
+	t := &PocketBaseStore{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+func (s *PocketBaseStore) SaveAuditEntry(ctx context.Context, e *domain.AuditEntry) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="audit",op="SaveAuditEntry"}`).UpdateDuration(time.Now())
+	collection, err := s.cfg.App.Dao().FindCollectionByNameOrId("audit_log")
+	if err != nil {
+		return err
+	}
+
+	errMsg := e.Error
+	if len(errMsg) > 2000 {
+		errMsg = errMsg[:2000]
+	}
+
+	record := models.NewRecord(collection)
+	record.Set("actionId", e.ActionID)
+	record.Set("actionType", string(e.ActionType))
+	record.Set("user", e.UserID)
+	record.Set("actor", e.Actor)
+	record.Set("source", e.SourceID)
+	record.Set("operation", string(e.Operation))
+	record.Set("job", e.Job)
+	record.Set("namespace", e.Namespace)
+	record.Set("region", e.Region)
+	record.Set("evalId", e.EvalID)
+	record.Set("error", errMsg)
+	record.Set("timestamp", e.Timestamp)
+
+	if err := s.cfg.App.Dao().SaveRecord(record); err != nil {
+		return err
+	}
+	e.ID = record.Id
+	return nil
+}
@@ -0,0 +1,242 @@
+package azuredevops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	giturls "github.com/whilp/git-urls"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+const (
+	apiVersion = "7.0"
+	// Azure DevOps limits the description of a status
+	maxDescription = 4000
+)
+
+// pullRequest is the part of a pull request event needed for the preview
+type pullRequest struct {
+	// org/project/repo
+	repo string
+	// apiURL is the API url of the repository
+	apiURL     string
+	id         int
+	baseBranch string
+	// ref fetched to preview the pull request
+	headRef string
+}
+
+// previewPullRequest reports the plan of every source affected by the pull request as pull request status
+func (a *AzureDevOps) previewPullRequest(ctx context.Context, pr pullRequest) {
+	a.logger.LogInfo(ctx, "Previewing pull request %d of %s", pr.id, pr.repo)
+	srcs, err := a.matchingSources(ctx, pr.repo, pr.baseBranch)
+	if err != nil {
+		a.logger.LogError(ctx, "Could not ListSources:%v", err)
+		return
+	}
+
+	var (
+		files     []string
+		iteration int
+	)
+	for _, src := range srcs {
+		auth, err := a.apiAuth(ctx, src, pr.apiURL)
+		if err != nil {
+			a.logger.LogTrace(ctx, "Not previewing source %s:%v", src.Name, err)
+			continue
+		}
+		if files == nil {
+			iteration, files, err = a.changedFiles(ctx, auth, pr)
+			if err != nil {
+				a.logger.LogError(ctx, "Could not list files of pull request %d of %s:%v", pr.id, pr.repo, err)
+				return
+			}
+		}
+		if !application.TouchesPath(files, src.Path) {
+			continue
+		}
+
+		state, description := "succeeded", ""
+		desiredState, err := a.git.FetchDesiredStateAt(ctx, src, pr.headRef)
+		if err == nil {
+			changes, perr := a.syncer.PreviewSource(ctx, src, desiredState)
+			if perr == nil {
+				description = fmt.Sprintf("%d to create, %d to update, %d to delete", len(changes.Create), len(changes.Update), len(changes.Delete))
+				if summary := changes.Summary(); summary != "" {
+					description += ": " + strings.ReplaceAll(summary, "\n", " ")
+				}
+			}
+			err = perr
+		}
+		if err != nil {
+			a.logger.LogError(ctx, "Could not preview source %s:%v", src.Name, err)
+			state, description = "failed", "Plan failed: "+err.Error()
+		}
+
+		err = a.setStatus(ctx, auth, pr, pullRequestStatus{
+			State:       state,
+			Description: truncate(description, maxDescription),
+			TargetURL:   a.cfg.StatusBaseURL + src.ID,
+			IterationID: iteration,
+			Context: statusContext{
+				Genre: a.cfg.StatusGenre,
+				Name:  src.Name,
+			},
+		})
+		if err != nil {
+			a.logger.LogError(ctx, "Could not set pull request status for source %s:%v", src.Name, err)
+		}
+	}
+}
+
+// apiAuth returns the credentials of the source for the API. They are only sent to the host of the source,
+// personal access tokens are token keys and OAuth tokens azure identity keys.
+func (a *AzureDevOps) apiAuth(ctx context.Context, src *domain.Source, apiURL string) (githttp.AuthMethod, error) {
+	if src.DeployKeyID == "" {
+		return nil, fmt.Errorf("source has no key")
+	}
+	if !sameHost(src.URL, apiURL) {
+		return nil, fmt.Errorf("api %s is not hosted by %s", apiURL, src.URL)
+	}
+	key, err := a.keyRepo.GetKey(ctx, src.DeployKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return a.git.HTTPAuth(ctx, key)
+}
+
+// sameHost checks if the API url belongs to the host of the clone url, ssh urls of Azure DevOps use their own host
+func sameHost(cloneURL, apiURL string) bool {
+	c, err := giturls.Parse(cloneURL)
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(c.Hostname())
+	if host == "ssh.dev.azure.com" {
+		host = "dev.azure.com"
+	}
+	return host == strings.ToLower(u.Hostname())
+}
+
+func (a *AzureDevOps) request(ctx context.Context, auth githttp.AuthMethod, method, rawURL string, body any, res any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	auth.SetAuth(req)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if res == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, res)
+}
+
+// changedFiles lists the files changed by the latest iteration of the pull request
+func (a *AzureDevOps) changedFiles(ctx context.Context, auth githttp.AuthMethod, pr pullRequest) (int, []string, error) {
+	base := fmt.Sprintf("%s/pullRequests/%d", strings.TrimSuffix(pr.apiURL, "/"), pr.id)
+	var iterations struct {
+		Value []struct {
+			ID int `json:"id"`
+		} `json:"value"`
+	}
+	err := a.request(ctx, auth, http.MethodGet, fmt.Sprintf("%s/iterations?api-version=%s", base, apiVersion), nil, &iterations)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(iterations.Value) == 0 {
+		return 0, nil, fmt.Errorf("pull request has no iterations")
+	}
+	iteration := iterations.Value[len(iterations.Value)-1].ID
+
+	files := []string{}
+	skip := 0
+	for {
+		var res struct {
+			ChangeEntries []struct {
+				Item struct {
+					Path string `json:"path"`
+				} `json:"item"`
+				OriginalPath string `json:"originalPath"`
+			} `json:"changeEntries"`
+			NextSkip int `json:"nextSkip"`
+		}
+		err := a.request(ctx, auth, http.MethodGet,
+			fmt.Sprintf("%s/iterations/%d/changes?api-version=%s&$top=2000&$skip=%d", base, iteration, apiVersion, skip), nil, &res)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, c := range res.ChangeEntries {
+			// paths are absolute
+			files = append(files, strings.TrimPrefix(c.Item.Path, "/"))
+			if c.OriginalPath != "" {
+				files = append(files, strings.TrimPrefix(c.OriginalPath, "/"))
+			}
+		}
+		if res.NextSkip == 0 || len(res.ChangeEntries) == 0 {
+			return iteration, files, nil
+		}
+		skip = res.NextSkip
+	}
+}
+
+type statusContext struct {
+	Genre string `json:"genre"`
+	Name  string `json:"name"`
+}
+
+type pullRequestStatus struct {
+	State       string        `json:"state"`
+	Description string        `json:"description"`
+	TargetURL   string        `json:"targetUrl,omitempty"`
+	IterationID int           `json:"iterationId,omitempty"`
+	Context     statusContext `json:"context"`
+}
+
+func (a *AzureDevOps) setStatus(ctx context.Context, auth githttp.AuthMethod, pr pullRequest, status pullRequestStatus) error {
+	return a.request(ctx, auth, http.MethodPost,
+		fmt.Sprintf("%s/pullRequests/%d/statuses?api-version=%s", strings.TrimSuffix(pr.apiURL, "/"), pr.id, apiVersion), status, nil)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max-3], "") + "..."
+}
@@ -0,0 +1,191 @@
+package azuredevops
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/github"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid webhook credentials")
+)
+
+// SourceSyncer is triggered by push events and previews pull requests
+type SourceSyncer interface {
+	SyncSourceByID(ctx context.Context, id string, opts application.SyncSourceOptions) error
+	PreviewSource(ctx context.Context, src *domain.Source, desiredState *application.DesiredState) (*application.ChangeInfo, error)
+}
+
+// AzureDevOps receives the service hooks of Azure DevOps and reports the plan of pull requests as pull request status
+type AzureDevOps struct {
+	ctx     context.Context
+	logger  log.Logger
+	cfg     Config
+	git     *github.GitProvider
+	sources application.SourceRepo
+	keyRepo application.KeyRepo
+	syncer  SourceSyncer
+	client  *http.Client
+}
+
+type Config struct {
+	// WebhookSecret is the password of the basic authentication of the service hooks
+	WebhookSecret string
+	// StatusGenre groups the statuses, the name of the source is used as status name
+	StatusGenre string
+	// StatusBaseURL is linked from the status, the id of the source is appended
+	StatusBaseURL string
+	// Transport is used for the API, defaults to http.DefaultTransport
+	Transport *http.Transport
+}
+
+func CreateAzureDevOps(ctx context.Context,
+	logger log.Logger,
+	cfg Config,
+	git *github.GitProvider,
+	sources application.SourceRepo,
+	keyRepo application.KeyRepo,
+	syncer SourceSyncer) (*AzureDevOps, error) {
+
+	if cfg.WebhookSecret == "" {
+		return nil, fmt.Errorf("a webhook secret is required")
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport.(*http.Transport)
+	}
+	if cfg.StatusGenre == "" {
+		cfg.StatusGenre = "nomad-ops"
+	}
+	return &AzureDevOps{
+		ctx:     ctx,
+		logger:  logger,
+		cfg:     cfg,
+		git:     git,
+		sources: sources,
+		keyRepo: keyRepo,
+		syncer:  syncer,
+		client: &http.Client{
+			Timeout:   time.Second * 30,
+			Transport: cfg.Transport,
+		},
+	}, nil
+}
+
+type repository struct {
+	// URL is the API url of the repository
+	URL       string `json:"url"`
+	RemoteURL string `json:"remoteUrl"`
+}
+
+type pushEvent struct {
+	Resource struct {
+		RefUpdates []struct {
+			Name        string `json:"name"`
+			NewObjectID string `json:"newObjectId"`
+		} `json:"refUpdates"`
+		Repository repository `json:"repository"`
+	} `json:"resource"`
+}
+
+type pullRequestEvent struct {
+	Resource struct {
+		PullRequestID         int    `json:"pullRequestId"`
+		Status                string `json:"status"`
+		SourceRefName         string `json:"sourceRefName"`
+		TargetRefName         string `json:"targetRefName"`
+		LastMergeSourceCommit struct {
+			CommitID string `json:"commitId"`
+		} `json:"lastMergeSourceCommit"`
+		Repository repository `json:"repository"`
+	} `json:"resource"`
+}
+
+// deleted refs are updated to the zero commit
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+// HandleWebhook verifies and processes a service hook. Pull requests are previewed in the background.
+func (a *AzureDevOps) HandleWebhook(ctx context.Context, password string, body []byte) error {
+	if subtle.ConstantTimeCompare([]byte(password), []byte(a.cfg.WebhookSecret)) != 1 {
+		return ErrInvalidCredentials
+	}
+
+	var ev struct {
+		EventType string `json:"eventType"`
+	}
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+	}
+
+	switch ev.EventType {
+	case "git.push":
+		ev := pushEvent{}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		repo := application.RepoName(ev.Resource.Repository.RemoteURL)
+		for _, u := range ev.Resource.RefUpdates {
+			if strings.HasPrefix(u.Name, "refs/heads/") && u.NewObjectID != zeroCommit {
+				a.syncBranch(ctx, repo, strings.TrimPrefix(u.Name, "refs/heads/"))
+			}
+		}
+	case "git.pullrequest.created", "git.pullrequest.updated":
+		ev := pullRequestEvent{}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		if ev.Resource.Status != "active" {
+			return nil
+		}
+		// Azure DevOps only has merge refs of pull requests, the source branch is previewed
+		go a.previewPullRequest(a.ctx, pullRequest{
+			repo:       application.RepoName(ev.Resource.Repository.RemoteURL),
+			apiURL:     ev.Resource.Repository.URL,
+			id:         ev.Resource.PullRequestID,
+			baseBranch: strings.TrimPrefix(ev.Resource.TargetRefName, "refs/heads/"),
+			headRef:    ev.Resource.SourceRefName,
+		})
+	}
+	return nil
+}
+
+// matchingSources returns the sources watching the branch of the repository
+func (a *AzureDevOps) matchingSources(ctx context.Context, repo, branch string) ([]*domain.Source, error) {
+	srcs, err := a.sources.ListSources(ctx, application.ListSourcesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var res []*domain.Source
+	for _, src := range srcs {
+		if src.Branch == branch && strings.EqualFold(application.RepoName(src.URL), repo) {
+			res = append(res, src)
+		}
+	}
+	return res, nil
+}
+
+func (a *AzureDevOps) syncBranch(ctx context.Context, repo, branch string) {
+	a.logger.LogInfo(ctx, "Received push to %s on %s", repo, branch)
+	srcs, err := a.matchingSources(ctx, repo, branch)
+	if err != nil {
+		a.logger.LogError(ctx, "Could not ListSources:%v", err)
+		return
+	}
+	for _, src := range srcs {
+		err := a.syncer.SyncSourceByID(ctx, src.ID, application.SyncSourceOptions{
+			Trigger: domain.SyncTriggerWebhook,
+		})
+		if err != nil {
+			a.logger.LogError(ctx, "Could not sync source %s:%v", src.Name, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package azuredevops
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeSources []*domain.Source
+
+func (s fakeSources) ListSources(ctx context.Context, opts application.ListSourcesOptions) ([]*domain.Source, error) {
+	return s, nil
+}
+
+type recordingSyncer struct {
+	synced []string
+}
+
+func (s *recordingSyncer) SyncSourceByID(ctx context.Context, id string, opts application.SyncSourceOptions) error {
+	s.synced = append(s.synced, id)
+	return nil
+}
+
+func (s *recordingSyncer) PreviewSource(ctx context.Context, src *domain.Source, desiredState *application.DesiredState) (*application.ChangeInfo, error) {
+	return &application.ChangeInfo{}, nil
+}
+
+func TestHandleWebhookPush(t *testing.T) {
+	syncer := &recordingSyncer{}
+	a, err := CreateAzureDevOps(context.Background(), log.NewSimpleLogger(false, "Test"),
+		Config{WebhookSecret: "secret"}, nil, fakeSources{
+			{ID: "https", URL: "https://dev.azure.com/org/project/_git/repo", Branch: "main"},
+			{ID: "ssh", URL: "git@ssh.dev.azure.com:v3/org/project/repo", Branch: "main"},
+			{ID: "other-branch", URL: "https://dev.azure.com/org/project/_git/repo", Branch: "dev"},
+		}, nil, syncer)
+	if err != nil {
+		t.Fatalf("Could not CreateAzureDevOps:%v", err)
+	}
+
+	body := []byte(`{"eventType":"git.push","resource":{"refUpdates":[{"name":"refs/heads/main","newObjectId":"abc"},{"name":"refs/heads/dev","newObjectId":"0000000000000000000000000000000000000000"}],"repository":{"remoteUrl":"https://org@dev.azure.com/org/project/_git/repo"}}}`)
+	if err := a.HandleWebhook(context.Background(), "other", body); err != ErrInvalidCredentials {
+		t.Fatalf("Expected invalid credentials, got %v", err)
+	}
+	if err := a.HandleWebhook(context.Background(), "secret", body); err != nil {
+		t.Fatalf("Could not HandleWebhook:%v", err)
+	}
+	if len(syncer.synced) != 2 || syncer.synced[0] != "https" || syncer.synced[1] != "ssh" {
+		t.Errorf("Unexpected synced sources %v", syncer.synced)
+	}
+}
+
+func TestPullRequestStatus(t *testing.T) {
+	var status pullRequestStatus
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, p, ok := r.BasicAuth(); !ok || p != "pat" {
+			t.Errorf("Unexpected auth %s", p)
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repo/pullRequests/3/iterations":
+			_, _ = w.Write([]byte(`{"value":[{"id":1},{"id":2}]}`))
+		case "GET /repo/pullRequests/3/iterations/2/changes":
+			_, _ = w.Write([]byte(`{"changeEntries":[{"item":{"path":"/jobs/web.nomad"},"originalPath":"/old/web.nomad"}]}`))
+		case "POST /repo/pullRequests/3/statuses":
+			_ = json.NewDecoder(r.Body).Decode(&status)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	a := &AzureDevOps{client: srv.Client()}
+	auth := &githttp.BasicAuth{Username: "nomad-ops", Password: "pat"}
+	pr := pullRequest{apiURL: srv.URL + "/repo", id: 3}
+
+	iteration, files, err := a.changedFiles(context.Background(), auth, pr)
+	if err != nil {
+		t.Fatalf("Could not list changed files:%v", err)
+	}
+	if iteration != 2 || len(files) != 2 || files[0] != "jobs/web.nomad" {
+		t.Errorf("Unexpected iteration %d or files %v", iteration, files)
+	}
+
+	err = a.setStatus(context.Background(), auth, pr, pullRequestStatus{State: "succeeded", IterationID: iteration})
+	if err != nil {
+		t.Fatalf("Could not set status:%v", err)
+	}
+	if status.State != "succeeded" || status.IterationID != 2 {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
+
+func TestSameHost(t *testing.T) {
+	for _, c := range []struct {
+		clone, api string
+		expected   bool
+	}{
+		{"git@ssh.dev.azure.com:v3/org/project/repo", "https://dev.azure.com/org/id/_apis/git/repositories/id", true},
+		{"https://dev.azure.com/org/project/_git/repo", "https://dev.azure.com/org/id/_apis/git/repositories/id", true},
+		{"https://dev.azure.com/org/project/_git/repo", "https://evil.example.com/_apis", false},
+		{"https://dev.azure.com/org/project/_git/repo", "http://dev.azure.com/_apis", false},
+	} {
+		if sameHost(c.clone, c.api) != c.expected {
+			t.Errorf("Expected %v for %s and %s", c.expected, c.clone, c.api)
+		}
+	}
+}
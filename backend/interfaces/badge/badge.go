@@ -0,0 +1,77 @@
+package badge
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"unicode/utf8"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+const (
+	StatusSynced    = "synced"
+	StatusOutOfSync = "out-of-sync"
+	StatusFailing   = "failing"
+	StatusUnknown   = "unknown"
+)
+
+// Badge is the public status of a source, it reveals nothing but the status and the deployed commit
+type Badge struct {
+	Status string `json:"status"`
+	// commit all jobs were applied at, empty if the jobs were applied at different commits
+	Commit string `json:"commit,omitempty"`
+}
+
+func FromSource(src *domain.Source) Badge {
+	b := Badge{Status: StatusUnknown}
+	if src.Status == nil {
+		return b
+	}
+	switch src.Status.Status {
+	case domain.SourceStatusStatusSynced:
+		b.Status = StatusSynced
+	case domain.SourceStatusStatusOutOfSync, domain.SourceStatusStatusSyncing, domain.SourceStatusStatusInit:
+		b.Status = StatusOutOfSync
+	case domain.SourceStatusStatusError, domain.SourceStatusStatusSyncedWithError:
+		b.Status = StatusFailing
+	}
+	b.Commit = src.Status.AppliedCommit()
+	return b
+}
+
+func (b Badge) color() string {
+	switch b.Status {
+	case StatusSynced:
+		return "#4c1"
+	case StatusOutOfSync:
+		return "#dfb317"
+	case StatusFailing:
+		return "#e05d44"
+	}
+	return "#9f9f9f"
+}
+
+// SVG renders the badge in the style of shields.io, the message contains the short commit if known
+func (b Badge) SVG(label string) []byte {
+	msg := b.Status
+	if len(b.Commit) >= 7 {
+		msg = fmt.Sprintf("%s @ %s", b.Status, b.Commit[:7])
+	}
+	// the width is estimated, badges use a fixed font
+	labelWidth := 10 + 7*utf8.RuneCountInString(label)
+	msgWidth := 10 + 7*utf8.RuneCountInString(msg)
+	width := labelWidth + msgWidth
+	label, msg = html.EscapeString(label), html.EscapeString(msg)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, width, label, msg)
+	fmt.Fprintf(&buf, `<title>%s: %s</title>`, label, msg)
+	fmt.Fprintf(&buf, `<rect width="%d" height="20" fill="#555"/>`, labelWidth)
+	fmt.Fprintf(&buf, `<rect x="%d" width="%d" height="20" fill="%s"/>`, labelWidth, msgWidth, b.color())
+	fmt.Fprintf(&buf, `<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&buf, `<text x="%d" y="14">%s</text>`, labelWidth/2, label)
+	fmt.Fprintf(&buf, `<text x="%d" y="14">%s</text>`, labelWidth+msgWidth/2, msg)
+	buf.WriteString(`</g></svg>`)
+	return buf.Bytes()
+}
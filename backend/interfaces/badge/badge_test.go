@@ -0,0 +1,61 @@
+package badge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestFromSource(t *testing.T) {
+	src := &domain.Source{
+		Status: &domain.SourceStatus{
+			Status: domain.SourceStatusStatusSyncedWithError,
+			Jobs: map[string]domain.JobStatus{
+				"web": {LastAppliedCommit: "0123456789abcdef"},
+				"api": {LastAppliedCommit: "0123456789abcdef"},
+			},
+		},
+	}
+	b := FromSource(src)
+	if b.Status != StatusFailing || b.Commit != "0123456789abcdef" {
+		t.Errorf("Expected a failing badge at the commit, got %+v", b)
+	}
+	svg := string(b.SVG("<billing>"))
+	if !strings.Contains(svg, "failing @ 0123456") || !strings.Contains(svg, "&lt;billing&gt;") {
+		t.Errorf("Expected the escaped label, status and short commit, got %s", svg)
+	}
+
+	src.Status.Jobs["db"] = domain.JobStatus{LastAppliedCommit: "fedcba"}
+	if b := FromSource(src); b.Commit != "" {
+		t.Errorf("Expected no commit for jobs at different commits, got %s", b.Commit)
+	}
+	if b := FromSource(&domain.Source{}); b.Status != StatusUnknown {
+		t.Errorf("Expected a source without status to be unknown, got %s", b.Status)
+	}
+}
+
+func TestStatusFromSource(t *testing.T) {
+	src := &domain.Source{
+		ID:   "src",
+		Name: "billing",
+		Status: &domain.SourceStatus{
+			Status: domain.SourceStatusStatusSynced,
+			Jobs: map[string]domain.JobStatus{
+				"web":     {Type: "service", Status: "running", DeploymentStatus: "running", LastError: "secret"},
+				"cleanup": {Type: "batch", Status: "dead"},
+			},
+		},
+	}
+	if s := StatusFromSource(src); s.Health != HealthDeploying || s.Status != StatusSynced || len(s.Jobs) != 2 {
+		t.Errorf("Expected a deploying source, got %+v", s)
+	}
+
+	src.Status.Jobs["api"] = domain.JobStatus{Type: "service", Status: "dead"}
+	if s := StatusFromSource(src); s.Health != HealthDegraded {
+		t.Errorf("Expected a stopped service to degrade the source, got %s", s.Health)
+	}
+	if s := StatusFromSource(&domain.Source{}); s.Health != HealthUnknown || s.Jobs != nil {
+		t.Errorf("Expected a source without status to be unknown, got %+v", s)
+	}
+}
@@ -0,0 +1,66 @@
+package badge
+
+import (
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+const (
+	HealthHealthy   = "healthy"
+	HealthDeploying = "deploying"
+	HealthDegraded  = "degraded"
+	HealthUnknown   = "unknown"
+)
+
+// SourceStatus is the read-only status of a source for status pages and dashboards.
+// Like badges it reveals no diffs, errors or settings of the source.
+type SourceStatus struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Badge
+	// Health summarizes the jobs of the source
+	Health string `json:"health"`
+	// LastDeploy is the time jobs of the source were last updated
+	LastDeploy *time.Time `json:"lastDeploy,omitempty"`
+	// LastCheck is the time the source was last synced
+	LastCheck *time.Time           `json:"lastCheck,omitempty"`
+	Jobs      map[string]JobHealth `json:"jobs,omitempty"`
+}
+
+type JobHealth struct {
+	Status           string `json:"status,omitempty"`
+	DeploymentStatus string `json:"deploymentStatus,omitempty"`
+}
+
+func StatusFromSource(src *domain.Source) SourceStatus {
+	s := SourceStatus{
+		ID:     src.ID,
+		Name:   src.Name,
+		Labels: src.Labels,
+		Badge:  FromSource(src),
+		Health: HealthUnknown,
+	}
+	if src.Status == nil || len(src.Status.Jobs) == 0 {
+		return s
+	}
+	s.LastDeploy = src.Status.LastUpdateTime
+	s.LastCheck = src.Status.LastCheckTime
+	s.Jobs = map[string]JobHealth{}
+	s.Health = HealthHealthy
+	for name, job := range src.Status.Jobs {
+		s.Jobs[name] = JobHealth{
+			Status:           job.Status,
+			DeploymentStatus: job.DeploymentStatus,
+		}
+		switch {
+		// finished batch jobs are dead as well
+		case job.DeploymentStatus == "failed" || (job.Status == "dead" && job.Type == "service"):
+			s.Health = HealthDegraded
+		case job.DeploymentStatus == "running" && s.Health == HealthHealthy:
+			s.Health = HealthDeploying
+		}
+	}
+	return s
+}
@@ -0,0 +1,229 @@
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// Bitbucket limits the description of a build status
+const maxDescription = 255
+
+// previewPullRequest reports the plan of every source affected by the pull request as build status
+func (b *Bitbucket) previewPullRequest(ctx context.Context, pr pullRequest) {
+	if pr.server && b.cfg.ServerURL == "" {
+		b.logger.LogError(ctx, "Not previewing pull request %d of %s, the url of Bitbucket Data Center is not configured", pr.id, pr.repo)
+		return
+	}
+	b.logger.LogInfo(ctx, "Previewing pull request %d of %s", pr.id, pr.repo)
+	srcs, err := b.matchingSources(ctx, pr.repo, pr.baseBranch)
+	if err != nil {
+		b.logger.LogError(ctx, "Could not ListSources:%v", err)
+		return
+	}
+
+	var files []string
+	for _, src := range srcs {
+		key, err := b.tokenKey(ctx, src)
+		if err != nil {
+			b.logger.LogTrace(ctx, "Not previewing source %s:%v", src.Name, err)
+			continue
+		}
+		if files == nil {
+			files, err = b.changedFiles(ctx, key, pr)
+			if err != nil {
+				b.logger.LogError(ctx, "Could not list files of pull request %d of %s:%v", pr.id, pr.repo, err)
+				return
+			}
+		}
+		if !application.TouchesPath(files, src.Path) {
+			continue
+		}
+
+		state, description := "SUCCESSFUL", ""
+		desiredState, err := b.git.FetchDesiredStateAt(ctx, src, pr.headRef)
+		if err == nil {
+			changes, perr := b.syncer.PreviewSource(ctx, src, desiredState)
+			if perr == nil {
+				description = fmt.Sprintf("%d to create, %d to update, %d to delete", len(changes.Create), len(changes.Update), len(changes.Delete))
+				if summary := changes.Summary(); summary != "" {
+					description += ": " + strings.ReplaceAll(summary, "\n", " ")
+				}
+			}
+			err = perr
+		}
+		if err != nil {
+			b.logger.LogError(ctx, "Could not preview source %s:%v", src.Name, err)
+			state, description = "FAILED", "Plan failed: "+err.Error()
+		}
+
+		err = b.setBuildStatus(ctx, key, pr, buildStatus{
+			// keys are limited to 40 characters
+			Key:         b.cfg.StatusKey + "-" + src.ID,
+			State:       state,
+			Name:        fmt.Sprintf("%s: %s", b.cfg.StatusKey, src.Name),
+			URL:         b.cfg.StatusBaseURL + src.ID,
+			Description: truncate(description, maxDescription),
+		})
+		if err != nil {
+			b.logger.LogError(ctx, "Could not set build status for source %s:%v", src.Name, err)
+		}
+	}
+}
+
+// tokenKey returns the token key of the source, which is used for the API as well
+func (b *Bitbucket) tokenKey(ctx context.Context, src *domain.Source) (*domain.DeployKey, error) {
+	if src.DeployKeyID == "" {
+		return nil, fmt.Errorf("source has no key")
+	}
+	key, err := b.keyRepo.GetKey(ctx, src.DeployKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Type != domain.DeployKeyTypeToken {
+		return nil, fmt.Errorf("key %s is no token key", key.Name)
+	}
+	return key, nil
+}
+
+func (b *Bitbucket) request(ctx context.Context, key *domain.DeployKey, method, rawURL string, body any, res any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	// app passwords require the user, access tokens do not
+	if key.Username != "" {
+		req.SetBasicAuth(key.Username, key.Value)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+key.Value)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if res == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, res)
+}
+
+// changedFiles lists the files changed by the pull request
+func (b *Bitbucket) changedFiles(ctx context.Context, key *domain.DeployKey, pr pullRequest) ([]string, error) {
+	files := []string{}
+	if pr.server {
+		project, slug, _ := strings.Cut(pr.repo, "/")
+		start := 0
+		for {
+			var res struct {
+				Values []struct {
+					Path struct {
+						ToString string `json:"toString"`
+					} `json:"path"`
+					SrcPath *struct {
+						ToString string `json:"toString"`
+					} `json:"srcPath"`
+				} `json:"values"`
+				IsLastPage    bool `json:"isLastPage"`
+				NextPageStart int  `json:"nextPageStart"`
+			}
+			err := b.request(ctx, key, http.MethodGet,
+				fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/pull-requests/%d/changes?limit=1000&start=%d",
+					strings.TrimSuffix(b.cfg.ServerURL, "/"), url.PathEscape(project), url.PathEscape(slug), pr.id, start), nil, &res)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range res.Values {
+				files = append(files, v.Path.ToString)
+				if v.SrcPath != nil && v.SrcPath.ToString != "" {
+					files = append(files, v.SrcPath.ToString)
+				}
+			}
+			if res.IsLastPage || len(res.Values) == 0 {
+				return files, nil
+			}
+			start = res.NextPageStart
+		}
+	}
+
+	next := fmt.Sprintf("%s/repositories/%s/pullrequests/%d/diffstat?pagelen=500", strings.TrimSuffix(b.cfg.CloudAPIURL, "/"), pr.repo, pr.id)
+	for next != "" {
+		var res struct {
+			Values []struct {
+				Old *struct {
+					Path string `json:"path"`
+				} `json:"old"`
+				New *struct {
+					Path string `json:"path"`
+				} `json:"new"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		err := b.request(ctx, key, http.MethodGet, next, nil, &res)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.Values {
+			if v.New != nil {
+				files = append(files, v.New.Path)
+			}
+			if v.Old != nil {
+				files = append(files, v.Old.Path)
+			}
+		}
+		next = res.Next
+	}
+	return files, nil
+}
+
+type buildStatus struct {
+	Key         string `json:"key"`
+	State       string `json:"state"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+func (b *Bitbucket) setBuildStatus(ctx context.Context, key *domain.DeployKey, pr pullRequest, status buildStatus) error {
+	if pr.server {
+		return b.request(ctx, key, http.MethodPost,
+			fmt.Sprintf("%s/rest/build-status/1.0/commits/%s", strings.TrimSuffix(b.cfg.ServerURL, "/"), url.PathEscape(pr.headCommit)), status, nil)
+	}
+	return b.request(ctx, key, http.MethodPost,
+		fmt.Sprintf("%s/repositories/%s/commit/%s/statuses/build", strings.TrimSuffix(b.cfg.CloudAPIURL, "/"), pr.repo, url.PathEscape(pr.headCommit)), status, nil)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max-3], "") + "..."
+}
@@ -0,0 +1,278 @@
+package bitbucket
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/github"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// SourceSyncer is triggered by push events and previews pull requests
+type SourceSyncer interface {
+	SyncSourceByID(ctx context.Context, id string, opts application.SyncSourceOptions) error
+	PreviewSource(ctx context.Context, src *domain.Source, desiredState *application.DesiredState) (*application.ChangeInfo, error)
+}
+
+// Bitbucket receives the webhooks of Bitbucket Cloud and Bitbucket Data Center and reports
+// the plan of pull requests as build status of their head commit
+type Bitbucket struct {
+	ctx     context.Context
+	logger  log.Logger
+	cfg     Config
+	git     *github.GitProvider
+	sources application.SourceRepo
+	keyRepo application.KeyRepo
+	syncer  SourceSyncer
+	client  *http.Client
+}
+
+type Config struct {
+	WebhookSecret string
+	// CloudAPIURL is the API of Bitbucket Cloud
+	CloudAPIURL string
+	// ServerURL is the base url of Bitbucket Data Center, required for its pull requests
+	ServerURL string
+	// StatusKey identifies the build status, the name of the source is appended
+	StatusKey string
+	// StatusBaseURL is linked from the build status, the id of the source is appended
+	StatusBaseURL string
+	// Transport is used for the API, defaults to http.DefaultTransport
+	Transport *http.Transport
+}
+
+func CreateBitbucket(ctx context.Context,
+	logger log.Logger,
+	cfg Config,
+	git *github.GitProvider,
+	sources application.SourceRepo,
+	keyRepo application.KeyRepo,
+	syncer SourceSyncer) (*Bitbucket, error) {
+
+	if cfg.WebhookSecret == "" {
+		return nil, fmt.Errorf("a webhook secret is required")
+	}
+	if cfg.CloudAPIURL == "" {
+		cfg.CloudAPIURL = "https://api.bitbucket.org/2.0"
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport.(*http.Transport)
+	}
+	if cfg.StatusKey == "" {
+		cfg.StatusKey = "nomad-ops"
+	}
+	return &Bitbucket{
+		ctx:     ctx,
+		logger:  logger,
+		cfg:     cfg,
+		git:     git,
+		sources: sources,
+		keyRepo: keyRepo,
+		syncer:  syncer,
+		client: &http.Client{
+			Timeout:   time.Second * 30,
+			Transport: cfg.Transport,
+		},
+	}, nil
+}
+
+// pullRequest is the flavour independent part of a pull request event
+type pullRequest struct {
+	server bool
+	// workspace/repo on Cloud, PROJECT/repo on Data Center
+	repo       string
+	id         int
+	baseBranch string
+	headCommit string
+	// ref fetched to preview the pull request
+	headRef string
+}
+
+type cloudRepository struct {
+	FullName string `json:"full_name"`
+}
+
+type cloudPushEvent struct {
+	Push struct {
+		Changes []struct {
+			New *struct {
+				Type string `json:"type"`
+				Name string `json:"name"`
+			} `json:"new"`
+		} `json:"changes"`
+	} `json:"push"`
+	Repository cloudRepository `json:"repository"`
+}
+
+type cloudPullRequestEvent struct {
+	PullRequest struct {
+		ID     int `json:"id"`
+		Source struct {
+			Branch struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+			Commit struct {
+				Hash string `json:"hash"`
+			} `json:"commit"`
+		} `json:"source"`
+		Destination struct {
+			Branch struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+		} `json:"destination"`
+	} `json:"pullrequest"`
+	Repository cloudRepository `json:"repository"`
+}
+
+type serverRepository struct {
+	Slug    string `json:"slug"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+}
+
+func (r serverRepository) fullName() string {
+	return r.Project.Key + "/" + r.Slug
+}
+
+type serverRefsChangedEvent struct {
+	Changes []struct {
+		Ref struct {
+			DisplayID string `json:"displayId"`
+			Type      string `json:"type"`
+		} `json:"ref"`
+		Type string `json:"type"`
+	} `json:"changes"`
+	Repository serverRepository `json:"repository"`
+}
+
+type serverPullRequestEvent struct {
+	PullRequest struct {
+		ID      int `json:"id"`
+		FromRef struct {
+			LatestCommit string `json:"latestCommit"`
+		} `json:"fromRef"`
+		ToRef struct {
+			DisplayID  string           `json:"displayId"`
+			Repository serverRepository `json:"repository"`
+		} `json:"toRef"`
+	} `json:"pullRequest"`
+}
+
+// HandleWebhook verifies and processes a webhook delivery of Bitbucket Cloud or Data Center,
+// the flavour is determined by the event key. Pull requests are previewed in the background.
+func (b *Bitbucket) HandleWebhook(ctx context.Context, eventKey, signature string, body []byte) error {
+	if !validSignature(b.cfg.WebhookSecret, signature, body) {
+		return ErrInvalidSignature
+	}
+
+	switch eventKey {
+	case "repo:push":
+		ev := cloudPushEvent{}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		for _, c := range ev.Push.Changes {
+			// deleted branches have no new state
+			if c.New != nil && c.New.Type == "branch" {
+				b.syncBranch(ctx, ev.Repository.FullName, c.New.Name)
+			}
+		}
+	case "repo:refs_changed":
+		ev := serverRefsChangedEvent{}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		for _, c := range ev.Changes {
+			if c.Ref.Type == "BRANCH" && c.Type != "DELETE" {
+				b.syncBranch(ctx, ev.Repository.fullName(), c.Ref.DisplayID)
+			}
+		}
+	case "pullrequest:created", "pullrequest:updated":
+		ev := cloudPullRequestEvent{}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		// Bitbucket Cloud has no refs of pull requests, pull requests of forks can not be fetched
+		go b.previewPullRequest(b.ctx, pullRequest{
+			repo:       ev.Repository.FullName,
+			id:         ev.PullRequest.ID,
+			baseBranch: ev.PullRequest.Destination.Branch.Name,
+			headCommit: ev.PullRequest.Source.Commit.Hash,
+			headRef:    "refs/heads/" + ev.PullRequest.Source.Branch.Name,
+		})
+	case "pr:opened", "pr:from_ref_updated":
+		ev := serverPullRequestEvent{}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		go b.previewPullRequest(b.ctx, pullRequest{
+			server:     true,
+			repo:       ev.PullRequest.ToRef.Repository.fullName(),
+			id:         ev.PullRequest.ID,
+			baseBranch: ev.PullRequest.ToRef.DisplayID,
+			headCommit: ev.PullRequest.FromRef.LatestCommit,
+			headRef:    fmt.Sprintf("refs/pull-requests/%d/from", ev.PullRequest.ID),
+		})
+	}
+	return nil
+}
+
+func validSignature(secret, signature string, body []byte) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// matchingSources returns the sources watching the branch of the repository
+func (b *Bitbucket) matchingSources(ctx context.Context, repo, branch string) ([]*domain.Source, error) {
+	srcs, err := b.sources.ListSources(ctx, application.ListSourcesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var res []*domain.Source
+	for _, src := range srcs {
+		if src.Branch == branch && strings.EqualFold(application.RepoName(src.URL), repo) {
+			res = append(res, src)
+		}
+	}
+	return res, nil
+}
+
+func (b *Bitbucket) syncBranch(ctx context.Context, repo, branch string) {
+	b.logger.LogInfo(ctx, "Received push to %s on %s", repo, branch)
+	srcs, err := b.matchingSources(ctx, repo, branch)
+	if err != nil {
+		b.logger.LogError(ctx, "Could not ListSources:%v", err)
+		return
+	}
+	for _, src := range srcs {
+		err := b.syncer.SyncSourceByID(ctx, src.ID, application.SyncSourceOptions{
+			Trigger: domain.SyncTriggerWebhook,
+		})
+		if err != nil {
+			b.logger.LogError(ctx, "Could not sync source %s:%v", src.Name, err)
+		}
+	}
+}
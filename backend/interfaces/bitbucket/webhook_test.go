@@ -0,0 +1,114 @@
+package bitbucket
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeSources []*domain.Source
+
+func (s fakeSources) ListSources(ctx context.Context, opts application.ListSourcesOptions) ([]*domain.Source, error) {
+	return s, nil
+}
+
+type recordingSyncer struct {
+	synced []string
+}
+
+func (s *recordingSyncer) SyncSourceByID(ctx context.Context, id string, opts application.SyncSourceOptions) error {
+	s.synced = append(s.synced, id)
+	return nil
+}
+
+func (s *recordingSyncer) PreviewSource(ctx context.Context, src *domain.Source, desiredState *application.DesiredState) (*application.ChangeInfo, error) {
+	return &application.ChangeInfo{}, nil
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHandleWebhookPush(t *testing.T) {
+	syncer := &recordingSyncer{}
+	b, err := CreateBitbucket(context.Background(), log.NewSimpleLogger(false, "Test"),
+		Config{WebhookSecret: "secret"}, nil, fakeSources{
+			{ID: "cloud", URL: "git@bitbucket.org:workspace/repo.git", Branch: "main"},
+			{ID: "server", URL: "https://bitbucket.example.com/scm/proj/repo.git", Branch: "main"},
+			{ID: "other-branch", URL: "git@bitbucket.org:workspace/repo.git", Branch: "dev"},
+		}, nil, syncer)
+	if err != nil {
+		t.Fatalf("Could not CreateBitbucket:%v", err)
+	}
+
+	cloud := []byte(`{"push":{"changes":[{"new":{"type":"branch","name":"main"}},{"new":null}]},"repository":{"full_name":"workspace/repo"}}`)
+	if err := b.HandleWebhook(context.Background(), "repo:push", sign("other", cloud), cloud); err != ErrInvalidSignature {
+		t.Fatalf("Expected an invalid signature, got %v", err)
+	}
+	if err := b.HandleWebhook(context.Background(), "repo:push", sign("secret", cloud), cloud); err != nil {
+		t.Fatalf("Could not HandleWebhook:%v", err)
+	}
+
+	server := []byte(`{"changes":[{"ref":{"displayId":"main","type":"BRANCH"},"type":"UPDATE"}],"repository":{"slug":"repo","project":{"key":"PROJ"}}}`)
+	if err := b.HandleWebhook(context.Background(), "repo:refs_changed", sign("secret", server), server); err != nil {
+		t.Fatalf("Could not HandleWebhook:%v", err)
+	}
+
+	if len(syncer.synced) != 2 || syncer.synced[0] != "cloud" || syncer.synced[1] != "server" {
+		t.Errorf("Unexpected synced sources %v", syncer.synced)
+	}
+}
+
+func TestCloudBuildStatus(t *testing.T) {
+	var status buildStatus
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "app-password" {
+			t.Errorf("Unexpected auth %s:%s", u, p)
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repositories/workspace/repo/pullrequests/3/diffstat":
+			_, _ = w.Write([]byte(`{"values":[{"new":{"path":"jobs/web.nomad"},"old":{"path":"old/web.nomad"}},{"new":null,"old":{"path":"README.md"}}]}`))
+		case "POST /repositories/workspace/repo/commit/abc/statuses/build":
+			_ = json.NewDecoder(r.Body).Decode(&status)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	b := &Bitbucket{
+		cfg:    Config{CloudAPIURL: srv.URL},
+		client: srv.Client(),
+	}
+	key := &domain.DeployKey{Type: domain.DeployKeyTypeToken, Username: "user", Value: "app-password"}
+	pr := pullRequest{repo: "workspace/repo", id: 3, headCommit: "abc"}
+
+	files, err := b.changedFiles(context.Background(), key, pr)
+	if err != nil {
+		t.Fatalf("Could not list changed files:%v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("Unexpected files %v", files)
+	}
+
+	err = b.setBuildStatus(context.Background(), key, pr, buildStatus{Key: "nomad-ops-src", State: "SUCCESSFUL"})
+	if err != nil {
+		t.Fatalf("Could not set build status:%v", err)
+	}
+	if status.Key != "nomad-ops-src" || status.State != "SUCCESSFUL" {
+		t.Errorf("Unexpected build status %+v", status)
+	}
+}
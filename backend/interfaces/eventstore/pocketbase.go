@@ -2,7 +2,9 @@ package eventstore
 
 import (
 	"context"
+	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/forms"
 	"github.com/pocketbase/pocketbase/models"
@@ -33,6 +35,7 @@ func CreatePocketBaseStore(ctx context.Context,
 	return t, nil
 }
 func (s *PocketBaseStore) SaveEvent(ctx context.Context, ev *domain.Event) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="events",op="SaveEvent"}`).UpdateDuration(time.Now())
 	collection, err := s.cfg.App.Dao().FindCollectionByNameOrId("events")
 	if err != nil {
 		return err
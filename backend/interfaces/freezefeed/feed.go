@@ -0,0 +1,112 @@
+package freezefeed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// maxFeedSize bounds the size of a fetched feed
+const maxFeedSize = 10 * 1024 * 1024
+
+// HTTPFeed fetches change freezes from an iCal calendar or a JSON feed
+type HTTPFeed struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    HTTPFeedConfig
+	client *http.Client
+}
+
+type HTTPFeedConfig struct {
+	URL string
+	// Authorization is sent as is if set, e.g. "Bearer <token>"
+	Authorization string
+}
+
+func CreateHTTPFeed(ctx context.Context,
+	logger log.Logger,
+	cfg HTTPFeedConfig) (*HTTPFeed, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("the url of the freeze feed is required")
+	}
+	f := &HTTPFeed{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	return f, nil
+}
+
+// jsonWindow is an entry of a JSON feed, e.g. {"start":"2023-12-20T00:00:00Z","end":"2024-01-02T00:00:00Z","reason":"Holidays"}
+type jsonWindow struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Reason   string    `json:"reason"`
+	Selector string    `json:"selector"`
+}
+
+func (f *HTTPFeed) FetchFreezeWindows(ctx context.Context) ([]domain.FreezeWindow, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar, application/json")
+	if f.cfg.Authorization != "" {
+		req.Header.Set("Authorization", f.cfg.Authorization)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		f.logger.LogError(ctx, "Could not fetch freeze feed:%v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch freeze feed: unexpected status %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse parses an iCal calendar or a JSON array of windows, windows without an end or ending before they start
+// are rejected
+func Parse(b []byte) ([]domain.FreezeWindow, error) {
+	b = bytes.TrimSpace(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")))
+	var windows []domain.FreezeWindow
+	if bytes.HasPrefix(b, []byte("BEGIN:VCALENDAR")) {
+		var err error
+		windows, err = ParseICal(b)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		entries := []jsonWindow{}
+		err := json.Unmarshal(b, &entries)
+		if err != nil {
+			return nil, fmt.Errorf("freeze feed is neither an iCal calendar nor a JSON array of windows: %v", err)
+		}
+		for _, e := range entries {
+			windows = append(windows, domain.FreezeWindow(e))
+		}
+	}
+	for _, w := range windows {
+		if w.End.IsZero() || !w.End.After(w.Start) {
+			return nil, fmt.Errorf("freeze window %q from %s must end after it starts", w.Reason, w.Start.Format(time.RFC3339))
+		}
+		if _, err := domain.ParseLabelSelector(w.Selector); err != nil {
+			return nil, fmt.Errorf("freeze window %q: %v", w.Reason, err)
+		}
+	}
+	return windows, nil
+}
@@ -0,0 +1,67 @@
+package freezefeed
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestParse(t *testing.T) {
+	format := func(windows []domain.FreezeWindow) string {
+		res := []string{}
+		for _, w := range windows {
+			res = append(res, fmt.Sprintf("%s-%s %s", w.Start.UTC().Format(time.RFC3339), w.End.UTC().Format(time.RFC3339), w.Reason))
+		}
+		return strings.Join(res, "\n")
+	}
+
+	ical := strings.ReplaceAll(`BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+DTSTART:20231220T080000Z
+DTEND:20231220T180000Z
+SUMMARY:Release of the
+  new checkout\, no changes
+END:VEVENT
+BEGIN:VEVENT
+DTSTART;TZID=Europe/Berlin:20231224T000000
+DTEND;TZID=Europe/Berlin:20231227T000000
+SUMMARY:Holidays
+END:VEVENT
+BEGIN:VEVENT
+DTSTART;VALUE=DATE:20231231
+SUMMARY:New Year's Eve
+END:VEVENT
+END:VCALENDAR
+`, "\n", "\r\n")
+	windows, err := Parse([]byte(ical))
+	if err != nil {
+		t.Fatalf("Could not parse iCal:%v", err)
+	}
+	expected := `2023-12-20T08:00:00Z-2023-12-20T18:00:00Z Release of the new checkout, no changes
+2023-12-23T23:00:00Z-2023-12-26T23:00:00Z Holidays
+2023-12-31T00:00:00Z-2024-01-01T00:00:00Z New Year's Eve`
+	if format(windows) != expected {
+		t.Errorf("Unexpected windows:\n%s", format(windows))
+	}
+
+	windows, err = Parse([]byte(`[{"start":"2023-12-20T08:00:00Z","end":"2023-12-20T18:00:00Z","reason":"Release"}]`))
+	if err != nil {
+		t.Fatalf("Could not parse JSON:%v", err)
+	}
+	if format(windows) != "2023-12-20T08:00:00Z-2023-12-20T18:00:00Z Release" {
+		t.Errorf("Unexpected windows:\n%s", format(windows))
+	}
+
+	_, err = Parse([]byte(`[{"start":"2023-12-20T08:00:00Z","reason":"No end"}]`))
+	if err == nil {
+		t.Errorf("Expected windows without an end to be rejected")
+	}
+	_, err = Parse([]byte(`<html></html>`))
+	if err == nil {
+		t.Errorf("Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,110 @@
+package freezefeed
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// ParseICal returns the events of an iCal calendar (RFC 5545) as freeze windows.
+// Recurring events are not expanded, only their first occurrence is used.
+// The custom property X-NOMAD-OPS-SELECTOR limits an event to the sources matching the label selector.
+func ParseICal(b []byte) ([]domain.FreezeWindow, error) {
+	windows := []domain.FreezeWindow{}
+	var current *domain.FreezeWindow
+	allDay := false
+	for _, line := range unfold(b) {
+		name, params, value := splitProperty(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &domain.FreezeWindow{}
+			allDay = false
+		case name == "END" && value == "VEVENT":
+			if current == nil {
+				return nil, fmt.Errorf("unexpected END:VEVENT")
+			}
+			if current.End.IsZero() && allDay {
+				// all day events without an end last one day
+				current.End = current.Start.AddDate(0, 0, 1)
+			}
+			windows = append(windows, *current)
+			current = nil
+		case current == nil:
+		case name == "DTSTART" || name == "DTEND":
+			t, date, err := parseTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %v", name, value, err)
+			}
+			if name == "DTSTART" {
+				current.Start = t
+				allDay = date
+			} else {
+				current.End = t
+			}
+		case name == "SUMMARY":
+			current.Reason = unescape(value)
+		case name == "X-NOMAD-OPS-SELECTOR":
+			current.Selector = unescape(value)
+		}
+	}
+	return windows, nil
+}
+
+// unfold joins the continuation lines, which start with a space or tab
+func unfold(b []byte) []string {
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 64*1024), maxFeedSize)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitProperty splits e.g. DTSTART;TZID=Europe/Berlin:20231220T080000
+func splitProperty(line string) (string, map[string]string, string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+	params := map[string]string{}
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// parseTime parses dates, UTC, floating and TZID times. Floating times are read as UTC.
+// It returns true for dates without a time.
+func parseTime(params map[string]string, value string) (time.Time, bool, error) {
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		var err error
+		loc, err = time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+	}
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,371 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	giturls "github.com/whilp/git-urls"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	utilerrors "github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// GitHub limits the text of a check run to 65535 characters
+const maxCheckRunText = 60000
+
+// SourceSyncer is triggered by push events and previews pull requests
+type SourceSyncer interface {
+	SyncSource(ctx context.Context, repo, branch string, opts application.SyncSourceOptions) error
+	PreviewSource(ctx context.Context, src *domain.Source, desiredState *application.DesiredState) (*application.ChangeInfo, error)
+}
+
+// GitHubApp receives the webhooks of a GitHub App and publishes the plan of pull requests as check runs
+type GitHubApp struct {
+	ctx     context.Context
+	logger  log.Logger
+	cfg     GitHubAppConfig
+	git     *GitProvider
+	sources application.SourceRepo
+	keyRepo application.KeyRepo
+	syncer  SourceSyncer
+	client  *http.Client
+}
+
+type GitHubAppConfig struct {
+	WebhookSecret string
+	// CheckRunName is prefixed to the name of the source
+	CheckRunName string
+	// Transport is used for the API, defaults to http.DefaultTransport
+	Transport *http.Transport
+}
+
+func CreateGitHubApp(ctx context.Context,
+	logger log.Logger,
+	cfg GitHubAppConfig,
+	git *GitProvider,
+	sources application.SourceRepo,
+	keyRepo application.KeyRepo,
+	syncer SourceSyncer) (*GitHubApp, error) {
+
+	if cfg.WebhookSecret == "" {
+		return nil, fmt.Errorf("a webhook secret is required")
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport.(*http.Transport)
+	}
+	if cfg.CheckRunName == "" {
+		cfg.CheckRunName = "nomad-ops"
+	}
+	return &GitHubApp{
+		ctx:     ctx,
+		logger:  logger,
+		cfg:     cfg,
+		git:     git,
+		sources: sources,
+		keyRepo: keyRepo,
+		syncer:  syncer,
+		client: &http.Client{
+			Timeout:   time.Second * 30,
+			Transport: cfg.Transport,
+		},
+	}, nil
+}
+
+type webhookRepository struct {
+	FullName string `json:"full_name"`
+}
+
+type pushEvent struct {
+	Ref        string            `json:"ref"`
+	Repository webhookRepository `json:"repository"`
+}
+
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			SHA string `json:"sha"`
+			// nil if the repository of a fork was deleted
+			Repo *webhookRepository `json:"repo"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository webhookRepository `json:"repository"`
+}
+
+// HandleWebhook verifies and processes a webhook delivery. Pull requests are previewed in the background.
+func (a *GitHubApp) HandleWebhook(ctx context.Context, event, signature string, body []byte) error {
+	if !validSignature(a.cfg.WebhookSecret, signature, body) {
+		return ErrInvalidSignature
+	}
+
+	switch event {
+	case "push":
+		ev := pushEvent{}
+		err := json.Unmarshal(body, &ev)
+		if err != nil {
+			return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		if !strings.HasPrefix(ev.Ref, "refs/heads/") {
+			// tags
+			return nil
+		}
+		branch := strings.TrimPrefix(ev.Ref, "refs/heads/")
+		a.logger.LogInfo(ctx, "Received push to %s on %s", ev.Repository.FullName, branch)
+		err = a.syncer.SyncSource(ctx, ev.Repository.FullName, branch, application.SyncSourceOptions{
+			Trigger: domain.SyncTriggerWebhook,
+		})
+		if err != nil && err != utilerrors.ErrNotFound {
+			return err
+		}
+		return nil
+	case "pull_request":
+		ev := pullRequestEvent{}
+		err := json.Unmarshal(body, &ev)
+		if err != nil {
+			return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		if ev.Action != "opened" && ev.Action != "synchronize" && ev.Action != "reopened" {
+			return nil
+		}
+		if !ev.fromSameRepository() {
+			// the jobs of forks would be planned with the credentials of the sources and their diff published
+			a.logger.LogInfo(ctx, "Not previewing pull request %d of %s from a fork", ev.Number, ev.Repository.FullName)
+			return nil
+		}
+		a.logger.LogInfo(ctx, "Previewing pull request %d of %s", ev.Number, ev.Repository.FullName)
+		// GitHub expects a response within 10s
+		go a.previewPullRequest(a.ctx, ev)
+		return nil
+	}
+	return nil
+}
+
+// fromSameRepository checks if the head branch of the pull request is in the repository of the base branch
+func (ev pullRequestEvent) fromSameRepository() bool {
+	return ev.PullRequest.Head.Repo != nil && strings.EqualFold(ev.PullRequest.Head.Repo.FullName, ev.Repository.FullName)
+}
+
+func validSignature(secret, signature string, body []byte) bool {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// previewPullRequest publishes a check run with the plan of every source affected by the pull request
+func (a *GitHubApp) previewPullRequest(ctx context.Context, ev pullRequestEvent) {
+	srcs, err := a.sources.ListSources(ctx, application.ListSourcesOptions{})
+	if err != nil {
+		a.logger.LogError(ctx, "Could not ListSources:%v", err)
+		return
+	}
+
+	var files []string
+	for _, src := range srcs {
+		if src.Branch != ev.PullRequest.Base.Ref || !strings.EqualFold(repoFullName(src.URL), ev.Repository.FullName) {
+			continue
+		}
+		key, err := a.appKey(ctx, src)
+		if err != nil {
+			a.logger.LogTrace(ctx, "Not previewing source %s:%v", src.Name, err)
+			continue
+		}
+		if files == nil {
+			files, err = a.pullRequestFiles(ctx, key, ev.Repository.FullName, ev.Number)
+			if err != nil {
+				a.logger.LogError(ctx, "Could not list files of pull request %d of %s:%v", ev.Number, ev.Repository.FullName, err)
+				return
+			}
+		}
+		if !touchesPath(files, src.Path) {
+			continue
+		}
+
+		conclusion, title, text := "success", "", ""
+		changes, err := a.previewSource(ctx, src, ev.Number)
+		if err != nil {
+			a.logger.LogError(ctx, "Could not preview source %s:%v", src.Name, err)
+			conclusion, title, text = "failure", "Plan failed", err.Error()
+		} else {
+			title = fmt.Sprintf("%d to create, %d to update, %d to delete", len(changes.Create), len(changes.Update), len(changes.Delete))
+			text = changes.Summary()
+			if text == "" {
+				title = "No changes"
+			}
+		}
+		err = a.createCheckRun(ctx, key, ev.Repository.FullName, checkRun{
+			Name:       fmt.Sprintf("%s: %s", a.cfg.CheckRunName, src.Name),
+			HeadSHA:    ev.PullRequest.Head.SHA,
+			Status:     "completed",
+			Conclusion: conclusion,
+			Output: checkRunOutput{
+				Title:   title,
+				Summary: fmt.Sprintf("Plan of source %s against the Nomad cluster", src.Name),
+				Text:    truncateCheckRunText(text),
+			},
+		})
+		if err != nil {
+			a.logger.LogError(ctx, "Could not create check run for source %s:%v", src.Name, err)
+		}
+	}
+}
+
+func (a *GitHubApp) previewSource(ctx context.Context, src *domain.Source, number int) (*application.ChangeInfo, error) {
+	desiredState, err := a.git.FetchDesiredStateAt(ctx, src, fmt.Sprintf("refs/pull/%d/head", number))
+	if err != nil {
+		return nil, err
+	}
+	return a.syncer.PreviewSource(ctx, src, desiredState)
+}
+
+// appKey returns the GitHub App key of the source, check runs can only be created by GitHub Apps
+func (a *GitHubApp) appKey(ctx context.Context, src *domain.Source) (*domain.DeployKey, error) {
+	if src.DeployKeyID == "" {
+		return nil, fmt.Errorf("source has no key")
+	}
+	key, err := a.keyRepo.GetKey(ctx, src.DeployKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Type != domain.DeployKeyTypeGitHubApp {
+		return nil, fmt.Errorf("key %s is no GitHub App key", key.Name)
+	}
+	return key, nil
+}
+
+func (a *GitHubApp) apiRequest(ctx context.Context, key *domain.DeployKey, method, apiPath string, body any) (*http.Request, error) {
+	p, ok := a.git.credentials[domain.DeployKeyTypeGitHubApp].(*gitHubAppProvider)
+	if !ok {
+		return nil, fmt.Errorf("GitHub App credentials are not available")
+	}
+	token, err := p.cache.get(key, time.Now(), func() (string, time.Time, error) {
+		return p.installationToken(ctx, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if body != nil {
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(p.apiURL, "/")+apiPath, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// pullRequestFiles lists the files changed by the pull request, GitHub returns at most 3000 files
+func (a *GitHubApp) pullRequestFiles(ctx context.Context, key *domain.DeployKey, repo string, number int) ([]string, error) {
+	files := []string{}
+	for page := 1; page <= 30; page++ {
+		req, err := a.apiRequest(ctx, key, http.MethodGet,
+			fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=100&page=%d", repo, number, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		var res []struct {
+			Filename         string `json:"filename"`
+			PreviousFilename string `json:"previous_filename"`
+		}
+		err = doTokenRequest(a.client, req, http.StatusOK, &res)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range res {
+			files = append(files, f.Filename)
+			if f.PreviousFilename != "" {
+				files = append(files, f.PreviousFilename)
+			}
+		}
+		if len(res) < 100 {
+			break
+		}
+	}
+	return files, nil
+}
+
+type checkRun struct {
+	Name       string         `json:"name"`
+	HeadSHA    string         `json:"head_sha"`
+	Status     string         `json:"status"`
+	Conclusion string         `json:"conclusion,omitempty"`
+	Output     checkRunOutput `json:"output"`
+}
+
+type checkRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Text    string `json:"text,omitempty"`
+}
+
+func (a *GitHubApp) createCheckRun(ctx context.Context, key *domain.DeployKey, repo string, run checkRun) error {
+	req, err := a.apiRequest(ctx, key, http.MethodPost, fmt.Sprintf("/repos/%s/check-runs", repo), run)
+	if err != nil {
+		return err
+	}
+	var res struct{}
+	return doTokenRequest(a.client, req, http.StatusCreated, &res)
+}
+
+func truncateCheckRunText(text string) string {
+	if len(text) <= maxCheckRunText {
+		return text
+	}
+	return text[:maxCheckRunText] + "\n\n... (truncated)"
+}
+
+// repoFullName returns the owner/name of the repository of a git url
+func repoFullName(rawURL string) string {
+	u, err := giturls.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+}
+
+// touchesPath checks if any of the files is the path of the source or within it
+func touchesPath(files []string, srcPath string) bool {
+	p := path.Clean(strings.TrimPrefix(srcPath, "/"))
+	if p == "." || p == "" {
+		return len(files) > 0
+	}
+	for _, f := range files {
+		if f == p || strings.HasPrefix(f, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package github
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type recordingSyncer struct {
+	repo, branch string
+}
+
+func (s *recordingSyncer) SyncSource(ctx context.Context, repo, branch string, opts application.SyncSourceOptions) error {
+	s.repo, s.branch = repo, branch
+	return nil
+}
+
+func (s *recordingSyncer) PreviewSource(ctx context.Context, src *domain.Source, desiredState *application.DesiredState) (*application.ChangeInfo, error) {
+	return &application.ChangeInfo{}, nil
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHandleWebhookPush(t *testing.T) {
+	syncer := &recordingSyncer{}
+	a, err := CreateGitHubApp(context.Background(), log.NewSimpleLogger(false, "Test"),
+		GitHubAppConfig{WebhookSecret: "secret"}, nil, nil, nil, syncer)
+	if err != nil {
+		t.Fatalf("Could not CreateGitHubApp:%v", err)
+	}
+
+	body := []byte(`{"ref":"refs/heads/main","repository":{"full_name":"org/repo"}}`)
+	if err := a.HandleWebhook(context.Background(), "push", sign("other", body), body); err != ErrInvalidSignature {
+		t.Fatalf("Expected an invalid signature, got %v", err)
+	}
+	if err := a.HandleWebhook(context.Background(), "push", "", body); err != ErrInvalidSignature {
+		t.Fatalf("Expected a missing signature to be rejected, got %v", err)
+	}
+
+	if err := a.HandleWebhook(context.Background(), "push", sign("secret", body), body); err != nil {
+		t.Fatalf("Could not HandleWebhook:%v", err)
+	}
+	if syncer.repo != "org/repo" || syncer.branch != "main" {
+		t.Errorf("Unexpected sync of %s on %s", syncer.repo, syncer.branch)
+	}
+}
+
+func TestTouchesPath(t *testing.T) {
+	files := []string{"README.md", "jobs/web.nomad"}
+	for p, expected := range map[string]bool{
+		"jobs":           true,
+		"/jobs/":         true,
+		"jobs/web.nomad": true,
+		"job":            false,
+		"other":          false,
+		".":              true,
+	} {
+		if touchesPath(files, p) != expected {
+			t.Errorf("Expected %v for path %s", expected, p)
+		}
+	}
+}
+
+func TestRepoFullName(t *testing.T) {
+	for u, expected := range map[string]string{
+		"git@github.com:org/repo.git":     "org/repo",
+		"https://github.com/org/repo.git": "org/repo",
+		"https://github.com/org/repo":     "org/repo",
+	} {
+		if n := repoFullName(u); n != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, u, n)
+		}
+	}
+}
+
+func TestPullRequestFromSameRepository(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected bool
+	}{
+		{`{"pull_request":{"head":{"repo":{"full_name":"org/repo"}}},"repository":{"full_name":"org/repo"}}`, true},
+		{`{"pull_request":{"head":{"repo":{"full_name":"fork/repo"}}},"repository":{"full_name":"org/repo"}}`, false},
+		{`{"pull_request":{"head":{"repo":null}},"repository":{"full_name":"org/repo"}}`, false},
+	}
+	for _, tt := range tests {
+		ev := pullRequestEvent{}
+		if err := json.Unmarshal([]byte(tt.body), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.fromSameRepository() != tt.expected {
+			t.Errorf("%s: expected %v", tt.body, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// addBundles adds the jobs of the bundles declared by the manifest in dir to the desired state.
+// Locked versions are used as long as they satisfy the constraints of the manifest, other bundles
+// are resolved to the highest tag satisfying their constraint. Bundles cannot declare bundles themselves.
+func (g *GitProvider) addBundles(ctx context.Context,
+	src *domain.Source,
+	fs billy.Filesystem,
+	dir string,
+	desiredState *application.DesiredState,
+	jobFiles map[string]string) error {
+
+	manifest := &domain.BundleManifest{}
+	found, err := readJSONFile(fs, fs.Join(dir, domain.BundleManifestFile), manifest)
+	if err != nil {
+		return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("invalid %s: %w", domain.BundleManifestFile, err))
+	}
+	if !found || len(manifest.Bundles) == 0 {
+		return nil
+	}
+	if err := manifest.Validate(); err != nil {
+		return err
+	}
+	lock := &domain.BundleLock{}
+	_, err = readJSONFile(fs, fs.Join(dir, domain.BundleLockFile), lock)
+	if err != nil {
+		return domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("invalid %s: %w", domain.BundleLockFile, err))
+	}
+	auth, err := g.sourceAuth(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range manifest.Bundles {
+		bundleAuth := bundleAuthMethod(auth, b.URL)
+		locked, err := g.resolveBundle(ctx, b, lock.Find(b.Name), bundleAuth)
+		if err != nil {
+			return err
+		}
+		bundleFS, err := g.checkoutBundle(ctx, b, locked, bundleAuth)
+		if err != nil {
+			return err
+		}
+
+		bundleSrc := *src
+		bundleSrc.URL = b.URL
+		bundleSrc.Path = b.Path
+		if bundleSrc.Path == "" {
+			bundleSrc.Path = "/"
+		}
+		bundleSrc.Renderer = b.Renderer
+		g.logger.LogTrace(ctx, "Adding bundle %s %s at %s", b.Name, locked.Version, locked.Commit)
+		err = g.readJobs(ctx, &bundleSrc, bundleFS, fmt.Sprintf("bundle %s: ", b.Name), desiredState, jobFiles)
+		if err != nil {
+			return fmt.Errorf("could not read bundle %s %s: %w", b.Name, locked.Version, err)
+		}
+		desiredState.Bundles = append(desiredState.Bundles, *locked)
+	}
+	return nil
+}
+
+// resolveBundle returns the tag of the bundle to deploy
+func (g *GitProvider) resolveBundle(ctx context.Context,
+	b domain.BundleDependency,
+	locked *domain.LockedBundle,
+	auth transport.AuthMethod) (*domain.LockedBundle, error) {
+
+	constraint, err := domain.ParseVersionConstraint(b.Version)
+	if err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{b.URL},
+	})
+	var refs []*plumbing.Reference
+	err = application.RunPhase(ctx, domain.ReconcilePhaseFetch, func(ctx context.Context) error {
+		var err error
+		refs, err = remote.ListContext(ctx, &git.ListOptions{
+			Auth: auth,
+		})
+		return err
+	})
+	if err != nil {
+		g.logger.LogError(ctx, "Could not list the tags of bundle %s - %v", b.URL, err)
+		return nil, gitAuthError(err)
+	}
+
+	var best *domain.LockedBundle
+	var bestVersion domain.Version
+	for _, ref := range refs {
+		if !ref.Name().IsTag() {
+			continue
+		}
+		tag := ref.Name().Short()
+		v, err := domain.ParseVersion(tag)
+		if err != nil || !constraint.Check(v) {
+			continue
+		}
+		if locked != nil && locked.URL == b.URL && locked.Tag == tag {
+			// the lockfile wins as long as it satisfies the manifest
+			return &domain.LockedBundle{
+				Name:    b.Name,
+				URL:     b.URL,
+				Version: v.String(),
+				Tag:     tag,
+				Commit:  locked.Commit,
+			}, nil
+		}
+		if best == nil || v.Compare(bestVersion) > 0 {
+			bestVersion = v
+			best = &domain.LockedBundle{
+				Name:    b.Name,
+				URL:     b.URL,
+				Version: v.String(),
+				Tag:     tag,
+			}
+		}
+	}
+	if best == nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeNotFound,
+			fmt.Errorf("no tag of bundle %s satisfies the version %s", b.Name, b.Version))
+	}
+	return best, nil
+}
+
+// checkoutBundle fetches the tag of the bundle and sets the commit of the locked bundle,
+// locked commits must still be the commit of the tag
+func (g *GitProvider) checkoutBundle(ctx context.Context,
+	b domain.BundleDependency,
+	locked *domain.LockedBundle,
+	auth transport.AuthMethod) (billy.Filesystem, error) {
+
+	fs := memfs.New()
+	repo, err := git.Init(memory.NewStorage(), fs)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := repo.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{b.URL},
+	})
+	if err != nil {
+		return nil, err
+	}
+	local := plumbing.NewBranchReferenceName("nomad-ops-bundle")
+	err = application.RunPhase(ctx, domain.ReconcilePhaseFetch, func(ctx context.Context) error {
+		return remote.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", plumbing.NewTagReferenceName(locked.Tag), local))},
+			Auth:     auth,
+			Tags:     git.NoTags,
+		})
+	})
+	if err != nil {
+		g.logger.LogError(ctx, "Could not fetch %s of bundle %s - %v", locked.Tag, b.URL, err)
+		return nil, gitAuthError(err)
+	}
+	ref, err := repo.Reference(local, true)
+	if err != nil {
+		return nil, err
+	}
+	commit := ref.Hash()
+	// annotated tags point to a tag object
+	if tag, err := repo.TagObject(commit); err == nil {
+		c, err := tag.Commit()
+		if err != nil {
+			return nil, err
+		}
+		commit = c.Hash
+	}
+	if locked.Commit != "" && locked.Commit != commit.String() {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest,
+			fmt.Errorf("tag %s of bundle %s moved from the locked commit %s to %s, update %s",
+				locked.Tag, b.Name, locked.Commit, commit, domain.BundleLockFile))
+	}
+	locked.Commit = commit.String()
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	err = wt.Checkout(&git.CheckoutOptions{
+		Hash: commit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// bundleAuthMethod returns the credentials of the source if they can be used for the url of the bundle,
+// e.g. ssh keys are not sent to http remotes
+func bundleAuthMethod(auth transport.AuthMethod, url string) transport.AuthMethod {
+	if auth == nil {
+		return nil
+	}
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil
+	}
+	_, isHTTP := auth.(githttp.AuthMethod)
+	switch ep.Protocol {
+	case "http", "https":
+		if isHTTP {
+			return auth
+		}
+	case "ssh":
+		if !isHTTP {
+			return auth
+		}
+	}
+	return nil
+}
+
+// readJSONFile decodes the file, it returns false if the file does not exist
+func readJSONFile(fs billy.Filesystem, name string, v interface{}) (bool, error) {
+	f, err := fs.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// bundleDir returns the directory of the manifest of the source
+func bundleDir(srcPath string, isDir bool) string {
+	if isDir {
+		return srcPath
+	}
+	return path.Dir(srcPath)
+}
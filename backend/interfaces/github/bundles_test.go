@@ -0,0 +1,155 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type jobNameParser struct{}
+
+var jobNameRegex = regexp.MustCompile(`job "([^"]+)"`)
+
+func (jobNameParser) ParseJob(ctx context.Context, j string, opts application.ParseJobOptions) (*application.JobInfo, error) {
+	name := jobNameRegex.FindStringSubmatch(j)[1]
+	return &application.JobInfo{Job: &api.Job{Name: &name}}, nil
+}
+
+func TestAddBundles(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every version of the bundle deploys a differently named job
+	commits := map[string]string{}
+	for _, version := range []string{"1.0.0", "1.2.0", "2.0.0"} {
+		err = util.WriteFile(wt.Filesystem, "jobs/agent.nomad", []byte(`job "agent-`+version+`" {}`), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add("jobs/agent.nomad"); err != nil {
+			t.Fatal(err)
+		}
+		sig := &object.Signature{Name: "test", Email: "test@localhost", When: time.Now()}
+		hash, err := wt.Commit("Release "+version, &git.CommitOptions{Author: sig})
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits[version] = hash.String()
+		opts := &git.CreateTagOptions{Message: "Release " + version, Tagger: sig}
+		if version == "1.0.0" {
+			// lightweight tag
+			opts = nil
+		}
+		if _, err := repo.CreateTag("v"+version, hash, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := &GitProvider{logger: log.NewSimpleLogger(false, "Test"), parser: jobNameParser{}}
+	src := &domain.Source{Name: "web", Path: "apps"}
+	run := func(lock *domain.BundleLock, jobs ...string) (*application.DesiredState, error) {
+		fs := memfs.New()
+		write := func(fs billy.Filesystem, name string, v interface{}) {
+			b, _ := json.Marshal(v)
+			if err := util.WriteFile(fs, name, b, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		write(fs, "apps/"+domain.BundleManifestFile, domain.BundleManifest{Bundles: []domain.BundleDependency{
+			{Name: "agents", URL: dir, Path: "jobs", Version: "^1.0.0"},
+		}})
+		if lock != nil {
+			write(fs, "apps/"+domain.BundleLockFile, lock)
+		}
+		desiredState := &application.DesiredState{Jobs: map[string]*application.JobInfo{}}
+		jobFiles := map[string]string{}
+		for _, name := range jobs {
+			desiredState.Jobs[name] = &application.JobInfo{Job: &api.Job{Name: &name}}
+			jobFiles["default/"+name] = name + ".nomad"
+		}
+		err := g.addBundles(context.Background(), src, fs, "apps", desiredState, jobFiles)
+		return desiredState, err
+	}
+	names := func(desiredState *application.DesiredState) []string {
+		res := []string{}
+		for name := range desiredState.Jobs {
+			res = append(res, name)
+		}
+		sort.Strings(res)
+		return res
+	}
+
+	// the highest matching version is deployed together with the jobs of the source
+	desiredState, err := run(nil, "web")
+	if err != nil {
+		t.Fatalf("Could not addBundles:%v", err)
+	}
+	if got := names(desiredState); len(got) != 2 || got[0] != "agent-1.2.0" || got[1] != "web" {
+		t.Errorf("Unexpected jobs %v", got)
+	}
+	if len(desiredState.Bundles) != 1 || desiredState.Bundles[0].Version != "1.2.0" ||
+		desiredState.Bundles[0].Commit != commits["1.2.0"] || desiredState.Bundles[0].Tag != "v1.2.0" {
+		t.Errorf("Unexpected bundles %v", desiredState.Bundles)
+	}
+
+	// the lockfile pins the version
+	locked := &domain.BundleLock{Bundles: []domain.LockedBundle{
+		{Name: "agents", URL: dir, Version: "1.0.0", Tag: "v1.0.0", Commit: commits["1.0.0"]},
+	}}
+	desiredState, err = run(locked)
+	if err != nil {
+		t.Fatalf("Could not addBundles:%v", err)
+	}
+	if got := names(desiredState); len(got) != 1 || got[0] != "agent-1.0.0" {
+		t.Errorf("Expected the locked version, got %v", got)
+	}
+
+	// locked versions not satisfying the manifest are resolved again
+	desiredState, err = run(&domain.BundleLock{Bundles: []domain.LockedBundle{
+		{Name: "agents", URL: dir, Version: "2.0.0", Tag: "v2.0.0", Commit: commits["2.0.0"]},
+	}})
+	if err != nil {
+		t.Fatalf("Could not addBundles:%v", err)
+	}
+	if got := names(desiredState); len(got) != 1 || got[0] != "agent-1.2.0" {
+		t.Errorf("Expected the version to be resolved again, got %v", got)
+	}
+
+	// moved tags are rejected
+	locked.Bundles[0].Commit = commits["2.0.0"]
+	if _, err := run(locked); domain.ErrorCodeOf(err, "") != domain.ErrorCodeInvalidRequest {
+		t.Errorf("Expected the moved tag to be rejected, got %v", err)
+	}
+
+	// jobs of bundles must not conflict with the jobs of the source
+	if _, err := run(nil, "agent-1.2.0"); domain.ErrorCodeOf(err, "") != domain.ErrorCodeJobConflict {
+		t.Errorf("Expected a job conflict, got %v", err)
+	}
+
+	// sources without manifest have no bundles
+	desiredState = &application.DesiredState{Jobs: map[string]*application.JobInfo{}}
+	if err := g.addBundles(context.Background(), src, memfs.New(), "apps", desiredState, map[string]string{}); err != nil ||
+		len(desiredState.Bundles) != 0 {
+		t.Errorf("Expected no bundles, got %v %v", desiredState.Bundles, err)
+	}
+}
@@ -0,0 +1,251 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/golang-jwt/jwt/v4"
+	sshstd "golang.org/x/crypto/ssh"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// CredentialProvider turns a key into the auth method used for git operations
+type CredentialProvider interface {
+	AuthMethod(ctx context.Context, key *domain.DeployKey) (transport.AuthMethod, error)
+}
+
+// tokens are refreshed this long before they expire, so they are still valid during long clones
+const tokenExpiryMargin = time.Minute * 5
+
+type sshKeyProvider struct{}
+
+func (p *sshKeyProvider) AuthMethod(ctx context.Context, key *domain.DeployKey) (transport.AuthMethod, error) {
+	publicKeys, err := ssh.NewPublicKeys("git", []byte(key.Value), "")
+	if err != nil {
+		return nil, err
+	}
+	publicKeys.HostKeyCallback = sshstd.InsecureIgnoreHostKey()
+	return publicKeys, nil
+}
+
+type staticTokenProvider struct{}
+
+func (p *staticTokenProvider) AuthMethod(ctx context.Context, key *domain.DeployKey) (transport.AuthMethod, error) {
+	if key.Value == "" {
+		return nil, fmt.Errorf("token of key %s is empty", key.Name)
+	}
+	username := key.Username
+	if username == "" {
+		// ignored by most providers, but must not be empty
+		username = "nomad-ops"
+	}
+	return &githttp.BasicAuth{
+		Username: username,
+		Password: key.Value,
+	}, nil
+}
+
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+// tokenCache holds short lived tokens by key id until shortly before they expire
+type tokenCache struct {
+	lock   sync.Mutex
+	tokens map[string]cachedToken
+}
+
+func (c *tokenCache) get(key *domain.DeployKey, now time.Time, fetch func() (string, time.Time, error)) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cacheKey := key.ID + "/" + key.Value
+	if t, ok := c.tokens[cacheKey]; ok && now.Add(tokenExpiryMargin).Before(t.expires) {
+		return t.token, nil
+	}
+	token, expires, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	if c.tokens == nil {
+		c.tokens = map[string]cachedToken{}
+	}
+	c.tokens[cacheKey] = cachedToken{
+		token:   token,
+		expires: expires,
+	}
+	return token, nil
+}
+
+// gitHubAppProvider authenticates with installation tokens of a GitHub App
+type gitHubAppProvider struct {
+	apiURL string
+	client *http.Client
+	cache  tokenCache
+}
+
+func (p *gitHubAppProvider) AuthMethod(ctx context.Context, key *domain.DeployKey) (transport.AuthMethod, error) {
+	if key.AppID == "" || key.InstallationID == "" {
+		return nil, fmt.Errorf("key %s requires an app id and an installation id", key.Name)
+	}
+	token, err := p.cache.get(key, time.Now(), func() (string, time.Time, error) {
+		return p.installationToken(ctx, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &githttp.BasicAuth{
+		Username: "x-access-token",
+		Password: token,
+	}, nil
+}
+
+func (p *gitHubAppProvider) installationToken(ctx context.Context, key *domain.DeployKey) (string, time.Time, error) {
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(key.Value))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("could not parse private key of GitHub App: %w", err)
+	}
+	now := time.Now()
+	appToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		// allow for clock drift
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * 9)),
+		Issuer:    key.AppID,
+	}).SignedString(privateKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/app/installations/%s/access_tokens", strings.TrimSuffix(p.apiURL, "/"), url.PathEscape(key.InstallationID)), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+appToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	var res struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err = doTokenRequest(p.client, req, http.StatusCreated, &res)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("could not create installation token: %w", err)
+	}
+	return res.Token, res.ExpiresAt, nil
+}
+
+// azureIdentityProvider authenticates against Azure DevOps with the managed identity of the host
+type azureIdentityProvider struct {
+	metadataURL string
+	client      *http.Client
+	cache       tokenCache
+}
+
+// resource id of Azure DevOps
+const azureDevOpsResource = "499b84ac-1321-427f-aa17-267ca6975798"
+
+func (p *azureIdentityProvider) AuthMethod(ctx context.Context, key *domain.DeployKey) (transport.AuthMethod, error) {
+	token, err := p.cache.get(key, time.Now(), func() (string, time.Time, error) {
+		q := url.Values{}
+		q.Set("api-version", "2018-02-01")
+		q.Set("resource", azureDevOpsResource)
+		if key.Value != "" {
+			// user assigned identity
+			q.Set("client_id", key.Value)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.metadataURL+"/metadata/identity/oauth2/token?"+q.Encode(), nil)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		req.Header.Set("Metadata", "true")
+
+		var res struct {
+			AccessToken string `json:"access_token"`
+			ExpiresOn   string `json:"expires_on"`
+		}
+		err = doTokenRequest(p.client, req, http.StatusOK, &res)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("could not get token of managed identity: %w", err)
+		}
+		expiresOn, err := strconv.ParseInt(res.ExpiresOn, 10, 64)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("unexpected expiry of managed identity token %q", res.ExpiresOn)
+		}
+		return res.AccessToken, time.Unix(expiresOn, 0), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &githttp.TokenAuth{
+		Token: token,
+	}, nil
+}
+
+// gcpIdentityProvider authenticates against Google Cloud Source Repositories with the service account of the host
+type gcpIdentityProvider struct {
+	metadataURL string
+	client      *http.Client
+	cache       tokenCache
+}
+
+func (p *gcpIdentityProvider) AuthMethod(ctx context.Context, key *domain.DeployKey) (transport.AuthMethod, error) {
+	token, err := p.cache.get(key, time.Now(), func() (string, time.Time, error) {
+		account := key.Value
+		if account == "" {
+			account = "default"
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+			fmt.Sprintf("%s/computeMetadata/v1/instance/service-accounts/%s/token", p.metadataURL, url.PathEscape(account)), nil)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+
+		var res struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int64  `json:"expires_in"`
+		}
+		now := time.Now()
+		err = doTokenRequest(p.client, req, http.StatusOK, &res)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("could not get token of service account: %w", err)
+		}
+		return res.AccessToken, now.Add(time.Duration(res.ExpiresIn) * time.Second), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &githttp.BasicAuth{
+		Username: "oauth2accesstoken",
+		Password: token,
+	}, nil
+}
+
+func doTokenRequest(client *http.Client, req *http.Request, expectedStatus int, res any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != expectedStatus {
+		return fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, res)
+}
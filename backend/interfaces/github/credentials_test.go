@@ -0,0 +1,109 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestGitHubAppProvider(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Could not generate key:%v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+
+	requests := 0
+	expiresAt := time.Now().Add(time.Hour)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		claims := &jwt.RegisteredClaims{}
+		_, err := jwt.ParseWithClaims(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), claims,
+			func(token *jwt.Token) (interface{}, error) {
+				return &privateKey.PublicKey, nil
+			})
+		if err != nil || claims.Issuer != "7" {
+			t.Errorf("Unexpected app token %v:%+v", err, claims)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"token":      "installation-token",
+			"expires_at": expiresAt,
+		})
+	}))
+	defer srv.Close()
+
+	p := &gitHubAppProvider{
+		apiURL: srv.URL,
+		client: srv.Client(),
+	}
+	key := &domain.DeployKey{
+		ID:             "key",
+		Type:           domain.DeployKeyTypeGitHubApp,
+		Value:          string(keyPEM),
+		AppID:          "7",
+		InstallationID: "42",
+	}
+
+	for i := 0; i < 2; i++ {
+		auth, err := p.AuthMethod(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Could not get AuthMethod:%v", err)
+		}
+		basic, ok := auth.(*githttp.BasicAuth)
+		if !ok || basic.Password != "installation-token" || basic.Username != "x-access-token" {
+			t.Errorf("Unexpected auth %+v", auth)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the token to be cached, got %d requests", requests)
+	}
+
+	// about to expire, refreshed on the next use
+	p.cache.tokens["key/"+key.Value] = cachedToken{token: "old", expires: time.Now().Add(time.Minute)}
+	if _, err := p.AuthMethod(context.Background(), key); err != nil {
+		t.Fatalf("Could not get AuthMethod:%v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected the token to be refreshed, got %d requests", requests)
+	}
+}
+
+func TestAuthMethodByType(t *testing.T) {
+	g := &GitProvider{
+		credentials: map[domain.DeployKeyType]CredentialProvider{
+			domain.DeployKeyTypeToken: &staticTokenProvider{},
+		},
+	}
+	auth, err := g.authMethod(context.Background(), &domain.DeployKey{Type: domain.DeployKeyTypeToken, Value: "secret"})
+	if err != nil {
+		t.Fatalf("Could not get AuthMethod:%v", err)
+	}
+	if basic, ok := auth.(*githttp.BasicAuth); !ok || basic.Password != "secret" || basic.Username == "" {
+		t.Errorf("Unexpected auth %+v", auth)
+	}
+
+	// keys without type are ssh keys
+	if _, err := g.authMethod(context.Background(), &domain.DeployKey{Name: "legacy"}); err == nil {
+		t.Errorf("Expected an error for a missing provider")
+	}
+}
@@ -3,20 +3,25 @@ package github
 import (
 	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	sshstd "golang.org/x/crypto/ssh"
-
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	gitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/storage/memory"
 
 	"github.com/nomad-ops/nomad-ops/backend/application"
@@ -32,10 +37,23 @@ type GitProvider struct {
 	repoLock sync.Mutex
 	repos    map[string]*git.Repository
 	keyRepo  application.KeyRepo
+	// credentials by key type
+	credentials map[domain.DeployKeyType]CredentialProvider
 }
 
 type GitProviderConfig struct {
 	ReposDir string
+	// GitHubAPIURL is used to issue installation tokens of GitHub App keys
+	GitHubAPIURL string
+	// AzureMetadataURL and GCPMetadataURL are the metadata services used by identity keys, defaults to the well known addresses
+	AzureMetadataURL string
+	GCPMetadataURL   string
+	// Transport is used for git fetches and the API of GitHub, it is installed for all http git urls
+	Transport *http.Transport
+	// Renderers generate the jobs of sources that select them by name instead of reading job files
+	Renderers map[string]application.Renderer
+	// Validator checks all jobs of the desired state, nil disables the validation
+	Validator application.JobValidator
 }
 
 func CreateGitProvider(ctx context.Context,
@@ -44,6 +62,37 @@ func CreateGitProvider(ctx context.Context,
 	parser application.JobParser,
 	keyRepo application.KeyRepo) (*GitProvider, error) {
 
+	if cfg.GitHubAPIURL == "" {
+		cfg.GitHubAPIURL = "https://api.github.com"
+	}
+	if cfg.AzureMetadataURL == "" {
+		cfg.AzureMetadataURL = "http://169.254.169.254"
+	}
+	if cfg.GCPMetadataURL == "" {
+		cfg.GCPMetadataURL = "http://metadata.google.internal"
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport.(*http.Transport)
+	}
+	// go-git has no per fetch transport, the client is replaced for all fetches
+	gitClient := githttp.NewClient(&http.Client{
+		Transport: cfg.Transport,
+	})
+	gitclient.InstallProtocol("http", gitClient)
+	gitclient.InstallProtocol("https", gitClient)
+
+	client := &http.Client{
+		Timeout:   time.Second * 30,
+		Transport: cfg.Transport,
+	}
+	// metadata services are link local and never proxied
+	metadataTransport := cfg.Transport.Clone()
+	metadataTransport.Proxy = nil
+	metadataClient := &http.Client{
+		Timeout:   time.Second * 30,
+		Transport: metadataTransport,
+	}
+
 	t := &GitProvider{
 		ctx:     ctx,
 		logger:  logger,
@@ -51,31 +100,40 @@ func CreateGitProvider(ctx context.Context,
 		parser:  parser,
 		repos:   map[string]*git.Repository{},
 		keyRepo: keyRepo,
+		credentials: map[domain.DeployKeyType]CredentialProvider{
+			domain.DeployKeyTypeSSH:   &sshKeyProvider{},
+			domain.DeployKeyTypeToken: &staticTokenProvider{},
+			domain.DeployKeyTypeGitHubApp: &gitHubAppProvider{
+				apiURL: cfg.GitHubAPIURL,
+				client: client,
+			},
+			domain.DeployKeyTypeAzureIdentity: &azureIdentityProvider{
+				metadataURL: cfg.AzureMetadataURL,
+				client:      metadataClient,
+			},
+			domain.DeployKeyTypeGCPIdentity: &gcpIdentityProvider{
+				metadataURL: cfg.GCPMetadataURL,
+				client:      metadataClient,
+			},
+		},
 	}
 
 	return t, nil
 }
 
 func (g *GitProvider) FetchDesiredState(ctx context.Context, src *domain.Source) (*application.DesiredState, error) {
+	// pinned sources, e.g. stages of a promotion pipeline, are fetched without the cached checkout of the branch
+	if src.Revision != "" {
+		if plumbing.IsHash(src.Revision) {
+			return g.FetchDesiredStateAtCommit(ctx, src, src.Revision)
+		}
+		return g.FetchDesiredStateAt(ctx, src, plumbing.NewTagReferenceName(src.Revision).String())
+	}
 	g.repoLock.Lock()
 	defer g.repoLock.Unlock()
-	var auth transport.AuthMethod
-	if src.DeployKeyID != "" {
-
-		key, err := g.keyRepo.GetKey(ctx, src.DeployKeyID)
-		if err != nil {
-			g.logger.LogError(ctx, "Could not GetKey:%v", err)
-			return nil, err
-		}
-
-		publicKeys, err := ssh.NewPublicKeys("git", []byte(key.Value), "")
-		if err != nil {
-			g.logger.LogError(ctx, "Could not NewPublicKeys:%v", err)
-			return nil, err
-		}
-
-		publicKeys.HostKeyCallback = sshstd.InsecureIgnoreHostKey()
-		auth = publicKeys
+	auth, err := g.sourceAuth(ctx, src)
+	if err != nil {
+		return nil, err
 	}
 
 	repoDir := filepath.Join(g.cfg.ReposDir, fmt.Sprintf("%x", md5.Sum([]byte(src.URL))), path.Base(src.URL))
@@ -89,18 +147,20 @@ func (g *GitProvider) FetchDesiredState(ctx context.Context, src *domain.Source)
 			return nil, err
 		}
 		g.logger.LogTrace(ctx, "Pulling...")
-		err = wt.PullContext(ctx, &git.PullOptions{
-			Auth:          auth,
-			ReferenceName: plumbing.NewBranchReferenceName(src.Branch),
-			SingleBranch:  true,
-			Progress:      nil,
+		err = application.RunPhase(ctx, domain.ReconcilePhaseFetch, func(ctx context.Context) error {
+			return wt.PullContext(ctx, &git.PullOptions{
+				Auth:          auth,
+				ReferenceName: plumbing.NewBranchReferenceName(src.Branch),
+				SingleBranch:  true,
+				Progress:      nil,
+			})
 		})
 		if err == git.NoErrAlreadyUpToDate {
 			g.logger.LogTrace(ctx, "Already up to date")
 		}
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			g.logger.LogError(ctx, "PullContext failed:%v", err)
-			return nil, err
+			return nil, gitAuthError(err)
 		}
 		g.logger.LogTrace(ctx, "Getting last commit...")
 
@@ -118,18 +178,23 @@ func (g *GitProvider) FetchDesiredState(ctx context.Context, src *domain.Source)
 		g.logger.LogTrace(ctx, "Getting last commit...%v", gitInfo.GitCommit)
 	} else {
 
-		repo, err := git.CloneContext(ctx, memory.NewStorage(), memfs.New(), &git.CloneOptions{
-			URL: src.URL,
-			// Depth:         1, https://github.com/go-git/go-git/issues/207
-			NoCheckout:    false,
-			Auth:          auth,
-			Progress:      nil,
-			SingleBranch:  true,
-			ReferenceName: plumbing.NewBranchReferenceName(src.Branch),
+		var repo *git.Repository
+		err := application.RunPhase(ctx, domain.ReconcilePhaseFetch, func(ctx context.Context) error {
+			var err error
+			repo, err = git.CloneContext(ctx, memory.NewStorage(), memfs.New(), &git.CloneOptions{
+				URL: src.URL,
+				// Depth:         1, https://github.com/go-git/go-git/issues/207
+				NoCheckout:    false,
+				Auth:          auth,
+				Progress:      nil,
+				SingleBranch:  true,
+				ReferenceName: plumbing.NewBranchReferenceName(src.Branch),
+			})
+			return err
 		})
 		if err != nil {
 			g.logger.LogError(ctx, "Could not clone:%s - %v", src.URL, err)
-			return nil, err
+			return nil, gitAuthError(err)
 		}
 
 		wt, err = repo.Worktree()
@@ -151,71 +216,355 @@ func (g *GitProvider) FetchDesiredState(ctx context.Context, src *domain.Source)
 		g.repos[src.ID] = repo
 	}
 
-	pathInfo, err := wt.Filesystem.Stat(src.Path)
+	return g.readDesiredState(ctx, src, wt, gitInfo)
+}
+
+// FetchDesiredStateAt reads the desired state of the source at the ref, e.g. the head of a pull request,
+// without touching the checkout used for syncing
+func (g *GitProvider) FetchDesiredStateAt(ctx context.Context, src *domain.Source, ref string) (*application.DesiredState, error) {
+	return g.fetchDesiredState(ctx, src, ref, plumbing.ZeroHash)
+}
+
+// FetchDesiredStateAtCommit reads the desired state of the source at a commit of its branch,
+// the head of the branch if commit is empty
+func (g *GitProvider) FetchDesiredStateAtCommit(ctx context.Context, src *domain.Source, commit string) (*application.DesiredState, error) {
+	hash := plumbing.ZeroHash
+	if commit != "" {
+		if !plumbing.IsHash(commit) {
+			return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("invalid commit %s", commit))
+		}
+		hash = plumbing.NewHash(commit)
+	}
+	return g.fetchDesiredState(ctx, src, plumbing.NewBranchReferenceName(src.Branch).String(), hash)
+}
+
+// fetchDesiredState fetches the ref into a new in-memory repository and reads the desired state at the commit,
+// at the fetched ref if the commit is zero
+func (g *GitProvider) fetchDesiredState(ctx context.Context, src *domain.Source, ref string, commit plumbing.Hash) (*application.DesiredState, error) {
+	wt, head, err := g.fetchWorktree(ctx, src, ref, commit)
 	if err != nil {
-		g.logger.LogError(ctx, "Could not stat Path in repo:%v - %v", src.Path, err)
 		return nil, err
 	}
+	return g.readDesiredState(ctx, src, wt, application.GitInfo{
+		GitCommit: head.String(),
+	})
+}
+
+// fetchWorktree fetches the ref into a new in-memory repository and checks out the commit, the fetched ref
+// if the commit is zero. The checked out commit is returned.
+func (g *GitProvider) fetchWorktree(ctx context.Context, src *domain.Source, ref string, commit plumbing.Hash) (*git.Worktree, plumbing.Hash, error) {
+	auth, err := g.sourceAuth(ctx, src)
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	remote, err := repo.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{src.URL},
+	})
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	local := plumbing.NewBranchReferenceName("nomad-ops-preview")
+	err = application.RunPhase(ctx, domain.ReconcilePhaseFetch, func(ctx context.Context) error {
+		return remote.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", ref, local))},
+			Auth:     auth,
+		})
+	})
+	if err != nil {
+		g.logger.LogError(ctx, "Could not fetch %s of %s - %v", ref, src.URL, err)
+		return nil, plumbing.ZeroHash, gitAuthError(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	checkout := &git.CheckoutOptions{
+		Branch: local,
+	}
+	if !commit.IsZero() {
+		if _, err := repo.CommitObject(commit); err != nil {
+			return nil, plumbing.ZeroHash, domain.WithErrorCode(domain.ErrorCodeNotFound,
+				fmt.Errorf("commit %s is not part of %s:%w", commit, ref, err))
+		}
+		checkout = &git.CheckoutOptions{
+			Hash: commit,
+		}
+	}
+	err = wt.Checkout(checkout)
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	return wt, head.Hash(), nil
+}
+
+// sourceAuth returns the credentials of the deploy key of the source, nil if it has none
+func (g *GitProvider) sourceAuth(ctx context.Context, src *domain.Source) (transport.AuthMethod, error) {
+	if src.DeployKeyID == "" {
+		return nil, nil
+	}
+	key, err := g.keyRepo.GetKey(ctx, src.DeployKeyID)
+	if err != nil {
+		g.logger.LogError(ctx, "Could not GetKey:%v", err)
+		return nil, err
+	}
+
+	auth, err := g.authMethod(ctx, key)
+	if err != nil {
+		g.logger.LogError(ctx, "Could not get credentials of key %s:%v", key.Name, err)
+		return nil, domain.WithErrorCode(domain.ErrorCodeGitAuthFailed, err)
+	}
+	return auth, nil
+}
+
+// readDesiredState parses the job files at the path of the source in the worktree
+func (g *GitProvider) readDesiredState(ctx context.Context,
+	src *domain.Source,
+	wt *git.Worktree,
+	gitInfo application.GitInfo) (*application.DesiredState, error) {
 
 	desiredState := &application.DesiredState{
 		GitInfo: gitInfo,
 		Jobs:    map[string]*application.JobInfo{},
 	}
+	// file declaring the job, by <namespace>/<name>
+	jobFiles := map[string]string{}
 
-	if pathInfo.IsDir() {
-		fileInfos, err := wt.Filesystem.ReadDir(src.Path)
+	src, err := g.applyVarsSchema(ctx, src, wt.Filesystem)
+	if err != nil {
+		return nil, err
+	}
+	err = g.readJobs(ctx, src, wt.Filesystem, "", desiredState, jobFiles)
+	if err != nil {
+		return nil, err
+	}
+	// the path exists, readJobs did not fail
+	pathInfo, err := wt.Filesystem.Stat(src.Path)
+	if err != nil {
+		return nil, err
+	}
+	err = g.addBundles(ctx, src, wt.Filesystem, bundleDir(src.Path, pathInfo.IsDir()), desiredState, jobFiles)
+	if err != nil {
+		return nil, err
+	}
+	if g.cfg.Validator != nil {
+		names := make([]string, 0, len(desiredState.Jobs))
+		for name := range desiredState.Jobs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			err = g.cfg.Validator.ValidateJob(ctx, src, desiredState.Jobs[name])
+			if err != nil {
+				g.logger.LogError(ctx, "Invalid job %s in %s:%v", name, jobFiles[jobFileKey(src, desiredState.Jobs[name])], err)
+				return nil, err
+			}
+		}
+	}
+	if g.logger.IsTraceEnabled(ctx) {
+		g.logger.LogTrace(ctx, "desiredState...%v", log.ToJSONString(desiredState))
+	}
+
+	return desiredState, nil
+}
+
+// readJobs adds the jobs at the path of the source in fs to the desired state,
+// the prefix is added to the file names of the jobs
+func (g *GitProvider) readJobs(ctx context.Context,
+	src *domain.Source,
+	fs billy.Filesystem,
+	fileNamePrefix string,
+	desiredState *application.DesiredState,
+	jobFiles map[string]string) error {
+
+	pathInfo, err := fs.Stat(src.Path)
+	if err != nil {
+		g.logger.LogError(ctx, "Could not stat Path in repo:%v - %v", src.Path, err)
+		return err
+	}
+
+	if src.Renderer != "" {
+		err = g.renderDesiredState(ctx, src, fs, fileNamePrefix, desiredState, jobFiles)
+		if err != nil {
+			return err
+		}
+	} else if pathInfo.IsDir() {
+		fileInfos, err := fs.ReadDir(src.Path)
 		if err != nil {
 			g.logger.LogError(ctx, "wt.Filesystem.ReadDir failed:%v", err)
-			return nil, err
+			return err
 		}
 
 		for _, file := range fileInfos {
-			if !strings.HasSuffix(file.Name(), ".nomad") && !strings.HasSuffix(file.Name(), ".hcl") {
+			isJSON := strings.HasSuffix(file.Name(), ".nomad.json")
+			if !strings.HasSuffix(file.Name(), ".nomad") && !strings.HasSuffix(file.Name(), ".hcl") && !isJSON {
 				g.logger.LogTrace(ctx, "ignoring file:%v", file.Name())
 				continue
 			}
-			f, err := wt.Filesystem.Open(wt.Filesystem.Join(src.Path, file.Name()))
+			f, err := fs.Open(fs.Join(src.Path, file.Name()))
 			if err != nil {
-				return nil, err
+				return err
 			}
 
 			jobData, err := io.ReadAll(f)
 			if err != nil {
-				return nil, err
+				return err
 			}
 
-			j, err := g.parser.ParseJob(ctx, string(jobData))
+			if isJSON {
+				// jobs in the JSON format of the Nomad API, e.g. exported by the import
+				job, err := parseJSONJob(string(jobData))
+				if err != nil {
+					return domain.WithErrorCode(domain.ErrorCodeParseError, fmt.Errorf("invalid job in %s: %w", file.Name(), err))
+				}
+				err = addJob(src, desiredState, jobFiles, fileNamePrefix+file.Name(), &application.JobInfo{Job: job})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			err = g.addJobFile(ctx, src, fileNamePrefix+file.Name(), jobData, desiredState, jobFiles)
 			if err != nil {
-				g.logger.LogError(ctx, "Could not parse JobFile:%v - %v", file.Name(), err)
-				return nil, err
+				return err
 			}
-			j.GitInfo = gitInfo
-			desiredState.Jobs[*j.Name] = j
 		}
 	} else {
-		f, err := wt.Filesystem.Open(src.Path)
+		f, err := fs.Open(src.Path)
 		if err != nil {
 			g.logger.LogError(ctx, " wt.Filesystem.Open(*src.Path) failed:%v", err)
-			return nil, err
+			return err
 		}
 
 		jobData, err := io.ReadAll(f)
 		if err != nil {
 			g.logger.LogError(ctx, " wt.Filesystem.Open(*src.Path).ReadAll failed:%v", err)
-			return nil, err
+			return err
 		}
 
-		j, err := g.parser.ParseJob(ctx, string(jobData))
+		err = g.addJobFile(ctx, src, fileNamePrefix+src.Path, jobData, desiredState, jobFiles)
 		if err != nil {
-			g.logger.LogError(ctx, "Could not parse JobFile:%v - %v", src.Path, err)
-			return nil, err
+			return err
 		}
-		j.GitInfo = gitInfo
-		desiredState.Jobs[*j.Name] = j
 	}
-	if g.logger.IsTraceEnabled(ctx) {
-		g.logger.LogTrace(ctx, "desiredState...%v", log.ToJSONString(desiredState))
+	return nil
+}
+
+// authMethod returns the credentials of the key, keys without type are ssh keys
+func (g *GitProvider) authMethod(ctx context.Context, key *domain.DeployKey) (transport.AuthMethod, error) {
+	keyType := key.Type
+	if keyType == "" {
+		keyType = domain.DeployKeyTypeSSH
 	}
+	p, ok := g.credentials[keyType]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s of key %s", keyType, key.Name)
+	}
+	return p.AuthMethod(ctx, key)
+}
 
-	return desiredState, nil
+// HTTPAuth returns the credentials of an http key, they are used for the APIs of the git hosts as well
+func (g *GitProvider) HTTPAuth(ctx context.Context, key *domain.DeployKey) (githttp.AuthMethod, error) {
+	auth, err := g.authMethod(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	httpAuth, ok := auth.(githttp.AuthMethod)
+	if !ok {
+		return nil, fmt.Errorf("key %s is no http key", key.Name)
+	}
+	return httpAuth, nil
+}
+
+// addJobFile parses all jobs of a job file into the desired state. Job names must be unique within a source.
+func (g *GitProvider) addJobFile(ctx context.Context,
+	src *domain.Source,
+	fileName string,
+	data []byte,
+	desiredState *application.DesiredState,
+	jobFiles map[string]string) error {
+
+	for _, jobData := range splitJobFile(string(data)) {
+		var j *application.JobInfo
+		err := application.RunPhase(ctx, domain.ReconcilePhaseParse, func(ctx context.Context) error {
+			var err error
+			j, err = g.parser.ParseJob(ctx, jobData, application.ParseJobOptions{
+				Strict:       src.StrictParsing,
+				HCL1Fallback: src.HCL1Fallback,
+			})
+			return err
+		})
+		if err != nil {
+			g.logger.LogError(ctx, "Could not parse JobFile:%v - %v", fileName, err)
+			return err
+		}
+		err = addJob(src, desiredState, jobFiles, fileName, j)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addJob adds a parsed job to the desired state, failing if another file declared the job in the same namespace
+// already. Like the jobs of the cluster state, the jobs are keyed by name, unless a name is declared in several
+// namespaces, then by <namespace>/<name>.
+func addJob(src *domain.Source, desiredState *application.DesiredState, jobFiles map[string]string, fileName string, j *application.JobInfo) error {
+	j.GitInfo = desiredState.GitInfo
+
+	key := jobFileKey(src, j)
+	if other, ok := jobFiles[key]; ok {
+		return domain.WithErrorCode(domain.ErrorCodeJobConflict,
+			fmt.Errorf("job %s in namespace %s is declared more than once: %s and %s", *j.Name, jobNamespace(src, j), other, fileName))
+	}
+	jobFiles[key] = fileName
+
+	name := *j.Name
+	if other, ok := desiredState.Jobs[name]; ok {
+		delete(desiredState.Jobs, name)
+		desiredState.Jobs[jobFileKey(src, other)] = other
+	}
+	for k := range jobFiles {
+		if k != key && strings.SplitN(k, "/", 2)[1] == name {
+			desiredState.Jobs[key] = j
+			return nil
+		}
+	}
+	desiredState.Jobs[name] = j
+	return nil
+}
+
+// jobFileKey is the <namespace>/<name> of the job
+func jobFileKey(src *domain.Source, j *application.JobInfo) string {
+	return jobNamespace(src, j) + "/" + *j.Name
+}
+
+// jobNamespace is the namespace the job is deployed to, the namespace of the source overrides the one of the job
+func jobNamespace(src *domain.Source, j *application.JobInfo) string {
+	if src.Namespace != "" {
+		return src.Namespace
+	}
+	if j.Namespace != nil && *j.Namespace != "" {
+		return *j.Namespace
+	}
+	return "default"
+}
+
+// gitAuthError marks errors caused by missing or rejected credentials
+func gitAuthError(err error) error {
+	if errors.Is(err, transport.ErrAuthenticationRequired) ||
+		errors.Is(err, transport.ErrAuthorizationFailed) ||
+		errors.Is(err, transport.ErrInvalidAuthMethod) {
+		return domain.WithErrorCode(domain.ErrorCodeGitAuthFailed, err)
+	}
+	return err
 }
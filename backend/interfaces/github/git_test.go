@@ -0,0 +1,70 @@
+package github
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestAddJob(t *testing.T) {
+	job := func(ns, name string) *application.JobInfo {
+		j := &api.Job{Name: log.ToStrPtr(name)}
+		if ns != "" {
+			j.Namespace = log.ToStrPtr(ns)
+		}
+		return &application.JobInfo{Job: j}
+	}
+	desiredState := &application.DesiredState{Jobs: map[string]*application.JobInfo{}}
+	jobFiles := map[string]string{}
+	add := func(src *domain.Source, file string, j *application.JobInfo) error {
+		return addJob(src, desiredState, jobFiles, file, j)
+	}
+	keys := func() string {
+		res := []string{}
+		for k := range desiredState.Jobs {
+			res = append(res, k)
+		}
+		sort.Strings(res)
+		return fmt.Sprint(res)
+	}
+
+	src := &domain.Source{}
+	for file, j := range map[string]*application.JobInfo{
+		"web.nomad":     job("", "web"),
+		"api.nomad":     job("a", "api"),
+		"web-b.nomad":   job("b", "web"),
+		"web-c.nomad":   job("c", "web"),
+		"worker.nomad":  job("a", "worker"),
+		"workers.nomad": job("b", "workers"),
+	} {
+		if err := add(src, file, j); err != nil {
+			t.Fatalf("Could not add %s:%v", file, err)
+		}
+	}
+	if got := keys(); got != "[api b/web c/web default/web worker workers]" {
+		t.Errorf("Unexpected keys %s", got)
+	}
+
+	err := add(src, "web-2.nomad", job("default", "web"))
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeJobConflict {
+		t.Errorf("Expected a conflict of web in the default namespace, got %v", err)
+	}
+
+	// the namespace of the source overrides the one of the jobs
+	desiredState.Jobs = map[string]*application.JobInfo{}
+	jobFiles = map[string]string{}
+	src = &domain.Source{Namespace: "team"}
+	if err := add(src, "web.nomad", job("a", "web")); err != nil {
+		t.Fatalf("Could not add web:%v", err)
+	}
+	err = add(src, "web-b.nomad", job("b", "web"))
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeJobConflict {
+		t.Errorf("Expected a conflict of web in the namespace of the source, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// renderDesiredState adds the jobs generated by the renderer of the source. Renderers work on a directory,
+// so the worktree is copied to a temporary directory first.
+func (g *GitProvider) renderDesiredState(ctx context.Context,
+	src *domain.Source,
+	fs billy.Filesystem,
+	fileNamePrefix string,
+	desiredState *application.DesiredState,
+	jobFiles map[string]string) error {
+
+	r, ok := g.cfg.Renderers[src.Renderer]
+	if !ok {
+		return domain.WithErrorCode(domain.ErrorCodeRenderFailed, fmt.Errorf("unknown renderer %s", src.Renderer))
+	}
+
+	dir, err := os.MkdirTemp("", "nomad-ops-render-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	err = copyFilesystem(fs, "/", dir)
+	if err != nil {
+		return fmt.Errorf("could not copy the worktree: %w", err)
+	}
+
+	var jobs []application.RenderedJob
+	err = application.RunPhase(ctx, domain.ReconcilePhaseRender, func(ctx context.Context) error {
+		var err error
+		jobs, err = r.Render(ctx, application.RenderRequest{
+			Dir:    dir,
+			Path:   src.Path,
+			Commit: desiredState.GitInfo.GitCommit,
+			Source: src,
+		})
+		return err
+	})
+	if err != nil {
+		g.logger.LogError(ctx, "Could not render %s with %s:%v", src.Path, src.Renderer, err)
+		return err
+	}
+	for _, j := range jobs {
+		if !isJSONJob(j.Spec) {
+			err = g.addJobFile(ctx, src, fileNamePrefix+j.File, []byte(j.Spec), desiredState, jobFiles)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		job, err := parseJSONJob(j.Spec)
+		if err != nil {
+			return domain.WithErrorCode(domain.ErrorCodeParseError, fmt.Errorf("invalid job in %s: %w", j.File, err))
+		}
+		err = addJob(src, desiredState, jobFiles, fileNamePrefix+j.File, &application.JobInfo{Job: job})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFilesystem copies the regular files of the directory of fs to dir
+func copyFilesystem(fs billy.Filesystem, from, dir string) error {
+	infos, err := fs.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		src := fs.Join(from, info.Name())
+		dst := filepath.Join(dir, info.Name())
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				continue
+			}
+			if err := os.Mkdir(dst, 0o700); err != nil {
+				return err
+			}
+			if err := copyFilesystem(fs, src, dst); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if err := copyFile(fs, src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(fs billy.Filesystem, src, dst string) error {
+	in, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func isJSONJob(spec string) bool {
+	return strings.HasPrefix(strings.TrimSpace(spec), "{")
+}
+
+// parseJSONJob decodes a job in the JSON format of the Nomad API, optionally wrapped in {"Job": ...}
+// like the output of `nomad job inspect`
+func parseJSONJob(spec string) (*api.Job, error) {
+	wrapped := struct {
+		Job *api.Job
+	}{}
+	err := json.Unmarshal([]byte(spec), &wrapped)
+	if err != nil {
+		return nil, err
+	}
+	job := wrapped.Job
+	if job == nil {
+		job = &api.Job{}
+		if err := json.Unmarshal([]byte(spec), job); err != nil {
+			return nil, err
+		}
+	}
+	if job.ID == nil || *job.ID == "" {
+		return nil, fmt.Errorf("the job has no ID")
+	}
+	if job.Name == nil || *job.Name == "" {
+		job.Name = job.ID
+	}
+	return job, nil
+}
@@ -0,0 +1,175 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// snapshotPushAttempts bounds the retries of pushes rejected because other sources pushed to the branch meanwhile
+const snapshotPushAttempts = 3
+
+// WriteSnapshot commits the files of the snapshot to its directory on the branch and pushes it,
+// the branch is created if it does not exist. Nothing is committed if the files did not change.
+// The deploy key of the source is used, it needs write access.
+func (g *GitProvider) WriteSnapshot(ctx context.Context, src *domain.Source, snapshot application.Snapshot) error {
+	auth, err := g.sourceAuth(ctx, src)
+	if err != nil {
+		return err
+	}
+	url := snapshot.URL
+	if url == "" {
+		url = src.URL
+	}
+	for attempt := 1; ; attempt++ {
+		err = g.writeSnapshot(ctx, url, auth, snapshot)
+		if !pushRejected(err) || attempt == snapshotPushAttempts {
+			return err
+		}
+		g.logger.LogInfo(ctx, "Branch %s of %s changed meanwhile, retrying...", snapshot.Branch, url)
+	}
+}
+
+func (g *GitProvider) writeSnapshot(ctx context.Context, url string, auth transport.AuthMethod, snapshot application.Snapshot) error {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		return err
+	}
+	remote, err := repo.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{url},
+	})
+	if err != nil {
+		return err
+	}
+	branch := plumbing.NewBranchReferenceName(snapshot.Branch)
+	refSpec := config.RefSpec(fmt.Sprintf("+%s:%s", branch, branch))
+	err = remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []config.RefSpec{refSpec},
+		Auth:     auth,
+	})
+	exists := true
+	if errors.Is(err, git.NoMatchingRefSpecError{}) || errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		exists = false
+		err = nil
+	}
+	if err != nil {
+		g.logger.LogError(ctx, "Could not fetch %s of %s - %v", branch, url, err)
+		return gitAuthError(err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if exists {
+		err = wt.Checkout(&git.CheckoutOptions{Branch: branch})
+	} else {
+		// the first commit starts an orphan branch
+		err = repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch))
+	}
+	if err != nil {
+		return err
+	}
+
+	// files of jobs that were deleted must be removed
+	err = removeAll(wt, snapshot.Dir)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for name := range snapshot.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := path.Join(snapshot.Dir, name)
+		err = util.WriteFile(wt.Filesystem, p, snapshot.Files[name], 0o644)
+		if err != nil {
+			return err
+		}
+		_, err = wt.Add(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return err
+	}
+	if status.IsClean() {
+		g.logger.LogTrace(ctx, "Snapshot %s of %s did not change", snapshot.Dir, url)
+		return nil
+	}
+	_, err = wt.Commit(snapshot.Message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  snapshot.AuthorName,
+			Email: snapshot.AuthorEmail,
+			When:  time.Now(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", branch, branch))},
+		Auth:       auth,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		g.logger.LogError(ctx, "Could not push %s to %s - %v", branch, url, err)
+		return err
+	}
+	return nil
+}
+
+// removeAll removes the files of the directory from the worktree and the index
+func removeAll(wt *git.Worktree, dir string) error {
+	infos, err := wt.Filesystem.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		p := path.Join(dir, info.Name())
+		if info.IsDir() {
+			err = removeAll(wt, p)
+		} else {
+			_, err = wt.Remove(p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushRejected returns true if the push failed because the branch moved since it was fetched
+func pushRejected(err error) bool {
+	if err == nil {
+		return false
+	}
+	// go-git and the servers only report this as text
+	msg := err.Error()
+	return strings.Contains(msg, "non-fast-forward") || strings.Contains(msg, "fetch first")
+}
@@ -0,0 +1,109 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &GitProvider{logger: log.NewSimpleLogger(false, "Test")}
+	src := &domain.Source{Name: "web", URL: dir}
+	snapshot := application.Snapshot{
+		Branch: "deployed",
+		Dir:    "web",
+		Files: map[string][]byte{
+			"default/web.json": []byte("{}\n"),
+			"default/api.json": []byte("{}\n"),
+		},
+		Message:     "Snapshot of web at c1",
+		AuthorName:  "nomad-ops",
+		AuthorEmail: "nomad-ops@localhost",
+	}
+
+	files := func() string {
+		ref, err := repo.Reference(plumbing.NewBranchReferenceName("deployed"), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := []string{}
+		err = tree.Files().ForEach(func(f *object.File) error {
+			res = append(res, f.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("%s %v", commit.Message, res)
+	}
+	commits := func() int {
+		iter, err := repo.Log(&git.LogOptions{From: plumbing.ZeroHash, All: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for {
+			_, err := iter.Next()
+			if err == io.EOF {
+				return n
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+	}
+
+	// the branch is created
+	if err := g.WriteSnapshot(context.Background(), src, snapshot); err != nil {
+		t.Fatalf("Could not WriteSnapshot:%v", err)
+	}
+	if files() != "Snapshot of web at c1 [web/default/api.json web/default/web.json]" {
+		t.Errorf("Unexpected snapshot %s", files())
+	}
+
+	// unchanged snapshots are not committed
+	if err := g.WriteSnapshot(context.Background(), src, snapshot); err != nil {
+		t.Fatalf("Could not WriteSnapshot:%v", err)
+	}
+	if commits() != 1 {
+		t.Errorf("Expected no commit of an unchanged snapshot, got %d commits", commits())
+	}
+
+	// deleted jobs are removed, other sources are kept
+	if err := g.WriteSnapshot(context.Background(), &domain.Source{Name: "db", URL: dir}, application.Snapshot{
+		Branch: "deployed", Dir: "db", Files: map[string][]byte{"default/db.json": []byte("{}\n")}, Message: "Snapshot of db at c1",
+	}); err != nil {
+		t.Fatalf("Could not WriteSnapshot:%v", err)
+	}
+	delete(snapshot.Files, "default/api.json")
+	snapshot.Message = "Snapshot of web at c2"
+	if err := g.WriteSnapshot(context.Background(), src, snapshot); err != nil {
+		t.Fatalf("Could not WriteSnapshot:%v", err)
+	}
+	if files() != "Snapshot of web at c2 [db/default/db.json web/default/web.json]" {
+		t.Errorf("Unexpected snapshot %s", files())
+	}
+}
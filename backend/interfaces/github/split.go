@@ -0,0 +1,77 @@
+package github
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// splitJobFile splits a job file with multiple job blocks into one job file per job.
+// All other top level items, e.g. variable and locals blocks, are added to every job file.
+// Files with a single job and files that are not valid HCL2 are returned unchanged,
+// the Nomad API reports the errors of the latter when parsing them.
+func splitJobFile(content string) []string {
+	var shared, jobs []string
+	items, ok := topLevelItems(content)
+	if !ok {
+		return []string{content}
+	}
+	for _, item := range items {
+		if item.job {
+			jobs = append(jobs, item.src)
+			continue
+		}
+		shared = append(shared, item.src)
+	}
+	if len(jobs) <= 1 {
+		return []string{content}
+	}
+
+	res := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		items := append(append([]string{}, shared...), job)
+		res = append(res, strings.Join(items, "\n\n")+"\n")
+	}
+	return res
+}
+
+type topLevelItem struct {
+	src string
+	job bool
+	pos int
+}
+
+// topLevelItems returns the top level blocks and attributes of a HCL file in their order, without comments
+func topLevelItems(content string) ([]topLevelItem, bool) {
+	f, diags := hclsyntax.ParseConfig([]byte(content), "job.nomad.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, false
+	}
+	body, ok := f.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, false
+	}
+
+	var items []topLevelItem
+	for _, b := range body.Blocks {
+		r := b.Range()
+		items = append(items, topLevelItem{
+			src: string(r.SliceBytes(f.Bytes)),
+			job: b.Type == "job",
+			pos: r.Start.Byte,
+		})
+	}
+	for _, a := range body.Attributes {
+		r := a.SrcRange
+		items = append(items, topLevelItem{
+			src: string(r.SliceBytes(f.Bytes)),
+			pos: r.Start.Byte,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].pos < items[j].pos
+	})
+	return items, true
+}
@@ -0,0 +1,59 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+const multiJobFile = `# shared variable
+variable "image" {
+  default = "nginx:${var.version}"
+}
+
+job "web" {
+  group "web" {
+    task "web" {
+      config {
+        image = "${var.image}" // not a } brace
+        args  = ["}", "{"]
+      }
+      template {
+        data = <<EOT
+{{ range service "api" }}}
+EOT
+      }
+    }
+  }
+}
+
+/* job "commented" {} */
+job "api" {
+  meta = { a = "b" }
+}
+`
+
+func TestSplitJobFile(t *testing.T) {
+	jobs := splitJobFile(multiJobFile)
+	if len(jobs) != 2 {
+		t.Fatalf("Expected 2 jobs, got %d:%v", len(jobs), jobs)
+	}
+	for i, name := range []string{`job "web"`, `job "api"`} {
+		if !strings.HasPrefix(jobs[i], `variable "image" {`) {
+			t.Errorf("Expected the variable in job %d:%s", i, jobs[i])
+		}
+		if !strings.Contains(jobs[i], name) {
+			t.Errorf("Expected %s in job %d:%s", name, i, jobs[i])
+		}
+		if strings.Contains(jobs[i], "commented") {
+			t.Errorf("Expected comments to be dropped in job %d:%s", i, jobs[i])
+		}
+	}
+	if !strings.HasSuffix(jobs[0], "EOT\n      }\n    }\n  }\n}\n") {
+		t.Errorf("Unexpected end of first job:%s", jobs[0])
+	}
+
+	single := `job "web" {}`
+	if jobs := splitJobFile(single); len(jobs) != 1 || jobs[0] != single {
+		t.Errorf("Expected a single job file to be unchanged, got %v", jobs)
+	}
+}
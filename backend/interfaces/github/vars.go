@@ -0,0 +1,73 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// FetchVarsSchema returns the schema of the variables of the source at its branch or revision, nil if the
+// repository has none
+func (g *GitProvider) FetchVarsSchema(ctx context.Context, src *domain.Source) (*domain.VarsSchema, error) {
+	ref, commit := plumbing.NewBranchReferenceName(src.Branch).String(), plumbing.ZeroHash
+	if src.Revision != "" {
+		if plumbing.IsHash(src.Revision) {
+			commit = plumbing.NewHash(src.Revision)
+		} else {
+			ref = plumbing.NewTagReferenceName(src.Revision).String()
+		}
+	}
+	wt, _, err := g.fetchWorktree(ctx, src, ref, commit)
+	if err != nil {
+		return nil, err
+	}
+	return readVarsSchema(wt.Filesystem, src)
+}
+
+// readVarsSchema reads the schema next to the job files of the source, nil if there is none
+func readVarsSchema(fs billy.Filesystem, src *domain.Source) (*domain.VarsSchema, error) {
+	pathInfo, err := fs.Stat(src.Path)
+	if err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeNotFound, err)
+	}
+	f, err := fs.Open(fs.Join(bundleDir(src.Path, pathInfo.IsDir()), domain.VarsSchemaFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return domain.ParseVarsSchema(data)
+}
+
+// applyVarsSchema validates the variables of the source against its schema and returns the source with the
+// defaults of the unset variables
+func (g *GitProvider) applyVarsSchema(ctx context.Context, src *domain.Source, fs billy.Filesystem) (*domain.Source, error) {
+	if _, err := fs.Stat(src.Path); err != nil {
+		// reported by reading the jobs
+		return src, nil
+	}
+	schema, err := readVarsSchema(fs, src)
+	if err != nil || schema == nil {
+		return src, err
+	}
+	err = schema.Validate(src.Variables)
+	if err != nil {
+		g.logger.LogError(ctx, "Invalid variables of source %s:%v", src.ID, err)
+		return nil, err
+	}
+	withDefaults := *src
+	withDefaults.Variables = schema.WithDefaults(src.Variables)
+	return &withDefaults, nil
+}
@@ -0,0 +1,75 @@
+package github
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestVarsSchema(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"apps/web.nomad": `job "web" {}`,
+		"apps/" + domain.VarsSchemaFile: `{
+	"properties": {
+		"env": {"enum": ["stage", "prod"]},
+		"replicas": {"type": "integer", "default": 2}
+	},
+	"required": ["env"]
+}`,
+	}
+	for name, content := range files {
+		if err := util.WriteFile(wt.Filesystem, name, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig := &object.Signature{Name: "test", Email: "test@localhost", When: time.Now()}
+	if _, err := wt.Commit("Add web", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+
+	g := &GitProvider{logger: log.NewSimpleLogger(false, "Test"), parser: jobNameParser{}}
+	src := &domain.Source{ID: "src", URL: dir, Branch: "master", Path: "apps/web.nomad"}
+	schema, err := g.FetchVarsSchema(ctx, src)
+	if err != nil {
+		t.Fatalf("Could not FetchVarsSchema:%v", err)
+	}
+	if schema == nil || schema.Properties["env"] == nil || len(schema.Required) != 1 {
+		t.Fatalf("Unexpected schema %+v", schema)
+	}
+
+	// the sync fails while the variables do not match
+	_, err = g.FetchDesiredStateAtCommit(ctx, src, "")
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeSchemaViolation {
+		t.Errorf("Expected a schema violation, got %v", err)
+	}
+	src.Variables = map[string]string{"env": "prod"}
+	state, err := g.FetchDesiredStateAtCommit(ctx, src, "")
+	if err != nil || state.Jobs["web"] == nil {
+		t.Errorf("Expected the job to be read, got %v", err)
+	}
+
+	src.Path = "other"
+	if _, err := g.FetchVarsSchema(ctx, src); domain.ErrorCodeOf(err, "") != domain.ErrorCodeNotFound {
+		t.Errorf("Expected the missing path to be reported, got %v", err)
+	}
+}
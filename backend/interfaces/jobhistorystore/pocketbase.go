@@ -0,0 +1,82 @@
+package jobhistorystore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PocketBaseStore struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PocketBaseStoreConfig
+}
+
+type PocketBaseStoreConfig struct {
+	App core.App
+}
+
+func CreatePocketBaseStore(ctx context.Context,
+	logger log.Logger,
+	cfg PocketBaseStoreConfig) (*PocketBaseStore, error) {
+	t := &PocketBaseStore{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+func (s *PocketBaseStore) SaveJobRevision(ctx context.Context, rev *domain.JobRevision) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="job_history",op="SaveJobRevision"}`).UpdateDuration(time.Now())
+	coll, err := s.cfg.App.Dao().FindCollectionByNameOrId("job_history")
+	if err != nil {
+		return err
+	}
+	record := models.NewRecord(coll)
+	record.Set("source", rev.SourceID)
+	record.Set("namespace", rev.Namespace)
+	record.Set("job", rev.Job)
+	record.Set("commit", rev.Commit)
+	record.Set("hash", rev.Hash)
+	if len(rev.Spec) > 0 {
+		record.Set("spec", types.JsonRaw(rev.Spec))
+	}
+	record.Set("deleted", rev.Deleted)
+	record.Set("timestamp", rev.Timestamp)
+	return s.cfg.App.Dao().SaveRecord(record)
+}
+
+// ListJobRevisions returns the revisions of the jobs of the source recorded until the time, oldest first.
+// A zero time returns all revisions.
+func (s *PocketBaseStore) ListJobRevisions(ctx context.Context, srcID string, until time.Time) ([]*domain.JobRevision, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="job_history",op="ListJobRevisions"}`).UpdateDuration(time.Now())
+	exprs := []dbx.Expression{dbx.HashExp{"source": srcID}}
+	if !until.IsZero() {
+		exprs = append(exprs, dbx.NewExp("timestamp <= {:until}", dbx.Params{
+			"until": until.UTC().Format(types.DefaultDateLayout),
+		}))
+	}
+	records, err := s.cfg.App.Dao().FindRecordsByExpr("job_history", exprs...)
+	if err != nil {
+		return nil, err
+	}
+	res := []*domain.JobRevision{}
+	for _, r := range records {
+		res = append(res, domain.JobRevisionFromRecord(r))
+	}
+	sort.SliceStable(res, func(a, b int) bool {
+		return res[a].Timestamp.Before(res[b].Timestamp)
+	})
+	return res, nil
+}
@@ -0,0 +1,342 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// ErrInvalidCredentials is returned if the user does not exist or the password is wrong
+var ErrInvalidCredentials = fmt.Errorf("invalid credentials")
+
+type Authenticator struct {
+	ctx       context.Context
+	logger    log.Logger
+	cfg       AuthenticatorConfig
+	tlsConfig *tls.Config
+}
+
+type AuthenticatorConfig struct {
+	// URL of the server, ldap://host:389 or ldaps://host:636
+	URL string
+	// StartTLS upgrades an ldap:// connection to TLS before binding
+	StartTLS bool
+	// CACertFile is a PEM file with the CA certificates to trust, optional
+	CACertFile string
+	// InsecureSkipVerify disables the verification of the server certificate
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+
+	// BindDN and BindPassword of the service account used to search for users and groups.
+	// If empty, an anonymous bind is used for searching.
+	BindDN       string
+	BindPassword string
+
+	UserBaseDN string
+	// UserFilter finds the user, %s is replaced with the escaped username, e.g. (uid=%s)
+	UserFilter        string
+	EmailAttribute    string
+	NameAttribute     string
+	MemberOfAttribute string
+	GroupBaseDN       string
+	// GroupFilter finds the groups of a user, %s is replaced with the escaped user DN, e.g. (member=%s).
+	// If empty, the groups are read from the MemberOfAttribute of the user.
+	GroupFilter        string
+	GroupNameAttribute string
+
+	// GroupTeamMapping maps group names or group DNs to nomad-ops teams.
+	// If empty, every group of the user is used as a team.
+	GroupTeamMapping map[string]string
+}
+
+// User is an authenticated LDAP user
+type User struct {
+	DN       string
+	Username string
+	Email    string
+	Name     string
+	// Groups holds the names of all groups of the user
+	Groups []string
+	// Teams holds the nomad-ops teams the user belongs to according to the group mapping
+	Teams []string
+}
+
+func CreateAuthenticator(ctx context.Context,
+	logger log.Logger,
+	cfg AuthenticatorConfig) (*Authenticator, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("need a LDAP url")
+	}
+	if cfg.UserBaseDN == "" {
+		return nil, fmt.Errorf("need a LDAP user base dn")
+	}
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(uid=%s)"
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+	if cfg.NameAttribute == "" {
+		cfg.NameAttribute = "cn"
+	}
+	if cfg.MemberOfAttribute == "" {
+		cfg.MemberOfAttribute = "memberOf"
+	}
+	if cfg.GroupNameAttribute == "" {
+		cfg.GroupNameAttribute = "cn"
+	}
+	if cfg.GroupBaseDN == "" {
+		cfg.GroupBaseDN = cfg.UserBaseDN
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if _, err := goldap.CompileFilter(fmt.Sprintf(cfg.UserFilter, "test")); err != nil {
+		return nil, fmt.Errorf("invalid LDAP user filter:%v", err)
+	}
+	if cfg.GroupFilter != "" {
+		if _, err := goldap.CompileFilter(fmt.Sprintf(cfg.GroupFilter, "test")); err != nil {
+			return nil, fmt.Errorf("invalid LDAP group filter:%v", err)
+		}
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP url:%v", err)
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, fmt.Errorf("unsupported LDAP scheme %q", u.Scheme)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CACertFile != "" {
+		b, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	t := &Authenticator{
+		ctx:       ctx,
+		logger:    logger,
+		cfg:       cfg,
+		tlsConfig: tlsConfig,
+	}
+
+	return t, nil
+}
+
+func (s *Authenticator) connect(ctx context.Context) (*goldap.Conn, error) {
+	c, err := goldap.DialURL(s.cfg.URL,
+		goldap.DialWithDialer(&net.Dialer{Timeout: s.cfg.Timeout}),
+		goldap.DialWithTLSConfig(s.tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	c.SetTimeout(s.cfg.Timeout)
+	if s.cfg.StartTLS && strings.HasPrefix(s.cfg.URL, "ldap://") {
+		if err := c.StartTLS(s.tlsConfig); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("could not start TLS:%v", err)
+		}
+	}
+	if s.cfg.BindDN != "" {
+		if err := c.Bind(s.cfg.BindDN, s.cfg.BindPassword); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("could not bind with service account:%v", err)
+		}
+	}
+	return c, nil
+}
+
+// Authenticate verifies the credentials of the user and resolves the groups and teams
+func (s *Authenticator) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	if username == "" || password == "" {
+		// an empty password would result in an unauthenticated bind that always succeeds
+		return nil, ErrInvalidCredentials
+	}
+
+	c, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	u, err := s.findUser(c, username)
+	if err == errors.ErrNotFound {
+		s.logger.LogInfo(ctx, "LDAP user %s not found", username)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Bind(u.DN, password)
+	if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+		s.logger.LogInfo(ctx, "Invalid credentials for LDAP user %s", username)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the user might not be allowed to search for groups
+	if s.cfg.BindDN != "" {
+		if err := c.Bind(s.cfg.BindDN, s.cfg.BindPassword); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.resolveGroups(c, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// LookupUser resolves the groups and teams of a user by its DN using the service account.
+// Returns errors.ErrNotFound if the user no longer exists.
+func (s *Authenticator) LookupUser(ctx context.Context, dn string) (*User, error) {
+	c, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	res, err := c.Search(goldap.NewSearchRequest(dn, goldap.ScopeBaseObject, goldap.NeverDerefAliases, 1, 0, false,
+		"(objectClass=*)", []string{s.cfg.EmailAttribute, s.cfg.NameAttribute, s.cfg.MemberOfAttribute}, nil))
+	if goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject) {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Entries) == 0 {
+		return nil, errors.ErrNotFound
+	}
+	e := res.Entries[0]
+	u := &User{
+		DN:     e.DN,
+		Email:  e.GetEqualFoldAttributeValue(s.cfg.EmailAttribute),
+		Name:   e.GetEqualFoldAttributeValue(s.cfg.NameAttribute),
+		Groups: e.GetEqualFoldAttributeValues(s.cfg.MemberOfAttribute),
+	}
+	if err := s.resolveGroups(c, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *Authenticator) findUser(c *goldap.Conn, username string) (*User, error) {
+	res, err := c.Search(goldap.NewSearchRequest(s.cfg.UserBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(s.cfg.UserFilter, goldap.EscapeFilter(username)),
+		[]string{s.cfg.EmailAttribute, s.cfg.NameAttribute, s.cfg.MemberOfAttribute}, nil))
+	if goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) || (err == nil && len(res.Entries) > 1) {
+		return nil, fmt.Errorf("found more than one LDAP user for %s", username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Entries) == 0 {
+		return nil, errors.ErrNotFound
+	}
+	e := res.Entries[0]
+	return &User{
+		DN:       e.DN,
+		Username: username,
+		Email:    e.GetEqualFoldAttributeValue(s.cfg.EmailAttribute),
+		Name:     e.GetEqualFoldAttributeValue(s.cfg.NameAttribute),
+		Groups:   e.GetEqualFoldAttributeValues(s.cfg.MemberOfAttribute),
+	}, nil
+}
+
+func (s *Authenticator) resolveGroups(c *goldap.Conn, u *User) error {
+	groups := map[string]string{} // dn => name
+	if s.cfg.GroupFilter == "" {
+		for _, dn := range u.Groups {
+			groups[dn] = firstRDNValue(dn)
+		}
+	} else {
+		res, err := c.Search(goldap.NewSearchRequest(s.cfg.GroupBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 0, 0, false,
+			fmt.Sprintf(s.cfg.GroupFilter, goldap.EscapeFilter(u.DN)),
+			[]string{s.cfg.GroupNameAttribute}, nil))
+		if err != nil {
+			return err
+		}
+		for _, e := range res.Entries {
+			name := e.GetEqualFoldAttributeValue(s.cfg.GroupNameAttribute)
+			if name == "" {
+				name = firstRDNValue(e.DN)
+			}
+			groups[e.DN] = name
+		}
+	}
+
+	u.Groups = nil
+	u.Teams = nil
+	teams := map[string]bool{}
+	for dn, name := range groups {
+		u.Groups = append(u.Groups, name)
+		if team := s.mapTeam(dn, name); team != "" {
+			teams[team] = true
+		}
+	}
+	for t := range teams {
+		u.Teams = append(u.Teams, t)
+	}
+	sort.Strings(u.Groups)
+	sort.Strings(u.Teams)
+	return nil
+}
+
+// MappedTeams returns the teams of the group mapping, empty if every group is used as a team
+func (s *Authenticator) MappedTeams() []string {
+	unique := map[string]bool{}
+	for _, team := range s.cfg.GroupTeamMapping {
+		unique[team] = true
+	}
+	res := []string{}
+	for team := range unique {
+		res = append(res, team)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (s *Authenticator) mapTeam(dn, name string) string {
+	if len(s.cfg.GroupTeamMapping) == 0 {
+		return name
+	}
+	for k, team := range s.cfg.GroupTeamMapping {
+		if strings.EqualFold(k, dn) || strings.EqualFold(k, name) {
+			return team
+		}
+	}
+	return ""
+}
+
+// firstRDNValue returns "admins" for "cn=admins,ou=groups,dc=example,dc=org"
+func firstRDNValue(dn string) string {
+	parsed, err := goldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return dn
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
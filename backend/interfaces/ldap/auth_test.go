@@ -0,0 +1,186 @@
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// fakeServer answers bind and search requests with fixed data
+type fakeServer struct {
+	passwords map[string]string // dn => password
+	entries   map[string][]*goldap.Entry
+
+	lock    sync.Mutex
+	filters []*ber.Packet
+}
+
+func (s *fakeServer) serve(t *testing.T, l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(t, c)
+	}
+}
+
+func (s *fakeServer) handle(t *testing.T, c net.Conn) {
+	defer c.Close()
+	for {
+		p, err := ber.ReadPacket(c)
+		if err != nil {
+			return
+		}
+		id := p.Children[0].Value
+		op := p.Children[1]
+		reply := func(ops ...*ber.Packet) {
+			for _, o := range ops {
+				msg := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+				msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+				msg.AppendChild(o)
+				_, _ = c.Write(msg.Bytes())
+			}
+		}
+		result := func(tag ber.Tag, code int64) *ber.Packet {
+			r := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+			r.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+			r.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+			r.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+			return r
+		}
+
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			if pw, ok := s.passwords[dn]; ok && pw == op.Children[2].Data.String() {
+				reply(result(goldap.ApplicationBindResponse, goldap.LDAPResultSuccess))
+				continue
+			}
+			reply(result(goldap.ApplicationBindResponse, goldap.LDAPResultInvalidCredentials))
+		case goldap.ApplicationSearchRequest:
+			s.lock.Lock()
+			s.filters = append(s.filters, op.Children[6])
+			s.lock.Unlock()
+			var ops []*ber.Packet
+			for _, e := range s.entries[op.Children[0].Data.String()] {
+				entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "")
+				entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, ""))
+				attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+				for _, a := range e.Attributes {
+					attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+					attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, a.Name, ""))
+					set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+					for _, v := range a.Values {
+						set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+					}
+					attr.AppendChild(set)
+					attrs.AppendChild(attr)
+				}
+				entry.AppendChild(attrs)
+				ops = append(ops, entry)
+			}
+			reply(append(ops, result(goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess))...)
+		case goldap.ApplicationUnbindRequest, goldap.ApplicationAbandonRequest:
+			return
+		default:
+			t.Errorf("Unexpected op %x", op.Tag)
+			return
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen:%v", err)
+	}
+	defer l.Close()
+
+	srv := &fakeServer{
+		passwords: map[string]string{
+			"cn=svc,dc=example,dc=org":             "svc-secret",
+			"uid=jdoe,ou=people,dc=example,dc=org": "secret",
+		},
+		entries: map[string][]*goldap.Entry{
+			"ou=people,dc=example,dc=org": {goldap.NewEntry("uid=jdoe,ou=people,dc=example,dc=org", map[string][]string{
+				"mail":     {"jdoe@example.org"},
+				"cn":       {"John Doe"},
+				"memberOf": {"cn=ops,ou=groups,dc=example,dc=org", "cn=dev,ou=groups,dc=example,dc=org"},
+			})},
+		},
+	}
+	go srv.serve(t, l)
+
+	a, err := CreateAuthenticator(ctx, log.NewSimpleLogger(false, "Test"), AuthenticatorConfig{
+		URL:          "ldap://" + l.Addr().String(),
+		BindDN:       "cn=svc,dc=example,dc=org",
+		BindPassword: "svc-secret",
+		UserBaseDN:   "ou=people,dc=example,dc=org",
+		GroupTeamMapping: map[string]string{
+			"cn=ops,ou=groups,dc=example,dc=org": "operations",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Could not CreateAuthenticator:%v", err)
+	}
+
+	u, err := a.Authenticate(ctx, "jdoe", "secret")
+	if err != nil {
+		t.Fatalf("Could not Authenticate:%v", err)
+	}
+	if u.Email != "jdoe@example.org" || u.Name != "John Doe" {
+		t.Errorf("Unexpected user %+v", u)
+	}
+	if !reflect.DeepEqual(u.Groups, []string{"dev", "ops"}) {
+		t.Errorf("Unexpected groups %v", u.Groups)
+	}
+	if !reflect.DeepEqual(u.Teams, []string{"operations"}) {
+		t.Errorf("Unexpected teams %v", u.Teams)
+	}
+	if !reflect.DeepEqual(a.MappedTeams(), []string{"operations"}) {
+		t.Errorf("Unexpected mapped teams %v", a.MappedTeams())
+	}
+
+	_, err = a.Authenticate(ctx, "jdoe", "wrong")
+	if err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+
+	_, _ = a.Authenticate(ctx, "jdoe*)(uid=*", "wrong")
+	expected, _ := goldap.CompileFilter(`(uid=jdoe\2a\29\28uid=\2a)`)
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	last := srv.filters[len(srv.filters)-1]
+	if !bytes.Equal(last.Bytes(), expected.Bytes()) {
+		t.Errorf("Expected the username to be escaped in the filter")
+	}
+}
+
+func TestFirstRDNValue(t *testing.T) {
+	if s := firstRDNValue("cn=admins,ou=groups,dc=example,dc=org"); s != "admins" {
+		t.Errorf("Unexpected rdn value %s", s)
+	}
+	if s := firstRDNValue("cn=a\\,b,ou=groups"); s != "a,b" {
+		t.Errorf("Unexpected rdn value %s", s)
+	}
+}
+
+func TestCreateAuthenticatorInvalidFilter(t *testing.T) {
+	_, err := CreateAuthenticator(context.Background(), log.NewSimpleLogger(false, "Test"), AuthenticatorConfig{
+		URL:        "ldap://localhost",
+		UserBaseDN: "ou=people,dc=example,dc=org",
+		UserFilter: "(uid=%s",
+	})
+	if err == nil {
+		t.Errorf("Expected an error for an invalid user filter")
+	}
+}
@@ -0,0 +1,236 @@
+package nomadcluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// Capability is a permission the Nomad token of nomad-ops requires
+type Capability string
+
+const (
+	// CapabilitySubmitJob plans and registers the jobs of the sources
+	CapabilitySubmitJob Capability = "submit-job"
+	// CapabilityReadJob lists and reads the managed jobs
+	CapabilityReadJob Capability = "read-job"
+	// CapabilityListNamespaces lists the namespaces
+	CapabilityListNamespaces Capability = "list-namespaces"
+	// CapabilityEventStream subscribes to the job and deployment events of all namespaces
+	CapabilityEventStream Capability = "event-stream"
+)
+
+// aclProbeJobID is the job planned to probe submit-job, it is never registered
+const aclProbeJobID = "nomad-ops-acl-check"
+
+// Destination is a region and namespace the jobs of sources are deployed to,
+// empty values are the defaults of the agent and the token
+type Destination struct {
+	Region    string `json:"region,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (d Destination) String() string {
+	ns := d.Namespace
+	if ns == "" {
+		ns = "the default namespace"
+	} else {
+		ns = "namespace " + ns
+	}
+	if d.Region == "" {
+		return ns
+	}
+	return fmt.Sprintf("%s of region %s", ns, d.Region)
+}
+
+// DestinationACL are the results of probing the capabilities of the token for a destination
+type DestinationACL struct {
+	Destination
+	// Missing are the capabilities Nomad denied
+	Missing []Capability `json:"missing"`
+	// Errors of probes that failed for other reasons, e.g. a missing namespace
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ACLReport are the capabilities of the Nomad token, checked at the start and every ACL check interval
+type ACLReport struct {
+	// Ready is set if no required capability is missing
+	Ready        bool             `json:"ready"`
+	Message      string           `json:"message,omitempty"`
+	Destinations []DestinationACL `json:"destinations"`
+	CheckedAt    time.Time        `json:"checkedAt"`
+}
+
+// newACLReport summarizes the results of the destinations
+func newACLReport(destinations []DestinationACL, now time.Time) ACLReport {
+	r := ACLReport{
+		Ready:        true,
+		Destinations: destinations,
+		CheckedAt:    now,
+	}
+	var parts []string
+	for _, d := range destinations {
+		if len(d.Missing) == 0 {
+			continue
+		}
+		r.Ready = false
+		caps := make([]string, 0, len(d.Missing))
+		for _, c := range d.Missing {
+			caps = append(caps, string(c))
+		}
+		parts = append(parts, fmt.Sprintf("%s in %s", strings.Join(caps, ", "), d.Destination))
+	}
+	if len(parts) > 0 {
+		r.Message = "The Nomad token lacks " + strings.Join(parts, "; ")
+	}
+	return r
+}
+
+// aclProbe calls the Nomad API with the capability, it fails with 403 if the token lacks it
+type aclProbe struct {
+	capability Capability
+	probe      func(ctx context.Context, d Destination) error
+}
+
+// aclTracker holds the last ACL report
+type aclTracker struct {
+	lock    sync.Mutex
+	current ACLReport
+}
+
+func (t *aclTracker) set(r ACLReport) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.current = r
+}
+
+func (t *aclTracker) get() ACLReport {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.current
+}
+
+// ACLReport returns the last ACL report, it is not ready before the first check
+func (c *Client) ACLReport() ACLReport {
+	r := c.acl.get()
+	if r.CheckedAt.IsZero() {
+		r.Message = "The capabilities of the Nomad token were not checked yet"
+	}
+	return r
+}
+
+// CheckACL probes the capabilities of the token for the default destination and the given ones.
+// The event stream is probed once for the default destination, as nomad-ops subscribes to all namespaces.
+func (c *Client) CheckACL(ctx context.Context, destinations []Destination) ACLReport {
+	seen := map[Destination]bool{{}: true}
+	unique := []Destination{{}}
+	for _, d := range destinations {
+		if !seen[d] {
+			seen[d] = true
+			unique = append(unique, d)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool {
+		if unique[i].Region != unique[j].Region {
+			return unique[i].Region < unique[j].Region
+		}
+		return unique[i].Namespace < unique[j].Namespace
+	})
+
+	results := make([]DestinationACL, 0, len(unique))
+	for _, d := range unique {
+		res := DestinationACL{Destination: d, Missing: []Capability{}}
+		probes := []aclProbe{
+			{CapabilityListNamespaces, c.probeListNamespaces},
+			{CapabilityReadJob, c.probeReadJob},
+			{CapabilitySubmitJob, c.probeSubmitJob},
+		}
+		if d == (Destination{}) {
+			probes = append(probes, aclProbe{CapabilityEventStream, c.probeEventStream})
+		}
+		for _, p := range probes {
+			err := p.probe(ctx, d)
+			if statusCode(err) == http.StatusForbidden {
+				res.Missing = append(res.Missing, p.capability)
+			} else if err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", p.capability, err))
+			}
+		}
+		results = append(results, res)
+	}
+
+	r := newACLReport(results, time.Now())
+	c.acl.set(r)
+	if !r.Ready {
+		c.logger.LogError(ctx, "%s", r.Message)
+	}
+	return r
+}
+
+func (c *Client) probeListNamespaces(ctx context.Context, d Destination) error {
+	_, _, err := c.client.Namespaces().List((&api.QueryOptions{Region: d.Region}).WithContext(ctx))
+	return err
+}
+
+func (c *Client) probeReadJob(ctx context.Context, d Destination) error {
+	_, _, err := c.client.Jobs().List((&api.QueryOptions{
+		Region:    d.Region,
+		Namespace: d.Namespace,
+		Filter:    fmt.Sprintf(`ID == "%s"`, aclProbeJobID),
+	}).WithContext(ctx))
+	return err
+}
+
+// probeSubmitJob plans a job that does not exist, which requires submit-job without changing the cluster
+func (c *Client) probeSubmitJob(ctx context.Context, d Destination) error {
+	job := api.NewServiceJob(aclProbeJobID, aclProbeJobID, d.Region, 50)
+	if d.Region == "" {
+		job.Region = nil
+	}
+	if d.Namespace != "" {
+		job.Namespace = &d.Namespace
+	}
+	job.Datacenters = []string{"*"}
+	job.AddTaskGroup(api.NewTaskGroup("probe", 1).AddTask(api.NewTask("probe", "docker")))
+	_, _, err := c.client.Jobs().Plan(job, false, (&api.WriteOptions{
+		Region:    d.Region,
+		Namespace: d.Namespace,
+	}).WithContext(ctx))
+	return err
+}
+
+// probeEventStream subscribes like SubscribeJobChanges, denied subscriptions fail before any event is received
+func (c *Client) probeEventStream(ctx context.Context, d Destination) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	_, err := c.client.EventStream().Stream(streamCtx, map[api.Topic][]string{
+		api.TopicJob:        {"*"},
+		api.TopicDeployment: {"*"},
+	}, 0, &api.QueryOptions{Namespace: "*", Region: d.Region})
+	return err
+}
+
+// RunACLCheck checks the capabilities of the token for the destinations now and every interval until ctx is done,
+// 0 checks only once
+func (c *Client) RunACLCheck(ctx context.Context, interval time.Duration, destinations func(ctx context.Context) []Destination) {
+	c.CheckACL(ctx, destinations(ctx))
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.CheckACL(ctx, destinations(ctx))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package nomadcluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCheckACL(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{})
+	if r := c.ACLReport(); r.Ready || r.Message == "" {
+		t.Fatalf("Expected the token not to be ready before the check, got %+v", r)
+	}
+
+	r := c.CheckACL(ctx, []Destination{{}, {Namespace: "default"}})
+	if !r.Ready || r.Message != "" || len(r.Destinations) != 2 {
+		t.Fatalf("Expected all capabilities, got %+v", r)
+	}
+	for _, d := range r.Destinations {
+		if len(d.Errors) > 0 {
+			t.Errorf("Unexpected errors probing %s: %v", d.Destination, d.Errors)
+		}
+	}
+
+	c = createFakeClient(t, FakeConfig{
+		DenyCapabilities: []string{string(CapabilitySubmitJob), string(CapabilityEventStream)},
+	})
+	r = c.CheckACL(ctx, []Destination{{Namespace: "default"}})
+	if r.Ready {
+		t.Fatalf("Expected missing capabilities, got %+v", r)
+	}
+	if !reflect.DeepEqual(r.Destinations[0].Missing, []Capability{CapabilitySubmitJob, CapabilityEventStream}) ||
+		!reflect.DeepEqual(r.Destinations[1].Missing, []Capability{CapabilitySubmitJob}) {
+		t.Errorf("Unexpected missing capabilities %+v", r.Destinations)
+	}
+	expected := "The Nomad token lacks submit-job, event-stream in the default namespace; submit-job in namespace default"
+	if r.Message != expected {
+		t.Errorf("Unexpected message:\n%s\nexpected:\n%s", r.Message, expected)
+	}
+	if got := c.ACLReport(); got.Message != expected {
+		t.Errorf("Expected the last report to be kept, got %+v", got)
+	}
+}
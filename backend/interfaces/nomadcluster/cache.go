@@ -0,0 +1,154 @@
+package nomadcluster
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// stateCache holds the managed jobs of each source as built by GetCurrentClusterState and applies
+// job events of the event stream to it. It is only used while the event stream is connected,
+// entries are rebuilt after ttl to recover from missed events.
+type stateCache struct {
+	lock   sync.Mutex
+	ttl    time.Duration
+	active bool
+	// index of the last applied job event
+	lastEventIndex uint64
+	sources        map[string]*cachedSource
+}
+
+type cachedSource struct {
+	built time.Time
+	// jobs by <namespace>/<name>, a source may deploy jobs of the same name into several namespaces
+	jobs map[string]*api.Job
+}
+
+func newStateCache(ttl time.Duration) *stateCache {
+	return &stateCache{
+		ttl:     ttl,
+		sources: map[string]*cachedSource{},
+	}
+}
+
+// setActive enables the cache while the event stream is connected, all entries are dropped on changes
+func (s *stateCache) setActive(active bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.active != active {
+		s.sources = map[string]*cachedSource{}
+	}
+	s.active = active
+}
+
+func (s *stateCache) isActive() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.active
+}
+
+// get returns a copy of the cached jobs of the source
+func (s *stateCache) get(srcID string, now time.Time) (map[string]*api.Job, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.active || s.ttl <= 0 {
+		return nil, false
+	}
+	entry, ok := s.sources[srcID]
+	if !ok || now.Sub(entry.built) > s.ttl {
+		return nil, false
+	}
+	// the reconciler modifies the jobs of the cluster state
+	jobs := make(map[string]*api.Job, len(entry.jobs))
+	for k, v := range entry.jobs {
+		j, err := copyJob(v)
+		if err != nil {
+			return nil, false
+		}
+		jobs[k] = j
+	}
+	return jobs, true
+}
+
+// set caches the jobs of the source, listed at the given index. The jobs are not cached if job events
+// were applied since then, as those may be missing in jobs.
+func (s *stateCache) set(srcID string, index uint64, jobs map[string]*api.Job, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.active || s.ttl <= 0 || s.lastEventIndex > index {
+		return
+	}
+	entry := &cachedSource{
+		built: now,
+		jobs:  make(map[string]*api.Job, len(jobs)),
+	}
+	for k, v := range jobs {
+		j, err := copyJob(v)
+		if err != nil {
+			return
+		}
+		entry.jobs[k] = j
+	}
+	s.sources[srcID] = entry
+}
+
+func (s *stateCache) invalidate(srcID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.sources, srcID)
+}
+
+// applyJobEvent updates the cached sources with a registered or deregistered job
+func (s *stateCache) applyJobEvent(index uint64, job *api.Job, deregistered bool) {
+	if job == nil || job.ID == nil || job.Name == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if index > s.lastEventIndex {
+		s.lastEventIndex = index
+	}
+
+	srcID := job.Meta[metaKeySrcID]
+	key := cacheKey(job)
+	for id, entry := range s.sources {
+		// the job may have been moved to another source
+		if cached, ok := entry.jobs[key]; ok && sameJob(cached, job) {
+			delete(entry.jobs, key)
+		}
+		if !deregistered && id == srcID {
+			entry.jobs[key] = job
+		}
+	}
+}
+
+func cacheKey(job *api.Job) string {
+	return indexKey(strPtrToStr(job.Namespace), strPtrToStr(job.Name))
+}
+
+func sameJob(a, b *api.Job) bool {
+	return strPtrToStr(a.ID) == strPtrToStr(b.ID) &&
+		strPtrToStr(a.Namespace) == strPtrToStr(b.Namespace)
+}
+
+// copyJob returns a deep copy of the job
+func copyJob(job *api.Job) (*api.Job, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	res := &api.Job{}
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func strPtrToStr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
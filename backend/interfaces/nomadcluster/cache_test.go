@@ -0,0 +1,78 @@
+package nomadcluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+func cacheJob(id, srcID string) *api.Job {
+	namespace := "default"
+	return &api.Job{
+		ID:        &id,
+		Name:      &id,
+		Namespace: &namespace,
+		Meta:      map[string]string{metaKeySrcID: srcID},
+	}
+}
+
+func TestStateCache(t *testing.T) {
+	now := time.Now()
+	c := newStateCache(time.Minute)
+
+	c.set("a", 10, map[string]*api.Job{"default/web": cacheJob("web", "a")}, now)
+	if _, ok := c.get("a", now); ok {
+		t.Fatalf("Expected the cache to be inactive without event stream")
+	}
+
+	c.setActive(true)
+	c.set("a", 10, map[string]*api.Job{"default/web": cacheJob("web", "a")}, now)
+	c.set("b", 10, map[string]*api.Job{}, now)
+
+	// moved from a to b
+	c.applyJobEvent(11, cacheJob("web", "b"), false)
+	c.applyJobEvent(12, cacheJob("api", "a"), false)
+
+	jobs, ok := c.get("a", now)
+	if !ok || len(jobs) != 1 || jobs["default/api"] == nil {
+		t.Errorf("Unexpected jobs of a %v", jobs)
+	}
+	jobs, ok = c.get("b", now)
+	if !ok || len(jobs) != 1 || jobs["default/web"] == nil {
+		t.Errorf("Unexpected jobs of b %v", jobs)
+	}
+
+	c.applyJobEvent(13, cacheJob("web", "b"), true)
+	if jobs, _ := c.get("b", now); len(jobs) != 0 {
+		t.Errorf("Expected the deregistered job to be removed, got %v", jobs)
+	}
+
+	if _, ok := c.get("a", now.Add(time.Minute*2)); ok {
+		t.Errorf("Expected the entry to expire")
+	}
+
+	// listed before the last event, may miss it
+	c.set("c", 12, map[string]*api.Job{}, now)
+	if _, ok := c.get("c", now); ok {
+		t.Errorf("Expected an outdated listing not to be cached")
+	}
+}
+
+func TestStateCacheCopies(t *testing.T) {
+	now := time.Now()
+	c := newStateCache(time.Minute)
+	c.setActive(true)
+
+	listed := cacheJob("web", "a")
+	c.set("a", 10, map[string]*api.Job{"default/web": listed}, now)
+	listed.Meta["changed"] = "true"
+
+	jobs, _ := c.get("a", now)
+	jobs["default/web"].Meta["changed"] = "true"
+
+	jobs, _ = c.get("a", now)
+	if _, ok := jobs["default/web"].Meta["changed"]; ok {
+		t.Errorf("Expected the cached job not to be changed by the callers")
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	// types "github.com/hashicorp/nomad-openapi/clients/go/v1"
 	// v1 "github.com/hashicorp/nomad-openapi/v1"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/hashicorp/nomad/api"
 
 	"github.com/nomad-ops/nomad-ops/backend/application"
@@ -23,23 +26,69 @@ var (
 	metaKeySrcUrl       = "nomadopssrcurl"
 	metaKeySrcCommit    = "nomadopssrccommit"
 	metaKeyForceRestart = "nomadopsforcerestart"
+	metaKeyWaitFor      = application.MetaKeyWaitFor
 )
 
 type ClientConfig struct {
 	NomadToken string
+	// Address of the Nomad API, defaults to NOMAD_ADDR. unix:///path/to/socket connects to a unix socket.
+	// Comma separated addresses or SRV records (srv+https://name) are failed over, see failoverTransport.
+	Address string
+	// UIURL is the address of the Nomad UI, defaults to the address of the API
+	UIURL string
+	// UIRegionURLs are the addresses of the Nomad UI by region, e.g. of federated clusters, defaults to UIURL
+	UIRegionURLs map[string]string
+	Transport    TransportConfig
+	// Fake replaces the Nomad API with an in-memory cluster, Address and Transport are ignored
+	Fake *FakeConfig
+	// AppName is used as label of the metrics
+	AppName string
+	// StateCacheTTL enables caching the cluster state of sources, kept up to date by the event stream,
+	// entries are rebuilt after this duration. 0 disables the cache.
+	StateCacheTTL time.Duration
+	// ParseTimeout bounds the time the Nomad API may take to parse a job file, 0 disables the limit
+	ParseTimeout time.Duration
+	// MaxJobFileSize is the maximum size in bytes of a job file, 0 disables the limit
+	MaxJobFileSize int
+	// ParseCacheSize is the number of parsed job files kept to skip parsing unchanged files, 0 disables the cache
+	ParseCacheSize int
+	// DiffIgnore extends the fields set by the Nomad server that are not considered a change, see defaultDiffIgnore
+	DiffIgnore []string
+	// TestedVersions are the lowest and highest Nomad version tested with nomad-ops, e.g. 1.3 and 1.6,
+	// agents outside the range are reported as version skew
+	TestedVersions []string
+	// VersionCheckInterval is the interval the versions of the Nomad agents are queried at, starting with the creation
+	// of the client. 0 disables the check.
+	VersionCheckInterval time.Duration
+	// JobStopTimeout bounds the time the allocations of deleted jobs are awaited to stop, before the jobs they
+	// wait for are deleted. 0 disables waiting.
+	JobStopTimeout time.Duration
 }
 
 type Client struct {
-	ctx    context.Context
-	logger log.Logger
-	cfg    ClientConfig
-	client *api.Client
-	url    string
+	ctx       context.Context
+	logger    log.Logger
+	cfg       ClientConfig
+	client    *api.Client
+	url       string
+	auditRepo application.AuditRepo
+	cache     *stateCache
+	parsed    *parseCache
+	index     *jobIndex
+	ignore    diffIgnore
+	versions  *versionTracker
+	acl       *aclTracker
+
+	// raft index of the last received event and of the last job listing, used to report the event stream lag
+	lastEventIndex uint64
+	lastJobsIndex  uint64
 }
 
+// CreateClient creates a Nomad client, auditRepo is optional
 func CreateClient(ctx context.Context,
 	logger log.Logger,
-	cfg ClientConfig) (*Client, error) {
+	cfg ClientConfig,
+	auditRepo application.AuditRepo) (*Client, error) {
 
 	defCfg := api.DefaultConfig()
 
@@ -47,6 +96,25 @@ func CreateClient(ctx context.Context,
 		// Use default client config from ENV, optionally a custom token
 		defCfg.SecretID = cfg.NomadToken
 	}
+	if cfg.Address != "" {
+		defCfg.Address = cfg.Address
+	}
+	if cfg.UIURL == "" {
+		cfg.UIURL = uiAddress(defCfg.Address)
+	}
+
+	if cfg.Fake != nil {
+		logger.LogInfo(ctx, "Using an in-memory Nomad cluster, nothing is deployed")
+		defCfg.HttpClient = fakeHTTPClient(*cfg.Fake)
+		defCfg.Address = "http://fake-nomad"
+	} else {
+		httpClient, address, err := newHTTPClient(ctx, logger, defCfg.Address, cfg.Transport, defCfg.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		defCfg.HttpClient = httpClient
+		defCfg.Address = address
+	}
 
 	client, err := api.NewClient(defCfg)
 
@@ -55,34 +123,123 @@ func CreateClient(ctx context.Context,
 	}
 
 	c := &Client{
-		ctx:    ctx,
-		logger: logger,
-		cfg:    cfg,
-		client: client,
-		url:    defCfg.Address,
+		ctx:       ctx,
+		logger:    logger,
+		cfg:       cfg,
+		client:    client,
+		url:       cfg.UIURL,
+		auditRepo: auditRepo,
+		cache:     newStateCache(cfg.StateCacheTTL),
+		parsed:    newParseCache(cfg.ParseCacheSize),
+		index:     newJobIndex(),
+		ignore:    newDiffIgnore(cfg.DiffIgnore),
+		versions:  newVersionTracker(cfg.TestedVersions),
+		acl:       &aclTracker{},
+	}
+
+	metrics.GetOrCreateGauge(fmt.Sprintf(`nomad_ops_event_stream_lag_index{app="%s"}`, cfg.AppName), func() float64 {
+		jobsIndex := atomic.LoadUint64(&c.lastJobsIndex)
+		eventIndex := atomic.LoadUint64(&c.lastEventIndex)
+		if jobsIndex <= eventIndex {
+			return 0
+		}
+		return float64(jobsIndex - eventIndex)
+	})
+	metrics.GetOrCreateGauge(fmt.Sprintf(`nomad_ops_event_stream_connected{app="%s"}`, cfg.AppName), func() float64 {
+		if c.cache.isActive() {
+			return 1
+		}
+		return 0
+	})
+	metrics.GetOrCreateGauge(fmt.Sprintf(`nomad_ops_nomad_version_skew{app="%s"}`, cfg.AppName), func() float64 {
+		if c.versions.get().Skew {
+			return 1
+		}
+		return 0
+	})
+
+	if cfg.VersionCheckInterval > 0 {
+		go c.runVersionCheck(ctx, cfg.VersionCheckInterval)
 	}
 
 	return c, nil
 }
 
+// backoff of reconnecting the event stream, doubling with every failed attempt
+var (
+	eventStreamBackoff    = time.Second
+	eventStreamMaxBackoff = time.Minute
+)
+
+// SubscribeJobChanges calls cb for every changed job. If the event stream closes or fails, it is
+// resubscribed from the last received index with a backoff, until ctx is done.
 func (c *Client) SubscribeJobChanges(ctx context.Context, cb func(jobName string)) error {
 	var index uint64 = 0
 	if _, meta, err := c.client.Jobs().List(nil); err == nil {
 		index = meta.LastIndex
 	}
+	atomic.StoreUint64(&c.lastEventIndex, index)
+	atomic.StoreUint64(&c.lastJobsIndex, index)
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	eventCh, err := c.streamJobEvents(streamCtx, index)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	minBackoff, maxBackoff := eventStreamBackoff, eventStreamMaxBackoff
+	go func() {
+		backoff := minBackoff
+		for {
+			c.cache.setActive(true)
+			received := c.handleJobEvents(ctx, eventCh, cb)
+			c.cache.setActive(false)
+			cancel()
+			if received {
+				backoff = minBackoff
+			}
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				if backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+
+				streamCtx, cancel = context.WithCancel(ctx)
+				eventCh, err = c.streamJobEvents(streamCtx, atomic.LoadUint64(&c.lastEventIndex))
+				if err == nil {
+					c.logger.LogInfo(ctx, "Resubscribed to the Nomad event stream")
+					break
+				}
+				cancel()
+				c.logger.LogError(ctx, "Could not resubscribe to the Nomad event stream:%v", err)
+			}
+		}
+	}()
+
+	return nil
+}
 
+func (c *Client) streamJobEvents(ctx context.Context, index uint64) (<-chan *api.Events, error) {
 	queryOptions := &api.QueryOptions{
 		Namespace: "*",
 	}
 
-	eventCh, err := c.client.EventStream().Stream(ctx, map[api.Topic][]string{
+	return c.client.EventStream().Stream(ctx, map[api.Topic][]string{
 		api.TopicJob:        {"*"},
 		api.TopicDeployment: {"*"},
 	}, index, queryOptions.WithContext(ctx))
-	if err != nil {
-		return err
-	}
+}
 
+// handleJobEvents passes the events of the stream to cb until the stream closes or fails,
+// it returns whether any events were received
+func (c *Client) handleJobEvents(ctx context.Context, eventCh <-chan *api.Events, cb func(jobName string)) bool {
 	eventHandler := func(event *api.Events) {
 		for _, e := range event.Events {
 
@@ -100,6 +257,8 @@ func (c *Client) SubscribeJobChanges(ctx context.Context, cb func(jobName string
 					return
 				}
 
+				c.cache.applyJobEvent(e.Index, job, e.Type == "JobDeregistered")
+				c.index.applyJobEvent(job, e.Type == "JobDeregistered")
 				cb(*job.ID)
 			case "DeploymentStatusUpdate":
 				dep, err := e.Deployment()
@@ -116,24 +275,33 @@ func (c *Client) SubscribeJobChanges(ctx context.Context, cb func(jobName string
 		}
 	}
 
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-
-			case events := <-eventCh:
+	received := false
+	for {
+		select {
+		case <-ctx.Done():
+			return received
 
-				if events.IsHeartbeat() {
-					continue
+		case events, ok := <-eventCh:
+			if !ok {
+				c.logger.LogError(ctx, "Nomad event stream closed")
+				return received
+			}
+			if events.Err != nil {
+				if ctx.Err() == nil {
+					c.logger.LogError(ctx, "Nomad event stream failed:%v", events.Err)
 				}
+				return received
+			}
+			received = true
 
-				eventHandler(events)
+			if events.IsHeartbeat() {
+				continue
 			}
-		}
-	}()
+			atomic.StoreUint64(&c.lastEventIndex, events.Index)
 
-	return nil
+			eventHandler(events)
+		}
+	}
 }
 
 func hasUpdate(diffResp *api.JobPlanResponse, restart, force bool) bool {
@@ -173,34 +341,96 @@ func hasUpdate(diffResp *api.JobPlanResponse, restart, force bool) bool {
 	return hasDiff
 }
 
-func (c *Client) ParseJob(ctx context.Context, j string) (*application.JobInfo, error) {
-	parsedJob, err := c.client.Jobs().ParseHCL(j, false)
+// ParseJob parses the job file using the Nomad API. The Nomad agent parses job files with
+// filesystem functions (file, fileset, ...) disabled, so a job file cannot read files of any host.
+// With the HCL1 fallback, job files that are not valid HCL2 are parsed with the deprecated HCL1 parser.
+// Strict parsing rejects job files with parser warnings, warnings of the plan are not affected.
+func (c *Client) ParseJob(ctx context.Context, j string, opts application.ParseJobOptions) (*application.JobInfo, error) {
+	if c.cfg.MaxJobFileSize > 0 && len(j) > c.cfg.MaxJobFileSize {
+		return nil, domain.WithErrorCode(domain.ErrorCodeParseError,
+			fmt.Errorf("job file exceeds the maximum size of %d bytes", c.cfg.MaxJobFileSize))
+	}
+
+	key := parseCacheKey(j, opts, c.versions.get())
+	if cached, ok := c.parsed.get(key); ok {
+		metrics.GetOrCreateCounter(fmt.Sprintf(`nomad_ops_parse_cache_hits_counter{app="%s"}`, c.cfg.AppName)).Inc()
+		return cached, nil
+	}
+	metrics.GetOrCreateCounter(fmt.Sprintf(`nomad_ops_parse_cache_misses_counter{app="%s"}`, c.cfg.AppName)).Inc()
+
+	parentCtx := ctx
+	if c.cfg.ParseTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.ParseTimeout)
+		defer cancel()
+	}
+
+	var warnings []string
+	parsedJob, err := c.parseJob(ctx, j, false)
+	if err != nil && opts.HCL1Fallback && statusCode(err) == http.StatusBadRequest {
+		var errV1 error
+		parsedJob, errV1 = c.parseJob(ctx, j, true)
+		if errV1 == nil {
+			warnings = append(warnings, fmt.Sprintf("parsed with the deprecated HCL1 parser: %v", err))
+			err = nil
+		}
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded && parentCtx.Err() == nil {
+			err = fmt.Errorf("parsing the job file did not finish within %v: %w", c.cfg.ParseTimeout, err)
+		} else if hint := versionHint(err, c.versions.get()); hint != "" {
+			err = fmt.Errorf("%w (%s)", err, hint)
+		}
+		return nil, withErrorCode(domain.ErrorCodeParseError, err)
+	}
+	if opts.Strict && len(warnings) > 0 {
+		return nil, domain.WithErrorCode(domain.ErrorCodeParseError,
+			fmt.Errorf("job file has warnings, which are not allowed in strict mode: %s", strings.Join(warnings, "; ")))
+	}
+
+	res := &application.JobInfo{
+		Job:      parsedJob,
+		Warnings: warnings,
+	}
+	c.parsed.set(key, res)
+	return res, nil
+}
+
+// parseJob is the same as Jobs().ParseHCLOpts, which does not allow to pass a context
+func (c *Client) parseJob(ctx context.Context, j string, hclV1 bool) (*api.Job, error) {
+	parsedJob := &api.Job{}
+	opts := &api.WriteOptions{}
+	_, err := c.client.Raw().Write("/v1/jobs/parse", &api.JobsParseRequest{
+		JobHCL: j,
+		HCLv1:  hclV1,
+	}, parsedJob, opts.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
+	return parsedJob, nil
+}
 
-	return &application.JobInfo{
-		Job: parsedJob,
-	}, nil
+// jobNamespaceRegion resolves the namespace and region of a job. The values of the job itself are
+// preferred, as the namespace override of the source is already applied to the job, and the source
+// values are used as fallback.
+func jobNamespaceRegion(src *domain.Source, job *application.JobInfo) (string, string) {
+	namespace := src.Namespace
+	region := src.Region
+	if job != nil && job.Job != nil {
+		if job.Namespace != nil && *job.Namespace != "" {
+			namespace = *job.Namespace
+		}
+		if job.Region != nil && *job.Region != "" {
+			region = *job.Region
+		}
+	}
+	return namespace, region
 }
 
 func (c *Client) getQueryOptsCtx(ctx context.Context, src *domain.Source, job *application.JobInfo) *api.QueryOptions {
 
 	opts := &api.QueryOptions{}
-	if job != nil && job.Namespace != nil && *job.Namespace != "" {
-		opts.Namespace = *job.Namespace
-	}
-	if job != nil && job.Region != nil && *job.Region != "" {
-		opts.Region = *job.Region
-	}
-
-	// Src overrides job
-	if src.Namespace != "" {
-		opts.Namespace = src.Namespace
-	}
-	if src.Region != "" {
-		opts.Region = src.Region
-	}
+	opts.Namespace, opts.Region = jobNamespaceRegion(src, job)
 
 	return opts.WithContext(ctx)
 }
@@ -208,20 +438,7 @@ func (c *Client) getQueryOptsCtx(ctx context.Context, src *domain.Source, job *a
 func (c *Client) getWriteOptions(ctx context.Context, src *domain.Source, job *application.JobInfo) *api.WriteOptions {
 
 	opts := &api.WriteOptions{}
-	if job != nil && job.Namespace != nil && *job.Namespace != "" {
-		opts.Namespace = *job.Namespace
-	}
-	if job != nil && job.Region != nil && *job.Region != "" {
-		opts.Region = *job.Region
-	}
-
-	// Src overrides job
-	if src.Namespace != "" {
-		opts.Namespace = src.Namespace
-	}
-	if src.Region != "" {
-		opts.Region = src.Region
-	}
+	opts.Namespace, opts.Region = jobNamespaceRegion(src, job)
 
 	return opts.WithContext(ctx)
 }
@@ -243,8 +460,9 @@ func (c *Client) UpdateJob(ctx context.Context,
 				metaKeyOps: "true",
 			},
 		}, c.getWriteOptions(ctx, src, job))
+		c.audit(ctx, src, domain.AuditOperationRegisterNamespace, writeOptions, "", "", err)
 		if err != nil {
-			return nil, err
+			return nil, withErrorCode("", err)
 		}
 	}
 
@@ -264,67 +482,210 @@ func (c *Client) UpdateJob(ctx context.Context,
 	}
 
 	job.Meta = metadata
-	resp, _, err := c.client.Jobs().Plan(job.Job, true, c.getWriteOptions(ctx, src, job))
+	hash := ""
+	if src.SkipPlanDiff {
+		var err error
+		hash, err = specHash(job.Job)
+		if err != nil {
+			return nil, err
+		}
+		metadata[metaKeySpecHash] = hash
+	}
+	unsupported := unsupportedFeatures(job.Job, c.versions.get())
+	// without the diff Nomad only checks policies and feasibility, which is much faster for large jobs
+	var resp *api.JobPlanResponse
+	err := application.RunPhase(ctx, domain.ReconcilePhasePlan, func(ctx context.Context) error {
+		var err error
+		resp, _, err = c.client.Jobs().Plan(job.Job, !src.SkipPlanDiff, c.getWriteOptions(ctx, src, job))
+		return err
+	})
 
-	if err != nil {
+	if domain.TimedOutPhaseOf(err) != "" {
 		return nil, err
 	}
+	if err != nil {
+		if perr := withSentinelViolations(err); perr != nil {
+			return nil, perr
+		}
+		if c.namespaceMissing(ctx, src, job) {
+			return nil, domain.WithErrorCode(domain.ErrorCodeNamespaceMissing, err)
+		}
+		if len(unsupported) > 0 {
+			err = fmt.Errorf("%w (%s)", err, strings.Join(unsupported, "; "))
+		}
+		return nil, withErrorCode(domain.ErrorCodePlanFailed, err)
+	}
 
-	deploymentStatus := ""
+	resp.Diff = c.ignore.filter(resp.Diff)
+
+	warnings := append(append([]string{}, job.Warnings...), unsupported...)
+	var violations []*domain.PolicyViolation
+	if resp.Warnings != "" {
+		warnings = append(warnings, resp.Warnings)
+		// soft-mandatory and advisory policies
+		violations = parseSentinelViolations(resp.Warnings)
+	}
+
+	deploymentStatus := application.DeploymentStatus{}
 
 	deployment, _, err := c.client.Jobs().LatestDeployment(*job.ID, c.getQueryOptsCtx(ctx, src, job))
-	if err != nil {
-		if !strings.Contains(strings.ToLower(err.Error()), "not found") {
-			// low effort "not found" detection
-			return nil, err
-		}
+	if err != nil && !isNotFound(err) {
+		return nil, withErrorCode("", err)
 	}
 	if deployment != nil {
-		deploymentStatus = deployment.Status
-		c.logger.LogTrace(ctx, "DeploymentStatus:%s %v", *job.ID, deploymentStatus)
+		deploymentStatus = deploymentStatusOf(deployment)
+		c.logger.LogTrace(ctx, "DeploymentStatus:%s %v", *job.ID, deploymentStatus.Status)
+	}
+
+	updated := false
+	diffSummary := ""
+	if src.SkipPlanDiff {
+		updated, diffSummary = c.specChange(src, job, hash)
+		if !updated && (restart || src.Force) {
+			updated, diffSummary = true, fmt.Sprintf("Job %s: forced update", *job.ID)
+		}
+	} else {
+		updated, diffSummary = hasUpdate(resp, restart, src.Force), summarizeDiff(resp.Diff)
 	}
 
-	if !hasUpdate(resp, restart, src.Force) {
+	links := c.jobLinks(src, job, deployment, c.failedAllocation(ctx, src, job, deployment))
+
+	if !updated {
 		c.logger.LogTrace(ctx, "Job is already up to date.")
 
 		return &application.UpdateJobInfo{
-			DeploymentStatus: application.DeploymentStatus{
-				Status: deploymentStatus,
-			},
+			DeploymentStatus: deploymentStatus,
+			Links:            links,
+			Warnings:         warnings,
+			PolicyViolations: violations,
 		}, nil
 	}
 
 	c.logger.LogTrace(ctx, "Job Diff:%v", log.ToJSONString(resp.Diff))
 
 	if !src.Paused {
-		regResp, _, err := c.client.Jobs().Register(job.Job, c.getWriteOptions(ctx, src, job))
+		writeOptions := c.getWriteOptions(ctx, src, job)
+		var regResp *api.JobRegisterResponse
+		err := application.RunPhase(ctx, domain.ReconcilePhaseRegister, func(ctx context.Context) error {
+			var err error
+			regResp, _, err = c.client.Jobs().Register(job.Job, c.getWriteOptions(ctx, src, job))
+			return err
+		})
+		evalID := ""
+		if regResp != nil {
+			evalID = regResp.EvalID
+		}
+		c.audit(ctx, src, domain.AuditOperationRegisterJob, writeOptions, *job.ID, evalID, err)
+		// do not rely on the event stream for our own changes
+		c.cache.invalidate(src.ID)
 		if err != nil {
-			return nil, err
+			if perr := withSentinelViolations(err); perr != nil {
+				return nil, perr
+			}
+			if domain.TimedOutPhaseOf(err) != "" {
+				return nil, err
+			}
+			return nil, withErrorCode("", err)
 		}
 
 		c.logger.LogInfo(ctx, "Job Post:%v", log.ToJSONString(regResp))
 	}
 
 	return &application.UpdateJobInfo{
-		Updated: true, // TODO check for creation, for now everything is an update...which is kinda true
-		Diff:    json.RawMessage(log.ToJSONString(resp.Diff)),
-		DeploymentStatus: application.DeploymentStatus{
-			Status: deploymentStatus,
-		},
+		Updated:          true, // TODO check for creation, for now everything is an update...which is kinda true
+		Diff:             json.RawMessage(log.ToJSONString(resp.Diff)),
+		DiffSummary:      diffSummary,
+		DeploymentStatus: deploymentStatus,
+		Links:            links,
+		Warnings:         warnings,
+		PolicyViolations: violations,
 	}, nil
 }
 
+// namespaceMissing checks if a failed plan was caused by the namespace of the job not existing
+func (c *Client) namespaceMissing(ctx context.Context, src *domain.Source, job *application.JobInfo) bool {
+	opts := c.getQueryOptsCtx(ctx, src, job)
+	if opts.Namespace == "" {
+		return false
+	}
+	_, _, err := c.client.Namespaces().Info(opts.Namespace, opts)
+	return isNotFound(err)
+}
+
+// RevertJob registers a previous version of the job again
+func (c *Client) RevertJob(ctx context.Context, src *domain.Source, job *application.JobInfo, version uint64) error {
+	vaultToken := ""
+	if job.VaultToken != nil {
+		vaultToken = *job.VaultToken
+	}
+	writeOptions := c.getWriteOptions(ctx, src, job)
+	resp, _, err := c.client.Jobs().Revert(*job.ID, version, nil, writeOptions, "", vaultToken)
+	evalID := ""
+	if resp != nil {
+		evalID = resp.EvalID
+	}
+	c.audit(ctx, src, domain.AuditOperationRevertJob, writeOptions, *job.ID, evalID, err)
+	c.cache.invalidate(src.ID)
+	if err != nil {
+		return withErrorCode("", err)
+	}
+	return nil
+}
+
 func (c *Client) DeleteJob(ctx context.Context, src *domain.Source, job *application.JobInfo) error {
 
-	_, _, err := c.client.Jobs().Deregister(*job.Job.Name, false, c.getWriteOptions(ctx, src, job))
+	// the namespace of the job as found in the cluster is preferred over the one of the source
+	writeOptions := c.getWriteOptions(ctx, src, job)
+	evalID, _, err := c.client.Jobs().Deregister(*job.ID, false, writeOptions)
+	c.audit(ctx, src, domain.AuditOperationDeregisterJob, writeOptions, *job.ID, evalID, err)
+	c.cache.invalidate(src.ID)
 
+	if isNotFound(err) {
+		c.logger.LogInfo(ctx, "Job %s is already gone", *job.ID)
+		return nil
+	}
 	if err != nil {
-		return err
+		return withErrorCode("", err)
 	}
 
 	return nil
 }
 
+// observeJobsIndex records the index of a job listing, if it is newer than the last one
+func (c *Client) observeJobsIndex(index uint64) {
+	for {
+		last := atomic.LoadUint64(&c.lastJobsIndex)
+		if index <= last || atomic.CompareAndSwapUint64(&c.lastJobsIndex, last, index) {
+			return
+		}
+	}
+}
+
+// audit records a mutation on the Nomad API, failures to write the audit log are only logged
+func (c *Client) audit(ctx context.Context,
+	src *domain.Source,
+	op domain.AuditOperation,
+	opts *api.WriteOptions,
+	job, evalID string,
+	opErr error) {
+	if c.auditRepo == nil {
+		return
+	}
+	e := application.NewAuditEntry(ctx, src, op)
+	e.Job = job
+	e.Namespace = opts.Namespace
+	e.Region = opts.Region
+	e.EvalID = evalID
+	if opErr != nil {
+		e.Error = opErr.Error()
+	}
+	// the mutation already happened, do not lose the entry if the request context got cancelled
+	err := c.auditRepo.SaveAuditEntry(c.ctx, e)
+	if err != nil {
+		c.logger.LogError(ctx, "Could not SaveAuditEntry for %s %s:%v", op, job, err)
+	}
+}
+
 func (c *Client) GetURL(ctx context.Context) (string, error) {
 	return c.url, nil
 }
@@ -332,22 +693,34 @@ func (c *Client) GetURL(ctx context.Context) (string, error) {
 func (c *Client) GetCurrentClusterState(ctx context.Context,
 	opts application.GetCurrentClusterStateOptions) (*application.ClusterState, error) {
 
+	// the event stream only covers the region of the agent
+	useCache := opts.Source.Region == ""
+	if useCache {
+		if jobs, ok := c.cache.get(opts.Source.ID, time.Now()); ok {
+			list := make([]*api.Job, 0, len(jobs))
+			for _, j := range jobs {
+				list = append(list, j)
+			}
+			return application.NewClusterState(list), nil
+		}
+	}
+
 	queryOptions := &api.QueryOptions{
 		Namespace: "*", // Query all authorized namespaces
+		Region:    opts.Source.Region,
 		Params: map[string]string{
 			"meta": "true",
 		},
 		Filter: fmt.Sprintf(`"nomadopssrcid" in Meta and Meta["nomadopssrcid"] == "%s"`, opts.Source.ID),
 	}
-	joblist, _, err := c.client.Jobs().List(queryOptions.WithContext(ctx))
+	joblist, meta, err := c.client.Jobs().List(queryOptions.WithContext(ctx))
 	if err != nil {
-		return nil, err
-	}
-
-	clusterState := &application.ClusterState{
-		CurrentJobs: map[string]*application.JobInfo{},
+		return nil, withErrorCode("", err)
 	}
+	c.observeJobsIndex(meta.LastIndex)
 
+	jobs := map[string]*api.Job{}
+	list := []*api.Job{}
 	for _, job := range joblist {
 		m := job.Meta
 		// Ignore stuff that is not managed by us
@@ -361,17 +734,26 @@ func (c *Client) GetCurrentClusterState(ctx context.Context,
 
 		queryOptions := &api.QueryOptions{
 			Namespace: job.Namespace,
+			Region:    opts.Source.Region,
 		}
 
-		j, _, err := c.client.Jobs().Info(job.Name, queryOptions.WithContext(ctx))
+		j, _, err := c.client.Jobs().Info(job.ID, queryOptions.WithContext(ctx))
+		if isNotFound(err) {
+			// deleted since listing the jobs
+			continue
+		}
 		if err != nil {
-			return nil, err
+			return nil, withErrorCode("", err)
 		}
 
-		clusterState.CurrentJobs[job.Name] = &application.JobInfo{
-			Job: j,
-		}
+		jobs[cacheKey(j)] = j
+		list = append(list, j)
+	}
+
+	if useCache {
+		c.cache.set(opts.Source.ID, meta.LastIndex, jobs, time.Now())
 	}
+	c.index.setSource(opts.Source.ID, jobs)
 
-	return clusterState, nil
+	return application.NewClusterState(list), nil
 }
@@ -0,0 +1,401 @@
+package nomadcluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeResponse struct {
+	code int
+	// strings are written as is, everything else as json
+	body interface{}
+	// if set the request must target this namespace
+	namespace string
+}
+
+// createFakeNomadClient creates a client talking to a fake Nomad API serving the given routes,
+// keyed by "<method> <path>"
+func createFakeNomadClient(t *testing.T, routes map[string]fakeResponse) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			resp = fakeResponse{code: http.StatusInternalServerError, body: "unexpected request"}
+		}
+		if resp.namespace != "" && r.URL.Query().Get("namespace") != resp.namespace {
+			t.Errorf("Expected %s %s in namespace %s, got %q", r.Method, r.URL.Path, resp.namespace, r.URL.Query().Get("namespace"))
+		}
+		w.Header().Set("X-Nomad-Index", "1")
+		w.Header().Set("X-Nomad-LastContact", "0")
+		w.Header().Set("X-Nomad-KnownLeader", "true")
+		w.WriteHeader(resp.code)
+		if s, ok := resp.body.(string); ok {
+			_, _ = w.Write([]byte(s))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resp.body)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("NOMAD_ADDR", srv.URL)
+	t.Setenv("NOMAD_TOKEN", "")
+	t.Setenv("NOMAD_NAMESPACE", "")
+	t.Setenv("NOMAD_REGION", "")
+
+	c, err := CreateClient(context.Background(), log.NewSimpleLogger(false, "Test"), ClientConfig{}, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateClient:%v", err)
+	}
+	return c
+}
+
+func testJob(id, namespace string) *application.JobInfo {
+	return &application.JobInfo{
+		Job: &api.Job{
+			ID:        &id,
+			Name:      &id,
+			Namespace: &namespace,
+		},
+	}
+}
+
+var noDiff = fakeResponse{code: http.StatusOK, body: api.JobPlanResponse{Diff: &api.JobDiff{Type: "None"}}}
+
+func TestUpdateJobWithoutDeployment(t *testing.T) {
+	c := createFakeNomadClient(t, map[string]fakeResponse{
+		"PUT /v1/job/web/plan":       noDiff,
+		"GET /v1/job/web/deployment": {code: http.StatusNotFound, body: "job not found"},
+	})
+
+	info, err := c.UpdateJob(context.Background(), &domain.Source{ID: "src"}, testJob("web", "default"), false)
+	if err != nil {
+		t.Fatalf("Expected a missing deployment to be ignored, got:%v", err)
+	}
+	if info.Updated || info.DeploymentStatus.Status != "" {
+		t.Errorf("Unexpected info %+v", info)
+	}
+}
+
+func TestUpdateJobDeploymentError(t *testing.T) {
+	c := createFakeNomadClient(t, map[string]fakeResponse{
+		"PUT /v1/job/web/plan":       noDiff,
+		"GET /v1/job/web/deployment": {code: http.StatusInternalServerError, body: "rpc error: no leader"},
+	})
+
+	_, err := c.UpdateJob(context.Background(), &domain.Source{ID: "src"}, testJob("web", "default"), false)
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+}
+
+func TestUpdateJobErrorCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		routes   map[string]fakeResponse
+		expected domain.ErrorCode
+	}{
+		{
+			name: "acl",
+			routes: map[string]fakeResponse{
+				"PUT /v1/job/web/plan":   {code: http.StatusForbidden, body: "Permission denied"},
+				"GET /v1/namespace/team": {code: http.StatusOK, body: api.Namespace{Name: "team"}},
+			},
+			expected: domain.ErrorCodeACLDenied,
+		},
+		{
+			name: "namespace",
+			routes: map[string]fakeResponse{
+				"PUT /v1/job/web/plan":   {code: http.StatusBadRequest, body: `job "web" is in nonexistent namespace "team"`},
+				"GET /v1/namespace/team": {code: http.StatusNotFound, body: "namespace not found"},
+			},
+			expected: domain.ErrorCodeNamespaceMissing,
+		},
+		{
+			name: "plan",
+			routes: map[string]fakeResponse{
+				"PUT /v1/job/web/plan":   {code: http.StatusBadRequest, body: "invalid job"},
+				"GET /v1/namespace/team": {code: http.StatusOK, body: api.Namespace{Name: "team"}},
+			},
+			expected: domain.ErrorCodePlanFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createFakeNomadClient(t, tt.routes)
+
+			_, err := c.UpdateJob(context.Background(), &domain.Source{ID: "src"}, testJob("web", "team"), false)
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if code := domain.ErrorCodeOf(err, ""); code != tt.expected {
+				t.Errorf("Expected %s, got %q", tt.expected, code)
+			}
+		})
+	}
+}
+
+func TestDeleteJobAlreadyGone(t *testing.T) {
+	c := createFakeNomadClient(t, map[string]fakeResponse{
+		"DELETE /v1/job/web": {code: http.StatusNotFound, body: "job not found"},
+	})
+
+	err := c.DeleteJob(context.Background(), &domain.Source{ID: "src"}, testJob("web", "default"))
+	if err != nil {
+		t.Errorf("Expected a missing job to be ignored, got:%v", err)
+	}
+}
+
+func TestGetCurrentClusterStateSkipsDeletedJobs(t *testing.T) {
+	meta := map[string]string{metaKeySrcID: "src"}
+	c := createFakeNomadClient(t, map[string]fakeResponse{
+		"GET /v1/jobs": {code: http.StatusOK, body: []*api.JobListStub{
+			{ID: "web", Name: "web", Namespace: "default", Meta: meta},
+			{ID: "gone", Name: "gone", Namespace: "default", Meta: meta},
+		}},
+		"GET /v1/job/web":  {code: http.StatusOK, body: testJob("web", "default").Job},
+		"GET /v1/job/gone": {code: http.StatusNotFound, body: "job not found"},
+	})
+
+	state, err := c.GetCurrentClusterState(context.Background(), application.GetCurrentClusterStateOptions{
+		Source: &domain.Source{ID: "src"},
+	})
+	if err != nil {
+		t.Fatalf("Could not GetCurrentClusterState:%v", err)
+	}
+	if len(state.CurrentJobs) != 1 || state.CurrentJobs["web"] == nil {
+		t.Errorf("Unexpected jobs %v", state.CurrentJobs)
+	}
+}
+
+func TestParseJobLimits(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+	t.Setenv("NOMAD_ADDR", srv.URL)
+
+	c, err := CreateClient(context.Background(), log.NewSimpleLogger(false, "Test"), ClientConfig{
+		ParseTimeout:   time.Millisecond * 50,
+		MaxJobFileSize: 10,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateClient:%v", err)
+	}
+
+	for name, job := range map[string]string{
+		"size":    `job "web" {}`,
+		"timeout": `job "a" {}`,
+	} {
+		_, err = c.ParseJob(context.Background(), job, application.ParseJobOptions{})
+		if err == nil {
+			t.Fatalf("%s: Expected an error", name)
+		}
+		if code := domain.ErrorCodeOf(err, ""); code != domain.ErrorCodeParseError {
+			t.Errorf("%s: Expected %s, got %q", name, domain.ErrorCodeParseError, code)
+		}
+	}
+}
+
+func TestParseJobHCL1Fallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := api.JobsParseRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.HCLv1 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("Unsupported argument"))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(testJob("web", "default").Job)
+	}))
+	defer srv.Close()
+	t.Setenv("NOMAD_ADDR", srv.URL)
+
+	c, err := CreateClient(context.Background(), log.NewSimpleLogger(false, "Test"), ClientConfig{}, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateClient:%v", err)
+	}
+
+	_, err = c.ParseJob(context.Background(), `job "web" {}`, application.ParseJobOptions{})
+	if code := domain.ErrorCodeOf(err, ""); code != domain.ErrorCodeParseError {
+		t.Errorf("Expected %s without the HCL1 fallback, got %q", domain.ErrorCodeParseError, code)
+	}
+
+	j, err := c.ParseJob(context.Background(), `job "web" {}`, application.ParseJobOptions{HCL1Fallback: true})
+	if err != nil {
+		t.Fatalf("Could not ParseJob:%v", err)
+	}
+	if len(j.Warnings) != 1 {
+		t.Errorf("Expected a warning about the HCL1 parser, got %v", j.Warnings)
+	}
+
+	_, err = c.ParseJob(context.Background(), `job "web" {}`, application.ParseJobOptions{HCL1Fallback: true, Strict: true})
+	if code := domain.ErrorCodeOf(err, ""); code != domain.ErrorCodeParseError {
+		t.Errorf("Expected %s in strict mode, got %q", domain.ErrorCodeParseError, code)
+	}
+}
+
+func TestUpdateJobWarnings(t *testing.T) {
+	routes := map[string]fakeResponse{
+		"PUT /v1/job/web/plan": {code: http.StatusOK, body: api.JobPlanResponse{
+			Diff:     &api.JobDiff{Type: "None"},
+			Warnings: "Group \"web\" has a deprecated field",
+		}},
+		"GET /v1/job/web/deployment": {code: http.StatusOK, body: nil},
+	}
+	c := createFakeNomadClient(t, routes)
+
+	info, err := c.UpdateJob(context.Background(), &domain.Source{ID: "src"}, testJob("web", "default"), false)
+	if err != nil {
+		t.Fatalf("Could not UpdateJob:%v", err)
+	}
+	if len(info.Warnings) != 1 {
+		t.Errorf("Expected the plan warning, got %v", info.Warnings)
+	}
+
+	// strict parsing does not reject warnings of the plan
+	info, err = c.UpdateJob(context.Background(), &domain.Source{ID: "src", StrictParsing: true}, testJob("web", "default"), false)
+	if err != nil {
+		t.Fatalf("Could not UpdateJob in strict mode:%v", err)
+	}
+	if len(info.Warnings) != 1 {
+		t.Errorf("Expected the plan warning in strict mode, got %v", info.Warnings)
+	}
+}
+
+func TestJobNamespaceRegion(t *testing.T) {
+	region := "eu"
+	job := testJob("web", "team")
+	job.Region = &region
+
+	tests := []struct {
+		src       *domain.Source
+		job       *application.JobInfo
+		namespace string
+		region    string
+	}{
+		{&domain.Source{Namespace: "src", Region: "us"}, job, "team", "eu"},
+		{&domain.Source{Namespace: "src", Region: "us"}, testJob("web", ""), "src", "us"},
+		{&domain.Source{Namespace: "src"}, nil, "src", ""},
+	}
+	for i, tt := range tests {
+		namespace, region := jobNamespaceRegion(tt.src, tt.job)
+		if namespace != tt.namespace || region != tt.region {
+			t.Errorf("%d: Expected %s/%s, got %s/%s", i, tt.namespace, tt.region, namespace, region)
+		}
+	}
+}
+
+func TestDeleteJobByIDAndNamespace(t *testing.T) {
+	c := createFakeNomadClient(t, map[string]fakeResponse{
+		"DELETE /v1/job/web-id": {code: http.StatusOK, body: api.JobDeregisterResponse{EvalID: "eval"}, namespace: "team"},
+	})
+
+	job := testJob("web-id", "team")
+	name := "web"
+	job.Name = &name
+
+	// the source does not know about the namespace of the job
+	err := c.DeleteJob(context.Background(), &domain.Source{ID: "src"}, job)
+	if err != nil {
+		t.Errorf("Could not DeleteJob:%v", err)
+	}
+}
+
+func TestGetCurrentClusterStateByID(t *testing.T) {
+	meta := map[string]string{metaKeySrcID: "src"}
+	job := testJob("web-id", "team")
+	name := "web"
+	job.Name = &name
+	c := createFakeNomadClient(t, map[string]fakeResponse{
+		"GET /v1/jobs": {code: http.StatusOK, body: []*api.JobListStub{
+			{ID: "web-id", Name: "web", Namespace: "team", Meta: meta},
+		}},
+		"GET /v1/job/web-id": {code: http.StatusOK, body: job.Job, namespace: "team"},
+	})
+
+	state, err := c.GetCurrentClusterState(context.Background(), application.GetCurrentClusterStateOptions{
+		Source: &domain.Source{ID: "src"},
+	})
+	if err != nil {
+		t.Fatalf("Could not GetCurrentClusterState:%v", err)
+	}
+	if state.CurrentJobs["web"] == nil || *state.CurrentJobs["web"].ID != "web-id" {
+		t.Errorf("Unexpected jobs %v", state.CurrentJobs)
+	}
+}
+
+func TestSubscribeJobChangesReconnects(t *testing.T) {
+	eventStreamBackoff = 10 * time.Millisecond
+	defer func() { eventStreamBackoff = time.Second }()
+
+	indexes := make(chan string, 10)
+	var streams int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Nomad-Index", "1")
+		if r.URL.Path == "/v1/jobs" {
+			_ = json.NewEncoder(w).Encode([]*api.JobListStub{})
+			return
+		}
+		indexes <- r.URL.Query().Get("index")
+		// the first stream closes after one event, the second one stays open
+		n := atomic.AddInt32(&streams, 1)
+		job := testJob(fmt.Sprintf("job-%d", n), "default").Job
+		_ = json.NewEncoder(w).Encode(api.Events{Index: uint64(10 + n), Events: []api.Event{{
+			Topic:   api.TopicJob,
+			Type:    "JobRegistered",
+			Index:   uint64(10 + n),
+			Payload: map[string]interface{}{"Job": job},
+		}}})
+		w.(http.Flusher).Flush()
+		if n > 1 {
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("NOMAD_ADDR", srv.URL)
+
+	c, err := CreateClient(context.Background(), log.NewSimpleLogger(false, "Test"), ClientConfig{}, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateClient:%v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan string, 10)
+	err = c.SubscribeJobChanges(ctx, func(jobName string) {
+		changed <- jobName
+	})
+	if err != nil {
+		t.Fatalf("Could not SubscribeJobChanges:%v", err)
+	}
+
+	for _, expected := range []string{"job-1", "job-2"} {
+		select {
+		case name := <-changed:
+			if name != expected {
+				t.Errorf("Expected a change of %s, got %s", expected, name)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected a change of %s", expected)
+		}
+	}
+	<-indexes
+	if index := <-indexes; index != "11" {
+		t.Errorf("Expected to resubscribe from the last event index, got %s", index)
+	}
+}
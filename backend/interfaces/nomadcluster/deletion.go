@@ -0,0 +1,218 @@
+package nomadcluster
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+type DeletionJob struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	// Wave is the position of the job in the deletion order, jobs are deleted before the jobs they wait for
+	Wave int `json:"wave"`
+}
+
+// jobStopPollInterval is the interval the allocations of deleted jobs are checked at
+var jobStopPollInterval = 2 * time.Second
+
+type DeletionVariable struct {
+	Path      string `json:"path"`
+	Namespace string `json:"namespace"`
+}
+
+// SourceDeletionPlan lists the resources that are pruned when a source is deleted
+type SourceDeletionPlan struct {
+	Jobs []*DeletionJob `json:"jobs"`
+	// namespaces created for the source that contain no other jobs
+	Namespaces []string `json:"namespaces"`
+	// variables of the jobs, nomad/jobs/<id>
+	Variables []*DeletionVariable `json:"variables"`
+}
+
+// PlanSourceDeletion lists the jobs of the source, their variables and the namespaces only used by them
+func (c *Client) PlanSourceDeletion(ctx context.Context, src *domain.Source) (*SourceDeletionPlan, error) {
+	state, err := c.GetCurrentClusterState(ctx, application.GetCurrentClusterStateOptions{
+		Source: src,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SourceDeletionPlan{
+		Jobs:       []*DeletionJob{},
+		Namespaces: []string{},
+		Variables:  []*DeletionVariable{},
+	}
+	jobs := map[string]*api.Job{}
+	for k, j := range state.CurrentJobs {
+		jobs[k] = j.Job
+	}
+	wave := map[string]int{}
+	for i, keys := range application.DeletionWaves(jobs) {
+		for _, k := range keys {
+			wave[k] = i
+		}
+	}
+	namespaces := map[string]bool{}
+	for k, j := range state.CurrentJobs {
+		ns := strPtrToStr(j.Job.Namespace)
+		plan.Jobs = append(plan.Jobs, &DeletionJob{ID: *j.Job.ID, Namespace: ns, Wave: wave[k]})
+		namespaces[ns] = true
+
+		prefix := "nomad/jobs/" + *j.Job.ID
+		vars, _, err := c.client.Variables().PrefixList(prefix, (&api.QueryOptions{
+			Namespace: ns,
+			Region:    src.Region,
+		}).WithContext(ctx))
+		if err != nil {
+			return nil, withErrorCode("", err)
+		}
+		for _, v := range vars {
+			// the prefix also matches other jobs, e.g. nomad/jobs/web-2
+			if v.Path == prefix || strings.HasPrefix(v.Path, prefix+"/") {
+				plan.Variables = append(plan.Variables, &DeletionVariable{Path: v.Path, Namespace: ns})
+			}
+		}
+	}
+
+	if src.CreateNamespace {
+		for ns := range namespaces {
+			if ns == "default" {
+				continue
+			}
+			jobs, _, err := c.client.Jobs().List((&api.QueryOptions{
+				Namespace: ns,
+				Region:    src.Region,
+			}).WithContext(ctx))
+			if err != nil {
+				return nil, withErrorCode("", err)
+			}
+			used := false
+			for _, j := range jobs {
+				if j.Meta[metaKeySrcID] != src.ID {
+					used = true
+				}
+			}
+			if !used {
+				plan.Namespaces = append(plan.Namespaces, ns)
+			}
+		}
+	}
+
+	sort.Slice(plan.Jobs, func(a, b int) bool {
+		if plan.Jobs[a].Wave != plan.Jobs[b].Wave {
+			return plan.Jobs[a].Wave < plan.Jobs[b].Wave
+		}
+		return indexKey(plan.Jobs[a].Namespace, plan.Jobs[a].ID) < indexKey(plan.Jobs[b].Namespace, plan.Jobs[b].ID)
+	})
+	sort.Strings(plan.Namespaces)
+	sort.Slice(plan.Variables, func(a, b int) bool {
+		return indexKey(plan.Variables[a].Namespace, plan.Variables[a].Path) < indexKey(plan.Variables[b].Namespace, plan.Variables[b].Path)
+	})
+	return plan, nil
+}
+
+// PruneSource deletes the resources of the plan: jobs first, wave by wave, then their variables and namespaces.
+// Namespaces that cannot be deleted yet, e.g. while allocations of the jobs are still running, are returned.
+// If retain is set nothing is deleted, the jobs are recorded as orphaned in the audit log instead.
+func (c *Client) PruneSource(ctx context.Context, src *domain.Source, plan *SourceDeletionPlan, retain bool) ([]string, error) {
+	if retain {
+		for _, j := range plan.Jobs {
+			c.audit(ctx, src, domain.AuditOperationOrphanJob, &api.WriteOptions{
+				Namespace: j.Namespace,
+				Region:    src.Region,
+			}, j.ID, "", nil)
+		}
+		return nil, nil
+	}
+
+	var wave []*application.JobInfo
+	for i, j := range plan.Jobs {
+		if i > 0 && j.Wave != plan.Jobs[i-1].Wave {
+			c.WaitForJobsStopped(ctx, src, wave)
+			wave = nil
+		}
+		id, ns := j.ID, j.Namespace
+		job := &application.JobInfo{Job: &api.Job{ID: &id, Namespace: &ns}}
+		err := c.DeleteJob(ctx, src, job)
+		if err != nil {
+			return nil, err
+		}
+		c.index.applyJobEvent(&api.Job{ID: &id, Namespace: &ns}, true)
+		wave = append(wave, job)
+	}
+	for _, v := range plan.Variables {
+		writeOptions := &api.WriteOptions{
+			Namespace: v.Namespace,
+			Region:    src.Region,
+		}
+		_, err := c.client.Variables().Delete(v.Path, writeOptions.WithContext(ctx))
+		c.audit(ctx, src, domain.AuditOperationDeleteVariable, writeOptions, v.Path, "", err)
+		if err != nil && !isNotFound(err) {
+			return nil, withErrorCode("", err)
+		}
+	}
+	kept := []string{}
+	for _, ns := range plan.Namespaces {
+		writeOptions := &api.WriteOptions{
+			Region: src.Region,
+		}
+		_, err := c.client.Namespaces().Delete(ns, writeOptions.WithContext(ctx))
+		writeOptions.Namespace = ns
+		c.audit(ctx, src, domain.AuditOperationDeleteNamespace, writeOptions, "", "", err)
+		if err != nil && !isNotFound(err) {
+			c.logger.LogInfo(ctx, "Could not delete namespace %s of source %s yet:%v", ns, src.ID, err)
+			kept = append(kept, ns)
+		}
+	}
+	return kept, nil
+}
+
+// WaitForJobsStopped waits until no allocation of the deleted jobs is pending or running anymore, at most
+// cfg.JobStopTimeout. Jobs that are gone, e.g. purged, are stopped. Allocations still running after the timeout
+// are logged, the deletion continues.
+func (c *Client) WaitForJobsStopped(ctx context.Context, src *domain.Source, jobs []*application.JobInfo) {
+	if c.cfg.JobStopTimeout <= 0 || len(jobs) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.JobStopTimeout)
+	defer cancel()
+	for {
+		running := ""
+		for _, job := range jobs {
+			allocs, _, err := c.client.Jobs().Allocations(*job.ID, false, c.getQueryOptsCtx(ctx, src, job))
+			if isNotFound(err) {
+				continue
+			}
+			if err != nil {
+				c.logger.LogInfo(ctx, "Could not check the allocations of deleted job %s:%v", *job.ID, err)
+				running = *job.ID
+				break
+			}
+			for _, a := range allocs {
+				if a.ClientStatus == api.AllocClientStatusPending || a.ClientStatus == api.AllocClientStatusRunning {
+					running = *job.ID
+				}
+			}
+			if running != "" {
+				break
+			}
+		}
+		if running == "" {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			c.logger.LogInfo(ctx, "Allocations of deleted job %s still running after %v, continuing", running, c.cfg.JobStopTimeout)
+			return
+		case <-time.After(jobStopPollInterval):
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package nomadcluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestPruneSource(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{})
+	src := &domain.Source{ID: "src", Namespace: "team", CreateNamespace: true}
+
+	job, err := c.ParseJob(ctx, strings.Replace(fakeJobFile, "%s", "1.25", 1), application.ParseJobOptions{})
+	if err != nil {
+		t.Fatalf("Could not ParseJob:%v", err)
+	}
+	job.Job.Namespace = &src.Namespace
+	if _, err := c.UpdateJob(ctx, src, job, false); err != nil {
+		t.Fatalf("Could not UpdateJob:%v", err)
+	}
+
+	plan, err := c.PlanSourceDeletion(ctx, src)
+	if err != nil {
+		t.Fatalf("Could not PlanSourceDeletion:%v", err)
+	}
+	if len(plan.Jobs) != 1 || plan.Jobs[0].ID != "web" || plan.Jobs[0].Namespace != "team" {
+		t.Errorf("Expected the job web to be pruned, got %v", plan.Jobs)
+	}
+	if len(plan.Namespaces) != 1 || plan.Namespaces[0] != "team" {
+		t.Errorf("Expected the namespace team to be pruned, got %v", plan.Namespaces)
+	}
+
+	// retaining deletes nothing
+	if _, err := c.PruneSource(ctx, src, plan, true); err != nil {
+		t.Fatalf("Could not retain:%v", err)
+	}
+	if jobs, _ := c.ListSourceJobs(ctx, src); len(jobs) != 1 {
+		t.Errorf("Expected the job to be retained, got %v", jobs)
+	}
+
+	kept, err := c.PruneSource(ctx, src, plan, false)
+	if err != nil || len(kept) != 0 {
+		t.Fatalf("Could not PruneSource, kept %v:%v", kept, err)
+	}
+	if jobs, _ := c.ListSourceJobs(ctx, src); len(jobs) != 0 {
+		t.Errorf("Expected the job to be deleted, got %v", jobs)
+	}
+	if _, _, err := c.client.Namespaces().Info("team", nil); !isNotFound(err) {
+		t.Errorf("Expected the namespace to be deleted, got %v", err)
+	}
+}
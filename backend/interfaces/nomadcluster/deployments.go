@@ -0,0 +1,92 @@
+package nomadcluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+)
+
+type DeploymentOperation string
+
+const (
+	DeploymentOperationPause  DeploymentOperation = "pause"
+	DeploymentOperationResume DeploymentOperation = "resume"
+	DeploymentOperationFail   DeploymentOperation = "fail"
+)
+
+// UpdateSourceDeployment pauses, resumes or fails a deployment of a job of the source.
+// Deployments of other jobs are not found.
+func (c *Client) UpdateSourceDeployment(ctx context.Context,
+	src *domain.Source,
+	deploymentID string,
+	op DeploymentOperation) (*api.DeploymentUpdateResponse, error) {
+
+	deployments, err := c.ListSourceDeployments(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	var deployment *api.Deployment
+	for _, d := range deployments {
+		if d.ID == deploymentID {
+			deployment = d
+		}
+	}
+	if deployment == nil {
+		return nil, errors.ErrNotFound
+	}
+	if deployment.Status != "running" && deployment.Status != "paused" && deployment.Status != "pending" {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest,
+			fmt.Errorf("deployment %s is %s", deploymentID, deployment.Status))
+	}
+
+	job := &application.JobInfo{Job: &api.Job{ID: &deployment.JobID, Namespace: &deployment.Namespace}}
+	writeOptions := c.getWriteOptions(ctx, src, job)
+	var resp *api.DeploymentUpdateResponse
+	var auditOp domain.AuditOperation
+	switch op {
+	case DeploymentOperationPause, DeploymentOperationResume:
+		auditOp = domain.AuditOperationPauseDeployment
+		if op == DeploymentOperationResume {
+			auditOp = domain.AuditOperationResumeDeployment
+		}
+		resp, _, err = c.client.Deployments().Pause(deploymentID, op == DeploymentOperationPause, writeOptions)
+	case DeploymentOperationFail:
+		auditOp = domain.AuditOperationFailDeployment
+		resp, _, err = c.client.Deployments().Fail(deploymentID, writeOptions)
+	default:
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("unknown operation %s", op))
+	}
+	evalID := ""
+	if resp != nil {
+		evalID = resp.EvalID
+	}
+	c.audit(ctx, src, auditOp, writeOptions, deployment.JobID, evalID, err)
+	c.cache.invalidate(src.ID)
+	if err != nil {
+		return nil, withErrorCode("", err)
+	}
+	return resp, nil
+}
+
+// FailDeployment fails a deployment of a job of the source, e.g. one that is stuck
+func (c *Client) FailDeployment(ctx context.Context, src *domain.Source, deploymentID string) error {
+	_, err := c.UpdateSourceDeployment(ctx, src, deploymentID, DeploymentOperationFail)
+	return err
+}
+
+// deploymentStatusOf summarizes the deployment, the healthy allocations are counted over all task groups
+func deploymentStatusOf(deployment *api.Deployment) application.DeploymentStatus {
+	s := application.DeploymentStatus{
+		Status: deployment.Status,
+		ID:     deployment.ID,
+	}
+	for _, tg := range deployment.TaskGroups {
+		s.HealthyAllocs += tg.HealthyAllocs
+	}
+	return s
+}
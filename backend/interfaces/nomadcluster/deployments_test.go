@@ -0,0 +1,92 @@
+package nomadcluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+)
+
+func TestUpdateSourceDeployment(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{DeploymentDuration: time.Minute})
+	src := &domain.Source{ID: "src"}
+
+	job, err := c.ParseJob(ctx, strings.Replace(fakeJobFile, "%s", "1.25", 1), application.ParseJobOptions{})
+	if err != nil {
+		t.Fatalf("Could not ParseJob:%v", err)
+	}
+	if _, err := c.UpdateJob(ctx, src, job, false); err != nil {
+		t.Fatalf("Could not UpdateJob:%v", err)
+	}
+	deployments, err := c.ListSourceDeployments(ctx, src)
+	if err != nil || len(deployments) != 1 {
+		t.Fatalf("Expected a deployment, got %v %v", deployments, err)
+	}
+	id := deployments[0].ID
+
+	status := func() string {
+		deployments, err := c.ListSourceDeployments(ctx, src)
+		if err != nil {
+			t.Fatalf("Could not ListSourceDeployments:%v", err)
+		}
+		return deployments[0].Status
+	}
+	for _, step := range []struct {
+		op     DeploymentOperation
+		status string
+	}{
+		{DeploymentOperationPause, "paused"},
+		{DeploymentOperationResume, "running"},
+		{DeploymentOperationFail, "failed"},
+	} {
+		if _, err := c.UpdateSourceDeployment(ctx, src, id, step.op); err != nil {
+			t.Fatalf("Could not %s the deployment:%v", step.op, err)
+		}
+		if s := status(); s != step.status {
+			t.Errorf("Expected the deployment to be %s after %s, got %s", step.status, step.op, s)
+		}
+	}
+
+	_, err = c.UpdateSourceDeployment(ctx, src, id, DeploymentOperationResume)
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeInvalidRequest {
+		t.Errorf("Expected a finished deployment to be rejected, got %v", err)
+	}
+	_, err = c.UpdateSourceDeployment(ctx, &domain.Source{ID: "other"}, id, DeploymentOperationPause)
+	if err != errors.ErrNotFound {
+		t.Errorf("Expected the deployment of another source not to be found, got %v", err)
+	}
+}
+
+func TestFailDeployment(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{DeploymentDuration: time.Minute})
+	src := &domain.Source{ID: "src"}
+
+	job, err := c.ParseJob(ctx, strings.Replace(fakeJobFile, "%s", "1.25", 1), application.ParseJobOptions{})
+	if err != nil {
+		t.Fatalf("Could not ParseJob:%v", err)
+	}
+	if _, err := c.UpdateJob(ctx, src, job, false); err != nil {
+		t.Fatalf("Could not UpdateJob:%v", err)
+	}
+	info, err := c.UpdateJob(ctx, src, job, false)
+	if err != nil {
+		t.Fatalf("Could not UpdateJob:%v", err)
+	}
+	if info.DeploymentStatus.Status != "running" || info.DeploymentStatus.ID == "" {
+		t.Fatalf("Expected the running deployment, got %+v", info.DeploymentStatus)
+	}
+
+	if err := c.FailDeployment(ctx, src, info.DeploymentStatus.ID); err != nil {
+		t.Fatalf("Could not FailDeployment:%v", err)
+	}
+	deployments, err := c.ListSourceDeployments(ctx, src)
+	if err != nil || len(deployments) != 1 || deployments[0].Status != "failed" {
+		t.Errorf("Expected the deployment to be failed, got %v %v", deployments, err)
+	}
+}
@@ -0,0 +1,257 @@
+package nomadcluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+)
+
+const (
+	diffTypeAdded   = "Added"
+	diffTypeDeleted = "Deleted"
+	diffTypeEdited  = "Edited"
+)
+
+// summarizeDiff renders a JobDiff returned by a plan into a short, human readable
+// text, e.g. to be used in notifications.
+// Changes to our own bookkeeping meta keys are not part of the summary, so a diff
+// that only touches those (new commit, forced restart) results in an empty summary.
+func summarizeDiff(diff *api.JobDiff) string {
+	if diff == nil {
+		return ""
+	}
+
+	var lines []string
+
+	switch diff.Type {
+	case diffTypeAdded:
+		return application.JobCreatedSummary(diff.ID)
+	case diffTypeDeleted:
+		return application.JobDeletedSummary(diff.ID)
+	}
+
+	for _, f := range diff.Fields {
+		if isOwnMetaField(f.Name) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Job: %s", describeField(f)))
+	}
+	if names := changedObjectNames(diff.Objects); len(names) > 0 {
+		lines = append(lines, fmt.Sprintf("Job: changed %s", strings.Join(names, ", ")))
+	}
+
+	for _, tg := range diff.TaskGroups {
+		lines = append(lines, summarizeTaskGroupDiff(tg)...)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func summarizeTaskGroupDiff(tg *api.TaskGroupDiff) []string {
+	switch tg.Type {
+	case diffTypeAdded:
+		return []string{fmt.Sprintf("Group %s: added", tg.Name)}
+	case diffTypeDeleted:
+		return []string{fmt.Sprintf("Group %s: removed", tg.Name)}
+	}
+
+	var lines []string
+	var others []string
+	for _, f := range tg.Fields {
+		if f.Name == "Count" {
+			lines = append(lines, fmt.Sprintf("Group %s: count %s -> %s", tg.Name, valueOrNone(f.Old), valueOrNone(f.New)))
+			continue
+		}
+		others = append(others, f.Name)
+	}
+	others = append(others, changedObjectNames(tg.Objects)...)
+	if len(others) > 0 {
+		lines = append(lines, fmt.Sprintf("Group %s: changed %s", tg.Name, strings.Join(others, ", ")))
+	}
+
+	for _, t := range tg.Tasks {
+		lines = append(lines, summarizeTaskDiff(tg.Name, t)...)
+	}
+	return lines
+}
+
+func summarizeTaskDiff(group string, t *api.TaskDiff) []string {
+	prefix := fmt.Sprintf("Task %s/%s", group, t.Name)
+	switch t.Type {
+	case diffTypeAdded:
+		return []string{prefix + ": added"}
+	case diffTypeDeleted:
+		return []string{prefix + ": removed"}
+	}
+
+	var lines []string
+	var others []string
+	for _, f := range t.Fields {
+		others = append(others, f.Name)
+	}
+	for _, o := range t.Objects {
+		if o.Name == "Config" {
+			var cfgFields []string
+			for _, f := range o.Fields {
+				if f.Name == "image" {
+					lines = append(lines, fmt.Sprintf("%s: image %s -> %s", prefix, valueOrNone(f.Old), valueOrNone(f.New)))
+					continue
+				}
+				cfgFields = append(cfgFields, "Config."+f.Name)
+			}
+			others = append(others, cfgFields...)
+			others = append(others, changedObjectNames(o.Objects)...)
+			continue
+		}
+		others = append(others, o.Name)
+	}
+	if len(others) > 0 {
+		lines = append(lines, fmt.Sprintf("%s: changed %s", prefix, strings.Join(uniqueSorted(others), ", ")))
+	}
+	return lines
+}
+
+func describeField(f *api.FieldDiff) string {
+	switch f.Type {
+	case diffTypeAdded:
+		return fmt.Sprintf("%s set to %s", f.Name, f.New)
+	case diffTypeDeleted:
+		return fmt.Sprintf("%s removed (was %s)", f.Name, f.Old)
+	}
+	return fmt.Sprintf("%s %s -> %s", f.Name, valueOrNone(f.Old), valueOrNone(f.New))
+}
+
+func changedObjectNames(objs []*api.ObjectDiff) []string {
+	var names []string
+	for _, o := range objs {
+		names = append(names, o.Name)
+	}
+	return uniqueSorted(names)
+}
+
+func isOwnMetaField(name string) bool {
+	for _, k := range []string{metaKeySrcCommit, metaKeyForceRestart, metaKeySpecHash} {
+		if name == fmt.Sprintf("Meta[%s]", k) {
+			return true
+		}
+	}
+	return false
+}
+
+func valueOrNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+func uniqueSorted(in []string) []string {
+	seen := map[string]bool{}
+	var res []string
+	for _, s := range in {
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		res = append(res, s)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// defaultDiffIgnore are the fields Nomad sets or removes on the server, they would show up in every plan.
+// Paths are <Job|Group|Task>.<Field>, Meta[key] or Constraint[<LTarget>] for constraints.
+var defaultDiffIgnore = []string{
+	// tokens are only used for the submission and never returned
+	"Job.ConsulToken",
+	"Job.VaultToken",
+	// implicit constraints of vault, consul and signal blocks
+	"Group.Constraint[${attr.vault.version}]",
+	"Group.Constraint[${attr.consul.version}]",
+	"Group.Constraint[${attr.os.signals}]",
+	"Task.Constraint[${attr.vault.version}]",
+	"Task.Constraint[${attr.consul.version}]",
+	"Task.Constraint[${attr.os.signals}]",
+}
+
+type diffIgnore map[string]bool
+
+// newDiffIgnore returns the built-in paths and the extra ones
+func newDiffIgnore(extra []string) diffIgnore {
+	ig := diffIgnore{}
+	for _, p := range append(append([]string{}, defaultDiffIgnore...), extra...) {
+		if p = strings.TrimSpace(p); p != "" {
+			ig[p] = true
+		}
+	}
+	return ig
+}
+
+func (ig diffIgnore) fields(level string, fields []*api.FieldDiff) []*api.FieldDiff {
+	var res []*api.FieldDiff
+	for _, f := range fields {
+		if !ig[level+"."+f.Name] {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+func (ig diffIgnore) objects(level string, objs []*api.ObjectDiff) []*api.ObjectDiff {
+	var res []*api.ObjectDiff
+	for _, o := range objs {
+		name := o.Name
+		if name == "Constraint" {
+			for _, f := range o.Fields {
+				if f.Name == "LTarget" {
+					name = fmt.Sprintf("Constraint[%s]", f.New+f.Old)
+				}
+			}
+		}
+		if !ig[level+"."+name] {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// filter returns a copy of the diff without the ignored fields, groups and tasks without changes left are removed
+func (ig diffIgnore) filter(diff *api.JobDiff) *api.JobDiff {
+	if diff == nil || diff.Type != diffTypeEdited {
+		return diff
+	}
+	res := *diff
+	res.Fields = ig.fields("Job", diff.Fields)
+	res.Objects = ig.objects("Job", diff.Objects)
+	res.TaskGroups = nil
+	for _, tg := range diff.TaskGroups {
+		if tg.Type != diffTypeEdited {
+			res.TaskGroups = append(res.TaskGroups, tg)
+			continue
+		}
+		g := *tg
+		g.Fields = ig.fields("Group", tg.Fields)
+		g.Objects = ig.objects("Group", tg.Objects)
+		g.Tasks = nil
+		for _, t := range tg.Tasks {
+			if t.Type != diffTypeEdited {
+				g.Tasks = append(g.Tasks, t)
+				continue
+			}
+			task := *t
+			task.Fields = ig.fields("Task", t.Fields)
+			task.Objects = ig.objects("Task", t.Objects)
+			if len(task.Fields) > 0 || len(task.Objects) > 0 {
+				g.Tasks = append(g.Tasks, &task)
+			}
+		}
+		if len(g.Fields) > 0 || len(g.Objects) > 0 || len(g.Tasks) > 0 {
+			res.TaskGroups = append(res.TaskGroups, &g)
+		}
+	}
+	return &res
+}
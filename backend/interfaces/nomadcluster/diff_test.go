@@ -0,0 +1,164 @@
+package nomadcluster
+
+import (
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+func TestSummarizeDiff(t *testing.T) {
+	diff := &api.JobDiff{
+		Type: diffTypeEdited,
+		ID:   "web",
+		Fields: []*api.FieldDiff{
+			{Type: diffTypeEdited, Name: "Meta[nomadopssrccommit]", Old: "a", New: "b"},
+			{Type: diffTypeEdited, Name: "Priority", Old: "50", New: "60"},
+		},
+		TaskGroups: []*api.TaskGroupDiff{
+			{
+				Type: diffTypeEdited,
+				Name: "web",
+				Fields: []*api.FieldDiff{
+					{Type: diffTypeEdited, Name: "Count", Old: "2", New: "3"},
+				},
+				Tasks: []*api.TaskDiff{
+					{
+						Type: diffTypeEdited,
+						Name: "app",
+						Objects: []*api.ObjectDiff{
+							{
+								Type: diffTypeEdited,
+								Name: "Config",
+								Fields: []*api.FieldDiff{
+									{Type: diffTypeEdited, Name: "image", Old: "nginx:1.24", New: "nginx:1.25"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	expected := "Job: Priority 50 -> 60\n" +
+		"Group web: count 2 -> 3\n" +
+		"Task web/app: image nginx:1.24 -> nginx:1.25"
+
+	if s := summarizeDiff(diff); s != expected {
+		t.Errorf("Unexpected summary:\n%s\nexpected:\n%s", s, expected)
+	}
+
+	if s := summarizeDiff(&api.JobDiff{Type: diffTypeAdded, ID: "web"}); s != "Job web: created" {
+		t.Errorf("Unexpected summary for added job:%s", s)
+	}
+}
+
+func TestSummarizeDiffOnlyOwnMeta(t *testing.T) {
+	diff := &api.JobDiff{
+		Type: diffTypeEdited,
+		ID:   "web",
+		Fields: []*api.FieldDiff{
+			{Type: diffTypeEdited, Name: "Meta[nomadopssrccommit]", Old: "a", New: "b"},
+			{Type: diffTypeAdded, Name: "Meta[nomadopsforcerestart]", New: "2023-01-01T00:00:00Z"},
+		},
+	}
+	if s := summarizeDiff(diff); s != "" {
+		t.Errorf("Expected an empty summary, got:%s", s)
+	}
+}
+
+func TestSummarizeDiffGroupsAndTasks(t *testing.T) {
+	diff := &api.JobDiff{
+		Type: diffTypeEdited,
+		ID:   "web",
+		TaskGroups: []*api.TaskGroupDiff{
+			{Type: diffTypeAdded, Name: "cache"},
+			{Type: diffTypeDeleted, Name: "legacy"},
+			{
+				Type: diffTypeEdited,
+				Name: "web",
+				Tasks: []*api.TaskDiff{
+					{Type: diffTypeAdded, Name: "sidecar"},
+					{
+						Type: diffTypeEdited,
+						Name: "app",
+						Objects: []*api.ObjectDiff{
+							{
+								Type: diffTypeEdited,
+								Name: "Config",
+								Fields: []*api.FieldDiff{
+									{Type: diffTypeEdited, Name: "ports", Old: "http", New: "https"},
+									{Type: diffTypeAdded, Name: "command", New: "/bin/app"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	expected := "Group cache: added\n" +
+		"Group legacy: removed\n" +
+		"Task web/sidecar: added\n" +
+		"Task web/app: changed Config.command, Config.ports"
+
+	if s := summarizeDiff(diff); s != expected {
+		t.Errorf("Unexpected summary:\n%s\nexpected:\n%s", s, expected)
+	}
+}
+
+func TestDescribeField(t *testing.T) {
+	tests := []struct {
+		f        *api.FieldDiff
+		expected string
+	}{
+		{&api.FieldDiff{Type: diffTypeAdded, Name: "Priority", New: "60"}, "Priority set to 60"},
+		{&api.FieldDiff{Type: diffTypeDeleted, Name: "Priority", Old: "50"}, "Priority removed (was 50)"},
+		{&api.FieldDiff{Type: diffTypeEdited, Name: "Priority", Old: "", New: "60"}, "Priority <none> -> 60"},
+	}
+	for _, tt := range tests {
+		if s := describeField(tt.f); s != tt.expected {
+			t.Errorf("Unexpected description:%s - expected:%s", s, tt.expected)
+		}
+	}
+}
+
+func TestDiffIgnore(t *testing.T) {
+	diff := &api.JobDiff{
+		Type: diffTypeEdited,
+		ID:   "web",
+		Fields: []*api.FieldDiff{
+			{Type: diffTypeDeleted, Name: "VaultToken", Old: "s.123"},
+			{Type: diffTypeEdited, Name: "Meta[owner]", Old: "a", New: "b"},
+		},
+		TaskGroups: []*api.TaskGroupDiff{
+			{
+				Type: diffTypeEdited,
+				Name: "web",
+				Objects: []*api.ObjectDiff{
+					{
+						Type: diffTypeAdded,
+						Name: "Constraint",
+						Fields: []*api.FieldDiff{
+							{Type: diffTypeAdded, Name: "LTarget", New: "${attr.vault.version}"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	filtered := newDiffIgnore(nil).filter(diff)
+	if len(filtered.TaskGroups) != 0 || len(filtered.Fields) != 1 {
+		t.Errorf("Expected only Meta[owner] to be left, got %+v", filtered)
+	}
+	if len(diff.Fields) != 2 || len(diff.TaskGroups) != 1 {
+		t.Errorf("Expected the plan diff to be unchanged")
+	}
+
+	filtered = newDiffIgnore([]string{"Job.Meta[owner]"}).filter(diff)
+	if hasUpdate(&api.JobPlanResponse{Diff: filtered}, false, false) {
+		t.Errorf("Expected no update, got %+v", filtered)
+	}
+}
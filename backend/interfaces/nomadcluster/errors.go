@@ -0,0 +1,35 @@
+package nomadcluster
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// statusCode returns the HTTP status code of an error returned by the Nomad API client or 0
+// if the request did not get a response
+func statusCode(err error) int {
+	var respErr api.UnexpectedResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode()
+	}
+	return 0
+}
+
+func isNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+// withErrorCode attaches ACL_DENIED to errors caused by insufficient token permissions
+// and the given code, if any, to all other errors
+func withErrorCode(code domain.ErrorCode, err error) error {
+	if statusCode(err) == http.StatusForbidden {
+		return domain.WithErrorCode(domain.ErrorCodeACLDenied, err)
+	}
+	if code == "" {
+		return err
+	}
+	return domain.WithErrorCode(code, err)
+}
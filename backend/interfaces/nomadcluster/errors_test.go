@@ -0,0 +1,79 @@
+package nomadcluster
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// responseError returns the error of the Nomad API client for a response with the given status code
+func responseError(t *testing.T, code int, body string) error {
+	c := createFakeNomadClient(t, map[string]fakeResponse{
+		"GET /v1/job/web": {code: code, body: body},
+	})
+	_, _, err := c.client.Jobs().Info("web", nil)
+	if err == nil {
+		t.Fatalf("Expected an error for status %d", code)
+	}
+	return err
+}
+
+func TestStatusCode(t *testing.T) {
+	if code := statusCode(responseError(t, http.StatusNotFound, "job not found")); code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", code)
+	}
+	if code := statusCode(fmt.Errorf("job web: %w", responseError(t, http.StatusForbidden, "Permission denied"))); code != http.StatusForbidden {
+		t.Errorf("Expected 403 of a wrapped error, got %d", code)
+	}
+	if code := statusCode(fmt.Errorf("Unexpected response code: 404 (job not found)")); code != 0 {
+		t.Errorf("Expected no status code of errors without a response, got %d", code)
+	}
+}
+
+func TestWithErrorCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		code     domain.ErrorCode
+		expected domain.ErrorCode
+	}{
+		{responseError(t, http.StatusForbidden, "Permission denied"), domain.ErrorCodePlanFailed, domain.ErrorCodeACLDenied},
+		{responseError(t, http.StatusInternalServerError, "rpc error"), domain.ErrorCodePlanFailed, domain.ErrorCodePlanFailed},
+		{fmt.Errorf("connection refused"), domain.ErrorCodeParseError, domain.ErrorCodeParseError},
+		{fmt.Errorf("connection refused"), "", ""},
+	}
+	for _, tt := range tests {
+		code := domain.ErrorCodeOf(withErrorCode(tt.code, tt.err), "")
+		if code != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.err, tt.expected, code)
+		}
+	}
+}
+
+func TestSentinelViolations(t *testing.T) {
+	err := fmt.Errorf(`Unexpected response code: 500 (1 error occurred:
+	* require-digests : Result: false (allowed failures: 0 in enforcement level: hard-mandatory)
+
+FALSE - require-digests:7:1 - Rule "main"
+  FALSE - require-digests:3:1 - Rule "digests"
+
+* limit-memory : Result: false
+
+FALSE - limit-memory:2:1 - Rule "main"
+
+)`)
+	perr := withSentinelViolations(err)
+	if domain.ErrorCodeOf(perr, "") != domain.ErrorCodePolicyViolation {
+		t.Fatalf("Expected a policy violation, got %v", perr)
+	}
+	if perr.Error() != "rejected by Sentinel policies require-digests (hard-mandatory) rules main, digests; limit-memory rules main" {
+		t.Errorf("Unexpected message %q", perr.Error())
+	}
+	if len(domain.PolicyViolationsOf(fmt.Errorf("job web: %w", perr))) != 2 {
+		t.Errorf("Expected the violations to be found in wrapped errors")
+	}
+	if withSentinelViolations(fmt.Errorf("Unexpected response code: 500 (rpc error)")) != nil {
+		t.Errorf("Expected no policy violation")
+	}
+}
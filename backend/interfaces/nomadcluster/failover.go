@@ -0,0 +1,220 @@
+package nomadcluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// srvRefreshInterval is the time resolved SRV records are used before they are looked up again
+const srvRefreshInterval = 30 * time.Second
+
+// lookupSRV resolves the SRV records of a name, replaced in tests
+var lookupSRV = net.DefaultResolver.LookupSRV
+
+// failoverTransport sends the requests of the Nomad API to the first reachable of several agents, given as
+// comma separated addresses or as SRV record (srv+http://name or srv+https://name). Requests stick to the agent
+// that answered last, the next agents are only tried if it cannot be reached.
+type failoverTransport struct {
+	ctx    context.Context
+	logger log.Logger
+	next   http.RoundTripper
+
+	// the fixed addresses, or the name and scheme of the SRV records
+	addresses []*url.URL
+	srvName   string
+	srvScheme string
+
+	lock       sync.Mutex
+	endpoints  []*url.URL
+	resolvedAt time.Time
+	current    string
+}
+
+// isFailoverAddress returns true if the address needs a failoverTransport
+func isFailoverAddress(address string) bool {
+	return strings.Contains(address, ",") || strings.HasPrefix(address, "srv+")
+}
+
+func newFailoverTransport(ctx context.Context,
+	logger log.Logger,
+	address string,
+	next http.RoundTripper) (*failoverTransport, error) {
+
+	t := &failoverTransport{
+		ctx:    ctx,
+		logger: logger,
+		next:   next,
+	}
+	for _, a := range strings.Split(address, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		u, err := url.Parse(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nomad address %s:%v", a, err)
+		}
+		switch u.Scheme {
+		case "http", "https":
+			if u.Host == "" {
+				return nil, fmt.Errorf("nomad address %s has no host", a)
+			}
+			t.addresses = append(t.addresses, &url.URL{Scheme: u.Scheme, Host: u.Host})
+		case "srv+http", "srv+https":
+			if u.Hostname() == "" || u.Port() != "" {
+				return nil, fmt.Errorf("nomad address %s must name the SRV records without port", a)
+			}
+			t.srvName = u.Hostname()
+			t.srvScheme = strings.TrimPrefix(u.Scheme, "srv+")
+		default:
+			return nil, fmt.Errorf("nomad address %s must use http, https, srv+http or srv+https", a)
+		}
+	}
+	if t.srvName != "" && len(t.addresses) > 0 {
+		return nil, fmt.Errorf("nomad address %s mixes SRV records and addresses", address)
+	}
+	if t.srvName == "" && len(t.addresses) == 0 {
+		return nil, fmt.Errorf("nomad address %s has no addresses", address)
+	}
+	t.endpoints = t.addresses
+	return t, nil
+}
+
+// uiAddress returns the address of the Nomad UI for the address of the API, the first one of several addresses
+func uiAddress(address string) string {
+	first := strings.TrimSpace(strings.Split(address, ",")[0])
+	return strings.TrimPrefix(first, "srv+")
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoints, err := t.currentEndpoints(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for i, endpoint := range endpoints {
+		r := req.Clone(req.Context())
+		if i > 0 {
+			if req.Body != nil && req.GetBody == nil {
+				// the body was consumed and cannot be sent again
+				break
+			}
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+		r.URL.Scheme = endpoint.Scheme
+		r.URL.Host = endpoint.Host
+		r.Host = ""
+
+		resp, err := t.next.RoundTrip(r)
+		if err == nil {
+			t.use(endpoint)
+			return resp, nil
+		}
+		lastErr = err
+		if !isUnreachable(err) || req.Context().Err() != nil {
+			return nil, err
+		}
+		if i+1 < len(endpoints) {
+			t.logger.LogError(t.ctx, "Nomad agent %s is unreachable, failing over to %s:%v", endpoint.Host, endpoints[i+1].Host, err)
+		}
+	}
+	// resolve the SRV records again with the next request
+	t.lock.Lock()
+	t.resolvedAt = time.Time{}
+	t.lock.Unlock()
+	return nil, lastErr
+}
+
+// currentEndpoints returns the endpoints starting with the one that answered last,
+// SRV records are resolved again after srvRefreshInterval
+func (t *failoverTransport) currentEndpoints(ctx context.Context) ([]*url.URL, error) {
+	t.lock.Lock()
+	resolve := t.srvName != "" && time.Since(t.resolvedAt) > srvRefreshInterval
+	t.lock.Unlock()
+
+	if resolve {
+		endpoints, err := t.resolve(ctx)
+		t.lock.Lock()
+		if err != nil && len(t.endpoints) == 0 {
+			t.lock.Unlock()
+			return nil, err
+		}
+		if err != nil {
+			// keep using the last known agents
+			t.logger.LogError(t.ctx, "Could not resolve the SRV records %s, using the last known agents:%v", t.srvName, err)
+		} else {
+			t.endpoints = endpoints
+		}
+		t.resolvedAt = time.Now()
+		t.lock.Unlock()
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	res := []*url.URL{}
+	for i, e := range t.endpoints {
+		if e.Host == t.current {
+			res = append(res, t.endpoints[i:]...)
+			res = append(res, t.endpoints[:i]...)
+			return res, nil
+		}
+	}
+	return append(res, t.endpoints...), nil
+}
+
+// resolve looks up the SRV records, ordered by priority and weight
+func (t *failoverTransport) resolve(ctx context.Context) ([]*url.URL, error) {
+	_, records, err := lookupSRV(ctx, "", "", t.srvName)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no SRV records of %s", t.srvName)
+	}
+	endpoints := []*url.URL{}
+	for _, r := range records {
+		endpoints = append(endpoints, &url.URL{
+			Scheme: t.srvScheme,
+			Host:   net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))),
+		})
+	}
+	return endpoints, nil
+}
+
+func (t *failoverTransport) use(endpoint *url.URL) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.current = endpoint.Host
+}
+
+// isUnreachable returns true if the agent could not be connected to, the request was not sent
+func isUnreachable(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+func (t *failoverTransport) CloseIdleConnections() {
+	if ci, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
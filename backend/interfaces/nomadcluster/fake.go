@@ -0,0 +1,736 @@
+package nomadcluster
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// FakeConfig configures the in-memory Nomad cluster, which replaces the Nomad API for development and e2e tests
+// fakeNomadVersion is the version of the agents of the in-memory cluster
+const fakeNomadVersion = "1.6.1"
+
+type FakeConfig struct {
+	// DeploymentDuration is the time until the deployment of a service job finishes
+	DeploymentDuration time.Duration
+	// FailurePercent is the probability in percent of plans, registrations and deregistrations to fail
+	FailurePercent int
+	// FailJobs are the jobs whose registration always fails
+	FailJobs []string
+	// FailDeployments are the jobs whose deployments fail
+	FailDeployments []string
+	// Latency is added to every request
+	Latency time.Duration
+	// DenyCapabilities are the capabilities the token lacks, requests needing them fail with 403, see Capability
+	DenyCapabilities []string
+}
+
+// fakeNomad serves the parts of the Nomad API used by the client and the UI from memory
+type fakeNomad struct {
+	cfg FakeConfig
+
+	lock        sync.Mutex
+	index       uint64
+	namespaces  map[string]*api.Namespace
+	jobs        map[string]*api.Job
+	versions    map[string][]*api.Job
+	deployments map[string][]*api.Deployment
+	variables   map[string]*api.Variable
+	subscribers map[chan *api.Events]struct{}
+}
+
+func newFakeNomad(cfg FakeConfig) *fakeNomad {
+	return &fakeNomad{
+		cfg:   cfg,
+		index: 1,
+		namespaces: map[string]*api.Namespace{
+			"default": {Name: "default", Description: "Default shared namespace"},
+		},
+		jobs:        map[string]*api.Job{},
+		versions:    map[string][]*api.Job{},
+		deployments: map[string][]*api.Deployment{},
+		variables:   map[string]*api.Variable{},
+		subscribers: map[chan *api.Events]struct{}{},
+	}
+}
+
+func fakeKey(namespace, id string) string {
+	return namespace + "/" + id
+}
+
+func fakeID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// injectFailure randomly fails operations according to FailurePercent
+func (f *fakeNomad) injectFailure() bool {
+	if f.cfg.FailurePercent <= 0 {
+		return false
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(100))
+	return err == nil && n.Int64() < int64(f.cfg.FailurePercent)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeNomad) writeJSON(w http.ResponseWriter, v interface{}) {
+	f.lock.Lock()
+	index := f.index
+	f.lock.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Nomad-Index", fmt.Sprint(index))
+	w.Header().Set("X-Nomad-LastContact", "0")
+	w.Header().Set("X-Nomad-KnownLeader", "true")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeFakeError(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	_, _ = w.Write([]byte(msg))
+}
+
+func (f *fakeNomad) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.cfg.Latency > 0 {
+		select {
+		case <-time.After(f.cfg.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	namespace := r.URL.Query().Get("namespace")
+	if namespace == "" {
+		namespace = "default"
+	}
+	write := r.Method == http.MethodPut || r.Method == http.MethodPost
+
+	p := r.URL.Path
+	if capability := fakeCapability(r.Method, p); capability != "" && contains(f.cfg.DenyCapabilities, string(capability)) {
+		writeFakeError(w, http.StatusForbidden, "Permission denied")
+		return
+	}
+	switch {
+	case p == "/v1/jobs/parse" && write:
+		req := api.JobsParseRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeFakeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		job, err := parseFakeJob(req.JobHCL)
+		if err != nil {
+			writeFakeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		f.writeJSON(w, job)
+	case p == "/v1/jobs" && r.Method == http.MethodGet:
+		f.writeJSON(w, f.listJobs(namespace))
+	case p == "/v1/jobs" && write:
+		req := api.JobRegisterRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Job == nil || req.Job.ID == nil {
+			writeFakeError(w, http.StatusBadRequest, "invalid job")
+			return
+		}
+		f.register(w, namespace, req.Job)
+	case p == "/v1/namespaces" && r.Method == http.MethodGet:
+		f.lock.Lock()
+		res := []*api.Namespace{}
+		for _, ns := range f.namespaces {
+			res = append(res, ns)
+		}
+		f.lock.Unlock()
+		sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+		f.writeJSON(w, res)
+	case p == "/v1/namespace" && write:
+		ns := &api.Namespace{}
+		if err := json.NewDecoder(r.Body).Decode(ns); err != nil || ns.Name == "" {
+			writeFakeError(w, http.StatusBadRequest, "invalid namespace")
+			return
+		}
+		f.lock.Lock()
+		f.index++
+		f.namespaces[ns.Name] = ns
+		f.lock.Unlock()
+		f.writeJSON(w, nil)
+	case strings.HasPrefix(p, "/v1/namespace/") && r.Method == http.MethodGet:
+		f.lock.Lock()
+		ns, ok := f.namespaces[strings.TrimPrefix(p, "/v1/namespace/")]
+		f.lock.Unlock()
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "namespace not found")
+			return
+		}
+		f.writeJSON(w, ns)
+	case strings.HasPrefix(p, "/v1/namespace/") && r.Method == http.MethodDelete:
+		name := strings.TrimPrefix(p, "/v1/namespace/")
+		if len(f.listJobs(name)) > 0 {
+			writeFakeError(w, http.StatusBadRequest, fmt.Sprintf("namespace %q has non-terminal jobs", name))
+			return
+		}
+		f.lock.Lock()
+		_, ok := f.namespaces[name]
+		f.index++
+		delete(f.namespaces, name)
+		f.lock.Unlock()
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "namespace not found")
+			return
+		}
+		f.writeJSON(w, nil)
+	case p == "/v1/vars" && r.Method == http.MethodGet:
+		prefix := r.URL.Query().Get("prefix")
+		f.lock.Lock()
+		res := []*api.VariableMetadata{}
+		for _, v := range f.variables {
+			if v.Namespace == namespace && strings.HasPrefix(v.Path, prefix) {
+				res = append(res, v.Metadata())
+			}
+		}
+		f.lock.Unlock()
+		sort.Slice(res, func(a, b int) bool { return res[a].Path < res[b].Path })
+		f.writeJSON(w, res)
+	case strings.HasPrefix(p, "/v1/var/") && write:
+		v := &api.Variable{}
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			writeFakeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		v.Path = strings.TrimPrefix(p, "/v1/var/")
+		v.Namespace = namespace
+		f.lock.Lock()
+		f.index++
+		if old, ok := f.variables[fakeKey(namespace, v.Path)]; ok {
+			v.CreateIndex, v.CreateTime = old.CreateIndex, old.CreateTime
+		} else {
+			v.CreateIndex, v.CreateTime = f.index, time.Now().UnixNano()
+		}
+		v.ModifyIndex, v.ModifyTime = f.index, time.Now().UnixNano()
+		f.variables[fakeKey(namespace, v.Path)] = v
+		f.lock.Unlock()
+		f.writeJSON(w, v)
+	case strings.HasPrefix(p, "/v1/var/") && r.Method == http.MethodGet:
+		f.lock.Lock()
+		v, ok := f.variables[fakeKey(namespace, strings.TrimPrefix(p, "/v1/var/"))]
+		f.lock.Unlock()
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "variable not found")
+			return
+		}
+		f.writeJSON(w, v)
+	case strings.HasPrefix(p, "/v1/var/") && r.Method == http.MethodDelete:
+		f.lock.Lock()
+		f.index++
+		delete(f.variables, fakeKey(namespace, strings.TrimPrefix(p, "/v1/var/")))
+		f.lock.Unlock()
+		f.writeJSON(w, nil)
+	case strings.HasPrefix(p, "/v1/deployment/pause/") && write:
+		req := api.DeploymentPauseRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeFakeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		id := strings.TrimPrefix(p, "/v1/deployment/pause/")
+		from, status, desc := "paused", "running", "Deployment is running"
+		if req.Pause {
+			from, status, desc = "running", "paused", "Deployment is paused"
+		}
+		key, ok := f.updateDeployment(id, from, status, desc)
+		if !ok {
+			writeFakeError(w, http.StatusBadRequest, fmt.Sprintf("deployment %s is not %s", id, from))
+			return
+		}
+		if !req.Pause {
+			_, jobID, _ := strings.Cut(key, "/")
+			time.AfterFunc(f.cfg.DeploymentDuration, func() {
+				f.finishDeployment(key, id, !contains(f.cfg.FailDeployments, jobID))
+			})
+		}
+		f.writeJSON(w, api.DeploymentUpdateResponse{EvalID: fakeID()})
+	case strings.HasPrefix(p, "/v1/deployment/fail/") && write:
+		id := strings.TrimPrefix(p, "/v1/deployment/fail/")
+		_, ok := f.updateDeployment(id, "running", "failed", "Deployment marked as failed")
+		if !ok {
+			_, ok = f.updateDeployment(id, "paused", "failed", "Deployment marked as failed")
+		}
+		if !ok {
+			writeFakeError(w, http.StatusBadRequest, fmt.Sprintf("deployment %s is not active", id))
+			return
+		}
+		f.writeJSON(w, api.DeploymentUpdateResponse{EvalID: fakeID()})
+	case p == "/v1/agent/members" && r.Method == http.MethodGet:
+		f.writeJSON(w, api.ServerMembers{
+			ServerName: "fake-nomad",
+			Members: []*api.AgentMember{{
+				Name:   "fake-nomad.global",
+				Status: "alive",
+				Tags:   map[string]string{"build": fakeNomadVersion, "role": "nomad"},
+			}},
+		})
+	case p == "/v1/nodes" && r.Method == http.MethodGet:
+		f.writeJSON(w, []*api.NodeListStub{{
+			ID:      "fake-node",
+			Name:    "fake-node",
+			Status:  api.NodeStatusReady,
+			Version: fakeNomadVersion,
+		}})
+	case p == "/v1/event/stream":
+		f.stream(w, r)
+	case strings.HasPrefix(p, "/v1/job/"):
+		id, sub, _ := strings.Cut(strings.TrimPrefix(p, "/v1/job/"), "/")
+		f.serveJob(w, r, namespace, id, sub)
+	default:
+		writeFakeError(w, http.StatusNotFound, fmt.Sprintf("fake nomad: %s %s is not supported", r.Method, p))
+	}
+}
+
+// fakeCapability returns the capability required by the request, empty if none is simulated
+func fakeCapability(method, p string) Capability {
+	write := method == http.MethodPut || method == http.MethodPost
+	switch {
+	case p == "/v1/namespaces" && method == http.MethodGet:
+		return CapabilityListNamespaces
+	case p == "/v1/event/stream":
+		return CapabilityEventStream
+	case p == "/v1/jobs/parse":
+		return ""
+	case (p == "/v1/jobs" || strings.HasPrefix(p, "/v1/job/")) && write:
+		return CapabilitySubmitJob
+	case p == "/v1/jobs" || strings.HasPrefix(p, "/v1/job/"):
+		return CapabilityReadJob
+	}
+	return ""
+}
+
+func (f *fakeNomad) serveJob(w http.ResponseWriter, r *http.Request, namespace, id, sub string) {
+	f.lock.Lock()
+	job := f.jobs[fakeKey(namespace, id)]
+	// deployments are updated in place
+	deployments := append([]*api.Deployment{}, f.deployments[fakeKey(namespace, id)]...)
+	f.lock.Unlock()
+
+	switch {
+	case sub == "plan" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		req := api.JobPlanRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Job == nil || req.Job.ID == nil {
+			writeFakeError(w, http.StatusBadRequest, "invalid job")
+			return
+		}
+		if !f.namespaceExists(namespace) {
+			writeFakeError(w, http.StatusBadRequest, fmt.Sprintf("job %q is in nonexistent namespace %q", id, namespace))
+			return
+		}
+		if f.injectFailure() {
+			writeFakeError(w, http.StatusInternalServerError, "fake nomad: injected plan failure")
+			return
+		}
+		f.writeJSON(w, api.JobPlanResponse{
+			JobModifyIndex: jobModifyIndex(job),
+			Diff:           fakeDiff(job, req.Job),
+		})
+	case sub == "revert" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		req := api.JobRevertRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeFakeError(w, http.StatusBadRequest, "invalid revert request")
+			return
+		}
+		var version *api.Job
+		f.lock.Lock()
+		for _, v := range f.versions[fakeKey(namespace, id)] {
+			if *v.Version == req.JobVersion {
+				cpy := *v
+				version = &cpy
+			}
+		}
+		f.lock.Unlock()
+		if version == nil {
+			writeFakeError(w, http.StatusBadRequest, fmt.Sprintf("version %d of job %q not found", req.JobVersion, id))
+			return
+		}
+		f.register(w, namespace, version)
+	case sub == "" && r.Method == http.MethodDelete:
+		if job == nil {
+			writeFakeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		if f.injectFailure() {
+			writeFakeError(w, http.StatusInternalServerError, "fake nomad: injected deregister failure")
+			return
+		}
+		f.lock.Lock()
+		f.index++
+		delete(f.jobs, fakeKey(namespace, id))
+		f.publish(api.TopicJob, "JobDeregistered", id, "Job", job)
+		f.lock.Unlock()
+		f.writeJSON(w, api.JobDeregisterResponse{EvalID: fakeID()})
+	case r.Method != http.MethodGet:
+		writeFakeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	case sub == "deployment":
+		if len(deployments) == 0 {
+			f.writeJSON(w, nil)
+			return
+		}
+		f.writeJSON(w, deployments[len(deployments)-1])
+	case sub == "deployments":
+		res := []*api.Deployment{}
+		for i := len(deployments) - 1; i >= 0; i-- {
+			res = append(res, deployments[i])
+		}
+		f.writeJSON(w, res)
+	case job == nil:
+		writeFakeError(w, http.StatusNotFound, "job not found")
+	case sub == "":
+		f.writeJSON(w, job)
+	case sub == "allocations":
+		f.writeJSON(w, fakeAllocations(job, deployments))
+	case sub == "summary":
+		summary := &api.JobSummary{JobID: id, Namespace: namespace, Summary: map[string]api.TaskGroupSummary{}}
+		for _, a := range fakeAllocations(job, deployments) {
+			s := summary.Summary[a.TaskGroup]
+			if a.ClientStatus == "failed" {
+				s.Failed++
+			} else {
+				s.Running++
+			}
+			summary.Summary[a.TaskGroup] = s
+		}
+		f.writeJSON(w, summary)
+	default:
+		writeFakeError(w, http.StatusNotFound, fmt.Sprintf("fake nomad: %s is not supported", sub))
+	}
+}
+
+func (f *fakeNomad) namespaceExists(namespace string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	_, ok := f.namespaces[namespace]
+	return ok
+}
+
+func jobModifyIndex(job *api.Job) uint64 {
+	if job == nil || job.JobModifyIndex == nil {
+		return 0
+	}
+	return *job.JobModifyIndex
+}
+
+func (f *fakeNomad) listJobs(namespace string) []*api.JobListStub {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	res := []*api.JobListStub{}
+	for _, j := range f.jobs {
+		if namespace != "*" && *j.Namespace != namespace {
+			continue
+		}
+		res = append(res, &api.JobListStub{
+			ID:             *j.ID,
+			Name:           *j.Name,
+			Namespace:      *j.Namespace,
+			Type:           *j.Type,
+			Status:         *j.Status,
+			Meta:           j.Meta,
+			ModifyIndex:    *j.ModifyIndex,
+			JobModifyIndex: *j.JobModifyIndex,
+			SubmitTime:     *j.SubmitTime,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// register stores the job as new version and starts a deployment for service jobs
+func (f *fakeNomad) register(w http.ResponseWriter, namespace string, job *api.Job) {
+	if job.Namespace != nil && *job.Namespace != "" {
+		namespace = *job.Namespace
+	}
+	if !f.namespaceExists(namespace) {
+		writeFakeError(w, http.StatusBadRequest, fmt.Sprintf("job %q is in nonexistent namespace %q", *job.ID, namespace))
+		return
+	}
+	if contains(f.cfg.FailJobs, *job.ID) || f.injectFailure() {
+		writeFakeError(w, http.StatusInternalServerError, "fake nomad: injected register failure")
+		return
+	}
+
+	f.lock.Lock()
+	f.index++
+	key := fakeKey(namespace, *job.ID)
+
+	j := *job
+	if j.Name == nil {
+		j.Name = j.ID
+	}
+	if j.Type == nil {
+		j.Type = log.ToStrPtr("service")
+	}
+	version := uint64(0)
+	createIndex := f.index
+	if old, ok := f.jobs[key]; ok {
+		version = *old.Version + 1
+		createIndex = *old.CreateIndex
+	}
+	submitTime := time.Now().UnixNano()
+	index := f.index
+	j.Namespace = &namespace
+	j.Status = log.ToStrPtr("running")
+	j.Version = &version
+	j.CreateIndex = &createIndex
+	j.ModifyIndex = &index
+	j.JobModifyIndex = &index
+	j.SubmitTime = &submitTime
+	f.jobs[key] = &j
+	f.versions[key] = append(f.versions[key], &j)
+	f.publish(api.TopicJob, "JobRegistered", *j.ID, "Job", &j)
+
+	if *j.Type == "service" && len(j.TaskGroups) > 0 {
+		// a new deployment cancels the running one
+		deployments := f.deployments[key]
+		if n := len(deployments); n > 0 && deployments[n-1].Status == "running" {
+			cancelled := *deployments[n-1]
+			cancelled.Status = "cancelled"
+			cancelled.StatusDescription = "Cancelled because job is updated"
+			deployments[n-1] = &cancelled
+		}
+		d := &api.Deployment{
+			ID:                fakeID(),
+			Namespace:         namespace,
+			JobID:             *j.ID,
+			JobVersion:        version,
+			JobModifyIndex:    index,
+			Status:            "running",
+			StatusDescription: "Deployment is running",
+			CreateIndex:       index,
+			ModifyIndex:       index,
+		}
+		f.deployments[key] = append(deployments, d)
+		f.publish(api.TopicDeployment, "DeploymentStatusUpdate", d.ID, "Deployment", d)
+		time.AfterFunc(f.cfg.DeploymentDuration, func() {
+			f.finishDeployment(key, d.ID, !contains(f.cfg.FailDeployments, *j.ID))
+		})
+	}
+	f.lock.Unlock()
+
+	f.writeJSON(w, api.JobRegisterResponse{
+		EvalID:         fakeID(),
+		JobModifyIndex: index,
+	})
+}
+
+func (f *fakeNomad) finishDeployment(key, id string, success bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i, d := range f.deployments[key] {
+		if d.ID != id || d.Status != "running" {
+			continue
+		}
+		f.index++
+		finished := *d
+		finished.ModifyIndex = f.index
+		finished.Status, finished.StatusDescription = "successful", "Deployment completed successfully"
+		if !success {
+			finished.Status, finished.StatusDescription = "failed", "Failed due to unhealthy allocations"
+		}
+		f.deployments[key][i] = &finished
+		f.publish(api.TopicDeployment, "DeploymentStatusUpdate", finished.ID, "Deployment", &finished)
+	}
+}
+
+// updateDeployment changes the status of the deployment if it has the status from and returns the key of its job
+func (f *fakeNomad) updateDeployment(id, from, status, description string) (string, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for key, deployments := range f.deployments {
+		for i, d := range deployments {
+			if d.ID != id || d.Status != from {
+				continue
+			}
+			f.index++
+			updated := *d
+			updated.ModifyIndex = f.index
+			updated.Status, updated.StatusDescription = status, description
+			deployments[i] = &updated
+			f.publish(api.TopicDeployment, "DeploymentStatusUpdate", updated.ID, "Deployment", &updated)
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// fakeAllocations returns an allocation per instance of the task groups, which fail if the latest deployment failed
+func fakeAllocations(job *api.Job, deployments []*api.Deployment) []*api.AllocationListStub {
+	status := "running"
+	if n := len(deployments); n > 0 && deployments[n-1].Status == "failed" {
+		status = "failed"
+	}
+	res := []*api.AllocationListStub{}
+	for _, tg := range job.TaskGroups {
+		count := 1
+		if tg.Count != nil {
+			count = *tg.Count
+		}
+		for i := 0; i < count; i++ {
+			res = append(res, &api.AllocationListStub{
+				ID:            fmt.Sprintf("%s-%s-%d-%d", *job.ID, *tg.Name, *job.Version, i),
+				Name:          fmt.Sprintf("%s.%s[%d]", *job.ID, *tg.Name, i),
+				Namespace:     *job.Namespace,
+				JobID:         *job.ID,
+				JobVersion:    *job.Version,
+				TaskGroup:     *tg.Name,
+				DesiredStatus: "run",
+				ClientStatus:  status,
+				CreateIndex:   *job.JobModifyIndex,
+				ModifyIndex:   *job.JobModifyIndex,
+			})
+		}
+	}
+	return res
+}
+
+// publish sends an event to all subscribers of the event stream, the lock must be held
+func (f *fakeNomad) publish(topic api.Topic, eventType, key, payloadKey string, payload interface{}) {
+	events := &api.Events{
+		Index: f.index,
+		Events: []api.Event{{
+			Topic:   topic,
+			Type:    eventType,
+			Key:     key,
+			Index:   f.index,
+			Payload: map[string]interface{}{payloadKey: payload},
+		}},
+	}
+	for ch := range f.subscribers {
+		select {
+		case ch <- events:
+		default:
+			// slow subscribers miss events, like with a lagging event stream
+		}
+	}
+}
+
+func (f *fakeNomad) stream(w http.ResponseWriter, r *http.Request) {
+	ch := make(chan *api.Events, 256)
+	f.lock.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.lock.Unlock()
+	defer func() {
+		f.lock.Lock()
+		delete(f.subscribers, ch)
+		f.lock.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	heartbeat := time.NewTicker(10 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			err = enc.Encode(api.Events{})
+		case events := <-ch:
+			err = enc.Encode(events)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handlerTransport serves requests in memory with an http.Handler, the response body is streamed
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t *handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	pr, pw := io.Pipe()
+	w := &pipeResponseWriter{
+		header: http.Header{},
+		body:   pw,
+		ready:  make(chan struct{}),
+	}
+	go func() {
+		defer func() {
+			w.WriteHeader(http.StatusOK)
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			pw.Close()
+		}()
+		t.handler.ServeHTTP(w, req)
+	}()
+
+	select {
+	case <-w.ready:
+	case <-req.Context().Done():
+		pr.Close()
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", w.code, http.StatusText(w.code)),
+		StatusCode: w.code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     w.sentHeader,
+		// streamed
+		ContentLength: -1,
+		Body:          pr,
+		Request:       req,
+	}, nil
+}
+
+type pipeResponseWriter struct {
+	header     http.Header
+	sentHeader http.Header
+	code       int
+	body       *io.PipeWriter
+	once       sync.Once
+	ready      chan struct{}
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(code int) {
+	w.once.Do(func() {
+		w.code = code
+		w.sentHeader = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+func (w *pipeResponseWriter) Flush() {}
+
+// fakeHTTPClient returns an http client talking to an in-memory Nomad cluster
+func fakeHTTPClient(cfg FakeConfig) *http.Client {
+	return &http.Client{
+		Transport: &handlerTransport{handler: newFakeNomad(cfg)},
+	}
+}
@@ -0,0 +1,148 @@
+package nomadcluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+const fakeJobFile = `
+job "web" {
+  datacenters = ["dc1"]
+
+  group "web" {
+    count = 2
+
+    task "server" {
+      driver = "docker"
+
+      config {
+        image = "nginx:%s"
+      }
+    }
+  }
+}
+`
+
+func createFakeClient(t *testing.T, cfg FakeConfig) *Client {
+	t.Setenv("NOMAD_NAMESPACE", "")
+	t.Setenv("NOMAD_REGION", "")
+	c, err := CreateClient(context.Background(), log.NewSimpleLogger(false, "Test"), ClientConfig{Fake: &cfg}, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateClient:%v", err)
+	}
+	return c
+}
+
+func TestFakeClusterLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{DeploymentDuration: 300 * time.Millisecond})
+	src := &domain.Source{ID: "src"}
+
+	changed := make(chan string, 10)
+	err := c.SubscribeJobChanges(ctx, func(jobName string) {
+		changed <- jobName
+	})
+	if err != nil {
+		t.Fatalf("Could not SubscribeJobChanges:%v", err)
+	}
+
+	update := func(image string) *application.UpdateJobInfo {
+		job, err := c.ParseJob(ctx, strings.Replace(fakeJobFile, "%s", image, 1), application.ParseJobOptions{})
+		if err != nil {
+			t.Fatalf("Could not ParseJob:%v", err)
+		}
+		info, err := c.UpdateJob(ctx, src, job, false)
+		if err != nil {
+			t.Fatalf("Could not UpdateJob:%v", err)
+		}
+		return info
+	}
+
+	if info := update("1.25"); !info.Updated || info.DiffSummary != application.JobCreatedSummary("web") {
+		t.Errorf("Expected the job to be created, got %+v", info)
+	}
+	select {
+	case name := <-changed:
+		if name != "web" {
+			t.Errorf("Unexpected change of %s", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected an event of the registration")
+	}
+	if info := update("1.25"); info.Updated {
+		t.Errorf("Expected no update, got %+v", info)
+	}
+	info := update("1.26")
+	if !info.Updated || !strings.Contains(info.DiffSummary, "Task web/server: image nginx:1.25 -> nginx:1.26") {
+		t.Errorf("Expected the image to be updated, got %+v", info)
+	}
+
+	state, err := c.GetCurrentClusterState(ctx, application.GetCurrentClusterStateOptions{Source: src})
+	if err != nil {
+		t.Fatalf("Could not GetCurrentClusterState:%v", err)
+	}
+	job, ok := state.CurrentJobs["web"]
+	if !ok {
+		t.Fatalf("Expected the job in the cluster state")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		deployments, err := c.ListSourceDeployments(ctx, src)
+		if err != nil {
+			t.Fatalf("Could not ListSourceDeployments:%v", err)
+		}
+		if len(deployments) == 2 && deployments[0].Status == "successful" && deployments[1].Status == "cancelled" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Unexpected deployments %+v", deployments)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := c.RevertJob(ctx, src, job, 0); err != nil {
+		t.Fatalf("Could not RevertJob:%v", err)
+	}
+	reverted, _, err := c.client.Jobs().Info("web", nil)
+	if err != nil || *reverted.Version != 2 || reverted.TaskGroups[0].Tasks[0].Config["image"] != "nginx:1.25" {
+		t.Errorf("Expected version 0 to be registered as version 2, got %+v %v", reverted, err)
+	}
+
+	if err := c.DeleteJob(ctx, src, job); err != nil {
+		t.Fatalf("Could not DeleteJob:%v", err)
+	}
+	if jobs, err := c.ListSourceJobs(ctx, src); err != nil || len(jobs) != 0 {
+		t.Errorf("Expected the job to be deleted, got %v %v", jobs, err)
+	}
+}
+
+func TestFakeClusterFailures(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{FailJobs: []string{"web"}})
+
+	job, err := c.ParseJob(ctx, fakeJobFile, application.ParseJobOptions{})
+	if err != nil {
+		t.Fatalf("Could not ParseJob:%v", err)
+	}
+	if _, err := c.UpdateJob(ctx, &domain.Source{ID: "src"}, job, false); err == nil {
+		t.Errorf("Expected the registration to fail")
+	}
+
+	namespace := "team"
+	job.Namespace = &namespace
+	_, err = c.UpdateJob(ctx, &domain.Source{ID: "src"}, job, false)
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeNamespaceMissing {
+		t.Errorf("Expected a missing namespace, got %v", err)
+	}
+
+	if _, err := c.ParseJob(ctx, `variable "a" {}`, application.ParseJobOptions{}); err == nil {
+		t.Errorf("Expected a file without job to fail parsing")
+	}
+}
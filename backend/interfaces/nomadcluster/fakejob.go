@@ -0,0 +1,294 @@
+package nomadcluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// metaKeyFakeSpec holds a hash of the job file, so every change of a job file results in a diff
+const metaKeyFakeSpec = "nomadopsfakespec"
+
+var (
+	fakeTokenRe  = regexp.MustCompile(`\b(job|group|task)\s+"([^"]+)"|\b(namespace|type|region|datacenters|count|driver|image)\s*=\s*("[^"]*"|\d+|\[[^\]]*\])`)
+	fakeQuotedRe = regexp.MustCompile(`"([^"]*)"`)
+)
+
+// parseFakeJob parses JSON jobs completely. There is no HCL parser, of HCL job files only the blocks of jobs,
+// groups and tasks and a few attributes are read.
+func parseFakeJob(spec string) (*api.Job, error) {
+	if strings.HasPrefix(strings.TrimSpace(spec), "{") {
+		wrapper := struct {
+			Job *api.Job
+		}{}
+		if err := json.Unmarshal([]byte(spec), &wrapper); err != nil {
+			return nil, err
+		}
+		job := wrapper.Job
+		if job == nil {
+			job = &api.Job{}
+			if err := json.Unmarshal([]byte(spec), job); err != nil {
+				return nil, err
+			}
+		}
+		if job.ID == nil || *job.ID == "" {
+			return nil, fmt.Errorf("job has no ID")
+		}
+		if job.Name == nil {
+			job.Name = job.ID
+		}
+		return job, nil
+	}
+
+	var (
+		job   *api.Job
+		group *api.TaskGroup
+		task  *api.Task
+	)
+	for _, m := range fakeTokenRe.FindAllStringSubmatch(spec, -1) {
+		name := m[2]
+		switch m[1] {
+		case "job":
+			if job != nil {
+				return nil, fmt.Errorf("only one job per file is supported")
+			}
+			job = &api.Job{ID: &name, Name: &name}
+			continue
+		case "group":
+			if job == nil {
+				return nil, fmt.Errorf("group %s is outside of a job", name)
+			}
+			count := 1
+			group = &api.TaskGroup{Name: &name, Count: &count}
+			task = nil
+			job.TaskGroups = append(job.TaskGroups, group)
+			continue
+		case "task":
+			if group == nil {
+				return nil, fmt.Errorf("task %s is outside of a group", name)
+			}
+			task = &api.Task{Name: name, Config: map[string]interface{}{}}
+			group.Tasks = append(group.Tasks, task)
+			continue
+		}
+		if job == nil {
+			// variables and locals
+			continue
+		}
+
+		attr, value := m[3], m[4]
+		s := strings.Trim(value, `"`)
+		switch {
+		case task != nil && attr == "driver":
+			task.Driver = s
+		case task != nil && attr == "image":
+			task.Config["image"] = s
+		case group != nil && task == nil && attr == "count":
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid count of group %s:%v", *group.Name, err)
+			}
+			group.Count = &count
+		case group == nil && attr == "namespace":
+			job.Namespace = &s
+		case group == nil && attr == "type":
+			job.Type = &s
+		case group == nil && attr == "region":
+			job.Region = &s
+		case group == nil && attr == "datacenters":
+			for _, dc := range fakeQuotedRe.FindAllStringSubmatch(value, -1) {
+				job.Datacenters = append(job.Datacenters, dc[1])
+			}
+		}
+	}
+	if job == nil {
+		return nil, fmt.Errorf("no job found")
+	}
+	sum := sha256.Sum256([]byte(spec))
+	job.Meta = map[string]string{
+		metaKeyFakeSpec: hex.EncodeToString(sum[:])[:12],
+	}
+	return job, nil
+}
+
+// fakeDiff compares the job with the registered one in the structure of the diffs of Nomad,
+// fields that are not compared one by one are reported as Spec
+func fakeDiff(old, new *api.Job) *api.JobDiff {
+	if old == nil {
+		d := &api.JobDiff{
+			Type:   diffTypeAdded,
+			ID:     *new.ID,
+			Fields: fieldDiffs(map[string]string{}, jobFields(new)),
+		}
+		for _, g := range new.TaskGroups {
+			d.TaskGroups = append(d.TaskGroups, &api.TaskGroupDiff{Type: diffTypeAdded, Name: *g.Name})
+		}
+		return d
+	}
+	d := &api.JobDiff{
+		Type:   "None",
+		ID:     *new.ID,
+		Fields: fieldDiffs(jobFields(old), jobFields(new)),
+	}
+
+	oldGroups := map[string]*api.TaskGroup{}
+	for _, g := range old.TaskGroups {
+		oldGroups[*g.Name] = g
+	}
+	for _, g := range new.TaskGroups {
+		if tg := groupDiff(oldGroups[*g.Name], g); tg != nil {
+			d.TaskGroups = append(d.TaskGroups, tg)
+		}
+		delete(oldGroups, *g.Name)
+	}
+	for _, g := range old.TaskGroups {
+		if _, ok := oldGroups[*g.Name]; ok {
+			d.TaskGroups = append(d.TaskGroups, &api.TaskGroupDiff{Type: diffTypeDeleted, Name: *g.Name})
+		}
+	}
+
+	if len(d.Fields) > 0 || len(d.TaskGroups) > 0 {
+		d.Type = diffTypeEdited
+	}
+	return d
+}
+
+func groupDiff(old, new *api.TaskGroup) *api.TaskGroupDiff {
+	if old == nil {
+		return &api.TaskGroupDiff{Type: diffTypeAdded, Name: *new.Name}
+	}
+	d := &api.TaskGroupDiff{
+		Type:   diffTypeEdited,
+		Name:   *new.Name,
+		Fields: fieldDiffs(map[string]string{"Count": fmt.Sprint(groupCount(old))}, map[string]string{"Count": fmt.Sprint(groupCount(new))}),
+	}
+	oldSpec, newSpec := *old, *new
+	oldSpec.Count, newSpec.Count = nil, nil
+	oldSpec.Tasks, newSpec.Tasks = nil, nil
+	if specJSON(oldSpec) != specJSON(newSpec) {
+		d.Fields = append(d.Fields, &api.FieldDiff{Type: diffTypeEdited, Name: "Spec"})
+	}
+
+	oldTasks := map[string]*api.Task{}
+	for _, t := range old.Tasks {
+		oldTasks[t.Name] = t
+	}
+	for _, t := range new.Tasks {
+		if td := taskDiff(oldTasks[t.Name], t); td != nil {
+			d.Tasks = append(d.Tasks, td)
+		}
+		delete(oldTasks, t.Name)
+	}
+	for _, t := range old.Tasks {
+		if _, ok := oldTasks[t.Name]; ok {
+			d.Tasks = append(d.Tasks, &api.TaskDiff{Type: diffTypeDeleted, Name: t.Name})
+		}
+	}
+
+	if len(d.Fields) == 0 && len(d.Tasks) == 0 {
+		return nil
+	}
+	return d
+}
+
+func taskDiff(old, new *api.Task) *api.TaskDiff {
+	if old == nil {
+		return &api.TaskDiff{Type: diffTypeAdded, Name: new.Name}
+	}
+	d := &api.TaskDiff{
+		Type:   diffTypeEdited,
+		Name:   new.Name,
+		Fields: fieldDiffs(map[string]string{"Driver": old.Driver}, map[string]string{"Driver": new.Driver}),
+	}
+	if fields := fieldDiffs(configFields(old.Config), configFields(new.Config)); len(fields) > 0 {
+		d.Objects = append(d.Objects, &api.ObjectDiff{Type: diffTypeEdited, Name: "Config", Fields: fields})
+	}
+	oldSpec, newSpec := *old, *new
+	oldSpec.Driver, newSpec.Driver = "", ""
+	oldSpec.Config, newSpec.Config = nil, nil
+	if specJSON(oldSpec) != specJSON(newSpec) {
+		d.Fields = append(d.Fields, &api.FieldDiff{Type: diffTypeEdited, Name: "Spec"})
+	}
+
+	if len(d.Fields) == 0 && len(d.Objects) == 0 {
+		return nil
+	}
+	return d
+}
+
+func jobFields(j *api.Job) map[string]string {
+	jobType := "service"
+	if j.Type != nil {
+		jobType = *j.Type
+	}
+	fields := map[string]string{
+		"Type":        jobType,
+		"Datacenters": strings.Join(j.Datacenters, ","),
+	}
+	if j.Priority != nil {
+		fields["Priority"] = fmt.Sprint(*j.Priority)
+	}
+	for k, v := range j.Meta {
+		fields[fmt.Sprintf("Meta[%s]", k)] = v
+	}
+	return fields
+}
+
+func configFields(config map[string]interface{}) map[string]string {
+	fields := map[string]string{}
+	for k, v := range config {
+		if s, ok := v.(string); ok {
+			fields[k] = s
+			continue
+		}
+		fields[k] = specJSON(v)
+	}
+	return fields
+}
+
+func groupCount(g *api.TaskGroup) int {
+	if g.Count == nil {
+		return 1
+	}
+	return *g.Count
+}
+
+// fieldDiffs compares flattened fields, missing fields are added or deleted
+func fieldDiffs(old, new map[string]string) []*api.FieldDiff {
+	var names []string
+	for k := range old {
+		names = append(names, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	var res []*api.FieldDiff
+	for _, name := range names {
+		o, hasOld := old[name]
+		n, hasNew := new[name]
+		switch {
+		case !hasOld:
+			res = append(res, &api.FieldDiff{Type: diffTypeAdded, Name: name, New: n})
+		case !hasNew:
+			res = append(res, &api.FieldDiff{Type: diffTypeDeleted, Name: name, Old: o})
+		case o != n:
+			res = append(res, &api.FieldDiff{Type: diffTypeEdited, Name: name, Old: o, New: n})
+		}
+	}
+	return res
+}
+
+func specJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
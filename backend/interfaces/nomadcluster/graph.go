@@ -0,0 +1,149 @@
+package nomadcluster
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+type GraphNodeType string
+
+const (
+	GraphNodeTypeSource    GraphNodeType = "source"
+	GraphNodeTypeJob       GraphNodeType = "job"
+	GraphNodeTypeNamespace GraphNodeType = "namespace"
+	GraphNodeTypeVolume    GraphNodeType = "volume"
+	GraphNodeTypeVariable  GraphNodeType = "variable"
+)
+
+type GraphEdgeType string
+
+const (
+	GraphEdgeTypeManages  GraphEdgeType = "manages"
+	GraphEdgeTypeRunsIn   GraphEdgeType = "runs_in"
+	GraphEdgeTypeMounts   GraphEdgeType = "mounts"
+	GraphEdgeTypeReads    GraphEdgeType = "reads"
+	GraphEdgeTypeWaitsFor GraphEdgeType = "waits_for"
+)
+
+type GraphNode struct {
+	ID    string        `json:"id"`
+	Type  GraphNodeType `json:"type"`
+	Label string        `json:"label"`
+	// source managing the job, empty for jobs that are only waited for
+	SourceID string `json:"sourceId,omitempty"`
+}
+
+type GraphEdge struct {
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	Type GraphEdgeType `json:"type"`
+}
+
+// Graph connects sources to the jobs they manage and the jobs to the namespaces, volumes
+// and variables they use and to the jobs they wait for
+type Graph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// JobGraph builds the graph of the managed jobs of the sources for which include returns true
+func (c *Client) JobGraph(ctx context.Context, include func(srcID string) bool) *Graph {
+	jobs := []*IndexedJob{}
+	for _, j := range c.index.search(JobSearchQuery{}) {
+		if include(j.SourceID) {
+			jobs = append(jobs, j)
+		}
+	}
+	return buildGraph(jobs)
+}
+
+func buildGraph(jobs []*IndexedJob) *Graph {
+	nodes := map[string]*GraphNode{}
+	g := &Graph{
+		Nodes: []*GraphNode{},
+		Edges: []*GraphEdge{},
+	}
+	addNode := func(t GraphNodeType, key, label string) string {
+		id := string(t) + ":" + key
+		if _, ok := nodes[id]; !ok {
+			nodes[id] = &GraphNode{ID: id, Type: t, Label: label}
+		}
+		return id
+	}
+	addEdge := func(from, to string, t GraphEdgeType) {
+		g.Edges = append(g.Edges, &GraphEdge{From: from, To: to, Type: t})
+	}
+
+	for _, j := range jobs {
+		src := addNode(GraphNodeTypeSource, j.SourceID, j.SourceID)
+		nodes[src].SourceID = j.SourceID
+		job := addNode(GraphNodeTypeJob, indexKey(j.Namespace, j.ID), j.Name)
+		nodes[job].SourceID = j.SourceID
+		addEdge(src, job, GraphEdgeTypeManages)
+		addEdge(job, addNode(GraphNodeTypeNamespace, j.Namespace, j.Namespace), GraphEdgeTypeRunsIn)
+		for _, v := range j.Volumes {
+			// CSI volumes belong to a namespace, host volumes to the nodes
+			key := v
+			if strings.HasPrefix(v, "csi:") {
+				key = indexKey(j.Namespace, v)
+			}
+			addEdge(job, addNode(GraphNodeTypeVolume, key, v), GraphEdgeTypeMounts)
+		}
+		for _, v := range j.Variables {
+			addEdge(job, addNode(GraphNodeTypeVariable, indexKey(j.Namespace, v), v), GraphEdgeTypeReads)
+		}
+		for _, dep := range j.WaitFor {
+			_, id, _ := strings.Cut(dep, "/")
+			addEdge(job, addNode(GraphNodeTypeJob, dep, id), GraphEdgeTypeWaitsFor)
+		}
+	}
+
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Slice(g.Nodes, func(a, b int) bool {
+		return g.Nodes[a].ID < g.Nodes[b].ID
+	})
+	return g
+}
+
+// Impact returns the jobs of other sources that wait for a job of the source, directly or through other jobs
+func (g *Graph) Impact(srcID string) []*GraphNode {
+	nodes := map[string]*GraphNode{}
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+	waitedBy := map[string][]string{}
+	for _, e := range g.Edges {
+		if e.Type == GraphEdgeTypeWaitsFor {
+			waitedBy[e.To] = append(waitedBy[e.To], e.From)
+		}
+	}
+
+	visited := map[string]bool{}
+	queue := []string{}
+	for _, n := range g.Nodes {
+		if n.Type == GraphNodeTypeJob && n.SourceID == srcID {
+			visited[n.ID] = true
+			queue = append(queue, n.ID)
+		}
+	}
+	res := []*GraphNode{}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, dependent := range waitedBy[id] {
+			if visited[dependent] {
+				continue
+			}
+			visited[dependent] = true
+			queue = append(queue, dependent)
+			res = append(res, nodes[dependent])
+		}
+	}
+	sort.Slice(res, func(a, b int) bool {
+		return res[a].ID < res[b].ID
+	})
+	return res
+}
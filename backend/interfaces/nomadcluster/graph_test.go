@@ -0,0 +1,53 @@
+package nomadcluster
+
+import (
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestJobGraphImpact(t *testing.T) {
+	newJob := func(id, srcID, waitFor string) *api.Job {
+		job := api.NewServiceJob(id, id, "global", 50)
+		job.Namespace = log.ToStrPtr("default")
+		job.Meta = map[string]string{metaKeySrcID: srcID, metaKeyWaitFor: waitFor}
+		tg := api.NewTaskGroup(id, 1)
+		tg.Volumes = map[string]*api.VolumeRequest{"data": {Type: "csi", Source: "db-data"}}
+		task := api.NewTask("task", "docker")
+		task.Templates = []*api.Template{{EmbeddedTmpl: log.ToStrPtr(`{{ with nomadVar "nomad/jobs/db" }}{{ .password }}{{ end }}`)}}
+		job.TaskGroups = []*api.TaskGroup{tg.AddTask(task)}
+		return job
+	}
+
+	index := newJobIndex()
+	index.setSource("db", map[string]*api.Job{"db": newJob("db", "db", "")})
+	index.setSource("app", map[string]*api.Job{"api": newJob("api", "app", "db")})
+	index.setSource("web", map[string]*api.Job{"web": newJob("web", "web", "default/api")})
+
+	g := buildGraph(index.search(JobSearchQuery{}))
+	found := map[string]bool{}
+	for _, e := range g.Edges {
+		found[e.From+" "+string(e.Type)+" "+e.To] = true
+	}
+	for _, edge := range []string{
+		"source:db manages job:default/db",
+		"job:default/db runs_in namespace:default",
+		"job:default/db mounts volume:default/csi:db-data",
+		"job:default/db reads variable:default/nomad/jobs/db",
+		"job:default/api waits_for job:default/db",
+	} {
+		if !found[edge] {
+			t.Errorf("Expected edge %s, got %v", edge, found)
+		}
+	}
+
+	impact := g.Impact("db")
+	if len(impact) != 2 || impact[0].ID != "job:default/api" || impact[1].ID != "job:default/web" {
+		t.Errorf("Expected api and web to be impacted, got %v", impact)
+	}
+	if impact := g.Impact("web"); len(impact) != 0 {
+		t.Errorf("Expected nothing to wait for web, got %v", impact)
+	}
+}
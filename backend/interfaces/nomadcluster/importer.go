@@ -0,0 +1,81 @@
+package nomadcluster
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+)
+
+// jobSubmission is the job file a job was submitted with, stored by Nomad 1.6 and newer
+type jobSubmission struct {
+	Source        string
+	Format        string
+	VariableFlags map[string]string
+	Variables     string
+}
+
+// ListUnmanagedJobs returns the jobs of all authorized namespaces of the region of the client
+// that are not managed by a source. Dispatched and periodic child jobs are left out, they belong to their parent.
+func (c *Client) ListUnmanagedJobs(ctx context.Context, withSubmissions bool) ([]*application.ImportedJob, error) {
+	queryOptions := &api.QueryOptions{
+		Namespace: "*", // Query all authorized namespaces
+		Params: map[string]string{
+			"meta": "true",
+		},
+	}
+	joblist, meta, err := c.client.Jobs().List(queryOptions.WithContext(ctx))
+	if err != nil {
+		return nil, withErrorCode("", err)
+	}
+	c.observeJobsIndex(meta.LastIndex)
+
+	res := []*application.ImportedJob{}
+	for _, stub := range joblist {
+		if stub.Meta[metaKeySrcID] != "" || stub.ParentID != "" {
+			continue
+		}
+		queryOptions := &api.QueryOptions{
+			Namespace: stub.Namespace,
+		}
+		j, _, err := c.client.Jobs().Info(stub.ID, queryOptions.WithContext(ctx))
+		if isNotFound(err) {
+			// deleted since listing the jobs
+			continue
+		}
+		if err != nil {
+			return nil, withErrorCode("", err)
+		}
+		imported := &application.ImportedJob{
+			Job: j,
+		}
+		if withSubmissions && j.Version != nil {
+			imported.Submission = c.jobSubmission(ctx, stub.Namespace, stub.ID, *j.Version)
+		}
+		res = append(res, imported)
+	}
+	return res, nil
+}
+
+// jobSubmission returns the HCL2 job file of the version of the job, empty if Nomad did not store it
+// or it cannot be used without its variables
+func (c *Client) jobSubmission(ctx context.Context, namespace, id string, version uint64) string {
+	sub := &jobSubmission{}
+	queryOptions := &api.QueryOptions{
+		Namespace: namespace,
+	}
+	_, err := c.client.Raw().Query(fmt.Sprintf("/v1/job/%s/submission?version=%d", url.PathEscape(id), version),
+		sub, queryOptions.WithContext(ctx))
+	if err != nil {
+		// older versions of Nomad, or the job was submitted through the API
+		c.logger.LogTrace(ctx, "No submission of job %s/%s:%v", namespace, id, err)
+		return ""
+	}
+	if sub.Format != "hcl2" || len(sub.VariableFlags) > 0 || sub.Variables != "" {
+		return ""
+	}
+	return sub.Source
+}
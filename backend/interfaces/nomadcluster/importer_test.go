@@ -0,0 +1,39 @@
+package nomadcluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestListUnmanagedJobs(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{})
+
+	managed, err := c.ParseJob(ctx, strings.Replace(fakeJobFile, "%s", "1.25", 1), application.ParseJobOptions{})
+	if err != nil {
+		t.Fatalf("Could not ParseJob:%v", err)
+	}
+	if _, err := c.UpdateJob(ctx, &domain.Source{ID: "src"}, managed, false); err != nil {
+		t.Fatalf("Could not UpdateJob:%v", err)
+	}
+	unmanaged := api.NewServiceJob("legacy", "legacy", "global", 50)
+	unmanaged.Datacenters = []string{"dc1"}
+	if _, _, err := c.client.Jobs().Register(unmanaged, nil); err != nil {
+		t.Fatalf("Could not Register:%v", err)
+	}
+
+	jobs, err := c.ListUnmanagedJobs(ctx, true)
+	if err != nil {
+		t.Fatalf("Could not ListUnmanagedJobs:%v", err)
+	}
+	// the fake cluster stores no submissions
+	if len(jobs) != 1 || *jobs[0].Job.ID != "legacy" || jobs[0].Submission != "" {
+		t.Errorf("Expected only the unmanaged job, got %v", jobs)
+	}
+}
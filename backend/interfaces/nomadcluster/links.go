@@ -0,0 +1,72 @@
+package nomadcluster
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// uiURL returns the address of the Nomad UI of the region, the one of the cluster if the region has none
+func (c *Client) uiURL(region string) string {
+	if u, ok := c.cfg.UIRegionURLs[region]; ok && region != "" {
+		return u
+	}
+	return c.url
+}
+
+// jobLinks returns the deep links of the job and its latest deployment, allocID is the failed allocation if any
+func (c *Client) jobLinks(src *domain.Source, job *application.JobInfo, deployment *api.Deployment, allocID string) *domain.NomadLinks {
+	namespace, region := jobNamespaceRegion(src, job)
+	deploymentID := ""
+	if deployment != nil {
+		deploymentID = deployment.ID
+	}
+	return NomadUILinks(c.uiURL(region), region, namespace, *job.ID, deploymentID, allocID)
+}
+
+// failedAllocation returns the id of an allocation that failed the deployment, empty if there is none
+func (c *Client) failedAllocation(ctx context.Context, src *domain.Source, job *application.JobInfo, deployment *api.Deployment) string {
+	if deployment == nil || deployment.Status != "failed" {
+		return ""
+	}
+	allocs, _, err := c.client.Deployments().Allocations(deployment.ID, c.getQueryOptsCtx(ctx, src, job))
+	if err != nil {
+		c.logger.LogTrace(ctx, "Could not list the allocations of deployment %s:%v", deployment.ID, err)
+		return ""
+	}
+	for _, alloc := range allocs {
+		unhealthy := alloc.DeploymentStatus != nil && alloc.DeploymentStatus.Healthy != nil && !*alloc.DeploymentStatus.Healthy
+		if alloc.ClientStatus == "failed" || unhealthy {
+			return alloc.ID
+		}
+	}
+	return ""
+}
+
+// NomadUILinks returns the links of a job in the Nomad UI at base, deploymentID and allocID are optional
+func NomadUILinks(base, region, namespace, jobID, deploymentID, allocID string) *domain.NomadLinks {
+	base = strings.TrimSuffix(base, "/")
+	if namespace == "" {
+		namespace = "default"
+	}
+	query := ""
+	if region != "" {
+		query = "?region=" + url.QueryEscape(region)
+	}
+	job := base + "/ui/jobs/" + url.PathEscape(jobID+"@"+namespace)
+	links := &domain.NomadLinks{
+		Job: job + query,
+	}
+	if deploymentID != "" {
+		links.Deployment = job + "/deployments" + query
+	}
+	if allocID != "" {
+		links.Allocation = base + "/ui/allocations/" + url.PathEscape(allocID) + query
+	}
+	return links
+}
@@ -0,0 +1,55 @@
+package nomadcluster
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestNomadUILinks(t *testing.T) {
+	links := NomadUILinks("https://nomad.example.com/", "eu", "", "web", "d1", "a1")
+	if links.Job != "https://nomad.example.com/ui/jobs/web@default?region=eu" ||
+		links.Deployment != "https://nomad.example.com/ui/jobs/web@default/deployments?region=eu" ||
+		links.Allocation != "https://nomad.example.com/ui/allocations/a1?region=eu" {
+		t.Errorf("Unexpected links %+v", links)
+	}
+	if links := NomadUILinks("https://nomad.example.com", "", "billing", "web", "", ""); links.Deployment != "" || links.Allocation != "" ||
+		links.Job != "https://nomad.example.com/ui/jobs/web@billing" {
+		t.Errorf("Unexpected links without deployment %+v", links)
+	}
+}
+
+func TestUpdateJobLinksFailedAllocation(t *testing.T) {
+	healthy, unhealthy := true, false
+	c := createFakeNomadClient(t, map[string]fakeResponse{
+		"PUT /v1/job/web/plan":       noDiff,
+		"GET /v1/job/web/deployment": {code: http.StatusOK, body: api.Deployment{ID: "d1", Status: "failed"}},
+		"GET /v1/deployment/allocations/d1": {code: http.StatusOK, body: []*api.AllocationListStub{
+			{ID: "a1", ClientStatus: "running", DeploymentStatus: &api.AllocDeploymentStatus{Healthy: &healthy}},
+			{ID: "a2", ClientStatus: "running", DeploymentStatus: &api.AllocDeploymentStatus{Healthy: &unhealthy}},
+		}},
+	})
+	c.cfg.UIRegionURLs = map[string]string{"eu": "https://nomad-eu.example.com"}
+	c.url = "https://nomad.example.com"
+
+	info, err := c.UpdateJob(context.Background(), &domain.Source{ID: "src"}, testJob("web", "default"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Links == nil || info.Links.Allocation != "https://nomad.example.com/ui/allocations/a2" ||
+		info.Links.Deployment != "https://nomad.example.com/ui/jobs/web@default/deployments" {
+		t.Errorf("Expected links to the failed allocation, got %+v", info.Links)
+	}
+
+	info, err = c.UpdateJob(context.Background(), &domain.Source{ID: "src", Region: "eu"}, testJob("web", "default"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Links.Job != "https://nomad-eu.example.com/ui/jobs/web@default?region=eu" {
+		t.Errorf("Expected the UI of the region, got %+v", info.Links)
+	}
+}
@@ -0,0 +1,107 @@
+package nomadcluster
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+)
+
+// parseCache holds the most recently parsed job files, so unchanged files are not parsed by the Nomad API
+// again on every sync. Jobs are stored in the JSON format of the Nomad API, every hit returns a new copy
+// that may be changed by the caller, e.g. by the overrides of a source.
+type parseCache struct {
+	lock    sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type parsedJobFile struct {
+	key      string
+	job      []byte
+	warnings []string
+}
+
+func newParseCache(size int) *parseCache {
+	return &parseCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// parseCacheKey hashes everything the result of a parse depends on: the content of the job file, the parse
+// options and the versions of the Nomad servers. Variables of renderers are part of the rendered content.
+func parseCacheKey(j string, opts application.ParseJobOptions, v ClusterVersion) string {
+	h := sha256.New()
+	h.Write([]byte(strings.Join(v.Servers, ",")))
+	h.Write([]byte{0})
+	if opts.Strict {
+		h.Write([]byte("strict"))
+	}
+	if opts.HCL1Fallback {
+		h.Write([]byte("hcl1"))
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(j))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns a copy of the cached job of the key
+func (p *parseCache) get(key string) (*application.JobInfo, bool) {
+	if p.size <= 0 {
+		return nil, false
+	}
+	p.lock.Lock()
+	e, ok := p.entries[key]
+	if ok {
+		p.order.MoveToFront(e)
+	}
+	p.lock.Unlock()
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*parsedJobFile)
+	job := &api.Job{}
+	if err := json.Unmarshal(entry.job, job); err != nil {
+		return nil, false
+	}
+	return &application.JobInfo{
+		Job:      job,
+		Warnings: append([]string(nil), entry.warnings...),
+	}, true
+}
+
+// set caches the parsed job, evicting the least recently used one if the cache is full
+func (p *parseCache) set(key string, job *application.JobInfo) {
+	if p.size <= 0 {
+		return
+	}
+	data, err := json.Marshal(job.Job)
+	if err != nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if e, ok := p.entries[key]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.entries[key] = p.order.PushFront(&parsedJobFile{
+		key:      key,
+		job:      data,
+		warnings: append([]string(nil), job.Warnings...),
+	})
+	for p.order.Len() > p.size {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.entries, oldest.Value.(*parsedJobFile).key)
+	}
+}
@@ -0,0 +1,64 @@
+package nomadcluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestParseCache(t *testing.T) {
+	var parsed int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&parsed, 1)
+		req := api.JobsParseRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(testJob(req.JobHCL, "default").Job)
+	}))
+	defer srv.Close()
+	t.Setenv("NOMAD_ADDR", srv.URL)
+
+	c, err := CreateClient(context.Background(), log.NewSimpleLogger(false, "Test"), ClientConfig{
+		ParseCacheSize: 2,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateClient:%v", err)
+	}
+	parse := func(j string, opts application.ParseJobOptions) *application.JobInfo {
+		info, err := c.ParseJob(context.Background(), j, opts)
+		if err != nil {
+			t.Fatalf("Could not ParseJob:%v", err)
+		}
+		return info
+	}
+
+	first := parse("web", application.ParseJobOptions{})
+	*first.Job.Namespace = "changed"
+	second := parse("web", application.ParseJobOptions{})
+	if n := atomic.LoadInt32(&parsed); n != 1 {
+		t.Fatalf("Expected the unchanged file to be parsed once, got %d", n)
+	}
+	if *second.Job.Name != "web" || *second.Job.Namespace != "default" {
+		t.Errorf("Expected an unchanged copy of the cached job, got %s/%s", *second.Job.Namespace, *second.Job.Name)
+	}
+
+	// other options and contents are parsed again
+	parse("web", application.ParseJobOptions{Strict: true})
+	parse("api", application.ParseJobOptions{})
+	if n := atomic.LoadInt32(&parsed); n != 3 {
+		t.Fatalf("Expected 3 parses, got %d", n)
+	}
+
+	// the least recently used file got evicted
+	parse("web", application.ParseJobOptions{})
+	if n := atomic.LoadInt32(&parsed); n != 4 {
+		t.Errorf("Expected the evicted file to be parsed again, got %d parses", n)
+	}
+}
@@ -0,0 +1,101 @@
+package nomadcluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// listSourceJobs returns the stubs of all jobs managed by the given source
+func (c *Client) listSourceJobs(ctx context.Context, src *domain.Source) ([]*api.JobListStub, error) {
+	queryOptions := &api.QueryOptions{
+		Namespace: "*", // Query all authorized namespaces
+		Region:    src.Region,
+		Params: map[string]string{
+			"meta": "true",
+		},
+		Filter: fmt.Sprintf(`"%s" in Meta and Meta["%s"] == %q`, metaKeySrcID, metaKeySrcID, src.ID),
+	}
+	joblist, meta, err := c.client.Jobs().List(queryOptions.WithContext(ctx))
+	if err != nil {
+		return nil, withErrorCode("", err)
+	}
+	c.observeJobsIndex(meta.LastIndex)
+
+	var res []*api.JobListStub
+	for _, job := range joblist {
+		// only consider jobs with my source id!
+		if job.Meta[metaKeySrcID] != src.ID {
+			continue
+		}
+		res = append(res, job)
+	}
+	return res, nil
+}
+
+// ListSourceJobs returns all jobs managed by the given source
+func (c *Client) ListSourceJobs(ctx context.Context, src *domain.Source) ([]*api.JobListStub, error) {
+	jobs, err := c.listSourceJobs(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	if jobs == nil {
+		jobs = []*api.JobListStub{}
+	}
+	return jobs, nil
+}
+
+// ListSourceAllocations returns the allocations of all jobs managed by the given source
+func (c *Client) ListSourceAllocations(ctx context.Context, src *domain.Source) ([]*api.AllocationListStub, error) {
+	jobs, err := c.listSourceJobs(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*api.AllocationListStub{}
+	for _, job := range jobs {
+		queryOptions := &api.QueryOptions{
+			Namespace: job.Namespace,
+			Region:    src.Region,
+		}
+		allocs, _, err := c.client.Jobs().Allocations(job.ID, false, queryOptions.WithContext(ctx))
+		if isNotFound(err) {
+			// deleted since listing the jobs
+			continue
+		}
+		if err != nil {
+			return nil, withErrorCode("", err)
+		}
+		res = append(res, allocs...)
+	}
+	return res, nil
+}
+
+// ListSourceDeployments returns the deployments of all jobs managed by the given source
+func (c *Client) ListSourceDeployments(ctx context.Context, src *domain.Source) ([]*api.Deployment, error) {
+	jobs, err := c.listSourceJobs(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*api.Deployment{}
+	for _, job := range jobs {
+		queryOptions := &api.QueryOptions{
+			Namespace: job.Namespace,
+			Region:    src.Region,
+		}
+		deployments, _, err := c.client.Jobs().Deployments(job.ID, false, queryOptions.WithContext(ctx))
+		if isNotFound(err) {
+			// deleted since listing the jobs
+			continue
+		}
+		if err != nil {
+			return nil, withErrorCode("", err)
+		}
+		res = append(res, deployments...)
+	}
+	return res, nil
+}
@@ -0,0 +1,213 @@
+package nomadcluster
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+)
+
+// IndexedJob is a managed job as found by SearchJobs
+type IndexedJob struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Type      string            `json:"type"`
+	SourceID  string            `json:"sourceId"`
+	Images    []string          `json:"images"`
+	Meta      map[string]string `json:"meta"`
+	// volumes as <type>:<source>, e.g. csi:db-data
+	Volumes []string `json:"volumes"`
+	// paths of the Nomad variables read by templates
+	Variables []string `json:"variables"`
+	// jobs as <namespace>/<id> declared in the meta key nomadopswaitfor
+	WaitFor []string `json:"waitFor"`
+}
+
+type JobSearchQuery struct {
+	// Text matches the name or id of a job, case insensitive
+	Text string
+	// Image matches a part of any image of a task
+	Image     string
+	Namespace string
+	SourceID  string
+	// Meta matches jobs with all of the meta values
+	Meta map[string]string
+}
+
+// jobIndex holds all managed jobs, it is replaced per source whenever the jobs of a source are listed
+// and updated by job events of the event stream in between
+type jobIndex struct {
+	lock sync.RWMutex
+	// jobs by namespace and id
+	jobs map[string]*IndexedJob
+}
+
+func newJobIndex() *jobIndex {
+	return &jobIndex{
+		jobs: map[string]*IndexedJob{},
+	}
+}
+
+func indexKey(namespace, id string) string {
+	return namespace + "/" + id
+}
+
+// setSource replaces the jobs of the source
+func (x *jobIndex) setSource(srcID string, jobs map[string]*api.Job) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for k, j := range x.jobs {
+		if j.SourceID == srcID {
+			delete(x.jobs, k)
+		}
+	}
+	for _, job := range jobs {
+		if j := newIndexedJob(job); j != nil {
+			x.jobs[indexKey(j.Namespace, j.ID)] = j
+		}
+	}
+}
+
+// get returns the job, nil if it is not managed
+func (x *jobIndex) get(namespace, id string) *IndexedJob {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.jobs[indexKey(namespace, id)]
+}
+
+// applyJobEvent adds, updates or removes a job, jobs that are not managed are removed
+func (x *jobIndex) applyJobEvent(job *api.Job, deregistered bool) {
+	if job == nil || job.ID == nil {
+		return
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	key := indexKey(strPtrToStr(job.Namespace), *job.ID)
+	j := newIndexedJob(job)
+	if deregistered || j == nil {
+		delete(x.jobs, key)
+		return
+	}
+	x.jobs[key] = j
+}
+
+func (x *jobIndex) search(q JobSearchQuery) []*IndexedJob {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	text := strings.ToLower(q.Text)
+	res := []*IndexedJob{}
+	for _, j := range x.jobs {
+		if text != "" &&
+			!strings.Contains(strings.ToLower(j.Name), text) &&
+			!strings.Contains(strings.ToLower(j.ID), text) {
+			continue
+		}
+		if q.Namespace != "" && j.Namespace != q.Namespace {
+			continue
+		}
+		if q.SourceID != "" && j.SourceID != q.SourceID {
+			continue
+		}
+		if q.Image != "" && !hasImage(j, q.Image) {
+			continue
+		}
+		if !hasMeta(j, q.Meta) {
+			continue
+		}
+		res = append(res, j)
+	}
+	sort.Slice(res, func(a, b int) bool {
+		if res[a].SourceID != res[b].SourceID {
+			return res[a].SourceID < res[b].SourceID
+		}
+		return indexKey(res[a].Namespace, res[a].Name) < indexKey(res[b].Namespace, res[b].Name)
+	})
+	return res
+}
+
+func hasImage(j *IndexedJob, image string) bool {
+	for _, img := range j.Images {
+		if strings.Contains(img, image) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMeta(j *IndexedJob, meta map[string]string) bool {
+	for k, v := range meta {
+		if j.Meta[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// nomadVarPattern matches the path of nomadVar, nomadVarList, ... in templates
+var nomadVarPattern = regexp.MustCompile(`nomadVar(?:List|ListSafe|Exists)?\s+"([^"]+)"`)
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
+
+// newIndexedJob returns nil for jobs that are not managed by a source
+func newIndexedJob(job *api.Job) *IndexedJob {
+	srcID := job.Meta[metaKeySrcID]
+	if srcID == "" || job.ID == nil {
+		return nil
+	}
+	j := &IndexedJob{
+		ID:        *job.ID,
+		Name:      strPtrToStr(job.Name),
+		Namespace: strPtrToStr(job.Namespace),
+		Type:      strPtrToStr(job.Type),
+		SourceID:  srcID,
+		Images:    []string{},
+		Meta:      job.Meta,
+		Volumes:   []string{},
+		Variables: []string{},
+	}
+	if j.Name == "" {
+		j.Name = j.ID
+	}
+	for _, tg := range job.TaskGroups {
+		for _, v := range tg.Volumes {
+			if v != nil && v.Source != "" {
+				j.Volumes = appendUnique(j.Volumes, v.Type+":"+v.Source)
+			}
+		}
+		for _, task := range tg.Tasks {
+			// docker, podman, containerd, ...
+			if img, ok := task.Config["image"].(string); ok && img != "" {
+				j.Images = append(j.Images, img)
+			}
+			for _, tmpl := range task.Templates {
+				if tmpl == nil || tmpl.EmbeddedTmpl == nil {
+					continue
+				}
+				for _, m := range nomadVarPattern.FindAllStringSubmatch(*tmpl.EmbeddedTmpl, -1) {
+					j.Variables = appendUnique(j.Variables, m[1])
+				}
+			}
+		}
+	}
+	j.WaitFor = application.WaitFor(job)
+	sort.Strings(j.Volumes)
+	return j
+}
+
+// SearchJobs searches the managed jobs of all sources as last seen in the cluster
+func (c *Client) SearchJobs(ctx context.Context, q JobSearchQuery) []*IndexedJob {
+	return c.index.search(q)
+}
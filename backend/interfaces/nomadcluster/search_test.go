@@ -0,0 +1,53 @@
+package nomadcluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestSearchJobs(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{})
+	src := &domain.Source{ID: "src"}
+
+	job, err := c.ParseJob(ctx, strings.Replace(fakeJobFile, "%s", "1.25", 1), application.ParseJobOptions{})
+	if err != nil {
+		t.Fatalf("Could not ParseJob:%v", err)
+	}
+	if _, err := c.UpdateJob(ctx, src, job, false); err != nil {
+		t.Fatalf("Could not UpdateJob:%v", err)
+	}
+	if _, err := c.GetCurrentClusterState(ctx, application.GetCurrentClusterStateOptions{Source: src}); err != nil {
+		t.Fatalf("Could not GetCurrentClusterState:%v", err)
+	}
+
+	for _, tc := range []struct {
+		q     JobSearchQuery
+		found bool
+	}{
+		{JobSearchQuery{Image: "nginx:1.25"}, true},
+		{JobSearchQuery{Image: "redis"}, false},
+		{JobSearchQuery{Text: "WEB", SourceID: "src"}, true},
+		{JobSearchQuery{SourceID: "other"}, false},
+		{JobSearchQuery{Meta: map[string]string{metaKeySrcID: "src"}}, true},
+	} {
+		res := c.SearchJobs(ctx, tc.q)
+		if found := len(res) == 1 && res[0].Name == "web"; found != tc.found {
+			t.Errorf("Expected found=%v for %+v, got %v", tc.found, tc.q, res)
+		}
+	}
+
+	if err := c.DeleteJob(ctx, src, job); err != nil {
+		t.Fatalf("Could not DeleteJob:%v", err)
+	}
+	if _, err := c.GetCurrentClusterState(ctx, application.GetCurrentClusterStateOptions{Source: src}); err != nil {
+		t.Fatalf("Could not GetCurrentClusterState:%v", err)
+	}
+	if res := c.SearchJobs(ctx, JobSearchQuery{}); len(res) != 0 {
+		t.Errorf("Expected the deleted job to be removed, got %v", res)
+	}
+}
@@ -0,0 +1,54 @@
+package nomadcluster
+
+import (
+	"regexp"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+var (
+	// e.g. "* require-digests : Result: false (allowed failures: 0 in enforcement level: hard-mandatory)"
+	sentinelPolicyPattern = regexp.MustCompile(`(?m)^\s*\*?\s*([\w.-]+)\s*:\s*Result:\s*false(?:\s*\(allowed failures:\s*\d+\s+in enforcement level:\s*([\w-]+)\))?`)
+	// e.g. `FALSE - require-digests:3:1 - Rule "main"`
+	sentinelRulePattern = regexp.MustCompile(`FALSE - ([\w.-]+):\d+:\d+ - Rule "([^"]+)"`)
+)
+
+// parseSentinelViolations extracts the failed Sentinel policies and their rules from an API error or plan warnings
+func parseSentinelViolations(msg string) []*domain.PolicyViolation {
+	res := []*domain.PolicyViolation{}
+	byPolicy := map[string]*domain.PolicyViolation{}
+	for _, m := range sentinelPolicyPattern.FindAllStringSubmatch(msg, -1) {
+		if _, ok := byPolicy[m[1]]; ok {
+			continue
+		}
+		v := &domain.PolicyViolation{
+			Policy:           m[1],
+			EnforcementLevel: m[2],
+		}
+		byPolicy[m[1]] = v
+		res = append(res, v)
+	}
+	for _, m := range sentinelRulePattern.FindAllStringSubmatch(msg, -1) {
+		v, ok := byPolicy[m[1]]
+		if !ok {
+			continue
+		}
+		v.Rules = appendUnique(v.Rules, m[2])
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// withSentinelViolations returns a PolicyViolationError if err was caused by Sentinel policies, otherwise nil
+func withSentinelViolations(err error) error {
+	violations := parseSentinelViolations(err.Error())
+	if violations == nil {
+		return nil
+	}
+	return domain.WithErrorCode(domain.ErrorCodePolicyViolation, &domain.PolicyViolationError{
+		Violations: violations,
+		Err:        err,
+	})
+}
@@ -0,0 +1,54 @@
+package nomadcluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// metaKeySpecHash holds the hash of the registered job spec, set for sources that skip the plan diff
+var metaKeySpecHash = "nomadopsspechash"
+
+// specHash hashes the job as submitted, without the meta keys that change with every commit or restart
+// and without the tokens Nomad does not return
+func specHash(job *api.Job) (string, error) {
+	cpy := *job
+	cpy.Meta = map[string]string{}
+	for k, v := range job.Meta {
+		if k == metaKeySrcCommit || k == metaKeyForceRestart || k == metaKeySpecHash {
+			continue
+		}
+		cpy.Meta[k] = v
+	}
+	cpy.VaultToken = nil
+	cpy.ConsulToken = nil
+	b, err := json.Marshal(&cpy)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// specChange compares the hash of the job with the one of the registered job instead of the plan diff,
+// it returns whether the job changed and a summary of the change
+func (c *Client) specChange(src *domain.Source, job *application.JobInfo, hash string) (bool, string) {
+	namespace, _ := jobNamespaceRegion(src, job)
+	if namespace == "" {
+		namespace = "default"
+	}
+	current := c.index.get(namespace, *job.ID)
+	if current == nil {
+		return true, application.JobCreatedSummary(*job.ID)
+	}
+	if current.Meta[metaKeySpecHash] == hash {
+		return false, ""
+	}
+	return true, fmt.Sprintf("Job %s: spec changed (plan diff skipped)", *job.ID)
+}
@@ -0,0 +1,43 @@
+package nomadcluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestSkipPlanDiff(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{})
+	src := &domain.Source{ID: "src", SkipPlanDiff: true}
+
+	update := func(image, commit string) *application.UpdateJobInfo {
+		// the reconciler lists the jobs of the source before updating them
+		if _, err := c.GetCurrentClusterState(ctx, application.GetCurrentClusterStateOptions{Source: src}); err != nil {
+			t.Fatalf("Could not GetCurrentClusterState:%v", err)
+		}
+		job, err := c.ParseJob(ctx, strings.Replace(fakeJobFile, "%s", image, 1), application.ParseJobOptions{})
+		if err != nil {
+			t.Fatalf("Could not ParseJob:%v", err)
+		}
+		job.GitInfo.GitCommit = commit
+		info, err := c.UpdateJob(ctx, src, job, false)
+		if err != nil {
+			t.Fatalf("Could not UpdateJob:%v", err)
+		}
+		return info
+	}
+
+	if info := update("1.25", "c1"); !info.Updated || info.DiffSummary != application.JobCreatedSummary("web") {
+		t.Errorf("Expected the job to be created, got %+v", info)
+	}
+	if info := update("1.25", "c2"); info.Updated {
+		t.Errorf("Expected no update for a new commit without changes, got %+v", info)
+	}
+	if info := update("1.26", "c3"); !info.Updated || info.DiffSummary != "Job web: spec changed (plan diff skipped)" {
+		t.Errorf("Expected the changed spec to be updated, got %+v", info)
+	}
+}
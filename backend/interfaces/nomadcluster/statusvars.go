@@ -0,0 +1,58 @@
+package nomadcluster
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// PutVariable creates or replaces the items of the variable at the path
+func (c *Client) PutVariable(ctx context.Context, namespace, path string, items map[string]string) error {
+	_, _, err := c.client.Variables().Create(&api.Variable{
+		Namespace: namespace,
+		Path:      path,
+		Items:     api.VariableItems(items),
+	}, (&api.WriteOptions{Namespace: namespace}).WithContext(ctx))
+	if err != nil {
+		return withErrorCode("", err)
+	}
+	return nil
+}
+
+// DeleteVariable deletes the variable at the path, missing variables are ignored
+func (c *Client) DeleteVariable(ctx context.Context, namespace, path string) error {
+	_, err := c.client.Variables().Delete(path, (&api.WriteOptions{
+		Namespace: namespace,
+	}).WithContext(ctx))
+	if err != nil && !isNotFound(err) {
+		return withErrorCode("", err)
+	}
+	return nil
+}
+
+// ListVariables returns the items of the variables below the prefix by path
+func (c *Client) ListVariables(ctx context.Context, namespace, prefix string) (map[string]map[string]string, error) {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	vars, _, err := c.client.Variables().PrefixList(prefix, (&api.QueryOptions{
+		Namespace: namespace,
+	}).WithContext(ctx))
+	if err != nil {
+		return nil, withErrorCode("", err)
+	}
+	res := map[string]map[string]string{}
+	for _, v := range vars {
+		items, _, err := c.client.Variables().GetVariableItems(v.Path, (&api.QueryOptions{
+			Namespace: namespace,
+		}).WithContext(ctx))
+		if err == api.ErrVariablePathNotFound || isNotFound(err) {
+			// deleted in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, withErrorCode("", err)
+		}
+		res[v.Path] = items
+	}
+	return res, nil
+}
@@ -0,0 +1,41 @@
+package nomadcluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+func TestStatusVariables(t *testing.T) {
+	ctx := context.Background()
+	c := createFakeClient(t, FakeConfig{})
+
+	err := c.PutVariable(ctx, "default", "nomad-ops/status/web", map[string]string{"health": "healthy"})
+	if err != nil {
+		t.Fatalf("Could not PutVariable:%v", err)
+	}
+	v, _, err := c.client.Variables().Read("nomad-ops/status/web", &api.QueryOptions{Namespace: "default"})
+	if err != nil || v.Items["health"] != "healthy" {
+		t.Fatalf("Expected the published variable, got %v %v", v, err)
+	}
+
+	err = c.PutVariable(ctx, "default", "nomad-ops/statusboard", map[string]string{"health": "healthy"})
+	if err != nil {
+		t.Fatalf("Could not PutVariable:%v", err)
+	}
+	vars, err := c.ListVariables(ctx, "default", "nomad-ops/status")
+	if err != nil || len(vars) != 1 || vars["nomad-ops/status/web"]["health"] != "healthy" {
+		t.Fatalf("Expected only the variable below the prefix, got %v %v", vars, err)
+	}
+
+	if err := c.DeleteVariable(ctx, "default", "nomad-ops/status/web"); err != nil {
+		t.Fatalf("Could not DeleteVariable:%v", err)
+	}
+	if v, _, err := c.client.Variables().Read("nomad-ops/status/web", &api.QueryOptions{Namespace: "default"}); err == nil {
+		t.Fatalf("Expected the variable to be deleted, got %v", v)
+	}
+	if err := c.DeleteVariable(ctx, "default", "nomad-ops/status/web"); err != nil {
+		t.Fatalf("Expected deleting a missing variable to succeed, got %v", err)
+	}
+}
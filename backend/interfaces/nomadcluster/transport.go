@@ -0,0 +1,120 @@
+package nomadcluster
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// TransportConfig tunes the http transport of the Nomad API, zero values keep the defaults of the Nomad API client
+type TransportConfig struct {
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+}
+
+// newHTTPClient creates the http client of the Nomad API. Addresses with the unix scheme are dialed as unix socket,
+// several addresses or SRV records are failed over by a failoverTransport. The returned address replaces them
+// for the Nomad API client.
+func newHTTPClient(ctx context.Context,
+	logger log.Logger,
+	address string,
+	cfg TransportConfig,
+	tlsConfig *api.TLSConfig) (*http.Client, string, error) {
+	// same defaults as the Nomad API client
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = 30 * time.Second
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = 30 * time.Second
+	}
+	if cfg.TLSHandshakeTimeout == 0 {
+		cfg.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = 90 * time.Second
+	}
+	if cfg.MaxIdleConns == 0 {
+		cfg.MaxIdleConns = 100
+	}
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = runtime.GOMAXPROCS(0) + 1
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		},
+		// the event stream and alloc exec are not supported with http/2
+		ForceAttemptHTTP2: false,
+	}
+	client := &http.Client{
+		Transport: transport,
+	}
+
+	if isFailoverAddress(address) {
+		err := api.ConfigureTLS(client, tlsConfig)
+		if err != nil {
+			return nil, "", err
+		}
+		ft, err := newFailoverTransport(ctx, logger, address, transport)
+		if err != nil {
+			return nil, "", err
+		}
+		client.Transport = ft
+		// the scheme and host are set by the failoverTransport
+		return client, "http://nomad", nil
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid nomad address %s:%v", address, err)
+	}
+	if u.Scheme != "unix" {
+		err = api.ConfigureTLS(client, tlsConfig)
+		if err != nil {
+			return nil, "", err
+		}
+		return client, address, nil
+	}
+
+	socket := u.Path
+	if socket == "" {
+		// unix:relative/path
+		socket = u.Opaque
+	}
+	if socket == "" {
+		return nil, "", fmt.Errorf("nomad address %s has no socket path", address)
+	}
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+	// the host is ignored by the dialer
+	return client, "http://nomad", nil
+}
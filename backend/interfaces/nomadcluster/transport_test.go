@@ -0,0 +1,121 @@
+package nomadcluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "nomad.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Could not listen on %s:%v", socket, err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/status/leader" {
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`"127.0.0.1:4647"`))
+	})}
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Close()
+
+	c, err := CreateClient(context.Background(), log.NewSimpleLogger(false, "Test"), ClientConfig{
+		Address: "unix://" + socket,
+		UIURL:   "https://nomad.example.com",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateClient:%v", err)
+	}
+	leader, err := c.client.Status().Leader()
+	if err != nil {
+		t.Fatalf("Could not reach the unix socket:%v", err)
+	}
+	if leader != "127.0.0.1:4647" {
+		t.Errorf("Unexpected leader %s", leader)
+	}
+	if u, _ := c.GetURL(context.Background()); u != "https://nomad.example.com" {
+		t.Errorf("Unexpected ui url %s", u)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`"127.0.0.1:4647"`))
+	}))
+	defer srv.Close()
+	// a node that went away
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone := "http://" + l.Addr().String()
+	l.Close()
+
+	for _, bad := range []string{"", "ftp://nomad", "srv+https://nomad:4646", "srv+https://nomad,https://nomad"} {
+		if _, err := newFailoverTransport(context.Background(), log.NewSimpleLogger(false, "Test"), bad+",", nil); err == nil {
+			t.Errorf("Expected %q to be invalid", bad)
+		}
+	}
+
+	c, err := CreateClient(context.Background(), log.NewSimpleLogger(false, "Test"), ClientConfig{
+		Address: gone + ", " + srv.URL,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateClient:%v", err)
+	}
+	if u, _ := c.GetURL(context.Background()); u != gone {
+		t.Errorf("Expected the first address as ui url, got %s", u)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.client.Status().Leader(); err != nil {
+			t.Fatalf("Expected to fail over:%v", err)
+		}
+	}
+	hc, address, err := newHTTPClient(context.Background(), log.NewSimpleLogger(false, "Test"), gone+","+srv.URL, TransportConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := hc.Get(address + "/v1/status/leader")
+	if err != nil {
+		t.Fatalf("Expected to fail over:%v", err)
+	}
+	resp.Body.Close()
+	ft := hc.Transport.(*failoverTransport)
+	if ft.current != srv.Listener.Addr().String() {
+		t.Errorf("Expected requests to stick to the reachable agent, got %s", ft.current)
+	}
+
+	// SRV records are resolved in order of their priority
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		if name != "nomad.service.consul" {
+			return "", nil, fmt.Errorf("unknown name %s", name)
+		}
+		_, port, _ := net.SplitHostPort(l.Addr().String())
+		goneSRV, _ := strconv.Atoi(port)
+		_, port, _ = net.SplitHostPort(srv.Listener.Addr().String())
+		srvPort, _ := strconv.Atoi(port)
+		return "", []*net.SRV{
+			{Target: "127.0.0.1.", Port: uint16(goneSRV), Priority: 1},
+			{Target: "127.0.0.1.", Port: uint16(srvPort), Priority: 2},
+		}, nil
+	}
+	defer func() { lookupSRV = net.DefaultResolver.LookupSRV }()
+	c, err = CreateClient(context.Background(), log.NewSimpleLogger(false, "Test"), ClientConfig{
+		Address: "srv+http://nomad.service.consul",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Could not CreateClient:%v", err)
+	}
+	if _, err := c.client.Status().Leader(); err != nil {
+		t.Fatalf("Expected to fail over to the second SRV record:%v", err)
+	}
+}
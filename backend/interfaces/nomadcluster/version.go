@@ -0,0 +1,312 @@
+package nomadcluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// versions tested with nomad-ops, see ClientConfig.TestedVersions
+const (
+	defaultMinTestedVersion = "1.3"
+	defaultMaxTestedVersion = "1.6"
+)
+
+// ClusterVersion are the versions of the Nomad agents, refreshed at the creation of the client and
+// every ClientConfig.VersionCheckInterval
+type ClusterVersion struct {
+	// distinct versions of the alive servers and ready clients
+	Servers []string `json:"servers"`
+	Clients []string `json:"clients"`
+	// Skew is set if any agent runs a version outside the tested range
+	Skew      bool      `json:"skew"`
+	Message   string    `json:"message,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// oldest returns the lowest of the versions, empty if none
+func oldest(versions []string) string {
+	res := ""
+	for _, v := range versions {
+		if res == "" || compareVersions(v, res) < 0 {
+			res = v
+		}
+	}
+	return res
+}
+
+// parseVersion returns major, minor and patch of e.g. 1.6.1, v1.5.0-beta.1 or 1.4.3+ent, missing components are -1
+func parseVersion(v string) [3]int {
+	res := [3]int{-1, -1, -1}
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	for i, p := range strings.SplitN(v, ".", 3) {
+		end := 0
+		for end < len(p) && p[end] >= '0' && p[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(p[:end])
+		if err != nil {
+			break
+		}
+		res[i] = n
+	}
+	return res
+}
+
+// compareVersions compares the components set in both versions, so 1.6.2 equals 1.6
+func compareVersions(a, b string) int {
+	pa, pb := parseVersion(a), parseVersion(b)
+	for i := range pa {
+		if pa[i] < 0 || pb[i] < 0 {
+			return 0
+		}
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionTracker holds the last known versions of the cluster
+type versionTracker struct {
+	lock     sync.Mutex
+	min, max string
+	current  ClusterVersion
+}
+
+func newVersionTracker(tested []string) *versionTracker {
+	t := &versionTracker{
+		min: defaultMinTestedVersion,
+		max: defaultMaxTestedVersion,
+	}
+	if len(tested) == 2 && tested[0] != "" && tested[1] != "" {
+		t.min, t.max = tested[0], tested[1]
+	}
+	return t
+}
+
+// set stores the versions and returns them with the skew evaluated against the tested range
+func (t *versionTracker) set(servers, clients []string, err error, now time.Time) ClusterVersion {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	v := ClusterVersion{
+		Servers:   servers,
+		Clients:   clients,
+		CheckedAt: now,
+	}
+	if err != nil {
+		// keep the last known versions
+		v.Servers, v.Clients = t.current.Servers, t.current.Clients
+		v.Error = err.Error()
+	}
+	outside := []string{}
+	for _, ver := range append(append([]string{}, v.Servers...), v.Clients...) {
+		if compareVersions(ver, t.min) < 0 || compareVersions(ver, t.max) > 0 {
+			outside = appendUnique(outside, ver)
+		}
+	}
+	if len(outside) > 0 {
+		sort.Strings(outside)
+		v.Skew = true
+		v.Message = fmt.Sprintf("Nomad %s is outside the tested versions %s to %s", strings.Join(outside, ", "), t.min, t.max)
+	}
+	t.current = v
+	return v
+}
+
+func (t *versionTracker) get() ClusterVersion {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.current
+}
+
+// ClusterVersion returns the last known versions of the Nomad agents
+func (c *Client) ClusterVersion() ClusterVersion {
+	return c.versions.get()
+}
+
+// refreshVersions queries the versions of the alive servers and the ready clients
+func (c *Client) refreshVersions(ctx context.Context) ClusterVersion {
+	servers, clients, err := c.queryVersions(ctx)
+	v := c.versions.set(servers, clients, err, time.Now())
+	if err != nil {
+		c.logger.LogError(ctx, "Could not query the Nomad versions:%v", err)
+	} else if v.Skew {
+		c.logger.LogInfo(ctx, "Warning: %s", v.Message)
+	}
+	return v
+}
+
+func (c *Client) queryVersions(ctx context.Context) ([]string, []string, error) {
+	members, err := c.client.Agent().MembersOpts((&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	servers := []string{}
+	for _, m := range members.Members {
+		if m.Status == "alive" && m.Tags["build"] != "" {
+			servers = appendUnique(servers, m.Tags["build"])
+		}
+	}
+	nodes, _, err := c.client.Nodes().List((&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	clients := []string{}
+	for _, n := range nodes {
+		if n.Status == api.NodeStatusReady && n.Version != "" {
+			clients = appendUnique(clients, n.Version)
+		}
+	}
+	sort.Strings(servers)
+	sort.Strings(clients)
+	return servers, clients, nil
+}
+
+// runVersionCheck refreshes the versions now and every interval until ctx is done
+func (c *Client) runVersionCheck(ctx context.Context, interval time.Duration) {
+	c.refreshVersions(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.refreshVersions(ctx)
+		}
+	}
+}
+
+// jobFeature is a jobspec feature the Nomad clients running the job must support
+type jobFeature struct {
+	name    string
+	version string
+	used    func(job *api.Job) bool
+}
+
+var jobFeatures = []jobFeature{
+	{
+		name:    "services with the nomad provider",
+		version: "1.3.0",
+		used: func(job *api.Job) bool {
+			return anyService(job, func(s *api.Service) bool { return s.Provider == "nomad" })
+		},
+	},
+	{
+		name:    "max_client_disconnect",
+		version: "1.3.0",
+		used: func(job *api.Job) bool {
+			for _, tg := range job.TaskGroups {
+				if tg.MaxClientDisconnect != nil {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		name:    "templates reading Nomad variables",
+		version: "1.4.0",
+		used: func(job *api.Job) bool {
+			return anyTemplate(job, func(t *api.Template) bool {
+				return t.EmbeddedTmpl != nil && nomadVarPattern.MatchString(*t.EmbeddedTmpl)
+			})
+		},
+	},
+	{
+		name:    "error_on_missing_key in templates",
+		version: "1.5.0",
+		used: func(job *api.Job) bool {
+			return anyTemplate(job, func(t *api.Template) bool { return t.ErrMissingKey != nil && *t.ErrMissingKey })
+		},
+	},
+	{
+		name:    "constraints on ${node.pool}",
+		version: "1.6.0",
+		used: func(job *api.Job) bool {
+			constraints := append([]*api.Constraint{}, job.Constraints...)
+			for _, tg := range job.TaskGroups {
+				constraints = append(constraints, tg.Constraints...)
+				for _, t := range tg.Tasks {
+					constraints = append(constraints, t.Constraints...)
+				}
+			}
+			for _, con := range constraints {
+				if con != nil && (con.LTarget == "${node.pool}" || con.RTarget == "${node.pool}") {
+					return true
+				}
+			}
+			return false
+		},
+	},
+}
+
+func anyService(job *api.Job, f func(s *api.Service) bool) bool {
+	for _, tg := range job.TaskGroups {
+		for _, s := range tg.Services {
+			if f(s) {
+				return true
+			}
+		}
+		for _, t := range tg.Tasks {
+			for _, s := range t.Services {
+				if f(s) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func anyTemplate(job *api.Job, f func(t *api.Template) bool) bool {
+	for _, tg := range job.TaskGroups {
+		for _, t := range tg.Tasks {
+			for _, tmpl := range t.Templates {
+				if f(tmpl) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// unsupportedFeatures returns a warning for every feature of the job newer than the oldest client of the cluster
+func unsupportedFeatures(job *api.Job, v ClusterVersion) []string {
+	oldestClient := oldest(v.Clients)
+	if oldestClient == "" || job == nil {
+		return nil
+	}
+	var res []string
+	for _, f := range jobFeatures {
+		if compareVersions(oldestClient, f.version) < 0 && f.used(job) {
+			res = append(res, fmt.Sprintf("%s requires Nomad %s, but clients of the cluster run Nomad %s", f.name, f.version, oldestClient))
+		}
+	}
+	return res
+}
+
+// versionHint explains a parse error of a job file caused by blocks unknown to older servers
+func versionHint(err error, v ClusterVersion) string {
+	msg := err.Error()
+	if !strings.Contains(msg, "Unsupported argument") && !strings.Contains(msg, "Unsupported block type") {
+		return ""
+	}
+	oldestServer := oldest(v.Servers)
+	if oldestServer == "" {
+		return ""
+	}
+	return fmt.Sprintf("the job file may use features newer than Nomad %s of the cluster", oldestServer)
+}
@@ -0,0 +1,41 @@
+package nomadcluster
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestVersionSkew(t *testing.T) {
+	tracker := newVersionTracker([]string{"1.3", "1.6"})
+	v := tracker.set([]string{"1.6.1+ent"}, []string{"1.2.15", "1.5.3"}, nil, time.Now())
+	if !v.Skew || v.Message != "Nomad 1.2.15 is outside the tested versions 1.3 to 1.6" {
+		t.Errorf("Expected skew of the old client, got %+v", v)
+	}
+	v = tracker.set(nil, nil, errors.New("connection refused"), time.Now())
+	if !v.Skew || v.Error == "" || len(v.Clients) != 2 {
+		t.Errorf("Expected the last known versions to be kept, got %+v", v)
+	}
+	if v = tracker.set([]string{"1.6.1"}, []string{"1.5.3"}, nil, time.Now()); v.Skew {
+		t.Errorf("Expected no skew, got %+v", v)
+	}
+
+	job := &api.Job{TaskGroups: []*api.TaskGroup{{
+		Constraints: []*api.Constraint{api.NewConstraint("${node.pool}", "=", "gpu")},
+		Tasks: []*api.Task{{
+			Templates: []*api.Template{{EmbeddedTmpl: log.ToStrPtr(`{{ with nomadVar "nomad/jobs/web" }}{{ .token }}{{ end }}`)}},
+		}},
+	}}}
+	warnings := unsupportedFeatures(job, v)
+	if fmt.Sprint(warnings) != "[constraints on ${node.pool} requires Nomad 1.6.0, but clients of the cluster run Nomad 1.5.3]" {
+		t.Errorf("Unexpected warnings %v", warnings)
+	}
+	if hint := versionHint(errors.New(`Unsupported argument; An argument named "node_pool" is not expected here.`), v); hint == "" {
+		t.Error("Expected a hint for the unsupported argument")
+	}
+}
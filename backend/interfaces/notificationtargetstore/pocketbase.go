@@ -0,0 +1,121 @@
+package notificationtargetstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PocketBaseStore struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PocketBaseStoreConfig
+}
+
+type PocketBaseStoreConfig struct {
+	App core.App
+}
+
+func CreatePocketBaseStore(ctx context.Context,
+	logger log.Logger,
+	cfg PocketBaseStoreConfig) (*PocketBaseStore, error) {
+	t := &PocketBaseStore{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+func (s *PocketBaseStore) findRecord(name string) (*models.Record, error) {
+	record, err := s.cfg.App.Dao().FindFirstRecordByData("notification_targets", "name", name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *PocketBaseStore) GetNotificationTarget(ctx context.Context, name string) (*domain.NotificationTarget, error) {
+	record, err := s.findRecord(name)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NotificationTargetFromRecord(record), nil
+}
+
+func (s *PocketBaseStore) SetNotificationDelivery(ctx context.Context, name string, d domain.NotificationDelivery) error {
+	record, err := s.findRecord(name)
+	if err != nil {
+		return err
+	}
+
+	record.Set("lastDeliveryStatus", d.Status)
+	record.Set("lastError", d.Error)
+	record.Set("lastAttemptTime", d.Time)
+	record.Set("pending", d.Pending)
+	if d.Status == domain.NotificationDeliveryStatusDelivered {
+		record.Set("lastSuccessTime", d.Time)
+	}
+
+	if err := s.cfg.App.Dao().SaveRecord(record); err != nil {
+		return err
+	}
+	return nil
+}
+
+// EnsureNotificationTargets makes sure that there is a record for every configured notifier
+// and removes records of notifiers that are no longer configured
+func (s *PocketBaseStore) EnsureNotificationTargets(ctx context.Context, names []string) error {
+	coll, err := s.cfg.App.Dao().FindCollectionByNameOrId("notification_targets")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		_, err := s.findRecord(name)
+		if err == nil {
+			continue
+		}
+		if err != errors.ErrNotFound {
+			return err
+		}
+		s.logger.LogInfo(ctx, "Creating notification target %s", name)
+		record := models.NewRecord(coll)
+		record.Set("name", name)
+		if err := s.cfg.App.Dao().SaveRecord(record); err != nil {
+			return err
+		}
+	}
+
+	records, err := s.cfg.App.Dao().FindRecordsByExpr("notification_targets",
+		dbx.Not(dbx.In("name", toInterfaces(names)...)))
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		s.logger.LogInfo(ctx, "Removing notification target %s", record.GetString("name"))
+		if err := s.cfg.App.Dao().DeleteRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toInterfaces(in []string) []interface{} {
+	res := make([]interface{}, 0, len(in))
+	for _, s := range in {
+		res = append(res, s)
+	}
+	return res
+}
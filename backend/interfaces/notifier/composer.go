@@ -2,14 +2,48 @@ package notifier
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	utilerrors "github.com/nomad-ops/nomad-ops/backend/utils/errors"
 	"github.com/nomad-ops/nomad-ops/backend/utils/log"
 )
 
 type ComposerConfig struct {
 	Notifiers map[string]application.Notifier
+	// TargetRepo provides the mute/snooze settings per notifier, optional
+	TargetRepo application.NotificationTargetRepo
+	// DedupWindow suppresses identical notifications to the same target within this duration, 0 disables it
+	DedupWindow time.Duration
+	// RetryBaseDelay is the delay before the first retry of a failed delivery, doubled on every further attempt
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay caps the delay between two retries
+	RetryMaxDelay time.Duration
+	// MaxAttempts is the number of delivery attempts before a notification is dropped, 1 disables retries
+	MaxAttempts int
+	// Now returns the current time, defaults to time.Now
+	Now func() time.Time
+}
+
+// digest collects the notifications of a target during its digest window
+type digest struct {
+	start   time.Time
+	window  time.Duration
+	entries []application.NotifyOptions
+}
+
+type queuedNotification struct {
+	target string
+	opts   application.NotifyOptions
+	// sent are the notifications recorded as sent once opts is delivered, the entries of a digest
+	sent      []application.NotifyOptions
+	attempts  int
+	nextRetry time.Time
 }
 
 // Composer ...
@@ -17,26 +51,72 @@ type Composer struct {
 	ctx    context.Context
 	logger log.Logger
 	cfg    ComposerConfig
+	now    func() time.Time
+
+	lock     sync.Mutex
+	lastSent map[string]time.Time
+	queue    []*queuedNotification
+	digests  map[string]*digest
 }
 
 // CreateComposer ...
 func CreateComposer(ctx context.Context,
 	logger log.Logger,
 	cfg ComposerConfig) (*Composer, error) {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
 	t := &Composer{
-		ctx:    ctx,
-		logger: logger,
-		cfg:    cfg,
+		ctx:      ctx,
+		logger:   logger,
+		cfg:      cfg,
+		now:      cfg.Now,
+		lastSent: map[string]time.Time{},
+		digests:  map[string]*digest{},
 	}
 
+	go t.loop()
+
 	return t, nil
 }
 
+// TargetNames returns the names of all configured notifiers
+func (s *Composer) TargetNames() []string {
+	var res []string
+	for n := range s.cfg.Notifiers {
+		res = append(res, n)
+	}
+	return res
+}
+
 func (s *Composer) Notify(ctx context.Context, opts application.NotifyOptions) error {
+	now := s.now()
+	if opts.Source != nil && opts.Source.NotificationsSilenced(now) {
+		s.logger.LogTrace(ctx, "Notifications for source %s are muted or snoozed", opts.Source.ID)
+		return nil
+	}
+
 	var aggErr error
 	for n, notifier := range s.cfg.Notifiers {
-		s.logger.LogTrace(ctx, "Notifying %s", n)
-		err := notifier.Notify(ctx, opts)
+		target := s.getTarget(ctx, n)
+		if target != nil && target.IsSilenced(now) {
+			s.logger.LogTrace(ctx, "Notification target %s is muted or snoozed", n)
+			continue
+		}
+		if target != nil && !target.Selects(opts.Source) {
+			s.logger.LogTrace(ctx, "Notification target %s does not select source %s", n, opts.Source.ID)
+			continue
+		}
+		if s.isDuplicate(n, opts, now) {
+			s.logger.LogTrace(ctx, "Skipping duplicate notification for %s", n)
+			continue
+		}
+		if target != nil && target.Digest() > 0 {
+			s.logger.LogTrace(ctx, "Adding notification to the digest of %s", n)
+			s.addToDigest(n, target.Digest(), opts, now)
+			continue
+		}
+		err := s.deliver(ctx, n, notifier, opts, []application.NotifyOptions{opts}, now)
 		if err != nil {
 			aggErr = errors.Join(aggErr, err)
 		}
@@ -44,3 +124,218 @@ func (s *Composer) Notify(ctx context.Context, opts application.NotifyOptions) e
 
 	return aggErr
 }
+
+// deliver sends the notification to the target, failed deliveries are queued for a retry if enabled.
+// Only temporary errors are retried, e.g. not a template that cannot be rendered.
+// The notifications in sent are recorded as sent once the delivery succeeded.
+func (s *Composer) deliver(ctx context.Context, n string, notifier application.Notifier, opts application.NotifyOptions,
+	sent []application.NotifyOptions, now time.Time) error {
+	s.logger.LogTrace(ctx, "Notifying %s", n)
+	err := notifier.Notify(ctx, opts)
+	if err != nil && s.cfg.MaxAttempts > 1 && utilerrors.IsTemporary(err) {
+		s.logger.LogError(ctx, "Could not notify %s, will retry:%v", n, err)
+		s.enqueue(ctx, &queuedNotification{
+			target:    n,
+			opts:      opts,
+			sent:      sent,
+			attempts:  1,
+			nextRetry: now.Add(s.retryDelay(1)),
+		}, err, now)
+		return nil
+	}
+	if err == nil {
+		s.markSent(n, sent, now)
+	}
+	s.reportDelivery(ctx, n, err, now)
+	return err
+}
+
+func (s *Composer) retryDelay(attempts int) time.Duration {
+	d := s.cfg.RetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if s.cfg.RetryMaxDelay > 0 && d >= s.cfg.RetryMaxDelay {
+			return s.cfg.RetryMaxDelay
+		}
+	}
+	return d
+}
+
+func (s *Composer) enqueue(ctx context.Context, q *queuedNotification, err error, now time.Time) {
+	s.lock.Lock()
+	s.queue = append(s.queue, q)
+	s.lock.Unlock()
+	s.reportDelivery(ctx, q.target, err, now)
+}
+
+func (s *Composer) pending(target string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	count := 0
+	for _, q := range s.queue {
+		if q.target == target {
+			count++
+		}
+	}
+	return count
+}
+
+// reportDelivery stores the outcome of a delivery attempt at the target
+func (s *Composer) reportDelivery(ctx context.Context, target string, err error, now time.Time) {
+	if s.cfg.TargetRepo == nil {
+		return
+	}
+	d := domain.NotificationDelivery{
+		Status:  domain.NotificationDeliveryStatusDelivered,
+		Time:    now,
+		Pending: s.pending(target),
+	}
+	if err != nil {
+		d.Status = domain.NotificationDeliveryStatusFailed
+		d.Error = err.Error()
+		if d.Pending > 0 {
+			d.Status = domain.NotificationDeliveryStatusRetrying
+		}
+	}
+	err = s.cfg.TargetRepo.SetNotificationDelivery(ctx, target, d)
+	if err != nil && err != utilerrors.ErrNotFound {
+		s.logger.LogError(ctx, "Could not SetNotificationDelivery for %s:%v", target, err)
+	}
+}
+
+func (s *Composer) loop() {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			// send what was collected so far
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			s.flushDigests(ctx, time.Time{}, true)
+			return
+		case <-t.C:
+			now := s.now()
+			s.processRetries(s.ctx, now)
+			s.flushDigests(s.ctx, now, false)
+		}
+	}
+}
+
+// processRetries sends all queued notifications that are due
+func (s *Composer) processRetries(ctx context.Context, now time.Time) {
+	s.lock.Lock()
+	var due []*queuedNotification
+	var remaining []*queuedNotification
+	for _, q := range s.queue {
+		if now.Before(q.nextRetry) {
+			remaining = append(remaining, q)
+			continue
+		}
+		due = append(due, q)
+	}
+	s.queue = remaining
+	s.lock.Unlock()
+
+	for _, q := range due {
+		notifier, ok := s.cfg.Notifiers[q.target]
+		if !ok {
+			continue
+		}
+		q.attempts++
+		err := notifier.Notify(ctx, q.opts)
+		if err == nil {
+			s.logger.LogInfo(ctx, "Delivered notification to %s after %d attempts", q.target, q.attempts)
+			s.markSent(q.target, q.sent, now)
+			s.reportDelivery(ctx, q.target, nil, now)
+			continue
+		}
+		if q.attempts >= s.cfg.MaxAttempts || !utilerrors.IsTemporary(err) {
+			s.logger.LogError(ctx, "Giving up notifying %s after %d attempts:%v", q.target, q.attempts, err)
+			s.reportDelivery(ctx, q.target, err, now)
+			continue
+		}
+		q.nextRetry = now.Add(s.retryDelay(q.attempts))
+		s.enqueue(ctx, q, err, now)
+	}
+}
+
+// getTarget returns nil if the target has no settings
+func (s *Composer) getTarget(ctx context.Context, name string) *domain.NotificationTarget {
+	if s.cfg.TargetRepo == nil {
+		return nil
+	}
+	t, err := s.cfg.TargetRepo.GetNotificationTarget(ctx, name)
+	if err == utilerrors.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		// rather notify too often than lose a notification
+		s.logger.LogError(ctx, "Could not GetNotificationTarget %s:%v", name, err)
+		return nil
+	}
+	return t
+}
+
+// isDuplicate returns true if the same notification was sent to the target within the dedup window,
+// or is waiting for a retry or in the digest of the target, which will deliver it
+func (s *Composer) isDuplicate(target string, opts application.NotifyOptions, now time.Time) bool {
+	if s.cfg.DedupWindow <= 0 {
+		return false
+	}
+	f := fingerprint(opts)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for k, t := range s.lastSent {
+		if now.Sub(t) >= s.cfg.DedupWindow {
+			delete(s.lastSent, k)
+		}
+	}
+	if _, ok := s.lastSent[target+"/"+f]; ok {
+		return true
+	}
+	for _, q := range s.queue {
+		if q.target != target {
+			continue
+		}
+		for _, o := range q.sent {
+			if fingerprint(o) == f {
+				return true
+			}
+		}
+	}
+	if d, ok := s.digests[target]; ok {
+		for _, o := range d.entries {
+			if fingerprint(o) == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// markSent remembers the delivered notifications for the dedup window
+func (s *Composer) markSent(target string, sent []application.NotifyOptions, now time.Time) {
+	if s.cfg.DedupWindow <= 0 {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, o := range sent {
+		s.lastSent[target+"/"+fingerprint(o)] = now
+	}
+}
+
+func fingerprint(opts application.NotifyOptions) string {
+	h := sha256.New()
+	if opts.Source != nil {
+		fmt.Fprintf(h, "%s\n", opts.Source.ID)
+	}
+	fmt.Fprintf(h, "%s\n%s\n%s\n", opts.Type, opts.Message, opts.GitInfo.GitCommit)
+	for _, i := range opts.Infos {
+		fmt.Fprintf(h, "%s=%s\n", i.Header, i.Text)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,329 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// fakeClock is read by the loop of the composer while the tests advance it
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = now
+}
+
+type countingNotifier struct {
+	count int
+}
+
+func (n *countingNotifier) Notify(ctx context.Context, opts application.NotifyOptions) error {
+	n.count++
+	return nil
+}
+
+type targetRepo map[string]*domain.NotificationTarget
+
+func (r targetRepo) GetNotificationTarget(ctx context.Context, name string) (*domain.NotificationTarget, error) {
+	t, ok := r[name]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	return t, nil
+}
+
+func (r targetRepo) SetNotificationDelivery(ctx context.Context, name string, d domain.NotificationDelivery) error {
+	t, ok := r[name]
+	if !ok {
+		return errors.ErrNotFound
+	}
+	t.LastDeliveryStatus = d.Status
+	t.LastError = d.Error
+	t.Pending = d.Pending
+	return nil
+}
+
+type flakyNotifier struct {
+	failures int
+	count    int
+	// permanent fails like a template that cannot be rendered
+	permanent bool
+}
+
+func (n *flakyNotifier) Notify(ctx context.Context, opts application.NotifyOptions) error {
+	n.count++
+	if n.count <= n.failures && n.permanent {
+		return fmt.Errorf("template: body:1: unexpected EOF")
+	}
+	if n.count <= n.failures {
+		return errors.CreateTemporaryError(fmt.Errorf("unavailable"))
+	}
+	return nil
+}
+
+func TestComposerMuteAndDedup(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	clock := &fakeClock{now: now}
+
+	slack := &countingNotifier{}
+	webhook := &countingNotifier{}
+	c, err := CreateComposer(ctx, log.NewSimpleLogger(false, "Test"), ComposerConfig{
+		Notifiers: map[string]application.Notifier{
+			"slack":   slack,
+			"webhook": webhook,
+		},
+		TargetRepo: targetRepo{
+			"webhook": {Name: "webhook", SnoozedUntil: &later},
+		},
+		DedupWindow: 10 * time.Minute,
+		Now:         clock.Now,
+	})
+	if err != nil {
+		t.Fatalf("Could not CreateComposer:%v", err)
+	}
+
+	opts := application.NotifyOptions{
+		Source:  &domain.Source{ID: "src"},
+		Type:    application.NotificationError,
+		Message: "Could not Reconcile",
+	}
+
+	_ = c.Notify(ctx, opts)
+	_ = c.Notify(ctx, opts)
+	if slack.count != 1 {
+		t.Errorf("Expected the duplicate to be suppressed, got %d notifications", slack.count)
+	}
+	if webhook.count != 0 {
+		t.Errorf("Expected the snoozed target to be skipped, got %d notifications", webhook.count)
+	}
+
+	clock.Set(now.Add(2 * time.Hour))
+	_ = c.Notify(ctx, opts)
+	if slack.count != 2 || webhook.count != 1 {
+		t.Errorf("Expected notifications after window and snooze expired, got %d/%d", slack.count, webhook.count)
+	}
+
+	opts.Source.NotificationsMuted = true
+	opts.Message = "Something else"
+	_ = c.Notify(ctx, opts)
+	if slack.count != 2 || webhook.count != 1 {
+		t.Errorf("Expected muted source to be skipped, got %d/%d", slack.count, webhook.count)
+	}
+}
+
+func TestComposerDedupAfterFailedDelivery(t *testing.T) {
+	ctx := context.Background()
+	flaky := &flakyNotifier{failures: 1}
+	c, err := CreateComposer(ctx, log.NewSimpleLogger(false, "Test"), ComposerConfig{
+		Notifiers: map[string]application.Notifier{
+			"flaky": flaky,
+		},
+		DedupWindow: 10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Could not CreateComposer:%v", err)
+	}
+
+	opts := application.NotifyOptions{Type: application.NotificationError, Message: "Could not Reconcile"}
+	if err := c.Notify(ctx, opts); err == nil {
+		t.Fatalf("Expected the delivery to fail")
+	}
+	if err := c.Notify(ctx, opts); err != nil {
+		t.Fatalf("Expected the failed notification not to be a duplicate, got:%v", err)
+	}
+	_ = c.Notify(ctx, opts)
+	if flaky.count != 2 {
+		t.Errorf("Expected the delivered notification to be de-duplicated, got %d attempts", flaky.count)
+	}
+}
+
+func TestComposerSourceSelector(t *testing.T) {
+	ctx := context.Background()
+	pager := &countingNotifier{}
+	slack := &countingNotifier{}
+	c, err := CreateComposer(ctx, log.NewSimpleLogger(false, "Test"), ComposerConfig{
+		Notifiers: map[string]application.Notifier{
+			"pager": pager,
+			"slack": slack,
+		},
+		TargetRepo: targetRepo{
+			"pager": {Name: "pager", SourceSelector: "env=prod"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Could not CreateComposer:%v", err)
+	}
+
+	_ = c.Notify(ctx, application.NotifyOptions{Source: &domain.Source{ID: "dev", Labels: map[string]string{"env": "dev"}}, Message: "dev"})
+	_ = c.Notify(ctx, application.NotifyOptions{Source: &domain.Source{ID: "prod", Labels: map[string]string{"env": "prod"}}, Message: "prod"})
+	_ = c.Notify(ctx, application.NotifyOptions{Message: "startup"})
+	if pager.count != 2 || slack.count != 3 {
+		t.Errorf("Expected only prod and notifications without source on the pager, got %d/%d", pager.count, slack.count)
+	}
+}
+
+func TestComposerRetry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// stop the background retry loop right away, retries are triggered manually below
+	loopCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	flaky := &flakyNotifier{failures: 2}
+	broken := &flakyNotifier{failures: 100}
+	invalid := &flakyNotifier{failures: 100, permanent: true}
+	repo := targetRepo{
+		"flaky":   {Name: "flaky"},
+		"broken":  {Name: "broken"},
+		"invalid": {Name: "invalid"},
+	}
+	c, err := CreateComposer(loopCtx, log.NewSimpleLogger(false, "Test"), ComposerConfig{
+		Notifiers: map[string]application.Notifier{
+			"flaky":   flaky,
+			"broken":  broken,
+			"invalid": invalid,
+		},
+		TargetRepo:     repo,
+		RetryBaseDelay: time.Minute,
+		RetryMaxDelay:  90 * time.Second,
+		MaxAttempts:    3,
+		Now:            func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("Could not CreateComposer:%v", err)
+	}
+
+	err = c.Notify(ctx, application.NotifyOptions{Message: "Synced"})
+	if err == nil || repo["invalid"].LastDeliveryStatus != domain.NotificationDeliveryStatusFailed {
+		t.Fatalf("Expected only the permanent error to be returned, got:%v", err)
+	}
+	if repo["flaky"].LastDeliveryStatus != domain.NotificationDeliveryStatusRetrying || repo["flaky"].Pending != 1 {
+		t.Errorf("Expected flaky to be retrying, got %s/%d", repo["flaky"].LastDeliveryStatus, repo["flaky"].Pending)
+	}
+
+	// not due yet
+	c.processRetries(ctx, now.Add(30*time.Second))
+	if flaky.count != 1 {
+		t.Errorf("Expected no retry before the delay, got %d attempts", flaky.count)
+	}
+
+	c.processRetries(ctx, now.Add(time.Minute))
+	c.processRetries(ctx, now.Add(time.Minute+90*time.Second))
+	if flaky.count != 3 || repo["flaky"].LastDeliveryStatus != domain.NotificationDeliveryStatusDelivered || repo["flaky"].Pending != 0 {
+		t.Errorf("Expected flaky to be delivered on the 3rd attempt, got %d/%s/%d",
+			flaky.count, repo["flaky"].LastDeliveryStatus, repo["flaky"].Pending)
+	}
+	if broken.count != 3 || repo["broken"].LastDeliveryStatus != domain.NotificationDeliveryStatusFailed || repo["broken"].LastError == "" {
+		t.Errorf("Expected broken to fail after 3 attempts, got %d/%s", broken.count, repo["broken"].LastDeliveryStatus)
+	}
+	if invalid.count != 1 || repo["invalid"].Pending != 0 {
+		t.Errorf("Expected the permanent error not to be retried, got %d attempts", invalid.count)
+	}
+}
+
+type recordingNotifier struct {
+	sent []application.NotifyOptions
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, opts application.NotifyOptions) error {
+	n.sent = append(n.sent, opts)
+	return nil
+}
+
+func TestComposerDigest(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	slack := &recordingNotifier{}
+	c, err := CreateComposer(ctx, log.NewSimpleLogger(false, "Test"), ComposerConfig{
+		Notifiers: map[string]application.Notifier{
+			"slack": slack,
+		},
+		TargetRepo: targetRepo{
+			"slack": {Name: "slack", DigestWindow: "15m"},
+		},
+		Now: func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("Could not CreateComposer:%v", err)
+	}
+
+	web := &domain.Source{ID: "web", Name: "web"}
+	api := &domain.Source{ID: "api", Name: "api"}
+	for _, opts := range []application.NotifyOptions{
+		{Source: web, Type: application.NotificationSuccess, Message: "Synced"},
+		{Source: web, Type: application.NotificationSuccess, Message: "Synced"},
+		{Source: api, Type: application.NotificationError, Message: "Plan failed"},
+		{Source: web, Type: application.NotificationInfo, Message: "Paused"},
+	} {
+		if err := c.Notify(ctx, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.flushDigests(ctx, now.Add(10*time.Minute), false)
+	if len(slack.sent) != 0 {
+		t.Fatalf("Expected the notifications to be collected, got %+v", slack.sent)
+	}
+
+	c.flushDigests(ctx, now.Add(15*time.Minute), false)
+	if len(slack.sent) != 1 {
+		t.Fatalf("Expected one digest, got %+v", slack.sent)
+	}
+	d := slack.sent[0]
+	if d.Type != application.NotificationError || d.Message != "4 notifications in the last 15m0s: 1 error, 2 success, 1 info" {
+		t.Errorf("Unexpected digest %s: %s", d.Type, d.Message)
+	}
+	headers := []string{}
+	for _, i := range d.Infos {
+		headers = append(headers, i.Header+": "+i.Text)
+	}
+	expected := []string{"api - error (1): Plan failed", "web - success (2): Synced (2x)", "web - info (1): Paused"}
+	if fmt.Sprint(headers) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, headers)
+	}
+	if d.Source == nil || d.Source.ID != "" || d.Source.Name != "Nomad Ops" {
+		t.Errorf("Expected the digest of several sources to have a summary source, got %+v", d.Source)
+	}
+
+	// sources of the same name are kept apart, a digest of a single source has that source
+	for _, opts := range []application.NotifyOptions{
+		{Source: web, Type: application.NotificationError, Message: "Plan failed"},
+		{Source: web, Type: application.NotificationError, Message: "Plan failed"},
+	} {
+		if err := c.Notify(ctx, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.flushDigests(ctx, now.Add(15*time.Minute), true)
+	if len(slack.sent) != 2 || slack.sent[1].Source != web {
+		t.Fatalf("Expected a digest of web, got %+v", slack.sent)
+	}
+	summary := summarizeDigest(&digest{window: time.Minute, entries: []application.NotifyOptions{
+		{Source: web, Type: application.NotificationError, Message: "Plan failed"},
+		{Source: &domain.Source{ID: "web-2", Name: "web"}, Type: application.NotificationError, Message: "Plan failed"},
+	}})
+	if len(summary.Infos) != 2 || summary.Source.Name != "Nomad Ops" {
+		t.Errorf("Expected two groups of the sources named web, got %+v", summary.Infos)
+	}
+}
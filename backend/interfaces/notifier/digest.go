@@ -0,0 +1,166 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// digestMaxMessages limits the distinct messages listed per source and severity
+const digestMaxMessages = 10
+
+var digestSeverities = []application.NotificationType{
+	application.NotificationError,
+	application.NotificationSuccess,
+	application.NotificationInfo,
+}
+
+func (s *Composer) addToDigest(target string, window time.Duration, opts application.NotifyOptions, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	d, ok := s.digests[target]
+	if !ok {
+		d = &digest{
+			start:  now,
+			window: window,
+		}
+		s.digests[target] = d
+	}
+	d.entries = append(d.entries, opts)
+}
+
+// flushDigests sends the digests whose window is over, or all if all is set
+func (s *Composer) flushDigests(ctx context.Context, now time.Time, all bool) {
+	s.lock.Lock()
+	due := map[string]*digest{}
+	for target, d := range s.digests {
+		if all || !now.Before(d.start.Add(d.window)) {
+			due[target] = d
+			delete(s.digests, target)
+		}
+	}
+	s.lock.Unlock()
+
+	for target, d := range due {
+		notifier, ok := s.cfg.Notifiers[target]
+		if !ok {
+			continue
+		}
+		s.logger.LogInfo(ctx, "Sending digest of %d notifications to %s", len(d.entries), target)
+		err := s.deliver(ctx, target, notifier, summarizeDigest(d), d.entries, s.now())
+		if err != nil {
+			s.logger.LogError(ctx, "Could not send digest to %s:%v", target, err)
+		}
+	}
+}
+
+// summarizeDigest groups the notifications by source and severity, a single notification is sent as is.
+// The digest has the source of its notifications if they all have the same one, otherwise a source named Nomad Ops.
+func summarizeDigest(d *digest) application.NotifyOptions {
+	if len(d.entries) == 1 {
+		return d.entries[0]
+	}
+
+	type group struct {
+		sourceID string
+		source   string
+		severity int
+		messages []string
+		counts   map[string]int
+	}
+	groups := map[string]*group{}
+	counts := map[application.NotificationType]int{}
+	sources := map[string]*domain.Source{}
+	for _, e := range d.entries {
+		counts[e.Type]++
+		sourceID, source := "", "Nomad Ops"
+		if e.Source != nil {
+			sourceID, source = e.Source.ID, e.Source.Name
+		}
+		sources[sourceID] = e.Source
+		severity := len(digestSeverities)
+		for i, t := range digestSeverities {
+			if t == e.Type {
+				severity = i
+			}
+		}
+		key := fmt.Sprintf("%s/%d", sourceID, severity)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{sourceID: sourceID, source: source, severity: severity, counts: map[string]int{}}
+			groups[key] = g
+		}
+		if g.counts[e.Message] == 0 {
+			g.messages = append(g.messages, e.Message)
+		}
+		g.counts[e.Message]++
+	}
+
+	sorted := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		if sorted[a].source != sorted[b].source {
+			return sorted[a].source < sorted[b].source
+		}
+		if sorted[a].sourceID != sorted[b].sourceID {
+			return sorted[a].sourceID < sorted[b].sourceID
+		}
+		return sorted[a].severity < sorted[b].severity
+	})
+
+	res := application.NotifyOptions{
+		Type:   application.NotificationInfo,
+		Source: &domain.Source{Name: "Nomad Ops"},
+	}
+	for _, src := range sources {
+		if len(sources) == 1 && src != nil {
+			res.Source = src
+		}
+	}
+	summary := []string{}
+	for _, t := range digestSeverities {
+		if counts[t] == 0 {
+			continue
+		}
+		// the digest has the type of the most severe notification
+		if len(summary) == 0 {
+			res.Type = t
+		}
+		summary = append(summary, fmt.Sprintf("%d %s", counts[t], t))
+	}
+	res.Message = fmt.Sprintf("%d notifications in the last %s: %s", len(d.entries), d.window, strings.Join(summary, ", "))
+
+	for _, g := range sorted {
+		total := 0
+		lines := []string{}
+		for i, m := range g.messages {
+			total += g.counts[m]
+			if i >= digestMaxMessages {
+				continue
+			}
+			if g.counts[m] > 1 {
+				m = fmt.Sprintf("%s (%dx)", m, g.counts[m])
+			}
+			lines = append(lines, m)
+		}
+		if len(g.messages) > digestMaxMessages {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(g.messages)-digestMaxMessages))
+		}
+		severity := "other"
+		if g.severity < len(digestSeverities) {
+			severity = string(digestSeverities[g.severity])
+		}
+		res.Infos = append(res.Infos, application.NotifyAdditionalInfos{
+			Header: fmt.Sprintf("%s - %s (%d)", g.source, severity, total),
+			Text:   strings.Join(lines, "\n"),
+		})
+	}
+	return res
+}
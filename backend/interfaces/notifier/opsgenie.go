@@ -0,0 +1,120 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type OpsgenieConfig struct {
+	// APIKey of an API integration of Opsgenie
+	APIKey string
+	// URL of the Opsgenie API, defaults to https://api.opsgenie.com, e.g. https://api.eu.opsgenie.com in the EU
+	URL string
+	// Priority of the created alerts, P1 to P5, defaults to P3
+	Priority string
+	Timeout  time.Duration
+	// Transport defaults to http.DefaultTransport
+	Transport *http.Transport
+}
+
+// Opsgenie creates and closes alerts through the Opsgenie Alert API, identified by the key of the incident as alias
+type Opsgenie struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    OpsgenieConfig
+	client *http.Client
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+func CreateOpsgenie(ctx context.Context,
+	logger log.Logger,
+	cfg OpsgenieConfig) (*Opsgenie, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("the API key of Opsgenie is empty")
+	}
+	if cfg.URL == "" {
+		cfg.URL = "https://api.opsgenie.com"
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	if cfg.Priority == "" {
+		cfg.Priority = "P3"
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport.(*http.Transport)
+	}
+
+	t := &Opsgenie{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: cfg.Transport,
+		},
+	}
+
+	return t, nil
+}
+
+func (o *Opsgenie) Trigger(ctx context.Context, inc *application.Incident) error {
+	return o.send(ctx, o.cfg.URL+"/v2/alerts", &opsgenieAlert{
+		// the message is limited to 130 characters, the full summary is the description
+		Message:     truncate(inc.Summary, 130),
+		Alias:       inc.Key(),
+		Description: inc.Summary,
+		Priority:    o.cfg.Priority,
+		Source:      "nomad-ops",
+		Tags:        []string{"nomad-ops", string(inc.Condition)},
+		Details:     inc.Details,
+	})
+}
+
+func (o *Opsgenie) Resolve(ctx context.Context, inc *application.Incident) error {
+	return o.send(ctx, fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", o.cfg.URL, url.PathEscape(inc.Key())),
+		map[string]string{
+			"source": "nomad-ops",
+			"note":   "The source recovered",
+		})
+}
+
+func (o *Opsgenie) send(ctx context.Context, u string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+o.cfg.APIKey)
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 || resp.StatusCode < 200 {
+		respB, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Opsgenie responded with %d: %s", resp.StatusCode, string(respB))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestOpsgenie(t *testing.T) {
+	ctx := context.Background()
+	requests := []string{}
+	var alert opsgenieAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.URL.Path == "/v2/alerts" {
+			if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	o, err := CreateOpsgenie(ctx, log.NewSimpleLogger(false, "Test"), OpsgenieConfig{
+		APIKey: "key",
+		URL:    srv.URL + "/",
+	})
+	if err != nil {
+		t.Fatalf("Could not CreateOpsgenie:%v", err)
+	}
+	inc := &application.Incident{
+		SourceID:   "src",
+		SourceName: "web-prod",
+		Condition:  domain.IncidentConditionDeploymentFailed,
+		Summary:    "Deployment of source web-prod failed: Deployment failed for job: web",
+	}
+	if err := o.Trigger(ctx, inc); err != nil {
+		t.Fatalf("Could not Trigger:%v", err)
+	}
+	if err := o.Resolve(ctx, inc); err != nil {
+		t.Fatalf("Could not Resolve:%v", err)
+	}
+
+	expected := []string{
+		"POST /v2/alerts",
+		"POST /v2/alerts/nomad-ops%2Fsrc%2FdeploymentFailed/close?identifierType=alias",
+	}
+	if len(requests) != 2 || requests[0] != expected[0] || requests[1] != expected[1] {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+	if alert.Alias != inc.Key() || alert.Priority != "P3" || alert.Message != inc.Summary {
+		t.Errorf("Unexpected alert %+v", alert)
+	}
+
+	o.cfg.APIKey = "invalid"
+	if err := o.Trigger(ctx, inc); err == nil {
+		t.Errorf("Expected an error for an invalid API key")
+	}
+}
@@ -0,0 +1,131 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PagerDutyConfig struct {
+	// RoutingKey is the integration key of the Events API v2 integration of the service
+	RoutingKey string
+	// URL of the Events API, defaults to https://events.pagerduty.com/v2/enqueue
+	URL string
+	// Severity of the triggered incidents, defaults to error
+	Severity string
+	Timeout  time.Duration
+	// Transport defaults to http.DefaultTransport
+	Transport *http.Transport
+}
+
+// PagerDuty opens and resolves incidents through the PagerDuty Events API v2
+type PagerDuty struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PagerDutyConfig
+	client *http.Client
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+func CreatePagerDuty(ctx context.Context,
+	logger log.Logger,
+	cfg PagerDutyConfig) (*PagerDuty, error) {
+	if cfg.RoutingKey == "" {
+		return nil, fmt.Errorf("the routing key of PagerDuty is empty")
+	}
+	if cfg.URL == "" {
+		cfg.URL = "https://events.pagerduty.com/v2/enqueue"
+	}
+	if cfg.Severity == "" {
+		cfg.Severity = "error"
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport.(*http.Transport)
+	}
+
+	t := &PagerDuty{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: cfg.Transport,
+		},
+	}
+
+	return t, nil
+}
+
+func (p *PagerDuty) Trigger(ctx context.Context, inc *application.Incident) error {
+	return p.send(ctx, &pagerDutyEvent{
+		RoutingKey:  p.cfg.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    inc.Key(),
+		Payload: &pagerDutyPayload{
+			Summary:       truncate(inc.Summary, 1024),
+			Source:        inc.SourceName,
+			Severity:      p.cfg.Severity,
+			Class:         string(inc.Condition),
+			CustomDetails: inc.Details,
+		},
+	})
+}
+
+func (p *PagerDuty) Resolve(ctx context.Context, inc *application.Incident) error {
+	return p.send(ctx, &pagerDutyEvent{
+		RoutingKey:  p.cfg.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    inc.Key(),
+	})
+}
+
+func (p *PagerDuty) send(ctx context.Context, ev *pagerDutyEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 || resp.StatusCode < 200 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PagerDuty responded with %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
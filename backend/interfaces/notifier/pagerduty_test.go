@@ -0,0 +1,67 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+func TestPagerDuty(t *testing.T) {
+	ctx := context.Background()
+	events := []*pagerDutyEvent{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev := &pagerDutyEvent{}
+		if err := json.NewDecoder(r.Body).Decode(ev); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events = append(events, ev)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	p, err := CreatePagerDuty(ctx, log.NewSimpleLogger(false, "Test"), PagerDutyConfig{
+		RoutingKey: "key",
+		URL:        srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("Could not CreatePagerDuty:%v", err)
+	}
+	inc := &application.Incident{
+		SourceID:   "src",
+		SourceName: "web-prod",
+		Condition:  domain.IncidentConditionSyncFailing,
+		Summary:    "Sync of source web-prod is failing: no such branch",
+	}
+	if err := p.Trigger(ctx, inc); err != nil {
+		t.Fatalf("Could not Trigger:%v", err)
+	}
+	if err := p.Resolve(ctx, inc); err != nil {
+		t.Fatalf("Could not Resolve:%v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].EventAction != "trigger" || events[0].RoutingKey != "key" || events[0].DedupKey != inc.Key() ||
+		events[0].Payload == nil || events[0].Payload.Source != "web-prod" || events[0].Payload.Severity != "error" {
+		t.Errorf("Unexpected trigger event %+v", events[0])
+	}
+	if events[1].EventAction != "resolve" || events[1].DedupKey != inc.Key() || events[1].Payload != nil {
+		t.Errorf("Unexpected resolve event %+v", events[1])
+	}
+
+	p.cfg.RoutingKey = "invalid"
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	if err := p.Trigger(ctx, inc); err == nil {
+		t.Errorf("Expected an error for a rejected event")
+	}
+}
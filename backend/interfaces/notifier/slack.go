@@ -9,6 +9,7 @@ import (
 	"net/http/httputil"
 
 	"github.com/nomad-ops/nomad-ops/backend/application"
+	utilerrors "github.com/nomad-ops/nomad-ops/backend/utils/errors"
 	"github.com/nomad-ops/nomad-ops/backend/utils/log"
 )
 
@@ -18,6 +19,8 @@ type SlackConfig struct {
 	IconSuccess string
 	IconError   string
 	EnvInfoText string
+	// Transport defaults to http.DefaultTransport
+	Transport *http.Transport
 }
 
 // Slack ...
@@ -25,6 +28,7 @@ type Slack struct {
 	ctx    context.Context
 	logger log.Logger
 	cfg    SlackConfig
+	client *http.Client
 }
 
 // CreateSlack ...
@@ -34,10 +38,16 @@ func CreateSlack(ctx context.Context,
 	if cfg.WebhookURL == "" {
 		logger.LogInfo(ctx, "Slack Webhook URL is empty. Will not notify")
 	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport.(*http.Transport)
+	}
 	t := &Slack{
 		ctx:    ctx,
 		logger: logger,
 		cfg:    cfg,
+		client: &http.Client{
+			Transport: cfg.Transport,
+		},
 	}
 
 	return t, nil
@@ -146,17 +156,17 @@ func (s *Slack) Notify(ctx context.Context, opts application.NotifyOptions) erro
 		return err
 	}
 
-	resp, err := http.DefaultClient.Post(s.cfg.WebhookURL, "application/json", bytes.NewBuffer(b))
+	resp, err := s.client.Post(s.cfg.WebhookURL, "application/json", bytes.NewBuffer(b))
 	if resp != nil && resp.Body != nil {
 		defer resp.Body.Close()
 	}
 	if err != nil {
-		return err
+		return utilerrors.CreateTemporaryError(err)
 	}
 	if resp.StatusCode != 200 {
 		respB, _ := httputil.DumpResponse(resp, true)
 		s.logger.LogError(ctx, "Could not send Webhook Message:%v - %v", string(b), string(respB))
-		return fmt.Errorf("could not send Webhook Message")
+		return utilerrors.CreateTemporaryError(fmt.Errorf("could not send Webhook Message"))
 	}
 
 	return nil
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/nomad-ops/nomad-ops/backend/application"
+	utilerrors "github.com/nomad-ops/nomad-ops/backend/utils/errors"
 	"github.com/nomad-ops/nomad-ops/backend/utils/log"
 )
 
@@ -28,6 +29,8 @@ type WebhookConfig struct {
 	AuthHeaderValue     string
 	FireOn              []string
 	LogTemplateResults  bool
+	// Transport defaults to http.DefaultTransport, TLS verification is disabled by Insecure
+	Transport *http.Transport
 }
 
 // Webhook ...
@@ -52,15 +55,23 @@ func CreateWebhook(ctx context.Context,
 		cfg.Method = "POST"
 	}
 
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport := cfg.Transport.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	// keeps the CA files and pins of the transport
+	transport.TLSClientConfig.InsecureSkipVerify = cfg.Insecure
+
 	t := &Webhook{
 		ctx:    ctx,
 		logger: logger,
 		cfg:    cfg,
 		client: &http.Client{
-			Timeout: cfg.Timeout,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: cfg.Insecure},
-			},
+			Timeout:   cfg.Timeout,
+			Transport: transport,
 		},
 	}
 
@@ -152,18 +163,19 @@ func (s *Webhook) Notify(ctx context.Context, opts application.NotifyOptions) er
 		req.Header.Set(s.cfg.AuthHeaderName, s.cfg.AuthHeaderValue)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	// only failed deliveries are retried, not the errors of the templates or the request above
+	resp, err := s.client.Do(req)
 	if resp != nil && resp.Body != nil {
 		defer resp.Body.Close()
 	}
 	if err != nil {
-		return err
+		return utilerrors.CreateTemporaryError(err)
 	}
 	if resp.StatusCode > 299 || resp.StatusCode < 200 {
 		reqB, _ := httputil.DumpRequestOut(req, true)
 		respB, _ := httputil.DumpResponse(resp, true)
 		s.logger.LogError(ctx, "Could not send Webhook Message:%v - %v", string(reqB), string(respB))
-		return fmt.Errorf("could not send Webhook Message")
+		return utilerrors.CreateTemporaryError(fmt.Errorf("could not send Webhook Message"))
 	}
 
 	return nil
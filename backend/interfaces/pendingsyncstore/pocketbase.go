@@ -0,0 +1,94 @@
+package pendingsyncstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PocketBaseStore struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PocketBaseStoreConfig
+}
+
+type PocketBaseStoreConfig struct {
+	App core.App
+}
+
+func CreatePocketBaseStore(ctx context.Context,
+	logger log.Logger,
+	cfg PocketBaseStoreConfig) (*PocketBaseStore, error) {
+	t := &PocketBaseStore{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+// SavePendingSync creates or replaces the pending sync of the source
+func (s *PocketBaseStore) SavePendingSync(ctx context.Context, p *domain.PendingSync) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="pending_syncs",op="SavePendingSync"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindFirstRecordByData("pending_syncs", "source", p.SourceID)
+	if err == sql.ErrNoRows {
+		coll, err := s.cfg.App.Dao().FindCollectionByNameOrId("pending_syncs")
+		if err != nil {
+			return err
+		}
+		record = models.NewRecord(coll)
+		record.Set("source", p.SourceID)
+	} else if err != nil {
+		return err
+	}
+	record.Set("forceRestart", p.ForceRestart)
+	record.Set("ignoreFreeze", p.IgnoreFreeze)
+	record.Set("actionId", p.ActionID)
+	record.Set("actionType", string(p.ActionType))
+	record.Set("userId", p.UserID)
+	record.Set("actor", p.Actor)
+	if !p.ActionTime.IsZero() {
+		record.Set("actionTime", p.ActionTime)
+	}
+	record.Set("requestedAt", p.RequestedAt)
+	record.Set("lastRequestedAt", p.LastRequestedAt)
+	return s.cfg.App.Dao().SaveRecord(record)
+}
+
+// DeletePendingSync deletes the pending sync of the source if it was last requested before the time,
+// later requests are still pending
+func (s *PocketBaseStore) DeletePendingSync(ctx context.Context, srcID string, before time.Time) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="pending_syncs",op="DeletePendingSync"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindFirstRecordByData("pending_syncs", "source", srcID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !record.GetDateTime("lastRequestedAt").Time().Before(before) {
+		return nil
+	}
+	return s.cfg.App.Dao().DeleteRecord(record)
+}
+
+func (s *PocketBaseStore) ListPendingSyncs(ctx context.Context) ([]*domain.PendingSync, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="pending_syncs",op="ListPendingSyncs"}`).UpdateDuration(time.Now())
+	records, err := s.cfg.App.Dao().FindRecordsByExpr("pending_syncs")
+	if err != nil {
+		return nil, err
+	}
+	res := []*domain.PendingSync{}
+	for _, r := range records {
+		res = append(res, domain.PendingSyncFromRecord(r))
+	}
+	return res, nil
+}
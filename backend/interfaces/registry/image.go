@@ -0,0 +1,43 @@
+package registry
+
+import "strings"
+
+// ImageRef is a pushed or deployed image, normalized like the Docker CLI does: docker.io/library/nginx:latest
+type ImageRef struct {
+	// Repository includes the registry, e.g. ghcr.io/org/app
+	Repository string
+	// Tag is empty for images referenced by digest only
+	Tag string
+}
+
+func (r ImageRef) String() string {
+	return r.Repository + ":" + r.Tag
+}
+
+// ParseImage normalizes an image reference, digests are ignored
+func ParseImage(image string) ImageRef {
+	image, _, digest := strings.Cut(strings.TrimSpace(image), "@")
+	ref := ImageRef{Repository: image}
+	// the last colon separates the tag unless it belongs to the port of the registry
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		ref.Repository = image[:i]
+		ref.Tag = image[i+1:]
+	} else if !digest {
+		ref.Tag = "latest"
+	}
+	first, rest, found := strings.Cut(ref.Repository, "/")
+	// the first part is a registry if it looks like a host
+	if !found || (!strings.ContainsAny(first, ".:") && first != "localhost") {
+		if !found {
+			ref.Repository = "library/" + ref.Repository
+		}
+		ref.Repository = "docker.io/" + ref.Repository
+	} else if first == "index.docker.io" || first == "registry-1.docker.io" {
+		ref.Repository = "docker.io/" + rest
+		if !strings.Contains(rest, "/") {
+			ref.Repository = "docker.io/library/" + rest
+		}
+	}
+	ref.Repository = strings.ToLower(ref.Repository)
+	return ref
+}
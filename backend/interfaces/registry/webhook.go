@@ -0,0 +1,221 @@
+package registry
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/nomadcluster"
+	utilerrors "github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+var (
+	ErrUnauthorized = errors.New("invalid webhook token or signature")
+)
+
+const (
+	ProviderDockerHub = "dockerhub"
+	ProviderHarbor    = "harbor"
+	ProviderGHCR      = "ghcr"
+)
+
+// JobSearcher finds the managed jobs using an image
+type JobSearcher interface {
+	SearchJobs(ctx context.Context, q nomadcluster.JobSearchQuery) []*nomadcluster.IndexedJob
+}
+
+type SourceSyncer interface {
+	SyncSourceByID(ctx context.Context, id string, opts application.SyncSourceOptions) error
+}
+
+type Config struct {
+	// Secret authenticates the webhooks, as token of Docker Hub and Harbor and as key of the signature of GHCR
+	Secret string
+	// Restart restarts the jobs of the synced sources, so mutable tags like latest are pulled again
+	Restart bool
+}
+
+// Registry receives push events of container registries and syncs the sources with jobs using the pushed image
+type Registry struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    Config
+	jobs   JobSearcher
+	syncer SourceSyncer
+}
+
+func CreateRegistry(ctx context.Context,
+	logger log.Logger,
+	cfg Config,
+	jobs JobSearcher,
+	syncer SourceSyncer) (*Registry, error) {
+
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("a webhook secret is required")
+	}
+	return &Registry{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+		jobs:   jobs,
+		syncer: syncer,
+	}, nil
+}
+
+type dockerHubPushEvent struct {
+	PushData struct {
+		Tag string `json:"tag"`
+	} `json:"push_data"`
+	Repository struct {
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+}
+
+type harborPushEvent struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			Tag         string `json:"tag"`
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+	} `json:"event_data"`
+}
+
+type githubPackage struct {
+	PackageType    string `json:"package_type"`
+	PackageVersion struct {
+		PackageURL string `json:"package_url"`
+	} `json:"package_version"`
+}
+
+// ghcrPackageEvent is the package or the registry_package event of GitHub
+type ghcrPackageEvent struct {
+	Action          string         `json:"action"`
+	Package         *githubPackage `json:"package"`
+	RegistryPackage *githubPackage `json:"registry_package"`
+}
+
+// HandleWebhook verifies a push event and syncs the sources with jobs using the pushed images,
+// it returns the ids of the synced sources
+func (r *Registry) HandleWebhook(ctx context.Context, provider, token, signature string, body []byte) ([]string, error) {
+	images, err := r.pushedImages(provider, token, signature, body)
+	if err != nil {
+		return nil, err
+	}
+	srcIDs := r.sourcesUsing(ctx, images)
+	for _, id := range srcIDs {
+		r.logger.LogInfo(ctx, "Image %v was pushed, syncing source %s...", images, id)
+		err := r.syncer.SyncSourceByID(ctx, id, application.SyncSourceOptions{
+			ForceRestart: r.cfg.Restart,
+			Trigger:      domain.SyncTriggerWebhook,
+		})
+		if err == utilerrors.ErrNotFound {
+			// not watched by a standby that was not promoted yet, the primary syncs it
+			continue
+		}
+		if err != nil {
+			r.logger.LogError(ctx, "Could not sync source %s:%v", id, err)
+		}
+	}
+	return srcIDs, nil
+}
+
+func (r *Registry) pushedImages(provider, token, signature string, body []byte) ([]ImageRef, error) {
+	images := []ImageRef{}
+	switch provider {
+	case ProviderDockerHub, ProviderHarbor:
+		if subtle.ConstantTimeCompare([]byte(token), []byte(r.cfg.Secret)) != 1 {
+			return nil, ErrUnauthorized
+		}
+	case ProviderGHCR:
+		if !validSignature(r.cfg.Secret, signature, body) {
+			return nil, ErrUnauthorized
+		}
+	default:
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, fmt.Errorf("unknown registry %q", provider))
+	}
+
+	switch provider {
+	case ProviderDockerHub:
+		ev := dockerHubPushEvent{}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		if ev.Repository.RepoName != "" && ev.PushData.Tag != "" {
+			images = append(images, ParseImage(ev.Repository.RepoName+":"+ev.PushData.Tag))
+		}
+	case ProviderHarbor:
+		ev := harborPushEvent{}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		if ev.Type != "PUSH_ARTIFACT" && ev.Type != "pushImage" {
+			return images, nil
+		}
+		for _, res := range ev.EventData.Resources {
+			if res.Tag != "" && res.ResourceURL != "" {
+				images = append(images, ParseImage(res.ResourceURL))
+			}
+		}
+	case ProviderGHCR:
+		ev := ghcrPackageEvent{}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+		}
+		pkg := ev.Package
+		if pkg == nil {
+			pkg = ev.RegistryPackage
+		}
+		if pkg == nil || ev.Action != "published" || !strings.EqualFold(pkg.PackageType, "container") ||
+			pkg.PackageVersion.PackageURL == "" {
+			return images, nil
+		}
+		images = append(images, ParseImage(pkg.PackageVersion.PackageURL))
+	}
+	return images, nil
+}
+
+// sourcesUsing returns the sources of the jobs using any of the images in the same tag
+func (r *Registry) sourcesUsing(ctx context.Context, images []ImageRef) []string {
+	ids := map[string]bool{}
+	for _, image := range images {
+		// the repository name narrows the search, the references are compared normalized
+		name := image.Repository[strings.LastIndex(image.Repository, "/")+1:]
+		for _, j := range r.jobs.SearchJobs(ctx, nomadcluster.JobSearchQuery{Image: name}) {
+			for _, used := range j.Images {
+				if ParseImage(used) == image {
+					ids[j.SourceID] = true
+				}
+			}
+		}
+	}
+	res := []string{}
+	for id := range ids {
+		res = append(res, id)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func validSignature(secret, signature string, body []byte) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/nomadcluster"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeJobs []*nomadcluster.IndexedJob
+
+func (f fakeJobs) SearchJobs(ctx context.Context, q nomadcluster.JobSearchQuery) []*nomadcluster.IndexedJob {
+	return f
+}
+
+type recordingSyncer struct {
+	synced []string
+}
+
+func (s *recordingSyncer) SyncSourceByID(ctx context.Context, id string, opts application.SyncSourceOptions) error {
+	s.synced = append(s.synced, fmt.Sprintf("%s:%v", id, opts.ForceRestart))
+	return nil
+}
+
+func TestParseImage(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                            "docker.io/library/nginx:latest",
+		"nginx:1.25":                       "docker.io/library/nginx:1.25",
+		"org/app:v1":                       "docker.io/org/app:v1",
+		"index.docker.io/org/app:v1":       "docker.io/org/app:v1",
+		"ghcr.io/Org/App:v1":               "ghcr.io/org/app:v1",
+		"localhost:5000/app":               "localhost:5000/app:latest",
+		"harbor.example.com/proj/app:v1":   "harbor.example.com/proj/app:v1",
+		"ghcr.io/org/app:v1@sha256:abcdef": "ghcr.io/org/app:v1",
+		"ghcr.io/org/app@sha256:abcdef":    "ghcr.io/org/app:",
+	} {
+		if ref := ParseImage(image); ref.String() != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, image, ref)
+		}
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	ctx := context.Background()
+	jobs := fakeJobs{
+		{SourceID: "web", Images: []string{"org/app:latest", "nginx:1.25"}},
+		{SourceID: "api", Images: []string{"ghcr.io/org/api:v2"}},
+		{SourceID: "worker", Images: []string{"harbor.example.com/proj/worker:v1"}},
+		{SourceID: "old", Images: []string{"org/app:v0"}},
+	}
+	syncer := &recordingSyncer{}
+	r, err := CreateRegistry(ctx, log.NewSimpleLogger(false, "Test"), Config{Secret: "secret"}, jobs, syncer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := r.HandleWebhook(ctx, ProviderDockerHub, "secret", "",
+		[]byte(`{"push_data":{"tag":"latest"},"repository":{"repo_name":"org/app"}}`))
+	if err != nil || fmt.Sprint(ids) != "[web]" {
+		t.Errorf("Expected web to be synced, got %v %v", ids, err)
+	}
+
+	ids, err = r.HandleWebhook(ctx, ProviderHarbor, "secret", "",
+		[]byte(`{"type":"PUSH_ARTIFACT","event_data":{"resources":[{"tag":"v1","resource_url":"harbor.example.com/proj/worker:v1"}]}}`))
+	if err != nil || fmt.Sprint(ids) != "[worker]" {
+		t.Errorf("Expected worker to be synced, got %v %v", ids, err)
+	}
+
+	body := []byte(`{"action":"published","package":{"package_type":"CONTAINER","package_version":{"package_url":"ghcr.io/org/api:v2"}}}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	ids, err = r.HandleWebhook(ctx, ProviderGHCR, "", "sha256="+hex.EncodeToString(mac.Sum(nil)), body)
+	if err != nil || fmt.Sprint(ids) != "[api]" {
+		t.Errorf("Expected api to be synced, got %v %v", ids, err)
+	}
+
+	if fmt.Sprint(syncer.synced) != "[web:false worker:false api:false]" {
+		t.Errorf("Unexpected syncs %v", syncer.synced)
+	}
+
+	_, err = r.HandleWebhook(ctx, ProviderGHCR, "", "sha256=00", body)
+	if err != ErrUnauthorized {
+		t.Errorf("Expected an invalid signature to be rejected, got %v", err)
+	}
+	_, err = r.HandleWebhook(ctx, ProviderDockerHub, "wrong", "", []byte(`{}`))
+	if err != ErrUnauthorized {
+		t.Errorf("Expected an invalid token to be rejected, got %v", err)
+	}
+}
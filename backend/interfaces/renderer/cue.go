@@ -0,0 +1,190 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+type CueConfig struct {
+	// Binary is the cue executable, defaults to cue
+	Binary string
+	// Timeout bounds the time of a single export or validation, 0 disables the limit
+	Timeout time.Duration
+	// Schemas is the directory of the .cue files jobs are validated against, empty disables the validation
+	Schemas string
+	// Definition is the definition of the schemas a job must satisfy, defaults to #Job
+	Definition string
+}
+
+// Cue exports the CUE package at the path of a source, or the .cue file it points to, with the cue CLI.
+// The variables of the source are unified with the package as the field variables, e.g. `variables: env: string`.
+// The package evaluates to a job, an array of jobs or an object of jobs, in the JSON format of the Nomad API.
+// Cue validates jobs against the schemas of the organization as well.
+type Cue struct {
+	cfg CueConfig
+}
+
+func CreateCue(cfg CueConfig) *Cue {
+	if cfg.Binary == "" {
+		cfg.Binary = "cue"
+	}
+	if cfg.Definition == "" {
+		cfg.Definition = "#Job"
+	}
+	return &Cue{
+		cfg: cfg,
+	}
+}
+
+func (c *Cue) Render(ctx context.Context, req application.RenderRequest) ([]application.RenderedJob, error) {
+	files, err := entrypoints(req.Dir, req.Path, ".cue")
+	if err != nil {
+		return nil, err
+	}
+	// the files of a directory form a package
+	entrypoint := files[0]
+	if filepath.Ext(filepath.Clean(req.Path)) != ".cue" {
+		entrypoint = "./" + filepath.Dir(entrypoint)
+	}
+
+	args := []string{"export", "--out", "json", entrypoint}
+	if len(req.Source.Variables) > 0 {
+		// command line arguments are visible to other users of the host, the file only to nomad-ops
+		varDir, err := os.MkdirTemp("", "nomad-ops-cue-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(varDir)
+		data, err := json.Marshal(req.Source.Variables)
+		if err != nil {
+			return nil, err
+		}
+		varFile := filepath.Join(varDir, "variables.json")
+		err = os.WriteFile(varFile, data, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		// the data file is unified with the package as the field variables
+		args = append(args, "--path", `"variables"`, varFile)
+	}
+	out, err := run(ctx, c.cfg.Timeout, req.Dir, nil, c.cfg.Binary, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Source.Variables) > 0 {
+		out = withoutVariables(out)
+	}
+	jobs, err := splitJSONJobs(out)
+	if err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeRenderFailed, fmt.Errorf("%s: %w", entrypoint, err))
+	}
+	res := []application.RenderedJob{}
+	for _, job := range jobs {
+		res = append(res, application.RenderedJob{File: entrypoint, Spec: string(job)})
+	}
+	return res, nil
+}
+
+// withoutVariables removes the variables of the source from the exported object, they are no job
+func withoutVariables(out []byte) []byte {
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(out, &obj); err != nil {
+		return out
+	}
+	if _, ok := obj["variables"]; !ok {
+		return out
+	}
+	delete(obj, "variables")
+	res, err := json.Marshal(obj)
+	if err != nil {
+		return out
+	}
+	return res
+}
+
+// ValidateJob vets the job against the definition of the schemas. The job is written as <name>.json, so the errors
+// point to the fields of the job and the constraints of the schemas that failed.
+func (c *Cue) ValidateJob(ctx context.Context, src *domain.Source, job *application.JobInfo) error {
+	if c.cfg.Schemas == "" {
+		return nil
+	}
+	// the validation runs in a temporary directory
+	schemaDir, err := filepath.Abs(c.cfg.Schemas)
+	if err != nil {
+		return err
+	}
+	schemas, err := filepath.Glob(filepath.Join(schemaDir, "*.cue"))
+	if err != nil {
+		return err
+	}
+	if len(schemas) == 0 {
+		return fmt.Errorf("no .cue files found in %s", c.cfg.Schemas)
+	}
+	if job.Name == nil || *job.Name == "" {
+		return domain.WithErrorCode(domain.ErrorCodeSchemaViolation, fmt.Errorf("a job has no name"))
+	}
+
+	cpy := *job.Job
+	cpy.VaultToken = nil
+	data, err := json.Marshal(&cpy)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	err = json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	// unset fields would conflict with the structs of the schemas
+	data, err = json.MarshalIndent(withoutNulls(v), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "nomad-ops-vet-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	name := strings.ReplaceAll(*job.Name, "/", "_") + ".json"
+	err = os.WriteFile(filepath.Join(dir, name), data, 0o600)
+	if err != nil {
+		return err
+	}
+
+	args := append([]string{"vet", "-c", "-d", c.cfg.Definition}, schemas...)
+	args = append(args, name)
+	_, err = execute(ctx, c.cfg.Timeout, dir, nil, c.cfg.Binary, args...)
+	if err != nil {
+		return domain.WithErrorCode(domain.ErrorCodeSchemaViolation,
+			fmt.Errorf("job %s does not match the schemas: %w", *job.Name, err))
+	}
+	return nil
+}
+
+// withoutNulls removes the null fields of decoded JSON objects
+func withoutNulls(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if e == nil {
+				delete(t, k)
+				continue
+			}
+			t[k] = withoutNulls(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = withoutNulls(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,79 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestCue(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"jobs/web.cue":    "",
+		"jobs/api.cue":    "",
+		"schemas/job.cue": "",
+		"schemas/README":  "",
+		// echoes its arguments as job id or the variables as meta, vet fails for jobs without meta
+		"bin/cue": "#!/bin/sh\nif [ \"$1\" = vet ]; then\n  grep -q Meta \"$(pwd)/$6\" && exit 0\n  echo \"#Job.Meta: field is required but not present:\n    $5:3:2\" >&2\n  exit 1\nfi\nif [ \"$5\" = --path ] && [ \"$6\" = '\"variables\"' ]; then\n  printf '{\"variables\": %s, \"web\": {\"ID\": \"%s\", \"Meta\": %s}}' \"$(cat \"$7\")\" \"$4\" \"$(cat \"$7\")\"\n  exit 0\nfi\nprintf '[{\"ID\": \"%s\"}]' \"$*\"\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := CreateCue(CueConfig{Binary: filepath.Join(dir, "bin/cue"), Schemas: filepath.Join(dir, "schemas")})
+	jobs, err := c.Render(context.Background(), application.RenderRequest{
+		Dir:    dir,
+		Path:   "/jobs",
+		Source: &domain.Source{Variables: map[string]string{"team": "web", "env": "prod"}},
+	})
+	if err != nil {
+		t.Fatalf("Could not Render:%v", err)
+	}
+	if fmt.Sprint(jobs) != `[{./jobs {"ID":"./jobs","Meta":{"env":"prod","team":"web"}}}]` {
+		t.Errorf("Unexpected jobs %v", jobs)
+	}
+
+	jobs, err = c.Render(context.Background(), application.RenderRequest{Dir: dir, Path: "jobs/web.cue", Source: &domain.Source{}})
+	if err != nil {
+		t.Fatalf("Could not Render:%v", err)
+	}
+	if fmt.Sprint(jobs) != `[{jobs/web.cue {"ID": "export --out json jobs/web.cue"}}]` {
+		t.Errorf("Unexpected jobs %v", jobs)
+	}
+
+	err = c.ValidateJob(context.Background(), &domain.Source{}, &application.JobInfo{
+		Job: &api.Job{Name: strPtr("web"), Meta: map[string]string{"team": "web"}},
+	})
+	if err != nil {
+		t.Errorf("Expected a valid job, got %v", err)
+	}
+	err = c.ValidateJob(context.Background(), &domain.Source{}, &application.JobInfo{
+		Job: &api.Job{Name: strPtr("api")},
+	})
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeSchemaViolation ||
+		!strings.Contains(err.Error(), "job api does not match the schemas") ||
+		!strings.Contains(err.Error(), filepath.Join(dir, "schemas/job.cue")+":3:2") {
+		t.Errorf("Expected a schema violation, got %v", err)
+	}
+
+	err = c.ValidateJob(context.Background(), &domain.Source{}, &application.JobInfo{Job: &api.Job{}})
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeSchemaViolation {
+		t.Errorf("Expected a schema violation of a job without name, got %v", err)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,156 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+type JsonnetConfig struct {
+	// Binary is the jsonnet executable, defaults to jsonnet
+	Binary string
+	// Timeout bounds the time of evaluating a single entrypoint, 0 disables the limit
+	Timeout time.Duration
+}
+
+// Jsonnet evaluates the .jsonnet entrypoints at the path of a source with the jsonnet CLI. The variables of the source
+// are passed as ext vars through files, imports are resolved relative to the file and to the root of the repository.
+// An entrypoint evaluates to a job, an array of jobs or an object of jobs, in the JSON format of the Nomad API.
+type Jsonnet struct {
+	cfg JsonnetConfig
+}
+
+func CreateJsonnet(cfg JsonnetConfig) *Jsonnet {
+	if cfg.Binary == "" {
+		cfg.Binary = "jsonnet"
+	}
+	return &Jsonnet{
+		cfg: cfg,
+	}
+}
+
+func (j *Jsonnet) Render(ctx context.Context, req application.RenderRequest) ([]application.RenderedJob, error) {
+	files, err := entrypoints(req.Dir, req.Path, ".jsonnet")
+	if err != nil {
+		return nil, err
+	}
+
+	varDir, err := os.MkdirTemp("", "nomad-ops-jsonnet-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(varDir)
+	varArgs, err := extStrFiles(varDir, req.Source.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []application.RenderedJob{}
+	for _, f := range files {
+		args := append([]string{"-J", "."}, varArgs...)
+		args = append(args, f)
+		out, err := run(ctx, j.cfg.Timeout, req.Dir, nil, j.cfg.Binary, args...)
+		if err != nil {
+			return nil, err
+		}
+		jobs, err := splitJSONJobs(out)
+		if err != nil {
+			return nil, domain.WithErrorCode(domain.ErrorCodeRenderFailed, fmt.Errorf("%s: %w", f, err))
+		}
+		for _, job := range jobs {
+			res = append(res, application.RenderedJob{File: f, Spec: string(job)})
+		}
+	}
+	return res, nil
+}
+
+// extStrFiles writes every variable to a file of dir and returns the arguments passing them as ext vars.
+// Command line arguments are visible to other users of the host, the files only to nomad-ops.
+func extStrFiles(dir string, variables map[string]string) ([]string, error) {
+	var keys []string
+	for k := range variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for i, k := range keys {
+		f := filepath.Join(dir, strconv.Itoa(i))
+		err := os.WriteFile(f, []byte(variables[k]), 0o600)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--ext-str-file", k+"="+f)
+	}
+	return args, nil
+}
+
+// entrypoints returns the files with the extension at the path, relative to dir. The path is either such a file or
+// a directory whose files are used, subdirectories are not searched.
+func entrypoints(dir, path, ext string) ([]string, error) {
+	path = strings.TrimPrefix(filepath.Clean("/"+path), "/")
+	if path == "" {
+		path = "."
+	}
+	info, err := os.Stat(filepath.Join(dir, path))
+	if err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeRenderFailed, err)
+	}
+	if !info.IsDir() {
+		if filepath.Ext(path) != ext {
+			return nil, domain.WithErrorCode(domain.ErrorCodeRenderFailed, fmt.Errorf("%s is no %s file", path, ext))
+		}
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, path))
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ext {
+			res = append(res, filepath.Join(path, e.Name()))
+		}
+	}
+	if len(res) == 0 {
+		return nil, domain.WithErrorCode(domain.ErrorCodeRenderFailed, fmt.Errorf("no %s files found in %s", ext, path))
+	}
+	return res, nil
+}
+
+// splitJSONJobs splits a job, an array of jobs or an object of jobs by name into single jobs
+func splitJSONJobs(out []byte) ([]json.RawMessage, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(out, &list); err == nil {
+		return list, nil
+	}
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(out, &obj); err != nil {
+		return nil, fmt.Errorf("expected a job, an array or an object of jobs: %w", err)
+	}
+	if _, ok := obj["ID"]; ok {
+		return []json.RawMessage{out}, nil
+	}
+	if _, ok := obj["Job"]; ok {
+		return []json.RawMessage{out}, nil
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]json.RawMessage, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, obj[k])
+	}
+	return res, nil
+}
@@ -0,0 +1,48 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestJsonnet(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"jobs/web.jsonnet":   "",
+		"jobs/lib.libsonnet": "",
+		"jobs/README.md":     "",
+		// echoes the ext vars read from their files and the entrypoint as job id
+		"bin/jsonnet": "#!/bin/sh\nvars=\nwhile [ $# -gt 1 ]; do\n  if [ \"$1\" = --ext-str-file ]; then\n    vars=\"$vars${2%%=*}=$(cat \"${2#*=}\") \"\n    shift\n  fi\n  shift\ndone\nprintf '{\"web\": {\"ID\": \"%s%s\"}, \"api\": {\"ID\": \"api\"}}' \"$vars\" \"$1\"\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	j := CreateJsonnet(JsonnetConfig{Binary: filepath.Join(dir, "bin/jsonnet")})
+	jobs, err := j.Render(context.Background(), application.RenderRequest{
+		Dir:    dir,
+		Path:   "/jobs",
+		Source: &domain.Source{Variables: map[string]string{"team": "web", "env": "prod"}},
+	})
+	if err != nil {
+		t.Fatalf("Could not Render:%v", err)
+	}
+	if fmt.Sprint(jobs) != `[{jobs/web.jsonnet {"ID": "api"}} {jobs/web.jsonnet {"ID": "env=prod team=web jobs/web.jsonnet"}}]` {
+		t.Errorf("Unexpected jobs %v", jobs)
+	}
+
+	_, err = j.Render(context.Background(), application.RenderRequest{Dir: dir, Path: "jobs/README.md", Source: &domain.Source{}})
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeRenderFailed {
+		t.Errorf("Expected no entrypoint, got %v", err)
+	}
+}
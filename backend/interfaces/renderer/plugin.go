@@ -0,0 +1,113 @@
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+// maxStderr limits the output of a failed plugin included in the error
+const maxStderr = 4096
+
+type PluginConfig struct {
+	// Command is the executable and its arguments
+	Command []string
+	// Timeout bounds the time of a single rendering, 0 disables the limit
+	Timeout time.Duration
+}
+
+// Plugin runs an external renderer as subprocess. The RenderRequest is written to its stdin as JSON, it is started
+// in the checkout and writes a PluginResponse as JSON to stdout. A non-zero exit code fails the rendering.
+// Plugins only get PATH from the environment of nomad-ops, so they can not read its secrets.
+type Plugin struct {
+	cfg PluginConfig
+}
+
+// PluginResponse is the output of a plugin
+type PluginResponse struct {
+	Jobs []application.RenderedJob `json:"jobs"`
+	// Error fails the rendering with the message
+	Error string `json:"error,omitempty"`
+}
+
+func CreatePlugin(cfg PluginConfig) (*Plugin, error) {
+	if len(cfg.Command) == 0 || cfg.Command[0] == "" {
+		return nil, fmt.Errorf("the command of a renderer plugin must not be empty")
+	}
+	return &Plugin{
+		cfg: cfg,
+	}, nil
+}
+
+func (p *Plugin) Render(ctx context.Context, req application.RenderRequest) ([]application.RenderedJob, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	out, err := run(ctx, p.cfg.Timeout, req.Dir, in, p.cfg.Command[0], p.cfg.Command[1:]...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := PluginResponse{}
+	err = json.Unmarshal(out, &resp)
+	if err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeRenderFailed, fmt.Errorf("renderer %s returned invalid output: %w", p.cfg.Command[0], err))
+	}
+	if resp.Error != "" {
+		return nil, domain.WithErrorCode(domain.ErrorCodeRenderFailed, fmt.Errorf("renderer %s failed: %s", p.cfg.Command[0], resp.Error))
+	}
+	return resp.Jobs, nil
+}
+
+// run executes the command like execute, failing the rendering on errors
+func run(ctx context.Context, timeout time.Duration, dir string, stdin []byte, name string, args ...string) ([]byte, error) {
+	out, err := execute(ctx, timeout, dir, stdin, name, args...)
+	if err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeRenderFailed, fmt.Errorf("renderer %s failed: %w", name, err))
+	}
+	return out, nil
+}
+
+// execute runs the command in dir with only PATH in its environment and returns its stdout,
+// the error contains its stderr
+func execute(ctx context.Context, timeout time.Duration, dir string, stdin []byte, name string, args ...string) ([]byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// children of a killed plugin may keep the output open
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("did not finish within %v", timeout)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > maxStderr {
+			msg = msg[:maxStderr] + "..."
+		}
+		if msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,45 @@
+package renderer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/application"
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+)
+
+func TestPlugin(t *testing.T) {
+	t.Setenv("NOMAD_TOKEN", "secret")
+	dir := t.TempDir()
+	render := func(script string) ([]application.RenderedJob, error) {
+		p, err := CreatePlugin(PluginConfig{Command: []string{"sh", "-c", script}, Timeout: time.Second})
+		if err != nil {
+			t.Fatalf("Could not CreatePlugin:%v", err)
+		}
+		return p.Render(context.Background(), application.RenderRequest{Dir: dir, Path: "jobs", Source: &domain.Source{ID: "src"}})
+	}
+
+	// the request is passed on stdin, the plugin runs in the checkout without the environment of nomad-ops
+	jobs, err := render(`req=$(cat); printf '{"jobs":[{"file":"%s","spec":"%s"}]}' "$(pwd)" "$(echo "$req" | grep -c '"path":"jobs"')$NOMAD_TOKEN"`)
+	if err != nil {
+		t.Fatalf("Could not Render:%v", err)
+	}
+	if len(jobs) != 1 || !strings.HasSuffix(jobs[0].File, dir[strings.LastIndex(dir, "/"):]) || jobs[0].Spec != "1" {
+		t.Errorf("Unexpected jobs %+v", jobs)
+	}
+
+	_, err = render(`echo "jobs.cue:3:5: field not allowed" >&2; exit 1`)
+	if domain.ErrorCodeOf(err, "") != domain.ErrorCodeRenderFailed || !strings.Contains(err.Error(), "jobs.cue:3:5: field not allowed") {
+		t.Errorf("Expected the output of the failed plugin, got %v", err)
+	}
+	_, err = render(`echo '{"error":"no entrypoint"}'`)
+	if err == nil || !strings.Contains(err.Error(), "no entrypoint") {
+		t.Errorf("Expected the error of the plugin, got %v", err)
+	}
+	_, err = render(`sleep 5`)
+	if err == nil || !strings.Contains(err.Error(), "did not finish within 1s") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
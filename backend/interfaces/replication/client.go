@@ -0,0 +1,93 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// Client fetches the changes of the store of the primary for a standby
+type Client struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    ClientConfig
+	client *http.Client
+}
+
+type ClientConfig struct {
+	// PrimaryURL is the address of the primary, e.g. https://nomad-ops.example.com
+	PrimaryURL string
+	// Token is the replication token of the primary
+	Token string
+	// Timeout bounds a single request for a page of changes
+	Timeout time.Duration
+}
+
+func CreateClient(ctx context.Context,
+	logger log.Logger,
+	cfg ClientConfig) (*Client, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("a standby requires the replication token of the primary")
+	}
+	u, err := url.Parse(cfg.PrimaryURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid address of the primary %s", cfg.PrimaryURL)
+	}
+	t := &Client{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+
+	return t, nil
+}
+
+// FetchChanges returns a page of the changes of the primary since the cursor, a zero cursor fetches all rows.
+// A nil page fetches the first page.
+func (c *Client) FetchChanges(ctx context.Context, since time.Time, page *domain.ReplicationPage) (*domain.ReplicationBatch, error) {
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339Nano))
+	}
+	if page != nil {
+		query.Set("table", page.Table)
+		if page.After != "" {
+			query.Set("after", page.After)
+		}
+	}
+	u := strings.TrimSuffix(c.cfg.PrimaryURL, "/") + "/api/replication/changes"
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("primary responded with %d: %s", resp.StatusCode, string(body))
+	}
+	batch := &domain.ReplicationBatch{}
+	err = json.NewDecoder(resp.Body).Decode(batch)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the changes of the primary:%v", err)
+	}
+	return batch, nil
+}
@@ -0,0 +1,316 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// overlap is subtracted from the cursor, rows of transactions that committed late are sent again
+const overlap = 5 * time.Second
+
+// defaultPageSize is the maximum number of rows of a page of changes
+const defaultPageSize = 1000
+
+// adminsTable holds the admins, which are no records but replicated as well
+const adminsTable = "_admins"
+
+// PocketBaseStore reads the changes of the store on the primary and applies them on a standby.
+// Rows are copied as stored, bypassing the hooks of the records.
+type PocketBaseStore struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PocketBaseStoreConfig
+
+	lock     sync.Mutex
+	prunedAt time.Time
+}
+
+type PocketBaseStoreConfig struct {
+	App core.App
+	// TombstoneTTL is the time deleted records are remembered, standbys that fell behind further replicate
+	// all rows again
+	TombstoneTTL time.Duration
+	// PageSize is the maximum number of rows returned by Changes, 1000 if not set
+	PageSize int
+}
+
+func CreatePocketBaseStore(ctx context.Context,
+	logger log.Logger,
+	cfg PocketBaseStoreConfig) (*PocketBaseStore, error) {
+	t := &PocketBaseStore{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+// RecordDeletion remembers a deleted record or admin for the standbys, it is called after every deleted model
+func (s *PocketBaseStore) RecordDeletion(ctx context.Context, m models.Model) error {
+	table := m.TableName()
+	if table == domain.ReplicationTombstonesCollection {
+		return nil
+	}
+	if _, ok := m.(*models.Record); !ok && table != adminsTable {
+		return nil
+	}
+	coll, err := s.cfg.App.Dao().FindCollectionByNameOrId(domain.ReplicationTombstonesCollection)
+	if err != nil {
+		return err
+	}
+	record := models.NewRecord(coll)
+	record.Set("tableName", table)
+	record.Set("recordId", m.GetId())
+	err = s.cfg.App.Dao().SaveRecord(record)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	prune := time.Since(s.prunedAt) > time.Hour
+	if prune {
+		s.prunedAt = time.Now()
+	}
+	s.lock.Unlock()
+	if prune {
+		return s.pruneTombstones(time.Now())
+	}
+	return nil
+}
+
+func (s *PocketBaseStore) pruneTombstones(now time.Time) error {
+	_, err := s.cfg.App.Dao().DB().Delete(domain.ReplicationTombstonesCollection, dbx.NewExp("created < {:before}", dbx.Params{
+		"before": now.Add(-s.cfg.TombstoneTTL).UTC().Format(types.DefaultDateLayout),
+	})).Execute()
+	return err
+}
+
+// tables returns the replicated tables: the records of all collections and the admins
+func (s *PocketBaseStore) tables(dao *daos.Dao) ([]string, error) {
+	res := []string{adminsTable}
+	for _, t := range []string{models.CollectionTypeBase, models.CollectionTypeAuth} {
+		colls, err := dao.FindCollectionsByType(t)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range colls {
+			if c.Name == domain.ReplicationTombstonesCollection {
+				continue
+			}
+			res = append(res, c.Name)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// Changes returns a page of the rows updated and deleted since the cursor of the standby, starting at the page
+// or the first table if it is nil. All rows are returned for a zero cursor or a cursor older than the tombstones.
+func (s *PocketBaseStore) Changes(ctx context.Context, since time.Time, page *domain.ReplicationPage) (*domain.ReplicationBatch, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="replication",op="Changes"}`).UpdateDuration(time.Now())
+	now := time.Now().UTC()
+	batch := &domain.ReplicationBatch{
+		Cursor: now.Add(-overlap),
+		Full:   since.IsZero() || now.Sub(since) > s.cfg.TombstoneTTL,
+		Tables: map[string]*domain.ReplicatedTable{},
+	}
+	pageSize := s.cfg.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	dao := s.cfg.App.Dao()
+	tables, err := s.tables(dao)
+	if err != nil {
+		return nil, err
+	}
+	sinceParam := dbx.Params{"since": since.UTC().Format(types.DefaultDateLayout)}
+	count := 0
+	for _, table := range tables {
+		after := ""
+		if page != nil {
+			if table < page.Table {
+				continue
+			}
+			if table == page.Table {
+				after = page.After
+			}
+		}
+		if count >= pageSize {
+			batch.Next = &domain.ReplicationPage{Table: table}
+			break
+		}
+		// one more row than fits tells whether the table continues in the next page
+		q := dao.DB().Select("*").
+			From(table).
+			Where(dbx.NewExp("id > {:after}", dbx.Params{"after": after})).
+			OrderBy("id").
+			Limit(int64(pageSize - count + 1))
+		if !batch.Full {
+			q = q.AndWhere(dbx.NewExp("updated >= {:since}", sinceParam))
+		}
+		rows := []dbx.NullStringMap{}
+		err := q.WithContext(ctx).All(&rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s:%v", table, err)
+		}
+		t := &domain.ReplicatedTable{
+			After: after,
+		}
+		if len(rows) > pageSize-count {
+			rows = rows[:pageSize-count]
+			t.Until = rows[len(rows)-1]["id"].String
+			batch.Next = &domain.ReplicationPage{Table: table, After: t.Until}
+		}
+		for _, row := range rows {
+			r := make(map[string]*string, len(row))
+			for k, v := range row {
+				if v.Valid {
+					str := v.String
+					r[k] = &str
+				} else {
+					r[k] = nil
+				}
+			}
+			t.Rows = append(t.Rows, r)
+		}
+		count += len(rows)
+		batch.Tables[table] = t
+		if batch.Next != nil {
+			break
+		}
+	}
+	if batch.Full {
+		return batch, nil
+	}
+
+	tombstones := []dbx.NullStringMap{}
+	err = dao.DB().Select("tableName", "recordId").
+		From(domain.ReplicationTombstonesCollection).
+		Where(dbx.NewExp("created >= {:since}", sinceParam)).
+		WithContext(ctx).
+		All(&tombstones)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the tombstones:%v", err)
+	}
+	for _, ts := range tombstones {
+		// the deleted rows are sent with the first page of their table
+		t, ok := batch.Tables[ts["tableName"].String]
+		if !ok || t.After != "" {
+			continue
+		}
+		t.Deleted = append(t.Deleted, ts["recordId"].String)
+	}
+	return batch, nil
+}
+
+// ApplyChanges writes the changes of the primary in a single transaction. Tables and columns
+// missing in this store, e.g. of another version of nomad-ops, are skipped.
+func (s *PocketBaseStore) ApplyChanges(ctx context.Context, batch *domain.ReplicationBatch) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="replication",op="ApplyChanges"}`).UpdateDuration(time.Now())
+	return s.cfg.App.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		names := make([]string, 0, len(batch.Tables))
+		for name := range batch.Tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if name == domain.ReplicationTombstonesCollection || !txDao.HasTable(name) {
+				s.logger.LogTrace(ctx, "Skipping unknown table %s", name)
+				continue
+			}
+			err := s.applyTable(ctx, txDao, name, batch.Tables[name], batch.Full)
+			if err != nil {
+				return fmt.Errorf("could not replicate %s:%v", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *PocketBaseStore) applyTable(ctx context.Context, txDao *daos.Dao, name string, t *domain.ReplicatedTable, full bool) error {
+	columns, err := txDao.TableColumns(name)
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, c := range columns {
+		known[c] = true
+	}
+
+	ids := map[string]bool{}
+	for _, row := range t.Rows {
+		cols := []string{}
+		params := dbx.Params{}
+		for c, v := range row {
+			if !known[c] {
+				continue
+			}
+			cols = append(cols, c)
+			if v == nil {
+				params[fmt.Sprintf("p%d", len(cols))] = nil
+			} else {
+				params[fmt.Sprintf("p%d", len(cols))] = *v
+			}
+		}
+		if id := row["id"]; id != nil {
+			ids[*id] = true
+		}
+		quoted := make([]string, len(cols))
+		placeholders := make([]string, len(cols))
+		for i, c := range cols {
+			quoted[i] = "[[" + c + "]]"
+			placeholders[i] = fmt.Sprintf("{:p%d}", i+1)
+		}
+		_, err := txDao.DB().NewQuery(fmt.Sprintf("INSERT OR REPLACE INTO {{%s}} (%s) VALUES (%s)",
+			name, strings.Join(quoted, ", "), strings.Join(placeholders, ", "))).
+			Bind(params).
+			WithContext(ctx).
+			Execute()
+		if err != nil {
+			return err
+		}
+	}
+
+	deleted := append([]string{}, t.Deleted...)
+	if full {
+		// rows missing in a full batch within the ids of the page got deleted on the primary
+		q := txDao.DB().Select("id").From(name).Where(dbx.NewExp("id > {:after}", dbx.Params{"after": t.After}))
+		if t.Until != "" {
+			q = q.AndWhere(dbx.NewExp("id <= {:until}", dbx.Params{"until": t.Until}))
+		}
+		local := []string{}
+		err := q.WithContext(ctx).Column(&local)
+		if err != nil {
+			return err
+		}
+		for _, id := range local {
+			if !ids[id] {
+				deleted = append(deleted, id)
+			}
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	values := make([]interface{}, len(deleted))
+	for i, id := range deleted {
+		values[i] = id
+	}
+	_, err = txDao.DB().Delete(name, dbx.In("id", values...)).WithContext(ctx).Execute()
+	return err
+}
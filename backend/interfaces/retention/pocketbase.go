@@ -0,0 +1,152 @@
+package retention
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// batchSize is the number of records archived and deleted at once
+const batchSize = 500
+
+// Policy limits the records of a collection by age and count, zero values disable a limit
+type Policy struct {
+	Collection string
+	MaxAge     time.Duration
+	MaxCount   int
+}
+
+type PrunerConfig struct {
+	App      core.App
+	Interval time.Duration
+	Policies []Policy
+	// Archive receives the pruned records as NDJSON before they are deleted, optional
+	Archive *filesystem.System
+}
+
+// Pruner deletes records that exceed the retention policies
+type Pruner struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PrunerConfig
+}
+
+func CreatePruner(ctx context.Context,
+	logger log.Logger,
+	cfg PrunerConfig) (*Pruner, error) {
+	t := &Pruner{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+// Run prunes all collections every interval until the context is done
+func (p *Pruner) Run(ctx context.Context) {
+	t := time.NewTicker(p.cfg.Interval)
+	defer t.Stop()
+	for {
+		for _, policy := range p.cfg.Policies {
+			n, err := p.Prune(ctx, policy, time.Now())
+			if err != nil {
+				p.logger.LogError(ctx, "Could not prune %s:%v", policy.Collection, err)
+			}
+			if n > 0 {
+				p.logger.LogInfo(ctx, "Pruned %d records of %s", n, policy.Collection)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Prune deletes the records of the collection that are older than MaxAge or exceed MaxCount, oldest first
+func (p *Pruner) Prune(ctx context.Context, policy Policy, now time.Time) (int, error) {
+	total := 0
+	if policy.MaxAge > 0 {
+		before, err := types.ParseDateTime(now.Add(-policy.MaxAge))
+		if err != nil {
+			return 0, err
+		}
+		n, err := p.pruneBatches(ctx, policy.Collection, func() ([]*models.Record, error) {
+			return p.cfg.App.Dao().FindRecordsByFilter(policy.Collection,
+				"created < {:before}", "created", batchSize, 0, dbx.Params{"before": before.String()})
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	if policy.MaxCount > 0 {
+		n, err := p.pruneBatches(ctx, policy.Collection, func() ([]*models.Record, error) {
+			return p.cfg.App.Dao().FindRecordsByFilter(policy.Collection,
+				"id != ''", "-created", batchSize, policy.MaxCount)
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// pruneBatches archives and deletes the records returned by find until there are none left
+func (p *Pruner) pruneBatches(ctx context.Context, collection string, find func() ([]*models.Record, error)) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		records, err := find()
+		if err != nil {
+			return total, err
+		}
+		if len(records) == 0 {
+			return total, nil
+		}
+		if p.cfg.Archive != nil {
+			if err := archive(p.cfg.Archive, collection, records, time.Now()); err != nil {
+				return total, fmt.Errorf("could not archive:%w", err)
+			}
+		}
+		err = p.cfg.App.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			for _, r := range records {
+				if err := txDao.DeleteRecord(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += len(records)
+	}
+	return total, ctx.Err()
+}
+
+// archive uploads the records as one NDJSON file per batch: <collection>/<time>-<id of the first record>.ndjson
+func archive(fs *filesystem.System, collection string, records []*models.Record, now time.Time) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	key := fmt.Sprintf("%s/%s-%s.ndjson", collection, now.UTC().Format("20060102T150405Z"), records[0].Id)
+	return fs.Upload(buf.Bytes(), key)
+}
@@ -0,0 +1,50 @@
+package retention
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+func TestArchive(t *testing.T) {
+	fs, err := filesystem.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("Could not NewLocal:%v", err)
+	}
+	defer fs.Close()
+
+	collection := &models.Collection{
+		Name:   "events",
+		Schema: schema.NewSchema(&schema.SchemaField{Name: "message", Type: schema.FieldTypeText}),
+	}
+	var records []*models.Record
+	for _, msg := range []string{"first", "second"} {
+		r := models.NewRecord(collection)
+		r.RefreshId()
+		r.Set("message", msg)
+		records = append(records, r)
+	}
+
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := archive(fs, "events", records, now); err != nil {
+		t.Fatalf("Could not archive:%v", err)
+	}
+	key := "events/20230102T030405Z-" + records[0].Id + ".ndjson"
+	r, err := fs.GetFile(key)
+	if err != nil {
+		t.Fatalf("Could not read %s:%v", key, err)
+	}
+	defer r.Close()
+	buf := new(strings.Builder)
+	if _, err := r.WriteTo(buf); err != nil {
+		t.Fatalf("Could not read %s:%v", key, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"first"`) || !strings.Contains(lines[1], `"second"`) {
+		t.Errorf("Expected one line per record, got %q", buf.String())
+	}
+}
@@ -0,0 +1,172 @@
+package sessionstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PocketBaseStore struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PocketBaseStoreConfig
+}
+
+type PocketBaseStoreConfig struct {
+	App core.App
+}
+
+func CreatePocketBaseStore(ctx context.Context,
+	logger log.Logger,
+	cfg PocketBaseStoreConfig) (*PocketBaseStore, error) {
+	t := &PocketBaseStore{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+// only a hash of the token is stored, so a leaked database does not leak valid tokens
+func hashToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+func (s *PocketBaseStore) CreateSession(ctx context.Context, userID, token, userAgent, ip string) (*domain.Session, error) {
+	coll, err := s.cfg.App.Dao().FindCollectionByNameOrId("sessions")
+	if err != nil {
+		return nil, err
+	}
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+	record := models.NewRecord(coll)
+	record.Set("user", userID)
+	record.Set("tokenHash", hashToken(token))
+	record.Set("userAgent", userAgent)
+	record.Set("ip", ip)
+	record.Set("lastSeen", time.Now())
+	if err := s.cfg.App.Dao().SaveRecord(record); err != nil {
+		return nil, err
+	}
+	return domain.SessionFromRecord(record), nil
+}
+
+func (s *PocketBaseStore) GetSessionByToken(ctx context.Context, token string) (*domain.Session, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sessions",op="GetSessionByToken"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindFirstRecordByData("sessions", "tokenHash", hashToken(token))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return domain.SessionFromRecord(record), nil
+}
+
+func (s *PocketBaseStore) TouchSession(ctx context.Context, id string, now time.Time) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sessions",op="TouchSession"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindRecordById("sessions", id)
+	if err != nil {
+		return err
+	}
+	record.Set("lastSeen", now)
+	return s.cfg.App.Dao().SaveRecord(record)
+}
+
+// ListSessions returns the sessions of a user or of all users if userID is empty
+func (s *PocketBaseStore) ListSessions(ctx context.Context, userID string) ([]*domain.Session, error) {
+	var exprs []dbx.Expression
+	if userID != "" {
+		exprs = append(exprs, dbx.HashExp{"user": userID})
+	}
+	records, err := s.cfg.App.Dao().FindRecordsByExpr("sessions", exprs...)
+	if err != nil {
+		return nil, err
+	}
+	var res []*domain.Session
+	for _, r := range records {
+		res = append(res, domain.SessionFromRecord(r))
+	}
+	return res, nil
+}
+
+func (s *PocketBaseStore) RevokeSession(ctx context.Context, id string) error {
+	record, err := s.cfg.App.Dao().FindRecordById("sessions", id)
+	if err == sql.ErrNoRows {
+		return errors.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.revoke(s.cfg.App.Dao(), record)
+}
+
+func (s *PocketBaseStore) revoke(dao *daos.Dao, record *models.Record) error {
+	if record.GetBool("revoked") {
+		return nil
+	}
+	record.Set("revoked", true)
+	record.Set("revokedAt", time.Now())
+	return dao.SaveRecord(record)
+}
+
+// RevokeUserSessions revokes all sessions of a user. Additionally all tokens of the user
+// are invalidated, including the ones that were never seen by nomad-ops.
+func (s *PocketBaseStore) RevokeUserSessions(ctx context.Context, userID string) error {
+	return s.cfg.App.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		user, err := txDao.FindRecordById("users", userID)
+		if err == sql.ErrNoRows {
+			return errors.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		records, err := txDao.FindRecordsByExpr("sessions", dbx.HashExp{"user": userID})
+		if err != nil {
+			return err
+		}
+		for _, r := range records {
+			if err := s.revoke(txDao, r); err != nil {
+				return err
+			}
+		}
+		if err := user.RefreshTokenKey(); err != nil {
+			return err
+		}
+		return txDao.SaveRecord(user)
+	})
+}
+
+// DeleteSessionsBefore removes all sessions created before the given time
+func (s *PocketBaseStore) DeleteSessionsBefore(ctx context.Context, t time.Time) (int, error) {
+	dt, err := types.ParseDateTime(t)
+	if err != nil {
+		return 0, err
+	}
+	records, err := s.cfg.App.Dao().FindRecordsByExpr("sessions",
+		dbx.NewExp("created < {:t}", dbx.Params{"t": dt.String()}))
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range records {
+		if err := s.cfg.App.Dao().DeleteRecord(r); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), nil
+}
@@ -2,11 +2,20 @@ package sourcestore
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/forms"
+	"github.com/pocketbase/pocketbase/models"
 
 	"github.com/nomad-ops/nomad-ops/backend/application"
 	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
 	"github.com/nomad-ops/nomad-ops/backend/utils/log"
 )
 
@@ -33,6 +42,7 @@ func CreatePocketBaseStore(ctx context.Context,
 }
 
 func (s *PocketBaseStore) ListSources(ctx context.Context, opts application.ListSourcesOptions) ([]*domain.Source, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="ListSources"}`).UpdateDuration(time.Now())
 	records, err := s.cfg.App.Dao().FindRecordsByExpr("sources")
 	if err != nil {
 		return nil, err
@@ -47,6 +57,7 @@ func (s *PocketBaseStore) ListSources(ctx context.Context, opts application.List
 }
 
 func (s *PocketBaseStore) SetSourceStatus(srcID string, status *domain.SourceStatus) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="SetSourceStatus"}`).UpdateDuration(time.Now())
 	record, err := s.cfg.App.Dao().FindRecordById("sources", srcID)
 	if err != nil {
 		return err
@@ -60,3 +71,145 @@ func (s *PocketBaseStore) SetSourceStatus(srcID string, status *domain.SourceSta
 
 	return nil
 }
+
+// CreateSourceFromTemplate creates a source with the settings of the template, the placeholder {name}
+// in text settings is replaced by the name of the source. Overrides replace text settings.
+func (s *PocketBaseStore) CreateSourceFromTemplate(ctx context.Context, templateID, name string, overrides map[string]string) (*domain.Source, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="CreateSourceFromTemplate"}`).UpdateDuration(time.Now())
+	tmpl, err := s.cfg.App.Dao().FindRecordById("source_templates", templateID)
+	if err != nil {
+		return nil, errors.ErrNotFound
+	}
+	return s.createSource(tmpl, name, overrides)
+}
+
+// CloneSource creates a source with the settings of another source, overrides replace text settings
+func (s *PocketBaseStore) CloneSource(ctx context.Context, id, name string, overrides map[string]string) (*domain.Source, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="CloneSource"}`).UpdateDuration(time.Now())
+	orig, err := s.cfg.App.Dao().FindRecordById("sources", id)
+	if err != nil {
+		return nil, errors.ErrNotFound
+	}
+	return s.createSource(orig, name, overrides)
+}
+
+// CreateSource creates a source with the settings, e.g. a source suggested by the import
+func (s *PocketBaseStore) CreateSource(ctx context.Context, settings map[string]any) (*domain.Source, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="CreateSource"}`).UpdateDuration(time.Now())
+	collection, err := s.cfg.App.Dao().FindCollectionByNameOrId("sources")
+	if err != nil {
+		return nil, err
+	}
+	record := models.NewRecord(collection)
+	form := forms.NewRecordUpsert(s.cfg.App, record)
+	if err := form.LoadData(settings); err != nil {
+		return nil, err
+	}
+	if err := form.Submit(); err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+	}
+	return domain.SourceFromRecord(record, false), nil
+}
+
+func (s *PocketBaseStore) createSource(from *models.Record, name string, overrides map[string]string) (*domain.Source, error) {
+	collection, err := s.cfg.App.Dao().FindCollectionByNameOrId("sources")
+	if err != nil {
+		return nil, err
+	}
+	record := models.NewRecord(collection)
+	form := forms.NewRecordUpsert(s.cfg.App, record)
+	data := map[string]any{
+		"name": name,
+	}
+	for _, field := range domain.SourceSettingFields {
+		v := from.Get(field)
+		if str, ok := v.(string); ok {
+			v = strings.ReplaceAll(str, domain.SourceTemplateNamePlaceholder, name)
+		}
+		data[field] = v
+	}
+	for field, v := range overrides {
+		data[field] = v
+	}
+	if err := form.LoadData(data); err != nil {
+		return nil, err
+	}
+	if err := form.Submit(); err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+	}
+	return domain.SourceFromRecord(record, false), nil
+}
+
+// SetBadgeToken stores the hash of the token of the status badge of the source, an empty token disables the badge
+func (s *PocketBaseStore) SetBadgeToken(ctx context.Context, id, token string) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="SetBadgeToken"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindRecordById("sources", id)
+	if err != nil {
+		return errors.ErrNotFound
+	}
+	hash := ""
+	if token != "" {
+		hash = hashToken(token)
+	}
+	record.Set("badgeTokenHash", hash)
+	return s.cfg.App.Dao().SaveRecord(record)
+}
+
+// GetSourceByBadgeToken returns the source with its status, if the token matches the one of its badge
+func (s *PocketBaseStore) GetSourceByBadgeToken(ctx context.Context, id, token string) (*domain.Source, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="GetSourceByBadgeToken"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindRecordById("sources", id)
+	if err != nil {
+		return nil, errors.ErrNotFound
+	}
+	hash := record.GetString("badgeTokenHash")
+	if hash == "" || token == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(hashToken(token))) != 1 {
+		return nil, errors.ErrNotFound
+	}
+	return domain.SourceFromRecord(record, true), nil
+}
+
+func hashToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+func (s *PocketBaseStore) SetExpiryWarned(ctx context.Context, id string, t time.Time) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="SetExpiryWarned"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindRecordById("sources", id)
+	if err != nil {
+		return err
+	}
+	record.Set("expiryWarnedAt", t)
+	return s.cfg.App.Dao().SaveRecord(record)
+}
+
+func (s *PocketBaseStore) SetRevision(ctx context.Context, id, revision string) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="SetRevision"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindRecordById("sources", id)
+	if err != nil {
+		return errors.ErrNotFound
+	}
+	record.Set("revision", revision)
+	record.Set("pendingPromotion", "")
+	return s.cfg.App.Dao().SaveRecord(record)
+}
+
+func (s *PocketBaseStore) SetPendingPromotion(ctx context.Context, id, commit string) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="SetPendingPromotion"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindRecordById("sources", id)
+	if err != nil {
+		return errors.ErrNotFound
+	}
+	record.Set("pendingPromotion", commit)
+	return s.cfg.App.Dao().SaveRecord(record)
+}
+
+func (s *PocketBaseStore) SetPaused(ctx context.Context, id string, paused bool) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="sources",op="SetPaused"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindRecordById("sources", id)
+	if err != nil {
+		return errors.ErrNotFound
+	}
+	record.Set("paused", paused)
+	return s.cfg.App.Dao().SaveRecord(record)
+}
@@ -0,0 +1,88 @@
+package statustokenstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/nomad-ops/nomad-ops/backend/domain"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PocketBaseStore struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PocketBaseStoreConfig
+}
+
+type PocketBaseStoreConfig struct {
+	App core.App
+}
+
+func CreatePocketBaseStore(ctx context.Context,
+	logger log.Logger,
+	cfg PocketBaseStoreConfig) (*PocketBaseStore, error) {
+	t := &PocketBaseStore{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+// only a hash of the token is stored, so a leaked database does not leak valid tokens
+func hashToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+func (s *PocketBaseStore) CreateStatusToken(ctx context.Context, tok *domain.StatusToken, token string) (*domain.StatusToken, error) {
+	coll, err := s.cfg.App.Dao().FindCollectionByNameOrId("status_tokens")
+	if err != nil {
+		return nil, err
+	}
+	record := models.NewRecord(coll)
+	record.Set("name", tok.Name)
+	record.Set("tokenHash", hashToken(token))
+	record.Set("teams", tok.TeamIDs)
+	record.Set("selector", tok.Selector)
+	if tok.ExpiresAt != nil {
+		record.Set("expiresAt", *tok.ExpiresAt)
+	}
+	if err := s.cfg.App.Dao().SaveRecord(record); err != nil {
+		return nil, domain.WithErrorCode(domain.ErrorCodeInvalidRequest, err)
+	}
+	return domain.StatusTokenFromRecord(record), nil
+}
+
+func (s *PocketBaseStore) GetStatusTokenByToken(ctx context.Context, token string) (*domain.StatusToken, error) {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="status_tokens",op="GetStatusTokenByToken"}`).UpdateDuration(time.Now())
+	if token == "" {
+		return nil, errors.ErrNotFound
+	}
+	record, err := s.cfg.App.Dao().FindFirstRecordByData("status_tokens", "tokenHash", hashToken(token))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return domain.StatusTokenFromRecord(record), nil
+}
+
+func (s *PocketBaseStore) TouchStatusToken(ctx context.Context, id string, now time.Time) error {
+	defer metrics.GetOrCreateHistogram(`nomad_ops_store_duration_seconds{store="status_tokens",op="TouchStatusToken"}`).UpdateDuration(time.Now())
+	record, err := s.cfg.App.Dao().FindRecordById("status_tokens", id)
+	if err != nil {
+		return err
+	}
+	record.Set("lastUsedAt", now)
+	return s.cfg.App.Dao().SaveRecord(record)
+}
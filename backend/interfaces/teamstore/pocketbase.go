@@ -91,3 +91,91 @@ func (s *PocketBaseStore) UpsertTeam(ctx context.Context, t *domain.Team) error
 	}
 	return nil
 }
+
+// SyncLDAPMembers sets the team memberships of the given LDAP users.
+// members maps team names to the IDs of the managed users that belong to it.
+// Only teams created by the LDAP sync and the mappedTeams, the teams of the group mapping, are synced,
+// whether they were created manually or not. Managed users are removed from these teams if they no longer
+// belong to them, other members stay untouched. Missing teams are created as external LDAP teams.
+func (s *PocketBaseStore) SyncLDAPMembers(ctx context.Context, managedUserIDs []string, members map[string][]string,
+	mappedTeams []string) error {
+	managed := map[string]bool{}
+	for _, id := range managedUserIDs {
+		managed[id] = true
+	}
+	mapped := map[string]bool{}
+	for _, name := range mappedTeams {
+		mapped[name] = true
+	}
+
+	return s.cfg.App.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		records, err := txDao.FindRecordsByExpr("teams")
+		if err != nil {
+			return err
+		}
+		existing := map[string]bool{}
+		for _, r := range records {
+			name := r.GetString("name")
+			existing[name] = true
+			if r.GetString("origin") != domain.TeamOriginLDAP && !mapped[name] {
+				// managed manually or by another directory
+				continue
+			}
+
+			current := r.GetStringSlice("members")
+			var res []string
+			for _, id := range current {
+				if !managed[id] {
+					res = append(res, id)
+				}
+			}
+			res = append(res, members[name]...)
+			if sameMembers(current, res) {
+				continue
+			}
+			s.logger.LogInfo(ctx, "Syncing members of team %s", name)
+			r.Set("members", res)
+			if err := txDao.SaveRecord(r); err != nil {
+				s.logger.LogError(ctx, "Could not sync team %s:%v", name, err)
+				return err
+			}
+		}
+
+		coll, err := txDao.FindCollectionByNameOrId("teams")
+		if err != nil {
+			return err
+		}
+		for name, ids := range members {
+			if existing[name] || len(ids) == 0 {
+				continue
+			}
+			s.logger.LogInfo(ctx, "Creating synced team %s", name)
+			record := models.NewRecord(coll)
+			record.Set("name", name)
+			record.Set("members", ids)
+			record.Set("external", true)
+			record.Set("origin", domain.TeamOriginLDAP)
+			if err := txDao.SaveRecord(record); err != nil {
+				s.logger.LogError(ctx, "Could not create team %s:%v", name, err)
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func sameMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	m := map[string]bool{}
+	for _, id := range a {
+		m[id] = true
+	}
+	for _, id := range b {
+		if !m[id] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package teamsync
+
+import (
+	"context"
+	"time"
+
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/ldap"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/userstore"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+// LDAPUserLookup resolves the current groups and teams of a user
+type LDAPUserLookup interface {
+	LookupUser(ctx context.Context, dn string) (*ldap.User, error)
+	// MappedTeams are the teams of the group mapping
+	MappedTeams() []string
+}
+
+// LDAPUserRepo lists all users that are linked to LDAP
+type LDAPUserRepo interface {
+	ListLDAPUsers(ctx context.Context) ([]userstore.ExternalUser, error)
+}
+
+// LDAPTeamRepo updates the memberships of users managed by LDAP
+type LDAPTeamRepo interface {
+	SyncLDAPMembers(ctx context.Context, managedUserIDs []string, members map[string][]string, mappedTeams []string) error
+}
+
+// LDAPTeamSync periodically refreshes the team memberships of all LDAP users,
+// so that permissions follow changes in the directory without the users logging in again.
+type LDAPTeamSync struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    LDAPTeamSyncConfig
+	lookup LDAPUserLookup
+	users  LDAPUserRepo
+	teams  LDAPTeamRepo
+}
+
+type LDAPTeamSyncConfig struct {
+	Interval time.Duration
+}
+
+func CreateLDAPTeamSync(ctx context.Context,
+	logger log.Logger,
+	cfg LDAPTeamSyncConfig,
+	lookup LDAPUserLookup,
+	users LDAPUserRepo,
+	teams LDAPTeamRepo) (*LDAPTeamSync, error) {
+	t := &LDAPTeamSync{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+		lookup: lookup,
+		users:  users,
+		teams:  teams,
+	}
+
+	if cfg.Interval > 0 {
+		go t.run()
+	}
+
+	return t, nil
+}
+
+func (s *LDAPTeamSync) run() {
+	t := time.NewTicker(s.cfg.Interval)
+	defer t.Stop()
+	for {
+		err := s.Sync(s.ctx)
+		if err != nil {
+			s.logger.LogError(s.ctx, "Could not sync LDAP teams:%v", err)
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Sync refreshes the team memberships of all LDAP users once
+func (s *LDAPTeamSync) Sync(ctx context.Context) error {
+	users, err := s.users.ListLDAPUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var managed []string
+	members := map[string][]string{}
+	for _, u := range users {
+		l, err := s.lookup.LookupUser(ctx, u.DN)
+		if err == errors.ErrNotFound {
+			// removed from the directory => remove from all synced teams
+			s.logger.LogInfo(ctx, "LDAP user %s no longer exists", u.DN)
+			managed = append(managed, u.ID)
+			continue
+		}
+		if err != nil {
+			// keep the current memberships if the directory is unavailable
+			s.logger.LogError(ctx, "Could not LookupUser %s:%v", u.DN, err)
+			continue
+		}
+		managed = append(managed, u.ID)
+		for _, team := range l.Teams {
+			members[team] = append(members[team], u.ID)
+		}
+	}
+
+	s.logger.LogTrace(ctx, "Syncing %d LDAP users into %d teams", len(managed), len(members))
+	return s.teams.SyncLDAPMembers(ctx, managed, members, s.lookup.MappedTeams())
+}
@@ -0,0 +1,89 @@
+package teamsync
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/ldap"
+	"github.com/nomad-ops/nomad-ops/backend/interfaces/userstore"
+	"github.com/nomad-ops/nomad-ops/backend/utils/errors"
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type fakeDirectory map[string]*ldap.User
+
+func (d fakeDirectory) LookupUser(ctx context.Context, dn string) (*ldap.User, error) {
+	if dn == "uid=broken" {
+		return nil, fmt.Errorf("connection refused")
+	}
+	u, ok := d[dn]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	return u, nil
+}
+
+func (d fakeDirectory) MappedTeams() []string {
+	return []string{"ops"}
+}
+
+type fakeUsers []userstore.ExternalUser
+
+func (u fakeUsers) ListLDAPUsers(ctx context.Context) ([]userstore.ExternalUser, error) {
+	return u, nil
+}
+
+type recordingTeams struct {
+	managed []string
+	members map[string][]string
+	mapped  []string
+}
+
+func (r *recordingTeams) SyncLDAPMembers(ctx context.Context, managedUserIDs []string, members map[string][]string,
+	mappedTeams []string) error {
+	r.managed = managedUserIDs
+	r.members = members
+	r.mapped = mappedTeams
+	return nil
+}
+
+func TestLDAPTeamSync(t *testing.T) {
+	ctx := context.Background()
+	teams := &recordingTeams{}
+	s, err := CreateLDAPTeamSync(ctx, log.NewSimpleLogger(false, "Test"), LDAPTeamSyncConfig{},
+		fakeDirectory{
+			"uid=a": {Teams: []string{"ops", "dev"}},
+			"uid=b": {Teams: []string{"ops"}},
+		},
+		fakeUsers{
+			{ID: "1", DN: "uid=a"},
+			{ID: "2", DN: "uid=b"},
+			{ID: "3", DN: "uid=removed"},
+			{ID: "4", DN: "uid=broken"},
+		},
+		teams)
+	if err != nil {
+		t.Fatalf("Could not CreateLDAPTeamSync:%v", err)
+	}
+
+	if err := s.Sync(ctx); err != nil {
+		t.Fatalf("Could not Sync:%v", err)
+	}
+
+	// the user that could not be looked up keeps its teams
+	if !reflect.DeepEqual(teams.managed, []string{"1", "2", "3"}) {
+		t.Errorf("Unexpected managed users %v", teams.managed)
+	}
+	expected := map[string][]string{
+		"ops": {"1", "2"},
+		"dev": {"1"},
+	}
+	if !reflect.DeepEqual(teams.members, expected) {
+		t.Errorf("Unexpected members %v", teams.members)
+	}
+	if !reflect.DeepEqual(teams.mapped, []string{"ops"}) {
+		t.Errorf("Expected the mapped teams to be synced, got %v", teams.mapped)
+	}
+}
@@ -0,0 +1,131 @@
+package userstore
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/security"
+
+	"github.com/nomad-ops/nomad-ops/backend/utils/log"
+)
+
+type PocketBaseStore struct {
+	ctx    context.Context
+	logger log.Logger
+	cfg    PocketBaseStoreConfig
+}
+
+type PocketBaseStoreConfig struct {
+	App core.App
+}
+
+// ExternalUser is a user managed by an external directory
+type ExternalUser struct {
+	ID       string
+	DN       string
+	Username string
+	Email    string
+	Name     string
+}
+
+var invalidUsernameChars = regexp.MustCompile(`[^\w\.\-]`)
+
+func CreatePocketBaseStore(ctx context.Context,
+	logger log.Logger,
+	cfg PocketBaseStoreConfig) (*PocketBaseStore, error) {
+	t := &PocketBaseStore{
+		ctx:    ctx,
+		logger: logger,
+		cfg:    cfg,
+	}
+
+	return t, nil
+}
+
+// UpsertLDAPUser returns the user linked to the given DN, creating it if necessary.
+// Existing local users are never linked automatically.
+func (s *PocketBaseStore) UpsertLDAPUser(ctx context.Context, u ExternalUser) (*models.Record, error) {
+	dao := s.cfg.App.Dao()
+	record, err := dao.FindFirstRecordByData("users", "ldapDN", u.DN)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if record == nil {
+		coll, err := dao.FindCollectionByNameOrId("users")
+		if err != nil {
+			return nil, err
+		}
+		record = models.NewRecord(coll)
+		record.Set("ldapDN", u.DN)
+		if err := record.SetUsername(s.freeUsername(u.Username)); err != nil {
+			return nil, err
+		}
+		// the password is never used, login happens through LDAP only
+		if err := record.SetPassword(security.RandomString(32)); err != nil {
+			return nil, err
+		}
+		s.logger.LogInfo(ctx, "Creating LDAP user %s", u.DN)
+	}
+
+	if u.Email != "" && u.Email != record.Email() && !s.emailTaken(u.Email, record.Id) {
+		if err := record.SetEmail(u.Email); err != nil {
+			return nil, err
+		}
+		if err := record.SetVerified(true); err != nil {
+			return nil, err
+		}
+	}
+	record.Set("name", u.Name)
+
+	if err := dao.SaveRecord(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ListLDAPUsers returns all users linked to an LDAP entry
+func (s *PocketBaseStore) ListLDAPUsers(ctx context.Context) ([]ExternalUser, error) {
+	records, err := s.cfg.App.Dao().FindRecordsByExpr("users",
+		dbx.Not(dbx.HashExp{"ldapDN": ""}))
+	if err != nil {
+		return nil, err
+	}
+	var res []ExternalUser
+	for _, r := range records {
+		res = append(res, ExternalUser{
+			ID:       r.Id,
+			DN:       r.GetString("ldapDN"),
+			Username: r.Username(),
+			Email:    r.Email(),
+			Name:     r.GetString("name"),
+		})
+	}
+	return res, nil
+}
+
+func (s *PocketBaseStore) freeUsername(username string) string {
+	name := invalidUsernameChars.ReplaceAllString(username, "_")
+	if name == "" {
+		name = "ldap"
+	}
+	if _, err := s.cfg.App.Dao().FindAuthRecordByUsername("users", name); err != nil {
+		return name
+	}
+	return name + "_" + security.RandomStringWithAlphabet(5, "abcdefghijklmnopqrstuvwxyz0123456789")
+}
+
+func (s *PocketBaseStore) emailTaken(email, ownID string) bool {
+	records, err := s.cfg.App.Dao().FindRecordsByExpr("users",
+		dbx.HashExp{"email": email},
+		dbx.Not(dbx.HashExp{"id": ownID}))
+	if err != nil {
+		s.logger.LogError(s.ctx, "Could not check email of LDAP user:%v", err)
+		return true
+	}
+	return len(records) != 0
+}
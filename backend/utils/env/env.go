@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nomad-ops/nomad-ops/backend/utils/log"
@@ -72,3 +73,14 @@ func GetInt64Env(ctx context.Context, logger log.Logger, key string, def int64)
 	logger.LogInfo(ctx, "Using value %v for %s", parsed, key)
 	return parsed
 }
+
+// GetListEnv returns the comma separated values of the variable, empty values are left out
+func GetListEnv(ctx context.Context, logger log.Logger, key string) []string {
+	res := []string{}
+	for _, s := range strings.Split(GetStringEnv(ctx, logger, key, ""), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
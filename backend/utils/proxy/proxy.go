@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Direct disables the proxy of an endpoint, even if HTTP_PROXY or HTTPS_PROXY are set
+const Direct = "direct"
+
+// Config is the proxy and the TLS trust of one kind of outbound traffic
+type Config struct {
+	// URL of the proxy. Empty uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY, Direct disables the proxy.
+	URL string
+	// NoProxy lists the hosts, domains and CIDRs that bypass the proxy, like NO_PROXY
+	NoProxy string
+	// CAFiles are PEM files of CA certificates trusted in addition to the system trust store
+	CAFiles []string
+	// Pins are the base64 encoded SHA-256 hashes of public keys, see PinOf. If set, the certificate chain of
+	// every server must contain one of them.
+	Pins []string
+}
+
+// Func returns the proxy function of an http.Transport
+func Func(cfg Config) (func(*http.Request) (*url.URL, error), error) {
+	switch cfg.URL {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case Direct:
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(cfg.URL)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %s", cfg.URL)
+	}
+	noProxy := parseNoProxy(cfg.NoProxy)
+	return func(r *http.Request) (*url.URL, error) {
+		if noProxy.matches(r.URL) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// Transport returns a copy of the default transport using the proxy and trusting the CA files
+func Transport(cfg Config) (*http.Transport, error) {
+	f, err := Func(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = f
+	t.TLSClientConfig = tlsCfg
+	return t, nil
+}
+
+type noProxyList struct {
+	all     bool
+	domains []string
+	cidrs   []*net.IPNet
+}
+
+func parseNoProxy(s string) noProxyList {
+	l := noProxyList{}
+	for _, e := range strings.Split(s, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		switch {
+		case e == "":
+		case e == "*":
+			l.all = true
+		default:
+			if _, n, err := net.ParseCIDR(e); err == nil {
+				l.cidrs = append(l.cidrs, n)
+				continue
+			}
+			if h, _, err := net.SplitHostPort(e); err == nil {
+				e = h
+			}
+			l.domains = append(l.domains, strings.TrimPrefix(strings.TrimPrefix(e, "*"), "."))
+		}
+	}
+	return l
+}
+
+// matches checks if the host of the url bypasses the proxy, domains match their subdomains as well
+func (l noProxyList) matches(u *url.URL) bool {
+	if l.all {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); ip != nil {
+		for _, n := range l.cidrs {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	for _, d := range l.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFunc(t *testing.T) {
+	f, err := Func(Config{URL: "http://proxy:3128", NoProxy: "internal.example.com, .corp, 10.0.0.0/8, localhost:8080"})
+	if err != nil {
+		t.Fatalf("Could not create proxy func:%v", err)
+	}
+	for u, proxied := range map[string]bool{
+		"https://github.com/org/repo":        true,
+		"https://internal.example.com/repo":  false,
+		"https://git.internal.example.com/x": false,
+		"https://notinternal.example.com/x":  true,
+		"https://git.corp/x":                 false,
+		"http://10.1.2.3:4646/v1/jobs":       false,
+		"http://localhost:9000/":             false,
+		"http://11.0.0.1/":                   true,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, u, nil)
+		p, err := f(req)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s:%v", u, err)
+		}
+		if (p != nil) != proxied {
+			t.Errorf("Expected proxied=%v for %s, got %v", proxied, u, p)
+		}
+	}
+
+	if f, err := Func(Config{URL: Direct}); err != nil || f != nil {
+		t.Errorf("Expected no proxy for %s", Direct)
+	}
+	if _, err := Func(Config{URL: "proxy"}); err == nil {
+		t.Errorf("Expected an invalid proxy url to be rejected")
+	}
+}
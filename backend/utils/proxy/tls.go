@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// tlsConfig returns the TLS configuration of the transport, nil if neither CA files nor pins are set
+func tlsConfig(cfg Config) (*tls.Config, error) {
+	if len(cfg.CAFiles) == 0 && len(cfg.Pins) == 0 {
+		return nil, nil
+	}
+	c := &tls.Config{}
+	if len(cfg.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, f := range cfg.CAFiles {
+			pem, err := os.ReadFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("could not read CA file %s: %w", f, err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA file %s", f)
+			}
+		}
+		c.RootCAs = pool
+	}
+	if len(cfg.Pins) > 0 {
+		pins := map[string]bool{}
+		for _, p := range cfg.Pins {
+			h, err := base64.StdEncoding.DecodeString(p)
+			if err != nil || len(h) != sha256.Size {
+				return nil, fmt.Errorf("invalid pin %s, expected a base64 encoded SHA-256 hash", p)
+			}
+			pins[p] = true
+		}
+		c.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(cs, pins)
+		}
+	}
+	return c, nil
+}
+
+// verifyPins checks that a certificate of the verified chains has one of the pinned public keys.
+// Without verification, e.g. for insecure webhooks, only the certificate of the server is considered.
+func verifyPins(cs tls.ConnectionState, pins map[string]bool) error {
+	var certs []*x509.Certificate
+	for _, chain := range cs.VerifiedChains {
+		certs = append(certs, chain...)
+	}
+	if len(cs.VerifiedChains) == 0 && len(cs.PeerCertificates) > 0 {
+		certs = cs.PeerCertificates[:1]
+	}
+	for _, cert := range certs {
+		if pins[PinOf(cert)] {
+			return nil
+		}
+	}
+	return fmt.Errorf("no certificate of %s matches the pinned public keys", cs.ServerName)
+}
+
+// PinOf returns the pin of the certificate, the base64 encoded SHA-256 hash of its subject public key info
+func PinOf(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTransportTrust(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	cert := srv.Certificate()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600)
+	if err != nil {
+		t.Fatalf("Could not write CA file:%v", err)
+	}
+
+	get := func(cfg Config) error {
+		cfg.URL = Direct
+		tr, err := Transport(cfg)
+		if err != nil {
+			t.Fatalf("Could not create transport:%v", err)
+		}
+		resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(Config{}); err == nil {
+		t.Errorf("Expected the certificate not to be trusted by the system trust store")
+	}
+	if err := get(Config{CAFiles: []string{caFile}}); err != nil {
+		t.Errorf("Expected the CA file to be trusted, got %v", err)
+	}
+	if err := get(Config{CAFiles: []string{caFile}, Pins: []string{PinOf(cert)}}); err != nil {
+		t.Errorf("Expected the pinned certificate to be accepted, got %v", err)
+	}
+	other := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	if err := get(Config{CAFiles: []string{caFile}, Pins: []string{other}}); err == nil {
+		t.Errorf("Expected a certificate without a pinned key to be rejected")
+	}
+
+	if _, err := Transport(Config{Pins: []string{"abc"}}); err == nil {
+		t.Errorf("Expected an invalid pin to be rejected")
+	}
+	if _, err := Transport(Config{CAFiles: []string{filepath.Join(t.TempDir(), "missing.pem")}}); err == nil {
+		t.Errorf("Expected a missing CA file to be rejected")
+	}
+}
@@ -1,7 +1,13 @@
+export type KeyType = "ssh" | "token" | "github-app" | "azure-identity" | "gcp-identity";
+
 export interface Key {
     id?: string
     name: string,
+    type?: KeyType,
     value: string,
+    username?: string,
+    appId?: string,
+    installationId?: string,
     created?: string,
     team?: string,
 }
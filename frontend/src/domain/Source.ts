@@ -23,6 +23,7 @@ export interface SourceStatus {
     jobs?: {[jobID: string]: any}
     status: string,
     message?: string,
+    errorCode?: string,
     lastCheckTime?: string
 }
 
@@ -2,7 +2,7 @@ import * as React from 'react';
 import Grid from '@mui/material/Grid';
 
 import RealTimeAccess from '../services/RealTimeAccess';
-import { Key } from '../domain/Key';
+import { Key, KeyType } from '../domain/Key';
 import Card from '@mui/material/Card';
 import CardHeader from '@mui/material/CardHeader';
 import Avatar from '@mui/material/Avatar';
@@ -24,12 +24,36 @@ import { FormInputDropdown } from '../components/form-components/FormInputDropdo
 
 interface IKeyFormInput {
     name: string;
+    type: KeyType;
     value: string;
+    username: string;
+    appId: string;
+    installationId: string;
 }
 
 const defaultKeyValues = {
     name: "",
-    value: ""
+    type: "ssh" as KeyType,
+    value: "",
+    username: "",
+    appId: "",
+    installationId: ""
+};
+
+const keyTypes = [
+    { label: "SSH key", value: "ssh" },
+    { label: "Token", value: "token" },
+    { label: "GitHub App", value: "github-app" },
+    { label: "Azure managed identity", value: "azure-identity" },
+    { label: "Google service account", value: "gcp-identity" },
+];
+
+const valueLabels: { [key in KeyType]: string } = {
+    "ssh": "Private key",
+    "token": "Token",
+    "github-app": "Private key of the GitHub App",
+    "azure-identity": "Client id of the identity (optional)",
+    "gcp-identity": "Service account (optional)",
 };
 
 export default function Keys() {
@@ -46,13 +70,18 @@ export default function Keys() {
     };
 
     const methods = useForm<IKeyFormInput>({ defaultValues: defaultKeyValues });
-    const { handleSubmit, reset, control } = methods;
+    const { handleSubmit, reset, control, watch } = methods;
+    const keyType = watch("type");
     const onSubmit = (data: IKeyFormInput) => {
         // TODO validate
 
         KeyService.createKey({
             name: data.name,
-            value: data.value
+            type: data.type,
+            value: data.value,
+            username: data.username,
+            appId: data.appId,
+            installationId: data.installationId
         })
             .then(() => {
                 NotificationService.notifySuccess(`Created key ${data.name}...`);
@@ -155,7 +184,7 @@ export default function Keys() {
                         />
                         <CardContent>
                             <Typography variant="body2" color="text.primary">
-                                * * * *
+                                {keyTypes.find((t) => t.value === (k.type || "ssh"))?.label}
                             </Typography>
                         </CardContent>
                         <CardActions disableSpacing >
@@ -238,10 +267,34 @@ export default function Keys() {
                     required={true}
                     autoFocus={true}
                     label="Name" />
+                <FormInputDropdown
+                    name="type"
+                    control={control}
+                    required={true}
+                    label="Type"
+                    options={keyTypes} />
                 <FormTextArea
                     name="value"
                     control={control}
-                    required={true} label={'Key value'} />
+                    required={keyType !== "azure-identity" && keyType !== "gcp-identity"}
+                    label={valueLabels[keyType] || 'Key value'} />
+                {keyType === "token" ? <FormInputText
+                    name="username"
+                    control={control}
+                    required={false}
+                    label="Username (optional)" /> : undefined}
+                {keyType === "github-app" ? <React.Fragment>
+                    <FormInputText
+                        name="appId"
+                        control={control}
+                        required={true}
+                        label="App ID" />
+                    <FormInputText
+                        name="installationId"
+                        control={control}
+                        required={true}
+                        label="Installation ID" />
+                </React.Fragment> : undefined}
                 <FormInputDropdown
                     name="team"
                     control={control}
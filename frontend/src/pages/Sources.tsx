@@ -636,7 +636,7 @@ export default function Sources() {
                                                     variant="body2"
                                                     color="text.primary"
                                                 >
-                                                    {k.status && k.status.message ? k.status.message : "No message available"}
+                                                    {k.status && k.status.errorCode ? `[${k.status.errorCode}] ` : ""}{k.status && k.status.message ? k.status.message : "No message available"}
                                                 </Typography>
                                             </React.Fragment>
                                         }
@@ -732,7 +732,8 @@ export default function Sources() {
                                         if (!k.id) {
                                             return;
                                         }
-                                        SourceService.deleteSource(k.id)
+                                        const prune = window.confirm(`Also delete the jobs, variables and namespaces of ${k.url} in Nomad? Cancel keeps them running.`) === true;
+                                        SourceService.deleteSource(k.id, k.name, prune)
                                             .then(() => {
                                                 NotificationService.notifySuccess(`Removed watcher on ${k.url}`);
                                             });
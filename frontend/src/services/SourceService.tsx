@@ -2,8 +2,14 @@ import { Source } from "../domain/Source";
 import pb from "./PocketBase";
 
 const SourceService = {
-    deleteSource: (id: string) => {
-        return pb.collection("sources").delete(id);
+    deleteSource: (id: string, name: string, prune: boolean) => {
+        return pb.send(`/api/nomad/sources/${id}/delete`, {
+            method: "POST",
+            body: {
+                confirm: name,
+                prune: prune
+            }
+        });
     },
     createSource: (src: Source) => {
         src.status = {